@@ -0,0 +1,103 @@
+package diagassert
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// allocsRuns is the number of calls AssertAllocs averages the allocations of.
+const allocsRuns = 100
+
+// AssertAllocs checks that fn makes at most maxAllocs allocations per call, measured as
+// testing.AllocsPerRun does, and outputs detailed diagnostic information if not:
+//
+//	AssertAllocs(t, 0, func() { _ = key.Hash() })
+//
+// The failure shows the average over 100 calls against the limit, by how much it is
+// exceeded, under ALLOCS, which the machine-readable section repeats between ALLOCS_START
+// and ALLOCS_END. Trailing args are handled as in Assert.
+func AssertAllocs(t TestingT, maxAllocs float64, fn func(), args ...interface{}) {
+	t.Helper()
+
+	measured := allocsPerRun(allocsRuns, fn)
+	recordAssertion(measured <= maxAllocs, "")
+	if measured <= maxAllocs {
+		return
+	}
+
+	failure := buildAllocsFailureInfo(measured, maxAllocs, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// allocsPerRun returns the average number of allocations of a call to fn, as
+// testing.AllocsPerRun does: after a warm-up call, over runs calls with GOMAXPROCS set to 1,
+// rounded down to a whole number.
+func allocsPerRun(runs int, fn func()) float64 {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	fn()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	mallocs := 0 - stats.Mallocs
+	for i := 0; i < runs; i++ {
+		fn()
+	}
+	runtime.ReadMemStats(&stats)
+	mallocs += stats.Mallocs
+
+	return float64(mallocs / uint64(runs))
+}
+
+// buildAllocsFailureInfo builds diagnostic information for a failed AssertAllocs.
+func buildAllocsFailureInfo(measured, limit float64, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source, or for function literals, the function and limit are named generically
+	fnText, limitText := "fn", "maxAllocs"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, "AssertAllocs"); err == nil && len(args) >= 3 {
+		fnText = evaluator.NameText(args[2], fnText)
+		if _, err := strconv.ParseFloat(args[1], 64); err == nil {
+			limitText = args[1]
+		} else {
+			limitText = evaluator.NameText(args[1], limitText)
+		}
+	}
+
+	result := evaluator.EvaluateAllocs(fnText, limitText, allocsRuns, measured, limit)
+	ctx.sections = append(ctx.sections, formatter.Section{Title: "ALLOCS", Lines: []string{
+		fmt.Sprintf("%s allocations per call, averaged over %d calls", formatAllocs(measured), allocsRuns),
+		fmt.Sprintf("%s over the limit of %s", formatAllocs(measured-limit), formatAllocs(limit)),
+		"run its benchmark with -benchmem, or the test with -memprofile, to see where they are made",
+	}})
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
+
+// formatAllocs formats an allocation count without a fractional part when it has none.
+func formatAllocs(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
@@ -0,0 +1,56 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+var allocSink []byte
+
+func TestAssertAllocs(t *testing.T) {
+	t.Run("functions within the limit pass", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		buf := make([]byte, 64)
+		AssertAllocs(mock, 0, func() { copy(buf, "diagassert") })
+		AssertAllocs(mock, 1, func() { allocSink = make([]byte, 64) })
+
+		if mock.Failed() {
+			t.Errorf("AssertAllocs should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure shows the measured count and the limit", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		maxAllocs := 1.0
+		grow := func() {
+			allocSink = make([]byte, 64)
+			allocSink = append(allocSink, make([]byte, 128)...)
+		}
+		AssertAllocs(mock, maxAllocs, grow, "hot path")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at allocs_test.go:",
+			"assert(testing.AllocsPerRun(100, grow) <= maxAllocs)",
+			"ALLOCS:\n  2 allocations per call, averaged over 100 calls\n  1 over the limit of 1\n  run its benchmark with -benchmem",
+			"CUSTOM MESSAGE:\nhot path",
+			"ALLOCS_START\n2 allocations per call, averaged over 100 calls\n1 over the limit of 1\n",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("function literals are named generically", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertAllocs(mock, 0, func() { allocSink = make([]byte, 64) })
+
+		if output := mock.GetOutput(); !strings.Contains(output, "assert(testing.AllocsPerRun(100, fn) <= 0)") {
+			t.Errorf("Output should name the function fn, got: %s", output)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+//go:build diagassert_analysis
+
+package analyzer
+
+import "golang.org/x/tools/go/analysis"
+
+// Analyzer reports the misuses of diagassert that Check finds, for drivers such as
+// singlechecker, multichecker and gopls. It is built with the diagassert_analysis tag only,
+// so that importing the package for Check does not compile golang.org/x/tools, which
+// diagassert's go.mod requires at a version that builds with Go 1.20.
+var Analyzer = &analysis.Analyzer{
+	Name: "diagassert",
+	Doc:  "report misuses of diagassert: side effects in assertions, interfaces compared with untyped numbers, unmatched V names and Require in goroutines",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, d := range Check(pass.Files, pass.TypesInfo) {
+		pass.Report(analysis.Diagnostic{Pos: d.Pos, Message: d.Message})
+	}
+	return nil, nil
+}
@@ -0,0 +1,44 @@
+//go:build diagassert_analysis
+
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestAnalyzer(t *testing.T) {
+	if err := analysis.Validate([]*analysis.Analyzer{Analyzer}); err != nil {
+		t.Fatal(err)
+	}
+
+	fset, file, info := typeCheck(t, `
+func TestReceive(t *testing.T) {
+	ch := make(chan int, 1)
+	ch <- 1
+	diagassert.Assert(t, <-ch == 1)
+}
+`)
+	var got []string
+	pass := &analysis.Pass{
+		Analyzer:  Analyzer,
+		Fset:      fset,
+		Files:     []*ast.File{file},
+		TypesInfo: info,
+		Report: func(d analysis.Diagnostic) {
+			got = append(got, fmt.Sprintf("%d: %s", fset.Position(d.Pos).Line-9, d.Message))
+		},
+	}
+	if _, err := Analyzer.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"5: <-ch in an assertion receives from a channel: the expression is evaluated again to explain a failure"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Analyzer reported %q, want %q", got, want)
+	}
+}
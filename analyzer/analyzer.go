@@ -0,0 +1,424 @@
+// Package analyzer finds misuses of diagassert that compile but make assertions misleading
+// or unsafe:
+//
+//   - asserted expressions with side effects, such as channel receives or calls of functions
+//     that assign to shared state, which run again or differently when a failure is explained;
+//   - == and != between an interface and an untyped numeric constant, which is false whenever
+//     the interface holds another numeric type, such as a float64 decoded from JSON;
+//   - V and Values names that match nothing in the asserted expression, so the diagram
+//     cannot show them;
+//   - Require in a goroutine the test spawned, where the t.FailNow it calls does not stop the
+//     test, unless it is made on a diagassert.Recorder.
+//
+// Check works on any type-checked package. Built with the diagassert_analysis tag, the
+// package also provides Analyzer for golang.org/x/tools/go/analysis drivers, and the
+// diagassert-vet command runs the same checks from go vet without that dependency:
+//
+//	go vet -vettool=$(which diagassert-vet) ./...
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/paveg/diagassert/internal/astutil"
+)
+
+// ImportPath is the import path of the package whose calls are checked.
+const ImportPath = "github.com/paveg/diagassert"
+
+// Diagnostic is a misuse found by Check.
+type Diagnostic struct {
+	Pos     token.Pos
+	Message string
+}
+
+// Check returns the misuses of diagassert in the files of a package, ordered by position.
+// info must hold the Types, Defs, Uses and Selections the files were type-checked with.
+func Check(files []*ast.File, info *types.Info) []Diagnostic {
+	c := &checker{info: info, decls: make(map[types.Object]*ast.FuncDecl), spawned: make(map[*ast.CallExpr]bool)}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+				c.decls[info.Defs[fn.Name]] = fn
+			}
+		}
+		ast.Inspect(file, c.findSpawned)
+	}
+
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				c.checkCall(call)
+			}
+			return true
+		})
+	}
+
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		return c.diagnostics[i].Pos < c.diagnostics[j].Pos
+	})
+	return c.diagnostics
+}
+
+type checker struct {
+	info        *types.Info
+	decls       map[types.Object]*ast.FuncDecl // Functions declared in the package, to look for side effects
+	spawned     map[*ast.CallExpr]bool         // Require calls made in goroutines
+	diagnostics []Diagnostic
+}
+
+func (c *checker) report(pos token.Pos, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// callee returns the name of the diagassert function a call calls, or "".
+func (c *checker) callee(call *ast.CallExpr) string {
+	var ident *ast.Ident
+	switch fun := astutil.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return ""
+	}
+	fn, ok := c.info.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != ImportPath {
+		return ""
+	}
+	return fn.Name()
+}
+
+// findSpawned records the Require calls in function literals started as goroutines, by a
+// go statement or by a Go method such as errgroup.Group.Go.
+func (c *checker) findSpawned(n ast.Node) bool {
+	var lits []*ast.FuncLit
+	switch n := n.(type) {
+	case *ast.GoStmt:
+		if lit, ok := astutil.Unparen(n.Call.Fun).(*ast.FuncLit); ok {
+			lits = append(lits, lit)
+		}
+	case *ast.CallExpr:
+		if sel, ok := astutil.Unparen(n.Fun).(*ast.SelectorExpr); ok && sel.Sel.Name == "Go" {
+			for _, arg := range n.Args {
+				if lit, ok := astutil.Unparen(arg).(*ast.FuncLit); ok {
+					lits = append(lits, lit)
+				}
+			}
+		}
+	}
+
+	for _, lit := range lits {
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok && c.callee(call) == "Require" && !c.onRecorder(call) {
+				c.spawned[call] = true
+			}
+			return true
+		})
+	}
+	return true
+}
+
+// onRecorder reports whether an assertion is made on a diagassert.Recorder, which is safe
+// to use from any goroutine.
+func (c *checker) onRecorder(call *ast.CallExpr) bool {
+	if len(call.Args) == 0 {
+		return false
+	}
+	ptr, ok := c.info.TypeOf(call.Args[0]).(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := ptr.Elem().(*types.Named)
+	return ok && named.Obj().Name() == "Recorder" && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == ImportPath
+}
+
+func (c *checker) checkCall(call *ast.CallExpr) {
+	name := c.callee(call)
+	if name != "Assert" && name != "Require" {
+		return
+	}
+
+	if c.spawned[call] {
+		c.report(call.Pos(), "Require in a goroutine: the t.FailNow it calls on failure must run on the test goroutine, so use Assert or a diagassert.Recorder")
+	}
+	if len(call.Args) < 2 {
+		return
+	}
+	expr := call.Args[1]
+	text := types.ExprString(expr)
+
+	if node, effect := c.sideEffect(expr); node != nil {
+		c.report(node.Pos(), "%s in an assertion %s: the expression is evaluated again to explain a failure", types.ExprString(node.(ast.Expr)), effect)
+	}
+	c.checkComparisons(expr)
+	c.checkValueNames(expr, text, call.Args[2:])
+}
+
+// sideEffect returns the first sub-expression of an asserted expression that changes state,
+// with what it does.
+func (c *checker) sideEffect(expr ast.Expr) (ast.Node, string) {
+	var node ast.Node
+	var effect string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if node != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Only run when called, which is checked below
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				node, effect = n, "receives from a channel"
+			}
+		case *ast.CallExpr:
+			if e := c.callEffect(n); e != "" {
+				node, effect = n, e
+			}
+		}
+		return node == nil
+	})
+	return node, effect
+}
+
+// callEffect describes what a call changes, looking into function literals and the
+// functions declared in the package, or returns "".
+func (c *checker) callEffect(call *ast.CallExpr) string {
+	if tv, ok := c.info.Types[call.Fun]; ok && tv.IsType() {
+		return ""
+	}
+
+	switch fun := astutil.Unparen(call.Fun).(type) {
+	case *ast.FuncLit:
+		if e := c.bodyEffect(fun, fun.Body); e != "" {
+			return "calls a function literal that " + e
+		}
+		return ""
+	case *ast.Ident:
+		if builtin, ok := c.info.Uses[fun].(*types.Builtin); ok {
+			if changesState(builtin.Name()) {
+				return "calls " + builtin.Name()
+			}
+			return ""
+		}
+	}
+
+	var ident *ast.Ident
+	switch fun := astutil.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	}
+	if ident == nil {
+		return ""
+	}
+	decl := c.decls[c.info.Uses[ident]]
+	if decl == nil {
+		return ""
+	}
+	if e := c.bodyEffect(decl, decl.Body); e != "" {
+		return fmt.Sprintf("calls %s, which %s", decl.Name.Name, e)
+	}
+	return ""
+}
+
+// bodyEffect describes the first statement of a function body that changes state outside
+// the function fn, or returns "".
+func (c *checker) bodyEffect(fn ast.Node, body *ast.BlockStmt) string {
+	var effect string
+	ast.Inspect(body, func(n ast.Node) bool {
+		if effect != "" {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				break
+			}
+			for _, lhs := range n.Lhs {
+				if c.outside(lhs, fn) {
+					effect = "assigns to " + types.ExprString(lhs)
+					break
+				}
+			}
+		case *ast.IncDecStmt:
+			if c.outside(n.X, fn) {
+				effect = "changes " + types.ExprString(n.X)
+			}
+		case *ast.SendStmt:
+			effect = "sends on " + types.ExprString(n.Chan)
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				effect = "receives from " + types.ExprString(n.X)
+			}
+		case *ast.CallExpr:
+			if ident, ok := astutil.Unparen(n.Fun).(*ast.Ident); ok {
+				if builtin, ok := c.info.Uses[ident].(*types.Builtin); ok && changesState(builtin.Name()) {
+					effect = "calls " + builtin.Name()
+				}
+			}
+		}
+		return effect == ""
+	})
+	return effect
+}
+
+// outside reports whether assigning to expr changes state declared outside the function fn:
+// package variables, captured variables, or anything reached through a pointer, slice or map.
+// Fields and elements of local structs and arrays stay inside.
+func (c *checker) outside(expr ast.Expr, fn ast.Node) bool {
+	switch e := astutil.Unparen(expr).(type) {
+	case *ast.Ident:
+		obj := c.info.Uses[e]
+		if obj == nil {
+			return false
+		}
+		return obj.Pos() < fn.Pos() || obj.Pos() >= fn.End()
+	case *ast.SelectorExpr:
+		sel, ok := c.info.Selections[e]
+		if ok && sel.Kind() == types.FieldVal && !sel.Indirect() {
+			return c.outside(e.X, fn)
+		}
+		return true
+	case *ast.IndexExpr:
+		if t := c.info.TypeOf(e.X); t != nil {
+			if _, ok := t.Underlying().(*types.Array); ok {
+				return c.outside(e.X, fn)
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// changesState reports whether a builtin changes its arguments or the goroutine's state.
+func changesState(builtin string) bool {
+	switch builtin {
+	case "close", "delete", "clear", "copy", "panic", "recover", "print", "println":
+		return true
+	}
+	return false
+}
+
+// checkComparisons reports == and != between an interface and an untyped numeric constant.
+// The constant takes its default type, so 1 only equals an interface holding an int.
+func (c *checker) checkComparisons(expr ast.Expr) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		binary, ok := n.(*ast.BinaryExpr)
+		if !ok || (binary.Op != token.EQL && binary.Op != token.NEQ) {
+			return true
+		}
+		for _, pair := range [][2]ast.Expr{{binary.X, binary.Y}, {binary.Y, binary.X}} {
+			value, number := pair[0], pair[1]
+			if !types.IsInterface(c.info.TypeOf(value)) || !c.untypedNumber(number) {
+				continue
+			}
+			c.report(binary.Pos(), "%s compares %s of type %s with %s as %s: it is false whenever %s holds another numeric type",
+				types.ExprString(binary), types.ExprString(value), c.typeString(c.info.TypeOf(value)),
+				types.ExprString(number), c.typeString(c.info.TypeOf(number)), types.ExprString(value))
+			break
+		}
+		return true
+	})
+}
+
+// untypedNumber reports whether expr is an untyped numeric constant, such as 1 or 2.5.
+func (c *checker) untypedNumber(expr ast.Expr) bool {
+	tv, ok := c.info.Types[expr]
+	if !ok || tv.Value == nil {
+		return false
+	}
+	switch tv.Value.Kind() {
+	case constant.Int, constant.Float, constant.Complex:
+	default:
+		return false
+	}
+	return c.untyped(expr)
+}
+
+// untyped reports whether a constant expression is built from literals and untyped
+// constants alone; the type checker records it with the type it was converted to.
+func (c *checker) untyped(expr ast.Expr) bool {
+	switch e := astutil.Unparen(expr).(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.Ident:
+		obj, ok := c.info.Uses[e].(*types.Const)
+		if !ok {
+			return false
+		}
+		basic, ok := obj.Type().(*types.Basic)
+		return ok && basic.Info()&types.IsUntyped != 0
+	case *ast.SelectorExpr:
+		return c.untyped(e.Sel)
+	case *ast.UnaryExpr:
+		return c.untyped(e.X)
+	case *ast.BinaryExpr:
+		return c.untyped(e.X) && c.untyped(e.Y)
+	}
+	return false
+}
+
+func (c *checker) typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string { return pkg.Name() })
+}
+
+// checkValueNames reports names given with V or Values that are neither an identifier nor
+// a sub-expression of the asserted expression, which is how values are matched to the diagram.
+func (c *checker) checkValueNames(expr ast.Expr, text string, args []ast.Expr) {
+	names := make(map[string]bool)
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if e, ok := n.(ast.Expr); ok {
+			names[types.ExprString(e)] = true
+		}
+		return true
+	})
+
+	check := func(name ast.Expr) {
+		tv, ok := c.info.Types[name]
+		if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+			return
+		}
+		if value := constant.StringVal(tv.Value); !names[value] {
+			c.report(name.Pos(), "%q matches nothing in %s, so the diagram cannot show its value", value, text)
+		}
+	}
+
+	for _, arg := range args {
+		switch arg := astutil.Unparen(arg).(type) {
+		case *ast.CallExpr:
+			if c.callee(arg) == "V" && len(arg.Args) == 2 {
+				check(arg.Args[0])
+			}
+		case *ast.CompositeLit:
+			if !c.isValues(arg) {
+				continue
+			}
+			for _, elt := range arg.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					check(kv.Key)
+				}
+			}
+		}
+	}
+}
+
+// isValues reports whether a composite literal builds a diagassert.Values.
+func (c *checker) isValues(lit *ast.CompositeLit) bool {
+	named, ok := c.info.TypeOf(lit).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == ImportPath && obj.Name() == "Values"
+}
@@ -0,0 +1,275 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+// diagassertSource declares the parts of diagassert the checks look at, so the tests do not
+// depend on export data for the real package.
+const diagassertSource = `package diagassert
+
+type TestingT interface{ Helper() }
+
+type Value struct{ Name string; Value interface{} }
+
+type Values map[string]interface{}
+
+type Recorder struct{}
+
+func (*Recorder) Helper() {}
+
+func Assert(t TestingT, expr bool, args ...interface{})  {}
+func Require(t TestingT, expr bool, args ...interface{}) {}
+func V(name string, value interface{}) Value             { return Value{name, value} }
+func NewRecorder(t TestingT) *Recorder                   { return &Recorder{} }
+`
+
+type testImporter map[string]*types.Package
+
+func (m testImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := m[path]; ok {
+		return pkg, nil
+	}
+	return importer.Default().Import(path)
+}
+
+// check type-checks a test file whose body follows the declarations every case shares and
+// returns its diagnostics as "line: message".
+func check(t *testing.T, body string) []string {
+	t.Helper()
+	fset, file, info := typeCheck(t, body)
+
+	var got []string
+	for _, d := range Check([]*ast.File{file}, info) {
+		got = append(got, fmt.Sprintf("%d: %s", fset.Position(d.Pos).Line-9, d.Message))
+	}
+	return got
+}
+
+// typeCheck parses and type-checks a test file whose body follows the declarations every
+// case shares. Its body starts at line 10.
+func typeCheck(t *testing.T, body string) (*token.FileSet, *ast.File, *types.Info) {
+	t.Helper()
+	fset := token.NewFileSet()
+
+	lib, err := parser.ParseFile(fset, "diagassert.go", diagassertSource, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	libPkg, err := (&types.Config{}).Check(ImportPath, fset, []*ast.File{lib}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := `package sample
+
+import (
+	"testing"
+
+	"github.com/paveg/diagassert"
+)
+
+var _ testing.TB
+` + body
+	file, err := parser.ParseFile(fset, "sample_test.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := &types.Config{Importer: testImporter{ImportPath: libPkg}}
+	if _, err := conf.Check("sample", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+	return fset, file, info
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "plain assertions",
+			body: `
+type user struct{ Age int }
+
+func (u user) Adult() bool { return u.Age >= 18 }
+
+func TestUser(t *testing.T) {
+	u := user{Age: 20}
+	diagassert.Assert(t, u.Adult() && u.Age < 100, diagassert.V("u.Age", u.Age))
+	diagassert.Require(t, u.Adult(), diagassert.Values{"u": u})
+}
+`,
+		},
+		{
+			name: "channel receive",
+			body: `
+func TestReceive(t *testing.T) {
+	ch := make(chan int, 1)
+	ch <- 1
+	diagassert.Assert(t, <-ch == 1)
+}
+`,
+			want: []string{"5: <-ch in an assertion receives from a channel: the expression is evaluated again to explain a failure"},
+		},
+		{
+			name: "method changing its receiver",
+			body: `
+type counter struct{ n int }
+
+func (c *counter) Next() int { c.n++; return c.n }
+
+func TestCounter(t *testing.T) {
+	c := &counter{}
+	diagassert.Assert(t, c.Next() == 1)
+}
+`,
+			want: []string{"8: c.Next() in an assertion calls Next, which changes c.n: the expression is evaluated again to explain a failure"},
+		},
+		{
+			name: "functions changing only their own variables",
+			body: `
+type pair struct{ a, b int }
+
+func (p pair) sum() int { p.a += p.b; return p.a }
+
+func double(xs [2]int) int {
+	total := 0
+	for _, x := range xs {
+		total += x
+	}
+	xs[0] = total
+	return 2 * total
+}
+
+func TestPure(t *testing.T) {
+	diagassert.Assert(t, pair{1, 2}.sum() == 3 && double([2]int{1, 2}) == 6)
+	diagassert.Assert(t, func() bool { ok := true; return ok }())
+}
+`,
+		},
+		{
+			name: "package state and builtins",
+			body: `
+var calls int
+
+func record() bool { calls = calls + 1; return true }
+
+func TestState(t *testing.T) {
+	m := map[string]int{"a": 1}
+	diagassert.Assert(t, record())
+	diagassert.Assert(t, func() bool { delete(m, "a"); return len(m) == 0 }())
+}
+`,
+			want: []string{
+				"8: record() in an assertion calls record, which assigns to calls: the expression is evaluated again to explain a failure",
+				"9: (func() bool literal)() in an assertion calls a function literal that calls delete: the expression is evaluated again to explain a failure",
+			},
+		},
+		{
+			name: "interface compared with an untyped number",
+			body: `
+const limit = 3
+
+func TestDecoded(t *testing.T) {
+	var decoded map[string]interface{}
+	var count int64
+	diagassert.Assert(t, decoded["count"] == 1)
+	diagassert.Assert(t, limit != decoded["limit"])
+	diagassert.Assert(t, decoded["name"] == "x" && decoded["count"] == count && decoded["count"] == int64(1))
+}
+`,
+			want: []string{
+				`7: decoded["count"] == 1 compares decoded["count"] of type interface{} with 1 as int: it is false whenever decoded["count"] holds another numeric type`,
+				`8: limit != decoded["limit"] compares decoded["limit"] of type interface{} with limit as int: it is false whenever decoded["limit"] holds another numeric type`,
+			},
+		},
+		{
+			name: "value names missing from the expression",
+			body: `
+func TestNames(t *testing.T) {
+	got, want := 1, 2
+	diagassert.Assert(t, got == want, diagassert.V("got", got), diagassert.V("expected", want))
+	diagassert.Assert(t, got+1 == want, diagassert.Values{"got + 1": got + 1, "got+1": got + 1})
+}
+`,
+			want: []string{
+				`4: "expected" matches nothing in got == want, so the diagram cannot show its value`,
+				`5: "got+1" matches nothing in got + 1 == want, so the diagram cannot show its value`,
+			},
+		},
+		{
+			name: "Require in goroutines",
+			body: `
+type group struct{}
+
+func (group) Go(f func() error) {}
+
+func TestWorkers(t *testing.T) {
+	done := make(chan bool)
+	go func() {
+		diagassert.Require(t, true)
+		diagassert.Assert(t, true)
+		done <- true
+	}()
+	var g group
+	g.Go(func() error {
+		diagassert.Require(t, true)
+		return nil
+	})
+	t.Run("sub", func(t *testing.T) {
+		diagassert.Require(t, true)
+	})
+	rec := diagassert.NewRecorder(t)
+	go func() {
+		diagassert.Require(rec, true)
+	}()
+}
+`,
+			want: []string{
+				"9: Require in a goroutine: the t.FailNow it calls on failure must run on the test goroutine, so use Assert or a diagassert.Recorder",
+				"15: Require in a goroutine: the t.FailNow it calls on failure must run on the test goroutine, so use Assert or a diagassert.Recorder",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := check(t, tt.body)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Check() reported:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCheckIgnoresOtherPackages(t *testing.T) {
+	got := check(t, `
+type fake struct{}
+
+func (fake) Assert(t *testing.T, ok bool, args ...interface{}) {}
+
+func TestFake(t *testing.T) {
+	var diag fake
+	ch := make(chan bool)
+	diag.Assert(t, <-ch, diagassert.V("missing", 1))
+}
+`)
+	if len(got) != 0 {
+		t.Errorf("Calls of other Assert functions should not be checked, got %q", got)
+	}
+}
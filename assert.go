@@ -2,10 +2,12 @@
 package diagassert
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"path/filepath"
-	"runtime"
 
+	"github.com/paveg/diagassert/internal/config"
 	"github.com/paveg/diagassert/internal/evaluator"
 	"github.com/paveg/diagassert/internal/formatter"
 	"github.com/paveg/diagassert/internal/parser"
@@ -18,6 +20,10 @@ type TestingT interface {
 	Helper()
 }
 
+// SchemaVersion is the version of the machine-readable section's format, reported on its
+// SCHEMA_VERSION line. Tools parsing the section should check it and ignore unknown keys.
+const SchemaVersion = formatter.SchemaVersion
+
 // Assert evaluates any expression and outputs detailed diagnostic information if false.
 // This is the primary API you need to remember.
 //
@@ -32,44 +38,167 @@ type TestingT interface {
 //	Assert(t, expr, "custom message", V("z", z))
 func Assert(t TestingT, expr bool, args ...interface{}) {
 	t.Helper()
+	recordAssertion(expr, "")
 
 	if expr {
 		return
 	}
 
 	// On failure: display detailed evaluation of the expression
-	ctx := NewAssertionContext(args...)
-	output := buildDiagnosticOutputWithContext(expr, ctx)
-	t.Error(output)
+	defer config.Hold()()
+	ctx := newContext(t, args)
+	failure := buildFailureInfo(expr, ctx)
+	reportFailure(t, failure, false)
 }
 
 // Require is the same as Assert, but terminates the test immediately on failure
 func Require(t TestingT, expr bool, args ...interface{}) {
 	t.Helper()
+	recordAssertion(expr, "")
 
 	if expr {
 		return
 	}
 
 	// On failure: display detailed evaluation of the expression and terminate
-	ctx := NewAssertionContext(args...)
-	output := buildDiagnosticOutputWithContext(expr, ctx)
-	t.Fatal(output)
+	defer config.Hold()()
+	ctx := newContext(t, args)
+	addGoroutines(ctx)
+	failure := buildFailureInfo(expr, ctx)
+	reportFailure(t, failure, true)
+}
+
+// AssertSkip is Assert for assertion helpers. The failure is reported skip frames above the
+// call, at the helper's caller for a skip of 1, and when the helper passes one of its own
+// parameters as expr, the expression is read from its caller too:
+//
+//	func checkPositive(t *testing.T, ok bool) {
+//		t.Helper()
+//		diagassert.AssertSkip(t, 1, ok)
+//	}
+//
+//	checkPositive(t, order.Total > 0) // Reported here, as order.Total > 0
+func AssertSkip(t TestingT, skip int, expr bool, args ...interface{}) {
+	t.Helper()
+	recordAssertion(expr, "")
+
+	if expr {
+		return
+	}
+
+	defer config.Hold()()
+	ctx := newContext(t, args)
+	ctx.CallerSkip += skip
+	failure := buildFailureInfo(expr, ctx)
+	reportFailure(t, failure, false)
+}
+
+// RequireSkip is the same as AssertSkip, but terminates the test immediately on failure
+func RequireSkip(t TestingT, skip int, expr bool, args ...interface{}) {
+	t.Helper()
+	recordAssertion(expr, "")
+
+	if expr {
+		return
+	}
+
+	defer config.Hold()()
+	ctx := newContext(t, args)
+	ctx.CallerSkip += skip
+	addGoroutines(ctx)
+	failure := buildFailureInfo(expr, ctx)
+	reportFailure(t, failure, true)
+}
+
+// reportFailure runs the failure hooks and reports the failure to t, terminating the test
+// if fatal is set. Failures made on a retry Attempt are only recorded: Retry reports them
+// once every attempt has failed. Failures made on a Recorder are reported by its Flush,
+// and those made on a SoftT together by its Report.
+// Repeated failures beyond the WithMaxRepeats limit only reach the hooks. Failures that
+// ExpectFail expects are logged instead of failing the test.
+func reportFailure(t TestingT, failure FailureInfo, fatal bool) {
+	t.Helper()
+	defer config.Hold()()
+
+	if attempt, ok := t.(*Attempt); ok {
+		attempt.recordFailure(failure, fatal)
+		return
+	}
+	if recorder, ok := t.(*Recorder); ok {
+		recorder.recordFailure(failure, fatal)
+		return
+	}
+	if soft, ok := t.(*SoftT); ok {
+		soft.recordFailure(failure, fatal)
+		return
+	}
+
+	failure.Test = testName(t)
+	if failure.Owners == nil {
+		failure.Owners = ownersOf(failure.File)
+	}
+	if failure.KnownIssue = expectedFailure(t); failure.KnownIssue != "" {
+		failure.Output = formatter.Message(formatter.MsgExpectedFailure, failure.KnownIssue) + "\n" + failure.Output
+	} else {
+		recordHistory(failure.fingerprint)
+	}
+	runFailureHooks(failure)
+	if !fatal && suppressRepeat(t, failure) {
+		return
+	}
+	routeOutput(failure.Test, failure.Output, failure.writers)
+	if failure.KnownIssue != "" {
+		reportExpectedFailure(t, encodeOutput(failure.Output), fatal)
+		return
+	}
+	if fatal {
+		t.Fatal(encodeOutput(failure.Output))
+		return
+	}
+	t.Error(encodeOutput(failure.Output))
 }
 
-// buildDiagnosticOutputWithContext builds diagnostic information with enhanced evaluation and context
-func buildDiagnosticOutputWithContext(exprResult bool, ctx *AssertionContext) string {
+// buildFailureInfo builds diagnostic information with enhanced evaluation and context
+func buildFailureInfo(exprResult bool, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
 	// Get caller information
-	pc, file, line, ok := runtime.Caller(2) // Same as original since we're called from Assert/Require
+	site, ok := locateCall(2, ctx) // Same as original since we're called from Assert/Require
 	if !ok {
-		return "ASSERTION FAILED (unable to get caller information)"
+		return FailureInfo{Output: "ASSERTION FAILED (unable to get caller information)", Messages: ctx.Messages, writers: ctx.Writers, maxRepeats: ctx.MaxRepeats}
 	}
+	file, line := site.reportFile, site.reportLine
 
-	// Extract expression from source code
-	expr, err := parser.ExtractExpression(file, line)
+	failure := FailureInfo{File: file, Line: line, Messages: ctx.Messages, Stack: site.stack, writers: ctx.Writers, maxRepeats: ctx.MaxRepeats}
+
+	// Attachments are written before formatting so the output can point at their files
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	failure.Attachments = ctx.Attachments
+
+	// Extract expression from source code, following it up through helpers that pass it on
+	extraction, err := parser.LocateExpression(site.file, site.line)
 	if err != nil {
-		return fmt.Sprintf("ASSERTION FAILED at %s:%d\n(unable to extract expression: %v)",
-			filepath.Base(file), line, err)
+		failure.Output = fmt.Sprintf("%s\n(unable to extract expression: %v)",
+			formatter.Message(formatter.MsgAssertionFailed, filepath.Base(file), line), err)
+		if errors.Is(err, fs.ErrNotExist) {
+			failure.Output += "\n" + missingSourceHint
+		}
+		return failure
+	}
+	expr := site.follow(extraction.Expr)
+	switch {
+	case extraction.Confidence == parser.Guessed && extraction.Deferred:
+		ctx.sections = append(ctx.sections, formatter.Section{Title: "EXPRESSION SOURCE", Lines: []string{
+			"confidence: guessed",
+			"taken from the last assertion deferred in the function returning at this line, which runs first; the others deferred there may be the one that failed",
+		}})
+	case extraction.Confidence == parser.Guessed:
+		ctx.sections = append(ctx.sections, formatter.Section{Title: "EXPRESSION SOURCE", Lines: []string{
+			"confidence: guessed",
+			fmt.Sprintf("taken from the call to %s at this line, which passes the test first, as no call to Assert or Require was found", extraction.Call),
+		}})
+	}
+	if ctx.negated {
+		expr = evaluator.Negate(expr)
 	}
 
 	// Perform enhanced evaluation with variable extraction
@@ -77,33 +206,110 @@ func buildDiagnosticOutputWithContext(exprResult bool, ctx *AssertionContext) st
 	if ctx.HasValues() {
 		// Use user-provided values when available
 		userValues := ctx.GetValuesMap()
-		result = evaluator.EvaluateWithValues(expr, exprResult, pc, userValues)
+		result = evaluator.EvaluateWithValues(expr, exprResult, site.pc, userValues)
 	} else {
 		// Use standard evaluation without user values
-		result = evaluator.Evaluate(expr, exprResult, pc)
+		result = evaluator.Evaluate(expr, exprResult, site.pc)
 	}
+	evaluator.ApplyDiffOptions(result.Tree, ctx.CmpOptions)
+	evaluator.Redact(result)
+	failure.fingerprint = formatter.Fingerprint(result, file, line)
+	addRepro(ctx, site, expr, result.Variables, failure.fingerprint)
 
 	// Build diagnostic output using enhanced formatter with context
 	opts := formatter.GetDefaultOptions()
 
-	// Convert our AssertionContext to formatter.AssertionContext
-	var formatterCtx *formatter.AssertionContext
-	if ctx.HasMessages() || ctx.HasValues() {
-		formatterCtx = &formatter.AssertionContext{
-			Messages: ctx.Messages,
-			Values:   make([]formatter.Value, len(ctx.Values)),
-		}
+	failure.Expression = expr
+	failure.Variables = result.Variables
+	failure.tree = result.Tree
+	failure.Output = formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result, toFormatterContext(ctx), opts)
+
+	return failure
+}
 
-		// Convert Value types
-		for i, v := range ctx.Values {
-			formatterCtx.Values[i] = formatter.Value{
-				Name:  v.Name,
-				Value: v.Value,
+// missingSourceHint follows a failure whose source file could not be found where the test runs.
+const missingSourceHint = `(the source is not available: set DIAGASSERT_SRC_ROOT to a checkout of it, ` +
+	`or build with diagassert-gen or -toolexec diagassert, which embed each expression in the test binary)`
+
+// configProblems describes what is wrong with the settings, which would otherwise go
+// unnoticed: a settings file that was ignored, and settings that keep their defaults.
+func configProblems() []string {
+	var problems []string
+	if err := config.Err(); err != nil {
+		problems = append(problems, err.Error(), "the settings file was ignored")
+	}
+	if _, err := config.Load(); err != nil {
+		var invalid config.ValidationError
+		if errors.As(err, &invalid) {
+			for _, problem := range invalid {
+				problems = append(problems, problem+", the default is used")
 			}
 		}
 	}
+	return problems
+}
+
+// toFormatterContext converts our AssertionContext to formatter.AssertionContext.
+// It returns nil when there is nothing to add to the output.
+func toFormatterContext(ctx *AssertionContext) *formatter.AssertionContext {
+	problems := configProblems()
+	if !ctx.HasMessages() && !ctx.HasValues() && !ctx.HasAttachments() && len(ctx.stack) == 0 && len(ctx.sections) == 0 &&
+		len(ctx.owners) == 0 && len(ctx.workers) == 0 &&
+		len(ctx.environ) == 0 && ctx.now == nil && len(ctx.times) == 0 && len(ctx.fixtures) == 0 && len(problems) == 0 {
+		return nil
+	}
+
+	formatterCtx := &formatter.AssertionContext{
+		Messages:    ctx.Messages,
+		Values:      make([]formatter.Value, len(ctx.Values)),
+		Attachments: make([]formatter.Attachment, len(ctx.Attachments)),
+		Owners:      ctx.owners,
+	}
+
+	// Convert Value types
+	for i, v := range ctx.Values {
+		formatterCtx.Values[i] = formatter.Value{
+			Name:  v.Name,
+			Value: evaluator.RedactValue(v.Name, v.Value),
+		}
+	}
+
+	for i, a := range ctx.Attachments {
+		formatterCtx.Attachments[i] = formatter.Attachment{
+			Name: a.Name,
+			MIME: a.MIME,
+			Size: len(a.Data),
+			Path: a.Path,
+		}
+	}
 
-	output := formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result, formatterCtx, opts)
+	formatterCtx.Sections = append(formatterCtx.Sections, ctx.sections...)
+	var outstanding []string
+	for _, w := range ctx.workers {
+		outstanding = append(outstanding, w.Outstanding()...)
+	}
+	if len(outstanding) > 0 {
+		formatterCtx.Sections = append(formatterCtx.Sections, formatter.Section{Title: "WORKERS", Lines: outstanding})
+	}
+	if len(ctx.fixtures) > 0 {
+		lines := make([]string, len(ctx.fixtures))
+		for i, v := range ctx.fixtures {
+			lines[i] = v.Name + ": " + formatter.ValueText(evaluator.RedactValue(v.Name, v.Value))
+		}
+		formatterCtx.Sections = append(formatterCtx.Sections, formatter.Section{Title: "FIXTURES", Lines: lines})
+	}
+	if lines := clockLines(ctx); len(lines) > 0 {
+		formatterCtx.Sections = append(formatterCtx.Sections, formatter.Section{Title: "CLOCK", Lines: lines})
+	}
+	if len(ctx.stack) > 0 {
+		formatterCtx.Sections = append(formatterCtx.Sections, formatter.Section{Title: "STACK", Lines: ctx.stack})
+	}
+	if len(ctx.environ) > 0 {
+		formatterCtx.Sections = append(formatterCtx.Sections, formatter.Section{Title: "RUNTIME", Lines: ctx.environ})
+	}
+	if len(problems) > 0 {
+		formatterCtx.Sections = append(formatterCtx.Sections, formatter.Section{Title: "CONFIG", Lines: problems})
+	}
 
-	return output
+	return formatterCtx
 }
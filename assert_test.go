@@ -1,13 +1,24 @@
 package diagassert
 
 import (
+	"net/http"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/paveg/diagassert/internal/formatter"
 	"github.com/paveg/diagassert/internal/testutil"
 )
 
+// TestMain checks, as the formatter's tests do, that no escape sequence written in a failure
+// is ever cut or moves the text, and keeps diagrams from wrapping at the width of the terminal
+// the tests may run in.
+func TestMain(m *testing.M) {
+	formatter.StrictANSI = true
+	os.Setenv("DIAGASSERT_MAX_WIDTH", "0")
+	os.Exit(m.Run())
+}
+
 // **Simple API: Use only Assert(t, expression)**
 
 func TestAssert_SimpleAPI(t *testing.T) {
@@ -78,6 +89,18 @@ func TestAssert_Expressions(t *testing.T) {
 			t.Errorf("Should show method call, got: %s", output)
 		}
 	})
+	t.Run("named constants", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		status := http.StatusNotFound
+
+		// Constants are resolved from the source, so no V() is needed for them
+		Assert(mock, status == http.StatusOK, V("status", status))
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "http.StatusOK = 200") {
+			t.Errorf("Should show the value of http.StatusOK, got: %s", output)
+		}
+	})
 }
 
 func TestAssert_NoLearningCurve(t *testing.T) {
@@ -153,6 +176,73 @@ func TestRequire(t *testing.T) {
 	})
 }
 
+func TestAssert_Aliased(t *testing.T) {
+	t.Run("through a variable", func(t *testing.T) {
+		da := Assert
+		mock := testutil.NewMockT()
+		x := 1
+		da(mock, x > 1, V("x", x))
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "assert(x > 1)") || strings.Contains(output, "EXPRESSION SOURCE") {
+			t.Errorf("The expression should be found through the variable, got: %s", output)
+		}
+	})
+
+	t.Run("guessed from a call passing the test", func(t *testing.T) {
+		helpers := struct {
+			check func(TestingT, bool, ...interface{})
+		}{check: Assert}
+		mock := testutil.NewMockT()
+		y := 2
+		func(t TestingT) {
+			helpers.check(t, y > 2, V("y", y))
+		}(mock)
+
+		output := mock.GetOutput()
+		expected := []string{
+			"assert(y > 2)",
+			"EXPRESSION SOURCE:\n  confidence: guessed\n  taken from the call to helpers.check at this line",
+			"EXPRESSION_SOURCE_START",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+}
+
+func TestAssert_Deferred(t *testing.T) {
+	t.Run("one deferred assertion", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		open := 2
+		func() {
+			defer Assert(mock, open == 0, V("open", open))
+		}()
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "assert(open == 0)") || strings.Contains(output, "EXPRESSION SOURCE") {
+			t.Errorf("The deferred expression should be found where its function returns, got: %s", output)
+		}
+	})
+
+	t.Run("several deferred assertions", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		open, closed := 2, 0
+		func() {
+			defer Assert(mock, open == 0, V("open", open))
+			defer Assert(mock, closed == 2, V("closed", closed))
+		}()
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "assert(closed == 2)") ||
+			!strings.Contains(output, "EXPRESSION SOURCE:\n  confidence: guessed\n  taken from the last assertion deferred") {
+			t.Errorf("The last deferred expression should be guessed, got: %s", output)
+		}
+	})
+}
+
 // Future enhancement tests (Phase 2 and beyond)
 func TestAssert_FutureEnhancements(t *testing.T) {
 	t.Skip("Future enhancements - showing variable values in output")
@@ -0,0 +1,108 @@
+package diagassert
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// Attachment is an artifact, such as a response body, a screenshot or a log, carried by a failure.
+type Attachment struct {
+	Name string // File name shown in the output, e.g. "response.json"
+	Data []byte // Raw content
+	MIME string // Media type, e.g. "application/json"
+	Path string // Where the attachment was written; set when the failure is reported
+}
+
+// Attach creates an Attachment that is written to the artifacts directory when the assertion fails.
+// Attachments are ignored when the assertion passes.
+//
+// Usage: diagassert.Assert(t, resp.StatusCode == 200, diagassert.Attach("body.json", body, "application/json"))
+func Attach(name string, data []byte, mime string) Attachment {
+	return Attachment{Name: name, Data: data, MIME: mime}
+}
+
+// artifactsDir returns the directory attachments and repro tests are written to.
+// DIAGASSERT_ARTIFACTS_DIR overrides the default of <tmp>/diagassert-artifacts.
+func artifactsDir() string {
+	if dir := config.Getenv("DIAGASSERT_ARTIFACTS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "diagassert-artifacts")
+}
+
+// writeAttachments writes every attachment under the artifacts directory and records its path.
+// File names start with the name of the test and the assertion's location, and a random part
+// keeps failures at one line, such as those of table cases or of repeated runs, from
+// overwriting each other. Attachments that cannot be written keep an empty Path.
+func writeAttachments(attachments []Attachment, test, file string, line int) {
+	if len(attachments) == 0 {
+		return
+	}
+
+	dir := artifactsDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+
+	prefix := fmt.Sprintf("%s-%d", strings.TrimSuffix(filepath.Base(file), ".go"), line)
+	if test != "" {
+		prefix = safeFileName(test) + "-" + prefix
+	}
+	for i := range attachments {
+		path, err := writeArtifact(dir, prefix+"-*-"+attachmentFileName(attachments[i]), attachments[i].Data)
+		if err != nil {
+			continue
+		}
+		attachments[i].Path = path
+	}
+}
+
+// writeArtifact writes data to a new file in dir named by pattern, as os.CreateTemp names
+// it, and returns its path.
+func writeArtifact(dir, pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// attachmentFileName makes an attachment's name safe to use as a file name and
+// adds an extension matching its MIME type when the name has none.
+func attachmentFileName(a Attachment) string {
+	name := safeFileName(a.Name)
+	if name == "" {
+		name = "attachment"
+	}
+
+	if filepath.Ext(name) == "" && a.MIME != "" {
+		if exts, err := mime.ExtensionsByType(a.MIME); err == nil && len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
+
+// safeFileName replaces the characters that are not allowed in file names on some systems.
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,104 @@
+package diagassert
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestAttach(t *testing.T) {
+	t.Run("failure writes attachments and lists them", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("DIAGASSERT_ARTIFACTS_DIR", dir)
+
+		var got FailureInfo
+		remove := OnFailure(func(f FailureInfo) { got = f })
+		defer remove()
+
+		mock := testutil.NewMockT()
+		status := 500
+		body := []byte(`{"error":"boom"}`)
+		Assert(mock, status == 200, Attach("response", body, "application/json"))
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "ATTACHMENTS:") {
+			t.Fatalf("Should contain attachments section, got: %s", output)
+		}
+		if !strings.Contains(output, "response (application/json, 16 B)") {
+			t.Errorf("Should list attachment with type and size, got: %s", output)
+		}
+
+		if len(got.Attachments) != 1 {
+			t.Fatalf("Expected 1 attachment in FailureInfo, got %d", len(got.Attachments))
+		}
+		path := got.Attachments[0].Path
+		if filepath.Dir(path) != dir || !strings.HasPrefix(filepath.Base(path), "attachments_test-") || filepath.Ext(path) != ".json" {
+			t.Errorf("Unexpected attachment path %q", path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != string(body) {
+			t.Errorf("Attachment content = %q (err %v), want %q", data, err, body)
+		}
+		if !strings.Contains(output, "ATTACHMENT: response (application/json, 16 bytes) => "+path) {
+			t.Errorf("Machine-readable section should record the path, got: %s", output)
+		}
+	})
+
+	t.Run("failures at one line keep their own files", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("DIAGASSERT_ARTIFACTS_DIR", dir)
+
+		var paths []string
+		remove := OnFailure(func(f FailureInfo) { paths = append(paths, f.Attachments[0].Path) })
+		defer remove()
+
+		for _, body := range []string{"first", "second"} {
+			mock := namedMockT{testutil.NewMockT(), "TestOrders/refund"}
+			Assert(mock, body == "", Attach("body.txt", []byte(body), "text/plain"))
+		}
+
+		if len(paths) != 2 || paths[0] == paths[1] {
+			t.Fatalf("Each failure should write a file of its own, got %q", paths)
+		}
+		for i, want := range []string{"first", "second"} {
+			if base := filepath.Base(paths[i]); !strings.HasPrefix(base, "TestOrders_refund-attachments_test-") || !strings.HasSuffix(base, "-body.txt") {
+				t.Errorf("Attachment should be named after the test and location, got %q", base)
+			}
+			if data, err := os.ReadFile(paths[i]); err != nil || string(data) != want {
+				t.Errorf("Attachment content = %q (err %v), want %q", data, err, want)
+			}
+		}
+	})
+
+	t.Run("passing assertion writes nothing", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "artifacts")
+		t.Setenv("DIAGASSERT_ARTIFACTS_DIR", dir)
+
+		Assert(testutil.NewMockT(), true, Attach("log.txt", []byte("ok"), "text/plain"))
+
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Artifacts directory should not be created, stat err = %v", err)
+		}
+	})
+}
+
+func TestAttachmentFileName(t *testing.T) {
+	tests := []struct {
+		attachment Attachment
+		want       string
+	}{
+		{Attach("body.json", nil, "application/json"), "body.json"},
+		{Attach("body", nil, "application/json"), "body.json"},
+		{Attach("../etc/passwd", nil, ""), ".._etc_passwd"},
+		{Attach("", nil, ""), "attachment"},
+	}
+
+	for _, tt := range tests {
+		if got := attachmentFileName(tt.attachment); got != tt.want {
+			t.Errorf("attachmentFileName(%q) = %q, want %q", tt.attachment.Name, got, tt.want)
+		}
+	}
+}
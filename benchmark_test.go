@@ -0,0 +1,98 @@
+package diagassert
+
+import (
+	"testing"
+
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// The failure path runs once per failed assertion; these benchmarks keep its cost in check.
+// Run with -benchmem. Once the caches are warm a simple failure takes under 50µs and 50
+// allocations, spread over evaluating the tree, drawing the diagram and the machine-readable
+// section. TestAssertFail_AllocationBudget fails when a change adds to them.
+
+func BenchmarkAssertFail_Simple(b *testing.B) {
+	x := 10
+	warm(b, func() { Assert(testutil.NewMockT(), x > 20) })
+	for i := 0; i < b.N; i++ {
+		Assert(testutil.NewMockT(), x > 20)
+	}
+}
+
+func BenchmarkAssertFail_Complex(b *testing.B) {
+	user := testutil.User{Name: "Alice", Age: 16}
+	items := []int{1, 2, 3}
+	warm(b, func() {
+		Assert(testutil.NewMockT(), user.Age >= 18 && len(items) > 5 || user.Name == "admin" && user.IsAdult(),
+			V("user", user), V("items", items))
+	})
+	for i := 0; i < b.N; i++ {
+		Assert(testutil.NewMockT(), user.Age >= 18 && len(items) > 5 || user.Name == "admin" && user.IsAdult(),
+			V("user", user), V("items", items))
+	}
+}
+
+func BenchmarkAssertFail_Unicode(b *testing.B) {
+	名前 := "こんにちは世界"
+	warm(b, func() { Assert(testutil.NewMockT(), 名前 == "さようなら", V("名前", 名前)) })
+	for i := 0; i < b.N; i++ {
+		Assert(testutil.NewMockT(), 名前 == "さようなら", V("名前", 名前))
+	}
+}
+
+// BenchmarkAssertPass measures what every passing assertion pays, which should stay a few
+// nanoseconds: no lock, no system call and no allocation.
+func BenchmarkAssertPass(b *testing.B) {
+	mock := testutil.NewMockT()
+	x := 30
+	Assert(mock, x > 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Assert(mock, x > 20)
+	}
+}
+
+func TestAssertPass_NoAllocations(t *testing.T) {
+	mock := testutil.NewMockT()
+	x := 30
+	pass := func() { Assert(mock, x > 20) }
+	if allocs := testing.AllocsPerRun(100, pass); allocs != 0 {
+		t.Errorf("a passing assertion makes %.0f allocations, want none", allocs)
+	}
+}
+
+// allocationBudget is the most allocations a simple failure may make: fewer than 50. Lower
+// it as the failure path gets cheaper.
+const allocationBudget = 49
+
+func TestAssertFail_AllocationBudget(t *testing.T) {
+	// Settings that add sections would add allocations too
+	for _, key := range []string{"DIAGASSERT_HISTORY", "DIAGASSERT_COVERAGE", "DIAGASSERT_STACK_DEPTH"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("DIAGASSERT_CONFIG", "false")
+	reloadPassSettings(t)
+	// The checks of strict mode are for tests only and are not counted
+	defer func(strict bool) { formatter.StrictANSI = strict }(formatter.StrictANSI)
+	formatter.StrictANSI = false
+
+	x := 10
+	fail := func() { Assert(testutil.NewMockT(), x > 20) }
+	fail()
+	if allocs := testing.AllocsPerRun(100, fail); allocs > allocationBudget {
+		t.Errorf("a simple failure makes %.0f allocations, want at most %d", allocs, allocationBudget)
+	}
+}
+
+// warm runs fail once so that the parse and settings caches are filled before timing starts,
+// and turns off the checks of strict mode, which users do not pay for.
+func warm(b *testing.B, fail func()) {
+	strict := formatter.StrictANSI
+	formatter.StrictANSI = false
+	b.Cleanup(func() { formatter.StrictANSI = strict })
+	fail()
+	b.ReportAllocs()
+	b.ResetTimer()
+}
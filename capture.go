@@ -0,0 +1,92 @@
+package diagassert
+
+import (
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// Capture records the values of the sub-expressions of an assertion as they are evaluated.
+// It is used by the code diagassert-gen writes and is not meant to be used by hand.
+type Capture struct {
+	values map[string]interface{}
+}
+
+// Cap records value as the value of the sub-expression with the given source text and
+// returns it unchanged, so that wrapping an operand does not change the expression.
+func Cap[T any](c *Capture, text string, value T) T {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[text] = value
+	return value
+}
+
+// AssertCaptured is the instrumented form of Assert written by diagassert-gen: expr is the
+// source text of the assertion and eval evaluates it, recording every sub-expression in the
+// Capture. A failure shows the recorded values instead of values recovered by reflection.
+func AssertCaptured(t TestingT, expr string, eval func(*Capture) bool, args ...interface{}) {
+	t.Helper()
+
+	c := &Capture{}
+	passed := eval(c)
+	recordAssertion(passed, expr)
+	if passed {
+		return
+	}
+
+	failure := buildCapturedFailureInfo(expr, c, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// RequireCaptured is the instrumented form of Require; see AssertCaptured.
+func RequireCaptured(t TestingT, expr string, eval func(*Capture) bool, args ...interface{}) {
+	t.Helper()
+
+	c := &Capture{}
+	passed := eval(c)
+	recordAssertion(passed, expr)
+	if passed {
+		return
+	}
+
+	ctx := newContext(t, args)
+	addGoroutines(ctx)
+	failure := buildCapturedFailureInfo(expr, c, ctx)
+	reportFailure(t, failure, true)
+}
+
+// buildCapturedFailureInfo builds diagnostic information for a failed instrumented assertion.
+// The generated files carry //line directives, so the caller is reported in the original test file.
+func buildCapturedFailureInfo(expr string, c *Capture, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, ok := locateCall(2, ctx)
+	if !ok {
+		return FailureInfo{Output: "ASSERTION FAILED (unable to get caller information)", Messages: ctx.Messages, writers: ctx.Writers, maxRepeats: ctx.MaxRepeats}
+	}
+	file, line := site.reportFile, site.reportLine
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+
+	result := evaluator.EvaluateCaptured(expr, false, c.values, ctx.GetValuesMap())
+	evaluator.ApplyDiffOptions(result.Tree, ctx.CmpOptions)
+	evaluator.Redact(result)
+	fingerprint := formatter.Fingerprint(result, file, line)
+	addRepro(ctx, site, expr, result.Variables, fingerprint)
+
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  expr,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: fingerprint,
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
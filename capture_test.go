@@ -0,0 +1,64 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+type inventory struct{ items map[string]int }
+
+func (inv *inventory) Take(name string) int {
+	inv.items[name]--
+	return inv.items[name]
+}
+
+func TestAssertCaptured(t *testing.T) {
+	t.Run("passing assertion", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertCaptured(mock, "x > 1", func(c *Capture) bool { return Cap(c, "x > 1", Cap(c, "x", 2) > 1) })
+
+		if mock.Failed() {
+			t.Errorf("AssertCaptured should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure shows the recorded values", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		inv := &inventory{items: map[string]int{"apple": 1}}
+
+		// As written by diagassert-gen for diagassert.Assert(t, inv.Take("apple") > 0, "stock")
+		AssertCaptured(mock, `inv.Take("apple") > 0`, func(c *Capture) bool {
+			return Cap(c, `inv.Take("apple") > 0`, Cap(c, `inv.Take("apple")`, Cap(c, "inv", inv).Take("apple")) > 0)
+		}, "stock")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at capture_test.go:",
+			`assert(inv.Take("apple") > 0)`,
+			`LIKELY CAUSE: inv.Take("apple") > 0 is false because inv.Take("apple") = 0`,
+			"CUSTOM MESSAGE:\nstock",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got:\n%s", part, output)
+			}
+		}
+		if inv.items["apple"] != 0 {
+			t.Errorf("The method should have been called once, stock is %d", inv.items["apple"])
+		}
+	})
+
+	t.Run("require panics on failure", func(t *testing.T) {
+		mock := testutil.NewMockT()
+
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("RequireCaptured should panic on failure")
+			}
+		}()
+
+		RequireCaptured(mock, "ok", func(c *Capture) bool { return Cap(c, "ok", false) })
+	})
+}
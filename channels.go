@@ -0,0 +1,174 @@
+package diagassert
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// Receives waits up to timeout for a value from ch and returns it, outputting detailed
+// diagnostic information if none arrives, replacing a select on time.After:
+//
+//	job := Receives(t, jobs, time.Second)
+//
+// It fails when the timeout passes, ch is closed, or the test's context, for tests with a
+// Context method, is done first. The failure shows the length and capacity of ch, how long
+// it waited and whether the test's context had expired. Trailing args are handled as in
+// Assert.
+func Receives[T any](t TestingT, ch <-chan T, timeout time.Duration, args ...interface{}) T {
+	t.Helper()
+
+	start := time.Now()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var zero T
+	var problem string
+	select {
+	case value, ok := <-ch:
+		if ok {
+			recordAssertion(true, "")
+			return value
+		}
+		problem = "%[1]s was closed before a value was received"
+	case <-timer.C:
+		problem = "no value was received from %[1]s within %[2]s"
+	case <-testDone(t):
+		problem = "the test's context was done before a value was received from %[1]s"
+	}
+	recordAssertion(false, "")
+
+	wait := channelWait{ch: ch, length: len(ch), capacity: cap(ch), timeout: timeout, waited: time.Since(start)}
+	failure := buildChannelFailureInfo("Receives", problem, wait, testContextState(t), newContext(t, args))
+	reportFailure(t, failure, false)
+	return zero
+}
+
+// Closed waits up to timeout for ch to be closed, receiving and discarding the values still
+// in it, and outputs detailed diagnostic information if it is not:
+//
+//	cancel()
+//	Closed(t, results, time.Second)
+//
+// It fails when the timeout passes or the test's context is done first, showing the length
+// and capacity of ch, how many values were drained, how long it waited and whether the
+// test's context had expired. Trailing args are handled as in Assert.
+func Closed[T any](t TestingT, ch <-chan T, timeout time.Duration, args ...interface{}) {
+	t.Helper()
+
+	start := time.Now()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	drained := 0
+	var problem string
+	for problem == "" {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				recordAssertion(true, "")
+				return
+			}
+			drained++
+		case <-timer.C:
+			problem = "%[1]s was not closed within %[2]s"
+		case <-testDone(t):
+			problem = "the test's context was done before %[1]s was closed"
+		}
+	}
+	recordAssertion(false, "")
+
+	wait := channelWait{ch: ch, length: len(ch), capacity: cap(ch), timeout: timeout, waited: time.Since(start), drained: drained}
+	failure := buildChannelFailureInfo("Closed", problem, wait, testContextState(t), newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// channelWait is what a channel helper saw while it waited.
+type channelWait struct {
+	ch               interface{}
+	length, capacity int
+	timeout, waited  time.Duration
+	drained          int // Values Closed received and discarded
+}
+
+// testDone returns the done channel of the test's context, for tests with a Context method
+// as *testing.T has from Go 1.24, or nil, which never delivers.
+func testDone(t TestingT) <-chan struct{} {
+	if c, ok := t.(interface{ Context() context.Context }); ok {
+		return c.Context().Done()
+	}
+	return nil
+}
+
+// testContextState describes the test's context for the CHANNEL section: whether it is
+// active or has expired, and how long is left before the test's deadline. It is "" for tests
+// with neither a Context nor a Deadline method.
+func testContextState(t TestingT) string {
+	c, hasContext := t.(interface{ Context() context.Context })
+	if hasContext && c.Context().Err() != nil {
+		return "expired: " + c.Context().Err().Error()
+	}
+	if deadline, ok := deadlineOf(t); ok {
+		if time.Now().After(deadline) {
+			return "expired: the test's deadline has passed"
+		}
+		return fmt.Sprintf("active, %s before the test's deadline", time.Until(deadline).Round(time.Millisecond))
+	}
+	if hasContext {
+		return "active"
+	}
+	return ""
+}
+
+// buildChannelFailureInfo builds diagnostic information for a failed Receives or Closed,
+// named by helper. problem is the note of the failure, with indexed verbs for the channel
+// and the timeout.
+func buildChannelFailureInfo(helper, problem string, wait channelWait, contextState string, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source the channel and the timeout are named generically
+	chText, timeoutText := "ch", "timeout"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, helper); err == nil && len(args) >= 3 {
+		chText, timeoutText = args[1], args[2]
+	}
+
+	note := fmt.Sprintf(problem, chText, wait.timeout)
+	result := evaluator.EvaluateWait(helper, chText, timeoutText, wait.ch, wait.timeout, note)
+	evaluator.Redact(result)
+
+	lines := []string{
+		fmt.Sprintf("%s: len %d, cap %d", chText, wait.length, wait.capacity),
+		fmt.Sprintf("waited %s of %s", wait.waited.Round(time.Microsecond), wait.timeout),
+	}
+	if helper == "Closed" {
+		lines = append(lines, fmt.Sprintf("drained %d values", wait.drained))
+	}
+	if contextState != "" {
+		lines = append(lines, "test context: "+contextState)
+	}
+	ctx.sections = append(ctx.sections, formatter.Section{Title: "CHANNEL", Lines: lines})
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
@@ -0,0 +1,117 @@
+package diagassert
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// contextT is a MockT with a test context, as *testing.T has.
+type contextT struct {
+	*testutil.MockT
+	ctx context.Context
+}
+
+func (c contextT) Context() context.Context { return c.ctx }
+
+func TestReceives(t *testing.T) {
+	t.Run("returns the value received", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		jobs := make(chan string)
+		go func() { jobs <- "build" }()
+
+		if job := Receives(mock, jobs, time.Second); job != "build" || mock.Failed() {
+			t.Errorf("Receives = %q, failed: %v, output: %s", job, mock.Failed(), mock.GetOutput())
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		jobs := make(chan int, 4)
+		Receives(mock, jobs, 10*time.Millisecond, "worker should enqueue")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at channels_test.go:",
+			"assert(Receives(jobs, 10*time.Millisecond))",
+			"no value was received from jobs within 10ms",
+			"CHANNEL:\n  jobs: len 0, cap 4\n  waited 1",
+			"CUSTOM MESSAGE:\nworker should enqueue",
+			"CHANNEL_START",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+		if strings.Contains(output, "test context") || strings.Contains(output, "%!") {
+			t.Errorf("A test without a context has no context state, got: %s", output)
+		}
+	})
+
+	t.Run("closed channel", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		jobs := make(chan int)
+		close(jobs)
+		Receives(mock, jobs, time.Second)
+
+		if output := mock.GetOutput(); !strings.Contains(output, "jobs was closed before a value was received") {
+			t.Errorf("Output should tell the channel was closed, got: %s", output)
+		}
+	})
+
+	t.Run("expired test context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		mock := contextT{MockT: testutil.NewMockT(), ctx: ctx}
+		jobs := make(chan int)
+		Receives(mock, jobs, time.Minute)
+
+		output := mock.GetOutput()
+		for _, part := range []string{"the test's context was done before a value was received from jobs", "test context: expired: context canceled"} {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+}
+
+func TestClosed(t *testing.T) {
+	t.Run("drains until closed", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		results := make(chan int, 3)
+		results <- 1
+		results <- 2
+		close(results)
+		Closed(mock, results, time.Second)
+
+		if mock.Failed() {
+			t.Errorf("Closed should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		mock := contextT{MockT: testutil.NewMockT(), ctx: context.Background()}
+		results := make(chan int, 3)
+		results <- 1
+		results <- 2
+		Closed(mock, results, 10*time.Millisecond)
+
+		output := mock.GetOutput()
+		expected := []string{
+			"assert(Closed(results, 10*time.Millisecond))",
+			"results was not closed within 10ms",
+			"results: len 0, cap 3",
+			"drained 2 values",
+			"test context: active",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package diagassert
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// Clock records the time an assertion is made at with now, such as time.Now or the Now
+// method of a fake clock:
+//
+//	diagassert.Assert(t, token.Valid(clock.Now()), diagassert.Clock(clock.Now), diagassert.V("expires", token.ExpiresAt))
+//
+// A failure lists the time under CLOCK with the time.Time values captured with V or Values,
+// each with its wall-clock and monotonic readings and how long before or after now it is.
+type Clock func() time.Time
+
+// monotonicReading matches the monotonic clock reading time.Time.String ends with.
+var monotonicReading = regexp.MustCompile(` m=([+-][0-9.]+)$`)
+
+// WithinDuration checks that a and b are at most delta apart, in either order, and outputs
+// detailed diagnostic information if not:
+//
+//	diagassert.WithinDuration(t, job.StartedAt, time.Now(), time.Second)
+//
+// The failure says by how much b is before or after a and exceeds delta, and lists both
+// under CLOCK with their wall-clock and monotonic readings. Times compare by their monotonic
+// readings when both have one, and otherwise by the wall clock, which the failure points out
+// when only one of them does. Trailing args are handled as in Assert.
+func WithinDuration(t TestingT, a, b time.Time, delta time.Duration, args ...interface{}) {
+	t.Helper()
+
+	apart := b.Sub(a)
+	if apart < 0 {
+		apart = -apart
+	}
+	recordAssertion(apart <= delta, "")
+	if apart <= delta {
+		return
+	}
+
+	failure := buildWithinDurationFailureInfo(a, b, delta, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// buildWithinDurationFailureInfo builds diagnostic information for a failed WithinDuration.
+func buildWithinDurationFailureInfo(a, b time.Time, delta time.Duration, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source the times are named generically
+	aText, bText, deltaText := "a", "b", "delta"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, "WithinDuration"); err == nil && len(args) >= 4 &&
+		!strings.Contains(args[1]+args[2]+args[3], "\n") {
+		aText, bText, deltaText = args[1], args[2], args[3]
+	}
+
+	apart := b.Sub(a)
+	if apart < 0 {
+		apart = -apart
+	}
+	note := fmt.Sprintf("%s is %s, %s more than the %s allowed", bText, relativeTime(b, a, aText),
+		humanDuration(apart-delta), humanDuration(delta))
+	result := evaluator.EvaluateWithinDuration(aText, bText, deltaText, a, b, delta, note)
+	evaluator.Redact(result)
+
+	ctx.times = append(ctx.times, Value{Name: aText, Value: a}, Value{Name: bText, Value: b})
+	if hasMonotonic(a) != hasMonotonic(b) {
+		ctx.clockNotes = append(ctx.clockNotes, fmt.Sprintf(
+			"only one of %s and %s has a monotonic reading, so they were compared by the wall clock", aText, bText))
+	}
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
+
+// clockLines returns the lines of the CLOCK section: the time recorded by Clock, the times
+// of the assertion and, once a time was recorded, the time.Time values captured with V.
+func clockLines(ctx *AssertionContext) []string {
+	times := ctx.times
+	if ctx.now != nil {
+		for _, v := range ctx.Values {
+			switch value := evaluator.RedactValue(v.Name, v.Value).(type) {
+			case time.Time:
+				times = append(times, Value{Name: v.Name, Value: value})
+			case *time.Time:
+				if value != nil {
+					times = append(times, Value{Name: v.Name, Value: *value})
+				}
+			}
+		}
+	}
+	if ctx.now == nil && len(times) == 0 {
+		return nil
+	}
+
+	var lines []string
+	if ctx.now != nil {
+		lines = append(lines, "now: "+describeTime(*ctx.now))
+	}
+	for _, v := range times {
+		tm := v.Value.(time.Time)
+		line := v.Name + ": " + describeTime(tm)
+		if ctx.now != nil {
+			line += ", " + relativeTime(tm, *ctx.now, "now")
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, ctx.clockNotes...)
+}
+
+// describeTime returns the wall-clock and monotonic readings of tm:
+// "2026-10-16T09:30:00.25Z, monotonic m=+0.512345678".
+func describeTime(tm time.Time) string {
+	text := tm.Round(0).Format(time.RFC3339Nano)
+	if m := monotonicReading.FindStringSubmatch(tm.String()); m != nil {
+		return text + ", monotonic m=" + m[1]
+	}
+	return text + ", no monotonic reading"
+}
+
+// hasMonotonic reports whether tm has a monotonic clock reading.
+func hasMonotonic(tm time.Time) bool {
+	return monotonicReading.MatchString(tm.String())
+}
+
+// relativeTime says how long before or after ref, named refName, tm is: "2.5s after start".
+func relativeTime(tm, ref time.Time, refName string) string {
+	switch d := tm.Sub(ref); {
+	case d > 0:
+		return humanDuration(d) + " after " + refName
+	case d < 0:
+		return humanDuration(-d) + " before " + refName
+	default:
+		return "the same as " + refName
+	}
+}
+
+// humanDuration rounds d to the precision that matters at its size, so that 2.500123456s
+// reads as 2.5s and 1.234567ms as 1.235ms.
+func humanDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	}
+	return d.String()
+}
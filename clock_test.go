@@ -0,0 +1,87 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestWithinDuration(t *testing.T) {
+	startedAt := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+
+	t.Run("passes within delta", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		WithinDuration(mock, startedAt, startedAt.Add(-time.Second), time.Second)
+		if mock.Failed() {
+			t.Errorf("Times a delta apart should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("reports how far apart", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		finishedAt := startedAt.Add(2500 * time.Millisecond)
+		WithinDuration(mock, startedAt, finishedAt, time.Second)
+
+		output := mock.GetOutput()
+		expected := []string{
+			"WithinDuration(startedAt, finishedAt, time.Second)",
+			"finishedAt is 2.5s after startedAt, 1.5s more than the 1s allowed",
+			"CLOCK:\n  startedAt: 2026-10-16T09:30:00Z, no monotonic reading\n  finishedAt: 2026-10-16T09:30:02.5Z, no monotonic reading\n",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("points out mixed clocks", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		now := time.Now()
+		WithinDuration(mock, now.Round(0).Add(-time.Minute), now, time.Second)
+		if output := mock.GetOutput(); !strings.Contains(output, "so they were compared by the wall clock") ||
+			!strings.Contains(output, "monotonic m=+") {
+			t.Errorf("Output should point out the wall-clock comparison, got: %s", output)
+		}
+	})
+}
+
+func TestClock(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	expires := now.Add(-90 * time.Second)
+
+	mock := testutil.NewMockT()
+	Assert(mock, expires.After(now), Clock(func() time.Time { return now }), V("expires", expires), V("ttl", time.Minute))
+	output := mock.GetOutput()
+	expected := "CLOCK:\n  now: 2026-10-16T09:30:00Z, no monotonic reading\n" +
+		"  expires: 2026-10-16T09:28:30Z, no monotonic reading, 1m30s before now\n"
+	if !strings.Contains(output, expected) {
+		t.Errorf("Output should contain %q, got: %s", expected, output)
+	}
+
+	// Captured times are only listed once the time of the assertion is recorded
+	mock = testutil.NewMockT()
+	Assert(mock, expires.After(now), V("expires", expires))
+	if output := mock.GetOutput(); strings.Contains(output, "CLOCK") {
+		t.Errorf("CLOCK should only be shown with Clock, got: %s", output)
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{2500123456 * time.Nanosecond, "2.5s"},
+		{1234567 * time.Nanosecond, "1.235ms"},
+		{61*time.Minute + 1500*time.Millisecond, "1h1m2s"},
+		{800 * time.Nanosecond, "800ns"},
+	}
+	for _, tt := range tests {
+		if got := humanDuration(tt.d); got != tt.expected {
+			t.Errorf("humanDuration(%v) = %q, want %q", tt.d, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+// tag selects the instrumented copies over the originals.
+const tag = "diagassert"
+
+// fileConstraint is the //go:build line of a file, if it has one.
+type fileConstraint struct {
+	expr       constraint.Expr // nil when the file has no constraint
+	start, end int             // Offsets of the line, without its newline
+	plusBuild  [][2]int        // Offsets of legacy // +build lines
+}
+
+// findConstraint locates the build constraint in the header of a file, which ends at the
+// package clause.
+func findConstraint(src []byte, packageOffset int) (fileConstraint, error) {
+	var c fileConstraint
+	for start := 0; start < packageOffset; {
+		end := bytes.IndexByte(src[start:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += start
+		}
+		line := string(src[start:end])
+		switch {
+		case constraint.IsGoBuild(line):
+			expr, err := constraint.Parse(line)
+			if err != nil {
+				return c, err
+			}
+			c.expr, c.start, c.end = expr, start, end
+		case constraint.IsPlusBuild(line):
+			c.plusBuild = append(c.plusBuild, [2]int{start, end})
+		}
+		start = end + 1
+	}
+	return c, nil
+}
+
+// exclude returns the original file with !diagassert added to its constraint, and the
+// number of lines added above the original first line.
+func (c fileConstraint) exclude(src []byte) ([]byte, int) {
+	not := &constraint.NotExpr{X: &constraint.TagExpr{Tag: tag}}
+	switch {
+	case c.expr == nil:
+		return append([]byte("//go:build "+not.String()+"\n\n"), src...), 2
+	case mentionsTag(c.expr):
+		return src, 0
+	}
+
+	line := "//go:build " + (&constraint.AndExpr{X: c.expr, Y: not}).String()
+	return replaceRange(src, c.start, c.end, line), 0
+}
+
+// include returns the constraint of the instrumented copy.
+func (c fileConstraint) include() string {
+	expr := constraint.Expr(&constraint.TagExpr{Tag: tag})
+	if original := withoutTag(c.expr); original != nil {
+		expr = &constraint.AndExpr{X: original, Y: expr}
+	}
+	return expr.String()
+}
+
+// blank empties the constraint lines of the instrumented copy's body, which is the original
+// file below a constraint of its own. Keeping the lines keeps the line numbers.
+func (c fileConstraint) blank(src []byte) []byte {
+	ranges := append([][2]int{}, c.plusBuild...)
+	if c.expr != nil {
+		ranges = append(ranges, [2]int{c.start, c.end})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] > ranges[j][0] })
+
+	for _, r := range ranges {
+		src = replaceRange(src, r[0], r[1], "")
+	}
+	return src
+}
+
+func mentionsTag(expr constraint.Expr) bool {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		return e.Tag == tag
+	case *constraint.NotExpr:
+		return mentionsTag(e.X)
+	case *constraint.AndExpr:
+		return mentionsTag(e.X) || mentionsTag(e.Y)
+	case *constraint.OrExpr:
+		return mentionsTag(e.X) || mentionsTag(e.Y)
+	}
+	return false
+}
+
+// withoutTag removes the "&& !diagassert" added by exclude, returning nil if nothing is left.
+func withoutTag(expr constraint.Expr) constraint.Expr {
+	switch e := expr.(type) {
+	case *constraint.NotExpr:
+		if t, ok := e.X.(*constraint.TagExpr); ok && t.Tag == tag {
+			return nil
+		}
+	case *constraint.AndExpr:
+		x, y := withoutTag(e.X), withoutTag(e.Y)
+		switch {
+		case x == nil:
+			return y
+		case y == nil:
+			return x
+		}
+		return &constraint.AndExpr{X: x, Y: y}
+	}
+	return expr
+}
+
+// cleanFile removes the instrumented copy of a test file and restores its constraint.
+func cleanFile(name string) error {
+	copyName := generatedName(name)
+	if data, err := os.ReadFile(copyName); err == nil {
+		if !strings.HasPrefix(string(data), "// Code generated by diagassert-gen") {
+			return fmt.Errorf("%s was not written by diagassert-gen", copyName)
+		}
+		if err := os.Remove(copyName); err != nil {
+			return err
+		}
+	}
+
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), name, src, parser.PackageClauseOnly)
+	if err != nil {
+		return err
+	}
+	c, err := findConstraint(src, int(file.Package)-1)
+	if err != nil || c.expr == nil || !mentionsTag(c.expr) {
+		return err
+	}
+
+	var restored []byte
+	if rest := withoutTag(c.expr); rest != nil {
+		restored = replaceRange(src, c.start, c.end, "//go:build "+rest.String())
+	} else {
+		// Remove the line and the blank line exclude put after it
+		end := c.end
+		for i := 0; i < 2 && end < len(src) && src[end] == '\n'; i++ {
+			end++
+		}
+		restored = replaceRange(src, c.start, end, "")
+	}
+	return os.WriteFile(name, restored, 0o644)
+}
+
+func replaceRange(src []byte, start, end int, text string) []byte {
+	out := append([]byte(nil), src[:start]...)
+	out = append(out, text...)
+	return append(out, src[end:]...)
+}
@@ -0,0 +1,123 @@
+// Command diagassert-gen writes instrumented copies of test files, in which every
+// diagassert.Assert and diagassert.Require call records the value of each sub-expression as
+// the test evaluates it. A failure then shows the values the test actually saw, including
+// the results of method calls and map lookups, without recovering anything by reflection.
+//
+// Usage:
+//
+//	diagassert-gen [-clean] [file_test.go...]
+//
+// It is meant to run with go generate, from a comment in the package it instruments:
+//
+//	//go:generate go run github.com/paveg/diagassert/cmd/diagassert-gen
+//
+// With no file named, every _test.go file in the current directory is processed. For each
+// one that asserts, diagassert-gen writes file_diag_test.go, a copy built only with the
+// diagassert tag in which the calls are rewritten to AssertCaptured and RequireCaptured, and
+// adds !diagassert to the original's build constraint. `go test` keeps running the original
+// tests; `go test -tags diagassert` runs the instrumented copies, whose line directives point
+// failures back at the original files. -clean removes the copies and the constraint.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `usage:
+  diagassert-gen [-clean] [file_test.go...]
+`
+
+func main() {
+	dir, err := os.Getwd()
+	if err == nil {
+		err = run(os.Args[1:], dir, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diagassert-gen:", err)
+		os.Exit(2)
+	}
+}
+
+// run instruments or cleans the named test files, or every test file in dir. It is separate
+// from main so tests can run it on a directory of their own.
+func run(args []string, dir string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("diagassert-gen", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	clean := flags.Bool("clean", false, "remove the generated files")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			io.WriteString(stdout, usage)
+			return nil
+		}
+		return fmt.Errorf("%v\n%s", err, usage)
+	}
+
+	files, err := testFiles(dir, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	if *clean {
+		for _, file := range files {
+			if err := cleanFile(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	pkgs, err := loadPackages(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		generated, err := instrumentFile(pkgs, file)
+		if err != nil {
+			return err
+		}
+		if generated != "" {
+			fmt.Fprintf(stdout, "wrote %s\n", filepath.Base(generated))
+		}
+	}
+	return nil
+}
+
+// testFiles returns the named files, or every test file in dir, leaving out generated copies.
+func testFiles(dir string, names []string) ([]string, error) {
+	if len(names) == 0 {
+		matches, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+		if err != nil {
+			return nil, err
+		}
+		names = matches
+	}
+
+	var files []string
+	for _, name := range names {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		if !strings.HasSuffix(name, "_test.go") {
+			return nil, fmt.Errorf("%s is not a test file", filepath.Base(name))
+		}
+		if isGeneratedName(name) {
+			continue
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+// generatedName returns the name of the instrumented copy of a test file.
+func generatedName(file string) string {
+	return strings.TrimSuffix(file, "_test.go") + "_diag_test.go"
+}
+
+func isGeneratedName(file string) bool {
+	return strings.HasSuffix(file, "_diag_test.go")
+}
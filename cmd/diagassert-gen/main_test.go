@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleSource = `package sample
+
+type counter struct{ n int }
+
+func (c *counter) Next() int { c.n++; return c.n }
+func (c counter) Value() int  { return c.n }
+
+const limit = 3
+`
+
+const sampleTest = `package sample
+
+import (
+	"testing"
+
+	"github.com/paveg/diagassert"
+)
+
+func TestSample(t *testing.T) {
+	c := counter{}
+	items := [3]int{1, 2, 3}
+	diagassert.Assert(t, c.Value() > limit, "msg")
+	diagassert.Require(t, c.Next() == 2 &&
+		len(items[1:]) == 3)
+}
+`
+
+// writePackage writes a package with one test file to a new directory.
+func writePackage(t *testing.T, test string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range map[string]string{"sample.go": sampleSource, "sample_test.go": test} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func generate(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, dir, &out); err != nil {
+		t.Fatalf("run(%v) failed: %v", args, err)
+	}
+	return out.String()
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestInstrument(t *testing.T) {
+	dir := writePackage(t, sampleTest)
+	if out := generate(t, dir); out != "wrote sample_diag_test.go\n" {
+		t.Errorf("Unexpected output: %q", out)
+	}
+
+	original := readFile(t, filepath.Join(dir, "sample_test.go"))
+	if original != "//go:build !diagassert\n\n"+sampleTest {
+		t.Errorf("The original should only gain a constraint, got:\n%s", original)
+	}
+
+	generated := readFile(t, filepath.Join(dir, "sample_diag_test.go"))
+	for _, want := range []string{
+		"// Code generated by diagassert-gen from sample_test.go. DO NOT EDIT.\n\n//go:build diagassert\n\n//line sample_test.go:3\npackage sample\n",
+		`diagassert.AssertCaptured(t, "c.Value() > limit", func(diagassertCapture *diagassert.Capture) bool { ` +
+			`diagassert.Cap(diagassertCapture, "limit", limit); ` +
+			`return diagassert.Cap(diagassertCapture, "c.Value() > limit", diagassert.Cap(diagassertCapture, "c.Value()", diagassert.Cap(diagassertCapture, "c", c).Value()) > limit) }, "msg")`,
+		// The pointer method needs an addressable receiver, and so does the slice of an array
+		`diagassert.Cap(diagassertCapture, "c.Next()", c.Next())`,
+		`len(items[1:])`,
+	} {
+		if !strings.Contains(generated, want) {
+			t.Errorf("Generated file should contain %q, got:\n%s", want, generated)
+		}
+	}
+
+	// Line numbers are kept, so the directive maps every line back to the original
+	if got, want := strings.Count(generated, "\n"), strings.Count(sampleTest, "\n")+5; got != want {
+		t.Errorf("Generated file has %d lines, want %d", got, want)
+	}
+}
+
+func TestInstrumentIsRepeatable(t *testing.T) {
+	dir := writePackage(t, sampleTest)
+	generate(t, dir)
+	first := readFile(t, filepath.Join(dir, "sample_diag_test.go"))
+
+	generate(t, dir)
+	if original := readFile(t, filepath.Join(dir, "sample_test.go")); strings.Count(original, "diagassert\n") != 1 {
+		t.Errorf("The constraint should be added once, got:\n%s", original)
+	}
+	// The second run copies the marked original, whose constraint line is left blank
+	second := readFile(t, filepath.Join(dir, "sample_diag_test.go"))
+	if want := strings.Replace(first, "//line sample_test.go:3\n", "//line sample_test.go:1\n\n\n", 1); second != want {
+		t.Errorf("Unexpected second copy:\n%s\nwant:\n%s", second, want)
+	}
+}
+
+func TestExistingConstraint(t *testing.T) {
+	dir := writePackage(t, "//go:build linux || darwin\n\n"+sampleTest)
+	generate(t, dir)
+
+	if original := readFile(t, filepath.Join(dir, "sample_test.go")); !strings.HasPrefix(original, "//go:build (linux || darwin) && !diagassert\n\npackage") {
+		t.Errorf("The constraint should be extended in place, got:\n%s", original)
+	}
+	generated := readFile(t, filepath.Join(dir, "sample_diag_test.go"))
+	if !strings.Contains(generated, "//go:build (linux || darwin) && diagassert\n\n//line sample_test.go:1\n\n\npackage") {
+		t.Errorf("The copy should keep the constraint and blank the original line, got:\n%s", generated)
+	}
+
+	generate(t, dir, "-clean")
+	if original := readFile(t, filepath.Join(dir, "sample_test.go")); original != "//go:build linux || darwin\n\n"+sampleTest {
+		t.Errorf("Clean should restore the constraint, got:\n%s", original)
+	}
+}
+
+func TestClean(t *testing.T) {
+	dir := writePackage(t, sampleTest)
+	generate(t, dir)
+	generate(t, dir, "-clean")
+
+	if original := readFile(t, filepath.Join(dir, "sample_test.go")); original != sampleTest {
+		t.Errorf("Clean should restore the original, got:\n%s", original)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sample_diag_test.go")); !os.IsNotExist(err) {
+		t.Errorf("Clean should remove the generated file, got %v", err)
+	}
+}
+
+func TestFilesWithoutAssertions(t *testing.T) {
+	test := "package sample\n\nimport \"testing\"\n\nfunc TestNothing(t *testing.T) {}\n"
+	dir := writePackage(t, test)
+	if out := generate(t, dir); out != "" {
+		t.Errorf("Nothing should be written, got %q", out)
+	}
+	if original := readFile(t, filepath.Join(dir, "sample_test.go")); original != test {
+		t.Errorf("The file should be left alone, got:\n%s", original)
+	}
+}
+
+func TestNotATestFile(t *testing.T) {
+	if err := run([]string{"sample.go"}, t.TempDir(), &bytes.Buffer{}); err == nil {
+		t.Error("Only test files should be accepted")
+	}
+}
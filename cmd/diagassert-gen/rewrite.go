@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/instrument"
+)
+
+// packages holds the type-checked files of a directory.
+type packages struct {
+	fset  *token.FileSet
+	files map[string]*ast.File
+	info  *types.Info
+}
+
+// loadPackages parses and type-checks the Go files in dir that the default build includes.
+func loadPackages(dir string) (*packages, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &packages{fset: token.NewFileSet(), files: make(map[string]*ast.File)}
+	var files []*ast.File
+	for _, name := range names {
+		if isGeneratedName(name) {
+			continue
+		}
+		if match, err := build.Default.MatchFile(dir, filepath.Base(name)); err != nil || !match {
+			continue
+		}
+		file, err := parser.ParseFile(p.fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.files[name] = file
+		files = append(files, file)
+	}
+
+	p.info = instrument.TypeCheck(p.fset, files,
+		fallbackImporter{importer.Default(), importer.ForCompiler(p.fset, "source", nil)})
+	return p, nil
+}
+
+// fallbackImporter imports from compiled export data and falls back to the source.
+type fallbackImporter []types.Importer
+
+func (f fallbackImporter) Import(path string) (*types.Package, error) {
+	var err error
+	for _, imp := range f {
+		var pkg *types.Package
+		if pkg, err = imp.Import(path); err == nil {
+			return pkg, nil
+		}
+	}
+	return nil, err
+}
+
+// instrumentFile writes the instrumented copy of a test file and marks the original to be
+// left out when it is built. It returns the copy's name, or "" when the file asserts nothing.
+func instrumentFile(p *packages, name string) (string, error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	file := p.files[name]
+	if file == nil {
+		// Excluded by its build constraint: instrument the calls without type information
+		if file, err = parser.ParseFile(p.fset, name, src, parser.ParseComments); err != nil {
+			return "", err
+		}
+	}
+
+	rewritten, ok := instrument.Rewrite(p.fset, file, p.info, src)
+	if !ok {
+		return "", nil
+	}
+
+	constraint, err := findConstraint(src, p.fset.Position(file.Package).Offset)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", filepath.Base(name), err)
+	}
+	original, shift := constraint.exclude(src)
+	body := constraint.blank(rewritten)
+
+	copyName := generatedName(name)
+	var out strings.Builder
+	fmt.Fprintf(&out, "// Code generated by diagassert-gen from %s. DO NOT EDIT.\n\n", filepath.Base(name))
+	fmt.Fprintf(&out, "//go:build %s\n\n", constraint.include())
+	fmt.Fprintf(&out, "//line %s:%d\n", filepath.Base(name), 1+shift)
+	out.Write(body)
+
+	if err := os.WriteFile(copyName, []byte(out.String()), 0o644); err != nil {
+		return "", err
+	}
+	if string(original) != string(src) {
+		if err := os.WriteFile(name, original, 0o644); err != nil {
+			return "", err
+		}
+	}
+	return copyName, nil
+}
@@ -0,0 +1,145 @@
+// Command diagassert-tui explores the failed assertions of a `go test` run in the terminal.
+// It reads the machine-readable blocks diagassert writes, from a saved log or from standard
+// input, and shows:
+//
+//   - the list of failures with their tests, locations and expressions
+//   - each failure's evaluation tree, with the path to the failing sub-expression expanded
+//   - the steps, captured values and variables behind the selected node
+//   - a test that reproduces the assertion with the recorded values, which can be copied
+//     to the clipboard (through the terminal's OSC 52 support)
+//
+// Usage:
+//
+//	go test ./... | diagassert-tui
+//	diagassert-tui test.log
+//	diagassert-tui -list test.log
+//
+// Keys: ↑/↓ or j/k move, enter opens or folds, ←/→ fold and unfold, r shows the
+// reproduction, c copies it, esc goes back, q quits.
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/paveg/diagassert/logparse"
+)
+
+func main() {
+	list := flag.Bool("list", false, "print the failures instead of opening the explorer")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: diagassert-tui [-list] [log file]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*list, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "diagassert-tui:", err)
+		os.Exit(1)
+	}
+}
+
+func run(list bool, args []string) error {
+	input, err := openInput(args)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	failures, err := logparse.Parse(input)
+	if err != nil {
+		return err
+	}
+
+	m := newModel(failures)
+	if list {
+		printList(os.Stdout, m)
+		return nil
+	}
+
+	term, err := openTerminal()
+	if err != nil {
+		printList(os.Stdout, m)
+		return err
+	}
+	defer term.close()
+
+	return explore(term, m)
+}
+
+// openInput opens the named log, or standard input when it is piped.
+func openInput(args []string) (io.ReadCloser, error) {
+	switch len(args) {
+	case 0:
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return nil, fmt.Errorf("no input: pipe `go test` output in or name a log file")
+		}
+		return io.NopCloser(os.Stdin), nil
+	case 1:
+		return os.Open(args[0])
+	}
+	return nil, fmt.Errorf("expected at most one log file, got %d", len(args))
+}
+
+// explore runs the interactive loop until the user quits.
+func explore(term *terminal, m *model) error {
+	// Alternate screen without a cursor, restored on exit
+	io.WriteString(term, "\x1b[?1049h\x1b[?25l")
+	defer io.WriteString(term, "\x1b[?25h\x1b[?1049l")
+
+	for !m.quit {
+		width, height := term.size()
+		io.WriteString(term, "\x1b[H\x1b[2J"+strings.Join(m.view(width, height), "\r\n"))
+
+		if m.clipboard != "" {
+			io.WriteString(term, "\x1b]52;c;"+base64.StdEncoding.EncodeToString([]byte(m.clipboard))+"\a")
+			m.clipboard = ""
+		}
+
+		key, err := term.readKey()
+		if err != nil {
+			return err
+		}
+		m.update(key)
+	}
+	return nil
+}
+
+// printList writes the failures without the interactive explorer.
+func printList(w io.Writer, m *model) {
+	for _, line := range m.listView(0) {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// decodeKey names the key behind the bytes of one read from the terminal.
+func decodeKey(b []byte) string {
+	switch s := string(b); s {
+	case "\x1b[A", "\x1bOA":
+		return keyUp
+	case "\x1b[B", "\x1bOB":
+		return keyDown
+	case "\x1b[C", "\x1bOC":
+		return keyRight
+	case "\x1b[D", "\x1bOD":
+		return keyLeft
+	case "\x1b[5~":
+		return keyPageUp
+	case "\x1b[6~":
+		return keyPageDown
+	case "\r", "\n":
+		return keyEnter
+	case "\x1b":
+		return keyEscape
+	case "\x7f", "\b":
+		return keyBackspace
+	case "\x03":
+		return keyInterrupt
+	default:
+		return s
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paveg/diagassert/logparse"
+)
+
+// Keys as reported by the terminal, after escape sequences are decoded.
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyLeft      = "left"
+	keyRight     = "right"
+	keyEnter     = "enter"
+	keyEscape    = "esc"
+	keyBackspace = "backspace"
+	keyInterrupt = "ctrl+c"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdown"
+)
+
+// model holds the explorer's state. update and view do no I/O, so the whole UI can be
+// driven from tests.
+type model struct {
+	failures []logparse.Failure
+	cursor   int     // Selected failure in the list
+	detail   *detail // Open failure, nil while the list is shown
+
+	status    string // Message shown in the footer until the next key
+	clipboard string // Text to copy with OSC 52 on the next draw
+	page      int    // Lines of a failure the last view showed, which pgup and pgdown scroll by
+	quit      bool
+}
+
+// detail is the state of the failure view.
+type detail struct {
+	failure   *logparse.Failure
+	roots     []*node
+	cursor    int // Selected row of the tree
+	showRepro bool
+	offset    int  // First line of the view shown, once wrapped to the screen's width
+	scrolled  bool // The view was scrolled by hand, so it need not follow the cursor
+}
+
+func newModel(failures []logparse.Failure) *model {
+	return &model{failures: failures}
+}
+
+func (m *model) open(i int) {
+	f := &m.failures[i]
+	m.detail = &detail{failure: f, roots: buildTree(f.Steps)}
+}
+
+// update applies a key press.
+func (m *model) update(key string) {
+	m.status = ""
+
+	switch key {
+	case "q", keyInterrupt:
+		m.quit = true
+		return
+	}
+
+	if m.detail == nil {
+		m.updateList(key)
+		return
+	}
+	m.updateDetail(key)
+}
+
+func (m *model) updateList(key string) {
+	switch key {
+	case keyUp, "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case keyDown, "j":
+		if m.cursor < len(m.failures)-1 {
+			m.cursor++
+		}
+	case keyEnter, keyRight, "l":
+		if len(m.failures) > 0 {
+			m.open(m.cursor)
+		}
+	}
+}
+
+func (m *model) updateDetail(key string) {
+	d := m.detail
+	rows := visibleRows(d.roots)
+
+	switch key {
+	case keyPageUp, "u":
+		d.offset -= m.page
+		d.scrolled = true
+		return
+	case keyPageDown, "d":
+		d.offset += m.page
+		d.scrolled = true
+		return
+	}
+	if d.showRepro {
+		// The reproduction has no cursor: the arrows scroll it
+		switch key {
+		case keyUp, "k":
+			d.offset--
+			d.scrolled = true
+			return
+		case keyDown, "j":
+			d.offset++
+			d.scrolled = true
+			return
+		}
+	}
+	d.scrolled = false
+
+	switch key {
+	case keyEscape, keyBackspace, "b":
+		if d.showRepro {
+			d.showRepro = false
+			d.offset = 0
+			return
+		}
+		m.detail = nil
+	case keyUp, "k":
+		if d.cursor > 0 {
+			d.cursor--
+		}
+	case keyDown, "j":
+		if d.cursor < len(rows)-1 {
+			d.cursor++
+		}
+	case keyEnter, " ":
+		if len(rows) > 0 {
+			n := rows[d.cursor].node
+			n.expanded = !n.expanded
+		}
+	case keyRight, "l":
+		if len(rows) > 0 {
+			rows[d.cursor].node.expanded = true
+		}
+	case keyLeft, "h":
+		if len(rows) == 0 {
+			return
+		}
+		r := rows[d.cursor]
+		if r.node.expanded && len(r.node.children) > 0 {
+			r.node.expanded = false
+			return
+		}
+		// Move to the parent
+		for i, other := range rows {
+			if other.node == r.parent {
+				d.cursor = i
+				break
+			}
+		}
+	case "r":
+		d.showRepro = !d.showRepro
+		d.offset = 0
+	case "c":
+		m.clipboard = reproduction(d.failure)
+		m.status = "Copied reproduction snippet to the clipboard"
+	}
+}
+
+// view renders the screen as lines no wider than width and no more than height.
+func (m *model) view(width, height int) []string {
+	var body []string
+	var footer string
+
+	switch {
+	case m.detail == nil:
+		body, footer = m.listView(height-2), "↑/↓ move · enter open · q quit"
+	case m.detail.showRepro:
+		body = append([]string{"REPRODUCTION", ""}, strings.Split(strings.TrimRight(reproduction(m.detail.failure), "\n"), "\n")...)
+		body, footer = m.scroll(body, -1, width, height-1, "↑/↓ pgup/pgdn scroll · c copy · r back · esc list · q quit")
+	default:
+		var cursor int
+		body, cursor = m.detailView()
+		body, footer = m.scroll(body, cursor, width, height-1, "↑/↓ move · enter toggle · ←/→ collapse/expand · pgup/pgdn scroll · r reproduction · c copy · esc list · q quit")
+	}
+
+	if m.status != "" {
+		footer = m.status
+	}
+
+	if len(body) > height-1 {
+		body = body[:height-1]
+	}
+	for len(body) < height-1 {
+		body = append(body, "")
+	}
+	lines := append(body, footer)
+
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return lines
+}
+
+// listView lists the failures, scrolled to keep the cursor on screen.
+func (m *model) listView(height int) []string {
+	lines := []string{fmt.Sprintf("diagassert · %d failed assertion%s", len(m.failures), plural(len(m.failures))), ""}
+	if len(m.failures) == 0 {
+		return append(lines, "  No machine-readable blocks found in the input.")
+	}
+
+	start := 0
+	if visible := height - len(lines); visible > 0 && m.cursor >= visible {
+		start = m.cursor - visible + 1
+	}
+	for i := start; i < len(m.failures); i++ {
+		f := m.failures[i]
+		marker := "  "
+		if i == m.cursor {
+			marker = "> "
+		}
+		location := f.File
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("%s%-30s %-24s %s", marker, f.Test, location, f.Expr), " "))
+	}
+	return lines
+}
+
+// scroll wraps the lines of the open failure to width and returns the height of them its
+// offset shows, moving the offset to keep the line at cursor on screen unless the view was
+// scrolled by hand; a cursor of -1 has no line. The footer tells which lines are shown when
+// they do not all fit.
+func (m *model) scroll(lines []string, cursor, width, height int, footer string) ([]string, string) {
+	d := m.detail
+	var wrapped []string
+	cursorAt := -1
+	for i, line := range lines {
+		if i == cursor {
+			cursorAt = len(wrapped)
+		}
+		wrapped = append(wrapped, wrap(line, width)...)
+	}
+
+	m.page = height
+	if cursorAt >= 0 && !d.scrolled {
+		if cursorAt < d.offset {
+			d.offset = cursorAt
+		}
+		if cursorAt >= d.offset+height {
+			d.offset = cursorAt - height + 1
+		}
+	}
+	if last := len(wrapped) - height; d.offset > last {
+		d.offset = last
+	}
+	if d.offset < 0 {
+		d.offset = 0
+	}
+
+	end := d.offset + height
+	if end >= len(wrapped) {
+		end = len(wrapped)
+	}
+	if d.offset > 0 || end < len(wrapped) {
+		footer = fmt.Sprintf("%d-%d/%d · %s", d.offset+1, end, len(wrapped), footer)
+	}
+	return wrapped[d.offset:end], footer
+}
+
+// detailView shows the tree of the open failure and the data of the selected node, and
+// returns the index of the selected row among its lines.
+func (m *model) detailView() ([]string, int) {
+	d := m.detail
+	f := d.failure
+
+	lines := []string{strings.TrimSpace(fmt.Sprintf("%s  %s:%d", f.Test, f.File, f.Line)), "EXPR: " + f.Expr}
+	if f.Reason != "" {
+		lines = append(lines, "REASON: "+f.Reason)
+	}
+	if f.Message != "" {
+		lines = append(lines, "MESSAGE: "+f.Message)
+	}
+
+	lines = append(lines, "", "EVALUATION TREE")
+	cursor := len(lines) + d.cursor
+	rows := visibleRows(d.roots)
+	if len(rows) == 0 {
+		lines = append(lines, "  (no evaluation steps recorded)")
+	}
+	for i, r := range rows {
+		marker := "  "
+		if i == d.cursor {
+			marker = "> "
+		}
+		fold := "  "
+		if len(r.node.children) > 0 {
+			fold = "▸ "
+			if r.node.expanded {
+				fold = "▾ "
+			}
+		}
+		label := r.node.text
+		if r.node.value != "" {
+			label += " => " + r.node.value
+		}
+		if f.FailingNodeID != "" && r.node.step.NodeID == f.FailingNodeID {
+			label += "   ← failing"
+		}
+		lines = append(lines, marker+strings.Repeat("  ", r.depth)+fold+label)
+	}
+
+	if len(rows) > 0 {
+		n := rows[d.cursor].node
+		lines = append(lines, "", "INSPECT", "  "+n.step.Text)
+		if n.step.NodeID != "" {
+			lines = append(lines, "  node "+n.step.NodeID)
+		}
+		if value, ok := f.Variables[n.text]; ok {
+			lines = append(lines, "  variable "+n.text+" = "+value)
+		}
+		for _, v := range f.Values {
+			if v.Name == n.text {
+				lines = append(lines, fmt.Sprintf("  captured %s = %s (%s)", v.Name, v.Value, v.Type))
+			}
+		}
+	}
+
+	if len(f.Values) > 0 {
+		lines = append(lines, "", "CAPTURED VALUES")
+		for _, v := range f.Values {
+			lines = append(lines, fmt.Sprintf("  %s = %s (%s)", v.Name, v.Value, v.Type))
+		}
+	}
+	for _, group := range []struct {
+		title string
+		items []string
+	}{{"NOTES", f.Notes}, {"DIFFERENCES", f.Diffs}} {
+		if len(group.items) == 0 {
+			continue
+		}
+		lines = append(lines, "", group.title)
+		for _, item := range group.items {
+			lines = append(lines, "  "+item)
+		}
+	}
+
+	return lines, cursor
+}
+
+// wrap splits a line into lines of width runes, continuing each under the indentation
+// of the first.
+func wrap(line string, width int) []string {
+	runes := []rune(line)
+	if width <= 0 || len(runes) <= width {
+		return []string{line}
+	}
+
+	indent := 0
+	for indent < len(runes) && runes[indent] == ' ' {
+		indent++
+	}
+	if indent >= width/2 {
+		indent = 0
+	}
+
+	lines := []string{string(runes[:width])}
+	for rest := runes[width:]; len(rest) > 0; {
+		n := width - indent
+		if n > len(rest) {
+			n = len(rest)
+		}
+		lines = append(lines, strings.Repeat(" ", indent)+string(rest[:n]))
+		rest = rest[n:]
+	}
+	return lines
+}
+
+// truncate shortens a line to width runes.
+func truncate(line string, width int) string {
+	if width <= 0 {
+		return line
+	}
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
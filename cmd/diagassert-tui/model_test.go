@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/logparse"
+)
+
+func sampleFailures() []logparse.Failure {
+	return []logparse.Failure{
+		{
+			Test: "TestUser", File: "user_test.go", Line: 7,
+			Expr: "u.Age >= 18 && x > 1", ExprID: "40abc208",
+			Reason: "u.Age >= 18 is false", FailingNodeID: "50fac3bc",
+			Variables: map[string]string{"u": "<u>", "u.Age": "16", "x": "5"},
+			Values:    []logparse.Value{{Name: "name", Value: "Bob", Type: "string"}},
+			Steps: []logparse.Step{
+				{Text: "`u` => <u>", NodeID: "ac1a6b94"},
+				{Text: "`u.Age` => 16", NodeID: "6ac272d0"},
+				{Text: "`18` => 18", NodeID: "68c26faa"},
+				{Text: "`u.Age >= 18` with 16 >= 18 => false", NodeID: "50fac3bc"},
+				{Text: "`x` => 5", NodeID: "6ea8e136"},
+				{Text: "`1` => 1", NodeID: "68a8d7c4"},
+				{Text: "`x > 1` with 5 > 1 => true", NodeID: "46fab3fe"},
+				{Text: "`u.Age >= 18 && x > 1` with false && true => false", NodeID: "d6627298"},
+			},
+		},
+		{Test: "TestOther", File: "other_test.go", Line: 3, Expr: "ok"},
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	roots := buildTree(sampleFailures()[0].Steps)
+	if len(roots) != 1 {
+		t.Fatalf("Expected one root, got %d", len(roots))
+	}
+
+	var got []string
+	for _, r := range visibleRows(roots) {
+		got = append(got, strings.Repeat(" ", r.depth)+r.node.text+" => "+r.node.value)
+	}
+	// Only the root and the false branch start expanded
+	want := []string{
+		"u.Age >= 18 && x > 1 => false",
+		" u.Age >= 18 => false",
+		"  u.Age => 16",
+		"  18 => 18",
+		" x > 1 => true",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected tree:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestBuildTreeRepeatedOperands(t *testing.T) {
+	roots := buildTree([]logparse.Step{{Text: "`x` => 1"}, {Text: "`x` => 1"}, {Text: "`x == x` with 1 == 1 => true"}})
+	if len(roots) != 1 || len(roots[0].children) != 2 {
+		t.Errorf("Both operands should be children of x == x, got %d roots", len(roots))
+	}
+}
+
+func TestModelNavigation(t *testing.T) {
+	m := newModel(sampleFailures())
+
+	m.update(keyDown)
+	m.update(keyDown)
+	if m.cursor != 1 {
+		t.Errorf("Cursor should stop at the last failure, got %d", m.cursor)
+	}
+	m.update(keyUp)
+	m.update(keyEnter)
+	if m.detail == nil || m.detail.failure.Test != "TestUser" {
+		t.Fatal("Enter should open the selected failure")
+	}
+
+	screen := strings.Join(m.view(120, 40), "\n")
+	for _, part := range []string{"EXPR: u.Age >= 18 && x > 1", "> ▾ u.Age >= 18 && x > 1 => false", "u.Age >= 18 => false   ← failing", "INSPECT", "node d6627298"} {
+		if !strings.Contains(screen, part) {
+			t.Errorf("Detail view should contain %q, got:\n%s", part, screen)
+		}
+	}
+
+	// Select u.Age and inspect its variable
+	m.update(keyDown)
+	m.update(keyDown)
+	screen = strings.Join(m.view(120, 40), "\n")
+	if !strings.Contains(screen, "variable u.Age = 16") {
+		t.Errorf("Inspecting u.Age should show its variable, got:\n%s", screen)
+	}
+
+	// Left moves to the parent, then folds it
+	m.update(keyLeft)
+	m.update(keyLeft)
+	if rows := visibleRows(m.detail.roots); len(rows) != 3 {
+		t.Errorf("Folding u.Age >= 18 should leave 3 rows, got %d", len(rows))
+	}
+
+	m.update("c")
+	if !strings.Contains(m.clipboard, "func TestRepro_40abc208") || m.status == "" {
+		t.Errorf("c should queue the reproduction for the clipboard, got %q", m.clipboard)
+	}
+
+	m.update(keyEscape)
+	if m.detail != nil {
+		t.Error("Escape should return to the list")
+	}
+	m.update("q")
+	if !m.quit {
+		t.Error("q should quit")
+	}
+}
+
+func TestViewFitsTheScreen(t *testing.T) {
+	m := newModel(sampleFailures())
+	lines := m.view(20, 5)
+	if len(lines) != 5 {
+		t.Errorf("Expected 5 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n > 20 {
+			t.Errorf("Line %q is %d runes wide", line, n)
+		}
+	}
+}
+
+func TestDetailViewScrolls(t *testing.T) {
+	failures := sampleFailures()
+	for i := 0; i < 30; i++ {
+		failures[0].Diffs = append(failures[0].Diffs, fmt.Sprintf("items[%d]: 1 != 2", i))
+	}
+	failures[0].Reason = strings.Repeat("long reason ", 10) + "end"
+	m := newModel(failures)
+	m.update(keyEnter)
+
+	screen := strings.Join(m.view(60, 10), "\n")
+	if !strings.Contains(screen, "> ▾ u.Age >= 18 && x > 1") || !strings.Contains(screen, "/") {
+		t.Errorf("The view should start at the top and tell which lines it shows, got:\n%s", screen)
+	}
+	if !strings.Contains(screen, "end") {
+		t.Errorf("Long lines should be wrapped rather than cut, got:\n%s", screen)
+	}
+
+	for i := 0; i < 20; i++ {
+		m.update(keyPageDown)
+	}
+	screen = strings.Join(m.view(60, 10), "\n")
+	if !strings.Contains(screen, "items[29]") {
+		t.Errorf("Paging down should reach the last difference, got:\n%s", screen)
+	}
+
+	// Moving the cursor brings its row back on screen
+	m.update(keyDown)
+	screen = strings.Join(m.view(60, 10), "\n")
+	if !strings.Contains(screen, "> ") || strings.Contains(screen, "items[29]") {
+		t.Errorf("Moving the cursor should scroll back to it, got:\n%s", screen)
+	}
+}
+
+func TestReproduction(t *testing.T) {
+	failures := sampleFailures()
+	got := reproduction(&failures[0])
+	want := `func TestRepro_40abc208(t *testing.T) {
+	// Reproduces TestUser at user_test.go:7
+	name := "Bob"
+	// u.Age = 16
+	x := 5
+	diagassert.Assert(t, u.Age >= 18 && x > 1)
+}
+`
+	if got != want {
+		t.Errorf("Unexpected reproduction:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	tests := map[string]string{
+		"\x1b[A":  keyUp,
+		"\x1bOB":  keyDown,
+		"\r":      keyEnter,
+		"\x1b":    keyEscape,
+		"\x03":    keyInterrupt,
+		"\x1b[6~": keyPageDown,
+		"j":       "j",
+	}
+	for input, want := range tests {
+		if got := decodeKey([]byte(input)); got != want {
+			t.Errorf("decodeKey(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/logparse"
+)
+
+// basicTypes lists the captured types whose printed values can be written back as Go literals.
+var basicTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "bool": true, "string": true,
+}
+
+// reproduction writes a test that repeats the failed assertion with the values the log
+// recorded. Values that cannot be written as literals are left as comments to fill in.
+func reproduction(f *logparse.Failure) string {
+	var b strings.Builder
+
+	name := f.ExprID
+	if name == "" {
+		name = "Failure"
+	}
+	fmt.Fprintf(&b, "func TestRepro_%s(t *testing.T) {\n", name)
+	if f.Test != "" || f.File != "" {
+		fmt.Fprintf(&b, "\t// Reproduces %s at %s:%d\n", f.Test, f.File, f.Line)
+	}
+
+	declared := map[string]bool{}
+	for _, v := range f.Values {
+		declared[v.Name] = true
+		b.WriteString("\t" + declaration(v.Name, v.Value, v.Type) + "\n")
+	}
+
+	names := make([]string, 0, len(f.Variables))
+	for name := range f.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := f.Variables[name]
+		if declared[name] || value == "<"+name+">" {
+			continue
+		}
+		if !token.IsIdentifier(name) {
+			fmt.Fprintf(&b, "\t// %s = %s\n", name, value)
+			continue
+		}
+		b.WriteString("\t" + declaration(name, value, inferType(value)) + "\n")
+	}
+
+	if strings.Contains(f.Expr, "$") {
+		fmt.Fprintf(&b, "\tdiagassert.AssertJSON(t, doc, %s)\n", strconv.Quote(f.Expr))
+	} else {
+		fmt.Fprintf(&b, "\tdiagassert.Assert(t, %s)\n", f.Expr)
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// declaration declares name with a literal value, or describes it in a comment when the
+// name is not an identifier or the value has no literal form.
+func declaration(name, value, typ string) string {
+	if !token.IsIdentifier(name) || !basicTypes[typ] {
+		if typ != "" {
+			return fmt.Sprintf("// %s = %s (%s)", name, value, typ)
+		}
+		return fmt.Sprintf("// %s = %s", name, value)
+	}
+
+	literal := value
+	if typ == "string" {
+		literal = strconv.Quote(value)
+	}
+	switch typ {
+	case "int", "string", "bool", "float64":
+		return fmt.Sprintf("%s := %s", name, literal)
+	}
+	return fmt.Sprintf("%s := %s(%s)", name, typ, literal)
+}
+
+// inferType guesses the type of a value listed under VARIABLES, which records no types.
+// Strings are printed without quotes there, so they are never inferred.
+func inferType(value string) string {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return "int"
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return "float64"
+	}
+	if value == "true" || value == "false" {
+		return "bool"
+	}
+	return ""
+}
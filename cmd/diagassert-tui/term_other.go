@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+type terminal struct{}
+
+func openTerminal() (*terminal, error) {
+	return nil, errors.New("the interactive explorer is not supported on this platform; use -list")
+}
+
+func (t *terminal) close()                      {}
+func (t *terminal) size() (int, int)            { return 80, 24 }
+func (t *terminal) Write(p []byte) (int, error) { return len(p), nil }
+func (t *terminal) readKey() (string, error)    { return "q", nil }
@@ -0,0 +1,74 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminal is the controlling terminal in raw mode. Keys are read from /dev/tty so that the
+// log itself can be piped to standard input.
+type terminal struct {
+	tty      *os.File
+	original unix.Termios
+}
+
+func openTerminal() (*terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fd := int(tty.Fd())
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+
+	t := &terminal{tty: tty, original: *termios}
+
+	// Raw input, as in cfmakeraw, but output processing stays on so "\n" starts a new line
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, termios); err != nil {
+		tty.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// close restores the terminal's original mode.
+func (t *terminal) close() {
+	unix.IoctlSetTermios(int(t.tty.Fd()), ioctlWriteTermios, &t.original)
+	t.tty.Close()
+}
+
+// size returns the terminal's width and height, or 80x24 when they are unknown.
+func (t *terminal) size() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(t.tty.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+func (t *terminal) Write(p []byte) (int, error) {
+	return t.tty.Write(p)
+}
+
+func (t *terminal) readKey() (string, error) {
+	buf := make([]byte, 16)
+	n, err := t.tty.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return decodeKey(buf[:n]), nil
+}
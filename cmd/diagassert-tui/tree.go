@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/paveg/diagassert/logparse"
+)
+
+// node is one evaluation step placed in the expression's tree.
+type node struct {
+	step     logparse.Step
+	text     string // Sub-expression, e.g. "u.Age >= 18"
+	value    string // What it evaluated to, e.g. "false"
+	children []*node
+	expanded bool
+}
+
+// row is a visible line of the tree view.
+type row struct {
+	node   *node
+	depth  int
+	parent *node
+}
+
+// buildTree nests the evaluation steps, which are listed in post-order: every operand comes
+// before the expression that uses it. A step adopts the steps on top of the stack whose text
+// is part of its own, which rebuilds the tree without the original AST.
+func buildTree(steps []logparse.Step) []*node {
+	var stack []*node
+	for _, step := range steps {
+		n := &node{step: step}
+		n.text, n.value = splitStep(step.Text)
+
+		first := len(stack)
+		for first > 0 {
+			top := stack[first-1]
+			if top.text == n.text || !strings.Contains(n.text, top.text) {
+				break
+			}
+			first--
+		}
+		n.children = append(n.children, stack[first:]...)
+		stack = append(stack[:first], n)
+	}
+
+	for _, root := range stack {
+		expandFailures(root)
+	}
+	return stack
+}
+
+// expandFailures opens the root and every node that evaluated to false, so that the path to
+// the failing sub-expression is visible without any key presses.
+func expandFailures(n *node) {
+	n.expanded = true
+	for _, child := range n.children {
+		if child.value == "false" {
+			expandFailures(child)
+		}
+	}
+}
+
+// splitStep separates "`x > 10` with 5 > 10 => false" into "x > 10" and "false".
+func splitStep(step string) (text, value string) {
+	text = step
+	if i := strings.LastIndex(step, " => "); i >= 0 {
+		text, value = step[:i], step[i+len(" => "):]
+	}
+	if strings.HasPrefix(text, "`") {
+		if end := strings.Index(text[1:], "`"); end >= 0 {
+			text = text[1 : end+1]
+		}
+	}
+	return text, value
+}
+
+// visibleRows flattens the expanded part of the tree.
+func visibleRows(roots []*node) []row {
+	var rows []row
+	var walk func(n, parent *node, depth int)
+	walk = func(n, parent *node, depth int) {
+		rows = append(rows, row{node: n, depth: depth, parent: parent})
+		if !n.expanded {
+			return
+		}
+		for _, child := range n.children {
+			walk(child, n, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, nil, 0)
+	}
+	return rows
+}
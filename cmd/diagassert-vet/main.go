@@ -0,0 +1,174 @@
+// Command diagassert-vet reports misuses of diagassert from go vet: assertions with side
+// effects, interfaces compared with untyped numbers, V names that match nothing in the
+// asserted expression and Require in goroutines. See the analyzer package for the checks.
+//
+// Usage:
+//
+//	go install github.com/paveg/diagassert/cmd/diagassert-vet@latest
+//	go vet -vettool=$(which diagassert-vet) ./...
+//
+// The go command runs it once per package with a JSON description of the package, as it
+// runs vet's own analyzers, so it needs no dependency beyond the standard library.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/analyzer"
+)
+
+const usage = `usage:
+  go vet -vettool=$(which diagassert-vet) [packages]
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run handles one invocation by the go command and returns the exit code: 1 when misuses
+// were reported.
+func run(args []string, stdout, stderr io.Writer) int {
+	switch {
+	case len(args) == 1 && args[0] == "-V=full":
+		return version(stdout, stderr)
+	case len(args) == 1 && args[0] == "-flags":
+		// The checks take no flags
+		fmt.Fprintln(stdout, "[]")
+		return 0
+	case len(args) > 0 && strings.HasSuffix(args[len(args)-1], ".cfg"):
+		// Flags meant for vet's own analyzers, such as -unsafeptr=false, may come first
+		return vet(args[len(args)-1], stderr)
+	}
+	fmt.Fprint(stderr, usage)
+	return 2
+}
+
+// version identifies the tool to the build cache by the hash of its executable, so that
+// results are not reused after it is rebuilt.
+func version(stdout, stderr io.Writer) int {
+	exe, err := os.Executable()
+	if err == nil {
+		var data []byte
+		if data, err = os.ReadFile(exe); err == nil {
+			fmt.Fprintf(stdout, "diagassert-vet version devel buildID=%x\n", sha256.Sum256(data))
+			return 0
+		}
+	}
+	fmt.Fprintln(stderr, "diagassert-vet:", err)
+	return 2
+}
+
+// vetConfig is the part of the package description written by the go command that the
+// checks use.
+type vetConfig struct {
+	ImportPath                string
+	GoFiles                   []string
+	ImportMap                 map[string]string // Import path in the source to package path
+	PackageFile               map[string]string // Package path to the file holding its export data
+	VetxOnly                  bool              // Only facts about the package are wanted, for its importers
+	VetxOutput                string            // Facts file the go command expects to be written
+	SucceedOnTypecheckFailure bool
+}
+
+// vet checks the package described by the config file cfgName.
+func vet(cfgName string, stderr io.Writer) int {
+	data, err := os.ReadFile(cfgName)
+	if err == nil {
+		var cfg vetConfig
+		if err = json.Unmarshal(data, &cfg); err == nil {
+			return vetPackage(&cfg, stderr)
+		}
+	}
+	fmt.Fprintln(stderr, "diagassert-vet:", err)
+	return 2
+}
+
+func vetPackage(cfg *vetConfig, stderr io.Writer) int {
+	// The checks record no facts, but the go command caches the file
+	if cfg.VetxOutput != "" {
+		if err := os.WriteFile(cfg.VetxOutput, nil, 0o644); err != nil {
+			fmt.Fprintln(stderr, "diagassert-vet:", err)
+			return 2
+		}
+	}
+	if cfg.VetxOnly {
+		return 0
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	asserts := false
+	for _, name := range cfg.GoFiles {
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			return typeCheckFailure(cfg, err, stderr)
+		}
+		files = append(files, file)
+		asserts = asserts || importsDiagassert(file)
+	}
+	if !asserts {
+		return 0
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := &types.Config{Importer: importer.ForCompiler(fset, "gc", cfg.lookup)}
+	if _, err := conf.Check(cfg.ImportPath, fset, files, info); err != nil {
+		return typeCheckFailure(cfg, err, stderr)
+	}
+
+	diagnostics := analyzer.Check(files, info)
+	for _, d := range diagnostics {
+		fmt.Fprintf(stderr, "%s: %s\n", fset.Position(d.Pos), d.Message)
+	}
+	if len(diagnostics) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// lookup opens the export data of an imported package.
+func (cfg *vetConfig) lookup(path string) (io.ReadCloser, error) {
+	if mapped, ok := cfg.ImportMap[path]; ok {
+		path = mapped
+	}
+	file, ok := cfg.PackageFile[path]
+	if !ok {
+		return nil, fmt.Errorf("no export data for %s", path)
+	}
+	return os.Open(file)
+}
+
+// typeCheckFailure reports a package that does not compile, unless the go command asked for
+// such packages to be left to the compiler.
+func typeCheckFailure(cfg *vetConfig, err error, stderr io.Writer) int {
+	if cfg.SucceedOnTypecheckFailure {
+		return 0
+	}
+	fmt.Fprintln(stderr, "diagassert-vet:", err)
+	return 1
+}
+
+func importsDiagassert(file *ast.File) bool {
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == analyzer.ImportPath {
+			return true
+		}
+	}
+	return false
+}
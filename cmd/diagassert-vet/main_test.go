@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandshake(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-flags"}, &stdout, &stderr); code != 0 || strings.TrimSpace(stdout.String()) != "[]" {
+		t.Errorf("-flags = %d, %q; want 0, []", code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-V=full"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("-V=full failed: %s", stderr.String())
+	}
+	fields := strings.Fields(stdout.String())
+	if len(fields) != 4 || fields[1] != "version" || fields[2] != "devel" || !strings.HasPrefix(fields[3], "buildID=") {
+		t.Errorf("-V=full printed %q, which the go command cannot parse", stdout.String())
+	}
+
+	stderr.Reset()
+	if code := run(nil, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "usage:") {
+		t.Errorf("Running without arguments should print the usage, got %d, %q", code, stderr.String())
+	}
+}
+
+func TestVet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the tool and runs go vet")
+	}
+
+	tool := filepath.Join(t.TempDir(), "diagassert-vet")
+	if out, err := exec.Command("go", "build", "-o", tool, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+
+	out, err := exec.Command("go", "vet", "-vettool="+tool, "./testdata/misuse").CombinedOutput()
+	if err == nil {
+		t.Fatalf("go vet should fail on the misuses, got:\n%s", out)
+	}
+	for _, want := range []string{
+		"misuse_test.go:12:23: <-ch in an assertion receives from a channel",
+		`misuse_test.go:15:23: decoded["count"] == 1 compares decoded["count"] of type interface{} with 1 as int`,
+		`misuse_test.go:15:59: "count" matches nothing in decoded["count"] == 1`,
+		"misuse_test.go:19:3: Require in a goroutine",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("go vet output should contain %q, got:\n%s", want, out)
+		}
+	}
+}
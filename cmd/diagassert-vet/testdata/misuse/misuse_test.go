@@ -0,0 +1,23 @@
+package misuse
+
+import (
+	"testing"
+
+	"github.com/paveg/diagassert"
+)
+
+func TestMisuse(t *testing.T) {
+	ch := make(chan int, 1)
+	ch <- 1
+	diagassert.Assert(t, <-ch == 1)
+
+	decoded := map[string]interface{}{"count": 1}
+	diagassert.Assert(t, decoded["count"] == 1, diagassert.V("count", decoded["count"]))
+
+	done := make(chan bool)
+	go func() {
+		diagassert.Require(t, len(decoded) == 1)
+		done <- true
+	}()
+	<-done
+}
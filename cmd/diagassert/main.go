@@ -0,0 +1,121 @@
+// Command diagassert reads saved `go test` output, such as CI logs, and converts the failed
+// assertions it finds into JSON, CSV, HTML or plain-text reports.
+//
+// Usage:
+//
+//	diagassert parse [--format json|csv|html|text] [log file...]
+//	diagassert summarize [--format text|json|csv|html] [log file...]
+//	go test -toolexec diagassert ./...
+//
+// parse lists every failure with its location, expression, evaluation steps and captured
+// values. summarize counts failures by test, by file and by expression. Both read standard
+// input when no file is named; plain, -v and -json test output are all understood.
+//
+// Used as the go command's -toolexec program, diagassert instruments the assertions of the
+// test files as they are compiled, like the copies diagassert-gen writes but without
+// touching the source tree, so that failures show the value of every sub-expression.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/paveg/diagassert/logparse"
+)
+
+const usage = `usage:
+  diagassert parse [--format json|csv|html|text] [log file...]
+  diagassert summarize [--format text|json|csv|html] [log file...]
+  go test -toolexec diagassert [packages]
+`
+
+func main() {
+	if tool, ok := toolexecArgs(os.Args[1:]); ok {
+		os.Exit(toolexec(tool, os.Stdout, os.Stderr))
+	}
+
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "diagassert:", err)
+		os.Exit(2)
+	}
+}
+
+// run executes a subcommand. It is separate from main so tests can supply input and output.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", usage)
+	}
+
+	command, args := args[0], args[1:]
+	var defaultFormat string
+	switch command {
+	case "parse":
+		defaultFormat = "json"
+	case "summarize":
+		defaultFormat = "text"
+	case "help", "-h", "--help":
+		io.WriteString(stdout, usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", command, usage)
+	}
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", defaultFormat, "output format")
+	files, err := parseArgs(flags, args)
+	if err != nil {
+		return fmt.Errorf("%v\n%s", err, usage)
+	}
+
+	failures, err := readFailures(files, stdin)
+	if err != nil {
+		return err
+	}
+
+	if command == "parse" {
+		return writeFailures(stdout, *format, failures)
+	}
+	return writeSummary(stdout, *format, logparse.Summarize(failures))
+}
+
+// parseArgs parses flags given before or after the file names, as in
+// `diagassert parse log.txt --format csv`.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var files []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return files, nil
+		}
+		files = append(files, args[0])
+		args = args[1:]
+	}
+}
+
+// readFailures parses every named log in order, or stdin when there are none.
+func readFailures(files []string, stdin io.Reader) ([]logparse.Failure, error) {
+	if len(files) == 0 {
+		return logparse.Parse(stdin)
+	}
+
+	var failures []logparse.Failure
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := logparse.Parse(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		failures = append(failures, parsed...)
+	}
+	return failures, nil
+}
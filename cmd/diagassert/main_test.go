@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/logparse"
+)
+
+const sampleLog = `--- FAIL: TestUser (0.00s)
+    user_test.go:7: ASSERTION FAILED at user_test.go:7
+
+        [MACHINE_READABLE_START]
+        SCHEMA_VERSION: 1
+        EXPR: age >= 18
+        EXPR_ID: 1c9f0e3a
+        RESULT: false
+        VARIABLES: age=16
+        EVALUATION_STEPS:
+          Step 1: ` + "`age >= 18`" + ` with 16 >= 18 => false [node 50fac3bc]
+        FAILURE_REASON: age >= 18 is false because age = 16
+        CAPTURED_VALUES_START
+        VALUE: name = <b>Bob</b> (string)
+        CAPTURED_VALUES_END
+        [MACHINE_READABLE_END]
+--- FAIL: TestOrder (0.00s)
+    order_test.go:3: ASSERTION FAILED at order_test.go:3
+
+        [MACHINE_READABLE_START]
+        EXPR: age >= 18
+        EXPR_ID: 1c9f0e3a
+        RESULT: false
+        [MACHINE_READABLE_END]
+FAIL
+`
+
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, strings.NewReader(sampleLog), &out); err != nil {
+		t.Fatalf("run(%v) failed: %v", args, err)
+	}
+	return out.String()
+}
+
+func TestParseJSON(t *testing.T) {
+	var failures []logparse.Failure
+	if err := json.Unmarshal([]byte(runCommand(t, "parse")), &failures); err != nil {
+		t.Fatalf("parse should write JSON by default: %v", err)
+	}
+	if len(failures) != 2 || failures[0].Test != "TestUser" || failures[0].Line != 7 || len(failures[0].Steps) != 1 {
+		t.Errorf("Unexpected failures: %+v", failures)
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{"csv", []string{
+			"test,file,line,expr,expr_id,reason,failing_node,message,variables,values\n",
+			"TestUser,user_test.go,7,age >= 18,1c9f0e3a,age >= 18 is false because age = 16,,,age=16,name=<b>Bob</b> (string)\n",
+		}},
+		{"text", []string{"TestUser user_test.go:7 \"age >= 18\"=false age=16\n", "TestOrder order_test.go:3 \"age >= 18\"=false\n"}},
+		{"html", []string{"<h1>2 failed assertions</h1>", "<code>age &gt;= 18</code>", "name = &lt;b&gt;Bob&lt;/b&gt; (string)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// Flags may follow the file names
+			output := runCommand(t, "parse", "--format", tt.format)
+			for _, part := range tt.expected {
+				if !strings.Contains(output, part) {
+					t.Errorf("Output should contain %q, got:\n%s", part, output)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	output := runCommand(t, "summarize")
+	want := `2 failed assertions
+
+BY TEST
+     1  TestOrder
+     1  TestUser
+
+BY FILE
+     1  order_test.go
+     1  user_test.go
+
+BY EXPRESSION
+     2  age >= 18
+        in TestUser, TestOrder
+`
+	if output != want {
+		t.Errorf("Unexpected summary:\n%s\nwant:\n%s", output, want)
+	}
+
+	csv := runCommand(t, "summarize", "--format", "csv")
+	if !strings.Contains(csv, "expr,age >= 18,2,1c9f0e3a,TestUser TestOrder\n") {
+		t.Errorf("Unexpected CSV summary:\n%s", csv)
+	}
+}
+
+func TestFilesAndFlagsInAnyOrder(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.log")
+	second := filepath.Join(dir, "second.log")
+	os.WriteFile(first, []byte(sampleLog), 0o644)
+	os.WriteFile(second, []byte(sampleLog), 0o644)
+
+	var out bytes.Buffer
+	if err := run([]string{"summarize", first, "--format", "json", second}, strings.NewReader(""), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var summary logparse.Summary
+	if err := json.Unmarshal(out.Bytes(), &summary); err != nil {
+		t.Fatalf("Expected JSON: %v\n%s", err, out.String())
+	}
+	if summary.Failures != 4 {
+		t.Errorf("Both files should be read, got %d failures", summary.Failures)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, args := range [][]string{{}, {"bogus"}, {"parse", "--format", "xml"}, {"parse", "missing.log"}} {
+		if err := run(args, strings.NewReader(sampleLog), &bytes.Buffer{}); err == nil {
+			t.Errorf("run(%v) should fail", args)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/logparse"
+)
+
+// writeFailures writes every failure in the given format.
+func writeFailures(w io.Writer, format string, failures []logparse.Failure) error {
+	switch format {
+	case "json":
+		if failures == nil {
+			failures = []logparse.Failure{}
+		}
+		return writeJSON(w, failures)
+
+	case "csv":
+		out := csv.NewWriter(w)
+		out.Write([]string{"test", "file", "line", "expr", "expr_id", "reason", "failing_node", "message", "variables", "values"})
+		for _, f := range failures {
+			line := ""
+			if f.Line > 0 {
+				line = strconv.Itoa(f.Line)
+			}
+			out.Write([]string{f.Test, f.File, line, f.Expr, f.ExprID, f.Reason, f.FailingNode, f.Message,
+				joinVariables(f.Variables), joinValues(f.Values)})
+		}
+		out.Flush()
+		return out.Error()
+
+	case "html":
+		return failuresPage.Execute(w, failures)
+
+	case "text":
+		// One line per failure, as DIAGASSERT_STYLE=compact writes them
+		for _, f := range failures {
+			line := formatter.CompactLine(f.File, f.Line, f.Expr, false, f.Variables, f.FailingNode, f.Message)
+			if f.Test != "" {
+				line = f.Test + " " + line
+			}
+			fmt.Fprintln(w, line)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q for parse: want json, csv, html or text", format)
+}
+
+// writeSummary writes the aggregated counts in the given format.
+func writeSummary(w io.Writer, format string, s logparse.Summary) error {
+	switch format {
+	case "json":
+		return writeJSON(w, s)
+
+	case "csv":
+		out := csv.NewWriter(w)
+		out.Write([]string{"kind", "name", "count", "expr_id", "tests"})
+		for _, c := range s.Tests {
+			out.Write([]string{"test", c.Name, strconv.Itoa(c.Count), "", ""})
+		}
+		for _, c := range s.Files {
+			out.Write([]string{"file", c.Name, strconv.Itoa(c.Count), "", ""})
+		}
+		for _, e := range s.Expressions {
+			out.Write([]string{"expr", e.Expr, strconv.Itoa(e.Count), e.ExprID, strings.Join(e.Tests, " ")})
+		}
+		out.Flush()
+		return out.Error()
+
+	case "html":
+		return summaryPage.Execute(w, s)
+
+	case "text":
+		fmt.Fprintf(w, "%d failed assertion%s\n", s.Failures, plural(s.Failures))
+		writeCounts(w, "BY TEST", s.Tests)
+		writeCounts(w, "BY FILE", s.Files)
+		if len(s.Expressions) > 0 {
+			fmt.Fprintln(w, "\nBY EXPRESSION")
+			for _, e := range s.Expressions {
+				fmt.Fprintf(w, "  %4d  %s\n", e.Count, e.Expr)
+				if len(e.Tests) > 0 {
+					fmt.Fprintf(w, "        in %s\n", strings.Join(e.Tests, ", "))
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q for summarize: want text, json, csv or html", format)
+}
+
+func writeCounts(w io.Writer, title string, counts []logparse.Count) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", title)
+	for _, c := range counts {
+		fmt.Fprintf(w, "  %4d  %s\n", c.Count, c.Name)
+	}
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// joinVariables renders variables as "a=1; b=2", sorted by name.
+func joinVariables(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + vars[name]
+	}
+	return strings.Join(parts, "; ")
+}
+
+func joinValues(values []logparse.Value) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%s=%s (%s)", v.Name, v.Value, v.Type)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func location(f logparse.Failure) string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return f.File
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+const pageStyle = `<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+code, pre { font-family: monospace; }
+pre { margin: 0; }
+</style>`
+
+var failuresPage = template.Must(template.New("failures").Funcs(template.FuncMap{"location": location}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>diagassert failures</title>` + pageStyle + `</head>
+<body>
+<h1>{{len .}} failed assertion{{if ne (len .) 1}}s{{end}}</h1>
+<table>
+<tr><th>Test</th><th>Location</th><th>Expression</th><th>Reason</th><th>Details</th></tr>
+{{range .}}<tr>
+<td>{{.Test}}</td>
+<td>{{location .}}</td>
+<td><code>{{.Expr}}</code></td>
+<td>{{.Reason}}{{if .Message}}<br>{{.Message}}{{end}}</td>
+<td><details><summary>steps and values</summary><pre>
+{{- range .Steps}}{{.Text}}
+{{end}}{{range .Values}}{{.Name}} = {{.Value}} ({{.Type}})
+{{end}}{{range .Diffs}}{{.}}
+{{end}}</pre></details></td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var summaryPage = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>diagassert summary</title>` + pageStyle + `</head>
+<body>
+<h1>{{.Failures}} failed assertion{{if ne .Failures 1}}s{{end}}</h1>
+{{if .Tests}}<h2>By test</h2>
+<table>
+<tr><th>Test</th><th>Failures</th></tr>
+{{range .Tests}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Files}}<h2>By file</h2>
+<table>
+<tr><th>File</th><th>Failures</th></tr>
+{{range .Files}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Expressions}}<h2>By expression</h2>
+<table>
+<tr><th>Expression</th><th>Failures</th><th>Tests</th><th>First reason</th></tr>
+{{range .Expressions}}<tr><td><code>{{.Expr}}</code></td><td>{{.Count}}</td><td>{{range $i, $t := .Tests}}{{if $i}}, {{end}}{{$t}}{{end}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
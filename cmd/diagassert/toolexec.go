@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/instrument"
+)
+
+// toolIDSuffix is added to the compiler's version, which the go command uses as the
+// compiler's identity in the build cache, so that instrumented and plain builds are cached apart.
+const toolIDSuffix = " diagassert-instrumented"
+
+// toolexecArgs returns the tool invocation when the command was started by the go command
+// through -toolexec: either as `diagassert toolexec /path/to/tool args...` or, with
+// `-toolexec diagassert`, directly as `diagassert /path/to/tool args...`.
+func toolexecArgs(args []string) ([]string, bool) {
+	if len(args) > 0 && args[0] == "toolexec" {
+		return args[1:], len(args) > 1
+	}
+	return args, len(args) > 0 && filepath.IsAbs(args[0])
+}
+
+// toolexec runs a build tool on behalf of the go command, instrumenting the assertions in
+// the test files the compiler is given. It returns the tool's exit code.
+func toolexec(args []string, stdout, stderr io.Writer) int {
+	tool, toolArgs := args[0], args[1:]
+
+	if isCompiler(tool) {
+		if len(toolArgs) == 1 && toolArgs[0] == "-V=full" {
+			return compilerVersion(tool, stdout, stderr)
+		}
+
+		instrumented, err := instrumentCompile(toolArgs)
+		if err != nil {
+			fmt.Fprintln(stderr, "diagassert:", err)
+			return 2
+		}
+		toolArgs = instrumented
+	}
+
+	cmd := exec.Command(tool, toolArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, stdout, stderr
+	return exitCode(cmd.Run(), stderr)
+}
+
+func isCompiler(tool string) bool {
+	return strings.TrimSuffix(filepath.Base(tool), ".exe") == "compile"
+}
+
+// compilerVersion reports the compiler's version with toolIDSuffix added.
+func compilerVersion(tool string, stdout, stderr io.Writer) int {
+	var out bytes.Buffer
+	cmd := exec.Command(tool, "-V=full")
+	cmd.Stdout, cmd.Stderr = &out, stderr
+	if code := exitCode(cmd.Run(), stderr); code != 0 {
+		return code
+	}
+
+	version := strings.TrimSpace(out.String())
+	if !strings.Contains(version, "devel") {
+		// Release versions are used whole; development versions by their trailing build ID
+		version += toolIDSuffix
+	}
+	fmt.Fprintln(stdout, version)
+	return 0
+}
+
+func exitCode(err error, stderr io.Writer) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	}
+	fmt.Fprintln(stderr, "diagassert:", err)
+	return 2
+}
+
+// instrumentCompile replaces the test files among the compiler's arguments that assert with
+// instrumented copies, written next to the compiler's output in the go command's work
+// directory. The copies begin with a line directive, so positions still name the originals.
+func instrumentCompile(args []string) ([]string, error) {
+	var output, importcfg string
+	var sources []int
+	for i, arg := range args {
+		switch {
+		case arg == "-o" && i+1 < len(args):
+			output = args[i+1]
+		case arg == "-importcfg" && i+1 < len(args):
+			importcfg = args[i+1]
+		case strings.HasSuffix(arg, ".go") && !strings.HasPrefix(arg, "-"):
+			sources = append(sources, i)
+		}
+	}
+	if output == "" || !assertsInTests(args, sources) {
+		return args, nil
+	}
+
+	fset := token.NewFileSet()
+	files := make([]*ast.File, len(sources))
+	srcs := make([][]byte, len(sources))
+	for i, index := range sources {
+		src, err := os.ReadFile(args[index])
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, args[index], src, parser.ParseComments)
+		if err != nil {
+			// Leave syntax errors for the compiler to report
+			return args, nil
+		}
+		files[i], srcs[i] = file, src
+	}
+
+	lookup, err := readImportConfig(importcfg)
+	if err != nil {
+		return nil, err
+	}
+	info := instrument.TypeCheck(fset, files, importer.ForCompiler(fset, "gc", lookup))
+
+	rewritten := append([]string(nil), args...)
+	for i, index := range sources {
+		name := args[index]
+		if !strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		body, ok := instrument.Rewrite(fset, files[i], info, srcs[i])
+		if !ok {
+			continue
+		}
+
+		original, err := filepath.Abs(name)
+		if err != nil {
+			return nil, err
+		}
+		copyName := filepath.Join(filepath.Dir(output), "diagassert_"+filepath.Base(name))
+		content := append([]byte(fmt.Sprintf("//line %s:1\n", original)), body...)
+		if err := os.WriteFile(copyName, content, 0o644); err != nil {
+			return nil, err
+		}
+		rewritten[index] = copyName
+	}
+	return rewritten, nil
+}
+
+// assertsInTests reports whether any test file among the sources imports diagassert,
+// which is checked cheaply before anything is parsed.
+func assertsInTests(args []string, sources []int) bool {
+	for _, index := range sources {
+		if !strings.HasSuffix(args[index], "_test.go") {
+			continue
+		}
+		if src, err := os.ReadFile(args[index]); err == nil && bytes.Contains(src, []byte(`"`+instrument.ImportPath+`"`)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readImportConfig reads the compiler's -importcfg file, which maps import paths to the
+// export data of the packages being compiled against, and returns a lookup for the gc importer.
+func readImportConfig(name string) (func(path string) (io.ReadCloser, error), error) {
+	packageFiles := make(map[string]string)
+	importMap := make(map[string]string)
+
+	if name != "" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			verb, rest, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+			from, to, ok := strings.Cut(rest, "=")
+			if !ok {
+				continue
+			}
+			switch verb {
+			case "packagefile":
+				packageFiles[from] = to
+			case "importmap":
+				importMap[from] = to
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(path string) (io.ReadCloser, error) {
+		if mapped, ok := importMap[path]; ok {
+			path = mapped
+		}
+		file, ok := packageFiles[path]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	}, nil
+}
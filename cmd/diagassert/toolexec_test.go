@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToolexecArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		tool []string
+		ok   bool
+	}{
+		{[]string{"/usr/local/go/pkg/tool/linux_amd64/compile", "-V=full"}, []string{"/usr/local/go/pkg/tool/linux_amd64/compile", "-V=full"}, true},
+		{[]string{"toolexec", "/go/pkg/tool/compile", "x.go"}, []string{"/go/pkg/tool/compile", "x.go"}, true},
+		{[]string{"toolexec"}, nil, false},
+		{[]string{"parse", "ci.log"}, nil, false},
+		{nil, nil, false},
+	}
+	for _, tt := range tests {
+		tool, ok := toolexecArgs(tt.args)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(tool, tt.tool)) {
+			t.Errorf("toolexecArgs(%q) = %q, %v; want %q, %v", tt.args, tool, ok, tt.tool, tt.ok)
+		}
+	}
+}
+
+func TestInstrumentCompile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	source := write("sample.go", "package sample\n\nfunc limit() int { return 3 }\n")
+	test := write("sample_test.go", `package sample
+
+import (
+	"testing"
+
+	"github.com/paveg/diagassert"
+)
+
+func TestLimit(t *testing.T) {
+	x := 2
+	diagassert.Assert(t, x > limit())
+}
+`)
+	importcfg := write("importcfg", "# import config\n")
+	output := filepath.Join(dir, "_pkg_.a")
+
+	args := []string{"-o", output, "-p", "sample", "-importcfg", importcfg, "-pack", source, test}
+	got, err := instrumentCompile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copyName := filepath.Join(dir, "diagassert_sample_test.go")
+	want := append(append([]string(nil), args[:len(args)-1]...), copyName)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Only the test file should be replaced, got %q", got)
+	}
+
+	data, err := os.ReadFile(copyName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{
+		"//line " + test + ":1\npackage sample\n",
+		`diagassert.AssertCaptured(t, "x > limit()", func(diagassertCapture *diagassert.Capture) bool {`,
+		`diagassert.Cap(diagassertCapture, "limit()", limit())`,
+	} {
+		if !strings.Contains(string(data), part) {
+			t.Errorf("Instrumented copy should contain %q, got:\n%s", part, data)
+		}
+	}
+
+	t.Run("packages without assertions are compiled as they are", func(t *testing.T) {
+		args := []string{"-o", output, "-importcfg", importcfg, source}
+		if got, err := instrumentCompile(args); err != nil || !reflect.DeepEqual(got, args) {
+			t.Errorf("instrumentCompile(%q) = %q, %v", args, got, err)
+		}
+	})
+}
+
+func TestReadImportConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "importcfg")
+	export := filepath.Join(dir, "fmt.a")
+	os.WriteFile(export, []byte("export data"), 0o644)
+	os.WriteFile(cfg, []byte("# import config\npackagefile fmt="+export+"\nimportmap vendored/fmt=fmt\n"), 0o644)
+
+	lookup, err := readImportConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"fmt", "vendored/fmt"} {
+		r, err := lookup(path)
+		if err != nil {
+			t.Errorf("lookup(%q) failed: %v", path, err)
+			continue
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != "export data" {
+			t.Errorf("lookup(%q) read %q", path, data)
+		}
+	}
+	if _, err := lookup("os"); err == nil {
+		t.Error("Packages missing from the config should not be found")
+	}
+}
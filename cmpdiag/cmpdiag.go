@@ -0,0 +1,130 @@
+//go:build diagassert_gocmp
+
+package cmpdiag
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+func init() {
+	evaluator.RegisterDiffer(func(left, right interface{}) ([]string, bool) {
+		return diff(left, right, nil)
+	})
+	evaluator.RegisterOptionsDiffer(diff)
+}
+
+// diff compares composite values with cmp.Equal and lists the paths where they differ.
+// opts must hold cmp.Option values; anything else is reported and skipped.
+func diff(left, right interface{}, opts []interface{}) (diffs []string, ok bool) {
+	if !isComposite(left) || !isComposite(right) {
+		return nil, false
+	}
+
+	r := &reporter{}
+	cmpOpts := []cmp.Option{cmp.Reporter(r)}
+	for _, o := range opts {
+		opt, isOption := o.(cmp.Option)
+		if !isOption {
+			r.diffs = append(r.diffs, fmt.Sprintf("(options): ignored %T, which is not a cmp.Option", o))
+			continue
+		}
+		cmpOpts = append(cmpOpts, opt)
+	}
+
+	// cmp panics on values it cannot compare, such as unexported fields without an option
+	defer func() {
+		if recover() != nil {
+			diffs, ok = nil, false
+		}
+	}()
+	cmp.Equal(left, right, cmpOpts...)
+
+	return r.diffs, true
+}
+
+// isComposite reports whether a value is a struct, map, slice or array, or points to one.
+func isComposite(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// reporter records one entry per unequal leaf that cmp visits.
+type reporter struct {
+	path  cmp.Path
+	diffs []string
+}
+
+func (r *reporter) PushStep(ps cmp.PathStep) {
+	r.path = append(r.path, ps)
+}
+
+func (r *reporter) PopStep() {
+	r.path = r.path[:len(r.path)-1]
+}
+
+func (r *reporter) Report(rs cmp.Result) {
+	if rs.Equal() {
+		return
+	}
+	vx, vy := r.path.Last().Values()
+	r.diffs = append(r.diffs, fmt.Sprintf("%s: %s != %s", formatPath(r.path), formatValue(vx), formatValue(vy)))
+}
+
+// formatPath renders a path like ".Items[3].Price", leaving out pointer indirections.
+func formatPath(path cmp.Path) string {
+	var s string
+	for _, step := range path[1:] { // The first step is the root value itself
+		switch step := step.(type) {
+		case cmp.StructField:
+			s += "." + step.Name()
+		case cmp.SliceIndex:
+			// An element present on one side only has key -1 on the other
+			ix, iy := step.SplitKeys()
+			switch {
+			case ix == iy, iy == -1:
+				s += fmt.Sprintf("[%d]", ix)
+			case ix == -1:
+				s += fmt.Sprintf("[%d]", iy)
+			default:
+				s += step.String()
+			}
+		case cmp.Indirect:
+		default:
+			s += step.String()
+		}
+	}
+	if s == "" {
+		return "(root)"
+	}
+	return s
+}
+
+// formatValue renders one side of a difference; a side missing from a map or slice is "<missing>".
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<missing>"
+	}
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	if v.CanInterface() {
+		return fmt.Sprintf("%v", v.Interface())
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,97 @@
+//go:build diagassert_gocmp
+
+package cmpdiag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/paveg/diagassert"
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+type Address struct {
+	City string
+}
+
+type User struct {
+	Name    string
+	Age     int
+	Address *Address
+	Roles   []string
+}
+
+func TestDiff(t *testing.T) {
+	got := User{Name: "Alice", Age: 16, Address: &Address{City: "Berlin"}, Roles: []string{"user"}}
+	want := User{Name: "Alice", Age: 18, Address: &Address{City: "Paris"}, Roles: []string{"user", "admin"}}
+
+	diffs, ok := diff(got, want, nil)
+	if !ok {
+		t.Fatal("diff should handle structs")
+	}
+	expected := []string{
+		".Age: 16 != 18",
+		`.Address.City: "Berlin" != "Paris"`,
+		`.Roles[1]: <missing> != "admin"`,
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("diff = %q, want %q", diffs, expected)
+	}
+
+	diffs, _ = diff(got, want, []interface{}{cmpopts.IgnoreFields(User{}, "Age", "Roles")})
+	if !reflect.DeepEqual(diffs, []string{`.Address.City: "Berlin" != "Paris"`}) {
+		t.Errorf("options should be applied, got %q", diffs)
+	}
+
+	diffs, _ = diff(got, got, []interface{}{42})
+	if !reflect.DeepEqual(diffs, []string{"(options): ignored int, which is not a cmp.Option"}) {
+		t.Errorf("non-options should be reported, got %q", diffs)
+	}
+
+	if _, ok := diff(1, 2, nil); ok {
+		t.Error("diff should not handle basic values")
+	}
+	type private struct{ n int }
+	if _, ok := diff(private{1}, private{2}, nil); ok {
+		t.Error("diff should leave unexported fields to the built-in diff")
+	}
+}
+
+type Account struct {
+	Name      string
+	Age       int
+	UpdatedAt int64
+}
+
+func TestAssertWithCmpOptions(t *testing.T) {
+	got := Account{Name: "Alice", Age: 16, UpdatedAt: 1700000000}
+	want := Account{Name: "Alice", Age: 18, UpdatedAt: 1600000000}
+
+	mock := testutil.NewMockT()
+	diagassert.Assert(mock, got == want, diagassert.V("got", got), diagassert.V("want", want))
+	output := mock.GetOutput()
+	if !strings.Contains(output, ".Age: 16 != 18") || !strings.Contains(output, ".UpdatedAt: 1700000000 != 1600000000") {
+		t.Errorf("Expected go-cmp differences, got: %s", output)
+	}
+
+	mock = testutil.NewMockT()
+	diagassert.Assert(mock, got == want, diagassert.V("got", got), diagassert.V("want", want), diagassert.CmpOptions(cmpopts.IgnoreFields(Account{}, "UpdatedAt")))
+	output = mock.GetOutput()
+	if !strings.Contains(output, ".Age: 16 != 18") || strings.Contains(output, "UpdatedAt:") {
+		t.Errorf("Ignored fields should not be listed, got: %s", output)
+	}
+
+	mock = testutil.NewMockT()
+	want.Age = 16
+	diagassert.Assert(mock, got == want, diagassert.V("got", got), diagassert.V("want", want), diagassert.CmpOptions(cmpopts.IgnoreFields(Account{}, "UpdatedAt")))
+	output = mock.GetOutput()
+	if !mock.Failed() {
+		t.Fatal("== still compares ignored fields, so the assertion should fail")
+	}
+	if !strings.Contains(output, "equal under the comparison options") {
+		t.Errorf("Expected a note about the comparison options, got: %s", output)
+	}
+}
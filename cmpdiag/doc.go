@@ -0,0 +1,23 @@
+// Package cmpdiag lets github.com/google/go-cmp describe failed == comparisons of structs,
+// maps, slices and arrays, so that cmp options passed with diagassert.CmpOptions shape the
+// reported differences:
+//
+//	diagassert.Assert(t, got == want,
+//		diagassert.CmpOptions(cmpopts.IgnoreFields(User{}, "UpdatedAt"), cmpopts.EquateEmpty()))
+//
+// Differences are reported by path, like the built-in diff:
+//
+//	DIFFERENCES in got == want:
+//	  .Address.City: "Berlin" != "Paris"
+//	  .Roles[1]: <missing> != "admin"
+//
+// Values go-cmp refuses to compare, such as structs with unexported fields and no option
+// for them, keep the built-in diff.
+//
+// The package is compiled only with the diagassert_gocmp build tag, so modules that do not
+// use go-cmp never build against it. Import it for its side effects:
+//
+//	import _ "github.com/paveg/diagassert/cmpdiag"
+//
+//	go test -tags diagassert_gocmp ./...
+package cmpdiag
@@ -0,0 +1,188 @@
+package diagassert
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// codeownersPaths are where GitHub and GitLab look for a CODEOWNERS file, in order.
+var codeownersPaths = []string{
+	filepath.Join(".github", "CODEOWNERS"),
+	"CODEOWNERS",
+	filepath.Join("docs", "CODEOWNERS"),
+}
+
+// ownerRule is a line of a CODEOWNERS file: a path pattern and the owners of the files it
+// matches, none for files left unowned.
+type ownerRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// codeowners is a parsed CODEOWNERS file with the root its patterns are relative to.
+type codeowners struct {
+	root  string
+	rules []ownerRule
+}
+
+var (
+	codeownersMu    sync.Mutex
+	codeownersFiles = map[string]*codeowners{} // By path, nil for files that cannot be read
+)
+
+// ownersOf returns the owners of a source file as listed in a CODEOWNERS file, when
+// DIAGASSERT_CODEOWNERS is set to the path of one, or to "true" to use the one of the
+// repository holding the file. As on GitHub, the last matching pattern wins.
+func ownersOf(file string) []string {
+	setting := config.Getenv("DIAGASSERT_CODEOWNERS")
+	if setting == "" || setting == "false" || file == "" {
+		return nil
+	}
+
+	path := setting
+	if setting == "true" {
+		var ok bool
+		if path, ok = findCodeowners(filepath.Dir(file)); !ok {
+			return nil
+		}
+	}
+	c := loadCodeowners(path)
+	if c == nil {
+		return nil
+	}
+
+	rel, err := filepath.Rel(c.root, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if !c.rules[i].pattern.MatchString(rel) {
+			continue
+		}
+		if len(c.rules[i].owners) == 0 {
+			return nil
+		}
+		return c.rules[i].owners
+	}
+	return nil
+}
+
+// findCodeowners looks for a CODEOWNERS file from dir up to the root of its repository.
+func findCodeowners(dir string) (string, bool) {
+	for {
+		for _, candidate := range codeownersPaths {
+			path := filepath.Join(dir, candidate)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, true
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// loadCodeowners parses the CODEOWNERS file at path once. Its patterns are relative to the
+// directory holding it, or to the parent of .github and docs.
+func loadCodeowners(path string) *codeowners {
+	codeownersMu.Lock()
+	defer codeownersMu.Unlock()
+
+	if c, ok := codeownersFiles[path]; ok {
+		return c
+	}
+	c := parseCodeowners(path)
+	codeownersFiles[path] = c
+	return c
+}
+
+// parseCodeowners reads a CODEOWNERS file, returning nil if it cannot be read.
+func parseCodeowners(path string) *codeowners {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	root := filepath.Dir(abs)
+	if base := filepath.Base(root); base == ".github" || base == "docs" {
+		root = filepath.Dir(root)
+	}
+
+	c := &codeowners{root: root}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		// Section headers of GitLab, such as [Docs], have no pattern
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+			continue
+		}
+		c.rules = append(c.rules, ownerRule{pattern: ownerPattern(fields[0]), owners: fields[1:]})
+	}
+	return c
+}
+
+// ownerPattern compiles a CODEOWNERS pattern, which follows .gitignore: a pattern with a
+// slash before its end is anchored at the root, others match at any depth, a pattern
+// matching a directory matches everything in it, and docs/* only matches files directly in
+// docs.
+func ownerPattern(pattern string) *regexp.Regexp {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	switch {
+	case pattern == "*":
+		b.WriteString(".*$")
+	case dirOnly:
+		b.WriteString("/.*$")
+	case strings.HasSuffix(pattern, "/*"):
+		b.WriteString("$")
+	default:
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,84 @@
+package diagassert
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOwnerPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*", "a/b/c.go", true},
+		{"*.go", "a/b/c.go", true},
+		{"*.go", "a/b/c.md", false},
+		{"/build/", "build/logs/x.log", true},
+		{"/build/", "src/build/x.log", false},
+		{"build/", "src/build/x.log", true},
+		{"docs/*", "docs/index.md", true},
+		{"docs/*", "docs/api/index.md", false},
+		{"apps/", "apps/web/app_test.go", true},
+		{"/payments", "payments/charge_test.go", true},
+		{"payments/api", "x/payments/api/a.go", false},
+		{"**/logs", "deep/down/logs/a.log", true},
+		{"src/**/test", "src/a/b/test/x_test.go", true},
+		{"a?c.go", "abc.go", true},
+	}
+
+	for _, tt := range tests {
+		if got := ownerPattern(tt.pattern).MatchString(tt.path); got != tt.want {
+			t.Errorf("ownerPattern(%q) matching %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestOwnersOf(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, ".github"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	codeowners := strings.Join([]string{
+		"# Default owners",
+		"*                  @acme/core",
+		"/payments/         @acme/payments ops@example.com # Billing",
+		"/payments/legacy/",
+		"",
+	}, "\n")
+	path := filepath.Join(repo, ".github", "CODEOWNERS")
+	if err := os.WriteFile(path, []byte(codeowners), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		setting string
+		file    string
+		want    []string
+	}{
+		{"true", "payments/charge_test.go", []string{"@acme/payments", "ops@example.com"}},
+		{"true", "users/user_test.go", []string{"@acme/core"}},
+		{"true", "payments/legacy/old_test.go", nil},
+		{path, "payments/charge_test.go", []string{"@acme/payments", "ops@example.com"}},
+		{"", "payments/charge_test.go", nil},
+		{"false", "payments/charge_test.go", nil},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("DIAGASSERT_CODEOWNERS", tt.setting)
+		if got := ownersOf(filepath.Join(repo, filepath.FromSlash(tt.file))); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ownersOf(%s) with %q = %q, want %q", tt.file, tt.setting, got, tt.want)
+		}
+	}
+
+	t.Setenv("DIAGASSERT_CODEOWNERS", path)
+	if got := ownersOf(filepath.Join(t.TempDir(), "elsewhere_test.go")); got != nil {
+		t.Errorf("Files outside the repository should have no owners, got %q", got)
+	}
+}
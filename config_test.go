@@ -0,0 +1,74 @@
+package diagassert
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestSettingsFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_CONFIG", write(".diagassert.yaml", "machine_readable: false\n"))
+		os.Unsetenv("DIAGASSERT_MACHINE_READABLE")
+
+		mock := testutil.NewMockT()
+		Assert(mock, 1 > 2)
+		if output := mock.GetOutput(); strings.Contains(output, "[MACHINE_READABLE_START]") {
+			t.Errorf("The settings file should turn the machine-readable section off, got: %s", output)
+		}
+	})
+
+	t.Run("environment first", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_CONFIG", write(".diagassert.toml", "machine_readable = false\n"))
+		t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+
+		mock := testutil.NewMockT()
+		Assert(mock, 1 > 2)
+		if output := mock.GetOutput(); !strings.Contains(output, "[MACHINE_READABLE_START]") {
+			t.Errorf("DIAGASSERT_MACHINE_READABLE should take precedence over the file, got: %s", output)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_CONFIG", write("broken.yaml", "machine_readable: false\nverbosity: high\n"))
+		os.Unsetenv("DIAGASSERT_MACHINE_READABLE")
+
+		mock := testutil.NewMockT()
+		Assert(mock, 1 > 2)
+		output := mock.GetOutput()
+		expected := []string{
+			"broken.yaml:2: unknown setting \"verbosity\"",
+			"CONFIG_START",
+			"[MACHINE_READABLE_START]", // The file was ignored
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_CONFIG", "false")
+		t.Setenv("DIAGASSERT_COLOR", "sometimes")
+
+		mock := testutil.NewMockT()
+		Assert(mock, 1 > 2)
+		if output := mock.GetOutput(); !strings.Contains(output, `DIAGASSERT_COLOR="sometimes" is not "auto", "always" or "never", the default is used`) {
+			t.Errorf("Output should report the invalid setting, got: %s", output)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package diagassert
+
+import (
+	"context"
+	"sort"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// contextValuesKey is the key of the values WithValues adds to a context.
+type contextValuesKey struct{}
+
+// WithValues returns a copy of ctx that carries values, so that test helpers deep down the
+// stack report what their callers registered, such as request IDs or fixture names, without
+// every signature passing them on: the failures of AssertC and RequireC with ctx, or a
+// context derived from it, show them with their own values. Values added later replace
+// those of the same name added before.
+//
+// Usage: ctx = diagassert.WithValues(ctx, diagassert.Values{"requestID": id})
+func WithValues(ctx context.Context, values Values) context.Context {
+	inherited := contextValues(ctx)
+	merged := make([]Value, 0, len(inherited)+len(values))
+	for _, v := range inherited {
+		if _, replaced := values[v.Name]; !replaced {
+			merged = append(merged, v)
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		merged = append(merged, Value{Name: name, Value: values[name]})
+	}
+	return context.WithValue(ctx, contextValuesKey{}, merged)
+}
+
+// contextValues returns the values added to ctx with WithValues, in the order they were added.
+func contextValues(ctx context.Context) []Value {
+	if ctx == nil {
+		return nil
+	}
+	values, _ := ctx.Value(contextValuesKey{}).([]Value)
+	return values
+}
+
+// AssertC is Assert for helpers handed a context: the failure also shows the values added to
+// ctx with WithValues, after those passed in args, which take precedence over context values
+// of the same name.
+//
+//	func checkOrder(ctx context.Context, t *testing.T, order Order) {
+//		t.Helper()
+//		diagassert.AssertC(ctx, t, order.Total > 0)
+//	}
+func AssertC(ctx context.Context, t TestingT, expr bool, args ...interface{}) {
+	t.Helper()
+	recordAssertion(expr, "")
+
+	if expr {
+		return
+	}
+
+	defer config.Hold()()
+	actx := newContext(t, args)
+	addContextValues(actx, ctx)
+	failure := buildFailureInfo(expr, actx)
+	reportFailure(t, failure, false)
+}
+
+// RequireC is the same as AssertC, but terminates the test immediately on failure
+func RequireC(ctx context.Context, t TestingT, expr bool, args ...interface{}) {
+	t.Helper()
+	recordAssertion(expr, "")
+
+	if expr {
+		return
+	}
+
+	defer config.Hold()()
+	actx := newContext(t, args)
+	addContextValues(actx, ctx)
+	addGoroutines(actx)
+	failure := buildFailureInfo(expr, actx)
+	reportFailure(t, failure, true)
+}
+
+// addContextValues appends the values of ctx to those of the assertion context, leaving out
+// names the assertion was passed values for.
+func addContextValues(actx *AssertionContext, ctx context.Context) {
+	passed := make(map[string]bool, len(actx.Values))
+	for _, v := range actx.Values {
+		passed[v.Name] = true
+	}
+	for _, v := range contextValues(ctx) {
+		if !passed[v.Name] {
+			actx.Values = append(actx.Values, v)
+		}
+	}
+}
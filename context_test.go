@@ -0,0 +1,82 @@
+package diagassert
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+type contextOrder struct{ Total int }
+
+// checkContextOrder is a helper deep down the stack that knows nothing of the request.
+func checkContextOrder(ctx context.Context, t TestingT, order contextOrder) {
+	t.Helper()
+	AssertC(ctx, t, order.Total > 0, V("order", order))
+}
+
+func TestAssertC(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	ctx := WithValues(context.Background(), Values{"requestID": "req-42", "fixture": "empty_cart"})
+	ctx = WithValues(ctx, Values{"fixture": "paid_cart"})
+
+	failure := captureFailure(t, func(mock *testutil.MockT) {
+		checkContextOrder(ctx, mock, contextOrder{})
+	})
+	if failure.Expression != "order.Total > 0" {
+		t.Errorf("Expression = %q, want %q", failure.Expression, "order.Total > 0")
+	}
+	for _, part := range []string{"requestID", "req-42", "paid_cart"} {
+		if !strings.Contains(failure.Output, part) {
+			t.Errorf("Output should contain %q, got: %s", part, failure.Output)
+		}
+	}
+	if strings.Contains(failure.Output, "empty_cart") {
+		t.Errorf("A value added later should replace the one of the same name, got: %s", failure.Output)
+	}
+
+	t.Run("values passed take precedence", func(t *testing.T) {
+		failure := captureFailure(t, func(mock *testutil.MockT) {
+			AssertC(ctx, mock, false, V("requestID", "req-7"))
+		})
+		if strings.Contains(failure.Output, "req-42") || !strings.Contains(failure.Output, "req-7") {
+			t.Errorf("Output should show the value passed, not the context's, got: %s", failure.Output)
+		}
+	})
+
+	t.Run("passing", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertC(ctx, mock, true)
+		if mock.Failed() {
+			t.Error("A true expression should not fail")
+		}
+	})
+
+	t.Run("context without values", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertC(context.Background(), mock, len("ab") > 5)
+		if !strings.Contains(mock.GetOutput(), "len(\"ab\") > 5") {
+			t.Errorf("Output should show the expression, got: %s", mock.GetOutput())
+		}
+	})
+}
+
+func TestRequireC(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	ctx := WithValues(context.Background(), Values{"requestID": "req-42"})
+
+	mock := testutil.NewMockT()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("RequireC should stop the test")
+			}
+		}()
+		RequireC(ctx, mock, 1 > 2)
+	}()
+	if !strings.Contains(mock.GetOutput(), "req-42") {
+		t.Errorf("Output should contain the context's values, got: %s", mock.GetOutput())
+	}
+}
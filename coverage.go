@@ -0,0 +1,177 @@
+package diagassert
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// AssertionSite is an Assert or Require call that ran while DIAGASSERT_COVERAGE was "true".
+type AssertionSite struct {
+	File        string
+	Line        int
+	Expression  string // Asserted expression as written in the source, when it could be read
+	Evaluations int    // Times the call ran
+	Failures    int    // Times the expression was false
+	Tautology   string // Why an expression that never failed cannot fail, such as "x == x compares x with itself"
+
+	pc uintptr // Of the call, to type check its package
+}
+
+type siteKey struct {
+	file string
+	line int
+}
+
+// passSettings are the settings every assertion checks, passing or not.
+type passSettings struct {
+	coverage bool // DIAGASSERT_COVERAGE is "true"
+}
+
+var (
+	coverageMu    sync.Mutex
+	coverageSites = map[siteKey]*AssertionSite{}
+
+	// Read by the first assertion of a process, so that passing ones take no lock and make
+	// no system call; tests that change the settings store nil
+	loadedPassSettings atomic.Pointer[passSettings]
+)
+
+// recordAssertion counts a run of the Assert or Require call that called its caller. expr
+// is the source text when the caller knows it, as instrumented assertions do. The first
+// assertion of a process also starts its run in the DIAGASSERT_HISTORY file.
+func recordAssertion(passed bool, expr string) {
+	settings := loadedPassSettings.Load()
+	if settings == nil {
+		settings = loadPassSettings()
+	}
+	if !settings.coverage {
+		return
+	}
+	pc, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return
+	}
+
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+
+	key := siteKey{file, line}
+	site := coverageSites[key]
+	if site == nil {
+		site = &AssertionSite{File: file, Line: line, Expression: expr, pc: pc}
+		coverageSites[key] = site
+	}
+	site.Evaluations++
+	if !passed {
+		site.Failures++
+	}
+}
+
+// loadPassSettings reads the settings recordAssertion checks and starts the run in the
+// DIAGASSERT_HISTORY file.
+func loadPassSettings() *passSettings {
+	defer config.Hold()()
+	startHistoryRun()
+	settings := &passSettings{coverage: config.Getenv("DIAGASSERT_COVERAGE") == "true"}
+	loadedPassSettings.Store(settings)
+	return settings
+}
+
+// AssertionSites returns every assertion call that ran while DIAGASSERT_COVERAGE was "true",
+// ordered by file and line. Expressions that never failed are checked for tautologies.
+func AssertionSites() []AssertionSite {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+
+	sites := make([]AssertionSite, 0, len(coverageSites))
+	for _, site := range coverageSites {
+		if site.Expression == "" {
+			if expr, err := parser.ExtractExpression(site.File, site.Line); err == nil {
+				site.Expression = expr
+			}
+		}
+		if site.Failures == 0 && site.Tautology == "" && site.Expression != "" {
+			site.Tautology = evaluator.DescribeTautology(site.Expression, site.pc)
+		}
+		sites = append(sites, *site)
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].File != sites[j].File {
+			return sites[i].File < sites[j].File
+		}
+		return sites[i].Line < sites[j].Line
+	})
+	return sites
+}
+
+// WriteCoverageReport writes a summary of the assertions that ran while DIAGASSERT_COVERAGE
+// was "true": how often each ran and failed, and which can never fail because their
+// expression is a tautology, such as x == x or len(s) >= 0. Call it from TestMain once the
+// tests have run:
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		diagassert.WriteCoverageReport(os.Stderr)
+//		os.Exit(code)
+//	}
+func WriteCoverageReport(w io.Writer) error {
+	if config.Getenv("DIAGASSERT_COVERAGE") != "true" {
+		_, err := fmt.Fprintln(w, "diagassert coverage: not recorded (set DIAGASSERT_COVERAGE=true)")
+		return err
+	}
+
+	sites := AssertionSites()
+	neverFailed, suspicious := 0, 0
+	for _, site := range sites {
+		if site.Failures == 0 {
+			neverFailed++
+		}
+		if site.Tautology != "" {
+			suspicious++
+		}
+	}
+
+	fmt.Fprintf(w, "diagassert coverage: %d assertion%s, %d never failed, %d suspicious\n",
+		len(sites), plural(len(sites)), neverFailed, suspicious)
+
+	if suspicious > 0 {
+		fmt.Fprintln(w, "\nSUSPICIOUS")
+		for _, site := range sites {
+			if site.Tautology != "" {
+				fmt.Fprintf(w, "  %s:%d  %s\n      %s\n", filepath.Base(site.File), site.Line, site.Expression, site.Tautology)
+			}
+		}
+	}
+
+	if len(sites) > 0 {
+		fmt.Fprintln(w, "\nASSERTIONS")
+	}
+	for _, site := range sites {
+		outcome := fmt.Sprintf("failed %d", site.Failures)
+		if site.Failures == 0 {
+			outcome = "never failed"
+		}
+		_, err := fmt.Fprintf(w, "  %s:%d  ran %d, %s  %s\n", filepath.Base(site.File), site.Line, site.Evaluations, outcome, site.Expression)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,83 @@
+package diagassert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func resetCoverage() {
+	coverageMu.Lock()
+	coverageSites = map[siteKey]*AssertionSite{}
+	coverageMu.Unlock()
+}
+
+// reloadPassSettings makes assertions read their settings again now and once the test ends,
+// after it changed them.
+func reloadPassSettings(t testing.TB) {
+	loadedPassSettings.Store(nil)
+	t.Cleanup(func() { loadedPassSettings.Store(nil) })
+}
+
+func TestCoverage(t *testing.T) {
+	t.Setenv("DIAGASSERT_COVERAGE", "true")
+	reloadPassSettings(t)
+	resetCoverage()
+	defer resetCoverage()
+
+	mock := testutil.NewMockT()
+	for _, age := range []int{20, 16, 30} {
+		Assert(mock, age >= 18)
+		Assert(mock, age == age)
+	}
+	AssertCaptured(mock, "len(items) >= 0", func(c *Capture) bool { return true })
+
+	sites := AssertionSites()
+	if len(sites) != 3 {
+		t.Fatalf("Expected 3 sites, got %+v", sites)
+	}
+	if s := sites[0]; s.Expression != "age >= 18" || s.Evaluations != 3 || s.Failures != 1 || s.Tautology != "" {
+		t.Errorf("Unexpected first site: %+v", s)
+	}
+	if s := sites[1]; s.Expression != "age == age" || s.Failures != 0 || s.Tautology != "age == age compares age with itself" {
+		t.Errorf("Unexpected second site: %+v", s)
+	}
+
+	var out bytes.Buffer
+	if err := WriteCoverageReport(&out); err != nil {
+		t.Fatal(err)
+	}
+	report := out.String()
+	expected := []string{
+		"diagassert coverage: 3 assertions, 2 never failed, 2 suspicious\n",
+		"SUSPICIOUS\n  coverage_test.go:",
+		"  age == age\n      age == age compares age with itself\n",
+		"len(items) >= 0\n      len(items) >= 0 always holds: lengths are never negative\n",
+		"ran 3, failed 1  age >= 18\n",
+		"ran 3, never failed  age == age\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(report, part) {
+			t.Errorf("Report should contain %q, got:\n%s", part, report)
+		}
+	}
+}
+
+func TestCoverageDisabled(t *testing.T) {
+	t.Setenv("DIAGASSERT_COVERAGE", "")
+	reloadPassSettings(t)
+	resetCoverage()
+
+	Assert(testutil.NewMockT(), true)
+	if sites := AssertionSites(); len(sites) != 0 {
+		t.Errorf("Nothing should be recorded without DIAGASSERT_COVERAGE, got %+v", sites)
+	}
+
+	var out bytes.Buffer
+	WriteCoverageReport(&out)
+	if !strings.Contains(out.String(), "not recorded") {
+		t.Errorf("The report should say coverage was not recorded, got %q", out.String())
+	}
+}
@@ -0,0 +1,287 @@
+// Package dbassert checks the row a SQL query returns against the one a test expects.
+// AssertRowEquals runs the query on a *sql.DB or *sql.Tx, insists on exactly one row and
+// compares only the columns the expectation names:
+//
+//	dbassert.AssertRowEquals(t, db, "SELECT name, age FROM users WHERE id = ?", map[string]interface{}{
+//		"name": "Alice",
+//		"age":  30,
+//	}, 42)
+//
+// When a column differs, the failure lists each one with both values and their Go types,
+// since drivers often scan text as []byte and numbers as int64, and shows the query with
+// its arguments. A query that errors, or returns no rows or several, fails without a
+// comparison. The report otherwise reads like that of a failed diagassert.Assert.
+package dbassert
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paveg/diagassert"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/reporting"
+)
+
+// Querier is satisfied by *sql.DB and *sql.Tx.
+type Querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// AssertRowEquals runs query with args and fails the test unless it returns exactly one row
+// whose columns equal want. want is a map from column name to value, or a struct whose
+// fields are matched to columns by their `db` tag or, without one, case-insensitively by name.
+// Only the columns named in want are compared; numbers compare by value across types and
+// []byte columns compare equal to strings.
+func AssertRowEquals(t diagassert.TestingT, db Querier, query string, want interface{}, args ...interface{}) {
+	t.Helper()
+
+	wantRow, err := expectedColumns(want)
+	if err != nil {
+		report(t, failedResult(err.Error()), query, args)
+		return
+	}
+
+	row, err := queryRow(db, query, args)
+	if err != nil {
+		report(t, failedResult(err.Error()), query, args)
+		return
+	}
+
+	mismatches := compareRow(row, wantRow)
+	if len(mismatches) == 0 {
+		return
+	}
+
+	result := evaluator.EvaluateWithValues("row == want", false, 0, map[string]interface{}{
+		"row":  displayRow(row),
+		"want": wantRow,
+	})
+	result.Tree.Result = false
+	result.Tree.Differences = mismatches
+
+	report(t, result, query, args)
+}
+
+// queryRow runs the query and scans its only row into a map keyed by column name.
+func queryRow(db Querier, query string, args []interface{}) (map[string]interface{}, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read columns: %v", err)
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("query failed: %v", err)
+		}
+		return nil, fmt.Errorf("query returned no rows")
+	}
+
+	values := make([]interface{}, len(columns))
+	targets := make([]interface{}, len(columns))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return nil, fmt.Errorf("cannot scan row: %v", err)
+	}
+
+	extra := 0
+	for rows.Next() {
+		extra++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	if extra > 0 {
+		return nil, fmt.Errorf("query returned %d rows, want exactly one", extra+1)
+	}
+
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row, nil
+}
+
+// displayRow returns a copy of row with text columns scanned as []byte turned into strings.
+func displayRow(row map[string]interface{}) map[string]interface{} {
+	display := make(map[string]interface{}, len(row))
+	for column, v := range row {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		display[column] = v
+	}
+	return display
+}
+
+// expectedColumns converts want into a map from column name to expected value.
+func expectedColumns(want interface{}) (map[string]interface{}, error) {
+	if m, ok := want.(map[string]interface{}); ok {
+		return m, nil
+	}
+
+	val := reflect.ValueOf(want)
+	if val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("want must be a map[string]interface{} or a struct, got %T", want)
+	}
+
+	columns := make(map[string]interface{})
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Tag.Get("db")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		columns[name] = val.Field(i).Interface()
+	}
+	return columns, nil
+}
+
+// compareRow lists a mismatch for every expected column, sorted by name, that is missing
+// from row or holds a different value.
+func compareRow(row, want map[string]interface{}) []string {
+	names := make([]string, 0, len(want))
+	for name := range want {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var mismatches []string
+	for _, name := range names {
+		got, ok := lookupColumn(row, name)
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: column not in result (columns: %s)", name, columnList(row)))
+			continue
+		}
+		if !columnEqual(got, want[name]) {
+			mismatches = append(mismatches, fmt.Sprintf("%s: got %s, want %s", name, describe(got), describe(want[name])))
+		}
+	}
+	return mismatches
+}
+
+// lookupColumn finds a column by exact name, then case-insensitively.
+func lookupColumn(row map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := row[name]; ok {
+		return v, true
+	}
+	for column, v := range row {
+		if strings.EqualFold(column, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func columnList(row map[string]interface{}) string {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return strings.Join(columns, ", ")
+}
+
+// columnEqual compares a scanned value with an expected one the way a reader of the
+// table would: numbers by value, text regardless of string or []byte, times by instant.
+func columnEqual(got, want interface{}) bool {
+	if b, ok := got.([]byte); ok {
+		if w, ok := want.([]byte); ok {
+			return bytes.Equal(b, w)
+		}
+		got = string(b)
+	}
+
+	if g, ok := got.(time.Time); ok {
+		w, ok := want.(time.Time)
+		return ok && g.Equal(w)
+	}
+
+	if g, gok := toFloat(got); gok {
+		w, wok := toFloat(want)
+		return wok && g == w
+	}
+
+	return reflect.DeepEqual(got, want)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	}
+	return 0, false
+}
+
+// describe renders a value with its type, e.g. `"Bob" (string)` or `NULL`.
+func describe(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return fmt.Sprintf("%q (string)", t)
+	case []byte:
+		return fmt.Sprintf("%q ([]byte)", t)
+	}
+	return fmt.Sprintf("%v (%T)", v, v)
+}
+
+// failedResult describes a row assertion that could not get as far as comparing columns.
+func failedResult(reason string) *evaluator.ExpressionResult {
+	result := evaluator.EvaluateWithValues("row == want", false, 0, nil)
+	result.Tree.Result = false
+	result.Tree.Note = reason
+	return result
+}
+
+// report renders a failure located at the caller of the exported assertion, with the
+// query and its arguments among the captured values, and reports it to t.
+func report(t diagassert.TestingT, result *evaluator.ExpressionResult, query string, args []interface{}) {
+	t.Helper()
+
+	_, file, line, _ := runtime.Caller(2)
+	ctx := &formatter.AssertionContext{
+		Values: []formatter.Value{{Name: "query", Value: query}},
+	}
+	if len(args) > 0 {
+		ctx.Values = append(ctx.Values, formatter.Value{Name: "args", Value: args})
+	}
+	reporting.Report(t, reporting.Failure{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		Steps:       formatter.EvaluationSteps(result.Tree),
+		Output:      formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result, ctx, formatter.GetDefaultOptions()),
+		Fingerprint: formatter.Fingerprint(result, file, line),
+	})
+}
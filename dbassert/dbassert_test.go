@@ -0,0 +1,157 @@
+package dbassert
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// fakeDriver answers every query with the rows registered for its text.
+type fakeDriver struct{}
+
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error // Returned once the rows are read, as a connection dropped mid-query would
+}
+
+var fakeResults = map[string]fakeResult{
+	"SELECT name, age FROM users WHERE id = ?": {
+		columns: []string{"name", "age"},
+		rows:    [][]driver.Value{{[]byte("Alice"), int64(30)}},
+	},
+	"SELECT name FROM users": {
+		columns: []string{"name"},
+		rows:    [][]driver.Value{{"Alice"}, {"Bob"}},
+	},
+	"SELECT name FROM users WHERE 1 = 0": {
+		columns: []string{"name"},
+	},
+	"SELECT name FROM users LIMIT 1": {
+		columns: []string{"name"},
+		rows:    [][]driver.Value{{"Alice"}},
+		err:     errors.New("connection reset"),
+	},
+}
+
+func init() {
+	sql.Register("dbassert-fake", fakeDriver{})
+}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	result := fakeResults[s.query]
+	return &fakeRows{result: result}, nil
+}
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		if r.result.err != nil {
+			return r.result.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("dbassert-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestAssertRowEquals(t *testing.T) {
+	const query = "SELECT name, age FROM users WHERE id = ?"
+
+	t.Run("matching map and struct", func(t *testing.T) {
+		type user struct {
+			Name string
+			Age  int `db:"age"`
+		}
+
+		mock := testutil.NewMockT()
+		db := openDB(t)
+		AssertRowEquals(mock, db, query, map[string]interface{}{"name": "Alice", "age": 30}, 1)
+		AssertRowEquals(mock, db, query, user{Name: "Alice", Age: 30}, 1)
+
+		if mock.Failed() {
+			t.Errorf("AssertRowEquals should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("reports mismatching columns with types", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertRowEquals(mock, openDB(t), query, map[string]interface{}{"name": "Bob", "age": "30", "email": "x"}, 1)
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at dbassert_test.go:",
+			"DIFFERENCES in row == want:",
+			`age: got 30 (int64), want "30" (string)`,
+			"email: column not in result (columns: age, name)",
+			`name: got "Alice" ([]byte), want "Bob" (string)`,
+			"row = map[age:30 name:Alice]",
+			"CAPTURED VALUES:",
+			"query = SELECT name, age FROM users WHERE id = ? (string)",
+			"args = [1] ([]interface {})",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("requires exactly one row", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		db := openDB(t)
+		AssertRowEquals(mock, db, "SELECT name FROM users", map[string]interface{}{"name": "Alice"})
+		AssertRowEquals(mock, db, "SELECT name FROM users WHERE 1 = 0", map[string]interface{}{"name": "Alice"})
+
+		output := mock.GetOutput()
+		for _, part := range []string{"query returned 2 rows, want exactly one", "query returned no rows"} {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("errors after the row are reported", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertRowEquals(mock, openDB(t), "SELECT name FROM users LIMIT 1", map[string]interface{}{"name": "Alice"})
+
+		if output := mock.GetOutput(); !strings.Contains(output, "query failed: connection reset") {
+			t.Errorf("The error ending the rows should fail the assertion, got: %s", output)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+// Package diagtest renders diagassert failures deterministically and compares them with
+// golden files, for tests of output that is easy to break by accident: this repository's
+// own, and those of custom encoders, message catalogs and value renderers.
+//
+// Usage:
+//
+//	func TestOrderFailure(t *testing.T) {
+//		got := diagtest.Render(t, diagtest.Case{
+//			Expr:   "order.Total > 100",
+//			Values: map[string]interface{}{"order": Order{Total: 80}},
+//		})
+//		diagtest.Golden(t, "order_total", got)
+//	}
+//
+// Run the tests with -diagtest.update to write the golden files, and review the diff.
+package diagtest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+var update = flag.Bool("diagtest.update", false, "rewrite the golden files diagtest.Golden compares with")
+
+// File and Line locate every rendered failure, so that their fingerprints do not change.
+const (
+	File = "golden_test.go"
+	Line = 1
+)
+
+// Case is a failure to render: an expression, the values of its operands, as passed with
+// diagassert.V, and the settings of the output. Settings left empty take the defaults below
+// rather than the environment's, which would make the output depend on where it runs.
+type Case struct {
+	Expr    string
+	Values  map[string]interface{}
+	Message string // A custom message, as passed to Assert
+
+	Style           string // DIAGASSERT_STYLE; the default style when empty
+	Lang            string // DIAGASSERT_LANG; "en" when empty
+	Layout          string // DIAGASSERT_LAYOUT; "stable" when empty
+	MachineFormat   string // DIAGASSERT_MACHINE_FORMAT; "text" when empty
+	MachineReadable bool   // Include the machine-readable section
+	Width           int    // DIAGASSERT_MAX_WIDTH; 0 for no wrapping
+}
+
+// addresses match pointers, which change from run to run.
+var addresses = regexp.MustCompile(`0x[0-9a-f]{8,}`)
+
+// Render returns the failure diagassert would report for c, without colors and with the
+// addresses of pointers replaced by 0xADDR. The assertion is taken to have failed: an
+// expression the values make true renders with a DIAGNOSTIC MISMATCH warning. Render sets the
+// environment for the test with t.Setenv, blanking the DIAGASSERT_ variables c does not
+// set, and so cannot be used in parallel tests.
+func Render(t testing.TB, c Case) string {
+	t.Helper()
+	// Settings the Case does not give must not reach the output either
+	for _, entry := range os.Environ() {
+		if key, _, _ := strings.Cut(entry, "="); strings.HasPrefix(key, "DIAGASSERT_") {
+			t.Setenv(key, "")
+		}
+	}
+	settings := map[string]string{
+		"DIAGASSERT_CONFIG":           "false",
+		"NO_COLOR":                    "1",
+		"DIAGASSERT_COLOR":            "never",
+		"DIAGASSERT_PIPE_COLORS":      "false",
+		"DIAGASSERT_STYLE":            c.Style,
+		"DIAGASSERT_LANG":             or(c.Lang, "en"),
+		"DIAGASSERT_LAYOUT":           or(c.Layout, "stable"),
+		"DIAGASSERT_MACHINE_FORMAT":   or(c.MachineFormat, "text"),
+		"DIAGASSERT_MACHINE_READABLE": strconv.FormatBool(c.MachineReadable),
+		"DIAGASSERT_MAX_WIDTH":        strconv.Itoa(c.Width),
+		"DIAGASSERT_HISTORY":          "",
+		"DIAGASSERT_CONSTANTS":        "false",
+	}
+	for key, value := range settings {
+		t.Setenv(key, value)
+	}
+
+	result := evaluator.EvaluateWithValues(c.Expr, false, 0, c.Values)
+	evaluator.Redact(result)
+
+	var ctx *formatter.AssertionContext
+	if len(c.Values) > 0 || c.Message != "" {
+		ctx = &formatter.AssertionContext{}
+		if c.Message != "" {
+			ctx.Messages = []string{c.Message}
+		}
+		names := make([]string, 0, len(c.Values))
+		for name := range c.Values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ctx.Values = append(ctx.Values, formatter.Value{Name: name, Value: evaluator.RedactValue(name, c.Values[name])})
+		}
+	}
+
+	output := formatter.BuildDiagnosticOutputWithEvaluatorAndContext(File, Line, result, ctx, formatter.GetDefaultOptions())
+	return addresses.ReplaceAllString(formatter.StripColors(output), "0xADDR")
+}
+
+// Golden compares got with the golden file testdata/name.golden, reporting a difference as a
+// test error. With -diagtest.update it writes got to the file instead.
+func Golden(t testing.TB, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run with -diagtest.update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// or returns value, or fallback when it is empty.
+func or(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package diagtest
+
+import (
+	"strings"
+	"testing"
+)
+
+type account struct {
+	Owner   string
+	Balance int
+	Secret  string `diag:"redact"`
+	Parent  *account
+}
+
+func (a account) Overdrawn() bool { return a.Balance < 0 }
+
+// TestRender_Golden locks the full output of failures, sections included.
+func TestRender_Golden(t *testing.T) {
+	acct := account{Owner: "ann", Balance: 40, Secret: "s3cr3t", Parent: &account{Owner: "bob"}}
+	tests := []struct {
+		name string
+		c    Case
+	}{
+		{"comparison", Case{Expr: "x > 20", Values: map[string]interface{}{"x": 15}}},
+		{"logical_message", Case{
+			Expr:    `age >= 18 && name == "admin"`,
+			Values:  map[string]interface{}{"age": 16, "name": "guest"},
+			Message: "only adult admins may sign in",
+		}},
+		{"fields_machine_readable", Case{
+			Expr:            "acct.Balance >= 100 && !acct.Overdrawn()",
+			Values:          map[string]interface{}{"acct": &acct},
+			MachineReadable: true,
+		}},
+		{"compact", Case{Expr: "len(items) == n", Values: map[string]interface{}{"items": []int{1, 2}, "n": 3}, Style: "compact"}},
+		{"japanese", Case{Expr: "x > 20", Values: map[string]interface{}{"x": 15}, Lang: "ja"}},
+		{"mismatch", Case{Expr: "x > 20", Values: map[string]interface{}{"x": 30}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Golden(t, tt.name, Render(t, tt.c))
+		})
+	}
+}
+
+func TestRender_Deterministic(t *testing.T) {
+	t.Setenv("FORCE_COLOR", "1")
+	c := Case{Expr: "p.Balance > 0", Values: map[string]interface{}{"p": &account{Balance: -1}}, MachineReadable: true}
+
+	first := Render(t, c)
+	if second := Render(t, c); first != second {
+		t.Errorf("Render() should give the same output each time, got:\n%s\nthen:\n%s", first, second)
+	}
+	if strings.Contains(first, "\x1b[") {
+		t.Errorf("Render() should strip colors, got: %q", first)
+	}
+	if !strings.Contains(first, "0xADDR") {
+		t.Errorf("Render() should replace addresses, got: %s", first)
+	}
+	for key, value := range map[string]string{"DIAGASSERT_STYLE": "compact", "DIAGASSERT_POINTER_DEPTH": "0", "DIAGASSERT_REDACT": "p,balance"} {
+		t.Setenv(key, value)
+	}
+	if got := Render(t, c); got != first {
+		t.Errorf("Render() should ignore the environment's settings, got:\n%s\nwant:\n%s", got, first)
+	}
+}
@@ -0,0 +1,83 @@
+package diagassert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// AssertDuration checks that measured is within budget, and outputs detailed diagnostic
+// information if not:
+//
+//	start := time.Now()
+//	index.Rebuild()
+//	AssertDuration(t, time.Since(start), 50*time.Millisecond)
+//
+// The failure shows both durations and by how much, and what percentage of the budget,
+// measured exceeds it, under DURATION, which the machine-readable section repeats between
+// DURATION_START and DURATION_END. In a benchmark, check the time per operation once the
+// loop is done:
+//
+//	AssertDuration(b, b.Elapsed()/time.Duration(b.N), time.Microsecond)
+//
+// Trailing args are handled as in Assert.
+func AssertDuration(t TestingT, measured, budget time.Duration, args ...interface{}) {
+	t.Helper()
+
+	recordAssertion(measured <= budget, "")
+	if measured <= budget {
+		return
+	}
+
+	failure := buildDurationFailureInfo(measured, budget, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// buildDurationFailureInfo builds diagnostic information for a failed AssertDuration.
+func buildDurationFailureInfo(measured, budget time.Duration, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source the durations are named generically
+	measuredText, budgetText := "elapsed", "budget"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, "AssertDuration"); err == nil && len(args) >= 3 &&
+		!strings.Contains(args[1]+args[2], "\n") {
+		measuredText, budgetText = args[1], args[2]
+	}
+
+	result := evaluator.EvaluateDuration(measuredText, budgetText, measured, budget)
+	evaluator.Redact(result)
+
+	over := fmt.Sprintf("took %s, %s over", measured, measured-budget)
+	if budget > 0 {
+		overshoot := float64(measured-budget) / float64(budget) * 100
+		over = fmt.Sprintf("took %s, %s (%.1f%%) over", measured, measured-budget, overshoot)
+	}
+	ctx.sections = append(ctx.sections, formatter.Section{Title: "DURATION", Lines: []string{
+		fmt.Sprintf("%s the budget of %s", over, budget),
+		"profile with -cpuprofile, or -trace for time spent waiting, to see where it goes",
+	}})
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
@@ -0,0 +1,80 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// Benchmarks get the same assertions as tests
+var _ TestingT = (*testing.B)(nil)
+
+func TestAssertDuration(t *testing.T) {
+	t.Run("durations within the budget pass", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertDuration(mock, 10*time.Millisecond, 50*time.Millisecond)
+		AssertDuration(mock, 50*time.Millisecond, 50*time.Millisecond)
+
+		if mock.Failed() {
+			t.Errorf("AssertDuration should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure shows the overshoot", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		elapsed, budget := 63*time.Millisecond, 50*time.Millisecond
+		AssertDuration(mock, elapsed, budget, "rebuild")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at duration_test.go:",
+			"assert(elapsed <= budget)",
+			"63ms",
+			"DURATION:\n  took 63ms, 13ms (26.0%) over the budget of 50ms\n",
+			"CUSTOM MESSAGE:\nrebuild",
+			"DURATION_START\ntook 63ms, 13ms (26.0%) over the budget of 50ms\n",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("computed durations keep their source", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		start := time.Now().Add(-time.Second)
+		AssertDuration(mock, time.Since(start), 50*time.Millisecond)
+
+		if output := mock.GetOutput(); !strings.Contains(output, "assert(time.Since(start) <= 50*time.Millisecond)") {
+			t.Errorf("Output should show the arguments as written, got: %s", output)
+		}
+	})
+
+	t.Run("no budget", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertDuration(mock, time.Millisecond, 0)
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "took 1ms, 1ms over the budget of 0s") || strings.Contains(output, "%)") {
+			t.Errorf("A zero budget has no overshoot percentage, got: %s", output)
+		}
+	})
+}
+
+func TestAssertDuration_Benchmark(t *testing.T) {
+	var failed bool
+	result := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Assert(b, i >= 0)
+		}
+		AssertDuration(b, b.Elapsed()/time.Duration(b.N), time.Second)
+		failed = b.Failed()
+	})
+
+	if failed || result.N == 0 {
+		t.Errorf("assertions should pass in a benchmark, got %d runs, failed: %v", result.N, failed)
+	}
+}
@@ -0,0 +1,96 @@
+package diagassert
+
+import (
+	"reflect"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// Empty checks that a string, slice, array, map or channel has no elements, or that value
+// is nil, and outputs detailed diagnostic information if not:
+//
+//	Empty(t, order.Items)
+//
+// The failure is that of len(order.Items) == 0 and so shows the length and the first
+// elements, see DIAGASSERT_PREVIEW_ELEMENTS. Values of other types are never empty.
+// Trailing args are handled as in Assert.
+func Empty(t TestingT, value interface{}, args ...interface{}) {
+	t.Helper()
+
+	n, ok := lengthOf(value)
+	passed := value == nil || ok && n == 0
+	recordAssertion(passed, "")
+	if passed {
+		return
+	}
+
+	failure := buildLengthFailureInfo("Empty", value, true, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// NotEmpty checks that a string, slice, array, map or channel has elements, and outputs
+// detailed diagnostic information if not, as the failure of len(value) != 0. nil and
+// values of other types are never non-empty. Trailing args are handled as in Assert.
+func NotEmpty(t TestingT, value interface{}, args ...interface{}) {
+	t.Helper()
+
+	n, ok := lengthOf(value)
+	passed := ok && n > 0
+	recordAssertion(passed, "")
+	if passed {
+		return
+	}
+
+	failure := buildLengthFailureInfo("NotEmpty", value, false, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// lengthOf returns the length of the values len accepts. ok is false for other values.
+func lengthOf(value interface{}) (n int, ok bool) {
+	if value == nil {
+		return 0, false
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// buildLengthFailureInfo builds diagnostic information for a failed Empty or NotEmpty, named
+// by helper.
+func buildLengthFailureInfo(helper string, value interface{}, empty bool, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source the value is named generically
+	valueText := "value"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, helper); err == nil && len(args) >= 2 {
+		valueText = args[1]
+	}
+
+	result := evaluator.EvaluateLength(valueText, value, empty)
+	evaluator.Redact(result)
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
@@ -0,0 +1,80 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestEmpty(t *testing.T) {
+	type order struct{ Items []string }
+
+	t.Run("empty values pass", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		Empty(mock, []int{})
+		Empty(mock, map[string]int(nil))
+		Empty(mock, "")
+		Empty(mock, nil)
+
+		if mock.Failed() {
+			t.Errorf("Empty should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure previews the elements", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_PREVIEW_ELEMENTS", "2")
+		mock := testutil.NewMockT()
+		o := order{Items: []string{"pen", "ink", "nib"}}
+		Empty(mock, o.Items, "cart should be cleared")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at empty_test.go:",
+			"assert(len(o.Items) == 0)",
+			"LIKELY CAUSE: len(o.Items) == 0 is false because len(o.Items) = 3",
+			"CONTENTS:\n  o.Items (3 elements): [\"pen\" \"ink\" … +1 more]",
+			"CUSTOM MESSAGE:\ncart should be cleared",
+			"CONTENTS: o.Items (3 elements): [\"pen\" \"ink\" … +1 more]",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+}
+
+func TestNotEmpty(t *testing.T) {
+	t.Run("non-empty values pass", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		NotEmpty(mock, []int{1})
+		NotEmpty(mock, "a")
+
+		if mock.Failed() {
+			t.Errorf("NotEmpty should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			check func(TestingT)
+			want  string
+		}{
+			{"empty string", func(mock TestingT) { name := ""; NotEmpty(mock, name) }, "len(name) != 0 is false because len(name) = 0"},
+			{"nil", func(mock TestingT) { NotEmpty(mock, nil) }, "nil is nil"},
+			{"no length", func(mock TestingT) { NotEmpty(mock, 42) }, "42 is of type int, which has no length"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				mock := testutil.NewMockT()
+				tt.check(mock)
+
+				if output := mock.GetOutput(); !mock.Failed() || !strings.Contains(output, tt.want) {
+					t.Errorf("NotEmpty should fail with %q, got: %s", tt.want, output)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package diagassert
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// Values of DIAGASSERT_OUTPUT_ENCODING.
+const (
+	encodingPlain   = "plain"
+	encodingEscaped = "escaped"
+	encodingBase64  = "base64"
+)
+
+// Markers introducing the payload of an encoded diagnostic.
+const (
+	escapedMarker = " | DIAGASSERT_ESCAPED "
+	base64Marker  = " | DIAGASSERT_BASE64 "
+)
+
+// outputEncoding returns the encoding of diagnostics reported to the test.
+// DIAGASSERT_OUTPUT_ENCODING may be "plain" (default), "escaped" or "base64".
+func outputEncoding() string {
+	switch encoding := config.Getenv("DIAGASSERT_OUTPUT_ENCODING"); encoding {
+	case encodingEscaped, encodingBase64:
+		return encoding
+	}
+	return encodingPlain
+}
+
+// encodeOutput turns a multi-line diagnostic into a single line when an encoding is
+// configured, so that go test -json emits it as one output event that cannot be split
+// or reordered. The line starts with the diagnostic's first line, without colors, for
+// human readers; the payload keeps the diagnostic byte for byte:
+//
+//	ASSERTION FAILED at user_test.go:42 | DIAGASSERT_ESCAPED "ASSERTION FAILED at user_test.go:42\nExpression: ..."
+func encodeOutput(output string) string {
+	encoding := outputEncoding()
+	if encoding == encodingPlain {
+		return output
+	}
+	header, _, _ := strings.Cut(output, "\n")
+	header = formatter.StripColors(header)
+
+	switch encoding {
+	case encodingEscaped:
+		return header + escapedMarker + strconv.Quote(output)
+	case encodingBase64:
+		return header + base64Marker + base64.StdEncoding.EncodeToString([]byte(output))
+	}
+	return output
+}
+
+// DecodeOutput reconstructs a diagnostic from a line written with DIAGASSERT_OUTPUT_ENCODING
+// set to "escaped" or "base64", such as the Output of a go test -json event. It reports
+// false if the line does not carry an encoded diagnostic.
+func DecodeOutput(line string) (string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+
+	if i := strings.Index(line, escapedMarker); i >= 0 {
+		output, err := strconv.Unquote(line[i+len(escapedMarker):])
+		return output, err == nil
+	}
+	if i := strings.Index(line, base64Marker); i >= 0 {
+		output, err := base64.StdEncoding.DecodeString(line[i+len(base64Marker):])
+		return string(output), err == nil
+	}
+	return "", false
+}
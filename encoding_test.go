@@ -0,0 +1,55 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestOutputEncoding(t *testing.T) {
+	for _, encoding := range []string{"escaped", "base64"} {
+		t.Run(encoding, func(t *testing.T) {
+			t.Setenv("DIAGASSERT_OUTPUT_ENCODING", encoding)
+
+			var plain string
+			remove := OnFailure(func(f FailureInfo) { plain = f.Output })
+			defer remove()
+
+			mock := testutil.NewMockT()
+			x := 5
+			Assert(mock, x > 10)
+
+			output := strings.TrimRight(mock.GetOutput(), "\n")
+			if strings.Contains(output, "\n") {
+				t.Fatalf("Encoded output should be a single line, got:\n%s", output)
+			}
+			if !strings.HasPrefix(output, "ASSERTION FAILED at encoding_test.go:") {
+				t.Errorf("Encoded output should start with the header for humans, got: %s", output)
+			}
+
+			decoded, ok := DecodeOutput(output)
+			if !ok {
+				t.Fatalf("DecodeOutput should recognize %q", output)
+			}
+			if decoded != plain || !strings.Contains(decoded, "x > 10") {
+				t.Errorf("Decoded output should match the rendered diagnostic.\nDecoded: %s\nRendered: %s", decoded, plain)
+			}
+		})
+	}
+
+	t.Run("plain by default", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_OUTPUT_ENCODING", "")
+
+		mock := testutil.NewMockT()
+		Assert(mock, 1 > 2)
+
+		output := strings.TrimRight(mock.GetOutput(), "\n")
+		if !strings.Contains(output, "\n") {
+			t.Errorf("Plain output should stay multi-line, got: %s", output)
+		}
+		if _, ok := DecodeOutput(output); ok {
+			t.Error("DecodeOutput should not recognize plain output")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package diagassert
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// EnvironmentKeys are environment variables listed in the RUNTIME section of a failure. See
+// WithEnvironmentKeys.
+type EnvironmentKeys []string
+
+// WithEnvironmentKeys adds a RUNTIME section to the failure, listing the Go version,
+// platform and working directory of the test binary and the values of keys, so that a
+// failure on a remote machine carries what is needed to reproduce it:
+//
+//	diagassert.Assert(t, ok, diagassert.WithEnvironmentKeys("GOFLAGS", "FEATURE_FLAGS"))
+//
+// The job IDs of common CI services are listed whenever they are set. DIAGASSERT_RUNTIME=true
+// adds the section to every failure, and DIAGASSERT_ENV_KEYS lists further keys for all of
+// them. Values are shown as they are set unless their keys are redacted, as with
+// Redact("DATABASE_URL"), so keep keys holding credentials redacted.
+func WithEnvironmentKeys(keys ...string) EnvironmentKeys {
+	return EnvironmentKeys(keys)
+}
+
+// ciEnvironmentKeys identify the CI job a failure happened in.
+var ciEnvironmentKeys = []string{
+	"GITHUB_RUN_ID", "GITHUB_JOB", // GitHub Actions
+	"CI_JOB_ID",        // GitLab CI
+	"BUILDKITE_JOB_ID", // Buildkite
+	"CIRCLE_BUILD_NUM", // CircleCI
+}
+
+// environmentLines lists the facts of the RUNTIME section, or nil when neither keys nor
+// DIAGASSERT_RUNTIME ask for it:
+//
+//	go: go1.22.1 linux/amd64
+//	dir: /home/ci/src/app
+//	GITHUB_RUN_ID=9150318204
+func environmentLines(keys []string, requested bool) []string {
+	if !requested && config.Getenv("DIAGASSERT_RUNTIME") != "true" {
+		return nil
+	}
+
+	lines := []string{"go: " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH}
+	if dir, err := os.Getwd(); err == nil {
+		lines = append(lines, "dir: "+dir)
+	}
+
+	all := append(append([]string{}, ciEnvironmentKeys...), strings.Split(config.Getenv("DIAGASSERT_ENV_KEYS"), ",")...)
+	seen := make(map[string]bool)
+	for _, key := range append(all, keys...) {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if value, ok := os.LookupEnv(key); ok {
+			lines = append(lines, key+"="+evaluator.RedactValue(key, value).(string))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,52 @@
+package diagassert
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestWithEnvironmentKeys(t *testing.T) {
+	t.Setenv("DIAGASSERT_RUNTIME", "")
+	t.Setenv("DIAGASSERT_ENV_KEYS", "")
+	t.Setenv("GITHUB_RUN_ID", "9150318204")
+	t.Setenv("APP_REGION", "eu-west-1")
+	t.Setenv("APP_TOKEN", "s3cr3t")
+	Redact("APP_TOKEN")
+
+	failure := func(args ...interface{}) string {
+		mock := testutil.NewMockT()
+		ready := false
+		Assert(mock, ready, args...)
+		return mock.GetOutput()
+	}
+
+	if output := failure(); strings.Contains(output, "RUNTIME") {
+		t.Errorf("RUNTIME should only be shown when asked for, got: %s", output)
+	}
+
+	output := failure(WithEnvironmentKeys("APP_REGION", "APP_TOKEN", "APP_UNSET"))
+	dir, _ := os.Getwd()
+	expected := []string{
+		"RUNTIME:\n  go: " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH + "\n  dir: " + dir + "\n",
+		"  GITHUB_RUN_ID=9150318204\n  APP_REGION=eu-west-1\n  APP_TOKEN=***\n",
+		"RUNTIME_START",
+	}
+	for _, part := range expected {
+		if !strings.Contains(output, part) {
+			t.Errorf("Output should contain %q, got: %s", part, output)
+		}
+	}
+	if strings.Contains(output, "APP_UNSET") || strings.Contains(output, "s3cr3t") {
+		t.Errorf("Unset and redacted variables should not be shown, got: %s", output)
+	}
+
+	t.Setenv("DIAGASSERT_RUNTIME", "true")
+	t.Setenv("DIAGASSERT_ENV_KEYS", "APP_REGION")
+	if output := failure(); !strings.Contains(output, "RUNTIME:") || !strings.Contains(output, "APP_REGION=eu-west-1") {
+		t.Errorf("DIAGASSERT_RUNTIME and DIAGASSERT_ENV_KEYS should apply to every failure, got: %s", output)
+	}
+}
@@ -0,0 +1,102 @@
+package diagassert
+
+import (
+	"reflect"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// EqualRule changes how Equal compares values. See Ignore and SortSlices.
+type EqualRule struct {
+	apply func(rules *evaluator.EqualRules)
+}
+
+// Ignore leaves fields out of Equal's comparison. A name, as "CreatedAt", matches the field
+// at any depth; a path of field names, as "Items.ID", matches where the path ends in it.
+//
+// Usage: diagassert.Equal(t, got, want, diagassert.Ignore("CreatedAt", "ID"))
+func Ignore(fields ...string) EqualRule {
+	return EqualRule{apply: func(rules *evaluator.EqualRules) {
+		rules.Ignore = append(rules.Ignore, fields...)
+	}}
+}
+
+// SortSlices makes Equal compare slices of T without regard to their order, by sorting both
+// by less first.
+//
+// Usage: diagassert.Equal(t, got, want, diagassert.SortSlices(func(a, b Tag) bool { return a.Name < b.Name }))
+func SortSlices[T any](less func(a, b T) bool) EqualRule {
+	return EqualRule{apply: func(rules *evaluator.EqualRules) {
+		if rules.Sorts == nil {
+			rules.Sorts = make(map[reflect.Type]func(a, b reflect.Value) bool)
+		}
+		rules.Sorts[reflect.TypeOf((*T)(nil)).Elem()] = func(a, b reflect.Value) bool {
+			return less(a.Interface().(T), b.Interface().(T))
+		}
+	}}
+}
+
+// Equal checks that got and want are deeply equal under the rules among args, and outputs
+// detailed diagnostic information if not:
+//
+//	Equal(t, got, want, Ignore("CreatedAt", "ID"), SortSlices(byName))
+//
+// Values are compared field by field, as reflect.DeepEqual does, with the fields Ignore names
+// left out and the slices SortSlices orders sorted. The failure lists the paths of the fields
+// that differ, as ".Items[1].Price: 10 != 12", rather than both values in full. Other
+// trailing args are handled as in Assert.
+func Equal(t TestingT, got, want interface{}, args ...interface{}) {
+	t.Helper()
+
+	var rules evaluator.EqualRules
+	for _, arg := range args {
+		if rule, ok := arg.(EqualRule); ok && rule.apply != nil {
+			rule.apply(&rules)
+		}
+	}
+
+	equal := len(evaluator.EqualDifferences(got, want, rules)) == 0
+	recordAssertion(equal, "")
+	if equal {
+		return
+	}
+
+	failure := buildEqualFailureInfo(got, want, rules, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// buildEqualFailureInfo builds diagnostic information for a failed Equal.
+func buildEqualFailureInfo(got, want interface{}, rules evaluator.EqualRules, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source the values are named generically
+	gotText, wantText := "got", "want"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, "Equal"); err == nil && len(args) >= 3 {
+		gotText, wantText = args[1], args[2]
+	}
+
+	result := evaluator.EvaluateEqual(gotText, wantText, got, want, rules)
+	evaluator.Redact(result)
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
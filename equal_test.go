@@ -0,0 +1,68 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestEqual(t *testing.T) {
+	type tag struct{ Name string }
+	type order struct {
+		ID        int
+		CreatedAt time.Time
+		Customer  string
+		Tags      []tag
+	}
+	byName := SortSlices(func(a, b tag) bool { return a.Name < b.Name })
+
+	t.Run("equal under the rules", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		got := order{ID: 1, CreatedAt: time.Now(), Customer: "ann", Tags: []tag{{"b"}, {"a"}}}
+		want := order{ID: 2, Customer: "ann", Tags: []tag{{"a"}, {"b"}}}
+		Equal(mock, got, want, Ignore("CreatedAt", "ID"), byName)
+		Equal(mock, []int{1, 2}, []int{1, 2})
+
+		if mock.Failed() {
+			t.Errorf("Equal should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure lists the paths that differ", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		got := order{ID: 1, CreatedAt: time.Now(), Customer: "ann", Tags: []tag{{"b"}, {"a"}}}
+		want := order{ID: 2, Customer: "bob", Tags: []tag{{"a"}, {"c"}}}
+		Equal(mock, got, want, Ignore("CreatedAt", "ID"), byName, "order from the API")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at equal_test.go:",
+			"assert(got == want)",
+			"LIKELY CAUSE: got == want is false because they differ at .Customer, .Tags[1].Name\n",
+			"compared ignoring CreatedAt, ID and sorting []diagassert.tag",
+			"DIFFERENCES in got == want:\n  .Customer: \"ann\" != \"bob\"\n  .Tags[1].Name: \"b\" != \"c\"\n",
+			"CUSTOM MESSAGE:\norder from the API",
+			"DIFF: got == want: .Customer: \"ann\" != \"bob\"",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("strings show where they diverge", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		greeting := "hello world"
+		Equal(mock, greeting, "hello there")
+
+		output := mock.GetOutput()
+		for _, part := range []string{`assert(greeting == "hello there")`, "first difference at rune 6"} {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+}
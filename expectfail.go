@@ -0,0 +1,130 @@
+package diagassert
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// expectation is the known issue ExpectFail marked a test with.
+type expectation struct {
+	issue  string
+	failed bool // An assertion of the test failed since
+}
+
+var (
+	expectationsMu     sync.Mutex
+	expectationsByName = map[string]*expectation{}   // Expectations of named tests, which their subtests share
+	expectationsByT    = map[TestingT]*expectation{} // Expectations of tests without a name
+)
+
+// ExpectFail marks the assertions made from now on in the test and its subtests as expected
+// to fail because of a known issue, such as "ISSUE-123" or the URL of its tracker entry:
+//
+//	diagassert.ExpectFail(t, "https://github.com/org/repo/issues/123")
+//
+// Their failures are logged in full under EXPECTED FAILURE with the issue, and do not fail
+// the test; a failing Require skips the rest of it. Should no assertion have failed by the
+// time the test ends, the test fails with UNEXPECTED PASS instead, so that the mark is
+// removed once the issue is fixed. Tests without Log, Skip or Cleanup methods lose the
+// parts that need them.
+func ExpectFail(t TestingT, issue string) {
+	t.Helper()
+
+	e := &expectation{issue: issue}
+	name := testName(t)
+	expectationsMu.Lock()
+	switch {
+	case name != "":
+		expectationsByName[name] = e
+	case reflect.TypeOf(t).Comparable():
+		expectationsByT[t] = e
+	}
+	expectationsMu.Unlock()
+
+	c, ok := t.(interface{ Cleanup(func()) })
+	if !ok {
+		return
+	}
+	c.Cleanup(func() {
+		t.Helper()
+
+		expectationsMu.Lock()
+		failed := e.failed
+		if name != "" {
+			delete(expectationsByName, name)
+		} else {
+			delete(expectationsByT, t)
+		}
+		expectationsMu.Unlock()
+
+		if !failed {
+			t.Error(formatter.Message(formatter.MsgUnexpectedPass, issue))
+		}
+	})
+}
+
+// expectedFailure returns the issue ExpectFail marked t or, for subtests, the closest test
+// above it with, and records that an assertion failed as expected. It returns "" when no
+// failure is expected.
+func expectedFailure(t TestingT) string {
+	expectationsMu.Lock()
+	defer expectationsMu.Unlock()
+
+	var e *expectation
+	for name := testName(t); name != "" && e == nil; {
+		e = expectationsByName[name]
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	if e == nil && reflect.TypeOf(t).Comparable() {
+		e = expectationsByT[t]
+	}
+	if e == nil {
+		return ""
+	}
+	e.failed = true
+	return e.issue
+}
+
+// reportCollected reports the output of failures SoftT.Report or Retry collected for the
+// test name, under EXPECTED FAILURE if issue is set.
+func reportCollected(t TestingT, name, issue, output string, writers []io.Writer, fatal bool) {
+	t.Helper()
+
+	if issue != "" {
+		output = formatter.Message(formatter.MsgExpectedFailure, issue) + "\n" + output
+	}
+	routeOutput(name, output, writers)
+	output = encodeOutput(output)
+	switch {
+	case issue != "":
+		reportExpectedFailure(t, output, fatal)
+	case fatal:
+		t.Fatal(output)
+	default:
+		t.Error(output)
+	}
+}
+
+// reportExpectedFailure reports a failure ExpectFail expected: logged, and skipping the rest
+// of the test if fatal, but not failing it.
+func reportExpectedFailure(t TestingT, output string, fatal bool) {
+	t.Helper()
+
+	if fatal {
+		if s, ok := t.(interface{ Skip(args ...interface{}) }); ok {
+			s.Skip(output)
+			return
+		}
+	}
+	if l, ok := t.(interface{ Log(args ...interface{}) }); ok {
+		l.Log(output)
+	}
+}
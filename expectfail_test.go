@@ -0,0 +1,110 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// xfailT is a test with the methods ExpectFail uses, recording what it logs and skips.
+type xfailT struct {
+	cleanupT
+	name    string
+	logged  []string
+	skipped string
+}
+
+func newXfailT(name string) *xfailT {
+	return &xfailT{cleanupT: cleanupT{MockT: testutil.NewMockT()}, name: name}
+}
+
+func (x *xfailT) Name() string { return x.name }
+
+func (x *xfailT) Log(args ...interface{}) { x.logged = append(x.logged, args[0].(string)) }
+
+func (x *xfailT) Skip(args ...interface{}) {
+	x.skipped = args[0].(string)
+	panic("SkipNow called")
+}
+
+func TestExpectFail(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	t.Run("failures are logged", func(t *testing.T) {
+		xt := newXfailT("TestKnownBug")
+		var got FailureInfo
+		remove := OnFailure(func(f FailureInfo) { got = f })
+		defer remove()
+
+		ExpectFail(xt, "ISSUE-123")
+		total := 3
+		Assert(xt, total == 4)
+		xt.end()
+
+		if xt.Failed() {
+			t.Errorf("An expected failure should not fail the test, got: %s", xt.GetOutput())
+		}
+		if len(xt.logged) != 1 || !strings.HasPrefix(xt.logged[0], "EXPECTED FAILURE (ISSUE-123): a known issue, so the test does not fail\nASSERTION FAILED at") ||
+			!strings.Contains(xt.logged[0], "assert(total == 4)") {
+			t.Errorf("The failure should be logged under EXPECTED FAILURE, got: %q", xt.logged)
+		}
+		if got.KnownIssue != "ISSUE-123" {
+			t.Errorf("Hooks should see the known issue, got %q", got.KnownIssue)
+		}
+	})
+
+	t.Run("subtests inherit the mark and Require skips", func(t *testing.T) {
+		parent := newXfailT("TestKnownBug")
+		ExpectFail(parent, "ISSUE-7")
+		sub := newXfailT("TestKnownBug/case")
+		func() {
+			defer func() { recover() }()
+			Require(sub, len("ab") == 3)
+			t.Error("Require should stop the subtest")
+		}()
+		parent.end()
+
+		if sub.Failed() || parent.Failed() {
+			t.Errorf("An expected failure should fail neither test, got: %s%s", sub.GetOutput(), parent.GetOutput())
+		}
+		if !strings.HasPrefix(sub.skipped, "EXPECTED FAILURE (ISSUE-7)") {
+			t.Errorf("Require should skip the subtest with the failure, got: %q", sub.skipped)
+		}
+	})
+
+	t.Run("a failing Retry", func(t *testing.T) {
+		xt := newXfailT("TestFlakyBug")
+		ExpectFail(xt, "ISSUE-1")
+		Retry(xt, 2, 0, func(a *Attempt) {
+			status := "running"
+			Assert(a, status == "done")
+		})
+		xt.end()
+
+		if xt.Failed() {
+			t.Errorf("An expected Retry failure should not fail the test, got: %s", xt.GetOutput())
+		}
+		if len(xt.logged) != 1 || !strings.HasPrefix(xt.logged[0], "EXPECTED FAILURE (ISSUE-1)") || !strings.Contains(xt.logged[0], "RETRY HISTORY") {
+			t.Errorf("The Retry failure should be logged under EXPECTED FAILURE, got: %q", xt.logged)
+		}
+	})
+
+	t.Run("unexpected pass", func(t *testing.T) {
+		xt := newXfailT("TestFixedBug")
+		ExpectFail(xt, "ISSUE-9")
+		Assert(xt, 1 < 2)
+		xt.end()
+
+		if !xt.Failed() || !strings.Contains(xt.GetOutput(), "UNEXPECTED PASS: no assertion failed, although the test was expected to fail because of ISSUE-9") {
+			t.Errorf("A test expected to fail that passes should fail, got: %s", xt.GetOutput())
+		}
+
+		// The mark ends with the test
+		other := newXfailT("TestFixedBug")
+		Assert(other, 1 > 2)
+		if !other.Failed() {
+			t.Errorf("Failures after the marked test ended should fail, got: %q", other.logged)
+		}
+	})
+}
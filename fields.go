@@ -0,0 +1,82 @@
+package diagassert
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// maxFieldsDepth is how many levels of nested structs Fields expands; deeper structs are
+// captured whole.
+const maxFieldsDepth = 3
+
+// FieldValues are named values in order, as Fields returns them.
+type FieldValues []Value
+
+// Fields captures the exported fields of a struct, or of the struct a pointer points to, as
+// values named prefix.Field, in declaration order, so that a table case is captured with one
+// argument. Nested structs are expanded into prefix.Field.Sub in turn, up to three levels;
+// structs that describe themselves, such as time.Time, and deeper ones are captured whole.
+// Fields tagged `diag:"-"` are left out and those tagged `diag:"redact"` are masked. Any
+// other value is captured as prefix.
+//
+// Usage: diagassert.Assert(t, got == tc.want, diagassert.Fields("tc", tc))
+func Fields(prefix string, obj interface{}) FieldValues {
+	var values FieldValues
+	expandFields(&values, prefix, obj, 0)
+	if len(values) == 0 && !expandable(obj) {
+		values = append(values, Value{Name: prefix, Value: obj})
+	}
+	return values
+}
+
+// expandFields appends the exported fields of obj, named after prefix, expanding nested
+// structs until maxFieldsDepth.
+func expandFields(values *FieldValues, prefix string, obj interface{}, depth int) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !expandable(obj) {
+		return
+	}
+
+	names, fieldValues, _ := evaluator.StructFields(v.Interface())
+	for i, name := range names {
+		if !token.IsExported(name) {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if depth+1 < maxFieldsDepth && expandable(fieldValues[i]) {
+			expandFields(values, name, fieldValues[i], depth+1)
+			continue
+		}
+		*values = append(*values, Value{Name: name, Value: fieldValues[i]})
+	}
+}
+
+// expandable reports whether obj is a struct, or a pointer to one, with exported fields that
+// does not describe itself with DiagString, Error or String.
+func expandable(obj interface{}) bool {
+	switch obj.(type) {
+	case DiagStringer, error, fmt.Stringer:
+		return false
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
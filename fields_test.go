@@ -0,0 +1,55 @@
+package diagassert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+type fieldsLimits struct{ Daily, Monthly int }
+
+type fieldsCase struct {
+	Name     string
+	Input    int
+	Limits   *fieldsLimits
+	Deadline time.Time
+	Token    string `diag:"redact"`
+	Scratch  []byte `diag:"-"`
+	internal int
+}
+
+func TestFields(t *testing.T) {
+	deadline := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tc := fieldsCase{Name: "daily", Input: 70, Limits: &fieldsLimits{Daily: 50, Monthly: 900}, Deadline: deadline, Token: "s3cr3t", internal: 1}
+
+	var names []string
+	for _, v := range Fields("tc", &tc) {
+		names = append(names, v.Name)
+		if v.Name == "tc.Token" && fmt.Sprint(v.Value) != "***" {
+			t.Errorf("tc.Token = %v, want it masked", v.Value)
+		}
+	}
+	want := "tc.Name tc.Input tc.Limits.Daily tc.Limits.Monthly tc.Deadline tc.Token"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("Fields() named %s, want %s", got, want)
+	}
+
+	if got := Fields("n", 3); len(got) != 1 || got[0].Name != "n" || got[0].Value != 3 {
+		t.Errorf("Fields() of a number = %v, want it captured as n", got)
+	}
+
+	mock := testutil.NewMockT()
+	Assert(mock, tc.Input <= tc.Limits.Daily, Fields("tc", tc))
+	output := mock.GetOutput()
+	for _, part := range []string{"tc.Input = 70", "tc.Limits.Daily = 50", "tc.Token = ***"} {
+		if !strings.Contains(output, part) {
+			t.Errorf("Output should contain %q, got: %s", part, output)
+		}
+	}
+	if strings.Contains(output, "s3cr3t") {
+		t.Errorf("Output should not show the redacted token, got: %s", output)
+	}
+}
@@ -0,0 +1,95 @@
+package diagassert
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	fixturesMu     sync.Mutex
+	fixturesByName = map[string][]Value{}   // Fixtures of named tests, which their subtests share
+	fixturesByT    = map[TestingT][]Value{} // Fixtures of tests without a name
+)
+
+// Fixture registers a fixture the test was set up with, such as the name of a database
+// snapshot or the IDs of the users seeded, and lists it in the FIXTURES section of every
+// failure in the test and its subtests. Setup code registers what it creates, so that the
+// assertions need not pass it:
+//
+//	func seedUsers(t *testing.T, db *sql.DB) []int64 {
+//		ids := insertUsers(t, db)
+//		diagassert.Fixture(t, "users", ids)
+//		return ids
+//	}
+//
+// Registering a fixture again by the same name replaces it. Fixtures are masked like values
+// when their names are redacted, and forgotten when the test ends if t has a Cleanup method.
+func Fixture(t TestingT, name string, value interface{}) {
+	t.Helper()
+
+	key := testName(t)
+	if key == "" && !reflect.TypeOf(t).Comparable() {
+		return
+	}
+
+	fixturesMu.Lock()
+	var fixtures []Value
+	if key != "" {
+		fixtures = fixturesByName[key]
+	} else {
+		fixtures = fixturesByT[t]
+	}
+	fixtures = replaceValue(fixtures, Value{Name: name, Value: value})
+	first := len(fixtures) == 1
+	if key != "" {
+		fixturesByName[key] = fixtures
+	} else {
+		fixturesByT[t] = fixtures
+	}
+	fixturesMu.Unlock()
+
+	if c, ok := t.(interface{ Cleanup(func()) }); ok && first {
+		c.Cleanup(func() {
+			fixturesMu.Lock()
+			defer fixturesMu.Unlock()
+			if key != "" {
+				delete(fixturesByName, key)
+			} else {
+				delete(fixturesByT, t)
+			}
+		})
+	}
+}
+
+// fixturesOf returns the fixtures registered for t and the tests above it, those of the
+// outermost test first. A subtest's fixture replaces one of the same name above it.
+func fixturesOf(t TestingT) []Value {
+	fixturesMu.Lock()
+	defer fixturesMu.Unlock()
+
+	var fixtures []Value
+	if name := testName(t); name != "" {
+		parts := strings.Split(name, "/")
+		for i := range parts {
+			for _, v := range fixturesByName[strings.Join(parts[:i+1], "/")] {
+				fixtures = replaceValue(fixtures, v)
+			}
+		}
+	} else if reflect.TypeOf(t).Comparable() {
+		fixtures = append(fixtures, fixturesByT[t]...)
+	}
+	return fixtures
+}
+
+// replaceValue returns values with v in place of the value of the same name, or with v
+// appended when there is none.
+func replaceValue(values []Value, v Value) []Value {
+	for i := range values {
+		if values[i].Name == v.Name {
+			values[i] = v
+			return values
+		}
+	}
+	return append(values, v)
+}
@@ -0,0 +1,69 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestFixture(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	t.Run("named", func(t *testing.T) {
+		Fixture(t, "snapshot", "orders_v3")
+		Fixture(t, "users", []int{7, 9})
+		Fixture(t, "snapshot", "orders_v4")
+
+		// Failures in subtests list the fixtures of the tests above them, and their own
+		mock := namedMockT{testutil.NewMockT(), t.Name() + "/case"}
+		Fixture(mock, "users", []int{11})
+		Assert(mock, 1 > 2)
+		output := mock.GetOutput()
+		if !strings.Contains(output, "FIXTURES:\n  snapshot: orders_v4\n  users: [11]\n") {
+			t.Errorf("Output should list the fixtures, got: %s", output)
+		}
+		if !strings.Contains(output, "FIXTURES_START\nsnapshot: orders_v4\nusers: [11]\nFIXTURES_END\n") {
+			t.Errorf("Machine-readable section should list the fixtures, got: %s", output)
+		}
+
+		// Tests beside it do not inherit them
+		other := namedMockT{testutil.NewMockT(), "TestOther"}
+		Assert(other, 1 > 2)
+		if strings.Contains(other.GetOutput(), "FIXTURES") {
+			t.Errorf("Other tests should not list the fixtures, got: %s", other.GetOutput())
+		}
+	})
+
+	t.Run("forgotten after the test", func(t *testing.T) {
+		var name string
+		t.Run("setup", func(t *testing.T) {
+			name = t.Name()
+			Fixture(t, "snapshot", "orders_v3")
+		})
+		mock := namedMockT{testutil.NewMockT(), name}
+		Assert(mock, 1 > 2)
+		if strings.Contains(mock.GetOutput(), "FIXTURES") {
+			t.Errorf("Fixtures should be forgotten when their test ends, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("redacted", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_REDACT", "dbPassword")
+		mock := namedMockT{testutil.NewMockT(), t.Name()}
+		Fixture(t, "dbPassword", "hunter2")
+		Assert(mock, 1 > 2)
+		if strings.Contains(mock.GetOutput(), "hunter2") {
+			t.Errorf("Redacted fixtures should be masked, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("unnamed", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		Fixture(mock, "tenant", "acme")
+		Assert(mock, 1 > 2)
+		if !strings.Contains(mock.GetOutput(), "tenant: acme") {
+			t.Errorf("Output should list the fixture, got: %s", mock.GetOutput())
+		}
+	})
+}
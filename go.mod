@@ -2,10 +2,10 @@ module github.com/paveg/diagassert
 
 go 1.20
 
-require github.com/fatih/color v1.18.0
-
 require (
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.25.0 // indirect
+	github.com/google/go-cmp v0.5.5
+	github.com/mattn/go-isatty v0.0.20
+	golang.org/x/sys v0.25.0
+	golang.org/x/tools v0.12.0
+	google.golang.org/protobuf v1.34.2
 )
@@ -0,0 +1,109 @@
+package diagassert
+
+import (
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// maxGoroutines is how many goroutines the GOROUTINES section lists at most.
+const maxGoroutines = 50
+
+var (
+	goroutineHeader = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+	frameArgs       = regexp.MustCompile(`\([^()]*\)$`)
+	frameOffset     = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+)
+
+// addGoroutines adds the other goroutines of the test binary to a failure that terminates
+// the test, as Require's do, when DIAGASSERT_GOROUTINES is "true". A failed precondition is
+// often a worker that got stuck or deadlocked, which its stack shows.
+func addGoroutines(ctx *AssertionContext) {
+	if config.Getenv("DIAGASSERT_GOROUTINES") != "true" {
+		return
+	}
+	if lines := filterGoroutines(goroutineDump()); len(lines) > 0 {
+		ctx.sections = append(ctx.sections, formatter.Section{Title: "GOROUTINES", Lines: lines})
+	}
+}
+
+// goroutineDump returns the stacks of all goroutines, the current one first.
+func goroutineDump() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// filterGoroutines shortens a goroutine dump for the GOROUTINES section. The current
+// goroutine, which made the assertion, is left out, and so are frames of the runtime and
+// the testing package and goroutines with no other frames. Frames lose their arguments
+// and offsets:
+//
+//	goroutine 7 [chan receive]:
+//	  example.com/app.(*Pool).worker
+//	      /src/app/pool.go:42
+func filterGoroutines(dump string) []string {
+	var lines []string
+	listed, omitted := 0, 0
+	for i, block := range strings.Split(strings.TrimSpace(dump), "\n\n") {
+		if i == 0 {
+			continue
+		}
+		frames := goroutineFrames(block)
+		if frames == nil {
+			continue
+		}
+		if listed == maxGoroutines {
+			omitted++
+			continue
+		}
+		listed++
+		lines = append(lines, frames...)
+	}
+	if omitted > 0 {
+		lines = append(lines, "... "+strconv.Itoa(omitted)+" more goroutines")
+	}
+	return lines
+}
+
+// goroutineFrames returns the header and the kept frames of one goroutine of a dump, or
+// nil if none are kept.
+func goroutineFrames(block string) []string {
+	rows := strings.Split(block, "\n")
+	if !goroutineHeader.MatchString(rows[0]) {
+		return nil
+	}
+
+	var frames []string
+	for i := 1; i+1 < len(rows); i += 2 {
+		// Deep stacks have a line marking the frames the runtime left out
+		if strings.HasPrefix(rows[i], "...") {
+			frames = append(frames, "  "+rows[i])
+			i--
+			continue
+		}
+		function := strings.TrimSpace(rows[i])
+		name := strings.TrimPrefix(function, "created by ")
+		if strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "testing.") {
+			continue
+		}
+		if !strings.HasPrefix(function, "created by ") {
+			function = frameArgs.ReplaceAllString(function, "")
+		}
+		location := frameOffset.ReplaceAllString(strings.TrimSpace(rows[i+1]), "")
+		frames = append(frames, "  "+function, "      "+location)
+	}
+	if frames == nil {
+		return nil
+	}
+	return append([]string{rows[0]}, frames...)
+}
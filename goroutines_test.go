@@ -0,0 +1,97 @@
+package diagassert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+const sampleDump = `goroutine 6 [running]:
+github.com/paveg/diagassert.goroutineDump()
+	/src/diagassert/goroutines.go:38 +0x45
+testing.tRunner(0xc000007a00, 0x5b8e40)
+	/usr/local/go/src/testing/testing.go:1689 +0xfb
+
+goroutine 1 [chan receive]:
+testing.(*T).Run(0xc000007860, {0x5a0f3e, 0x9}, 0x5b8e40)
+	/usr/local/go/src/testing/testing.go:1750 +0x3ab
+main.main()
+	_testmain.go:47 +0x195
+
+goroutine 7 [chan receive, 2 minutes]:
+runtime.gopark(0x0?, 0x0?, 0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:402 +0xce
+example.com/app.(*Pool).worker(0xc00001c0c0, 0x1)
+	/src/app/pool.go:42 +0x2a
+created by example.com/app.NewPool in goroutine 6
+	/src/app/pool.go:20 +0x7d
+
+goroutine 8 [select]:
+testing.(*T).Parallel(0xc000007a00)
+	/usr/local/go/src/testing/testing.go:1484 +0x1d9
+created by testing.(*T).Run in goroutine 1
+	/usr/local/go/src/testing/testing.go:1742 +0x390
+`
+
+func TestFilterGoroutines(t *testing.T) {
+	got := filterGoroutines(sampleDump)
+	want := []string{
+		"goroutine 1 [chan receive]:",
+		"  main.main",
+		"      _testmain.go:47",
+		"goroutine 7 [chan receive, 2 minutes]:",
+		"  example.com/app.(*Pool).worker",
+		"      /src/app/pool.go:42",
+		"  created by example.com/app.NewPool in goroutine 6",
+		"      /src/app/pool.go:20",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterGoroutines =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// stuckWorker waits until released, as a worker deadlocked on a channel would.
+func stuckWorker(started, release chan struct{}) {
+	close(started)
+	<-release
+}
+
+func TestRequire_Goroutines(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	go stuckWorker(started, release)
+	<-started
+
+	run := func() string {
+		mock := testutil.NewMockT()
+		func() {
+			defer func() { _ = recover() }()
+			ready := false
+			Require(mock, ready)
+		}()
+		return mock.GetOutput()
+	}
+
+	if output := run(); strings.Contains(output, "GOROUTINES:") {
+		t.Errorf("goroutines should only be listed with DIAGASSERT_GOROUTINES=true:\n%s", output)
+	}
+
+	t.Setenv("DIAGASSERT_GOROUTINES", "true")
+	output := run()
+	if !strings.Contains(output, "GOROUTINES:") || !strings.Contains(output, "diagassert.stuckWorker") {
+		t.Errorf("expected the stuck worker under GOROUTINES, got:\n%s", output)
+	}
+	if strings.Contains(output, "runtime.gopark") || strings.Contains(output, "testing.tRunner") {
+		t.Errorf("runtime and testing frames should be left out:\n%s", output)
+	}
+
+	// Assert does not terminate the test, so it leaves the goroutines out
+	mock := testutil.NewMockT()
+	Assert(mock, output == "")
+	if strings.Contains(mock.GetOutput(), "GOROUTINES:") {
+		t.Errorf("Assert should not list goroutines:\n%s", mock.GetOutput())
+	}
+}
@@ -0,0 +1,187 @@
+package diagassert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// historyRuns is how many of the last runs the history of a failure covers.
+const historyRuns = 10
+
+// historyMaxSize caps the history file. Past it, the oldest half of the file is dropped
+// when a run starts.
+const historyMaxSize = 1 << 20
+
+var (
+	historyMu       sync.Mutex
+	historyRunIDs   = map[string]string{} // Run of this process, by history file
+	historyRecorded = map[string]bool{}   // Failures already recorded in this run, by file and fingerprint
+)
+
+func init() {
+	formatter.SetHistory(lookupHistory)
+}
+
+// startHistoryRun records that a test process ran in the history file DIAGASSERT_HISTORY
+// names, once per process, so that runs in which nothing failed are counted too.
+func startHistoryRun() {
+	path := config.Getenv("DIAGASSERT_HISTORY")
+	if path == "" {
+		return
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	historyRun(path)
+}
+
+// historyRun returns the ID of this process's run in the history file at path, recording
+// the run with the directory its tests run in when it is new. historyMu must be held.
+func historyRun(path string) string {
+	if id, ok := historyRunIDs[path]; ok {
+		return id
+	}
+	id := fmt.Sprintf("%d-%d", time.Now().UnixNano(), os.Getpid())
+	historyRunIDs[path] = id
+	trimHistory(path)
+	appendHistory(path, fmt.Sprintf("run %s %s", id, historyDir()))
+	return id
+}
+
+// trimHistory drops the oldest half of the history file at path once it is larger than
+// historyMaxSize. The rest replaces the file at once, so readers never see it cut short.
+func trimHistory(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() <= historyMaxSize {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	data = data[len(data)/2:]
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// historyDir is the directory the tests of this process run in, their package's directory
+// under go test. Runs are only compared with earlier runs of the same package.
+func historyDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return dir
+}
+
+// lookupHistory returns in how many of the package's last historyRuns runs, recorded in the
+// history file DIAGASSERT_HISTORY names, the failure with the fingerprint failed. The
+// current run counts as failed, since the failure being formatted is why it is asked.
+// Errors leave the failure without a history.
+func lookupHistory(fingerprint string) (failed, runs int) {
+	path := config.Getenv("DIAGASSERT_HISTORY")
+	if path == "" {
+		return 0, 0
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	return readHistory(path, fingerprint, historyRun(path))
+}
+
+// recordHistory records a failure reported to a test in the history file DIAGASSERT_HISTORY
+// names, once per run. Failures without a fingerprint, which were not evaluated, are not
+// recorded.
+func recordHistory(fingerprint string) {
+	path := config.Getenv("DIAGASSERT_HISTORY")
+	if path == "" || fingerprint == "" {
+		return
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	id := historyRun(path)
+	if key := path + "\x00" + fingerprint; !historyRecorded[key] {
+		historyRecorded[key] = true
+		appendHistory(path, fmt.Sprintf("fail %s %s", id, fingerprint))
+	}
+}
+
+// appendHistory appends a line to the history file. Each line is written at once, so test
+// processes of several packages can share the file.
+func appendHistory(path, line string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
+
+// readHistory counts the runs of this package among the last historyRuns in which the
+// failure with the fingerprint was recorded, counting the current run as one of them.
+func readHistory(path, fingerprint, current string) (failed, runs int) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	dir := historyDir()
+	var order []string
+	failedRuns := map[string]bool{current: true}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Directories may hold spaces, so only the first two separate fields
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		switch fields[0] {
+		case "run":
+			if fields[2] == dir {
+				order = append(order, fields[1])
+			}
+		case "fail":
+			if fields[2] == fingerprint {
+				failedRuns[fields[1]] = true
+			}
+		}
+	}
+
+	if len(order) > historyRuns {
+		order = order[len(order)-historyRuns:]
+	}
+	for _, id := range order {
+		if failedRuns[id] {
+			failed++
+		}
+	}
+	return failed, len(order)
+}
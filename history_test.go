@@ -0,0 +1,104 @@
+package diagassert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	t.Setenv("DIAGASSERT_HISTORY", path)
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+
+	x := 1
+	fail := func() string {
+		mock := testutil.NewMockT()
+		Assert(mock, x == 2)
+		return mock.GetOutput()
+	}
+
+	output := fail()
+	if !strings.Contains(output, "HISTORY: 1/1\n") || strings.Contains(output, "possibly flaky") {
+		t.Fatalf("a first failure should have a history of one run, got:\n%s", output)
+	}
+	match := regexp.MustCompile(`FINGERPRINT: (\w+)`).FindStringSubmatch(output)
+	if match == nil {
+		t.Fatalf("output should contain a fingerprint, got:\n%s", output)
+	}
+
+	// Four earlier runs of this package, two of them failing the same way, and one elsewhere
+	dir, _ := os.Getwd()
+	var past []string
+	for i := 0; i < 4; i++ {
+		past = append(past, fmt.Sprintf("run past-%d %s", i, dir))
+		if i%2 == 0 {
+			past = append(past, fmt.Sprintf("fail past-%d %s", i, match[1]))
+		}
+	}
+	past = append(past, "run other "+filepath.Join(dir, "other pkg"), "fail other "+match[1])
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(past, "\n")+"\n"+string(current)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output = fail()
+	for _, want := range []string{"failed in 3 of the last 5 runs, possibly flaky", "HISTORY: 3/5\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output should contain %q, got:\n%s", want, output)
+		}
+	}
+
+	// A failure is recorded once per run
+	data, _ := os.ReadFile(path)
+	if n := strings.Count(string(data), " "+match[1]+"\n"); n != 4 {
+		t.Errorf("the failure should be recorded in 4 runs, got %d in:\n%s", n, data)
+	}
+}
+
+func TestHistory_OnlyReportedFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	t.Setenv("DIAGASSERT_HISTORY", path)
+
+	// A failed attempt of a Retry that passes in the end is not a failure of the run
+	calls := 0
+	Retry(testutil.NewMockT(), 2, 0, func(a *Attempt) {
+		calls++
+		Assert(a, calls == 2)
+	})
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "fail ") {
+		t.Errorf("no failure should be recorded, got:\n%s", data)
+	}
+
+	mock := testutil.NewMockT()
+	Assert(mock, calls == 3)
+	if data, _ := os.ReadFile(path); strings.Count(string(data), "fail ") != 1 {
+		t.Errorf("the reported failure should be recorded once, got:\n%s", data)
+	}
+}
+
+func TestHistory_Trimmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	t.Setenv("DIAGASSERT_HISTORY", path)
+	old := strings.Repeat("run old-run /elsewhere\n", historyMaxSize/20)
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	startHistoryRun()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > historyMaxSize || !strings.HasPrefix(string(data), "run old-run ") || !strings.Contains(string(data), "run "+historyRunIDs[path]) {
+		t.Errorf("the history should keep its newest lines within %d bytes, got %d bytes", historyMaxSize, len(data))
+	}
+}
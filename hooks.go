@@ -0,0 +1,115 @@
+package diagassert
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/reporting"
+)
+
+// FailureInfo describes a failed assertion. It is passed to the hooks registered with OnFailure.
+type FailureInfo struct {
+	File        string                 // Source file of the failed Assert or Require call, or of the caller WithCallerSkip points at
+	Line        int                    // Line of the failed call
+	Expression  string                 // Asserted expression as written in the source
+	Variables   map[string]interface{} // Values known for the expression's variables
+	Messages    []string               // Custom messages passed to the assertion
+	Attachments []Attachment           // Artifacts passed with Attach, with the paths they were written to
+	Steps       []string               // Evaluation steps, as in the machine-readable section
+	Stack       []string               // Frames of the STACK section, from the assertion up; see DIAGASSERT_STACK_DEPTH
+	Test        string                 // Name of the test, when t has a Name method like *testing.T
+	Owners      []string               // Owners of File in CODEOWNERS, when DIAGASSERT_CODEOWNERS is set
+	Output      string                 // Rendered diagnostic output, before DIAGASSERT_OUTPUT_ENCODING is applied
+	KnownIssue  string                 // Issue ExpectFail marked the test with; the failure does not fail the test
+
+	writers     []io.Writer // Writers passed with OutputTo
+	maxRepeats  int         // Limit passed with WithMaxRepeats, 0 for the default
+	fingerprint string      // Identifies the failure in DIAGASSERT_HISTORY; empty when it was not evaluated
+
+	tree *evaluator.EvaluationTree // Evaluated expression, listed into Steps only when hooks run
+}
+
+// Helper packages report their failures through reportFailure, so hooks and Retry see them too
+func init() {
+	reporting.SetReporter(func(t reporting.TestingT, f reporting.Failure) {
+		t.Helper()
+		reportFailure(t, FailureInfo{
+			File:        f.File,
+			Line:        f.Line,
+			Expression:  f.Expression,
+			Variables:   f.Variables,
+			Steps:       f.Steps,
+			Output:      f.Output,
+			fingerprint: f.Fingerprint,
+		}, false)
+	})
+}
+
+var (
+	failureHooksMu sync.RWMutex
+	failureHooks   = map[int]func(FailureInfo){}
+	nextHookID     int
+)
+
+// OnFailure registers fn to run on every failed assertion, before the failure is
+// reported to the test. Hooks can dump goroutine stacks, collect profiles or snapshot
+// external state. The returned function unregisters the hook.
+//
+// Usage:
+//
+//	remove := diagassert.OnFailure(func(f diagassert.FailureInfo) {
+//		log.Printf("%s:%d: %s", f.File, f.Line, f.Expression)
+//	})
+//	defer remove()
+func OnFailure(fn func(FailureInfo)) (remove func()) {
+	failureHooksMu.Lock()
+	defer failureHooksMu.Unlock()
+
+	id := nextHookID
+	nextHookID++
+	failureHooks[id] = fn
+
+	return func() {
+		failureHooksMu.Lock()
+		defer failureHooksMu.Unlock()
+		delete(failureHooks, id)
+	}
+}
+
+// testName returns the name of the test behind t, or "" if t does not expose one.
+func testName(t TestingT) string {
+	if named, ok := t.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+// runFailureHooks calls the registered hooks in registration order.
+func runFailureHooks(failure FailureInfo) {
+	failureHooksMu.RLock()
+	if len(failureHooks) == 0 {
+		failureHooksMu.RUnlock()
+		return
+	}
+	ids := make([]int, 0, len(failureHooks))
+	for id := range failureHooks {
+		ids = append(ids, id)
+	}
+	hooks := make([]func(FailureInfo), 0, len(ids))
+	sort.Ints(ids)
+	for _, id := range ids {
+		hooks = append(hooks, failureHooks[id])
+	}
+	failureHooksMu.RUnlock()
+
+	if failure.Steps == nil && failure.tree != nil {
+		failure.Steps = formatter.EvaluationSteps(failure.tree)
+	}
+	// Hooks run without the lock so they may register or remove hooks themselves
+	for _, hook := range hooks {
+		hook(failure)
+	}
+}
@@ -0,0 +1,85 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestOnFailure(t *testing.T) {
+	t.Run("hook receives failure details", func(t *testing.T) {
+		var got []FailureInfo
+		remove := OnFailure(func(f FailureInfo) {
+			got = append(got, f)
+		})
+		defer remove()
+
+		mock := testutil.NewMockT()
+		x := 5
+		Assert(mock, x > 10, V("x", x), "x too small")
+
+		if len(got) != 1 {
+			t.Fatalf("Expected hook to run once, ran %d times", len(got))
+		}
+		failure := got[0]
+		if !strings.HasSuffix(failure.File, "hooks_test.go") || failure.Line == 0 {
+			t.Errorf("Unexpected location %s:%d", failure.File, failure.Line)
+		}
+		if failure.Expression != "x > 10" {
+			t.Errorf("Expression = %q, want %q", failure.Expression, "x > 10")
+		}
+		if failure.Variables["x"] != 5 {
+			t.Errorf("Variables[x] = %v, want 5", failure.Variables["x"])
+		}
+		if len(failure.Messages) != 1 || failure.Messages[0] != "x too small" {
+			t.Errorf("Messages = %v, want [x too small]", failure.Messages)
+		}
+		if len(failure.Steps) == 0 || !strings.HasPrefix(failure.Steps[0], "`x` => 5") {
+			t.Errorf("Steps = %v, want the evaluation steps starting with x", failure.Steps)
+		}
+		if failure.Test != "" {
+			t.Errorf("Test = %q, want empty for a TestingT without Name", failure.Test)
+		}
+		if failure.Output != mock.GetOutput() {
+			t.Errorf("Output should match what was reported to the test.\nHook: %s\nTest: %s", failure.Output, mock.GetOutput())
+		}
+	})
+
+	t.Run("hook receives the test name", func(t *testing.T) {
+		var got FailureInfo
+		remove := OnFailure(func(f FailureInfo) { got = f })
+		defer remove()
+
+		Assert(namedMockT{testutil.NewMockT(), "TestNamed"}, false)
+
+		if got.Test != "TestNamed" {
+			t.Errorf("Test = %q, want %q", got.Test, "TestNamed")
+		}
+	})
+
+	t.Run("hooks run in order and not on success", func(t *testing.T) {
+		var order []string
+		removeFirst := OnFailure(func(FailureInfo) { order = append(order, "first") })
+		removeSecond := OnFailure(func(FailureInfo) { order = append(order, "second") })
+
+		Assert(testutil.NewMockT(), true)
+		Assert(testutil.NewMockT(), false)
+
+		removeFirst()
+		removeSecond()
+		Assert(testutil.NewMockT(), false)
+
+		if strings.Join(order, ",") != "first,second" {
+			t.Errorf("Hook calls = %v, want [first second]", order)
+		}
+	})
+}
+
+// namedMockT is a MockT that reports a test name like *testing.T.
+type namedMockT struct {
+	*testutil.MockT
+	name string
+}
+
+func (m namedMockT) Name() string { return m.name }
@@ -0,0 +1,206 @@
+// Package httpassert provides assertions on HTTP responses whose failures show the whole
+// exchange: request method and URL, status, headers (with credentials redacted) and body.
+//
+// Usage:
+//
+//	resp, _ := http.Get(srv.URL + "/items")
+//	httpassert.AssertStatus(t, resp, http.StatusOK)
+//	httpassert.AssertJSONBody(t, resp, "$.items[0].id", 42)
+//
+// Failures are rendered by the same formatter as diagassert.Assert, including the
+// machine-readable section.
+package httpassert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/paveg/diagassert"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/jsonpath"
+	"github.com/paveg/diagassert/internal/reporting"
+)
+
+// maxBodyBytes caps how much of the body is shown in a failure.
+const maxBodyBytes = 2048
+
+// redactedHeaders lists the headers whose values are never printed.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// AssertStatus fails the test unless resp has the wanted status code.
+func AssertStatus(t diagassert.TestingT, resp *http.Response, want int) {
+	t.Helper()
+
+	if resp != nil && resp.StatusCode == want {
+		return
+	}
+
+	var status interface{}
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	result := evaluator.EvaluateWithValues("status == want", false, 0, map[string]interface{}{
+		"status": status,
+		"want":   want,
+	})
+
+	report(t, result, resp, readBody(resp))
+}
+
+// AssertJSONBody fails the test unless the value at path in the JSON response body equals want.
+// want is compared after a JSON round trip, so 42 matches the number 42 and structs match
+// objects with the same members. The body remains readable after the call.
+func AssertJSONBody(t diagassert.TestingT, resp *http.Response, path string, want interface{}) {
+	t.Helper()
+
+	body := readBody(resp)
+	normalizedWant, err := jsonpath.Normalize(want)
+	if err != nil {
+		report(t, failedResult(fmt.Sprintf("cannot encode want as JSON: %v", err)), resp, body)
+		return
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		report(t, failedResult(fmt.Sprintf("response body is not JSON: %v", err)), resp, body)
+		return
+	}
+
+	got, err := jsonpath.Select(doc, path)
+	if err != nil {
+		report(t, failedResult(fmt.Sprintf("cannot select %s: %v", path, err)), resp, body)
+		return
+	}
+
+	differences := jsonpath.Diff(path, got, normalizedWant)
+	if len(differences) == 0 {
+		return
+	}
+
+	result := evaluator.EvaluateWithValues("got == want", false, 0, map[string]interface{}{
+		"got":  got,
+		"want": normalizedWant,
+	})
+	result.Tree.Result = false
+	result.Tree.Differences = differences
+
+	report(t, result, resp, body)
+}
+
+// failedResult describes a body assertion that could not get as far as comparing values.
+func failedResult(reason string) *evaluator.ExpressionResult {
+	result := evaluator.EvaluateWithValues("got == want", false, 0, nil)
+	result.Tree.Result = false
+	result.Tree.Note = reason
+	return result
+}
+
+// report renders a failure located at the caller of the exported assertion and reports it to t.
+func report(t diagassert.TestingT, result *evaluator.ExpressionResult, resp *http.Response, body []byte) {
+	t.Helper()
+
+	_, file, line, _ := runtime.Caller(2)
+	ctx := &formatter.AssertionContext{
+		Sections: []formatter.Section{{Title: "HTTP RESPONSE", Lines: describeResponse(resp, body)}},
+	}
+	reporting.Report(t, reporting.Failure{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		Steps:       formatter.EvaluationSteps(result.Tree),
+		Output:      formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result, ctx, formatter.GetDefaultOptions()),
+		Fingerprint: formatter.Fingerprint(result, file, line),
+	})
+}
+
+// readBody reads the whole body and puts it back so the caller can still read it.
+func readBody(resp *http.Response) []byte {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// describeResponse lists the request line, status, headers and body of an exchange.
+func describeResponse(resp *http.Response, body []byte) []string {
+	if resp == nil {
+		return []string{"(nil response)"}
+	}
+
+	var lines []string
+	if req := resp.Request; req != nil && req.URL != nil {
+		lines = append(lines, fmt.Sprintf("%s %s", req.Method, req.URL.Redacted()))
+	}
+	lines = append(lines, fmt.Sprintf("Status: %s", resp.Status))
+
+	if len(resp.Header) > 0 {
+		lines = append(lines, "Headers:")
+		lines = append(lines, describeHeaders(resp.Header)...)
+	}
+	if req := resp.Request; req != nil && len(req.Header) > 0 {
+		lines = append(lines, "Request headers:")
+		lines = append(lines, describeHeaders(req.Header)...)
+	}
+
+	if len(body) > 0 {
+		shown := body
+		if len(shown) > maxBodyBytes {
+			shown = shown[:maxBodyBytes]
+			lines = append(lines, fmt.Sprintf("Body (first %d of %d bytes):", maxBodyBytes, len(body)))
+		} else {
+			lines = append(lines, fmt.Sprintf("Body (%d bytes):", len(body)))
+		}
+		for _, line := range strings.Split(prettyBody(shown), "\n") {
+			lines = append(lines, "  "+line)
+		}
+	}
+
+	return lines
+}
+
+// describeHeaders renders headers sorted by name, redacting credentials.
+func describeHeaders(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			value = "[REDACTED]"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", name, value))
+	}
+	return lines
+}
+
+// prettyBody indents JSON bodies and returns anything else unchanged.
+func prettyBody(body []byte) string {
+	var out bytes.Buffer
+	if json.Valid(body) && json.Indent(&out, body, "", "  ") == nil {
+		return out.String()
+	}
+	return strings.TrimRight(string(body), "\n")
+}
@@ -0,0 +1,145 @@
+package httpassert
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert"
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func newResponse(t *testing.T, status int, body string) *http.Response {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAssertStatus(t *testing.T) {
+	t.Run("passes on matching status", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertStatus(mock, newResponse(t, http.StatusOK, `{}`), http.StatusOK)
+
+		if mock.Failed() {
+			t.Errorf("AssertStatus should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure shows the exchange", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertStatus(mock, newResponse(t, http.StatusInternalServerError, `{"error":"boom"}`), http.StatusOK)
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at httpassert_test.go:",
+			"status == want is false because status = 500, want = 200",
+			"HTTP RESPONSE:",
+			"GET http://127.0.0.1:",
+			"Status: 500 Internal Server Error",
+			"Content-Type: application/json",
+			"Authorization: [REDACTED]",
+			`"error": "boom"`,
+			"HTTP_RESPONSE_START",
+			"HTTP_RESPONSE_END",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+		if strings.Contains(output, "secret-token") {
+			t.Errorf("Authorization header should be redacted, got: %s", output)
+		}
+	})
+}
+
+func TestFailureHooks(t *testing.T) {
+	var failures []diagassert.FailureInfo
+	remove := diagassert.OnFailure(func(f diagassert.FailureInfo) { failures = append(failures, f) })
+	defer remove()
+
+	mock := testutil.NewMockT()
+	AssertStatus(mock, newResponse(t, http.StatusNotFound, `{}`), http.StatusOK)
+
+	if len(failures) != 1 {
+		t.Fatalf("Expected one hook call, got %d", len(failures))
+	}
+	f := failures[0]
+	if f.Expression != "status == want" || !strings.HasSuffix(f.File, "httpassert_test.go") {
+		t.Errorf("Unexpected failure info: %s at %s:%d", f.Expression, f.File, f.Line)
+	}
+	if f.Variables["status"] != http.StatusNotFound || f.Output != mock.GetOutput() {
+		t.Errorf("Hook should see the captured values and reported output, got %v", f.Variables)
+	}
+}
+
+func TestAssertJSONBody(t *testing.T) {
+	const body = `{"items":[{"id":41,"name":"apple"}]}`
+
+	t.Run("passes on matching value and keeps body readable", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		resp := newResponse(t, http.StatusOK, body)
+		AssertJSONBody(mock, resp, "$.items[0].name", "apple")
+		AssertJSONBody(mock, resp, "items[0]", map[string]interface{}{"id": 41, "name": "apple"})
+
+		if mock.Failed() {
+			t.Errorf("AssertJSONBody should pass, got: %s", mock.GetOutput())
+		}
+		if data, _ := io.ReadAll(resp.Body); string(data) != body {
+			t.Errorf("Body should still be readable, got %q", data)
+		}
+	})
+
+	t.Run("reports differences with JSON paths", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertJSONBody(mock, newResponse(t, http.StatusOK, body), "$.items[0]", map[string]interface{}{"id": 42, "name": "apple"})
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "$.items[0].id: 41 != 42") {
+			t.Errorf("Should report the differing path, got: %s", output)
+		}
+		if !strings.Contains(output, "DIFF: got == want: $.items[0].id: 41 != 42") {
+			t.Errorf("Machine-readable section should carry the difference, got: %s", output)
+		}
+	})
+
+	t.Run("reports paths that cannot be selected", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertJSONBody(mock, newResponse(t, http.StatusOK, body), "$.items[2].id", 42)
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "cannot select $.items[2].id: $.items has length 1, no index 2") {
+			t.Errorf("Should explain why the path cannot be selected, got: %s", output)
+		}
+	})
+
+	t.Run("reports bodies that are not JSON", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertJSONBody(mock, newResponse(t, http.StatusOK, "<html>"), "$.id", 1)
+
+		if !strings.Contains(mock.GetOutput(), "response body is not JSON") {
+			t.Errorf("Should report invalid JSON, got: %s", mock.GetOutput())
+		}
+	})
+}
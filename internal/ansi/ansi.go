@@ -0,0 +1,84 @@
+// Package ansi writes text with ANSI color and style escape sequences. It covers what the
+// formatter draws with, so diagassert needs no color library: the sequences match those of
+// github.com/fatih/color, which it replaced.
+package ansi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NoColor turns every Color into plain text when true. Unlike color libraries, it is not
+// worked out from the environment: the formatter decides whether to color and sets it.
+var NoColor = false
+
+// Attribute is an SGR parameter, such as a foreground color or bold.
+type Attribute int
+
+// Styles
+const (
+	Reset Attribute = iota
+	Bold
+	Faint
+)
+
+// resetBold ends both Bold and Faint.
+const resetBold Attribute = 22
+
+// Foreground colors
+const (
+	FgBlack Attribute = iota + 30
+	FgRed
+	FgGreen
+	FgYellow
+	FgBlue
+	FgMagenta
+	FgCyan
+	FgWhite
+)
+
+// Bright foreground colors
+const (
+	FgHiBlack Attribute = iota + 90
+	FgHiRed
+	FgHiGreen
+	FgHiYellow
+	FgHiBlue
+	FgHiMagenta
+	FgHiCyan
+	FgHiWhite
+)
+
+// Color is a combination of attributes that text is written with.
+type Color struct {
+	start, end string
+}
+
+// New returns the Color of attrs.
+func New(attrs ...Attribute) *Color {
+	start := make([]string, len(attrs))
+	end := make([]string, len(attrs))
+	for i, attr := range attrs {
+		start[i] = strconv.Itoa(int(attr))
+		reset := Reset
+		if attr == Bold || attr == Faint {
+			reset = resetBold
+		}
+		end[i] = strconv.Itoa(int(reset))
+	}
+	return &Color{
+		start: "\x1b[" + strings.Join(start, ";") + "m",
+		end:   "\x1b[" + strings.Join(end, ";") + "m",
+	}
+}
+
+// Sprint formats a as fmt.Sprint does and wraps the text in the escape sequences of c, unless
+// NoColor is set.
+func (c *Color) Sprint(a ...interface{}) string {
+	s := fmt.Sprint(a...)
+	if NoColor {
+		return s
+	}
+	return c.start + s + c.end
+}
@@ -0,0 +1,31 @@
+package ansi
+
+import "testing"
+
+func TestColor_Sprint(t *testing.T) {
+	defer func(noColor bool) { NoColor = noColor }(NoColor)
+
+	tests := []struct {
+		name  string
+		color *Color
+		want  string
+	}{
+		{"foreground", New(FgRed), "\x1b[31mtext\x1b[0m"},
+		{"bright", New(FgHiBlack), "\x1b[90mtext\x1b[0m"},
+		{"bold", New(FgRed, Bold), "\x1b[31;1mtext\x1b[0;22m"},
+		{"faint", New(Faint), "\x1b[2mtext\x1b[22m"},
+	}
+	NoColor = false
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.color.Sprint("text"); got != tt.want {
+				t.Errorf("Sprint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	NoColor = true
+	if got := New(FgRed, Bold).Sprint("text"); got != "text" {
+		t.Errorf("Sprint() with NoColor = %q, want plain text", got)
+	}
+}
@@ -0,0 +1,17 @@
+// Package astutil holds the helpers for Go syntax trees that the evaluator, the formatter
+// and the analyzer share.
+package astutil
+
+import "go/ast"
+
+// Unparen strips any enclosing parentheses from an expression, as ast.Unparen does in Go
+// 1.22 and later.
+func Unparen(node ast.Expr) ast.Expr {
+	for {
+		paren, ok := node.(*ast.ParenExpr)
+		if !ok {
+			return node
+		}
+		node = paren.X
+	}
+}
@@ -0,0 +1,26 @@
+package astutil
+
+import (
+	"go/parser"
+	"go/types"
+	"testing"
+)
+
+func TestUnparen(t *testing.T) {
+	tests := map[string]string{
+		"x":           "x",
+		"(x)":         "x",
+		"((a + b))":   "a + b",
+		"(a) + (b)":   "(a) + (b)",
+		"(f(x)).Name": "(f(x)).Name",
+	}
+	for expr, want := range tests {
+		node, err := parser.ParseExpr(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := types.ExprString(Unparen(node)); got != want {
+			t.Errorf("Unparen(%s) = %s, want %s", expr, got, want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package config reads the settings of diagassert: the DIAGASSERT_ environment variables,
+// with defaults from a settings file at the root of the module under test, so that a team
+// can share them without each developer exporting the variables.
+//
+// The file is .diagassert.yaml, .diagassert.yml or .diagassert.toml, or the file
+// DIAGASSERT_CONFIG names ("false" for none), and sets each setting by the name of its
+// variable without the prefix, in lower case:
+//
+//	style: compact
+//	lang: ja
+//	redact: [password, api_key]
+//
+// Environment variables take precedence over the file, and options passed to an assertion
+// over both.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// prefix starts the names of the environment variables of the settings.
+const prefix = "DIAGASSERT_"
+
+// names are the names the settings file is looked for by at the module root, in order.
+var names = []string{".diagassert.yaml", ".diagassert.yml", ".diagassert.toml"}
+
+// settings are the settings the file may set, by the names of their variables without the
+// prefix. DIAGASSERT_SEED is left out: a seed belongs to a run, not to a module.
+var settings = map[string]bool{
+	"ARTIFACTS_DIR": true, "CALL_TIMEOUT": true, "CODEOWNERS": true, "COLOR": true, "CONSTANTS": true, "COVERAGE": true,
+	"DIFF_STYLE": true, "ENV_KEYS": true, "EXPAND": true, "GOROUTINES": true,
+	"HINTS": true, "HISTORY": true, "LANG": true, "LAYOUT": true,
+	"MACHINE_FORMAT": true, "MACHINE_READABLE": true, "MAX_REPEATS": true, "MAX_WIDTH": true, "NORMALIZE": true,
+	"OUTPUT_DIR": true, "OUTPUT_ENCODING": true, "PIPE_COLORS": true, "POINTER_DEPTH": true,
+	"PREVIEW_ELEMENTS": true, "PURE_METHODS": true, "RAW_VALUES": true, "REDACT": true,
+	"REEVALUATE": true, "REPRO": true, "RUNTIME": true, "SRC_ROOT": true,
+	"STACK_DEPTH": true, "STYLE": true,
+}
+
+// file is a loaded settings file.
+type file struct {
+	path     string
+	defaults map[string]string // By variable name, as DIAGASSERT_STYLE
+	err      error
+}
+
+// fileKey identifies a settings file by the DIAGASSERT_CONFIG setting and, when the file it
+// names depends on it, the working directory.
+type fileKey struct {
+	setting, wd string
+}
+
+var (
+	mu     sync.Mutex
+	loaded = map[fileKey]*file{}
+
+	// held counts the Holds in effect, heldFile is the settings file found for them and
+	// heldValues the settings read during them
+	held       int
+	heldFile   *file
+	heldValues = map[string]string{}
+)
+
+// Hold keeps the settings file the next setting read finds for the reads made until the
+// returned function is called. A failure reads dozens of settings, which then share one
+// settings file found once, and each setting is read once however often it is asked for;
+// outside of Holds every read looks up the environment and the working directory, which
+// os.Chdir may have changed. Holds may nest.
+func Hold() (release func()) {
+	mu.Lock()
+	held++
+	mu.Unlock()
+	return releaseHold
+}
+
+// releaseHold ends a Hold.
+func releaseHold() {
+	mu.Lock()
+	defer mu.Unlock()
+	if held--; held == 0 {
+		heldFile = nil
+		for key := range heldValues {
+			delete(heldValues, key)
+		}
+	}
+}
+
+// Getenv returns the value of the setting of the environment variable key, such as
+// DIAGASSERT_STYLE: the variable's when it is set, even to "", and otherwise the settings
+// file's. Other variables are returned as os.Getenv does.
+func Getenv(key string) string {
+	mu.Lock()
+	value, ok := heldValues[key]
+	mu.Unlock()
+	if ok {
+		return value
+	}
+
+	value, ok = os.LookupEnv(key)
+	if !ok && strings.HasPrefix(key, prefix) {
+		value = current().defaults[key]
+	}
+	mu.Lock()
+	if held > 0 {
+		heldValues[key] = value
+	}
+	mu.Unlock()
+	return value
+}
+
+// Int returns the setting of key, as Getenv does, as an integer, and false when it is unset
+// or not one. Numeric settings are read for every failure, and most are left unset: the
+// empty value is not parsed, which would allocate an error each time.
+func Int(key string) (int, bool) {
+	value := Getenv(key)
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil
+}
+
+// Err returns the problem with the settings file, which is then ignored as a whole, or nil.
+func Err() error {
+	return current().err
+}
+
+// Path returns the path of the settings file in use, or "" when there is none.
+func Path() string {
+	f := current()
+	if f.err != nil {
+		return ""
+	}
+	return f.path
+}
+
+// current returns the settings file of the working directory, loading it the first time.
+// During Holds it is found once.
+func current() *file {
+	mu.Lock()
+	if f := heldFile; f != nil {
+		mu.Unlock()
+		return f
+	}
+	mu.Unlock()
+	setting := os.Getenv(prefix + "CONFIG")
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Only the default file and relative paths depend on the working directory
+	key := fileKey{setting: setting}
+	if setting != "false" && !filepath.IsAbs(setting) {
+		key.wd = workingDir()
+	}
+	f, ok := loaded[key]
+	if !ok {
+		switch setting {
+		case "false":
+			f = &file{}
+		case "":
+			f = &file{}
+			if path, ok := find(key.wd); ok {
+				f = load(path)
+			}
+		default:
+			f = load(setting)
+		}
+		loaded[key] = f
+	}
+	if held > 0 {
+		heldFile = f
+	}
+	return f
+}
+
+// find looks for a settings file in the root of the module enclosing dir: the nearest
+// directory with a go.mod, or dir itself outside of modules.
+func find(dir string) (string, bool) {
+	root := dir
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			root = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// load reads and parses the settings file at path.
+func load(path string) *file {
+	f := &file{path: path}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		f.err = err
+		return f
+	}
+
+	parse := parseYAML
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		parse = parseTOML
+	}
+	values, err := parse(string(src))
+	if err != nil {
+		f.err = fmt.Errorf("%s:%v", filepath.Base(path), err)
+		return f
+	}
+	f.defaults = values
+	return f
+}
+
+// variable returns the environment variable of a setting named in a settings file, such as
+// DIAGASSERT_MACHINE_READABLE for machine_readable or machine-readable.
+func variable(name string) (string, error) {
+	key := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	key = strings.TrimPrefix(key, prefix)
+	if !settings[key] {
+		return "", fmt.Errorf("unknown setting %q", name)
+	}
+	return prefix + key, nil
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	src := `# Shared settings
+style: compact
+lang: "ja"
+max-width: 100  # columns
+redact: [password, "api key"]
+env_keys:
+  - BUILD_ID
+  - 'RUNNER'
+diagassert_hints: false
+`
+	got, err := parseYAML(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DIAGASSERT_STYLE":     "compact",
+		"DIAGASSERT_LANG":      "ja",
+		"DIAGASSERT_MAX_WIDTH": "100",
+		"DIAGASSERT_REDACT":    "password,api key",
+		"DIAGASSERT_ENV_KEYS":  "BUILD_ID,RUNNER",
+		"DIAGASSERT_HINTS":     "false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML() = %v, want %v", got, want)
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	src := `# Shared settings
+style = "compact"
+color = false
+max_repeats = 3
+redact = ["password", "token#1"] # a comment
+`
+	got, err := parseTOML(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DIAGASSERT_STYLE":       "compact",
+		"DIAGASSERT_COLOR":       "false",
+		"DIAGASSERT_MAX_REPEATS": "3",
+		"DIAGASSERT_REDACT":      "password,token#1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML() = %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) (map[string]string, error)
+		src   string
+		want  string
+	}{
+		{"unknown setting", parseYAML, "style: compact\ncolour: false\n", `2: unknown setting "colour"`},
+		{"seed", parseYAML, "seed: 42\n", `1: unknown setting "seed"`},
+		{"nested", parseYAML, "style:\n  name: compact\n", "2: nested settings are not supported"},
+		{"stray item", parseYAML, "- compact\n", "1: list item outside of a setting"},
+		{"no colon", parseYAML, "compact\n", "1: expected key: value"},
+		{"unterminated", parseYAML, `lang: "ja` + "\n", `1: malformed string "ja`},
+		{"table", parseTOML, "[diagassert]\nstyle = \"compact\"\n", "1: tables are not supported"},
+		{"no value", parseTOML, "style =\n", `1: missing value of "style"`},
+		{"open array", parseTOML, "redact = [a, b\n", "1: unterminated list [a, b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse(tt.src)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "pkg", "inner")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "go.mod"), "module example.com/m\n")
+	// Files below the module root are not settings files
+	writeFile(t, filepath.Join(root, "pkg", ".diagassert.yaml"), "style: compact\n")
+
+	if path, ok := find(sub); ok {
+		t.Errorf("find() = %s, want none", path)
+	}
+	writeFile(t, filepath.Join(root, ".diagassert.toml"), "style = \"compact\"\n")
+	if path, ok := find(sub); !ok || path != filepath.Join(root, ".diagassert.toml") {
+		t.Errorf("find() = %s, %v, want the .diagassert.toml of the module root", path, ok)
+	}
+}
+
+func TestGetenv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	writeFile(t, path, "style: compact\nlang: ja\nhints: false\n")
+	t.Setenv("DIAGASSERT_CONFIG", path)
+	t.Setenv("DIAGASSERT_LANG", "en")
+	t.Setenv("DIAGASSERT_HINTS", "")
+	os.Unsetenv("DIAGASSERT_STYLE")
+
+	if got := Getenv("DIAGASSERT_STYLE"); got != "compact" {
+		t.Errorf("Getenv(STYLE) = %q, want the file's compact", got)
+	}
+	if got := Getenv("DIAGASSERT_LANG"); got != "en" {
+		t.Errorf("Getenv(LANG) = %q, want the variable's en", got)
+	}
+	if got := Getenv("DIAGASSERT_HINTS"); got != "" {
+		t.Errorf("Getenv(HINTS) = %q, want the variable's empty value", got)
+	}
+	if Err() != nil || Path() != path {
+		t.Errorf("Err() = %v, Path() = %q, want no error and %s", Err(), Path(), path)
+	}
+
+	t.Setenv("DIAGASSERT_CONFIG", "false")
+	if got := Getenv("DIAGASSERT_STYLE"); got != "" {
+		t.Errorf("Getenv(STYLE) with DIAGASSERT_CONFIG=false = %q, want empty", got)
+	}
+}
+
+func TestGetenv_Chdir(t *testing.T) {
+	// os.Chdir leaves PWD as it was: the settings file must follow the directory itself
+	dirs := []string{t.TempDir(), t.TempDir()}
+	writeFile(t, filepath.Join(dirs[0], ".diagassert.yaml"), "lang: ja\n")
+	writeFile(t, filepath.Join(dirs[1], ".diagassert.yaml"), "lang: en\n")
+	t.Setenv("DIAGASSERT_CONFIG", "")
+	os.Unsetenv("DIAGASSERT_LANG")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, want := range []struct{ dir, lang string }{{dirs[0], "ja"}, {dirs[1], "en"}, {dirs[0], "ja"}} {
+		if err := os.Chdir(want.dir); err != nil {
+			t.Fatal(err)
+		}
+		if got := Getenv("DIAGASSERT_LANG"); got != want.lang {
+			t.Errorf("Getenv(LANG) in %s = %q, want %q", want.dir, got, want.lang)
+		}
+	}
+
+	// A Hold keeps the directory of its first read
+	release := Hold()
+	Getenv("DIAGASSERT_LANG")
+	if err := os.Chdir(dirs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := Getenv("DIAGASSERT_LANG"); got != "ja" {
+		t.Errorf("Getenv(LANG) held = %q, want ja", got)
+	}
+	release()
+	if got := Getenv("DIAGASSERT_LANG"); got != "en" {
+		t.Errorf("Getenv(LANG) after the Hold = %q, want en", got)
+	}
+}
+
+func TestErr(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".diagassert.yaml")
+	writeFile(t, path, "style: compact\nverbose: true\n")
+	t.Setenv("DIAGASSERT_CONFIG", path)
+	os.Unsetenv("DIAGASSERT_STYLE")
+
+	err := Err()
+	if err == nil || !strings.Contains(err.Error(), `.diagassert.yaml:2: unknown setting "verbose"`) {
+		t.Errorf("Err() = %v, want the unknown setting", err)
+	}
+	// A file with an error is ignored as a whole
+	if got := Getenv("DIAGASSERT_STYLE"); got != "" {
+		t.Errorf("Getenv(STYLE) = %q, want empty", got)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML parses the subset of YAML a settings file needs: top-level key: value pairs of
+// scalars and lists, inline as [a, b] or as blocks of "- item" lines, with # comments.
+// Lists are joined with commas, as the variables take them.
+func parseYAML(src string) (map[string]string, error) {
+	values := map[string]string{}
+	var list []string // Items of the block list under key, when one is open
+	key := ""
+	closeList := func() {
+		if key != "" {
+			values[key] = strings.Join(list, ",")
+		}
+		list, key = nil, ""
+	}
+
+	for i, line := range strings.Split(src, "\n") {
+		n := i + 1
+		text := strings.TrimRight(stripComment(line), " \t\r")
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		indented := text != strings.TrimLeft(text, " \t")
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if key == "" {
+				return nil, fmt.Errorf("%d: list item outside of a setting", n)
+			}
+			item, err := scalar(strings.TrimSpace(strings.TrimPrefix(trimmed, "-")))
+			if err != nil {
+				return nil, fmt.Errorf("%d: %v", n, err)
+			}
+			list = append(list, item)
+			continue
+		}
+		if indented {
+			return nil, fmt.Errorf("%d: nested settings are not supported", n)
+		}
+		closeList()
+
+		colon := strings.Index(trimmed, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("%d: expected key: value", n)
+		}
+		name, raw := strings.TrimSpace(trimmed[:colon]), strings.TrimSpace(trimmed[colon+1:])
+		variable, err := variable(name)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", n, err)
+		}
+		if raw == "" {
+			// A block list follows, or the setting is empty
+			key = variable
+			continue
+		}
+		value, err := listOrScalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", n, err)
+		}
+		values[variable] = value
+	}
+	closeList()
+	return values, nil
+}
+
+// parseTOML parses the subset of TOML a settings file needs: top-level key = value pairs of
+// strings, numbers, booleans and single-line arrays, with # comments. Arrays are joined with
+// commas, as the variables take them.
+func parseTOML(src string) (map[string]string, error) {
+	values := map[string]string{}
+	for i, line := range strings.Split(src, "\n") {
+		n := i + 1
+		trimmed := strings.TrimSpace(stripComment(line))
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			return nil, fmt.Errorf("%d: tables are not supported", n)
+		}
+
+		eq := strings.Index(trimmed, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("%d: expected key = value", n)
+		}
+		name, raw := strings.TrimSpace(trimmed[:eq]), strings.TrimSpace(trimmed[eq+1:])
+		name = strings.Trim(name, `"'`)
+		variable, err := variable(name)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", n, err)
+		}
+		if raw == "" {
+			return nil, fmt.Errorf("%d: missing value of %q", n, name)
+		}
+		value, err := listOrScalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", n, err)
+		}
+		values[variable] = value
+	}
+	return values, nil
+}
+
+// listOrScalar returns the value of an inline list such as [a, "b"], joined with commas, or
+// of a scalar.
+func listOrScalar(raw string) (string, error) {
+	if !strings.HasPrefix(raw, "[") {
+		return scalar(raw)
+	}
+	if !strings.HasSuffix(raw, "]") {
+		return "", fmt.Errorf("unterminated list %s", raw)
+	}
+	inner := strings.TrimSpace(raw[1 : len(raw)-1])
+	if inner == "" {
+		return "", nil
+	}
+	var items []string
+	for _, part := range splitList(inner) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			// A trailing comma
+			continue
+		}
+		item, err := scalar(part)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ","), nil
+}
+
+// scalar returns the value of a string, number or boolean, unquoting quoted strings.
+func scalar(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		value, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", fmt.Errorf("malformed string %s", raw)
+		}
+		return value, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", fmt.Errorf("malformed string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case strings.HasPrefix(raw, "{"), strings.HasPrefix(raw, "["):
+		return "", fmt.Errorf("nested values are not supported: %s", raw)
+	}
+	return raw, nil
+}
+
+// splitList splits the items of an inline list at the commas outside of quotes.
+func splitList(s string) []string {
+	var parts []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// stripComment removes a # comment from a line, leaving # inside quotes alone.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
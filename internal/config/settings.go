@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ColorMode is the choice of DIAGASSERT_COLOR.
+type ColorMode string
+
+// Color modes.
+const (
+	ColorAuto   ColorMode = "auto"   // Colors in terminals and CI services whose logs show them
+	ColorAlways ColorMode = "always" // Colors everywhere, over NO_COLOR
+	ColorNever  ColorMode = "never"  // No colors, over FORCE_COLOR
+)
+
+// Config holds the settings of how failures are shown. Each setting comes from the first of
+// these that sets it:
+//
+//  1. an option passed to the assertion or formatter, which its caller applies over the Config
+//  2. the environment variable
+//  3. the settings file
+//  4. the default of Default
+//
+// NO_COLOR and FORCE_COLOR are only read from the environment, as other tools read them.
+type Config struct {
+	MachineReadable bool      // DIAGASSERT_MACHINE_READABLE
+	PipeColors      bool      // DIAGASSERT_PIPE_COLORS
+	Color           ColorMode // DIAGASSERT_COLOR
+	NoColor         bool      // NO_COLOR is set to anything but ""
+	ForceColor      bool      // FORCE_COLOR is set to anything but ""
+}
+
+// Default returns the Config used when nothing sets a setting.
+func Default() Config {
+	return Config{MachineReadable: true, PipeColors: true, Color: ColorAuto}
+}
+
+// Load returns the Config of the environment and the settings file. Settings with invalid
+// values keep their defaults, and the returned *ValidationError lists them.
+func Load() (Config, error) {
+	return build(Getenv)
+}
+
+// New returns the Config of values, by variable name such as DIAGASSERT_COLOR, alone: neither
+// the environment nor the settings file is read, so tests can make the Config they need
+// without setting variables other tests would see.
+func New(values map[string]string) (Config, error) {
+	return build(func(key string) string { return values[key] })
+}
+
+// build makes the Config of the settings get returns.
+func build(get func(key string) string) (Config, error) {
+	c := Default()
+	var invalid ValidationError
+	boolean := func(key string, setting *bool) {
+		switch value := get(key); value {
+		case "":
+		case "true":
+			*setting = true
+		case "false":
+			*setting = false
+		default:
+			invalid = append(invalid, fmt.Sprintf(`%s=%q is not "true" or "false"`, key, value))
+		}
+	}
+	boolean(prefix+"MACHINE_READABLE", &c.MachineReadable)
+	boolean(prefix+"PIPE_COLORS", &c.PipeColors)
+	if mode := get(prefix + "COLOR"); mode != "" {
+		c.Color = ColorMode(mode)
+	}
+	c.NoColor = get("NO_COLOR") != ""
+	c.ForceColor = get("FORCE_COLOR") != ""
+
+	if err := c.Validate(); err != nil {
+		invalid = append(invalid, err.(ValidationError)...)
+		c.Color = ColorAuto
+	}
+	if len(invalid) > 0 {
+		return c, invalid
+	}
+	return c, nil
+}
+
+// Validate reports the settings of c that hold values they cannot take.
+func (c Config) Validate() error {
+	switch c.Color {
+	case ColorAuto, ColorAlways, ColorNever:
+		return nil
+	}
+	return ValidationError{fmt.Sprintf(`%sCOLOR=%q is not "auto", "always" or "never"`, prefix, string(c.Color))}
+}
+
+// ColorsForced reports whether FORCE_COLOR overrides NO_COLOR, in which case colors are
+// written as escape sequences directly, since the color package would leave them out.
+func (c Config) ColorsForced() bool {
+	return c.ForceColor && c.NoColor
+}
+
+// ValidationError lists the settings with invalid values, one problem each.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return strings.Join(e, "; ")
+}
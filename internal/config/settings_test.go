@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    Config
+		invalid ValidationError
+	}{
+		{
+			name: "defaults",
+			want: Config{MachineReadable: true, PipeColors: true, Color: ColorAuto},
+		},
+		{
+			name: "set",
+			values: map[string]string{
+				"DIAGASSERT_MACHINE_READABLE": "false",
+				"DIAGASSERT_PIPE_COLORS":      "false",
+				"DIAGASSERT_COLOR":            "never",
+				"NO_COLOR":                    "1",
+				"FORCE_COLOR":                 "1",
+			},
+			want: Config{Color: ColorNever, NoColor: true, ForceColor: true},
+		},
+		{
+			name: "invalid",
+			values: map[string]string{
+				"DIAGASSERT_MACHINE_READABLE": "no",
+				"DIAGASSERT_COLOR":            "sometimes",
+			},
+			want: Config{MachineReadable: true, PipeColors: true, Color: ColorAuto},
+			invalid: ValidationError{
+				`DIAGASSERT_MACHINE_READABLE="no" is not "true" or "false"`,
+				`DIAGASSERT_COLOR="sometimes" is not "auto", "always" or "never"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.values)
+			if got != tt.want {
+				t.Errorf("New() = %+v, want %+v", got, tt.want)
+			}
+			var invalid ValidationError
+			errors.As(err, &invalid)
+			if !reflect.DeepEqual(invalid, tt.invalid) {
+				t.Errorf("New() error = %v, want %v", err, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := t.TempDir() + "/.diagassert.toml"
+	writeFile(t, path, "pipe_colors = false\ncolor = \"always\"\n")
+	t.Setenv("DIAGASSERT_CONFIG", path)
+	t.Setenv("DIAGASSERT_COLOR", "never")
+	t.Setenv("DIAGASSERT_PIPE_COLORS", "")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "")
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "1")
+
+	got, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The variables take precedence over the file, even when empty
+	want := Config{MachineReadable: true, PipeColors: true, Color: ColorNever, ForceColor: true}
+	if got != want {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() = %v", err)
+	}
+	c := Default()
+	c.Color = "rainbow"
+	if err := c.Validate(); err == nil || err.Error() != `DIAGASSERT_COLOR="rainbow" is not "auto", "always" or "never"` {
+		t.Errorf("Validate() = %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package config
+
+import "os"
+
+// workingDir returns the working directory, "" when it cannot be found.
+func workingDir() string {
+	wd, _ := os.Getwd()
+	return wd
+}
@@ -0,0 +1,34 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package config
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lastDir is the working directory found last, as getcwd returned it and as os.Getwd does.
+var lastDir struct {
+	raw  [4096]byte
+	n    int
+	path string
+}
+
+// workingDir returns the working directory, "" when it cannot be found. It is looked up for
+// every setting read, so getcwd fills a fixed buffer and the path of the last one is kept
+// while it returns the same bytes. Called with mu held.
+func workingDir() string {
+	var buf [len(lastDir.raw)]byte
+	n, err := unix.Getcwd(buf[:])
+	if err != nil {
+		wd, _ := os.Getwd()
+		return wd
+	}
+	if n == lastDir.n && buf == lastDir.raw {
+		return lastDir.path
+	}
+	wd, _ := os.Getwd()
+	lastDir.raw, lastDir.n, lastDir.path = buf, n, wd
+	return wd
+}
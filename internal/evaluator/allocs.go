@@ -0,0 +1,30 @@
+package evaluator
+
+import "fmt"
+
+// EvaluateAllocs builds the result of measuring fn, averaged over runs calls, to make
+// measured allocations against a limit. fnText and limitText are the source texts of the
+// function and the limit, and the expression is the check as testing would write it:
+// "testing.AllocsPerRun(100, fn) <= 2". See NameText.
+func EvaluateAllocs(fnText, limitText string, runs int, measured, limit float64) *ExpressionResult {
+	callText := fmt.Sprintf("testing.AllocsPerRun(%d, %s)", runs, fnText)
+	expr := fmt.Sprintf("%s <= %s", callText, limitText)
+	tree := buildEvaluationTree(expr, nil)
+
+	variables := map[string]interface{}{callText: measured}
+	if tree.Type == "comparison" && tree.Left != nil && tree.Right != nil {
+		setLeafValue(tree.Left, measured)
+		if tree.Right.Type != "literal" {
+			setLeafValue(tree.Right, limit)
+			variables[limitText] = limit
+		}
+		tree.Result = evaluateBinaryExpr(tree.Left, tree.Right, "<=")
+	}
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     tree.Result,
+		Variables:  variables,
+		Tree:       tree,
+	}
+}
@@ -0,0 +1,34 @@
+package evaluator
+
+import "testing"
+
+func TestEvaluateAllocs(t *testing.T) {
+	tests := []struct {
+		name      string
+		limitText string
+		measured  float64
+		expr      string
+		result    bool
+		limit     interface{}
+	}{
+		{"over a named limit", "maxAllocs", 3, "testing.AllocsPerRun(100, parse) <= maxAllocs", false, 1.0},
+		{"over a literal limit", "1", 3, "testing.AllocsPerRun(100, parse) <= 1", false, 1},
+		{"within the limit", "maxAllocs", 1, "testing.AllocsPerRun(100, parse) <= maxAllocs", true, 1.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluateAllocs("parse", tt.limitText, 100, tt.measured, 1)
+
+			if result.Expression != tt.expr || result.Result != tt.result {
+				t.Fatalf("Expression = %q, Result = %v, want %q, %v", result.Expression, result.Result, tt.expr, tt.result)
+			}
+			if call := result.Tree.Left; call.Value != tt.measured || call.Children != nil {
+				t.Errorf("The call should be a leaf holding %v, got %+v", tt.measured, call)
+			}
+			if limit := result.Tree.Right; limit.Value != tt.limit {
+				t.Errorf("limit = %#v, want %#v", limit.Value, tt.limit)
+			}
+		})
+	}
+}
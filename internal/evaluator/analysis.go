@@ -0,0 +1,373 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/config"
+	diagparser "github.com/paveg/diagassert/internal/parser"
+)
+
+// staticInfo is what type checking the caller's package tells about an asserted expression.
+type staticInfo struct {
+	constants map[string]interface{} // Values of named constants, e.g. "http.StatusOK": 200
+	types     map[string]types.Type  // Static types of sub-expressions by their text
+	typeNames map[string]string      // The same types as printed, with package names, e.g. "time.Duration"
+	instances map[string]ast.Expr    // Instantiations of generics indexed by named types, e.g. "Max[User]"
+}
+
+// checkedPackage is a caller's package after type checking.
+type checkedPackage struct {
+	fset  *token.FileSet
+	files map[string]*ast.File // By absolute file name
+	info  *types.Info
+}
+
+var (
+	analysisMu  sync.Mutex
+	checked     = map[string]*checkedPackage{} // By directory, package name and the imports needed
+	imported    = map[string]*types.Package{}  // By import path
+	analyzed    = map[callerExpr]*staticInfo{} // analyzeCaller's results
+	gcImporter  = importer.Default()
+	srcImporter types.Importer
+)
+
+// callerExpr is an expression asserted at a call site.
+type callerExpr struct {
+	expr string
+	pc   uintptr
+}
+
+// analyzeCaller type checks the package of the assertion at callerFrame and returns the
+// static information about expr, or nil when it is unavailable. The checked package is
+// cached, so only the first failure in a package pays for it, and so is the result, which
+// must not be modified. Standard library packages are
+// loaded from compiled export data; other imports are type checked from source only when
+// the expression selects from them. Setting DIAGASSERT_CONSTANTS=false skips the pass.
+func analyzeCaller(expr string, callerFrame uintptr) *staticInfo {
+	if config.Getenv("DIAGASSERT_CONSTANTS") == "false" {
+		return nil
+	}
+
+	key := callerExpr{expr, callerFrame}
+	analysisMu.Lock()
+	static, ok := analyzed[key]
+	analysisMu.Unlock()
+	if !ok {
+		static = analyzeExpr(expr, callerFrame)
+		analysisMu.Lock()
+		analyzed[key] = static
+		analysisMu.Unlock()
+	}
+	return static
+}
+
+// analyzeExpr is analyzeCaller without the cache of results.
+func analyzeExpr(expr string, callerFrame uintptr) *staticInfo {
+	_, node, err := ParseExpr(expr)
+	if err != nil || !hasIdentifiers(node) {
+		return nil
+	}
+	fn := runtime.FuncForPC(callerFrame)
+	if fn == nil {
+		return nil
+	}
+	file, line := fn.FileLine(callerFrame)
+	if file == "" {
+		return nil
+	}
+	file = diagparser.ResolveSource(file)
+
+	pkg := checkCallerPackage(file, qualifiers(node))
+	if pkg == nil || pkg.files[file] == nil {
+		return nil
+	}
+
+	// Find the asserted expression among the arguments of the calls made at the line
+	target := types.ExprString(node)
+	var found ast.Expr
+	ast.Inspect(pkg.files[file], func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if found != nil || !ok || pkg.fset.Position(call.Pos()).Line != line {
+			return found == nil
+		}
+		for _, arg := range call.Args {
+			if types.ExprString(arg) == target {
+				found = arg
+				return false
+			}
+		}
+		return true
+	})
+	if found == nil {
+		return nil
+	}
+
+	static := &staticInfo{
+		constants: resolveConstants(found, pkg.info),
+		types:     map[string]types.Type{},
+		typeNames: map[string]string{},
+		instances: map[string]ast.Expr{},
+	}
+	qualifier := func(p *types.Package) string { return p.Name() }
+	ast.Inspect(found, func(n ast.Node) bool {
+		// Max[User] cannot be told from an index without knowing that User is a type
+		if index, ok := n.(*ast.IndexExpr); ok && !isInstantiation(index) && pkg.info.Types[index.Index].IsType() {
+			static.instances[types.ExprString(index)] = index
+		}
+		if e, ok := n.(ast.Expr); ok {
+			if tv, ok := pkg.info.Types[e]; ok && tv.Type != nil && !tv.IsType() && tv.Type != types.Typ[types.Invalid] {
+				text := types.ExprString(e)
+				static.types[text] = tv.Type
+				static.typeNames[text] = types.TypeString(tv.Type, qualifier)
+			}
+		}
+		return true
+	})
+	return static
+}
+
+// addConstants replaces the placeholders of named constants with their values: "http.StatusOK"
+// replaces the package and member placeholders, "MaxRetries" its own. The placeholders of
+// generics and their type arguments, such as Max and User in Max[User], are dropped.
+func (s *staticInfo) addConstants(variables map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	for _, instance := range s.instances {
+		for _, name := range extractVariableNames(instance) {
+			if isPlaceholder(variables[name]) {
+				delete(variables, name)
+			}
+		}
+	}
+	for text, value := range s.constants {
+		if pkg, member, ok := strings.Cut(text, "."); ok {
+			delete(variables, pkg)
+			delete(variables, member)
+		}
+		variables[text] = value
+	}
+}
+
+// apply records the static type of every node of the tree and corrects comparisons of
+// interfaces, which are equal only when their dynamic types are identical too.
+func (s *staticInfo) apply(tree *EvaluationTree) {
+	if s == nil {
+		return
+	}
+
+	corrected := false
+	var walk func(node *EvaluationTree)
+	walk = func(node *EvaluationTree) {
+		if node == nil {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+
+		if node.Type == "index" && s.instances[node.Text] != nil {
+			// The generic and its type arguments are not operands with values
+			node.Type, node.Left, node.Right, node.Value, node.Note = "generic_instance", nil, nil, nil, ""
+		}
+		if name, ok := s.typeNames[node.Text]; ok {
+			node.StaticType = name
+		}
+		if s.correctInterfaceComparison(node) {
+			corrected = true
+		}
+	}
+	walk(tree)
+
+	if corrected {
+		refreshResults(tree)
+	}
+}
+
+// correctInterfaceComparison fixes a == or != between an interface and a value of another
+// dynamic type, which compareValues would have decided by value, e.g. any(3) == any(3.0).
+func (s *staticInfo) correctInterfaceComparison(node *EvaluationTree) bool {
+	if node.NotEvaluated || node.Type != "comparison" || (node.Operator != "==" && node.Operator != "!=") {
+		return false
+	}
+	left, right := node.Left, node.Right
+	if !HasKnownResult(left) || !HasKnownResult(right) || left.Value == nil || right.Value == nil {
+		return false
+	}
+	// Operands the type checker recorded no type for, as text the tree reformats, are skipped
+	isInterface := func(t types.Type) bool { return t != nil && types.IsInterface(t) }
+	if !isInterface(s.types[left.Text]) && !isInterface(s.types[right.Text]) {
+		return false
+	}
+
+	// Go converts the other operand to the interface type: a concrete value keeps its type
+	// and an untyped constant takes its default type rather than that of the dynamic value
+	for _, operand := range []*EvaluationTree{left, right} {
+		if value, ok := untypedText(operand.Text); ok {
+			operand.Value = value
+		}
+	}
+	leftType, rightType := reflect.TypeOf(left.Value), reflect.TypeOf(right.Value)
+	if leftType == rightType {
+		return false
+	}
+
+	node.Result = node.Operator == "!="
+	node.Note = fmt.Sprintf("%s holds %s and %s holds %s — interfaces with different dynamic types are never equal",
+		left.Text, leftType, right.Text, rightType)
+	return true
+}
+
+// hasIdentifiers reports whether the expression has identifiers other than the predeclared
+// ones, so that plain literal comparisons skip type checking entirely.
+func hasIdentifiers(expr ast.Expr) bool {
+	return len(extractVariableNames(expr)) > 0
+}
+
+// qualifiers returns the sorted identifiers that selectors in the expression start from,
+// i.e. the package names it may refer to.
+func qualifiers(expr ast.Expr) []string {
+	seen := map[string]bool{}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				seen[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkCallerPackage type checks the package of file. Imports outside the standard library
+// are stubbed out unless file imports them under one of the needed names.
+func checkCallerPackage(file string, needed []string) *checkedPackage {
+	header, err := diagparser.ParseCached(file)
+	if err != nil {
+		return nil
+	}
+	dir, name := filepath.Dir(file), header.Name.Name
+
+	neededPaths := map[string]bool{}
+	for _, spec := range header.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		importName := packageName(importPath)
+		if spec.Name != nil {
+			importName = spec.Name.Name
+		}
+		for _, n := range needed {
+			if n == importName {
+				neededPaths[importPath] = true
+			}
+		}
+	}
+
+	key := dir + "\x00" + name + "\x00" + strings.Join(needed, ",")
+	analysisMu.Lock()
+	defer analysisMu.Unlock()
+	if pkg, ok := checked[key]; ok {
+		return pkg
+	}
+
+	pkg := &checkedPackage{
+		fset:  token.NewFileSet(),
+		files: map[string]*ast.File{},
+		info: &types.Info{
+			Types: map[ast.Expr]types.TypeAndValue{},
+			Uses:  map[*ast.Ident]types.Object{},
+		},
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []*ast.File
+	for _, entry := range entries {
+		filename := filepath.Join(dir, entry.Name())
+		if match, err := build.Default.MatchFile(dir, entry.Name()); (err != nil || !match) && filename != file {
+			continue
+		}
+		f, err := parser.ParseFile(pkg.fset, filename, nil, 0)
+		if err != nil || f.Name.Name != name {
+			continue
+		}
+		pkg.files[filename] = f
+		files = append(files, f)
+	}
+
+	config := types.Config{
+		Importer:    &lazyImporter{needed: neededPaths},
+		Error:       func(error) {}, // Unresolved imports must not stop the check
+		FakeImportC: true,
+	}
+	config.Check(name, pkg.fset, files, pkg.info)
+
+	checked[key] = pkg
+	return pkg
+}
+
+// lazyImporter loads standard library packages from export data. Other packages are only
+// type checked from source when their name is needed, since that may take seconds; the rest
+// are replaced by empty packages.
+type lazyImporter struct {
+	needed map[string]bool // Import paths to load even from source
+}
+
+func (l *lazyImporter) Import(importPath string) (*types.Package, error) {
+	if pkg, ok := imported[importPath]; ok {
+		return pkg, nil
+	}
+
+	pkg, err := gcImporter.Import(importPath)
+	if err != nil && l.needed[importPath] {
+		if srcImporter == nil {
+			srcImporter = importer.ForCompiler(token.NewFileSet(), "source", nil)
+		}
+		pkg, err = srcImporter.Import(importPath)
+	}
+	if err != nil {
+		stub := types.NewPackage(importPath, packageName(importPath))
+		stub.MarkComplete()
+		return stub, nil
+	}
+
+	imported[importPath] = pkg
+	return pkg, nil
+}
+
+// packageName guesses the name of the package at an import path: "yaml" for
+// "gopkg.in/yaml.v3" and "chi" for "github.com/go-chi/chi/v5".
+func packageName(importPath string) string {
+	base := path.Base(importPath)
+	if len(base) > 1 && base[0] == 'v' && strings.Trim(base[1:], "0123456789") == "" {
+		base = path.Base(path.Dir(importPath))
+	}
+	if i := strings.IndexByte(base, '.'); i > 0 {
+		base = base[:i]
+	}
+	return strings.TrimPrefix(base, "go-")
+}
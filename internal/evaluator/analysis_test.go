@@ -0,0 +1,153 @@
+package evaluator
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLimit = 10
+
+type testLevel uint8
+
+const testDebug testLevel = 4
+
+// callerPC returns the program counter of its call, as Assert does for the asserted expression.
+func callerPC(bool) uintptr {
+	pc, _, _, _ := runtime.Caller(1)
+	return pc
+}
+
+func TestAnalyzeCaller_Constants(t *testing.T) {
+	limit, code, level := 12, 404, testLevel(1)
+	pc := callerPC(limit < testLimit && code == http.StatusOK && level >= testDebug && time.Duration(limit) > time.Second)
+
+	static := analyzeCaller("limit < testLimit && code == http.StatusOK && level >= testDebug && time.Duration(limit) > time.Second", pc)
+	if static == nil {
+		t.Fatal("The test's own package should type check")
+	}
+	want := map[string]interface{}{
+		"testLimit":     10,
+		"http.StatusOK": 200,
+		"testDebug":     uint8(4),
+		"time.Second":   int64(time.Second),
+	}
+	if !reflect.DeepEqual(static.constants, want) {
+		t.Errorf("constants = %v, want %v", static.constants, want)
+	}
+
+	// A local variable shadows the package-level constant
+	testLimit := 3
+	pc = callerPC(limit < testLimit)
+	if static := analyzeCaller("limit < testLimit", pc); static == nil || len(static.constants) != 0 {
+		t.Errorf("Shadowed constants should not be resolved, got %+v", static)
+	}
+}
+
+func TestEvaluate_NamedConstants(t *testing.T) {
+	code := 404
+	pc := callerPC(code == http.StatusOK)
+
+	result := EvaluateWithValues("code == http.StatusOK", false, pc, map[string]interface{}{"code": code})
+	if right := result.Tree.Right; right.Value != 200 || right.Text != "http.StatusOK" {
+		t.Errorf("Expected http.StatusOK to evaluate to 200, got %v (%s)", right.Value, right.Text)
+	}
+	for _, placeholder := range []string{"http", "StatusOK"} {
+		if _, ok := result.Variables[placeholder]; ok {
+			t.Errorf("Variables should not list a placeholder for %s: %v", placeholder, result.Variables)
+		}
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	tests := map[string]string{
+		"net/http":                 "http",
+		"gopkg.in/yaml.v3":         "yaml",
+		"github.com/go-chi/chi/v5": "chi",
+		"example.com/app/config":   "config",
+	}
+	for importPath, want := range tests {
+		if got := packageName(importPath); got != want {
+			t.Errorf("packageName(%q) = %q, want %q", importPath, got, want)
+		}
+	}
+}
+
+func TestAnalyzeCaller_Disabled(t *testing.T) {
+	t.Setenv("DIAGASSERT_CONSTANTS", "false")
+
+	pc := callerPC(404 == http.StatusOK)
+	if static := analyzeCaller("404 == http.StatusOK", pc); static != nil {
+		t.Errorf("The caller should not be type checked when disabled, got %+v", static)
+	}
+}
+
+func TestAnalyzeCaller_StaticTypes(t *testing.T) {
+	var got, want interface{} = 3, 3.0
+	timeout := 2 * time.Second
+	pc := callerPC(got == want || timeout < time.Second)
+
+	result := EvaluateWithValues("got == want || timeout < time.Second", false, pc,
+		map[string]interface{}{"got": got, "want": want, "timeout": timeout})
+
+	comparison := result.Tree.Left
+	if comparison.Left.StaticType != "interface{}" || comparison.StaticType != "bool" {
+		t.Errorf("StaticType = %q and %q, want interface{} and bool", comparison.Left.StaticType, comparison.StaticType)
+	}
+	if right := result.Tree.Right.Right; right.StaticType != "time.Duration" || right.Value != int64(time.Second) {
+		t.Errorf("time.Second = %v (%s), want 1000000000 (time.Duration)", right.Value, right.StaticType)
+	}
+
+	// Interfaces holding different dynamic types are unequal even when the numbers are
+	if comparison.Result {
+		t.Error("got == want should be false: the interfaces hold int and float64")
+	}
+	if !strings.Contains(comparison.Note, "interfaces with different dynamic types are never equal") {
+		t.Errorf("Expected a note about the dynamic types, got %q", comparison.Note)
+	}
+	if result.Tree.Result {
+		t.Error("The || should be recomputed after the correction")
+	}
+}
+
+func TestAnalyzeCaller_InterfaceAndLiteral(t *testing.T) {
+	var v interface{} = int64(3)
+	pc := callerPC(v == 3)
+
+	result := EvaluateWithValues("v == 3", false, pc, map[string]interface{}{"v": v})
+	if result.Tree.Result {
+		t.Error("v == 3 should be false: the literal converts to int, not int64")
+	}
+}
+
+type testUser struct{ Name string }
+
+func testKeys[T any](m map[string]T) []string { return nil }
+
+func testApply(keys func(map[string]testUser) []string, m map[string]testUser) []string {
+	return keys(m)
+}
+
+func TestAnalyzeCaller_NamedTypeArguments(t *testing.T) {
+	users := map[string]testUser{"a": {}}
+	pc := callerPC(len(testApply(testKeys[testUser], users)) > 1)
+
+	result := EvaluateWithValues("len(testApply(testKeys[testUser], users)) > 1", false, pc, map[string]interface{}{"users": users})
+	instance := result.Tree.Left.Children[0].Children[0]
+	if instance.Type != "generic_instance" || instance.Text != "testKeys[testUser]" || instance.Left != nil {
+		t.Errorf("testKeys[testUser] should be an instance without operands, got %+v", instance)
+	}
+	for _, placeholder := range []string{"testKeys", "testUser"} {
+		if _, ok := result.Variables[placeholder]; ok {
+			t.Errorf("Variables should not list a placeholder for %s: %v", placeholder, result.Variables)
+		}
+	}
+	for _, hint := range Hints(result) {
+		if strings.Contains(hint, "testKeys") || strings.Contains(hint, "testUser") {
+			t.Errorf("The generic and its type argument should not be hinted at, got %v", hint)
+		}
+	}
+}
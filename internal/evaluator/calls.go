@@ -0,0 +1,122 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Results holds the results of a call that returns several, such as a (value, error) pair.
+// It is shown as a tuple: (0, strconv.Atoi: parsing "x": invalid syntax).
+type Results []interface{}
+
+// String returns the results in parentheses, separated by commas.
+func (r Results) String() string {
+	parts := make([]string, len(r))
+	for i, result := range r {
+		parts[i] = fmt.Sprint(result)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// callValue returns the value of a call from its results: its only result, or all of them as
+// Results. The message of a non-nil error returned after the other results is returned
+// apart, to be reported.
+func callValue(results []interface{}) (interface{}, string) {
+	switch len(results) {
+	case 0:
+		return nil, ""
+	case 1:
+		return results[0], ""
+	}
+	var errText string
+	if err, ok := results[len(results)-1].(error); ok && !isNilValue(err) {
+		errText = err.Error()
+	}
+	return Results(results), errText
+}
+
+// knownArgs reports whether the values of all of a call's arguments are known, so that the
+// call can be made with them.
+func knownArgs(args []*EvaluationTree) bool {
+	for _, arg := range args {
+		if !HasKnownResult(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// methodArgs converts the values of a call's arguments to the parameters of a method of
+// type t, as Go would. The arguments after the fixed parameters of a variadic method fill
+// its final slice, unless the call spreads a slice with "...".
+func methodArgs(t reflect.Type, args []*EvaluationTree, spread bool) ([]reflect.Value, bool) {
+	fixed := t.NumIn()
+	if t.IsVariadic() {
+		fixed--
+	}
+	switch {
+	case spread && (!t.IsVariadic() || len(args) != t.NumIn()):
+		return nil, false
+	case !t.IsVariadic() && len(args) != fixed, len(args) < fixed:
+		return nil, false
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var param reflect.Type
+		switch {
+		case i < fixed:
+			param = t.In(i)
+		case spread:
+			param = t.In(fixed)
+		default:
+			param = t.In(fixed).Elem()
+		}
+		value, ok := argValue(arg, param)
+		if !ok {
+			return nil, false
+		}
+		in[i] = value
+	}
+	return in, true
+}
+
+// argValue converts an argument's value to the type of its parameter. Values must be
+// assignable to it, as in Go, except for literals, which are untyped constants in Go and so
+// take the type of their parameter, such as float64 for 3 or Role for "admin".
+func argValue(arg *EvaluationTree, param reflect.Type) (reflect.Value, bool) {
+	if arg.Value == nil {
+		switch param.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(param), true
+		}
+		return reflect.Value{}, false
+	}
+
+	value := reflect.ValueOf(arg.Value)
+	if value.Type().AssignableTo(param) {
+		return value, true
+	}
+	if arg.Type == "literal" && constantKind(value.Kind()) != "" &&
+		constantKind(value.Kind()) == constantKind(param.Kind()) && value.CanConvert(param) {
+		return value.Convert(param), true
+	}
+	return reflect.Value{}, false
+}
+
+// constantKind groups the kinds a constant can be converted between: numbers, strings and
+// booleans.
+func constantKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	}
+	return ""
+}
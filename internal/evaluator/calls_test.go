@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type callRole string
+
+type callAccount struct {
+	Roles   []callRole
+	Balance float64
+}
+
+func (a callAccount) Has(role callRole) bool { return len(a.Roles) > 0 && a.Roles[0] == role }
+
+func (a callAccount) Covers(amount float64) bool { return a.Balance >= amount }
+
+func (a callAccount) HasAny(roles ...callRole) bool { return len(roles) > 0 && a.Has(roles[0]) }
+
+func (a callAccount) Parse(s string) (int, error) { return strconv.Atoi(s) }
+
+func (a callAccount) Check(err error) bool { return err == nil }
+
+func TestBuildEvaluationTree_MethodArguments(t *testing.T) {
+	account := callAccount{Roles: []callRole{"admin"}, Balance: 10}
+	variables := map[string]interface{}{
+		"a":      account,
+		"role":   callRole("admin"),
+		"roles":  []callRole{"admin"},
+		"amount": 12,
+	}
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{`a.Has("admin")`, true},
+		{"a.Has(role)", true},
+		{"a.Covers(3)", true},
+		{"a.Covers(2.5)", true},
+		{`a.HasAny("guest", "admin")`, false},
+		{"a.HasAny(roles...)", true},
+		{"a.HasAny()", false},
+		{"a.Check(nil)", true},
+		{"a.Covers(amount)", nil}, // An int variable is not assignable to float64
+		{"a.Has(unknown)", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if tree := buildEvaluationTree(tt.expr, variables); tree.Value != tt.expected {
+				t.Errorf("Value = %v, want %v", tree.Value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildEvaluationTree_MultipleResults(t *testing.T) {
+	variables := map[string]interface{}{"a": callAccount{}}
+
+	tree := buildEvaluationTree(`a.Parse("x")`, variables)
+	results, ok := tree.Value.(Results)
+	if !ok || len(results) != 2 || results[0] != 0 {
+		t.Fatalf("Value = %#v, want both results", tree.Value)
+	}
+	if want := `strconv.Atoi: parsing "x": invalid syntax`; tree.Err != want {
+		t.Errorf("Err = %q, want %q", tree.Err, want)
+	}
+	if got := results.String(); got != `(0, strconv.Atoi: parsing "x": invalid syntax)` {
+		t.Errorf("String() = %q", got)
+	}
+
+	tree = buildEvaluationTree(`a.Parse("42")`, variables)
+	if !reflect.DeepEqual(tree.Value, Results{42, nil}) || tree.Err != "" {
+		t.Errorf("Value = %v and Err = %q, want (42, <nil>) without an error", tree.Value, tree.Err)
+	}
+}
+
+func TestCallValue(t *testing.T) {
+	var typedNil *strconv.NumError
+	tests := []struct {
+		name    string
+		results []interface{}
+		value   interface{}
+		err     string
+	}{
+		{"no results", nil, nil, ""},
+		{"single error", []interface{}{errors.New("boom")}, errors.New("boom"), ""},
+		{"trailing error", []interface{}{0, errors.New("boom")}, Results{0, errors.New("boom")}, "boom"},
+		{"typed nil error", []interface{}{0, typedNil}, Results{0, typedNil}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := callValue(tt.results)
+			if !reflect.DeepEqual(value, tt.value) || err != tt.err {
+				t.Errorf("callValue() = %v, %q, want %v, %q", value, err, tt.value, tt.err)
+			}
+		})
+	}
+}
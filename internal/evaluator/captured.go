@@ -0,0 +1,109 @@
+package evaluator
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// EvaluateCaptured builds the result of an assertion rewritten by diagassert-gen, whose
+// instrumented code recorded the value of every sub-expression it evaluated, keyed by
+// source text. Recorded values replace what reflection would otherwise have to recover, so
+// method calls, map lookups and function results show what the test actually saw.
+// userValues are values passed with V or Values; the recorded values take precedence.
+func EvaluateCaptured(expr string, result bool, captured, userValues map[string]interface{}) *ExpressionResult {
+	// Identifiers and qualified constants are looked up while the tree is built, and so are
+	// method calls, which must not be made a second time. Everything else replaces the value
+	// computed for its node afterwards, so that its operands stay in the diagram.
+	variables := make(map[string]interface{})
+	lookups := make(map[string]interface{})
+	for name, value := range captured {
+		switch {
+		case token.IsIdentifier(name) || isQualifiedName(name, captured):
+			variables[name] = value
+			lookups[name] = value
+		case isMethodCall(name):
+			lookups[name] = value
+		}
+	}
+	variables = mergeVariables(userValues, variables)
+
+	tree := buildEvaluationTree(expr, mergeVariables(userValues, lookups))
+	applyCaptured(tree, captured)
+	refreshResults(tree)
+	tree.Result = result
+	sources := provenances(nil, userValues)
+	for text := range captured {
+		sources[text] = ProvenanceCaptured
+	}
+	markProvenance(tree, sources, variables)
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     result,
+		Variables:  variables,
+		Tree:       tree,
+	}
+}
+
+// applyCaptured overrides the value of every node whose text was recorded, bottom up, and
+// redoes what depended on the operands: differences of == and the short-circuiting of && and ||.
+func applyCaptured(node *EvaluationTree, captured map[string]interface{}) {
+	if node == nil {
+		return
+	}
+	applyCaptured(node.Left, captured)
+	applyCaptured(node.Right, captured)
+	for _, child := range node.Children {
+		applyCaptured(child, captured)
+	}
+
+	value, recorded := captured[node.Text]
+	switch node.Type {
+	case "comparison", "logical", "unary":
+		if b, ok := value.(bool); recorded && ok {
+			node.Result = b
+		}
+	case "literal":
+	default:
+		if recorded {
+			node.Value = value
+			node.Result = isTruthy(value)
+			node.Note = ""
+		}
+	}
+
+	switch {
+	case node.Type == "comparison" && node.Operator == "==" && !node.Result && node.Differences == nil:
+		node.Differences = equalityDifferences(node.Left, node.Right)
+	case node.Type == "logical" && HasKnownResult(node.Left) && !node.Right.NotEvaluated:
+		if (node.Operator == "&&" && !node.Left.Result) || (node.Operator == "||" && node.Left.Result) {
+			markNotEvaluated(node.Right)
+		}
+	}
+}
+
+// isQualifiedName reports whether name has the form pkg.Name, with a package that is not
+// itself a recorded variable, as for http.StatusOK.
+func isQualifiedName(name string, captured map[string]interface{}) bool {
+	pkg, member, ok := strings.Cut(name, ".")
+	if !ok || !token.IsIdentifier(pkg) || !token.IsIdentifier(member) {
+		return false
+	}
+	_, variable := captured[pkg]
+	return !variable
+}
+
+func isMethodCall(text string) bool {
+	expr, err := parser.ParseExpr(text)
+	if err != nil {
+		return false
+	}
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	_, ok = call.Fun.(*ast.SelectorExpr)
+	return ok
+}
@@ -0,0 +1,62 @@
+package evaluator
+
+import "testing"
+
+func TestEvaluateCaptured(t *testing.T) {
+	type user struct{ Name string }
+
+	t.Run("recorded calls are not made again", func(t *testing.T) {
+		captured := map[string]interface{}{
+			"c":              &counter{n: 3},
+			"c.Next()":       2,
+			"c.Next() == 10": false,
+		}
+		result := EvaluateCaptured("c.Next() == 10", false, captured, nil)
+
+		call := result.Tree.Left
+		if call.Value != 2 || captured["c"].(*counter).n != 3 {
+			t.Errorf("The call should show its recorded result without being made, got %v (n = %d)",
+				call.Value, captured["c"].(*counter).n)
+		}
+		if _, ok := result.Variables["c.Next()"]; ok {
+			t.Errorf("Only identifiers should be listed as variables, got %v", result.Variables)
+		}
+	})
+
+	t.Run("recorded values replace placeholders", func(t *testing.T) {
+		captured := map[string]interface{}{
+			"users":              []user{{Name: "bob"}},
+			"lookup(users)":      user{Name: "bob"},
+			"lookup(users).Name": "bob",
+			"http.StatusOK":      200,
+		}
+		result := EvaluateCaptured(`lookup(users).Name == "alice" || http.StatusOK == 201`, false, captured, nil)
+
+		tree := result.Tree
+		if name := tree.Left.Left; name.Value != "bob" || name.Left.Value != (user{Name: "bob"}) {
+			t.Errorf("The selector and its base should hold the recorded values, got %+v", name)
+		}
+		if tree.Left.Differences == nil {
+			t.Error("The differences of == should be computed from the recorded values")
+		}
+		if status := tree.Right.Left; status.Value != 200 || status.Left != nil {
+			t.Errorf("Qualified constants should be leaves, got %+v", status)
+		}
+	})
+
+	t.Run("short-circuited operands", func(t *testing.T) {
+		captured := map[string]interface{}{"ok()": false, "ok() && check()": false}
+		result := EvaluateCaptured("ok() && check()", false, captured, nil)
+
+		if !result.Tree.Right.NotEvaluated {
+			t.Error("The right operand was never evaluated and should be marked so")
+		}
+	})
+}
+
+type counter struct{ n int }
+
+func (c *counter) Next() int {
+	c.n++
+	return c.n
+}
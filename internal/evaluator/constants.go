@@ -0,0 +1,243 @@
+package evaluator
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math/big"
+	"reflect"
+)
+
+// resolveConstants finds the named constants used in a type-checked expression, such as
+// http.StatusOK or a package-level MaxRetries, and returns their values keyed by their text.
+// Local variables that shadow a constant are left alone.
+func resolveConstants(expr ast.Expr, info *types.Info) map[string]interface{} {
+	constants := map[string]interface{}{}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		var ident *ast.Ident
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if _, ok := n.X.(*ast.Ident); !ok {
+				return true
+			}
+			ident = n.Sel
+		case *ast.Ident:
+			ident = n
+		default:
+			return true
+		}
+
+		c, ok := info.Uses[ident].(*types.Const)
+		if !ok {
+			return true
+		}
+		if value, ok := constantValue(c); ok {
+			constants[types.ExprString(n.(ast.Expr))] = value
+		}
+		return false
+	})
+	return constants
+}
+
+// constantValue converts a constant to the Go value it has at run time: typed constants
+// keep the width and signedness of their basic type, untyped ones take their default type.
+func constantValue(c *types.Const) (interface{}, bool) {
+	basic, ok := c.Type().Underlying().(*types.Basic)
+	if !ok || basic.Info()&types.IsComplex != 0 {
+		return nil, false
+	}
+	val := c.Val()
+
+	switch val.Kind() {
+	case constant.Bool:
+		return constant.BoolVal(val), true
+	case constant.String:
+		return constant.StringVal(val), true
+	case constant.Float:
+		f, _ := constant.Float64Val(val)
+		if basic.Kind() == types.Float32 {
+			return float32(f), true
+		}
+		return f, true
+	case constant.Int:
+		return intConstantValue(val, basic.Kind())
+	}
+	return nil, false
+}
+
+// intConstantValue converts an integer constant to its basic kind. Untyped constants become
+// int, rune for rune literals, or a wider type when they do not fit.
+func intConstantValue(val constant.Value, kind types.BasicKind) (interface{}, bool) {
+	i, exact := constant.Int64Val(val)
+	switch kind {
+	case types.Int, types.UntypedInt:
+		if exact {
+			return int(i), true
+		}
+	case types.Int8:
+		return int8(i), exact
+	case types.Int16:
+		return int16(i), exact
+	case types.Int32, types.UntypedRune:
+		return int32(i), exact
+	case types.Int64:
+		return i, exact
+	case types.Uint8:
+		return uint8(i), exact
+	case types.Uint16:
+		return uint16(i), exact
+	case types.Uint32:
+		return uint32(i), exact
+	case types.Float32:
+		f, _ := constant.Float64Val(val)
+		return float32(f), true
+	case types.Float64, types.UntypedFloat:
+		f, _ := constant.Float64Val(val)
+		return f, true
+	}
+
+	u, exactUint := constant.Uint64Val(val)
+	switch kind {
+	case types.Uint:
+		return uint(u), exactUint
+	case types.Uint64:
+		return u, exactUint
+	case types.Uintptr:
+		return uintptr(u), exactUint
+	}
+	if exactUint {
+		return u, true
+	}
+	if b, ok := new(big.Int).SetString(val.ExactString(), 10); ok {
+		return bigInt{b}, true
+	}
+	return nil, false
+}
+
+// untypedConstant folds an expression made only of literals, such as 1<<63 or 'a'+1, exactly
+// as the compiler does, and returns its value with the kind of untyped constant it is. It
+// reports false for anything else, or for an operation that would not compile.
+func untypedConstant(expr ast.Expr) (constant.Value, types.BasicKind, bool) {
+	switch n := expr.(type) {
+	case *ast.BasicLit:
+		val := constant.MakeFromLiteral(n.Value, n.Kind, 0)
+		switch n.Kind {
+		case token.INT:
+			return val, types.UntypedInt, val.Kind() == constant.Int
+		case token.CHAR:
+			return val, types.UntypedRune, val.Kind() == constant.Int
+		case token.FLOAT:
+			return val, types.UntypedFloat, val.Kind() == constant.Float || val.Kind() == constant.Int
+		}
+	case *ast.ParenExpr:
+		return untypedConstant(n.X)
+	case *ast.UnaryExpr:
+		x, kind, ok := untypedConstant(n.X)
+		if !ok || (n.Op != token.ADD && n.Op != token.SUB && n.Op != token.XOR) || (n.Op == token.XOR && kind == types.UntypedFloat) {
+			return nil, 0, false
+		}
+		return constant.UnaryOp(n.Op, x, 0), kind, true
+	case *ast.BinaryExpr:
+		x, xKind, ok := untypedConstant(n.X)
+		if !ok {
+			return nil, 0, false
+		}
+		y, yKind, ok := untypedConstant(n.Y)
+		if !ok {
+			return nil, 0, false
+		}
+		return foldConstant(x, y, xKind, yKind, n.Op)
+	}
+	return nil, 0, false
+}
+
+// foldConstant applies an arithmetic or bitwise operator to two untyped constants. The result
+// has the later kind of rune, int and float, except for shifts, which keep the left one's.
+func foldConstant(x, y constant.Value, xKind, yKind types.BasicKind, op token.Token) (constant.Value, types.BasicKind, bool) {
+	if op == token.SHL || op == token.SHR {
+		x, count := constant.ToInt(x), constant.ToInt(y)
+		s, ok := constant.Uint64Val(count)
+		if x.Kind() != constant.Int || !ok || s > 1<<16 {
+			return nil, 0, false
+		}
+		if xKind == types.UntypedFloat {
+			xKind = types.UntypedInt
+		}
+		return constant.Shift(x, op, uint(s)), xKind, true
+	}
+
+	// Untyped int < rune < float, in the order the kinds are declared
+	kind := xKind
+	if yKind > kind {
+		kind = yKind
+	}
+	switch op {
+	case token.ADD, token.SUB, token.MUL:
+	case token.QUO:
+		if constant.Sign(y) == 0 {
+			return nil, 0, false
+		}
+		if kind != types.UntypedFloat {
+			op = token.QUO_ASSIGN // Integer division
+		}
+	case token.REM, token.AND, token.OR, token.XOR, token.AND_NOT:
+		if kind == types.UntypedFloat || (op == token.REM && constant.Sign(y) == 0) {
+			return nil, 0, false
+		}
+	default:
+		return nil, 0, false
+	}
+	return constant.BinaryOp(x, op, y), kind, true
+}
+
+// untypedValue returns the value an untyped constant has on its own: that of its default type,
+// or a wider one for integers that do not fit, as intConstantValue does.
+func untypedValue(val constant.Value, kind types.BasicKind) (interface{}, bool) {
+	if kind == types.UntypedFloat {
+		f, _ := constant.Float64Val(constant.ToFloat(val))
+		return f, true
+	}
+	return intConstantValue(val, kind)
+}
+
+// untypedText returns the value of an expression text made only of literals on its own, as
+// untypedValue does. It reports false for any other text.
+func untypedText(text string) (interface{}, bool) {
+	expr, err := parser.ParseExpr(text)
+	if err != nil {
+		return nil, false
+	}
+	if c, kind, ok := untypedConstant(expr); ok {
+		return untypedValue(c, kind)
+	}
+	return nil, false
+}
+
+// convertConstant converts an untyped constant to the numeric type t of the operand it is
+// combined with, as Go does. It reports false when t is not numeric or the constant does not
+// fit it.
+func convertConstant(val constant.Value, t reflect.Type) (interface{}, bool) {
+	v := reflect.New(t).Elem()
+	switch {
+	case isIntKind(t.Kind()):
+		i, exact := constant.Int64Val(constant.ToInt(val))
+		if !exact || v.OverflowInt(i) {
+			return nil, false
+		}
+		v.SetInt(i)
+	case isUintKind(t.Kind()):
+		u, exact := constant.Uint64Val(constant.ToInt(val))
+		if !exact || v.OverflowUint(u) {
+			return nil, false
+		}
+		v.SetUint(u)
+	case isFloatKind(t.Kind()):
+		f, _ := constant.Float64Val(constant.ToFloat(val))
+		v.SetFloat(f)
+	default:
+		return nil, false
+	}
+	return v.Interface(), true
+}
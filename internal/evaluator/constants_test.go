@@ -0,0 +1,44 @@
+package evaluator
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConstantValue(t *testing.T) {
+	tests := []struct {
+		typ  types.Type
+		val  constant.Value
+		want interface{}
+	}{
+		{types.Typ[types.UntypedInt], constant.MakeInt64(200), 200},
+		{types.Typ[types.UntypedInt], constant.MakeUint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{types.Typ[types.UntypedRune], constant.MakeInt64('a'), 'a'},
+		{types.Typ[types.UntypedFloat], constant.MakeFloat64(1.5), 1.5},
+		{types.Typ[types.UntypedFloat], constant.MakeInt64(3), 3.0},
+		{types.Typ[types.UntypedString], constant.MakeString("v1"), "v1"},
+		{types.Typ[types.UntypedBool], constant.MakeBool(true), true},
+		{types.Typ[types.Int64], constant.MakeInt64(int64(time.Second)), int64(time.Second)},
+		{types.Typ[types.Uint8], constant.MakeInt64(255), uint8(255)},
+		{types.Typ[types.Uint64], constant.MakeUint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{types.Typ[types.Float32], constant.MakeFloat64(0.5), float32(0.5)},
+	}
+
+	for _, tt := range tests {
+		c := types.NewConst(token.NoPos, nil, "c", tt.typ, tt.val)
+		got, ok := constantValue(c)
+		if !ok || got != tt.want {
+			t.Errorf("constantValue(%s %s) = %v (%T), want %v (%T)", tt.typ, tt.val, got, got, tt.want, tt.want)
+		}
+	}
+
+	huge := constant.Shift(constant.MakeInt64(1), token.SHL, 70)
+	got, ok := constantValue(types.NewConst(token.NoPos, nil, "c", types.Typ[types.UntypedInt], huge))
+	if b, isBig := got.(bigInt); !ok || !isBig || b.String() != huge.ExactString() {
+		t.Errorf("Expected a big integer for 1 << 70, got %v (%T)", got, got)
+	}
+}
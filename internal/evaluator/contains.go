@@ -0,0 +1,123 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFoundContextRunes is how much of the text around a match a found note quotes on each side.
+const maxFoundContextRunes = 16
+
+// evaluateContainsCall evaluates the search functions of the strings, bytes and slices
+// packages: Contains, ContainsAny, ContainsRune, HasPrefix and HasSuffix. A search that
+// finds what it looked for comes with a note saying where, so that a failed negative
+// assertion such as !strings.Contains(log, "panic") shows what was found rather than only
+// true. ok is false for any other call.
+func evaluateContainsCall(fun *ast.SelectorExpr, baseTree *EvaluationTree, args []*EvaluationTree) (interface{}, string, bool) {
+	ident, isIdent := fun.X.(*ast.Ident)
+	if !isIdent || (baseTree.Value != nil && !isPlaceholder(baseTree.Value)) || len(args) != 2 || !knownArgs(args) {
+		return nil, "", false
+	}
+
+	switch ident.Name {
+	case "strings", "bytes":
+		s, ok := textOf(args[0].Value)
+		if !ok {
+			return nil, "", false
+		}
+		return searchText(fun.Sel.Name, s, args[0].Text, args[1].Value)
+	case "slices":
+		if fun.Sel.Name != "Contains" {
+			return nil, "", false
+		}
+		list := reflect.ValueOf(args[0].Value)
+		if list.Kind() != reflect.Slice {
+			return nil, "", false
+		}
+		for i := 0; i < list.Len(); i++ {
+			if elem := list.Index(i); elem.Type().Comparable() && elem.Interface() == args[1].Value {
+				return true, fmt.Sprintf("%s found in %s at index %d", args[1].Text, args[0].Text, i), true
+			}
+		}
+		return false, "", true
+	}
+	return nil, "", false
+}
+
+// searchText makes the strings or bytes search named name for target in s, named text.
+func searchText(name, s, text string, target interface{}) (interface{}, string, bool) {
+	var index, size int
+	var found string
+	switch name {
+	case "Contains", "HasPrefix", "HasSuffix":
+		sub, ok := textOf(target)
+		if !ok {
+			return nil, "", false
+		}
+		switch name {
+		case "HasPrefix":
+			return strings.HasPrefix(s, sub), "", true
+		case "HasSuffix":
+			return strings.HasSuffix(s, sub), "", true
+		}
+		index, size, found = strings.Index(s, sub), len(sub), fmt.Sprintf("%q", sub)
+	case "ContainsAny":
+		chars, ok := textOf(target)
+		if !ok {
+			return nil, "", false
+		}
+		index = strings.IndexAny(s, chars)
+		if index >= 0 {
+			r, n := utf8.DecodeRuneInString(s[index:])
+			size, found = n, fmt.Sprintf("%q (of %q)", r, chars)
+		}
+	case "ContainsRune":
+		v := reflect.ValueOf(target)
+		if v.Kind() != reflect.Int32 && v.Kind() != reflect.Int {
+			return nil, "", false
+		}
+		r := rune(v.Int())
+		index, found = strings.IndexRune(s, r), fmt.Sprintf("%q", r)
+		if index >= 0 {
+			// utf8.RuneError also matches a single invalid byte, shorter than the rune
+			_, size = utf8.DecodeRuneInString(s[index:])
+		}
+	default:
+		return nil, "", false
+	}
+
+	if index < 0 {
+		return false, "", true
+	}
+	return true, fmt.Sprintf("%s found in %s at index %d: %s", found, text, index, foundContext(s, index, size)), true
+}
+
+// textOf returns the text of a string or byte slice, of named types too.
+func textOf(value interface{}) (string, bool) {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String(), true
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return string(v.Bytes()), true
+	}
+	return "", false
+}
+
+// foundContext quotes the match of size bytes at index in s with the text around it,
+// shortened with "…" on the sides it is cut: "…oroutine panic: nil map…".
+func foundContext(s string, index, size int) string {
+	before := []rune(s[:index])
+	after := []rune(s[index+size:])
+	prefix, suffix := "", ""
+	if len(before) > maxFoundContextRunes {
+		before, prefix = before[len(before)-maxFoundContextRunes:], "…"
+	}
+	if len(after) > maxFoundContextRunes {
+		after, suffix = after[:maxFoundContextRunes], "…"
+	}
+	return prefix + fmt.Sprintf("%q", string(before)+s[index:index+size]+string(after)) + suffix
+}
@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestBuildEvaluationTree_Contains(t *testing.T) {
+	log := "worker 3 started\ngoroutine 7 [running]: panic: assignment to entry in nil map"
+	tests := []struct {
+		name         string
+		expr         string
+		variables    map[string]interface{}
+		expectResult bool
+		expectNote   string
+	}{
+		{
+			name:         "substring found",
+			expr:         `strings.Contains(log, "panic")`,
+			variables:    map[string]interface{}{"log": log},
+			expectResult: true,
+			expectNote:   `"panic" found in log at index 40: …"ne 7 [running]: panic: assignment to "…`,
+		},
+		{
+			name:         "substring missing",
+			expr:         `strings.Contains(log, "fatal")`,
+			variables:    map[string]interface{}{"log": log},
+			expectResult: false,
+		},
+		{
+			name:         "any of the characters, in bytes",
+			expr:         `bytes.ContainsAny(body, "<>")`,
+			variables:    map[string]interface{}{"body": []byte("a<b")},
+			expectResult: true,
+			expectNote:   `'<' (of "<>") found in body at index 1: "a<b"`,
+		},
+		{
+			name:         "rune",
+			expr:         `strings.ContainsRune(name, 'é')`,
+			variables:    map[string]interface{}{"name": "café"},
+			expectResult: true,
+			expectNote:   `'é' found in name at index 3: "café"`,
+		},
+		{
+			name:         "replacement rune matching an invalid byte",
+			expr:         `strings.ContainsRune(data, bad)`,
+			variables:    map[string]interface{}{"data": "ab\xffcd", "bad": utf8.RuneError},
+			expectResult: true,
+			expectNote:   `'�' found in data at index 2: "ab\xffcd"`,
+		},
+		{
+			name:         "prefix",
+			expr:         `strings.HasPrefix(path, "/admin")`,
+			variables:    map[string]interface{}{"path": "/admin/users"},
+			expectResult: true,
+		},
+		{
+			name:         "slice element",
+			expr:         `slices.Contains(statuses, status)`,
+			variables:    map[string]interface{}{"statuses": []string{"paid", "refunded"}, "status": "refunded"},
+			expectResult: true,
+			expectNote:   "status found in statuses at index 1",
+		},
+		{
+			name:         "unknown arguments",
+			expr:         `strings.Contains(log, word)`,
+			variables:    map[string]interface{}{"log": log, "word": "<word>"},
+			expectResult: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, tt.variables)
+			if tree.Result != tt.expectResult || tree.Note != tt.expectNote {
+				t.Errorf("Result = %v, Note = %q, want %v, %q", tree.Result, tree.Note, tt.expectResult, tt.expectNote)
+			}
+		})
+	}
+}
+
+func TestFoundContext(t *testing.T) {
+	s := strings.Repeat("a", 20) + "XY" + strings.Repeat("b", 20)
+	if got, want := foundContext(s, 20, 2), `…"`+strings.Repeat("a", 16)+"XY"+strings.Repeat("b", 16)+`"…`; got != want {
+		t.Errorf("foundContext = %s, want %s", got, want)
+	}
+}
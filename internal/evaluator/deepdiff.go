@@ -0,0 +1,320 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/textwidth"
+)
+
+// maxDifferences caps how many differing paths are recorded for one comparison.
+const maxDifferences = 5
+
+// hiddenDifference is recorded when only fields tagged diag:"-" differ, which are compared
+// like any other but never shown.
+const hiddenDifference = `(hidden): fields tagged diag:"-" differ`
+
+// deepDiffer walks two values in parallel and records the paths where they diverge,
+// e.g. ".Items[3].Price: 10 != 12".
+type deepDiffer struct {
+	diffs     []string
+	limit     int
+	truncated bool
+	visited   map[[2]uintptr]bool
+	rules     EqualRules
+
+	hiding bool // Walking a field tagged diag:"-"
+	hidden bool // A field tagged diag:"-" differs
+}
+
+// deepDiff returns up to limit differences between left and right. A trailing
+// "..." entry means more differences were found than recorded.
+func deepDiff(left, right interface{}, limit int) []string {
+	d := &deepDiffer{limit: limit, visited: make(map[[2]uintptr]bool)}
+	d.diff("", reflect.ValueOf(left), reflect.ValueOf(right), FieldTag{})
+	return d.differences()
+}
+
+// differences returns the differences recorded, ending in "..." when more were found, or
+// noting hidden fields when they are all that differs.
+func (d *deepDiffer) differences() []string {
+	if d.hidden && len(d.diffs) == 0 {
+		d.diffs = append(d.diffs, hiddenDifference)
+	}
+	if d.truncated {
+		d.diffs = append(d.diffs, "...")
+	}
+	return d.diffs
+}
+
+// isComposite reports whether a value has inner structure worth reporting paths for.
+func isComposite(value interface{}) bool {
+	val := reflect.ValueOf(value)
+	if val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+func (d *deepDiffer) add(path, format string, args ...interface{}) {
+	if d.hiding {
+		d.hidden = true
+		return
+	}
+	if len(d.diffs) >= d.limit {
+		d.truncated = true
+		return
+	}
+	if path == "" {
+		path = "(root)"
+	}
+	d.diffs = append(d.diffs, path+": "+fmt.Sprintf(format, args...))
+}
+
+// diff compares left and right at path. tag holds the display options of the struct field
+// the values belong to, which apply to the elements of slices and maps in it too.
+func (d *deepDiffer) diff(path string, left, right reflect.Value, tag FieldTag) {
+	if d.truncated {
+		return
+	}
+
+	if !left.IsValid() || !right.IsValid() {
+		if left.IsValid() != right.IsValid() {
+			d.add(path, "%s != %s", formatDiffValue(left), formatDiffValue(right))
+		}
+		return
+	}
+
+	if left.Type() != right.Type() {
+		d.add(path, "type %s != %s", left.Type(), right.Type())
+		return
+	}
+
+	switch left.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if left.IsNil() || right.IsNil() {
+			if left.IsNil() != right.IsNil() {
+				d.add(path, "%s != %s", formatDiffValue(left), formatDiffValue(right))
+			}
+			return
+		}
+		if left.Kind() == reflect.Ptr {
+			// Guard against cycles such as linked lists pointing back to themselves
+			key := [2]uintptr{left.Pointer(), right.Pointer()}
+			if d.visited[key] {
+				return
+			}
+			d.visited[key] = true
+		}
+		d.diff(path, left.Elem(), right.Elem(), tag)
+
+	case reflect.Struct:
+		for i := 0; i < left.NumField(); i++ {
+			field := left.Type().Field(i)
+			fieldTag := ParseFieldTag(field)
+			if d.rules.ignores(path + "." + field.Name) {
+				continue
+			}
+			if fieldTag.Omit {
+				// Hidden fields count for equality, but their paths and values are not shown
+				if !d.hidden {
+					hiding := d.hiding
+					d.hiding = true
+					d.diff(path+"."+field.Name, left.Field(i), right.Field(i), fieldTag)
+					d.hiding = hiding
+				}
+				continue
+			}
+			d.diff(path+"."+field.Name, left.Field(i), right.Field(i), fieldTag)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if left.Kind() == reflect.Slice && left.IsNil() != right.IsNil() {
+			d.add(path, "%s != %s", formatDiffValue(left), formatDiffValue(right))
+			return
+		}
+		if left.Len() != right.Len() {
+			d.add(path, "len %d != %d", left.Len(), right.Len())
+		}
+		if less := d.rules.Sorts[left.Type().Elem()]; less != nil && left.Kind() == reflect.Slice && left.CanInterface() {
+			left, right = sortedSlice(left, less), sortedSlice(right, less)
+		}
+		n := left.Len()
+		if right.Len() < n {
+			n = right.Len()
+		}
+		for i := 0; i < n; i++ {
+			d.diff(fmt.Sprintf("%s[%d]", path, i), left.Index(i), right.Index(i), tag)
+		}
+
+	case reflect.Map:
+		if left.IsNil() != right.IsNil() {
+			d.add(path, "%s != %s", formatDiffValue(left), formatDiffValue(right))
+			return
+		}
+		for _, key := range sortedMapKeys(left, right) {
+			keyPath := fmt.Sprintf("%s[%s]", path, formatDiffValue(key))
+			l, r := left.MapIndex(key), right.MapIndex(key)
+			switch {
+			case !l.IsValid():
+				d.add(keyPath, "<missing> != %s", formatDiffValue(r))
+			case !r.IsValid():
+				d.add(keyPath, "%s != <missing>", formatDiffValue(l))
+			default:
+				d.diff(keyPath, l, r, tag)
+			}
+		}
+
+	default:
+		if !leafEqual(left, right) {
+			d.add(path, "%s != %s", formatTaggedDiffValue(left, tag), formatTaggedDiffValue(right, tag))
+		}
+	}
+}
+
+// sortedMapKeys returns the union of both maps' keys in a stable order.
+func sortedMapKeys(left, right reflect.Value) []reflect.Value {
+	seen := make(map[string]bool)
+	var keys []reflect.Value
+	for _, m := range []reflect.Value{left, right} {
+		for _, key := range m.MapKeys() {
+			text := formatDiffValue(key)
+			if !seen[text] {
+				seen[text] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return formatDiffValue(keys[i]) < formatDiffValue(keys[j])
+	})
+	return keys
+}
+
+// leafEqual compares scalar values, including unexported struct fields that cannot be
+// turned back into interfaces.
+func leafEqual(left, right reflect.Value) bool {
+	if left.CanInterface() && right.CanInterface() {
+		return reflect.DeepEqual(left.Interface(), right.Interface())
+	}
+	return formatDiffValue(left) == formatDiffValue(right)
+}
+
+// formatDiffValue renders a value for a difference line. Strings are quoted so that
+// whitespace differences stay visible.
+func formatDiffValue(val reflect.Value) string {
+	if !val.IsValid() {
+		return "nil"
+	}
+
+	switch val.Kind() {
+	case reflect.String:
+		return strconv.Quote(val.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(val.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(val.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(val.Bool())
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if val.IsNil() {
+			return "nil"
+		}
+	}
+
+	if val.CanInterface() {
+		return fmt.Sprintf("%v", val.Interface())
+	}
+	return val.String()
+}
+
+// formatTaggedDiffValue renders a value for a difference line as its field's tag asks.
+func formatTaggedDiffValue(val reflect.Value, tag FieldTag) string {
+	if text, ok := FormatTagged(tag, val); ok {
+		return text
+	}
+	return formatDiffValue(val)
+}
+
+// stringExcerptRadius is how many runes of context are shown on each side of the
+// first difference between two strings.
+const stringExcerptRadius = 20
+
+// stringDiff explains where two unequal strings diverge: the rune index of the first
+// difference, the common prefix and suffix lengths, both lengths and a caret-marked
+// excerpt around the difference, all counted in runes; the excerpts and the caret are
+// aligned in columns.
+func stringDiff(leftText, rightText, left, right string) []string {
+	l, r := []rune(left), []rune(right)
+
+	prefix := 0
+	for prefix < len(l) && prefix < len(r) && l[prefix] == r[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(l)-prefix && suffix < len(r)-prefix && l[len(l)-1-suffix] == r[len(r)-1-suffix] {
+		suffix++
+	}
+
+	// Labels are padded in columns, as wide runes such as those of 名前 take two
+	labelWidth := textwidth.String(leftText)
+	if n := textwidth.String(rightText); n > labelWidth {
+		labelWidth = n
+	}
+	labelWidth++ // room for the colon
+	label := func(text string) string {
+		return text + ":" + strings.Repeat(" ", labelWidth-textwidth.String(text)-1)
+	}
+
+	leftLine, caret := stringExcerpt(l, prefix)
+	rightLine, _ := stringExcerpt(r, prefix)
+
+	return []string{
+		fmt.Sprintf("first difference at rune %d (common prefix %d, common suffix %d)", prefix, prefix, suffix),
+		fmt.Sprintf("len %d != %d", len(l), len(r)),
+		label(leftText) + " " + leftLine,
+		label(rightText) + " " + rightLine,
+		strings.Repeat(" ", labelWidth+1+caret) + "^",
+	}
+}
+
+// stringExcerpt quotes the runes around index, eliding the rest with "...", and returns
+// the column at which index appears in the excerpt.
+func stringExcerpt(s []rune, index int) (string, int) {
+	start := index - stringExcerptRadius
+	if start < 0 {
+		start = 0
+	}
+	end := index + stringExcerptRadius
+	if end > len(s) {
+		end = len(s)
+	}
+
+	var head string
+	if start > 0 {
+		head = "..."
+	}
+
+	// Escapes such as \n widen the quoted text, so the caret column is measured on the quoted
+	// prefix, in columns
+	before := strconv.Quote(string(s[start:index]))
+	before = before[:len(before)-1]
+	caret := len(head) + textwidth.String(before)
+
+	excerpt := head + strconv.Quote(string(s[start:end]))
+	if end < len(s) {
+		excerpt += "..."
+	}
+	return excerpt, caret
+}
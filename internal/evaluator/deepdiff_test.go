@@ -0,0 +1,340 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type diffItem struct {
+	Name  string
+	Price int
+}
+
+type diffOrder struct {
+	ID    int
+	Items []diffItem
+	Tags  map[string]int
+	note  string
+}
+
+type diffNode struct {
+	Value int
+	Next  *diffNode
+}
+
+func TestDeepDiff(t *testing.T) {
+	items := []diffItem{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 10}}
+	changed := []diffItem{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 12}}
+
+	cyclic := &diffNode{Value: 1}
+	cyclic.Next = cyclic
+	otherCyclic := &diffNode{Value: 2}
+	otherCyclic.Next = otherCyclic
+
+	tests := []struct {
+		name     string
+		left     interface{}
+		right    interface{}
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "nested field in slice",
+			left:     diffOrder{ID: 1, Items: items},
+			right:    diffOrder{ID: 1, Items: changed},
+			limit:    5,
+			expected: []string{".Items[3].Price: 10 != 12"},
+		},
+		{
+			name:     "slice length",
+			left:     []int{1, 2},
+			right:    []int{1, 2, 3},
+			limit:    5,
+			expected: []string{"(root): len 2 != 3"},
+		},
+		{
+			name:     "map keys",
+			left:     map[string]int{"a": 1, "b": 2},
+			right:    map[string]int{"a": 1, "c": 3},
+			limit:    5,
+			expected: []string{`["b"]: 2 != <missing>`, `["c"]: <missing> != 3`},
+		},
+		{
+			name:     "unexported string field",
+			left:     diffOrder{note: "x"},
+			right:    diffOrder{note: "x "},
+			limit:    5,
+			expected: []string{`.note: "x" != "x "`},
+		},
+		{
+			name:     "pointers compare pointees",
+			left:     &diffItem{Name: "a"},
+			right:    &diffItem{Name: "b"},
+			limit:    5,
+			expected: []string{`.Name: "a" != "b"`},
+		},
+		{
+			name:     "nil pointer",
+			left:     &diffNode{Value: 1},
+			right:    &diffNode{Value: 1, Next: &diffNode{}},
+			limit:    5,
+			expected: []string{".Next: nil != &{0 <nil>}"},
+		},
+		{
+			name:     "cycles terminate",
+			left:     cyclic,
+			right:    otherCyclic,
+			limit:    5,
+			expected: []string{".Value: 1 != 2"},
+		},
+		{
+			name:     "limit truncates",
+			left:     []int{1, 2, 3},
+			right:    []int{4, 5, 6},
+			limit:    2,
+			expected: []string{"[0]: 1 != 4", "[1]: 2 != 5", "..."},
+		},
+		{
+			name:     "equal values",
+			left:     diffOrder{ID: 1, Items: items},
+			right:    diffOrder{ID: 1, Items: items},
+			limit:    5,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := deepDiff(tt.left, tt.right, tt.limit)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("deepDiff() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildEvaluationTree_Differences(t *testing.T) {
+	variables := map[string]interface{}{
+		"got":  diffItem{Name: "a", Price: 10},
+		"want": diffItem{Name: "a", Price: 12},
+	}
+
+	tree := buildEvaluationTree("got == want", variables)
+	expected := []string{".Price: 10 != 12"}
+	if !reflect.DeepEqual(tree.Differences, expected) {
+		t.Errorf("Differences = %q, expected %q", tree.Differences, expected)
+	}
+
+	tree = buildEvaluationTree("got != want", variables)
+	if len(tree.Differences) != 0 {
+		t.Errorf("Expected no differences for a passing !=, got %q", tree.Differences)
+	}
+}
+
+func TestStringDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		expected []string
+	}{
+		{
+			name:  "changed rune",
+			left:  "hello world",
+			right: "hello wurld",
+			expected: []string{
+				"first difference at rune 7 (common prefix 7, common suffix 3)",
+				"len 11 != 11",
+				`got:  "hello world"`,
+				`want: "hello wurld"`,
+				"              ^",
+			},
+		},
+		{
+			name:  "prefix of the other",
+			left:  "abc",
+			right: "abcd",
+			expected: []string{
+				"first difference at rune 3 (common prefix 3, common suffix 0)",
+				"len 3 != 4",
+				`got:  "abc"`,
+				`want: "abcd"`,
+				"          ^",
+			},
+		},
+		{
+			name:  "escapes shift the caret",
+			left:  "a\nb",
+			right: "a\nc",
+			expected: []string{
+				"first difference at rune 2 (common prefix 2, common suffix 0)",
+				"len 3 != 3",
+				`got:  "a\nb"`,
+				`want: "a\nc"`,
+				"          ^",
+			},
+		},
+		{
+			name:  "multibyte runes count once",
+			left:  "café au lait",
+			right: "café au lard",
+			expected: []string{
+				"first difference at rune 10 (common prefix 10, common suffix 0)",
+				"len 12 != 12",
+				`got:  "café au lait"`,
+				`want: "café au lard"`,
+				strings.Repeat(" ", 17) + "^",
+			},
+		},
+		{
+			name:  "long strings are trimmed",
+			left:  strings.Repeat("x", 30) + "A" + strings.Repeat("y", 30),
+			right: strings.Repeat("x", 30) + "B" + strings.Repeat("y", 30),
+			expected: []string{
+				"first difference at rune 30 (common prefix 30, common suffix 30)",
+				"len 61 != 61",
+				`got:  ..."` + strings.Repeat("x", 20) + "A" + strings.Repeat("y", 19) + `"...`,
+				`want: ..."` + strings.Repeat("x", 20) + "B" + strings.Repeat("y", 19) + `"...`,
+				strings.Repeat(" ", 30) + "^",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stringDiff("got", "want", tt.left, tt.right)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("stringDiff() =\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestBuildEvaluationTree_StringDifferenceColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		value    string
+		expected []string
+	}{
+		{
+			name:  "escaped literal",
+			expr:  `s == "a\tc"`,
+			value: "a\tb",
+			expected: []string{
+				`s:      "a\tb"`,
+				`"a\tc": "a\tc"`,
+				strings.Repeat(" ", 12) + "^",
+			},
+		},
+		{
+			name:  "wide runes",
+			expr:  `名前 == "太郎b"`,
+			value: "太郎a",
+			expected: []string{
+				`名前:    "太郎a"`,
+				`"太郎b": "太郎b"`,
+				strings.Repeat(" ", 14) + "^",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := strings.Fields(tt.expr)[0]
+			tree := buildEvaluationTree(tt.expr, map[string]interface{}{name: tt.value})
+			if len(tree.Differences) < 5 || !reflect.DeepEqual(tree.Differences[2:], tt.expected) {
+				t.Errorf("Differences =\n%s\nexpected the excerpts\n%s", strings.Join(tree.Differences, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestBuildEvaluationTree_StringDifferences(t *testing.T) {
+	tree := buildEvaluationTree(`name == "Alice"`, map[string]interface{}{"name": "Alicia"})
+
+	if len(tree.Differences) == 0 || tree.Differences[0] != "first difference at rune 4 (common prefix 4, common suffix 0)" {
+		t.Errorf("Unexpected differences: %q", tree.Differences)
+	}
+
+	tree = buildEvaluationTree(`name == "Alice"`, map[string]interface{}{"name": "<name>"})
+	if len(tree.Differences) != 0 {
+		t.Errorf("Expected no differences for unknown values, got %q", tree.Differences)
+	}
+}
+
+func TestCustomDiffer(t *testing.T) {
+	type version struct{ major, minor int }
+	RegisterDiffer(func(left, right interface{}) ([]string, bool) {
+		l, lok := left.(version)
+		r, rok := right.(version)
+		if !lok || !rok {
+			return nil, false
+		}
+		return []string{fmt.Sprintf("v%d.%d != v%d.%d", l.major, l.minor, r.major, r.minor)}, true
+	})
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"got == want", []string{"v1.2 != v1.3"}},
+		{"versions.Equal(got, want)", []string{"v1.2 != v1.3"}},
+	}
+
+	for _, tt := range tests {
+		tree := buildEvaluationTree(tt.expr, map[string]interface{}{
+			"got":  version{1, 2},
+			"want": version{1, 3},
+		})
+		if !reflect.DeepEqual(tree.Differences, tt.want) {
+			t.Errorf("%s: Differences = %q, want %q", tt.expr, tree.Differences, tt.want)
+		}
+	}
+}
+
+func TestApplyDiffOptions(t *testing.T) {
+	type user struct {
+		name string
+		seen int
+	}
+	RegisterOptionsDiffer(func(left, right interface{}, opts []interface{}) ([]string, bool) {
+		l, lok := left.(user)
+		r, rok := right.(user)
+		if !lok || !rok {
+			return nil, false
+		}
+		var diffs []string
+		if l.name != r.name {
+			diffs = append(diffs, fmt.Sprintf(".name: %q != %q", l.name, r.name))
+		}
+		if l.seen != r.seen && opts[0] != "ignore seen" {
+			diffs = append(diffs, fmt.Sprintf(".seen: %d != %d", l.seen, r.seen))
+		}
+		return diffs, true
+	})
+	defer RegisterOptionsDiffer(nil)
+
+	values := map[string]interface{}{"got": user{"a", 1}, "want": user{"b", 2}}
+	tree := buildEvaluationTree("got == want", values)
+	ApplyDiffOptions(tree, []interface{}{"compare all"})
+	if want := []string{`.name: "a" != "b"`, ".seen: 1 != 2"}; !reflect.DeepEqual(tree.Differences, want) {
+		t.Errorf("Differences = %q, want %q", tree.Differences, want)
+	}
+
+	values["want"] = user{"a", 2}
+	tree = buildEvaluationTree("got == want", values)
+	ApplyDiffOptions(tree, []interface{}{"ignore seen"})
+	if len(tree.Differences) != 0 || !strings.Contains(tree.Note, "equal under the comparison options") {
+		t.Errorf("Expected a note instead of differences, got %q, note %q", tree.Differences, tree.Note)
+	}
+
+	tree = buildEvaluationTree("got == want", values)
+	before := tree.Differences
+	ApplyDiffOptions(tree, nil)
+	if !reflect.DeepEqual(tree.Differences, before) || tree.Note != "" {
+		t.Error("Without options the tree should be left alone")
+	}
+}
@@ -0,0 +1,107 @@
+package evaluator
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Differ lists the differences between two values of a type it understands better than
+// the reflection-based deep diff, e.g. protobuf messages compared field by field. ok is
+// false for values the differ does not handle.
+type Differ func(left, right interface{}) (differences []string, ok bool)
+
+var (
+	differsMu sync.RWMutex
+	differs   []Differ
+)
+
+// RegisterDiffer adds a differ that is consulted, in registration order, before the
+// built-in diffs when a == comparison fails.
+func RegisterDiffer(d Differ) {
+	differsMu.Lock()
+	defer differsMu.Unlock()
+	differs = append(differs, d)
+}
+
+// customDiff returns the differences found by the first registered differ that handles
+// both values, capped at maxDifferences like the built-in diffs.
+func customDiff(left, right interface{}) ([]string, bool) {
+	differsMu.RLock()
+	defer differsMu.RUnlock()
+	for _, d := range differs {
+		diffs, ok := d(left, right)
+		if !ok {
+			continue
+		}
+		if len(diffs) > maxDifferences {
+			diffs = append(diffs[:maxDifferences:maxDifferences], "...")
+		}
+		return diffs, true
+	}
+	return nil, false
+}
+
+// equalFuncDiff evaluates calls like proto.Equal(got, want): a package-level function named
+// Equal with two known arguments that a registered differ understands.
+func equalFuncDiff(name string, base *EvaluationTree, args []*EvaluationTree) ([]string, bool) {
+	if name != "Equal" || len(args) != 2 || (base.Value != nil && !isPlaceholder(base.Value)) {
+		return nil, false
+	}
+	if !HasKnownResult(args[0]) || !HasKnownResult(args[1]) {
+		return nil, false
+	}
+	return customDiff(args[0].Value, args[1].Value)
+}
+
+// OptionsDiffer is a Differ configured by options passed with the assertion, such as
+// go-cmp options. ok is false for values it does not handle.
+type OptionsDiffer func(left, right interface{}, opts []interface{}) (differences []string, ok bool)
+
+var optionsDiffer OptionsDiffer
+
+// RegisterOptionsDiffer installs the differ used by ApplyDiffOptions, replacing any previous one.
+func RegisterOptionsDiffer(d OptionsDiffer) {
+	differsMu.Lock()
+	defer differsMu.Unlock()
+	optionsDiffer = d
+}
+
+// ApplyDiffOptions recomputes the differences of every failed == comparison in tree with
+// the registered OptionsDiffer. Without a registered differ the options are ignored.
+func ApplyDiffOptions(tree *EvaluationTree, opts []interface{}) {
+	differsMu.RLock()
+	d := optionsDiffer
+	differsMu.RUnlock()
+	if d == nil || len(opts) == 0 {
+		return
+	}
+
+	var walk func(node *EvaluationTree)
+	walk = func(node *EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+
+		if node.Operator != "==" || node.Result || !HasKnownResult(node.Left) || !HasKnownResult(node.Right) {
+			return
+		}
+		diffs, ok := d(node.Left.Value, node.Right.Value, opts)
+		if !ok {
+			return
+		}
+		if len(diffs) > maxDifferences {
+			diffs = append(diffs[:maxDifferences:maxDifferences], "...")
+		}
+		node.Differences = diffs
+		if len(diffs) == 0 {
+			node.Note = fmt.Sprintf("%s and %s are equal under the comparison options, but == compares every field",
+				node.Left.Text, node.Right.Text)
+		}
+	}
+	walk(tree)
+}
@@ -0,0 +1,27 @@
+package evaluator
+
+import (
+	"fmt"
+	"time"
+)
+
+// EvaluateDuration builds the result of checking a measured duration against a budget,
+// "elapsed <= budget", where measuredText and budgetText are the source texts of the two.
+// Both operands show their values, computed ones such as time.Since(start) included.
+func EvaluateDuration(measuredText, budgetText string, measured, budget time.Duration) *ExpressionResult {
+	expr := fmt.Sprintf("%s <= %s", measuredText, budgetText)
+	tree := buildEvaluationTree(expr, nil)
+
+	if tree.Type == "comparison" && tree.Left != nil && tree.Right != nil {
+		setLeafValue(tree.Left, measured)
+		setLeafValue(tree.Right, budget)
+		tree.Result = evaluateBinaryExpr(tree.Left, tree.Right, "<=")
+	}
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     tree.Result,
+		Variables:  map[string]interface{}{measuredText: measured, budgetText: budget},
+		Tree:       tree,
+	}
+}
@@ -0,0 +1,35 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		measured time.Duration
+		result   bool
+	}{
+		{"over budget", 63 * time.Millisecond, false},
+		{"at the budget", 50 * time.Millisecond, true},
+		{"under budget", time.Millisecond, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluateDuration("time.Since(start)", "50*time.Millisecond", tt.measured, 50*time.Millisecond)
+
+			if result.Expression != "time.Since(start) <= 50*time.Millisecond" || result.Result != tt.result {
+				t.Fatalf("Expression = %q, Result = %v, want %v", result.Expression, result.Result, tt.result)
+			}
+			left, right := result.Tree.Left, result.Tree.Right
+			if left.Value != tt.measured || left.Children != nil {
+				t.Errorf("The measured duration should be a leaf holding %v, got %+v", tt.measured, left)
+			}
+			if right.Value != 50*time.Millisecond || right.Left != nil {
+				t.Errorf("The budget should be a leaf holding 50ms, got %+v", right)
+			}
+		})
+	}
+}
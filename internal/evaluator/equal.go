@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxEqualDifferences caps the differences Equal records. It is higher than for ==, since
+// the field-path diff is all its failure shows of two large values.
+const maxEqualDifferences = 20
+
+// EqualRules are the declarative rules Equal compares values under.
+type EqualRules struct {
+	// Ignore lists fields left out of the comparison, by name, as "CreatedAt", which matches
+	// the field at any depth, or by a path of field names, as "Items.ID"
+	Ignore []string
+
+	// Sorts holds the orders slices are sorted in before their elements are compared, by
+	// element type, for slices whose order does not matter
+	Sorts map[reflect.Type]func(a, b reflect.Value) bool
+}
+
+// pathIndex matches the indices and map keys of a difference path, as in .Items[2] or
+// .Labels["env"].
+var pathIndex = regexp.MustCompile(`\[("(\\.|[^"\\])*"|[^\]]*)\]`)
+
+// ignores reports whether the field at path, such as ".Items[2].ID", is left out.
+func (r EqualRules) ignores(path string) bool {
+	if len(r.Ignore) == 0 {
+		return false
+	}
+	fields := pathIndex.ReplaceAllString(path, "")
+	for _, rule := range r.Ignore {
+		rule = "." + strings.TrimPrefix(rule, ".")
+		if fields == rule || strings.HasSuffix(fields, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedSlice returns a sorted copy of slice, leaving slice as it is.
+func sortedSlice(slice reflect.Value, less func(a, b reflect.Value) bool) reflect.Value {
+	if slice.IsNil() {
+		return slice
+	}
+	sorted := reflect.MakeSlice(slice.Type(), slice.Len(), slice.Len())
+	reflect.Copy(sorted, slice)
+	sort.SliceStable(sorted.Interface(), func(i, j int) bool {
+		return less(sorted.Index(i), sorted.Index(j))
+	})
+	return sorted
+}
+
+// EqualDifferences compares got and want under rules, field by field, and returns the paths
+// where they differ, none if they are equal.
+func EqualDifferences(got, want interface{}, rules EqualRules) []string {
+	d := &deepDiffer{limit: maxEqualDifferences, visited: make(map[[2]uintptr]bool), rules: rules}
+	d.diff("", reflect.ValueOf(got), reflect.ValueOf(want), FieldTag{})
+	return d.differences()
+}
+
+// EvaluateEqual compares got and want under rules, field by field, and builds the result of
+// "got == want", where gotText and wantText are their source texts. Where the values differ
+// is listed as the Differences of the comparison, as for a failed ==, and the rules that
+// applied are noted.
+func EvaluateEqual(gotText, wantText string, got, want interface{}, rules EqualRules) *ExpressionResult {
+	gotText, wantText = operandText(gotText), operandText(wantText)
+	expr := fmt.Sprintf("%s == %s", gotText, wantText)
+	tree := buildEvaluationTree(expr, nil)
+
+	differences := EqualDifferences(got, want, rules)
+	equal := len(differences) == 0
+
+	// Strings are better explained by where they diverge than as a whole
+	gotString, gotIsString := got.(string)
+	wantString, wantIsString := want.(string)
+	if !equal && gotIsString && wantIsString {
+		differences = stringDiff(gotText, wantText, gotString, wantString)
+	}
+
+	if tree.Type == "comparison" && tree.Left != nil && tree.Right != nil {
+		setLeafValue(tree.Left, got)
+		setLeafValue(tree.Right, want)
+		tree.Result = equal
+		if !equal {
+			tree.Differences = differences
+			tree.Note = rules.describe()
+		}
+	}
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     equal,
+		Variables:  map[string]interface{}{gotText: got, wantText: want},
+		Tree:       tree,
+	}
+}
+
+// operandText parenthesizes text if it cannot be an operand of == as written, such as
+// "a || b".
+func operandText(text string) string {
+	if expr, err := parser.ParseExpr(text); err == nil {
+		if _, ok := expr.(*ast.BinaryExpr); !ok {
+			return text
+		}
+	}
+	return "(" + text + ")"
+}
+
+// describe summarizes the rules for the note of a failed comparison, or returns "" without
+// rules.
+func (r EqualRules) describe() string {
+	var parts []string
+	if len(r.Ignore) > 0 {
+		parts = append(parts, "ignoring "+strings.Join(r.Ignore, ", "))
+	}
+	if len(r.Sorts) > 0 {
+		types := make([]string, 0, len(r.Sorts))
+		for t := range r.Sorts {
+			types = append(types, "[]"+t.String())
+		}
+		sort.Strings(types)
+		parts = append(parts, "sorting "+strings.Join(types, ", "))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "compared " + strings.Join(parts, " and ")
+}
@@ -0,0 +1,76 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+)
+
+type equalItem struct {
+	ID    int
+	Price int
+}
+
+type equalOrder struct {
+	ID     int
+	Items  []equalItem
+	Tags   []string
+	Labels map[string]string
+}
+
+func TestEqualDifferences(t *testing.T) {
+	got := equalOrder{ID: 1, Items: []equalItem{{1, 10}, {2, 12}}, Tags: []string{"b", "a"}, Labels: map[string]string{"env": "prod"}}
+	want := equalOrder{ID: 2, Items: []equalItem{{7, 10}, {8, 13}}, Tags: []string{"a", "b"}, Labels: map[string]string{"env": "prod"}}
+	byString := map[reflect.Type]func(a, b reflect.Value) bool{
+		reflect.TypeOf(""): func(a, b reflect.Value) bool { return a.String() < b.String() },
+	}
+
+	tests := []struct {
+		name  string
+		rules EqualRules
+		want  []string
+	}{
+		{"no rules", EqualRules{}, []string{
+			".ID: 1 != 2", ".Items[0].ID: 1 != 7", ".Items[1].ID: 2 != 8", ".Items[1].Price: 12 != 13",
+			`.Tags[0]: "b" != "a"`, `.Tags[1]: "a" != "b"`,
+		}},
+		{"ignored at any depth", EqualRules{Ignore: []string{"ID"}}, []string{
+			".Items[1].Price: 12 != 13", `.Tags[0]: "b" != "a"`, `.Tags[1]: "a" != "b"`,
+		}},
+		{"ignored by path", EqualRules{Ignore: []string{"Items.ID"}, Sorts: byString}, []string{
+			".ID: 1 != 2", ".Items[1].Price: 12 != 13",
+		}},
+		{"everything that differs ignored", EqualRules{Ignore: []string{"ID", ".Items"}, Sorts: byString}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diffs := EqualDifferences(got, want, tt.rules); !reflect.DeepEqual(diffs, tt.want) {
+				t.Errorf("EqualDifferences() = %q, want %q", diffs, tt.want)
+			}
+		})
+	}
+
+	if got.Tags[0] != "b" {
+		t.Errorf("Sorting should not change the compared slices, got %q", got.Tags)
+	}
+}
+
+func TestEvaluateEqual(t *testing.T) {
+	rules := EqualRules{Ignore: []string{"ID"}}
+	result := EvaluateEqual("got", "want", equalOrder{ID: 1, Tags: []string{"a"}}, equalOrder{ID: 2, Tags: []string{"b"}}, rules)
+
+	if result.Expression != "got == want" || result.Result {
+		t.Fatalf("Expression = %q, Result = %v", result.Expression, result.Result)
+	}
+	tree := result.Tree
+	if !reflect.DeepEqual(tree.Differences, []string{`.Tags[0]: "a" != "b"`}) || tree.Note != "compared ignoring ID" {
+		t.Errorf("Differences = %q, Note = %q", tree.Differences, tree.Note)
+	}
+
+	if result := EvaluateEqual("a || b", "want", true, false, EqualRules{}); result.Expression != "(a || b) == want" {
+		t.Errorf("Operators should be parenthesized, got %q", result.Expression)
+	}
+	if result := EvaluateEqual("got", "want", equalOrder{ID: 1}, equalOrder{ID: 2}, rules); !result.Result || result.Tree.Note != "" {
+		t.Errorf("Values differing only in ignored fields should be equal, got %+v", result.Tree)
+	}
+}
@@ -2,13 +2,18 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
-	"go/parser"
+	"go/constant"
+	"go/printer"
 	"go/token"
+	"math/big"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // ExpressionResult represents the result of evaluating an expression.
@@ -21,7 +26,7 @@ type ExpressionResult struct {
 
 // EvaluationTree represents the tree structure of expression evaluation.
 type EvaluationTree struct {
-	ID       int
+	ID       string // Derived from the expression and the node's position; see NodeID
 	Type     string // "comparison", "logical", "method_call", "identifier", "literal"
 	Operator string // ">", "&&", "||", etc.
 	Left     *EvaluationTree
@@ -30,14 +35,56 @@ type EvaluationTree struct {
 	Result   bool
 	Text     string // Original expression text
 	Children []*EvaluationTree
-}
 
-var nodeCounter int
+	// NotEvaluated is true when && or || short-circuited before reaching this node,
+	// so at runtime Go never evaluated it.
+	NotEvaluated bool
+
+	// Note explains a notable condition found while evaluating this node,
+	// such as a nil pointer in the middle of a selector chain.
+	Note string
+
+	// Reevaluated is true when Value comes from calling the method again after the assertion,
+	// so a method with side effects may have returned something else to the test.
+	Reevaluated bool
+
+	// NotReevaluated is true when the method was not called again, as DIAGASSERT_REEVALUATE
+	// is "pure" and it is not listed as free of side effects; see AddPureMethods.
+	NotReevaluated bool
+
+	// Provenance tells where Value came from.
+	Provenance Provenance
+
+	// Failure stands in for Value when evaluating the node panicked or timed out, as
+	// "(evaluation panicked: ...)".
+	Failure string
+
+	// Err is the message of a non-nil error a method call returned after its other results,
+	// which are all kept in Value as Results.
+	Err string
+
+	// Differences lists the paths where the operands of a failed == on composite
+	// values diverge, e.g. ".Items[3].Price: 10 != 12".
+	Differences []string
+
+	// Explanation is set on the call of a matcher that rejected a value; see EvaluateMatch.
+	Explanation *MatchExplanation
+
+	// StaticType is the Go type of the sub-expression in the type-checked source, such as
+	// "time.Duration" or "any". It is empty when the caller's package could not be checked.
+	StaticType string
+}
 
 // Evaluate performs expression evaluation with variable value extraction and tree building.
 func Evaluate(expr string, result bool, callerFrame uintptr) *ExpressionResult {
+	static := analyzeCaller(expr, callerFrame)
 	variables := extractVariableValuesFromFrame(expr, callerFrame)
+	static.addConstants(variables)
+
 	tree := buildEvaluationTree(expr, variables)
+	static.apply(tree)
+	reconcileTypedNil(tree, result)
+	markProvenance(tree, provenances(variables, nil), variables)
 
 	return &ExpressionResult{
 		Expression: expr,
@@ -49,13 +96,18 @@ func Evaluate(expr string, result bool, callerFrame uintptr) *ExpressionResult {
 
 // EvaluateWithValues performs expression evaluation with user-provided values merged with auto-extracted variables.
 func EvaluateWithValues(expr string, result bool, callerFrame uintptr, userValues map[string]interface{}) *ExpressionResult {
-	// Extract variables from frame (returns placeholders like "<x>")
+	// Extract variables from frame (returns placeholders like "<x>") and named constants
+	static := analyzeCaller(expr, callerFrame)
 	autoExtracted := extractVariableValuesFromFrame(expr, callerFrame)
+	static.addConstants(autoExtracted)
 
 	// Merge user-provided values with auto-extracted, giving precedence to user values
 	variables := mergeVariables(autoExtracted, userValues)
 
 	tree := buildEvaluationTree(expr, variables)
+	static.apply(tree)
+	reconcileTypedNil(tree, result)
+	markProvenance(tree, provenances(autoExtracted, userValues), variables)
 
 	return &ExpressionResult{
 		Expression: expr,
@@ -66,21 +118,30 @@ func EvaluateWithValues(expr string, result bool, callerFrame uintptr, userValue
 }
 
 // buildEvaluationTree constructs a detailed evaluation tree for the expression.
-func buildEvaluationTree(expr string, variables map[string]interface{}) *EvaluationTree {
-	nodeCounter = 0
+func buildEvaluationTree(expr string, variables map[string]interface{}) (tree *EvaluationTree) {
+	// A diagnostic must never take down the test: a value the evaluator cannot handle leaves
+	// the whole expression unevaluated instead
+	defer func() {
+		if r := recover(); r != nil {
+			tree = &EvaluationTree{Type: "error", Text: expr, Failure: fmt.Sprintf("(evaluation panicked: %v)", r)}
+			assignNodeIDs(tree, expr)
+		}
+	}()
 
-	fset := token.NewFileSet()
-	node, err := parser.ParseExpr(expr)
+	fset, node, err := ParseExpr(expr)
 	if err != nil {
-		return &EvaluationTree{
-			ID:     getNextNodeID(),
+		tree := &EvaluationTree{
 			Type:   "error",
 			Text:   expr,
 			Result: false,
 		}
+		assignNodeIDs(tree, expr)
+		return tree
 	}
 
-	return buildTreeFromAST(node, variables, fset)
+	tree = buildTreeFromAST(node, variables, fset)
+	assignNodeIDs(tree, expr)
+	return tree
 }
 
 // buildTreeFromAST recursively builds evaluation tree from AST node.
@@ -99,6 +160,9 @@ func buildTreeFromAST(node ast.Expr, variables map[string]interface{}, fset *tok
 	case *ast.CallExpr:
 		return buildCallTree(n, variables, fset)
 	case *ast.IndexExpr:
+		if isInstantiation(n) {
+			return buildInstanceTree(n, fset)
+		}
 		return buildIndexTree(n, variables, fset)
 	case *ast.SliceExpr:
 		return buildSliceTree(n, variables, fset)
@@ -114,9 +178,14 @@ func buildTreeFromAST(node ast.Expr, variables map[string]interface{}, fset *tok
 		return buildTypeAssertTree(n, variables, fset)
 	case *ast.StarExpr:
 		return buildStarExprTree(n, variables, fset)
+	case *ast.IndexListExpr:
+		return buildInstanceTree(n, fset)
+	case *ast.KeyValueExpr:
+		return buildKeyValueTree(n, variables, fset)
+	case *ast.Ellipsis, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.StructType:
+		return buildTypeExprTree(n, fset)
 	default:
 		return &EvaluationTree{
-			ID:   getNextNodeID(),
 			Type: "unknown",
 			Text: fmt.Sprintf("%T", node),
 		}
@@ -127,18 +196,220 @@ func buildTreeFromAST(node ast.Expr, variables map[string]interface{}, fset *tok
 func buildBinaryExprTree(expr *ast.BinaryExpr, variables map[string]interface{}, fset *token.FileSet) *EvaluationTree {
 	left := buildTreeFromAST(expr.X, variables, fset)
 	right := buildTreeFromAST(expr.Y, variables, fset)
+	convertUntypedOperand(expr, left, right)
 
 	operator := expr.Op.String()
+	exprType := getBinaryExprType(operator)
 	result := evaluateBinaryExpr(left, right, operator)
+	text := left.Text + " " + operator + " " + right.Text
+
+	// Explain what a failing "x == nil" or "x != nil" actually compared against
+	var note string
+	if operand := nilComparisonOperand(expr, left, right); operand != nil {
+		note = describeNilOperand(operand)
+	} else if exprType == "comparison" {
+		note = numericTypeNote(text, left, right)
+	}
+
+	// Structs, slices and maps that are not equal report where they diverge,
+	// strings where their first differing rune is
+	var differences []string
+	if operator == "==" && !result {
+		differences = equalityDifferences(left, right)
+	}
+
+	// Mirror Go's short-circuit semantics: once the left operand decides the outcome,
+	// the right operand is never evaluated
+	if exprType == "logical" && HasKnownResult(left) {
+		if (operator == "&&" && !left.Result) || (operator == "||" && left.Result) {
+			markNotEvaluated(right)
+		}
+	}
+
+	// Arithmetic nodes carry their computed value so it can be shown under the operator
+	var value interface{}
+	if exprType == "binary" {
+		if c, kind, ok := untypedConstant(expr); ok {
+			value, _ = untypedValue(c, kind)
+		} else {
+			value = evaluateArithmetic(left.Value, right.Value, operator)
+		}
+		result = value != nil && isTruthy(value)
+	}
 
 	return &EvaluationTree{
-		ID:       getNextNodeID(),
-		Type:     getBinaryExprType(operator),
-		Operator: operator,
-		Left:     left,
-		Right:    right,
-		Result:   result,
-		Text:     fmt.Sprintf("%s %s %s", left.Text, operator, right.Text),
+		Type:        exprType,
+		Operator:    operator,
+		Left:        left,
+		Right:       right,
+		Value:       value,
+		Result:      result,
+		Text:        text,
+		Note:        note,
+		Differences: differences,
+	}
+}
+
+// convertUntypedOperand gives an operand made only of literals, such as 1<<63, the type of
+// the other operand, as Go converts untyped constants: compared with a uint64, 1<<63 is
+// 9223372036854775808 rather than the int it overflows. Shift counts keep their own type.
+func convertUntypedOperand(expr *ast.BinaryExpr, left, right *EvaluationTree) {
+	if expr.Op == token.SHL || expr.Op == token.SHR {
+		return
+	}
+	x, _, leftIsConstant := untypedConstant(expr.X)
+	y, _, rightIsConstant := untypedConstant(expr.Y)
+	switch {
+	case leftIsConstant && !rightIsConstant:
+		convertOperand(x, left, right)
+	case rightIsConstant && !leftIsConstant:
+		convertOperand(y, right, left)
+	}
+}
+
+// convertOperand sets the value of the constant operand tree to c converted to the type of
+// the other operand, when that one is known and c fits it.
+func convertOperand(c constant.Value, tree, other *EvaluationTree) {
+	if other.Value == nil || isPlaceholder(other.Value) {
+		return
+	}
+	if value, ok := convertConstant(c, reflect.TypeOf(other.Value)); ok {
+		tree.Value = value
+	}
+}
+
+// equalityDifferences describes where the operands of a failed == diverge. It returns nil
+// when either operand is unknown or the values have no finer-grained difference to show.
+func equalityDifferences(left, right *EvaluationTree) []string {
+	if !HasKnownResult(left) || !HasKnownResult(right) {
+		return nil
+	}
+
+	leftStr, leftIsString := left.Value.(string)
+	rightStr, rightIsString := right.Value.(string)
+	custom, hasCustom := customDiff(left.Value, right.Value)
+	switch {
+	case hasCustom:
+		return custom
+	case leftIsString && rightIsString:
+		return stringDiff(left.Text, right.Text, leftStr, rightStr)
+	case isComposite(left.Value) && isComposite(right.Value):
+		return deepDiff(left.Value, right.Value, maxDifferences)
+	}
+	return nil
+}
+
+// nilComparisonOperand returns the non-nil side of "x == nil" or "x != nil", or nil otherwise.
+func nilComparisonOperand(expr *ast.BinaryExpr, left, right *EvaluationTree) *EvaluationTree {
+	if expr.Op != token.EQL && expr.Op != token.NEQ {
+		return nil
+	}
+	if isNilIdent(expr.Y) {
+		return left
+	}
+	if isNilIdent(expr.X) {
+		return right
+	}
+	return nil
+}
+
+func isNilIdent(node ast.Expr) bool {
+	ident, ok := node.(*ast.Ident)
+	return ok && ident.Name == "nil"
+}
+
+// describeNilOperand describes the value compared against nil, including its dynamic type
+// and, for pointers, its address. It returns "" when the value is unknown.
+func describeNilOperand(operand *EvaluationTree) string {
+	value := operand.Value
+	if value == nil || isPlaceholder(value) {
+		return ""
+	}
+
+	val := reflect.ValueOf(value)
+	switch {
+	case isNilPointer(value):
+		return fmt.Sprintf("%s is (%T)(nil)", operand.Text, value)
+	case isError(value):
+		return fmt.Sprintf("%s is a non-nil %T: %v", operand.Text, value, value)
+	case val.Kind() == reflect.Ptr:
+		return fmt.Sprintf("%s is (%T)(%p) — non-nil pointer", operand.Text, value, value)
+	case isNilValue(value):
+		return fmt.Sprintf("%s is a nil %T", operand.Text, value)
+	default:
+		return fmt.Sprintf("%s is a non-nil %T: %v", operand.Text, value, value)
+	}
+}
+
+func isError(value interface{}) bool {
+	_, ok := value.(error)
+	return ok
+}
+
+// isNilValue reports whether a value is a typed nil pointer, map, slice, func, channel or interface.
+func isNilValue(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return val.IsNil()
+	}
+	return false
+}
+
+// reconcileTypedNil resolves the classic typed-nil gotcha. Captured values lose their static
+// type, so a (*T)(nil) is evaluated as a nil pointer. If that contradicts the actual result of
+// the assertion, the operand must have been an interface wrapping the nil pointer, which Go
+// considers non-nil: the affected comparisons are flipped and annotated accordingly.
+func reconcileTypedNil(tree *EvaluationTree, actual bool) {
+	if tree == nil || tree.Result == actual || !HasKnownResult(tree) {
+		return
+	}
+
+	flipped := false
+	var walk func(node *EvaluationTree)
+	walk = func(node *EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+
+		if node.Type != "comparison" || (node.Operator != "==" && node.Operator != "!=") {
+			return
+		}
+		for _, operand := range []*EvaluationTree{node.Left, node.Right} {
+			if isNilPointer(operand.Value) {
+				node.Result = !node.Result
+				node.Note = fmt.Sprintf("%s: interface holds (%T)(nil) — non-nil interface wrapping nil pointer",
+					operand.Text, operand.Value)
+				flipped = true
+				break
+			}
+		}
+	}
+	walk(tree)
+
+	if flipped {
+		refreshResults(tree)
+	}
+}
+
+// refreshResults recomputes logical and negation results bottom-up after a child result changed.
+func refreshResults(node *EvaluationTree) {
+	if node == nil {
+		return
+	}
+	refreshResults(node.Left)
+	refreshResults(node.Right)
+
+	switch {
+	case node.Type == "logical" && node.Left != nil && node.Right != nil:
+		node.Result = evaluateBinaryExpr(node.Left, node.Right, node.Operator)
+	case node.Type == "unary" && node.Operator == "!" && node.Left != nil:
+		node.Result = !node.Left.Result
 	}
 }
 
@@ -155,25 +426,32 @@ func buildUnaryExprTree(expr *ast.UnaryExpr, variables map[string]interface{}, f
 	}
 
 	return &EvaluationTree{
-		ID:       getNextNodeID(),
 		Type:     "unary",
 		Operator: operator,
 		Left:     operand,
 		Result:   result,
-		Text:     fmt.Sprintf("%s%s", operator, operand.Text),
+		Text:     fmt.Sprintf("%s%s", operator, baseText(expr.X, operand)),
 	}
 }
 
 // buildIdentTree builds tree for identifiers like "x", "user".
 func buildIdentTree(ident *ast.Ident, variables map[string]interface{}) *EvaluationTree {
-	value, exists := variables[ident.Name]
+	name := ident.Name
+	if isJSONRoot(name, variables) {
+		name = JSONRoot
+	}
+	value, exists := variables[name]
+
+	// Predeclared boolean constants evaluate to themselves unless shadowed
+	if !exists && (ident.Name == "true" || ident.Name == "false") {
+		value, exists = ident.Name == "true", true
+	}
 
 	return &EvaluationTree{
-		ID:     getNextNodeID(),
 		Type:   "identifier",
 		Value:  value,
 		Result: exists && isTruthy(value),
-		Text:   ident.Name,
+		Text:   name,
 	}
 }
 
@@ -182,7 +460,6 @@ func buildLiteralTree(lit *ast.BasicLit) *EvaluationTree {
 	value := parseLiteral(lit)
 
 	return &EvaluationTree{
-		ID:     getNextNodeID(),
 		Type:   "literal",
 		Value:  value,
 		Result: isTruthy(value),
@@ -192,28 +469,87 @@ func buildLiteralTree(lit *ast.BasicLit) *EvaluationTree {
 
 // buildSelectorTree builds tree for selector expressions like "user.Age".
 func buildSelectorTree(sel *ast.SelectorExpr, variables map[string]interface{}, fset *token.FileSet) *EvaluationTree {
+	// Qualified constants such as http.StatusOK are resolved as a whole
+	if pkg, ok := sel.X.(*ast.Ident); ok {
+		text := pkg.Name + "." + sel.Sel.Name
+		if value, exists := variables[text]; exists {
+			return &EvaluationTree{
+				Type:   "selector",
+				Value:  value,
+				Result: isTruthy(value),
+				Text:   text,
+			}
+		}
+	}
+
 	baseTree := buildTreeFromAST(sel.X, variables, fset)
 	fieldName := sel.Sel.Name
-	text := fmt.Sprintf("%s.%s", baseTree.Text, fieldName)
+	text := fmt.Sprintf("%s.%s", baseText(sel.X, baseTree), fieldName)
 
 	var value interface{}
 	var result bool
+	var note string
 
-	if baseTree.Value != nil {
+	if isNilBase(sel.X, baseTree, variables) {
+		note = fmt.Sprintf("%s is nil — cannot access %s", baseTree.Text, fieldName)
+	} else if baseTree.Value != nil {
 		if fieldValue := getFieldValue(baseTree.Value, fieldName); fieldValue != nil {
 			value = fieldValue
 			result = isTruthy(value)
+		} else {
+			note = jsonMemberNote(baseTree, fieldName)
 		}
 	}
 
 	return &EvaluationTree{
-		ID:     getNextNodeID(),
 		Type:   "selector",
 		Left:   baseTree,
 		Value:  value,
 		Result: result,
 		Text:   text,
+		Note:   note,
+	}
+}
+
+// baseText returns the text of the operand a selector, index, slice or type assertion
+// applies to, keeping the parentheses that bind a dereference to it, as in (*pp).Name.
+func baseText(base ast.Expr, baseTree *EvaluationTree) string {
+	if _, ok := base.(*ast.ParenExpr); ok {
+		return "(" + baseTree.Text + ")"
+	}
+	return baseTree.Text
+}
+
+// isNilBase reports whether the base of a selector or method call is known to be nil.
+// A nil pointer is always known; an untyped nil only when the identifier was explicitly captured.
+func isNilBase(base ast.Expr, baseTree *EvaluationTree, variables map[string]interface{}) bool {
+	if isNilPointer(baseTree.Value) {
+		return true
+	}
+	if ident, ok := base.(*ast.Ident); ok && baseTree.Value == nil {
+		value, exists := variables[ident.Name]
+		return exists && value == nil
+	}
+	return false
+}
+
+// isNilPointer reports whether a value is a typed nil pointer.
+func isNilPointer(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	val := reflect.ValueOf(value)
+	return val.Kind() == reflect.Ptr && val.IsNil()
+}
+
+// hasValueReceiver reports whether calling the method on a nil pointer would dereference it,
+// i.e. whether the method is declared on the value type rather than the pointer type.
+func hasValueReceiver(ptr interface{}, methodName string) bool {
+	if ptr == nil {
+		return true
 	}
+	_, ok := reflect.TypeOf(ptr).Elem().MethodByName(methodName)
+	return ok
 }
 
 // buildCallTree builds tree for method calls like "user.IsAdult()".
@@ -225,69 +561,162 @@ func buildCallTree(call *ast.CallExpr, variables map[string]interface{}, fset *t
 	case *ast.SelectorExpr:
 		baseTree := buildTreeFromAST(fun.X, variables, fset)
 		methodName := fun.Sel.Name
-		text.WriteString(fmt.Sprintf("%s.%s()", baseTree.Text, methodName))
+		args, argTexts := buildArgTrees(call, variables, fset)
+		text.WriteString(fmt.Sprintf("%s.%s(%s)", baseText(fun.X, baseTree), methodName, strings.Join(argTexts, ", ")))
 
 		// Try to call the method if possible
 		var value interface{}
 		var result bool
-		if baseTree.Value != nil {
-			if methodResult := callMethod(baseTree.Value, methodName); methodResult != nil {
-				value = methodResult
+		var note, errText string
+		var differences []string
+		var reevaluated, notReevaluated bool
+		var failure string
+		if recorded, ok := variables[text.String()]; ok {
+			// Calls recorded by instrumented code are not made again; see EvaluateCaptured
+			value, result = recorded, isTruthy(recorded)
+		} else if diffs, ok := equalFuncDiff(methodName, baseTree, args); ok {
+			// Package-level equality functions such as proto.Equal(got, want) report where the values differ
+			differences = diffs
+			value = len(diffs) == 0
+			result = len(diffs) == 0
+		} else if regexpValue, regexpNote, ok := evaluateRegexpCall(fun, baseTree, args); ok {
+			value, note = regexpValue, regexpNote
+			result = value != nil && isTruthy(value)
+		} else if containsValue, containsNote, ok := evaluateContainsCall(fun, baseTree, args); ok {
+			value, note = containsValue, containsNote
+			result = isTruthy(value)
+		} else if isNilBase(fun.X, baseTree, variables) && hasValueReceiver(baseTree.Value, methodName) {
+			// Go would panic dereferencing the nil receiver; report it instead of calling
+			note = fmt.Sprintf("%s is nil — cannot call %s()", baseTree.Text, methodName)
+		} else if baseTree.Value != nil && knownArgs(args) && !mayCallAgain(baseTree.Value, methodName) {
+			notReevaluated = true
+			note = fmt.Sprintf("%s was not called again, as it is not listed as free of side effects", text.String())
+		} else if baseTree.Value != nil && knownArgs(args) {
+			if results, callFailure, ok := callMethod(baseTree.Value, methodName, args, call.Ellipsis.IsValid()); ok && callFailure != "" {
+				failure = callFailure
+			} else if ok {
+				value, errText = callValue(results)
 				result = isTruthy(value)
+				reevaluated = true
 			}
 		}
 
 		return &EvaluationTree{
-			ID:     getNextNodeID(),
-			Type:   "method_call",
-			Left:   baseTree,
-			Value:  value,
-			Result: result,
-			Text:   text.String(),
+			Type:           "method_call",
+			Left:           baseTree,
+			Children:       args,
+			Value:          value,
+			Result:         result,
+			Text:           text.String(),
+			Note:           note,
+			Err:            errText,
+			Differences:    differences,
+			Reevaluated:    reevaluated,
+			NotReevaluated: notReevaluated,
+			Failure:        failure,
 		}
 	default:
+		// Plain functions, builtins and generic instantiations like Map[int, string](xs, f)
+		args, argTexts := buildArgTrees(call, variables, fset)
+		funTree := buildTreeFromAST(fun, variables, fset)
+		text.WriteString(fmt.Sprintf("%s(%s)", funTree.Text, strings.Join(argTexts, ", ")))
+
+		// Only side-effect free builtins are evaluated; other results, such as those of
+		// generic functions like Max[int](a, b), are read from the values passed by their text
+		var value interface{}
+		if recorded, ok := variables[text.String()]; ok && !isPlaceholder(recorded) {
+			value = recorded
+		} else if ident, ok := fun.(*ast.Ident); ok && !call.Ellipsis.IsValid() {
+			value = callBuiltin(ident.Name, args)
+		}
+
 		return &EvaluationTree{
-			ID:   getNextNodeID(),
-			Type: "call",
-			Text: "function_call",
+			Type:     "call",
+			Children: args,
+			Value:    value,
+			Result:   value != nil && isTruthy(value),
+			Text:     text.String(),
 		}
 	}
 }
 
+// buildArgTrees builds the trees of a call's arguments and their texts, marking a spread final argument with "...".
+func buildArgTrees(call *ast.CallExpr, variables map[string]interface{}, fset *token.FileSet) ([]*EvaluationTree, []string) {
+	args := make([]*EvaluationTree, len(call.Args))
+	argTexts := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = buildTreeFromAST(arg, variables, fset)
+		argTexts[i] = args[i].Text
+	}
+	if call.Ellipsis.IsValid() && len(argTexts) > 0 {
+		argTexts[len(argTexts)-1] += "..."
+	}
+	return args, argTexts
+}
+
+// buildKeyValueTree builds tree for key-value pairs in composite literals like "Name: name".
+func buildKeyValueTree(kv *ast.KeyValueExpr, variables map[string]interface{}, fset *token.FileSet) *EvaluationTree {
+	keyTree := buildTreeFromAST(kv.Key, variables, fset)
+	valueTree := buildTreeFromAST(kv.Value, variables, fset)
+
+	return &EvaluationTree{
+		Type:  "key_value",
+		Left:  keyTree,
+		Right: valueTree,
+		Text:  fmt.Sprintf("%s: %s", keyTree.Text, valueTree.Text),
+	}
+}
+
+// buildTypeExprTree builds tree for type expressions like "map[string]int" or "chan int".
+// Types carry no runtime value, so only their source text is kept.
+func buildTypeExprTree(node ast.Expr, fset *token.FileSet) *EvaluationTree {
+	return &EvaluationTree{
+		Type: "type",
+		Text: nodeText(node, fset),
+	}
+}
+
+// nodeText renders an AST node back to Go source.
+func nodeText(node ast.Node, fset *token.FileSet) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return fmt.Sprintf("%T", node)
+	}
+	return buf.String()
+}
+
 // buildIndexTree builds tree for index expressions like "arr[0]".
 func buildIndexTree(index *ast.IndexExpr, variables map[string]interface{}, fset *token.FileSet) *EvaluationTree {
 	baseTree := buildTreeFromAST(index.X, variables, fset)
 	indexTree := buildTreeFromAST(index.Index, variables, fset)
-	text := fmt.Sprintf("%s[%s]", baseTree.Text, indexTree.Text)
+	text := fmt.Sprintf("%s[%s]", baseText(index.X, baseTree), indexTree.Text)
 
 	var value interface{}
 	var result bool
+	var note string
 
-	if baseTree.Value != nil && indexTree.Value != nil {
+	if baseTree.Value != nil && indexTree.Value != nil && !isPlaceholder(baseTree.Value) {
 		if indexValue := getIndexValue(baseTree.Value, indexTree.Value); indexValue != nil {
 			value = indexValue
 			result = isTruthy(value)
+		} else {
+			note = jsonMemberNote(baseTree, indexTree.Value)
 		}
 	}
 
 	return &EvaluationTree{
-		ID:     getNextNodeID(),
 		Type:   "index",
 		Left:   baseTree,
 		Right:  indexTree,
 		Value:  value,
 		Result: result,
 		Text:   text,
+		Note:   note,
 	}
 }
 
 // Helper functions
 
-func getNextNodeID() int {
-	nodeCounter++
-	return nodeCounter
-}
-
 func getBinaryExprType(operator string) string {
 	switch operator {
 	case "&&", "||":
@@ -322,7 +751,356 @@ func evaluateBinaryExpr(left, right *EvaluationTree, operator string) bool {
 	}
 }
 
+// FindFailingNode walks a failed evaluation tree down to the deepest node that explains
+// the failure: the first false operand of an && chain, then the failing comparison itself.
+// It returns nil when the tree did not fail or its values are unknown (placeholders).
+func FindFailingNode(tree *EvaluationTree) *EvaluationTree {
+	if tree == nil || tree.NotEvaluated || tree.Result || !HasKnownResult(tree) {
+		return nil
+	}
+
+	// Only && narrows down to one operand; a false || means every operand was false
+	if tree.Type == "logical" && tree.Operator == "&&" {
+		for _, operand := range []*EvaluationTree{tree.Left, tree.Right} {
+			if cause := FindFailingNode(operand); cause != nil {
+				return cause
+			}
+		}
+	}
+
+	return tree
+}
+
+// HasKnownResult reports whether a node's result reflects real values rather than
+// placeholders, i.e. whether it can be trusted to decide a short-circuit.
+func HasKnownResult(tree *EvaluationTree) bool {
+	if tree == nil {
+		return false
+	}
+
+	switch tree.Type {
+	case "literal":
+		return true
+	case "identifier":
+		return tree.Text == "nil" || (tree.Value != nil && !isPlaceholder(tree.Value))
+	case "selector", "index", "call", "method_call", "dereference", "binary", "type_assert":
+		return tree.Value != nil && !isPlaceholder(tree.Value)
+	case "comparison":
+		return HasKnownResult(tree.Left) && HasKnownResult(tree.Right)
+	case "logical":
+		if tree.Right != nil && tree.Right.NotEvaluated {
+			return HasKnownResult(tree.Left)
+		}
+		return HasKnownResult(tree.Left) && HasKnownResult(tree.Right)
+	case "unary":
+		return HasKnownResult(tree.Left)
+	default:
+		return false
+	}
+}
+
+// markNotEvaluated flags a subtree as skipped by short-circuit evaluation.
+func markNotEvaluated(tree *EvaluationTree) {
+	if tree == nil {
+		return
+	}
+
+	tree.NotEvaluated = true
+	markNotEvaluated(tree.Left)
+	markNotEvaluated(tree.Right)
+	for _, child := range tree.Children {
+		markNotEvaluated(child)
+	}
+}
+
+// evaluateArithmetic computes the result of an arithmetic or bitwise operator.
+// It returns nil when either operand is unknown or the operation is not defined for the operands.
+func evaluateArithmetic(left, right interface{}, operator string) interface{} {
+	if left == nil || right == nil || isPlaceholder(left) || isPlaceholder(right) {
+		return nil
+	}
+
+	// String concatenation
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok && operator == "+" {
+			return l + r
+		}
+		return nil
+	}
+
+	leftVal := reflect.ValueOf(left)
+	rightVal := reflect.ValueOf(right)
+	if operator == "<<" || operator == ">>" {
+		return evaluateShift(leftVal, rightVal, operator)
+	}
+	resultType := arithmeticResultType(leftVal.Type(), rightVal.Type())
+
+	switch {
+	case isFloatKind(leftVal.Kind()) || isFloatKind(rightVal.Kind()):
+		l, r := getNumericValue(left), getNumericValue(right)
+		if l == nil || r == nil {
+			return nil
+		}
+		var res float64
+		switch operator {
+		case "+":
+			res = *l + *r
+		case "-":
+			res = *l - *r
+		case "*":
+			res = *l * *r
+		case "/":
+			if *r == 0 {
+				return nil
+			}
+			res = *l / *r
+		default:
+			return nil
+		}
+		if !isFloatKind(resultType.Kind()) {
+			resultType = reflect.TypeOf(res)
+		}
+		return reflect.ValueOf(res).Convert(resultType).Interface()
+
+	case isUintKind(leftVal.Kind()) || isUintKind(rightVal.Kind()):
+		l, lok := toUint64(leftVal)
+		r, rok := toUint64(rightVal)
+		if !lok || !rok {
+			return nil
+		}
+		res, ok := applyUintOperator(l, r, operator)
+		if !ok {
+			return nil
+		}
+		return reflect.ValueOf(res).Convert(resultType).Interface()
+
+	case isIntKind(leftVal.Kind()) && isIntKind(rightVal.Kind()):
+		res, ok := applyIntOperator(leftVal.Int(), rightVal.Int(), operator)
+		if !ok {
+			return nil
+		}
+		return reflect.ValueOf(res).Convert(resultType).Interface()
+	}
+
+	return nil
+}
+
+// evaluateShift shifts an integer by a non-negative count. The result has the type of the
+// left operand whatever the count's type, so 1 << n is an int even for a uint n.
+func evaluateShift(left, right reflect.Value, operator string) interface{} {
+	count, ok := toUint64(right)
+	if !ok {
+		return nil
+	}
+
+	result := reflect.New(left.Type()).Elem()
+	switch {
+	case isIntKind(left.Kind()):
+		l := left.Int()
+		if operator == "<<" {
+			l <<= count
+		} else {
+			l >>= count
+		}
+		result.SetInt(l) // Truncates to the width of the type, as the shift itself would
+	case isUintKind(left.Kind()):
+		l := left.Uint()
+		if operator == "<<" {
+			l <<= count
+		} else {
+			l >>= count
+		}
+		result.SetUint(l)
+	default:
+		return nil
+	}
+	return result.Interface()
+}
+
+// arithmeticResultType picks the type of an arithmetic result.
+// Untyped named constants evaluate to int or float64, so a differently typed operand (a
+// variable) wins, mirroring how Go converts them; literals are converted before.
+func arithmeticResultType(left, right reflect.Type) reflect.Type {
+	if left == right {
+		return left
+	}
+	if left.Kind() == reflect.Int || left.Kind() == reflect.Float64 {
+		return right
+	}
+	return left
+}
+
+// applyIntOperator applies an arithmetic or bitwise operator to signed integers.
+func applyIntOperator(l, r int64, operator string) (int64, bool) {
+	switch operator {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	case "%":
+		if r == 0 {
+			return 0, false
+		}
+		return l % r, true
+	case "&":
+		return l & r, true
+	case "|":
+		return l | r, true
+	case "^":
+		return l ^ r, true
+	case "&^":
+		return l &^ r, true
+	default:
+		return 0, false
+	}
+}
+
+// applyUintOperator applies an arithmetic or bitwise operator to unsigned integers.
+func applyUintOperator(l, r uint64, operator string) (uint64, bool) {
+	switch operator {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	case "%":
+		if r == 0 {
+			return 0, false
+		}
+		return l % r, true
+	case "&":
+		return l & r, true
+	case "|":
+		return l | r, true
+	case "^":
+		return l ^ r, true
+	case "&^":
+		return l &^ r, true
+	default:
+		return 0, false
+	}
+}
+
+// toUint64 converts a non-negative integer value to uint64.
+func toUint64(val reflect.Value) (uint64, bool) {
+	switch {
+	case isUintKind(val.Kind()):
+		return val.Uint(), true
+	case isIntKind(val.Kind()) && val.Int() >= 0:
+		return uint64(val.Int()), true
+	default:
+		return 0, false
+	}
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isFloatKind(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+// callBuiltin evaluates side-effect free builtin functions such as len, cap, min and max.
+func callBuiltin(name string, args []*EvaluationTree) interface{} {
+	if len(args) == 0 || args[0].Value == nil || isPlaceholder(args[0].Value) {
+		return nil
+	}
+	if name == "min" || name == "max" {
+		return callMinMax(name, args)
+	}
+	if len(args) != 1 {
+		return nil
+	}
+
+	val := reflect.ValueOf(args[0].Value)
+	switch name {
+	case "len":
+		switch val.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+			return val.Len()
+		}
+	case "cap":
+		switch val.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Chan:
+			return val.Cap()
+		}
+	}
+
+	return nil
+}
+
+// callMinMax evaluates the min and max builtins (Go 1.21) over numbers or strings.
+func callMinMax(name string, args []*EvaluationTree) interface{} {
+	best := args[0].Value
+	for _, arg := range args[1:] {
+		if arg.Value == nil || isPlaceholder(arg.Value) {
+			return nil
+		}
+
+		var less bool
+		switch current := best.(type) {
+		case string:
+			s, ok := arg.Value.(string)
+			if !ok {
+				return nil
+			}
+			less = s < current
+		default:
+			var ok bool
+			if less, ok = compareNumbers(arg.Value, current, "<"); !ok {
+				return nil
+			}
+		}
+
+		if less == (name == "min") {
+			best = arg.Value
+		}
+	}
+	return best
+}
+
+// isPlaceholder reports whether a value is the "<name>" stand-in used for
+// variables whose runtime value could not be extracted.
+func isPlaceholder(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && len(s) > 2 && strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">")
+}
+
 func compareValues(left, right interface{}, operator string) bool {
+	// Typed nils (a nil *T, map or slice) compare equal to nil, as they do for pointer-typed variables
+	if isNilValue(left) {
+		left = nil
+	}
+	if isNilValue(right) {
+		right = nil
+	}
+
 	if left == nil || right == nil {
 		switch operator {
 		case "==":
@@ -342,83 +1120,70 @@ func compareValues(left, right interface{}, operator string) bool {
 		return false
 	}
 
+	// Untyped constants such as 'a' or 97 compare by value against typed operands (s[0] == 'a')
+	if result, ok := compareNumbers(left, right, operator); ok {
+		return result
+	}
+
 	switch operator {
 	case "==":
 		return reflect.DeepEqual(left, right)
 	case "!=":
 		return !reflect.DeepEqual(left, right)
-	case "<", "<=", ">", ">=":
-		return compareNumeric(left, right, operator)
 	default:
 		return false
 	}
 }
 
-func compareNumeric(left, right interface{}, operator string) bool {
-	leftVal := getNumericValue(left)
-	rightVal := getNumericValue(right)
-
-	if leftVal == nil || rightVal == nil {
-		return false
+// getNumericValue converts a value of any integer or floating-point kind, including named
+// types such as time.Duration, to float64. It returns nil for other values.
+func getNumericValue(v interface{}) *float64 {
+	if v == nil {
+		return nil
 	}
 
-	l := *leftVal
-	r := *rightVal
-
-	switch operator {
-	case "<":
-		return l < r
-	case "<=":
-		return l <= r
-	case ">":
-		return l > r
-	case ">=":
-		return l >= r
+	val := reflect.ValueOf(v)
+	var f float64
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		f = val.Float()
 	default:
-		return false
+		return nil
 	}
+	return &f
 }
 
-func getNumericValue(v interface{}) *float64 {
-	switch val := v.(type) {
-	case int:
-		f := float64(val)
-		return &f
-	case int8:
-		f := float64(val)
-		return &f
-	case int16:
-		f := float64(val)
-		return &f
-	case int32:
-		f := float64(val)
-		return &f
-	case int64:
-		f := float64(val)
-		return &f
-	case uint:
-		f := float64(val)
-		return &f
-	case uint8:
-		f := float64(val)
-		return &f
-	case uint16:
-		f := float64(val)
-		return &f
-	case uint32:
-		f := float64(val)
-		return &f
-	case uint64:
-		f := float64(val)
-		return &f
-	case float32:
-		f := float64(val)
-		return &f
-	case float64:
-		return &val
-	default:
-		return nil
+// numericTypeNote flags a comparison of numbers whose types differ, such as an int and a
+// float64 held in interfaces. They are compared by value, but the mismatch is usually a bug
+// in the test: Go considers interfaces holding different types unequal. Literals are
+// untyped constants and never flagged.
+func numericTypeNote(node string, left, right *EvaluationTree) string {
+	if left.Type == "literal" || right.Type == "literal" || !HasKnownResult(left) || !HasKnownResult(right) {
+		return ""
+	}
+	if !toNumber(left.Value).valid || !toNumber(right.Value).valid {
+		return ""
+	}
+	leftType, rightType := reflect.TypeOf(left.Value), reflect.TypeOf(right.Value)
+	if leftType == rightType {
+		return ""
+	}
+	return fmt.Sprintf("%s: comparing %s (%s) with %s (%s) — types differ",
+		node, leftType, formatNumber(left.Value), rightType, formatNumber(right.Value))
+}
+
+// formatNumber prints a number so that floating-point values are recognizable as such: 3.0, not 3.
+func formatNumber(v interface{}) string {
+	s := fmt.Sprint(v)
+	kind := reflect.ValueOf(v).Kind()
+	if (kind == reflect.Float32 || kind == reflect.Float64) && !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
 	}
+	return s
 }
 
 func isTruthy(value interface{}) bool {
@@ -445,29 +1210,40 @@ func isTruthy(value interface{}) bool {
 func parseLiteral(lit *ast.BasicLit) interface{} {
 	switch lit.Kind {
 	case token.INT:
-		// Simple int parsing - more sophisticated parsing could be added
-		var val int
-		if _, err := fmt.Sscanf(lit.Value, "%d", &val); err != nil {
-			return lit.Value // Return original string if parsing fails
+		// Base 0 accepts every Go notation: 0x1F, 0b1010, 0o755, 0755 and 1_000
+		val, err := strconv.ParseInt(lit.Value, 0, 0)
+		if err == nil {
+			return int(val)
 		}
-		return val
+		// Larger constants such as math.MaxUint64 written out keep every digit
+		if u, err := strconv.ParseUint(lit.Value, 0, 64); err == nil {
+			return u
+		}
+		if b, ok := new(big.Int).SetString(lit.Value, 0); ok {
+			return bigInt{b}
+		}
+		return lit.Value // Return original string if parsing fails
 	case token.FLOAT:
-		var val float64
-		if _, err := fmt.Sscanf(lit.Value, "%f", &val); err != nil {
+		val, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
 			return lit.Value // Return original string if parsing fails
 		}
 		return val
 	case token.STRING:
-		// Remove quotes
-		if len(lit.Value) >= 2 {
-			return lit.Value[1 : len(lit.Value)-1]
+		// Unquote handles escapes ("a\tb", "\u00e9") and raw strings alike
+		unquoted, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return lit.Value
 		}
-		return lit.Value
+		return unquoted
 	case token.CHAR:
-		if len(lit.Value) >= 3 {
-			return rune(lit.Value[1])
+		// Unquote handles escapes ('\n', '\x41', '\u00e9') and multibyte runes ('é')
+		unquoted, err := strconv.Unquote(lit.Value)
+		if err != nil || unquoted == "" {
+			return rune(0)
 		}
-		return rune(0)
+		r, _ := utf8.DecodeRuneInString(unquoted)
+		return r
 	default:
 		return lit.Value
 	}
@@ -478,6 +1254,11 @@ func getFieldValue(obj interface{}, fieldName string) interface{} {
 		return nil
 	}
 
+	// JSON objects decoded into maps expose their members as fields
+	if doc, ok := obj.(map[string]interface{}); ok {
+		return doc[fieldName]
+	}
+
 	val := reflect.ValueOf(obj)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
@@ -487,32 +1268,72 @@ func getFieldValue(obj interface{}, fieldName string) interface{} {
 		return nil
 	}
 
-	field := val.FieldByName(fieldName)
-	if !field.IsValid() || !field.CanInterface() {
+	// Fields promoted through nil embedded pointers cannot be read
+	structField, ok := val.Type().FieldByName(fieldName)
+	if !ok {
+		return nil
+	}
+	field, err := val.FieldByIndexErr(structField.Index)
+	if err != nil {
 		return nil
 	}
 
+	// The expression compiled in the test's package, so an unexported field it names is
+	// visible there; read it from a copy, as StructFields does
+	if !field.CanInterface() {
+		copied := Addressable(val)
+		field, err = copied.FieldByIndexErr(structField.Index)
+		if err != nil {
+			return nil
+		}
+		field = Settable(field)
+	}
 	return field.Interface()
 }
 
-func callMethod(obj interface{}, methodName string) interface{} {
+// callMethod calls the exported method methodName of obj with the values of args and returns
+// its results. ok is false when there is no such method or it does not take the arguments.
+// Like Go, it looks in the method sets of both obj and a pointer to it, so that pointer
+// methods of struct copies are found too. spread is set for calls ending in "...". The call
+// is sandboxed: failure describes a call that panicked or timed out, which has no results.
+func callMethod(obj interface{}, methodName string, args []*EvaluationTree, spread bool) (results []interface{}, failure string, ok bool) {
 	if obj == nil {
-		return nil
+		return nil, "", false
 	}
 
 	val := reflect.ValueOf(obj)
 	method := val.MethodByName(methodName)
+	if !method.IsValid() && val.Kind() != reflect.Ptr {
+		// Methods with pointer receivers are called through &x in the source, which compiled,
+		// so x was addressable there; call them on an addressable copy
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		method = ptr.MethodByName(methodName)
+	}
 	if !method.IsValid() {
-		return nil
+		return nil, "", false
 	}
-
-	// Call method with no arguments (simplified)
-	results := method.Call(nil)
-	if len(results) > 0 {
-		return results[0].Interface()
+	in, ok := methodArgs(method.Type(), args, spread)
+	if !ok {
+		return nil, "", false
 	}
 
-	return nil
+	// The call may panic where the test's did not, e.g. through a nil embedded pointer of a
+	// value that changed since, or never return
+	out, failure := sandboxed(func() []reflect.Value {
+		if spread {
+			return method.CallSlice(in)
+		}
+		return method.Call(in)
+	})
+	if failure != "" {
+		return nil, failure, true
+	}
+	results = make([]interface{}, len(out))
+	for i, result := range out {
+		results[i] = result.Interface()
+	}
+	return results, "", true
 }
 
 func getIndexValue(obj, index interface{}) interface{} {
@@ -521,7 +1342,11 @@ func getIndexValue(obj, index interface{}) interface{} {
 	}
 
 	val := reflect.ValueOf(obj)
-	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array && val.Kind() != reflect.Map {
+	if val.Kind() == reflect.Ptr && val.Type().Elem().Kind() == reflect.Array && !val.IsNil() {
+		// Pointers to arrays are indexed through, as in Go
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array && val.Kind() != reflect.Map && val.Kind() != reflect.String {
 		return nil
 	}
 
@@ -555,6 +1380,11 @@ func getIndexValue(obj, index interface{}) interface{} {
 		return nil
 	}
 
+	// Indexing a string yields a byte, as in Go
+	if val.Kind() == reflect.String {
+		return val.String()[idx]
+	}
+
 	return val.Index(idx).Interface()
 }
 
@@ -565,7 +1395,7 @@ func buildSliceTree(slice *ast.SliceExpr, variables map[string]interface{}, fset
 	var lowTree, highTree, maxTree *EvaluationTree
 	var text strings.Builder
 
-	text.WriteString(baseTree.Text)
+	text.WriteString(baseText(slice.X, baseTree))
 	text.WriteString("[")
 
 	if slice.Low != nil {
@@ -621,7 +1451,6 @@ func buildSliceTree(slice *ast.SliceExpr, variables map[string]interface{}, fset
 	}
 
 	return &EvaluationTree{
-		ID:       getNextNodeID(),
 		Type:     "slice",
 		Children: children,
 		Value:    value,
@@ -736,7 +1565,6 @@ func buildArrayTypeTree(arrayType *ast.ArrayType, variables map[string]interface
 	}
 
 	return &EvaluationTree{
-		ID:   getNextNodeID(),
 		Type: "array_type",
 		Text: text.String(),
 	}
@@ -748,7 +1576,7 @@ func buildCompositeLitTree(comp *ast.CompositeLit, variables map[string]interfac
 	var text strings.Builder
 
 	if comp.Type != nil {
-		typeTree := buildTreeFromAST(comp.Type, variables, fset)
+		typeTree := buildTypeTree(comp.Type, fset)
 		children = append(children, typeTree)
 		text.WriteString(typeTree.Text)
 	}
@@ -767,7 +1595,6 @@ func buildCompositeLitTree(comp *ast.CompositeLit, variables map[string]interfac
 	text.WriteString("}")
 
 	return &EvaluationTree{
-		ID:       getNextNodeID(),
 		Type:     "composite_lit",
 		Children: children,
 		Text:     text.String(),
@@ -777,7 +1604,6 @@ func buildCompositeLitTree(comp *ast.CompositeLit, variables map[string]interfac
 // buildFuncLitTree builds tree for function literals.
 func buildFuncLitTree(funcLit *ast.FuncLit, variables map[string]interface{}, fset *token.FileSet) *EvaluationTree {
 	return &EvaluationTree{
-		ID:   getNextNodeID(),
 		Type: "func_lit",
 		Text: "func(...) {...}",
 	}
@@ -795,13 +1621,23 @@ func buildTypeAssertTree(typeAssert *ast.TypeAssertExpr, variables map[string]in
 		typeText = "type" // for x.(type) in type switches
 	}
 
-	text := fmt.Sprintf("%s.(%s)", baseTree.Text, typeText)
+	text := fmt.Sprintf("%s.(%s)", baseText(typeAssert.X, baseTree), typeText)
+
+	// A failed assertion would have panicked before the test reached Assert, so the value
+	// asserted is the interface's own, and fields and methods of it can be read further on
+	var value interface{}
+	var result bool
+	if typeAssert.Type != nil && baseTree.Value != nil && !isPlaceholder(baseTree.Value) {
+		value = baseTree.Value
+		result = isTruthy(value)
+	}
 
 	return &EvaluationTree{
-		ID:   getNextNodeID(),
-		Type: "type_assert",
-		Left: baseTree,
-		Text: text,
+		Type:   "type_assert",
+		Left:   baseTree,
+		Value:  value,
+		Result: result,
+		Text:   text,
 	}
 }
 
@@ -811,22 +1647,26 @@ func buildStarExprTree(star *ast.StarExpr, variables map[string]interface{}, fse
 
 	var value interface{}
 	var result bool
+	var note string
 
-	if baseTree.Value != nil {
+	// Each star follows one pointer, so **pp reads through two
+	if isNilPointer(baseTree.Value) {
+		note = fmt.Sprintf("%s is nil — cannot dereference it", baseTree.Text)
+	} else if baseTree.Value != nil {
 		val := reflect.ValueOf(baseTree.Value)
-		if val.Kind() == reflect.Ptr && !val.IsNil() {
+		if val.Kind() == reflect.Ptr {
 			value = val.Elem().Interface()
 			result = isTruthy(value)
 		}
 	}
 
 	return &EvaluationTree{
-		ID:     getNextNodeID(),
 		Type:   "dereference",
 		Left:   baseTree,
 		Value:  value,
 		Result: result,
-		Text:   fmt.Sprintf("*%s", baseTree.Text),
+		Text:   fmt.Sprintf("*%s", baseText(star.X, baseTree)),
+		Note:   note,
 	}
 }
 
@@ -853,14 +1693,11 @@ func extractVariableValuesFromFrame(expr string, callerFrame uintptr) map[string
 	variables := make(map[string]interface{})
 
 	// Parse expression to find variable names
-	node, err := parser.ParseExpr(expr)
+	vars, err := placeholders(expr)
 	if err != nil {
 		return variables
 	}
 
-	// Extract variable names from AST
-	varNames := extractVariableNames(node)
-
 	// Get function info
 	fn := runtime.FuncForPC(callerFrame)
 	if fn == nil {
@@ -869,10 +1706,10 @@ func extractVariableValuesFromFrame(expr string, callerFrame uintptr) map[string
 
 	// Try to extract variable values using runtime introspection
 	// This is a simplified implementation - real stack inspection is very complex
-	for _, name := range varNames {
+	for _, v := range vars {
 		// For demonstration, we'll use a placeholder approach
 		// In a real implementation, this would require deep runtime introspection
-		variables[name] = fmt.Sprintf("<%s>", name)
+		variables[v.name] = v.value
 	}
 
 	return variables
@@ -893,6 +1730,18 @@ func extractVariableNames(node ast.Expr) []string {
 	var names []string
 
 	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			// Generic functions and types are not values, nor are their type arguments
+			return !isInstantiation(n)
+		case *ast.IndexListExpr:
+			return false
+		case *ast.CompositeLit:
+			for _, elt := range n.Elts {
+				names = append(names, extractVariableNames(elt)...)
+			}
+			return false
+		}
 		if ident, ok := n.(*ast.Ident); ok {
 			// Skip built-in identifiers
 			if ident.Name != "true" && ident.Name != "false" && ident.Name != "nil" {
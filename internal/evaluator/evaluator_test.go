@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"errors"
+	"fmt"
+	"go/ast"
 	"go/parser"
+	"reflect"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestEvaluate(t *testing.T) {
@@ -94,8 +99,8 @@ func TestBuildEvaluationTree(t *testing.T) {
 				return
 			}
 
-			if tree.ID == 0 {
-				t.Error("Tree node should have non-zero ID")
+			if tree.ID == "" {
+				t.Error("Tree node should have an ID")
 			}
 
 			if tree.Type == "" {
@@ -278,3 +283,724 @@ func TestMergeVariables(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLiteral_NumericNotations(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected interface{}
+	}{
+		{"42", 42},
+		{"0x1F", 31},
+		{"0X1f", 31},
+		{"0b1010", 10},
+		{"0o755", 493},
+		{"0755", 493},
+		{"1_000_000", 1000000},
+		{"1.5", 1.5},
+		{"1e3", 1000.0},
+		{"0x1p-2", 0.25},
+		{"18446744073709551615", uint64(18446744073709551615)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			node, err := parser.ParseExpr(tt.literal)
+			if err != nil {
+				t.Fatalf("Failed to parse literal: %v", err)
+			}
+
+			lit, ok := node.(*ast.BasicLit)
+			if !ok {
+				t.Fatalf("Expected *ast.BasicLit, got %T", node)
+			}
+
+			if value := parseLiteral(lit); value != tt.expected {
+				t.Errorf("parseLiteral(%s) = %v (%T), expected %v (%T)", tt.literal, value, value, tt.expected, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildEvaluationTree_NonDecimalLiteralComparison(t *testing.T) {
+	variables := map[string]interface{}{"perm": 0o644}
+	tree := buildEvaluationTree("perm == 0o755", variables)
+
+	if tree.Result {
+		t.Error("Expected 0o644 == 0o755 to evaluate to false")
+	}
+	if tree.Right == nil || tree.Right.Value != 493 {
+		t.Errorf("Expected literal 0o755 to evaluate to 493, got %v", tree.Right)
+	}
+	if tree.Right.Text != "0o755" {
+		t.Errorf("Expected literal text to be preserved, got %q", tree.Right.Text)
+	}
+
+	tree = buildEvaluationTree("flags == 0x1F", map[string]interface{}{"flags": 31})
+	if !tree.Result {
+		t.Error("Expected 31 == 0x1F to evaluate to true")
+	}
+}
+
+func TestBuildEvaluationTree_ArithmeticValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		expr      string
+		variables map[string]interface{}
+		expected  interface{}
+	}{
+		{"addition", "a + b", map[string]interface{}{"a": 10, "b": 20}, 30},
+		{"subtraction", "a - 5", map[string]interface{}{"a": 10}, 5},
+		{"integer division", "a / b", map[string]interface{}{"a": 7, "b": 2}, 3},
+		{"modulo", "a % 3", map[string]interface{}{"a": 10}, 1},
+		{"float multiplication", "f * 2", map[string]interface{}{"f": 1.5}, 3.0},
+		{"typed operand wins", "n + 1", map[string]interface{}{"n": int64(41)}, int64(42)},
+		{"unsigned", "u << 2", map[string]interface{}{"u": uint8(3)}, uint8(12)},
+		{"shift keeps the left type", "1 << n", map[string]interface{}{"n": uint(3)}, 8},
+		{"shift truncates to the left type", "b << n", map[string]interface{}{"b": uint8(0x81), "n": 1}, uint8(2)},
+		{"constant converts to the operand type", "u + 1<<63", map[string]interface{}{"u": uint64(1)}, uint64(1<<63 + 1)},
+		{"constants fold exactly", "1<<63 - 1", nil, int(1<<63 - 1)},
+		{"constants past int", "1 << 63", nil, uint64(1 << 63)},
+		{"bitwise and", "flags & 0x0F", map[string]interface{}{"flags": 0xFF}, 15},
+		{"string concatenation", "s + \"!\"", map[string]interface{}{"s": "hi"}, "hi!"},
+		{"division by zero", "a / b", map[string]interface{}{"a": 1, "b": 0}, nil},
+		{"unknown operand", "a + b", map[string]interface{}{"a": "<a>", "b": 2}, nil},
+		{"len builtin", "len(items)", map[string]interface{}{"items": []int{1, 2, 3}}, 3},
+		{"cap builtin", "cap(items)", map[string]interface{}{"items": make([]int, 1, 4)}, 4},
+		{"len of placeholder", "len(items)", map[string]interface{}{"items": "<items>"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, tt.variables)
+			if tree.Value != tt.expected {
+				t.Errorf("buildEvaluationTree(%q).Value = %v (%T), expected %v (%T)",
+					tt.expr, tree.Value, tree.Value, tt.expected, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildEvaluationTree_UntypedConstantOperand(t *testing.T) {
+	tree := buildEvaluationTree("u == 1<<63", map[string]interface{}{"u": uint64(1)})
+	if tree.Result {
+		t.Error("Expected u == 1<<63 to evaluate to false")
+	}
+	if tree.Right.Value != uint64(1<<63) {
+		t.Errorf("Expected 1<<63 to take the type of u, got %v (%T)", tree.Right.Value, tree.Right.Value)
+	}
+	if tree.Note != "" {
+		t.Errorf("Expected no note, got %q", tree.Note)
+	}
+
+	tree = buildEvaluationTree("u == 1<<63", map[string]interface{}{"u": uint64(1 << 63)})
+	if !tree.Result {
+		t.Error("Expected u == 1<<63 to evaluate to true")
+	}
+}
+
+func TestBuildEvaluationTree_ArithmeticComparison(t *testing.T) {
+	tree := buildEvaluationTree("a + b == 30", map[string]interface{}{"a": 10, "b": 20})
+	if !tree.Result {
+		t.Error("Expected a + b == 30 to evaluate to true")
+	}
+
+	tree = buildEvaluationTree("len(items) > 2", map[string]interface{}{"items": []string{"x"}})
+	if tree.Result {
+		t.Error("Expected len(items) > 2 to evaluate to false")
+	}
+	if tree.Left.Text != "len(items)" {
+		t.Errorf("Expected call text %q, got %q", "len(items)", tree.Left.Text)
+	}
+}
+
+func TestBuildEvaluationTree_NumericKinds(t *testing.T) {
+	type userID int64
+
+	tests := []struct {
+		name   string
+		expr   string
+		vars   map[string]interface{}
+		result bool
+		note   string
+	}{
+		{"int and float64", "got == want", map[string]interface{}{"got": 3, "want": 3.0}, true,
+			"got == want: comparing int (3) with float64 (3.0) — types differ"},
+		{"int and int64", "got != want", map[string]interface{}{"got": 7, "want": int64(8)}, true,
+			"got != want: comparing int (7) with int64 (8) — types differ"},
+		{"named kind", "id == 42", map[string]interface{}{"id": userID(42)}, true, ""},
+		{"duration", "timeout > limit", map[string]interface{}{"timeout": 2 * time.Second, "limit": time.Second}, true, ""},
+		{"same type", "a == b", map[string]interface{}{"a": 1.5, "b": 2.5}, false, ""},
+		{"literal operand", "x == 3", map[string]interface{}{"x": 3.0}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, tt.vars)
+			if tree.Result != tt.result {
+				t.Errorf("Result = %v, want %v", tree.Result, tt.result)
+			}
+			if tree.Note != tt.note {
+				t.Errorf("Note = %q, want %q", tree.Note, tt.note)
+			}
+		})
+	}
+}
+
+func TestParseLiteral_Runes(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected rune
+	}{
+		{`'a'`, 'a'},
+		{`'\n'`, '\n'},
+		{`'\''`, '\''},
+		{`'\x41'`, 'A'},
+		{`'é'`, 'é'},
+		{`'\u00e9'`, 'é'},
+		{`'日'`, '日'},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			node, err := parser.ParseExpr(tt.literal)
+			if err != nil {
+				t.Fatalf("Failed to parse literal: %v", err)
+			}
+
+			if value := parseLiteral(node.(*ast.BasicLit)); value != tt.expected {
+				t.Errorf("parseLiteral(%s) = %v, expected %v", tt.literal, value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildEvaluationTree_StringIndex(t *testing.T) {
+	tree := buildEvaluationTree("s[0] == 'h'", map[string]interface{}{"s": "hello"})
+
+	if tree.Left.Value != byte('h') {
+		t.Errorf("Expected s[0] to evaluate to byte 'h', got %v (%T)", tree.Left.Value, tree.Left.Value)
+	}
+	if !tree.Result {
+		t.Error("Expected s[0] == 'h' to evaluate to true")
+	}
+
+	tree = buildEvaluationTree("s[0] == 'h'", map[string]interface{}{"s": "<s>"})
+	if tree.Left.Value != nil {
+		t.Errorf("Indexing a placeholder should yield no value, got %v", tree.Left.Value)
+	}
+}
+
+func TestBuildEvaluationTree_ShortCircuit(t *testing.T) {
+	tests := []struct {
+		name         string
+		expr         string
+		variables    map[string]interface{}
+		notEvaluated []string // Texts of nodes expected to be skipped
+	}{
+		{
+			name:         "and chain stops at first false",
+			expr:         "a && b && c",
+			variables:    map[string]interface{}{"a": false, "b": true, "c": true},
+			notEvaluated: []string{"b", "c"},
+		},
+		{
+			name:         "or stops at first true",
+			expr:         "a || b",
+			variables:    map[string]interface{}{"a": true, "b": false},
+			notEvaluated: []string{"b"},
+		},
+		{
+			name:         "comparison on the left decides",
+			expr:         "x > 10 && y < 5",
+			variables:    map[string]interface{}{"x": 3, "y": 1},
+			notEvaluated: []string{"y < 5", "y", "5"},
+		},
+		{
+			name:         "no short circuit when left does not decide",
+			expr:         "a && b",
+			variables:    map[string]interface{}{"a": true, "b": false},
+			notEvaluated: nil,
+		},
+		{
+			name:         "placeholders never decide",
+			expr:         "a && b",
+			variables:    map[string]interface{}{"a": "<a>", "b": "<b>"},
+			notEvaluated: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, tt.variables)
+
+			var skipped []string
+			var walk func(node *EvaluationTree)
+			walk = func(node *EvaluationTree) {
+				if node == nil {
+					return
+				}
+				if node.NotEvaluated {
+					skipped = append(skipped, node.Text)
+				}
+				walk(node.Left)
+				walk(node.Right)
+			}
+			walk(tree)
+
+			if len(skipped) != len(tt.notEvaluated) {
+				t.Fatalf("Expected not evaluated nodes %v, got %v", tt.notEvaluated, skipped)
+			}
+			for i := range skipped {
+				if skipped[i] != tt.notEvaluated[i] {
+					t.Errorf("Expected not evaluated nodes %v, got %v", tt.notEvaluated, skipped)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestFindFailingNode(t *testing.T) {
+	tests := []struct {
+		name      string
+		expr      string
+		variables map[string]interface{}
+		expected  string // Text of the failing node, "" for none
+	}{
+		{
+			name:      "simple comparison",
+			expr:      "x > 20",
+			variables: map[string]interface{}{"x": 10},
+			expected:  "x > 20",
+		},
+		{
+			name:      "second operand of and fails",
+			expr:      "name != \"\" && age >= 18",
+			variables: map[string]interface{}{"name": "Al", "age": 16},
+			expected:  "age >= 18",
+		},
+		{
+			name:      "nested and chain",
+			expr:      "a && (b && c > 1)",
+			variables: map[string]interface{}{"a": true, "b": true, "c": 0},
+			expected:  "c > 1",
+		},
+		{
+			name:      "or reports the whole disjunction",
+			expr:      "a || b",
+			variables: map[string]interface{}{"a": false, "b": false},
+			expected:  "a || b",
+		},
+		{
+			name:      "placeholders give no cause",
+			expr:      "x > 20",
+			variables: map[string]interface{}{"x": "<x>"},
+			expected:  "",
+		},
+		{
+			name:      "passing expression has no cause",
+			expr:      "x > 20",
+			variables: map[string]interface{}{"x": 30},
+			expected:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := FindFailingNode(buildEvaluationTree(tt.expr, tt.variables))
+
+			var got string
+			if node != nil {
+				got = node.Text
+			}
+			if got != tt.expected {
+				t.Errorf("FindFailingNode(%q) = %q, expected %q", tt.expr, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestBuildEvaluationTree_ModernSyntaxCorpus guards against diagnostics silently degrading
+// as users adopt newer language features: no node may fall into the "unknown" bucket.
+func TestBuildEvaluationTree_ModernSyntaxCorpus(t *testing.T) {
+	tests := []struct {
+		name         string
+		expr         string
+		expectedText string
+	}{
+		{"generic function call", "Max[int](a, b) == 3", "Max[int](a, b) == 3"},
+		{"generic call with several type args", "Convert[int, string](x) != \"\"", "Convert[int, string](x) != \"\""},
+		{"generic type composite literal", "Pair[int, string]{1, \"a\"} == p", "Pair[int, string]{1, \"a\"} == p"},
+		{"generic method value", "list.Len() == 0", "list.Len() == 0"},
+		{"min builtin", "min(a, b, c) > 0", "min(a, b, c) > 0"},
+		{"max builtin", "max(a, b) < 10", "max(a, b) < 10"},
+		{"clear-style builtin call", "len(m) == 0", "len(m) == 0"},
+		{"range-over-func result captured into var", "len(collected) == count", "len(collected) == count"},
+		{"variadic spread", "Sum(nums...) == 6", "Sum(nums...) == 6"},
+		{"keyed composite literal", "p == Point{X: 1, Y: 2}", "p == Point{X: 1, Y: 2}"},
+		{"map type conversion", "len(map[string]int{}) == 0", "len(map[string]int{}) == 0"},
+		{"channel type", "cap(make(chan int, 1)) == 1", "cap(make(chan int, 1)) == 1"},
+		{"any type assertion", "v.(any) != nil", "v.(any) != nil"},
+		{"interface type assertion", "v.(interface{ Len() int }) != nil", "v.(interface{ Len() int }) != nil"},
+		{"array with inferred length", "len([...]int{1, 2}) == 2", "len([...]int{1, 2}) == 2"},
+		{"func literal call", "func() bool { return ok }()", "func(...) {...}()"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, map[string]interface{}{})
+
+			var walk func(node *EvaluationTree)
+			walk = func(node *EvaluationTree) {
+				if node == nil {
+					return
+				}
+				if node.Type == "unknown" || node.Type == "error" {
+					t.Errorf("Node %q of %q fell into the %q bucket", node.Text, tt.expr, node.Type)
+				}
+				walk(node.Left)
+				walk(node.Right)
+				for _, child := range node.Children {
+					walk(child)
+				}
+			}
+			walk(tree)
+
+			if tree.Text != tt.expectedText {
+				t.Errorf("Tree text = %q, expected %q", tree.Text, tt.expectedText)
+			}
+		})
+	}
+}
+
+type pointerCounter struct{ n int }
+
+func (c *pointerCounter) Empty() bool { return c.n == 0 }
+
+func TestBuildEvaluationTree_PointerReceiverMethod(t *testing.T) {
+	tree := buildEvaluationTree("c.Empty()", map[string]interface{}{"c": pointerCounter{n: 2}})
+	if tree.Value != false {
+		t.Errorf("Expected c.Empty() to be called through &c and return false, got %v", tree.Value)
+	}
+}
+
+type methodLimit int
+
+func (l *methodLimit) Reached() bool { return *l >= 10 }
+
+type methodInner struct{ n int }
+
+func (i *methodInner) Count() int { return i.n }
+
+type methodOuter struct {
+	*methodInner
+	Tags []string
+}
+
+func (o *methodOuter) HasTags(tags ...string) bool { return len(o.Tags) > 0 }
+
+func (o methodOuter) Tag(i int) string { return o.Tags[i] }
+
+func TestCallMethod(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      interface{}
+		method   string
+		expected interface{}
+	}{
+		{"pointer method of a named non-struct copy", methodLimit(12), "Reached", true},
+		{"pointer method promoted through an embedded pointer", methodOuter{methodInner: &methodInner{n: 3}}, "Count", 3},
+		{"variadic method called without arguments", methodOuter{Tags: []string{"a"}}, "HasTags", true},
+		{"panic through a nil embedded pointer", methodOuter{}, "Count", nil},
+		{"method with parameters called without arguments", methodOuter{Tags: []string{"a"}}, "Tag", nil},
+		{"missing method", methodOuter{}, "Missing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got interface{}
+			if results, _, _ := callMethod(tt.obj, tt.method, nil, false); len(results) > 0 {
+				got = results[0]
+			}
+			if got != tt.expected {
+				t.Errorf("callMethod(%T, %s) = %v, want %v", tt.obj, tt.method, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCallBuiltin_MinMax(t *testing.T) {
+	tests := []struct {
+		expr      string
+		variables map[string]interface{}
+		expected  interface{}
+	}{
+		{"min(a, b, c)", map[string]interface{}{"a": 3, "b": 1, "c": 2}, 1},
+		{"max(a, b)", map[string]interface{}{"a": 3, "b": 7}, 7},
+		{"max(f, 2)", map[string]interface{}{"f": 1.5}, 2},
+		{"min(s, t)", map[string]interface{}{"s": "b", "t": "a"}, "a"},
+		{"min(a, b)", map[string]interface{}{"a": 1, "b": "<b>"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, tt.variables)
+			if tree.Value != tt.expected {
+				t.Errorf("buildEvaluationTree(%q).Value = %v, expected %v", tt.expr, tree.Value, tt.expected)
+			}
+		})
+	}
+}
+
+type nilChainDB struct{ Host string }
+
+func (db nilChainDB) Ready() bool { return db.Host != "" }
+
+func (db *nilChainDB) Ping() bool { return db != nil }
+
+type nilChainConfig struct{ DB *nilChainDB }
+
+func TestBuildEvaluationTree_NilChain(t *testing.T) {
+	tests := []struct {
+		name         string
+		expr         string
+		variables    map[string]interface{}
+		expectedNote string
+	}{
+		{
+			name:         "nil pointer in the middle of a chain",
+			expr:         "cfg.DB.Host != \"\"",
+			variables:    map[string]interface{}{"cfg": nilChainConfig{}},
+			expectedNote: "cfg.DB is nil — cannot access Host",
+		},
+		{
+			name:         "nil pointer at the root",
+			expr:         "cfg.DB != nil",
+			variables:    map[string]interface{}{"cfg": (*nilChainConfig)(nil)},
+			expectedNote: "cfg is nil — cannot access DB",
+		},
+		{
+			name:         "captured untyped nil",
+			expr:         "cfg.DB != nil",
+			variables:    map[string]interface{}{"cfg": nil},
+			expectedNote: "cfg is nil — cannot access DB",
+		},
+		{
+			name:         "value receiver method on nil pointer",
+			expr:         "cfg.DB.Ready()",
+			variables:    map[string]interface{}{"cfg": nilChainConfig{}},
+			expectedNote: "cfg.DB is nil — cannot call Ready()",
+		},
+		{
+			name:         "nil pointer dereferenced",
+			expr:         "(*cfg.DB).Host != \"\"",
+			variables:    map[string]interface{}{"cfg": nilChainConfig{}},
+			expectedNote: "cfg.DB is nil — cannot dereference it",
+		},
+		{
+			name:         "pointer receiver method accepts nil",
+			expr:         "cfg.DB.Ping()",
+			variables:    map[string]interface{}{"cfg": nilChainConfig{}},
+			expectedNote: "",
+		},
+		{
+			name:         "unknown base is not reported",
+			expr:         "cfg.DB.Host != \"\"",
+			variables:    map[string]interface{}{},
+			expectedNote: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, tt.variables)
+
+			var notes []string
+			var walk func(node *EvaluationTree)
+			walk = func(node *EvaluationTree) {
+				if node == nil {
+					return
+				}
+				walk(node.Left)
+				walk(node.Right)
+				if node.Note != "" {
+					notes = append(notes, node.Note)
+				}
+			}
+			walk(tree)
+
+			if tt.expectedNote == "" {
+				if len(notes) != 0 {
+					t.Errorf("Expected no notes, got %v", notes)
+				}
+				return
+			}
+			if len(notes) != 1 || notes[0] != tt.expectedNote {
+				t.Errorf("Expected note %q, got %v", tt.expectedNote, notes)
+			}
+		})
+	}
+}
+
+type embeddedDB struct {
+	*nilChainDB
+}
+
+func TestBuildEvaluationTree_MultiLevelPointers(t *testing.T) {
+	db := &nilChainDB{Host: "db"}
+	pdb := &db
+	dbs := &[]*nilChainDB{db}
+	hosts := &[2]string{"a", "b"}
+
+	tests := []struct {
+		expr      string
+		variables map[string]interface{}
+		text      string
+		expected  interface{}
+	}{
+		{"(*pdb).Host", map[string]interface{}{"pdb": pdb}, "(*pdb).Host", "db"},
+		{"(**pdb).Host", map[string]interface{}{"pdb": pdb}, "(**pdb).Host", "db"},
+		{"(*dbs)[0].Host", map[string]interface{}{"dbs": dbs}, "(*dbs)[0].Host", "db"},
+		{"hosts[1]", map[string]interface{}{"hosts": hosts}, "hosts[1]", "b"},
+		{"e.Host", map[string]interface{}{"e": embeddedDB{}}, "e.Host", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, tt.variables)
+			if tree.Text != tt.text {
+				t.Errorf("Text = %q, want %q", tree.Text, tt.text)
+			}
+			if tree.Value != tt.expected {
+				t.Errorf("Value = %v, want %v", tree.Value, tt.expected)
+			}
+		})
+	}
+}
+
+var errTest = errors.New("test error")
+
+type typedNilError struct{}
+
+func (*typedNilError) Error() string { return "typed nil" }
+
+func TestEvaluateWithValues_NilComparisons(t *testing.T) {
+	var nilUser *nilChainDB
+	var typedNil error = (*typedNilError)(nil)
+
+	tests := []struct {
+		name         string
+		expr         string
+		actual       bool
+		userValues   map[string]interface{}
+		expectResult bool
+		expectNote   string
+	}{
+		{
+			name:         "nil pointer compared with != nil",
+			expr:         "p != nil",
+			userValues:   map[string]interface{}{"p": nilUser},
+			expectResult: false,
+			expectNote:   "p is (*evaluator.nilChainDB)(nil)",
+		},
+		{
+			name:         "interface wrapping nil pointer",
+			expr:         "err == nil",
+			userValues:   map[string]interface{}{"err": typedNil},
+			expectResult: false,
+			expectNote:   "err: interface holds (*evaluator.typedNilError)(nil) — non-nil interface wrapping nil pointer",
+		},
+		{
+			name:         "interface wrapping nil pointer inside a chain",
+			expr:         "ok && err == nil",
+			userValues:   map[string]interface{}{"ok": true, "err": typedNil},
+			expectResult: false,
+			expectNote:   "err: interface holds (*evaluator.typedNilError)(nil) — non-nil interface wrapping nil pointer",
+		},
+		{
+			name:         "non-nil error",
+			expr:         "err == nil",
+			userValues:   map[string]interface{}{"err": errTest},
+			expectResult: false,
+			expectNote:   "err is a non-nil *errors.errorString: test error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, _, _, _ := runtime.Caller(0)
+			result := EvaluateWithValues(tt.expr, tt.actual, pc, tt.userValues)
+
+			if result.Tree.Result != tt.expectResult {
+				t.Errorf("Tree result = %v, expected %v", result.Tree.Result, tt.expectResult)
+			}
+
+			var notes []string
+			var walk func(node *EvaluationTree)
+			walk = func(node *EvaluationTree) {
+				if node == nil {
+					return
+				}
+				walk(node.Left)
+				walk(node.Right)
+				if node.Note != "" {
+					notes = append(notes, node.Note)
+				}
+			}
+			walk(result.Tree)
+
+			if len(notes) != 1 || notes[0] != tt.expectNote {
+				t.Errorf("Expected note %q, got %v", tt.expectNote, notes)
+			}
+		})
+	}
+}
+
+func TestDescribeNilOperand_PointerAddress(t *testing.T) {
+	db := &nilChainDB{}
+	note := describeNilOperand(&EvaluationTree{Text: "db", Value: db})
+
+	expected := fmt.Sprintf("db is (*evaluator.nilChainDB)(%p) — non-nil pointer", db)
+	if note != expected {
+		t.Errorf("describeNilOperand() = %q, expected %q", note, expected)
+	}
+}
+
+func TestBuildEvaluationTree_PredeclaredBooleans(t *testing.T) {
+	tree := buildEvaluationTree("done == true", map[string]interface{}{"done": true})
+	if !tree.Result {
+		t.Error("Expected done == true to evaluate to true")
+	}
+}
+
+type unexportedHolder struct {
+	name string
+	Any  interface{}
+}
+
+func TestBuildEvaluationTree_RootPaths(t *testing.T) {
+	holder := unexportedHolder{name: "ann", Any: unexportedHolder{name: "bob"}}
+	tree := buildEvaluationTree(`h.name == "x" || h.Any.(unexportedHolder).name == "y"`, map[string]interface{}{"h": holder})
+
+	want := map[string]interface{}{
+		"h.name":                        "ann",
+		"h.Any.(unexportedHolder)":      holder.Any,
+		"h.Any.(unexportedHolder).name": "bob",
+	}
+	var walk func(node *EvaluationTree)
+	walk = func(node *EvaluationTree) {
+		if node == nil {
+			return
+		}
+		if expected, ok := want[node.Text]; ok {
+			if !reflect.DeepEqual(node.Value, expected) {
+				t.Errorf("%s = %#v, want %#v", node.Text, node.Value, expected)
+			}
+			delete(want, node.Text)
+		}
+		walk(node.Left)
+		walk(node.Right)
+	}
+	walk(tree)
+	for text := range want {
+		t.Errorf("%s not found in the tree", text)
+	}
+}
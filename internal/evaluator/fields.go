@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// StructFields returns the names and values of the fields of a struct, unexported ones
+// included, in declaration order. Fields tagged `diag:"-"` are left out and those tagged
+// `diag:"redact"` are masked. ok is false for values that are not structs.
+func StructFields(v interface{}) (names []string, values []interface{}, ok bool) {
+	val := reflect.ValueOf(v)
+	if !val.IsValid() || val.Kind() != reflect.Struct {
+		return nil, nil, false
+	}
+
+	val = Addressable(val)
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		tag := ParseFieldTag(field)
+		if tag.Omit || field.Name == "_" {
+			continue
+		}
+		names = append(names, field.Name)
+		if tag.Redact {
+			values = append(values, Redacted(mask))
+			continue
+		}
+		values = append(values, Settable(val.Field(i)).Interface())
+	}
+	return names, values, true
+}
+
+// Addressable returns v, or an addressable copy of it, so that its unexported fields can be
+// read through their addresses.
+func Addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	copied := reflect.New(v.Type()).Elem()
+	copied.Set(v)
+	return copied
+}
+
+// Settable returns a settable view of v, an element of an addressable copy, even when it is
+// an unexported field.
+func Settable(v reflect.Value) reflect.Value {
+	if v.CanSet() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
@@ -0,0 +1,63 @@
+package evaluator
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// predeclaredTypes are the types and constraints every package can name without importing
+// anything.
+var predeclaredTypes = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true, "complex64": true,
+	"complex128": true, "error": true, "float32": true, "float64": true, "int": true,
+	"int8": true, "int16": true, "int32": true, "int64": true, "rune": true, "string": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+}
+
+// isTypeExpr reports whether an expression can only be a type: a predeclared type such as
+// int, a type literal such as []string or map[string]int, a pointer to one or an
+// instantiation of one. Named types cannot be told from values without type checking.
+func isTypeExpr(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return predeclaredTypes[e.Name]
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.StructType:
+		return true
+	case *ast.StarExpr:
+		return isTypeExpr(e.X)
+	case *ast.ParenExpr:
+		return isTypeExpr(e.X)
+	case *ast.IndexExpr:
+		return isInstantiation(e)
+	case *ast.IndexListExpr:
+		return true
+	}
+	return false
+}
+
+// isInstantiation reports whether an index expression instantiates a generic function or
+// type, as Max[int] does, rather than indexing a value: values are never indexed by types.
+// Instantiations with several type arguments, such as Pair[int, string], are IndexListExprs.
+func isInstantiation(index *ast.IndexExpr) bool {
+	return isTypeExpr(index.Index)
+}
+
+// buildInstanceTree builds tree for generic instantiations like "Max[int]" and
+// "Pair[int, string]". The generic function or type is only named and its type arguments
+// are types, so the node carries no value and has no operands to show.
+func buildInstanceTree(node ast.Expr, fset *token.FileSet) *EvaluationTree {
+	return &EvaluationTree{
+		Type: "generic_instance",
+		Text: nodeText(node, fset),
+	}
+}
+
+// buildTypeTree builds tree for an expression in a position that only holds types, such as
+// the type of a composite literal, where an index expression is always an instantiation.
+func buildTypeTree(node ast.Expr, fset *token.FileSet) *EvaluationTree {
+	switch node.(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return buildInstanceTree(node, fset)
+	}
+	return buildTypeExprTree(node, fset)
+}
@@ -0,0 +1,70 @@
+package evaluator
+
+import (
+	"go/ast"
+	"go/parser"
+	"reflect"
+	"testing"
+)
+
+func TestIsInstantiation(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"Max[int]", true},
+		{"Keys[[]string]", true},
+		{"New[*bytes.Buffer]", false}, // Named types need type checking
+		{"Cache[map[string]int]", true},
+		{"Wrap[Pair[int, string]]", true},
+		{"items[0]", false},
+		{"scores[name]", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			node, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := isInstantiation(node.(*ast.IndexExpr)); got != tt.want {
+				t.Errorf("isInstantiation(%s) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildEvaluationTree_Generics(t *testing.T) {
+	variables := map[string]interface{}{"a": 1, "b": 2, "Max[int](a, b)": 2, "p": "<p>"}
+
+	tree := buildEvaluationTree("Max[int](a, b) > 3", variables)
+	call := tree.Left
+	if call.Value != 2 || tree.Result {
+		t.Errorf("The result of a generic call should be read from the values passed by its text, got %v", call.Value)
+	}
+
+	tree = buildEvaluationTree(`p == Pair[string, int]{"a", 2}`, variables)
+	literal := tree.Right
+	if literal.Children[0].Type != "generic_instance" || literal.Children[0].Text != "Pair[string, int]" {
+		t.Errorf("The type of a generic composite literal should be an instance, got %+v", literal.Children[0])
+	}
+	if hints := Hints(&ExpressionResult{Tree: tree, Variables: variables}); !reflect.DeepEqual(hints, []string{`diagassert.V("p", p)`}) {
+		t.Errorf("Generics and their type arguments should not be hinted at, got %v", hints)
+	}
+}
+
+func TestExtractVariableNames_Generics(t *testing.T) {
+	for expr, want := range map[string][]string{
+		"Max[int](a, b) > 0":              {"a", "b"},
+		`Pair[string, User]{"a", u} == p`: {"u", "p"},
+		"Set[User]{} == s":                {"s"},
+		"scores[name] > 0":                {"scores", "name"},
+	} {
+		node, err := parser.ParseExpr(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := extractVariableNames(node); !reflect.DeepEqual(got, want) {
+			t.Errorf("extractVariableNames(%s) = %v, want %v", expr, got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Hints returns the V calls that would let the diagram show the values the evaluation could
+// not read, such as diagassert.V("user.Age", user.Age), in the order they appear in the
+// expression. Each names the largest operand the evaluator looks up by its text: an
+// identifier, a field of one, or a method call. Operands that short-circuiting skipped are
+// left out, since their values do not explain the failure.
+func Hints(result *ExpressionResult) []string {
+	if result == nil {
+		return nil
+	}
+	var hints []string
+	seen := make(map[string]bool)
+	add := func(text string) {
+		if !seen[text] {
+			seen[text] = true
+			hints = append(hints, vCall(text))
+		}
+	}
+
+	var walk func(node *EvaluationTree)
+	walk = func(node *EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+		switch node.Type {
+		case "literal", "error":
+			return
+		case "identifier":
+			if node.Text != "nil" && unresolved(node, result.Variables) {
+				add(node.Text)
+			}
+			return
+		case "selector":
+			if node.Left == nil {
+				// Looked up as a whole, like a named constant
+				return
+			}
+			if node.Left.Type == "identifier" && unresolved(node.Left, result.Variables) {
+				add(node.Text)
+				return
+			}
+		case "method_call":
+			if (node.Note == "" || node.NotReevaluated) && unresolved(node, result.Variables) {
+				add(node.Text)
+				return
+			}
+			if node.Left != nil && node.Left.Type == "identifier" && unresolved(node.Left, result.Variables) {
+				// A call made without its receiver is a package's, as strings.Contains
+				for _, child := range node.Children {
+					walk(child)
+				}
+				return
+			}
+		}
+
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(result.Tree)
+	return hints
+}
+
+// vCall returns the V call passing the value of the operand text under its text. The text
+// is quoted into a buffer on the stack, so the call is the only string made.
+func vCall(text string) string {
+	var quoted [64]byte
+	q := strconv.AppendQuote(quoted[:0], text)
+	var b strings.Builder
+	b.Grow(len("diagassert.V(, )") + len(q) + len(text))
+	b.WriteString("diagassert.V(")
+	b.Write(q)
+	b.WriteString(", ")
+	b.WriteString(text)
+	b.WriteByte(')')
+	return b.String()
+}
+
+// unresolved reports whether a node's value could not be read: it is missing or a
+// placeholder, and no value was passed under the node's text, not even a nil one.
+func unresolved(node *EvaluationTree, variables map[string]interface{}) bool {
+	if node.Text == JSONRoot || (node.Value != nil && !isPlaceholder(node.Value)) {
+		return false
+	}
+	value, ok := variables[node.Text]
+	return !ok || isPlaceholder(value)
+}
@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHints(t *testing.T) {
+	placeholders := func(names ...string) map[string]interface{} {
+		values := make(map[string]interface{})
+		for _, name := range names {
+			values[name] = "<" + name + ">"
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		expr   string
+		values map[string]interface{}
+		want   []string
+	}{
+		{
+			name:   "fields and method calls",
+			expr:   "user.Age >= 18 && user.HasLicense()",
+			values: placeholders("user", "Age", "HasLicense"),
+			want:   []string{`diagassert.V("user.Age", user.Age)`, `diagassert.V("user.HasLicense()", user.HasLicense())`},
+		},
+		{
+			name:   "operands of indexes and builtins",
+			expr:   `len(items) > limit && m["k"] == a.b.c`,
+			values: placeholders("items", "limit", "m", "a"),
+			want:   []string{`diagassert.V("items", items)`, `diagassert.V("limit", limit)`, `diagassert.V("m", m)`, `diagassert.V("a.b", a.b)`},
+		},
+		{
+			name:   "calls quoted as Go strings",
+			expr:   `strings.HasPrefix(name, "x")`,
+			values: placeholders("strings", "name"),
+			want:   []string{`diagassert.V("strings.HasPrefix(name, \"x\")", strings.HasPrefix(name, "x"))`},
+		},
+		{
+			name:   "packages of calls that were made",
+			expr:   `strings.HasPrefix(name, "x") && ok`,
+			values: map[string]interface{}{"strings": "<strings>", "name": "xy", "ok": "<ok>"},
+			want:   []string{`diagassert.V("ok", ok)`},
+		},
+		{
+			name:   "captured values, including nil, need no hint",
+			expr:   "err == nil && count > limit",
+			values: map[string]interface{}{"err": nil, "count": 3, "limit": "<limit>"},
+			want:   []string{`diagassert.V("limit", limit)`},
+		},
+		{
+			name:   "each operand once",
+			expr:   "x > 0 && x < 10",
+			values: placeholders("x"),
+			want:   []string{`diagassert.V("x", x)`},
+		},
+		{
+			name:   "everything known",
+			expr:   "x > 0",
+			values: map[string]interface{}{"x": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluateWithValues(tt.expr, false, 0, tt.values)
+			if got := Hints(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Hints(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHintsSkipShortCircuitedOperands(t *testing.T) {
+	result := EvaluateWithValues("ready && cfg.Enabled", false, 0, map[string]interface{}{"ready": false, "cfg": "<cfg>"})
+	if got := Hints(result); len(got) != 0 {
+		t.Errorf("Operands skipped by && should get no hint, got %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package evaluator
+
+import "strconv"
+
+// ExprID identifies an expression across runs: the same source text always yields the
+// same ID, so machine-readable output from two runs can be matched up.
+func ExprID(expr string) string {
+	return hashID(expr)
+}
+
+// NodeID identifies a node of an expression's tree by the expression and the node's path
+// from the root: "" for the root, then "L" and "R" for operands and "C<i>" for children.
+// Unlike a running counter, it does not change when other assertions are evaluated first.
+func NodeID(expr, path string) string {
+	return hashID(expr + "\x00" + path)
+}
+
+// Fingerprint identifies a failure across runs by the expression, the text of the operand
+// that made it fail and the file and line it was asserted at, file being a base name such
+// as user_test.go so that checkouts in other directories agree.
+func Fingerprint(expr, failingNode, file string, line int) string {
+	return hashID(expr + "\x00" + failingNode + "\x00" + file + ":" + strconv.Itoa(line))
+}
+
+// hashID returns the 32-bit FNV-1a hash of s in hexadecimal, as hash/fnv computes it. Every
+// node of a failure is identified, so s is hashed in place instead of copied into a hash.Hash.
+func hashID(s string) string {
+	const offset, prime = 2166136261, 16777619
+	h := uint32(offset)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= prime
+	}
+	var b [8]byte
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = "0123456789abcdef"[h&0xf]
+		h >>= 4
+	}
+	return string(b[:])
+}
+
+// assignNodeIDs sets the ID of every node in the tree built for expr, identified as
+// IdentityExpression gives it.
+func assignNodeIDs(tree *EvaluationTree, expr string) {
+	expr = IdentityExpression(expr)
+	var walk func(node *EvaluationTree, path string)
+	walk = func(node *EvaluationTree, path string) {
+		if node == nil {
+			return
+		}
+		node.ID = NodeID(expr, path)
+		walk(node.Left, path+"L")
+		walk(node.Right, path+"R")
+		for i, child := range node.Children {
+			walk(child, path+"C"+strconv.Itoa(i))
+		}
+	}
+	walk(tree, "")
+}
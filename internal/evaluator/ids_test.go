@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNodeIDsAreDeterministic(t *testing.T) {
+	collect := func(tree *EvaluationTree) []string {
+		var ids []string
+		var walk func(node *EvaluationTree)
+		walk = func(node *EvaluationTree) {
+			if node == nil {
+				return
+			}
+			ids = append(ids, node.ID)
+			walk(node.Left)
+			walk(node.Right)
+			for _, child := range node.Children {
+				walk(child)
+			}
+		}
+		walk(tree)
+		return ids
+	}
+
+	expr := "x > 10 && len(items) == 3"
+	first := collect(buildEvaluationTree(expr, nil))
+	buildEvaluationTree("a == b || c", nil) // Evaluating another expression must not shift IDs
+	second := collect(buildEvaluationTree(expr, nil))
+
+	if len(first) != len(second) {
+		t.Fatalf("Expected the same tree twice, got %d and %d nodes", len(first), len(second))
+	}
+
+	format := regexp.MustCompile(`^[0-9a-f]{8}$`)
+	seen := map[string]bool{}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("Node %d has ID %s in one run and %s in another", i, first[i], second[i])
+		}
+		if !format.MatchString(first[i]) {
+			t.Errorf("Node ID %q should be 8 hex digits", first[i])
+		}
+		if seen[first[i]] {
+			t.Errorf("Node ID %s is used twice", first[i])
+		}
+		seen[first[i]] = true
+	}
+}
+
+func TestExprID(t *testing.T) {
+	if ExprID("x > 10") != ExprID("x > 10") {
+		t.Error("ExprID should be stable for the same expression")
+	}
+	if ExprID("x > 10") == ExprID("x > 11") {
+		t.Error("ExprID should differ between expressions")
+	}
+	if ExprID("x > 10") == NodeID("x > 10", "") {
+		t.Error("The root node ID should not collide with the expression ID")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint("a && b", "b", "user_test.go", 12)
+	if fp != Fingerprint("a && b", "b", "user_test.go", 12) {
+		t.Error("Fingerprint should be the same for the same failure")
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}$`).MatchString(fp) {
+		t.Errorf("Fingerprint = %q, want 8 hex digits", fp)
+	}
+	for _, other := range []string{
+		Fingerprint("a && b", "a", "user_test.go", 12),
+		Fingerprint("a && b", "b", "user_test.go", 13),
+		Fingerprint("a && b", "b", "order_test.go", 12),
+	} {
+		if other == fp {
+			t.Errorf("Fingerprint should differ by failing operand and location, got %q twice", fp)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JSONRoot names the document in JSON expressions such as "$.items[0].id == 42".
+const JSONRoot = "$"
+
+// jsonRootIdent stands in for JSONRoot when a JSON expression is parsed as Go. The blank
+// identifier can never be read in Go code, so it cannot clash with a real operand, and it
+// has the same length as JSONRoot so every offset is preserved.
+const jsonRootIdent = "_"
+
+// EvaluateJSON evaluates a JSON expression against a document decoded by encoding/json.
+// Members are selected with Go syntax rooted at JSONRoot: "$.items[0].id", "$.meta["x-id"]",
+// len($.items).
+func EvaluateJSON(expr string, doc interface{}) *ExpressionResult {
+	tree := buildEvaluationTree(ParseableExpr(expr), map[string]interface{}{JSONRoot: doc})
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     tree.Result,
+		Tree:       tree,
+	}
+}
+
+// ParseableExpr replaces every JSONRoot outside string and rune literals with the blank
+// identifier so that a JSON expression parses as Go. Go expressions are returned unchanged.
+func ParseableExpr(expr string) string {
+	if !strings.Contains(expr, JSONRoot) {
+		return expr
+	}
+
+	b := []byte(expr)
+	var quote byte
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == JSONRoot[0]:
+			b[i] = jsonRootIdent[0]
+		}
+	}
+	return string(b)
+}
+
+// isJSONRoot reports whether an identifier is the stand-in for the document of a JSON expression.
+func isJSONRoot(name string, variables map[string]interface{}) bool {
+	if name != jsonRootIdent {
+		return false
+	}
+	_, ok := variables[JSONRoot]
+	return ok
+}
+
+// jsonMemberNote explains why selecting a member or element of a decoded JSON value
+// yielded nothing. It returns "" for values that are not JSON objects or arrays.
+func jsonMemberNote(base *EvaluationTree, member interface{}) string {
+	switch doc := base.Value.(type) {
+	case map[string]interface{}:
+		key, ok := member.(string)
+		if _, exists := doc[key]; ok && !exists {
+			return fmt.Sprintf("%s has no member %q", base.Text, key)
+		}
+	case []interface{}:
+		index, ok := member.(int)
+		if ok && (index < 0 || index >= len(doc)) {
+			return fmt.Sprintf("%s has length %d, no index %d", base.Text, len(doc), index)
+		}
+	}
+	return ""
+}
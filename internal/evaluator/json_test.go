@@ -0,0 +1,77 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseableExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"x > 1", "x > 1"},
+		{"$.items[0].id == 42", "_.items[0].id == 42"},
+		{`$.name == "$5" && $.sym != '$'`, `_.name == "$5" && _.sym != '$'`},
+		{"$[\"a\\\"$\"] == `$`", "_[\"a\\\"$\"] == `$`"},
+	}
+
+	for _, tt := range tests {
+		if got := ParseableExpr(tt.expr); got != tt.want {
+			t.Errorf("ParseableExpr(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateJSON(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"items":[{"id":41,"tags":["a"]}],"meta":{"x-id":"7"}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr   string
+		result bool
+		note   string
+	}{
+		{"$.items[0].id == 41", true, ""},
+		{"$.items[0].id == 42", false, ""},
+		{`$.meta["x-id"] == "7"`, true, ""},
+		{"len($.items) == 1 && len($.items[0].tags) > 0", true, ""},
+		{"$.items[3].id == 41", false, "$.items has length 1, no index 3"},
+		{"$.items[0].name == \"x\"", false, `$.items[0] has no member "name"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			result := EvaluateJSON(tt.expr, doc)
+			if result.Result != tt.result {
+				t.Errorf("Result = %v, want %v", result.Result, tt.result)
+			}
+			if result.Expression != tt.expr {
+				t.Errorf("Expression = %q, want %q", result.Expression, tt.expr)
+			}
+
+			var notes []string
+			var walk func(node *EvaluationTree)
+			walk = func(node *EvaluationTree) {
+				if node == nil {
+					return
+				}
+				walk(node.Left)
+				walk(node.Right)
+				if node.Note != "" {
+					notes = append(notes, node.Note)
+				}
+			}
+			walk(result.Tree)
+
+			if tt.note == "" && len(notes) > 0 {
+				t.Errorf("Unexpected notes %q", notes)
+			}
+			if tt.note != "" && (len(notes) != 1 || notes[0] != tt.note) {
+				t.Errorf("Notes = %q, want [%q]", notes, tt.note)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package evaluator
+
+import "fmt"
+
+// EvaluateLength builds the result of checking that value, whose source text is text, is
+// empty, or not empty when empty is false. The expression is "len(text) == 0", or
+// "len(text) != 0", so the diagram shows the value and its length.
+func EvaluateLength(text string, value interface{}, empty bool) *ExpressionResult {
+	operator := "!="
+	if empty {
+		operator = "=="
+	}
+	expr := fmt.Sprintf("len(%s) %s 0", text, operator)
+	tree := buildEvaluationTree(expr, nil)
+
+	if call := tree.Left; tree.Type == "comparison" && call != nil && call.Type == "call" && len(call.Children) == 1 {
+		setLeafValue(call.Children[0], value)
+		call.Value = callBuiltin("len", call.Children)
+		call.Result = call.Value != nil && isTruthy(call.Value)
+		switch {
+		case value == nil:
+			call.Note = fmt.Sprintf("%s is nil", text)
+		case call.Value == nil:
+			call.Note = fmt.Sprintf("%s is of type %T, which has no length", text, value)
+		}
+		tree.Result = call.Value != nil && evaluateBinaryExpr(call, tree.Right, operator)
+	}
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     tree.Result,
+		Variables:  map[string]interface{}{text: value},
+		Tree:       tree,
+	}
+}
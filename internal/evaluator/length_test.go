@@ -0,0 +1,38 @@
+package evaluator
+
+import "testing"
+
+func TestEvaluateLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		empty  bool
+		expr   string
+		length interface{}
+		result bool
+		note   string
+	}{
+		{"non-empty slice", []int{1, 2}, true, "len(o.Items) == 0", 2, false, ""},
+		{"empty map", map[string]int{}, true, "len(o.Items) == 0", 0, true, ""},
+		{"empty string", "", false, "len(o.Items) != 0", 0, false, ""},
+		{"nil", nil, false, "len(o.Items) != 0", nil, false, "o.Items is nil"},
+		{"no length", 42, false, "len(o.Items) != 0", nil, false, "o.Items is of type int, which has no length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluateLength("o.Items", tt.value, tt.empty)
+
+			if result.Expression != tt.expr || result.Result != tt.result {
+				t.Fatalf("Expression = %q, Result = %v, want %q, %v", result.Expression, result.Result, tt.expr, tt.result)
+			}
+			call := result.Tree.Left
+			if call.Value != tt.length || call.Note != tt.note {
+				t.Errorf("len = %v with note %q, want %v with %q", call.Value, call.Note, tt.length, tt.note)
+			}
+			if arg := call.Children[0]; arg.Left != nil || arg.Text != "o.Items" {
+				t.Errorf("The value should be a leaf under its source text, got %+v", arg)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+)
+
+// MatchExplanation is a matcher's account of why it rejected a value.
+type MatchExplanation struct {
+	Matcher  string // Source text of the matcher, e.g. "IsEmail()"
+	Actual   string // Source text of the matched value, e.g. "user.Email"
+	Expected string // What the matcher accepts, in its own words
+	Found    string // What it found instead
+	Details  []string
+}
+
+// EvaluateMatch builds the result of a matcher that rejected actual. The expression is the
+// call that was made, "matcher.DiagMatch(actual)", so the diagram places the value and the
+// matcher under their own source text, and the call node carries the explanation.
+func EvaluateMatch(actualText, matcherText string, actual, matcher interface{}, explanation MatchExplanation) *ExpressionResult {
+	expr := fmt.Sprintf("%s.DiagMatch(%s)", receiverText(matcherText), actualText)
+	tree := buildEvaluationTree(expr, nil)
+
+	explanation.Matcher, explanation.Actual = matcherText, actualText
+	tree.Value, tree.Result = false, false
+	tree.Explanation = &explanation
+	if tree.Type == "method_call" && len(tree.Children) == 1 {
+		setLeafValue(tree.Left, matcher)
+		setLeafValue(tree.Children[0], actual)
+	}
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     false,
+		Variables:  map[string]interface{}{actualText: actual},
+		Tree:       tree,
+	}
+}
+
+// setLeafValue records a value that is known as a whole, dropping the sub-expressions that
+// could only be shown as placeholders.
+func setLeafValue(node *EvaluationTree, value interface{}) {
+	node.Left, node.Right, node.Children = nil, nil, nil
+	node.Value = value
+	node.Result = isTruthy(value)
+	node.Note = ""
+}
+
+// receiverText parenthesizes a matcher expression that cannot be the receiver of a method
+// call as written, such as "*m" or "x + y".
+func receiverText(text string) string {
+	expr, err := parser.ParseExpr(text)
+	if err != nil {
+		return text
+	}
+	switch expr.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.CallExpr, *ast.IndexExpr, *ast.IndexListExpr,
+		*ast.ParenExpr, *ast.CompositeLit, *ast.TypeAssertExpr, *ast.SliceExpr:
+		return text
+	}
+	return "(" + text + ")"
+}
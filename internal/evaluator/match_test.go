@@ -0,0 +1,42 @@
+package evaluator
+
+import "testing"
+
+func TestEvaluateMatch(t *testing.T) {
+	result := EvaluateMatch("u.Email", "IsEmail()", "bob@", "matcher", MatchExplanation{Found: "no domain"})
+
+	if result.Expression != "IsEmail().DiagMatch(u.Email)" || result.Result {
+		t.Fatalf("Expression = %q, Result = %v", result.Expression, result.Result)
+	}
+
+	tree := result.Tree
+	if tree.Type != "method_call" || tree.Explanation == nil {
+		t.Fatalf("Expected a method call carrying the explanation, got %+v", tree)
+	}
+	if e := tree.Explanation; e.Actual != "u.Email" || e.Matcher != "IsEmail()" || e.Found != "no domain" {
+		t.Errorf("Explanation = %+v", e)
+	}
+	if arg := tree.Children[0]; arg.Value != "bob@" || arg.Left != nil {
+		t.Errorf("The matched value should be a leaf holding the value, got %+v", arg)
+	}
+	if FindFailingNode(tree) != tree {
+		t.Error("The matcher call should be the failing node")
+	}
+}
+
+func TestReceiverText(t *testing.T) {
+	tests := map[string]string{
+		"m":               "m",
+		"IsEmail()":       "IsEmail()",
+		"matchers.Email":  "matchers.Email",
+		"Between{1, 5}":   "Between{1, 5}",
+		"*m":              "(*m)",
+		"&Between{Lo: 1}": "(&Between{Lo: 1})",
+		"not valid (":     "not valid (",
+	}
+	for text, want := range tests {
+		if got := receiverText(text); got != want {
+			t.Errorf("receiverText(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
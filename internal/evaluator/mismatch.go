@@ -0,0 +1,63 @@
+package evaluator
+
+// MismatchCause is a possible cause of a diagram that disagrees with the assertion. The
+// formatter words it in the language of the output.
+type MismatchCause struct {
+	Kind     MismatchKind
+	Operands []string // The operands whose values are in doubt, in evaluation order
+}
+
+// MismatchKind tells what may have made a diagram disagree with the assertion.
+type MismatchKind int
+
+// Kinds of mismatch causes.
+const (
+	MismatchUserValues  MismatchKind = iota // Values passed with V or Values for the operands
+	MismatchReevaluated                     // Methods of the operands called again after the assertion
+	MismatchChanged                         // Values changed after the assertion; it has no operands
+)
+
+// Mismatch returns the possible causes of a diagram that disagrees with the assertion: the
+// tree, worked out from the values diagassert could read, gives another result than the
+// boolean the test passed, so some value in the diagram is not the one the expression read.
+// It returns nil when they agree, or when the tree's result rests on values it could not read.
+func Mismatch(result *ExpressionResult) []MismatchCause {
+	if result == nil || result.Tree == nil || result.Tree.Result == result.Result || !HasKnownResult(result.Tree) {
+		return nil
+	}
+
+	var userValues, reevaluated []string
+	seen := make(map[string]bool)
+	var walk func(node *EvaluationTree)
+	walk = func(node *EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+		if seen[node.Text] {
+			return
+		}
+		switch node.Provenance {
+		case ProvenanceUser:
+			seen[node.Text] = true
+			userValues = append(userValues, node.Text)
+		case ProvenanceReevaluated:
+			seen[node.Text] = true
+			reevaluated = append(reevaluated, node.Text)
+		}
+	}
+	walk(result.Tree)
+
+	var causes []MismatchCause
+	if len(userValues) > 0 {
+		causes = append(causes, MismatchCause{Kind: MismatchUserValues, Operands: userValues})
+	}
+	if len(reevaluated) > 0 {
+		causes = append(causes, MismatchCause{Kind: MismatchReevaluated, Operands: reevaluated})
+	}
+	return append(causes, MismatchCause{Kind: MismatchChanged})
+}
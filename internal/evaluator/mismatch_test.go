@@ -0,0 +1,41 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+)
+
+type mismatchCounter struct{ n *int }
+
+func (c mismatchCounter) Next() int { *c.n++; return *c.n }
+
+func TestMismatch(t *testing.T) {
+	x := 10
+	pc := callerPC(x > 20)
+
+	result := EvaluateWithValues("x > 20", x > 20, pc, map[string]interface{}{"x": 30})
+	causes := Mismatch(result)
+	if want := []MismatchCause{{MismatchUserValues, []string{"x"}}, {Kind: MismatchChanged}}; !reflect.DeepEqual(causes, want) {
+		t.Errorf("Mismatch() = %v, want the stale value of x first", causes)
+	}
+
+	result = EvaluateWithValues("x > 20", x > 20, pc, map[string]interface{}{"x": x})
+	if causes := Mismatch(result); causes != nil {
+		t.Errorf("Mismatch() = %v for a consistent diagram, want nil", causes)
+	}
+
+	n := 0
+	c := mismatchCounter{&n}
+	pc = callerPC(c.Next() == 2)
+	result = EvaluateWithValues("c.Next() == 2", c.Next() == 2, pc, map[string]interface{}{"c": c})
+	causes = Mismatch(result)
+	if len(causes) != 3 || !reflect.DeepEqual(causes[1], MismatchCause{MismatchReevaluated, []string{"c.Next()"}}) {
+		t.Errorf("Mismatch() = %v, want the method called again after the value of c", causes)
+	}
+
+	// Placeholders leave the diagram's result unknown, so it cannot contradict the assertion
+	result = Evaluate("y > 20", true, pc)
+	if causes := Mismatch(result); causes != nil {
+		t.Errorf("Mismatch() = %v for an unknown result, want nil", causes)
+	}
+}
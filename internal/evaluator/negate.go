@@ -0,0 +1,7 @@
+package evaluator
+
+// Negate returns the text of the negation of expr, parenthesizing expr where ! would
+// otherwise bind to only part of it: "!strings.Contains(s, x)", "!(a == b)".
+func Negate(expr string) string {
+	return "!" + receiverText(expr)
+}
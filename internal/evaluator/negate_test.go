@@ -0,0 +1,16 @@
+package evaluator
+
+import "testing"
+
+func TestNegate(t *testing.T) {
+	tests := map[string]string{
+		`strings.Contains(s, x)`: `!strings.Contains(s, x)`,
+		`a == b`:                 `!(a == b)`,
+		`ok`:                     `!ok`,
+	}
+	for expr, want := range tests {
+		if got := Negate(expr); got != want {
+			t.Errorf("Negate(%q) = %q, want %q", expr, got, want)
+		}
+	}
+}
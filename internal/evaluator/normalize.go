@@ -0,0 +1,133 @@
+package evaluator
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// IdentityExpression returns the text failures of expr are matched by across runs, in the
+// EXPR field, the expression and node IDs and the fingerprint: expr as written or, with
+// DIAGASSERT_NORMALIZE=true, as NormalizeExpression gives it, so that reformatting an
+// assertion does not make its failure look new. Diagrams show the source either way.
+func IdentityExpression(expr string) string {
+	if config.Getenv("DIAGASSERT_NORMALIZE") == "true" {
+		return NormalizeExpression(expr)
+	}
+	return expr
+}
+
+// NormalizeExpression returns expr as gofmt prints it once the parentheses that change
+// nothing are dropped, so that an assertion reads the same however its source is spaced or
+// parenthesized: "(a>1) && (b)" and "a > 1 && b" both give "a > 1 && b". Expressions that do
+// not parse are returned as they are. Expressions written over several lines are printed on
+// one.
+func NormalizeExpression(expr string) string {
+	fset := token.NewFileSet()
+	root, err := parser.ParseExprFrom(fset, "", expr, 0)
+	if err != nil {
+		return expr
+	}
+	// Every position on the first line, so the printer keeps no line break of the source
+	fset.File(root.Pos()).SetLines([]int{0})
+
+	var b bytes.Buffer
+	if err := format.Node(&b, fset, dropParens(root, contextAny)); err != nil {
+		return expr
+	}
+	return b.String()
+}
+
+// parenContext is where an expression stands, which decides whether parentheses around it
+// can go.
+type parenContext int
+
+const (
+	contextAny     parenContext = iota // Alone, or an argument, index or element
+	contextOperand                     // Operand of a unary operator, or before a selector, call or index
+)
+
+// dropParens returns node with the parentheses that change nothing removed, in place. In a
+// binary expression, parentheses stay around operands that bind less tightly than the
+// operator, or as tightly on its right.
+func dropParens(node ast.Expr, context parenContext) ast.Expr {
+	if paren, ok := node.(*ast.ParenExpr); ok {
+		inner := dropParens(paren.X, context)
+		if context == contextAny || isPrimary(inner) {
+			return inner
+		}
+		paren.X = inner
+		return paren
+	}
+
+	switch n := node.(type) {
+	case *ast.BinaryExpr:
+		n.X = dropOperandParens(n.X, n.Op, false)
+		n.Y = dropOperandParens(n.Y, n.Op, true)
+	case *ast.UnaryExpr:
+		n.X = dropParens(n.X, contextOperand)
+	case *ast.StarExpr:
+		n.X = dropParens(n.X, contextOperand)
+	case *ast.SelectorExpr:
+		n.X = dropParens(n.X, contextOperand)
+	case *ast.CallExpr:
+		n.Fun = dropParens(n.Fun, contextOperand)
+		for i, arg := range n.Args {
+			n.Args[i] = dropParens(arg, contextAny)
+		}
+	case *ast.IndexExpr:
+		n.X = dropParens(n.X, contextOperand)
+		n.Index = dropParens(n.Index, contextAny)
+	case *ast.SliceExpr:
+		n.X = dropParens(n.X, contextOperand)
+		for _, bound := range []*ast.Expr{&n.Low, &n.High, &n.Max} {
+			if *bound != nil {
+				*bound = dropParens(*bound, contextAny)
+			}
+		}
+	case *ast.TypeAssertExpr:
+		n.X = dropParens(n.X, contextOperand)
+	case *ast.CompositeLit:
+		for i, elt := range n.Elts {
+			n.Elts[i] = dropParens(elt, contextAny)
+		}
+	case *ast.KeyValueExpr:
+		n.Key = dropParens(n.Key, contextAny)
+		n.Value = dropParens(n.Value, contextAny)
+	}
+	return node
+}
+
+// dropOperandParens drops the parentheses around an operand of op that change nothing.
+func dropOperandParens(operand ast.Expr, op token.Token, right bool) ast.Expr {
+	paren, ok := operand.(*ast.ParenExpr)
+	if !ok {
+		return dropParens(operand, contextAny)
+	}
+	inner := dropParens(paren.X, contextAny)
+	binary, ok := inner.(*ast.BinaryExpr)
+	if !ok {
+		if _, unary := inner.(*ast.UnaryExpr); unary || isPrimary(inner) {
+			return inner
+		}
+	} else if p := binary.Op.Precedence(); p > op.Precedence() || p == op.Precedence() && !right {
+		return inner
+	}
+	paren.X = inner
+	return paren
+}
+
+// isPrimary reports whether node is an operand or primary expression, which parentheses
+// never group differently.
+func isPrimary(node ast.Expr) bool {
+	switch node.(type) {
+	case *ast.Ident, *ast.BasicLit, *ast.SelectorExpr, *ast.CallExpr, *ast.IndexExpr,
+		*ast.SliceExpr, *ast.TypeAssertExpr:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package evaluator
+
+import "testing"
+
+func TestNormalizeExpression(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"a > 1 && b", "a > 1 && b"},
+		{"(a>1) && (b)", "a > 1 && b"},
+		{"((user.Age >= 18))", "user.Age >= 18"},
+		{"x*2+1 == y", "x*2+1 == y"},
+		{"a &&\n\tb ||\n\tc", "a && b || c"},
+		{"(a && b) && c", "a && b && c"},
+		{"a && (b && c)", "a && (b && c)"},
+		{"(a || b) && c", "(a || b) && c"},
+		{"(a - b) - c", "a - b - c"},
+		{"a - (b - c)", "a - (b - c)"},
+		{"(-x) + y", "-x + y"},
+		{"-(a + b)", "-(a + b)"},
+		{"-(-x) == 1", "-(-x) == 1"},
+		{"!(ok)", "!ok"},
+		{"(*p).Name == \"x\"", "(*p).Name == \"x\""},
+		{"(user).Name == (\"x\")", "user.Name == \"x\""},
+		{"len((items)) > (0)", "len(items) > 0"},
+		{"f((a + b), c)[(i)]", "f(a+b, c)[i]"},
+		{"(*T)(p) != nil", "(*T)(p) != nil"},
+		{"s == `a\n  b`", "s == `a\n  b`"},
+		{"a >", "a >"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeExpression(tt.expr); got != tt.want {
+			t.Errorf("NormalizeExpression(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// bigInt is an integer literal too large for uint64, such as 1 << 70 written out. It is kept
+// apart from *big.Int so that captured *big.Int pointers are still compared as pointers.
+type bigInt struct {
+	*big.Int
+}
+
+// number is a numeric value in the narrowest domain that holds it exactly.
+type number struct {
+	kind  reflect.Kind // reflect.Int64, reflect.Uint64 or reflect.Float64; unset for a bigInt
+	i     int64
+	u     uint64
+	f     float64
+	big   *big.Int
+	valid bool
+}
+
+// toNumber classifies a value of any integer or floating-point kind, including named types
+// such as time.Duration, without losing precision.
+func toNumber(v interface{}) number {
+	if b, ok := v.(bigInt); ok {
+		return number{big: b.Int, valid: true}
+	}
+	if v == nil {
+		return number{}
+	}
+
+	val := reflect.ValueOf(v)
+	switch {
+	case isIntKind(val.Kind()):
+		return number{kind: reflect.Int64, i: val.Int(), valid: true}
+	case isUintKind(val.Kind()):
+		return number{kind: reflect.Uint64, u: val.Uint(), valid: true}
+	case isFloatKind(val.Kind()):
+		return number{kind: reflect.Float64, f: val.Float(), valid: true}
+	}
+	return number{}
+}
+
+// compareNumbers applies a comparison operator to two numbers. Integers are compared as
+// int64, uint64 or big.Int, so IDs above 2^53 keep every digit; a float is compared exactly
+// against an integer. ok is false when either value is not a number.
+func compareNumbers(left, right interface{}, operator string) (result, ok bool) {
+	l, r := toNumber(left), toNumber(right)
+	if !l.valid || !r.valid {
+		return false, false
+	}
+
+	// NaN is unordered: it is unequal to everything, itself included
+	if (l.kind == reflect.Float64 && math.IsNaN(l.f)) || (r.kind == reflect.Float64 && math.IsNaN(r.f)) {
+		return operator == "!=", true
+	}
+
+	c := l.cmp(r)
+	switch operator {
+	case "==":
+		return c == 0, true
+	case "!=":
+		return c != 0, true
+	case "<":
+		return c < 0, true
+	case "<=":
+		return c <= 0, true
+	case ">":
+		return c > 0, true
+	case ">=":
+		return c >= 0, true
+	}
+	return false, false
+}
+
+// cmp returns -1, 0 or +1 as n is less than, equal to or greater than o. Neither may be NaN.
+func (n number) cmp(o number) int {
+	switch {
+	case n.kind == reflect.Int64 && o.kind == reflect.Int64:
+		return boolInt(n.i > o.i) - boolInt(n.i < o.i)
+	case n.kind == reflect.Float64 && o.kind == reflect.Float64:
+		return boolInt(n.f > o.f) - boolInt(n.f < o.f)
+	case n.kind == reflect.Float64 || o.kind == reflect.Float64:
+		return n.bigFloat().Cmp(o.bigFloat())
+	}
+	// Unsigned and mixed-sign integers are compared as big.Int rather than converted
+	return n.bigInt().Cmp(o.bigInt())
+}
+
+// bigInt converts an integer to a big.Int.
+func (n number) bigInt() *big.Int {
+	switch n.kind {
+	case reflect.Int64:
+		return big.NewInt(n.i)
+	case reflect.Uint64:
+		return new(big.Int).SetUint64(n.u)
+	}
+	return n.big
+}
+
+// bigFloat converts any number other than NaN to a big.Float without rounding.
+func (n number) bigFloat() *big.Float {
+	if n.kind == reflect.Float64 {
+		return big.NewFloat(n.f)
+	}
+	return new(big.Float).SetInt(n.bigInt())
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
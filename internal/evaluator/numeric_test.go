@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCompareNumbers(t *testing.T) {
+	const maxSafe = 1 << 53
+
+	tests := []struct {
+		name     string
+		left     interface{}
+		operator string
+		right    interface{}
+		want     bool
+	}{
+		{"large uint64 IDs differ", uint64(maxSafe + 1), "==", uint64(maxSafe), false},
+		{"large uint64 IDs order", uint64(maxSafe + 1), ">", uint64(maxSafe), true},
+		{"max uint64", uint64(math.MaxUint64), "==", uint64(math.MaxUint64 - 1), false},
+		{"max int64", int64(math.MaxInt64), ">", int64(math.MaxInt64 - 1), true},
+		{"min int64", int64(math.MinInt64), "<", int64(math.MinInt64 + 1), true},
+		{"negative int below uint", -1, "<", uint64(0), true},
+		{"negative int never equals uint", -1, "==", uint64(math.MaxUint64), false},
+		{"int64 max and uint64", int64(math.MaxInt64), "<", uint64(math.MaxInt64 + 1), true},
+		{"uint64 and int", uint64(3), ">=", 3, true},
+		{"uint8 and rune", uint8('a'), "==", 'a', true},
+		{"float equals int", 3.0, "==", 3, true},
+		{"float rounds large int", float64(maxSafe), "==", int64(maxSafe + 1), false},
+		{"float below large int", float64(maxSafe), "<", int64(maxSafe + 1), true},
+		{"float fraction", 2.5, ">", 2, true},
+		{"infinity above max uint64", math.Inf(1), ">", uint64(math.MaxUint64), true},
+		{"NaN is unequal", math.NaN(), "==", math.NaN(), false},
+		{"NaN is not equal to anything", math.NaN(), "!=", 1, true},
+		{"NaN is unordered", math.NaN(), "<", 1, false},
+		{"named kinds", 2 * time.Second, ">", time.Second, true},
+		{"big literal above uint64", bigInt{new(big.Int).Lsh(big.NewInt(1), 70)}, ">", uint64(math.MaxUint64), true},
+		{"big literal and float", bigInt{new(big.Int).Lsh(big.NewInt(1), 70)}, "==", math.Ldexp(1, 70), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := compareNumbers(tt.left, tt.right, tt.operator)
+			if !ok {
+				t.Fatalf("compareNumbers(%v, %v) should handle numbers", tt.left, tt.right)
+			}
+			if got != tt.want {
+				t.Errorf("%v %s %v = %v, want %v", tt.left, tt.operator, tt.right, got, tt.want)
+			}
+		})
+	}
+
+	for _, v := range []interface{}{"1", nil, big.NewInt(1), []int{1}} {
+		if _, ok := compareNumbers(v, 1, "=="); ok {
+			t.Errorf("compareNumbers should not handle %T", v)
+		}
+	}
+}
+
+func TestBuildEvaluationTree_LargeIntegers(t *testing.T) {
+	vars := map[string]interface{}{"id": uint64(math.MaxUint64 - 1)}
+
+	tree := buildEvaluationTree("id == 18446744073709551615", vars)
+	if tree.Result {
+		t.Error("Expected id == MaxUint64 to be false for MaxUint64-1")
+	}
+	tree = buildEvaluationTree("id < 18446744073709551615", vars)
+	if !tree.Result {
+		t.Error("Expected id < MaxUint64 to be true")
+	}
+
+	tree = buildEvaluationTree("id < 0x1_0000_0000_0000_0000", vars)
+	if !tree.Result {
+		t.Error("Expected id to be below 2^64")
+	}
+	if got := fmt.Sprint(tree.Right.Value); got != "18446744073709551616" {
+		t.Errorf("Expected the big literal to print as a number, got %s", got)
+	}
+}
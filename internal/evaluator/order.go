@@ -0,0 +1,53 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+)
+
+// EvaluateOrder builds the result of a slice found out of order at index, where its
+// element sorts before the one preceding it. sliceText and lessText are the source texts of
+// the slice and of its ordering function, whose call on the two elements is the
+// expression: "!less(s[4], s[3])". See NameText.
+func EvaluateOrder(sliceText, lessText string, index int, prev, next interface{}) *ExpressionResult {
+	prevText, nextText := fmt.Sprintf("%s[%d]", sliceText, index-1), fmt.Sprintf("%s[%d]", sliceText, index)
+	expr := fmt.Sprintf("!%s(%s, %s)", lessText, nextText, prevText)
+	tree := buildEvaluationTree(expr, nil)
+
+	if call := tree.Left; tree.Type == "unary" && call != nil && len(call.Children) == 2 {
+		setLeafValue(call.Children[0], next)
+		setLeafValue(call.Children[1], prev)
+		call.Value, call.Result = true, true
+		call.Note = fmt.Sprintf("%s sorts before %s, breaking the order at index %d", nextText, prevText, index)
+		tree.Result = false
+	}
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     false,
+		Variables:  map[string]interface{}{prevText: prev, nextText: next},
+		Tree:       tree,
+	}
+}
+
+// NameText returns text if it names a variable, field or function, such as users or
+// sorter.Less, and fallback otherwise. Helpers that build an expression from their
+// arguments use it to keep function literals and composite literals out of it.
+func NameText(text, fallback string) string {
+	if expr, err := parser.ParseExpr(text); err == nil && isNamePath(expr) {
+		return text
+	}
+	return fallback
+}
+
+// isNamePath reports whether expr is an identifier or a chain of selectors on one.
+func isNamePath(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return isNamePath(e.X)
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package evaluator
+
+import "testing"
+
+func TestEvaluateOrder(t *testing.T) {
+	result := EvaluateOrder("users", "byAge", 4, 41, 25)
+
+	if result.Expression != "!byAge(users[4], users[3])" || result.Result {
+		t.Fatalf("Expression = %q, Result = %v", result.Expression, result.Result)
+	}
+	call := result.Tree.Left
+	if call.Value != true || call.Note != "users[4] sorts before users[3], breaking the order at index 4" {
+		t.Errorf("The call should be true and explain the order, got %+v", call)
+	}
+	if next, prev := call.Children[0], call.Children[1]; next.Value != 25 || prev.Value != 41 || next.Left != nil {
+		t.Errorf("The pair should be leaves holding the elements, got %+v and %+v", next, prev)
+	}
+	if FindFailingNode(result.Tree) != result.Tree {
+		t.Error("The negated call should be the failing node")
+	}
+}
+
+func TestNameText(t *testing.T) {
+	tests := map[string]string{
+		"users":                 "users",
+		"s.byAge":               "s.byAge",
+		"[]int{3, 1}":           "fallback",
+		"func(a, b int) bool {": "fallback",
+		"items()":               "fallback",
+	}
+	for text, want := range tests {
+		if got := NameText(text, "fallback"); got != want {
+			t.Errorf("NameText(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
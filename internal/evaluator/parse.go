@@ -0,0 +1,83 @@
+package evaluator
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sync"
+)
+
+// parsedExpr is an expression parsed into a FileSet of its own.
+type parsedExpr struct {
+	fset         *token.FileSet
+	node         ast.Expr
+	err          error
+	placeholders []placeholder // Made the first time they are asked for
+}
+
+// placeholder is a variable of an expression with the placeholder standing for its value
+// until a value is passed, such as "<x>".
+type placeholder struct {
+	name  string
+	value interface{}
+}
+
+// maxParsedExprs bounds the expressions ParseExpr keeps; it starts over once they are all
+// taken.
+const maxParsedExprs = 1024
+
+var (
+	parsedMu    sync.Mutex
+	parsedExprs = map[string]parsedExpr{}
+)
+
+// ParseExpr parses expr as go/parser.ParseExprFrom does, into a FileSet of its own. Every
+// stage of a failure parses the asserted expression, and a failing assertion fails again,
+// so the result is kept: the node and the FileSet are shared and must not be modified.
+func ParseExpr(expr string) (*token.FileSet, ast.Expr, error) {
+	parsedMu.Lock()
+	parsed, ok := parsedExprs[expr]
+	parsedMu.Unlock()
+	if ok {
+		return parsed.fset, parsed.node, parsed.err
+	}
+
+	parsed.fset = token.NewFileSet()
+	parsed.node, parsed.err = parser.ParseExprFrom(parsed.fset, "", expr, 0)
+	parsedMu.Lock()
+	if len(parsedExprs) >= maxParsedExprs {
+		parsedExprs = map[string]parsedExpr{}
+	}
+	parsedExprs[expr] = parsed
+	parsedMu.Unlock()
+	return parsed.fset, parsed.node, parsed.err
+}
+
+// placeholders returns the variables of expr, as extractVariableNames lists them, with their
+// placeholders. They are kept with the parsed expression, boxed, so that a failing
+// assertion does not make them again each time.
+func placeholders(expr string) ([]placeholder, error) {
+	parsedMu.Lock()
+	parsed, ok := parsedExprs[expr]
+	parsedMu.Unlock()
+	if ok && parsed.placeholders != nil {
+		return parsed.placeholders, nil
+	}
+
+	_, node, err := ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	names := extractVariableNames(node)
+	list := make([]placeholder, len(names))
+	for i, name := range names {
+		list[i] = placeholder{name: name, value: "<" + name + ">"}
+	}
+	parsedMu.Lock()
+	if parsed, ok := parsedExprs[expr]; ok {
+		parsed.placeholders = list
+		parsedExprs[expr] = parsed
+	}
+	parsedMu.Unlock()
+	return list, nil
+}
@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Provenance tells where the value of a node came from, so that a value passed with V can be
+// told apart from one the evaluator worked out when they disagree.
+type Provenance int
+
+// Provenances of values.
+const (
+	ProvenanceUnknown     Provenance = iota // The node has no value
+	ProvenanceLiteral                       // Written in the expression
+	ProvenanceUser                          // Passed with V or Values
+	ProvenanceCaptured                      // Recorded by code diagassert-gen instrumented
+	ProvenanceExtracted                     // Read from the test's package, as named constants
+	ProvenanceComputed                      // Worked out from other values: fields, indexes, operators
+	ProvenanceReevaluated                   // Returned by a method called again after the assertion
+)
+
+// String returns the name of the provenance, as written in the machine-readable section.
+func (p Provenance) String() string {
+	switch p {
+	case ProvenanceLiteral:
+		return "literal"
+	case ProvenanceUser:
+		return "user"
+	case ProvenanceCaptured:
+		return "captured"
+	case ProvenanceExtracted:
+		return "extracted"
+	case ProvenanceComputed:
+		return "computed"
+	case ProvenanceReevaluated:
+		return "re-evaluated"
+	}
+	return "unknown"
+}
+
+// markProvenance records where the value of every node of the tree came from: sources gives
+// the provenance of the values looked up by their text, and the others are literals, results
+// of methods called again or computed. variables are the values the tree was built from; a value
+// passed for a field that reads differently from the value passed for its base gets a note.
+func markProvenance(node *EvaluationTree, sources map[string]Provenance, variables map[string]interface{}) {
+	if node == nil {
+		return
+	}
+	markProvenance(node.Left, sources, variables)
+	markProvenance(node.Right, sources, variables)
+	for _, child := range node.Children {
+		markProvenance(child, sources, variables)
+	}
+
+	if node.NotEvaluated || node.Failure != "" {
+		return
+	}
+	if (node.Value == nil || isPlaceholder(node.Value)) && node.Type != "comparison" && node.Type != "logical" {
+		return
+	}
+	source, lookedUp := sources[node.Text]
+	switch {
+	case node.Reevaluated:
+		node.Provenance = ProvenanceReevaluated
+	case lookedUp:
+		node.Provenance = source
+		if source == ProvenanceUser && node.Note == "" {
+			node.Note = disagreement(node, variables)
+		}
+	case node.Type == "literal", node.Type == "identifier":
+		// Identifiers that were not looked up are the predeclared true and false
+		node.Provenance = ProvenanceLiteral
+	default:
+		node.Provenance = ProvenanceComputed
+	}
+}
+
+// provenances returns the provenance of the values looked up by their text: those passed with
+// V or Values, and those read from the test's package, which are not placeholders.
+func provenances(extracted, userValues map[string]interface{}) map[string]Provenance {
+	sources := make(map[string]Provenance, len(extracted)+len(userValues))
+	for name, value := range extracted {
+		if !isPlaceholder(value) {
+			sources[name] = ProvenanceExtracted
+		}
+	}
+	for name := range userValues {
+		sources[name] = ProvenanceUser
+	}
+	return sources
+}
+
+// disagreement returns a note for a field whose value was passed with V, as user.Age, when
+// the value passed for its base reads differently, or "".
+func disagreement(node *EvaluationTree, variables map[string]interface{}) string {
+	if node.Type != "selector" || node.Left != nil {
+		return ""
+	}
+	base, field, ok := cutLast(node.Text, ".")
+	if !ok {
+		return ""
+	}
+	baseValue, exists := variables[base]
+	if !exists || baseValue == nil || isPlaceholder(baseValue) {
+		return ""
+	}
+	read := getFieldValue(baseValue, field)
+	if read == nil || reflect.DeepEqual(read, node.Value) {
+		return ""
+	}
+	if secretField(baseValue, field) {
+		return fmt.Sprintf("%s was passed with a value other than the one the %s passed holds", node.Text, base)
+	}
+	return fmt.Sprintf("%s was passed as %v, but the %s passed holds %v", node.Text, node.Value, base, read)
+}
+
+// secretField reports whether the field of a value is redacted, so that notes must not quote it.
+func secretField(value interface{}, field string) bool {
+	t := reflect.TypeOf(value)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		if structField, ok := t.FieldByName(field); ok {
+			return isRedactedField(structField)
+		}
+	}
+	return isRedactedName(field)
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
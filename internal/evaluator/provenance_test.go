@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"net/http"
+	"testing"
+)
+
+type provenanceUser struct {
+	Age    int
+	Secret string `diag:"redact"`
+}
+
+func (u provenanceUser) Adult() bool { return u.Age >= 18 }
+
+func TestProvenance(t *testing.T) {
+	code := 404
+	user := provenanceUser{Age: 17}
+	pc := callerPC(code == http.StatusOK && user.Adult() && user.Age+1 > 0)
+
+	result := EvaluateWithValues("code == http.StatusOK && user.Adult() && user.Age+1 > 0", false, pc,
+		map[string]interface{}{"code": code, "user": user})
+	want := map[string]Provenance{
+		"code":          ProvenanceUser,
+		"http.StatusOK": ProvenanceExtracted,
+		"user":          ProvenanceUser,
+		"user.Adult()":  ProvenanceReevaluated,
+		"user.Age":      ProvenanceComputed,
+		"user.Age + 1":  ProvenanceComputed,
+		"1":             ProvenanceLiteral,
+	}
+	walkTree(result.Tree, func(node *EvaluationTree) {
+		if expected, ok := want[node.Text]; ok && node.Provenance != expected && !node.NotEvaluated {
+			t.Errorf("%s: provenance = %v, want %v", node.Text, node.Provenance, expected)
+		}
+	})
+}
+
+func TestProvenance_Captured(t *testing.T) {
+	captured := map[string]interface{}{"c": struct{}{}, "c.Next()": 3}
+	result := EvaluateCaptured("c.Next() == 10", false, captured, nil)
+	if got := result.Tree.Left.Provenance; got != ProvenanceCaptured {
+		t.Errorf("c.Next(): provenance = %v, want captured", got)
+	}
+}
+
+func TestProvenance_Disagreement(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		values map[string]interface{}
+		note   string
+	}{
+		{
+			name:   "disagrees",
+			expr:   "user.Age >= 18",
+			values: map[string]interface{}{"user": provenanceUser{Age: 17}, "user.Age": 20},
+			note:   "user.Age was passed as 20, but the user passed holds 17",
+		},
+		{
+			name:   "agrees",
+			expr:   "user.Age >= 18",
+			values: map[string]interface{}{"user": provenanceUser{Age: 17}, "user.Age": 17},
+		},
+		{
+			name:   "no base",
+			expr:   "user.Age >= 18",
+			values: map[string]interface{}{"user.Age": 20},
+		},
+		{
+			name:   "redacted",
+			expr:   `user.Secret == ""`,
+			values: map[string]interface{}{"user": provenanceUser{Secret: "s3cret"}, "user.Secret": "other"},
+			note:   "user.Secret was passed with a value other than the one the user passed holds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluateWithValues(tt.expr, false, 0, tt.values)
+			field := result.Tree.Left
+			if field.Provenance != ProvenanceUser {
+				t.Errorf("%s: provenance = %v, want user", field.Text, field.Provenance)
+			}
+			if field.Note != tt.note {
+				t.Errorf("note = %q, want %q", field.Note, tt.note)
+			}
+		})
+	}
+}
+
+func walkTree(node *EvaluationTree, visit func(*EvaluationTree)) {
+	if node == nil {
+		return
+	}
+	visit(node)
+	walkTree(node.Left, visit)
+	walkTree(node.Right, visit)
+	for _, child := range node.Children {
+		walkTree(child, visit)
+	}
+}
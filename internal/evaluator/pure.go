@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// reevaluatePure is the DIAGASSERT_REEVALUATE mode in which only methods known to be free of
+// side effects are called again. In the default mode, "all", every method is.
+const reevaluatePure = "pure"
+
+var (
+	pureMethodsMu sync.RWMutex
+	pureMethods   = map[string]bool{}
+)
+
+// defaultPureMethods are called again without being listed: the methods of time's values,
+// which never change, and those that conventionally only describe a value.
+var defaultPureMethods = map[string]bool{
+	"time.Time.*":     true,
+	"time.Duration.*": true,
+	"time.Month.*":    true,
+	"time.Weekday.*":  true,
+	"Error":           true,
+	"String":          true,
+}
+
+// AddPureMethods marks methods as free of side effects, so that they are called again in the
+// pure mode of DIAGASSERT_REEVALUATE. Each name is a method, as "IsAdult"; a method of a
+// type, as "User.IsAdult"; or every method of a type, as "User.*". Types may be qualified by
+// the last element of their package path, as "time.Time.*".
+func AddPureMethods(names ...string) {
+	pureMethodsMu.Lock()
+	defer pureMethodsMu.Unlock()
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			pureMethods[name] = true
+		}
+	}
+}
+
+// mayCallAgain reports whether the method named method of obj may be called again to show its
+// result: always, unless DIAGASSERT_REEVALUATE is "pure", and then only when the method is
+// listed by AddPureMethods, the comma-separated DIAGASSERT_PURE_METHODS or the defaults.
+func mayCallAgain(obj interface{}, method string) bool {
+	if config.Getenv("DIAGASSERT_REEVALUATE") != reevaluatePure {
+		return true
+	}
+
+	names := []string{method}
+	if t := reflect.TypeOf(obj); t != nil {
+		for t.Kind() == reflect.Ptr && t.Name() == "" {
+			t = t.Elem()
+		}
+		if t.Name() != "" {
+			typeName := t.Name()
+			names = append(names, typeName+"."+method, typeName+".*")
+			if t.PkgPath() != "" {
+				qualified := path.Base(t.PkgPath()) + "." + typeName
+				names = append(names, qualified+"."+method, qualified+".*")
+			}
+		}
+	}
+
+	listed := map[string]bool{}
+	for _, name := range strings.Split(config.Getenv("DIAGASSERT_PURE_METHODS"), ",") {
+		listed[strings.TrimSpace(name)] = true
+	}
+	pureMethodsMu.RLock()
+	defer pureMethodsMu.RUnlock()
+	for _, name := range names {
+		if pureMethods[name] || listed[name] || defaultPureMethods[name] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+)
+
+type pureAccount struct{}
+
+func (pureAccount) Balance() int   { return 0 }
+func (*pureAccount) Withdraw() int { return 0 }
+
+func TestMayCallAgain(t *testing.T) {
+	AddPureMethods("pureAccount.Balance", "Summary")
+	tests := []struct {
+		name   string
+		mode   string
+		listed string
+		obj    interface{}
+		method string
+		want   bool
+	}{
+		{"default mode", "", "", &pureAccount{}, "Withdraw", true},
+		{"unlisted", "pure", "", &pureAccount{}, "Withdraw", false},
+		{"listed by type", "pure", "", &pureAccount{}, "Balance", true},
+		{"listed by name", "pure", "", 42, "Summary", true},
+		{"listed in the environment", "pure", "evaluator.pureAccount.*", &pureAccount{}, "Withdraw", true},
+		{"time", "pure", "", time.Now(), "Sub", true},
+		{"String", "pure", "", &pureAccount{}, "String", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DIAGASSERT_REEVALUATE", tt.mode)
+			t.Setenv("DIAGASSERT_PURE_METHODS", tt.listed)
+			if got := mayCallAgain(tt.obj, tt.method); got != tt.want {
+				t.Errorf("mayCallAgain(%T, %s) = %v, want %v", tt.obj, tt.method, got, tt.want)
+			}
+		})
+	}
+}
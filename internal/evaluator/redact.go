@@ -0,0 +1,359 @@
+package evaluator
+
+import (
+	"go/ast"
+	"go/parser"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// Redacted stands in for a value that must not be shown. It prints as "***" however it is
+// formatted.
+type Redacted string
+
+// String returns the mask.
+func (Redacted) String() string { return mask }
+
+const mask = "***"
+
+// maxRedactDepth bounds the copy of nested values, which may be cyclic.
+const maxRedactDepth = 16
+
+var (
+	redactedNamesMu sync.RWMutex
+	redactedNames   = map[string]bool{}
+)
+
+// RedactNames marks names whose values are masked wherever they appear: variables, struct
+// fields, method calls and map keys. Names are matched without regard to case.
+func RedactNames(names ...string) {
+	redactedNamesMu.Lock()
+	defer redactedNamesMu.Unlock()
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			redactedNames[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// redactingNames reports whether any name is redacted, so that the names of values, which
+// take parsing to find, are only looked for then.
+func redactingNames() bool {
+	redactedNamesMu.RLock()
+	registered := len(redactedNames) > 0
+	redactedNamesMu.RUnlock()
+	return registered || strings.TrimSpace(config.Getenv("DIAGASSERT_REDACT")) != ""
+}
+
+// isRedactedName reports whether name was passed to RedactNames or listed in the
+// comma-separated DIAGASSERT_REDACT.
+func isRedactedName(name string) bool {
+	if name == "" {
+		return false
+	}
+	name = strings.ToLower(name)
+
+	redactedNamesMu.RLock()
+	registered := redactedNames[name]
+	redactedNamesMu.RUnlock()
+	if registered {
+		return true
+	}
+	for _, listed := range strings.Split(config.Getenv("DIAGASSERT_REDACT"), ",") {
+		if strings.ToLower(strings.TrimSpace(listed)) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact masks the secrets in a result before it is shown: the values of redacted names,
+// fields tagged `diag:"redact"` and fields or map keys with redacted names inside other
+// values, and the differences found in them. Values are copied before being masked, so the
+// test's own are left alone.
+func Redact(result *ExpressionResult) {
+	if result == nil {
+		return
+	}
+	r := &redactor{masked: map[string]bool{}}
+	for name, value := range result.Variables {
+		result.Variables[name] = r.value(name, value)
+	}
+	r.tree(result.Tree)
+}
+
+// RedactValue returns value, masked as Redact would for a value shown under name.
+func RedactValue(name string, value interface{}) interface{} {
+	r := &redactor{masked: map[string]bool{}}
+	return r.value(name, value)
+}
+
+// redactor remembers the names of the fields and keys it masked, to mask the differences
+// found in them too.
+type redactor struct {
+	masked map[string]bool
+}
+
+func (r *redactor) tree(node *EvaluationTree) {
+	if node == nil {
+		return
+	}
+	r.tree(node.Left)
+	r.tree(node.Right)
+	for _, child := range node.Children {
+		r.tree(child)
+	}
+
+	if node.Value != nil && node.Type != "literal" {
+		node.Value = r.value(node.Text, node.Value)
+	}
+	if node.Err != "" && isRedactedName(lastName(node.Text)) {
+		node.Err = mask
+	}
+	if len(node.Differences) > 0 {
+		redactOperands := isRedactedName(nameOf(node.Left)) || isRedactedName(nameOf(node.Right))
+		differences := node.Differences[:0]
+		for _, diff := range node.Differences {
+			path, _, found := strings.Cut(diff, ": ")
+			switch {
+			case found && (redactOperands || r.maskedPath(path)):
+				differences = append(differences, path+": "+mask)
+			case redactOperands:
+				// Lines without a path, such as where two strings first differ and their
+				// lengths, tell as much about a secret as its value: they are dropped
+			default:
+				differences = append(differences, diff)
+			}
+		}
+		if len(differences) == 0 {
+			differences = append(differences, mask)
+		}
+		node.Differences = differences
+	}
+}
+
+// value masks value entirely when the last name of the expression that produced it is
+// redacted, or else the redacted parts inside it.
+func (r *redactor) value(text string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if _, ok := value.(Redacted); ok {
+		return value
+	}
+	if redactingNames() && isRedactedName(lastName(text)) {
+		// Strings keep their type; anything else could not hold the mask
+		if t := reflect.TypeOf(value); t.Kind() == reflect.String || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return maskedValue(t).Interface()
+		}
+		return Redacted(mask)
+	}
+	if isPlaceholder(value) {
+		return value
+	}
+	if masked, changed := r.copy(reflect.ValueOf(value), 0); changed {
+		return masked.Interface()
+	}
+	return value
+}
+
+// copy returns v with its redacted fields and map entries masked, and whether anything was.
+// Unchanged values are returned as they are.
+func (r *redactor) copy(v reflect.Value, depth int) (reflect.Value, bool) {
+	if depth > maxRedactDepth {
+		return v, false
+	}
+	if v.CanAddr() && !v.CanInterface() {
+		// Unexported fields are read through their address
+		v = Settable(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, false
+		}
+		elem, changed := r.copy(v.Elem(), depth+1)
+		if !changed {
+			return v, false
+		}
+		copied := reflect.New(elem.Type())
+		copied.Elem().Set(elem)
+		return copied, true
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		elem, changed := r.copy(v.Elem(), depth+1)
+		if !changed {
+			return v, false
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(elem)
+		return copied, true
+
+	case reflect.Struct:
+		v = Addressable(v)
+		var copied reflect.Value
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			replacement, changed := reflect.Value{}, false
+			if isRedactedField(field) {
+				replacement, changed = maskedValue(field.Type), true
+				r.masked[field.Name] = true
+			} else {
+				replacement, changed = r.copy(v.Field(i), depth+1)
+			}
+			if !changed {
+				continue
+			}
+			if !copied.IsValid() {
+				copied = reflect.New(v.Type()).Elem()
+				copied.Set(v)
+			}
+			Settable(copied.Field(i)).Set(replacement)
+		}
+		if copied.IsValid() {
+			return copied, true
+		}
+		return v, false
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return v, false
+		}
+		v = Addressable(v)
+		var copied reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			elem, changed := r.copy(v.Index(i), depth+1)
+			if !changed {
+				continue
+			}
+			if !copied.IsValid() {
+				if v.Kind() == reflect.Slice {
+					copied = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+				} else {
+					copied = reflect.New(v.Type()).Elem()
+				}
+				reflect.Copy(copied, v)
+			}
+			Settable(copied.Index(i)).Set(elem)
+		}
+		if copied.IsValid() {
+			return copied, true
+		}
+		return v, false
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v, false
+		}
+		iter := v.MapRange()
+		changes := map[int]reflect.Value{}
+		keys := []reflect.Value{}
+		for iter.Next() {
+			key, elem := iter.Key(), iter.Value()
+			keys = append(keys, key)
+			if key.Kind() == reflect.String && isRedactedName(key.String()) {
+				changes[len(keys)-1] = maskedValue(v.Type().Elem())
+				r.masked[key.String()] = true
+				continue
+			}
+			if masked, changed := r.copy(elem, depth+1); changed {
+				changes[len(keys)-1] = masked
+			}
+		}
+		if len(changes) == 0 {
+			return v, false
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for i, key := range keys {
+			if masked, ok := changes[i]; ok {
+				copied.SetMapIndex(key, masked)
+				continue
+			}
+			copied.SetMapIndex(key, v.MapIndex(key))
+		}
+		return copied, true
+	}
+	return v, false
+}
+
+// isRedactedField reports whether a struct field is tagged `diag:"redact"` or has a redacted name.
+func isRedactedField(field reflect.StructField) bool {
+	return ParseFieldTag(field).Redact || isRedactedName(field.Name)
+}
+
+// maskedValue is the mask as a value of type t: "***" for strings, byte slices and
+// interfaces, and the zero value for types that cannot hold it.
+func maskedValue(t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+	switch {
+	case t.Kind() == reflect.String:
+		v.SetString(mask)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		v.SetBytes([]byte(mask))
+	case t.Kind() == reflect.Interface && reflect.TypeOf(Redacted("")).Implements(t):
+		v.Set(reflect.ValueOf(Redacted(mask)))
+	}
+	return v
+}
+
+// maskedPath reports whether a difference's path, such as .Credentials.Token or
+// ["password"], goes through a field or key that was masked or has a redacted name.
+func (r *redactor) maskedPath(path string) bool {
+	for _, part := range strings.FieldsFunc(path, func(c rune) bool { return c == '.' || c == '[' || c == ']' }) {
+		if name, err := strconv.Unquote(part); err == nil {
+			part = name
+		}
+		if r.masked[part] || isRedactedName(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameOf returns the last name of a node's expression, or "" for a missing node.
+func nameOf(node *EvaluationTree) string {
+	if node == nil {
+		return ""
+	}
+	return lastName(node.Text)
+}
+
+// lastName returns the name that ends an expression: Password for user.Password and
+// user.Password(), token for creds["token"], and for any other index the name of what is
+// indexed, such as pins for pins[0].
+func lastName(text string) string {
+	expr, err := parser.ParseExpr(text)
+	if err != nil {
+		return ""
+	}
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e.Name
+		case *ast.SelectorExpr:
+			return e.Sel.Name
+		case *ast.CallExpr:
+			expr = e.Fun
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			if lit, ok := e.Index.(*ast.BasicLit); ok {
+				if key, err := strconv.Unquote(lit.Value); err == nil {
+					return key
+				}
+			}
+			// Elements indexed by anything but a string literal take the name of their container
+			expr = e.X
+		default:
+			return ""
+		}
+	}
+}
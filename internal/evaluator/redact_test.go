@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type login struct {
+	User   string
+	Secret string `diag:"redact"`
+	pin    []byte `diag:"redact"`
+	Tries  int    `diag:"redact"`
+}
+
+type account struct {
+	Name     string
+	Logins   []login
+	Settings map[string]interface{}
+	owner    *login
+}
+
+func TestRedactValue(t *testing.T) {
+	RedactNames("apiKey")
+
+	original := account{
+		Name:     "alice",
+		Logins:   []login{{User: "alice", Secret: "hunter2", pin: []byte("1234"), Tries: 3}},
+		Settings: map[string]interface{}{"theme": "dark", "APIKEY": "k-123"},
+		owner:    &login{User: "root", Secret: "toor"},
+	}
+
+	masked := RedactValue("acct", original)
+	text := fmt.Sprintf("%+v %+v", masked, *masked.(account).owner)
+	for _, secret := range []string{"hunter2", "1234", "k-123", "toor", "Tries:3"} {
+		if strings.Contains(text, secret) {
+			t.Errorf("RedactValue() should mask %q, got %s", secret, text)
+		}
+	}
+	for _, shown := range []string{"Name:alice", "User:alice", "Secret:***", "theme:dark", "APIKEY:***", "User:root"} {
+		if !strings.Contains(text, shown) {
+			t.Errorf("RedactValue() should keep %q, got %s", shown, text)
+		}
+	}
+
+	if original.Logins[0].Secret != "hunter2" || original.owner.Secret != "toor" || original.Settings["APIKEY"] != "k-123" {
+		t.Errorf("RedactValue() should not modify the original value, got %+v", original)
+	}
+
+	plain := login{User: "bob"}
+	if got := RedactValue("apikey", "k-456"); got != "***" {
+		t.Errorf("A string with a redacted name should be masked whole, got %v", got)
+	}
+	if got := RedactValue("cfg.APIKey", 456); got != Redacted("***") {
+		t.Errorf("A value of another type with a redacted name should be replaced by the mask, got %#v", got)
+	}
+	if got := RedactValue("plain", plain.User); got != "bob" {
+		t.Errorf("Other values should be returned as they are, got %v", got)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	RedactNames("apiKey")
+
+	left := login{User: "alice", Secret: "hunter2"}
+	right := login{User: "alice", Secret: "swordfish"}
+	key := "k-789"
+	result := &ExpressionResult{
+		Expression: `left == right && cfg.APIKey() == key`,
+		Variables:  map[string]interface{}{"left": left, "right": right, "cfg.APIKey()": key, "key": key},
+		Tree:       buildEvaluationTree(`left == right && cfg.APIKey() == key`, map[string]interface{}{"left": left, "right": right, "cfg.APIKey()": key, "key": key}),
+	}
+	Redact(result)
+
+	var texts []string
+	var walk func(node *EvaluationTree)
+	walk = func(node *EvaluationTree) {
+		if node == nil {
+			return
+		}
+		texts = append(texts, fmt.Sprintf("%s=%v %q", node.Text, node.Value, node.Differences))
+		walk(node.Left)
+		walk(node.Right)
+	}
+	walk(result.Tree)
+	all := strings.Join(texts, "\n") + fmt.Sprint(result.Variables)
+
+	for _, secret := range []string{"hunter2", "swordfish"} {
+		if strings.Contains(all, secret) {
+			t.Errorf("Redact() should mask %q in values and differences, got:\n%s", secret, all)
+		}
+	}
+	if !strings.Contains(all, `.Secret: ***`) {
+		t.Errorf("The difference in a masked field should be kept without its values, got:\n%s", all)
+	}
+	if !strings.Contains(all, "cfg.APIKey()=***") {
+		t.Errorf("A method call with a redacted name should be masked, got:\n%s", all)
+	}
+}
+
+func TestLastName(t *testing.T) {
+	tests := map[string]string{
+		"password":          "password",
+		"user.Password":     "Password",
+		"user.Password()":   "Password",
+		`creds["token"]`:    "token",
+		"(cfg.Token)":       "Token",
+		"pins[0]":           "pins",
+		"a + b":             "",
+		"not an expression": "",
+	}
+	for text, want := range tests {
+		if got := lastName(text); got != want {
+			t.Errorf("lastName(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/ast"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxRegexpInputRunes is how much of a regexp input is quoted in a mismatch note.
+const maxRegexpInputRunes = 64
+
+// evaluateRegexpCall evaluates regexp.MatchString, regexp.Match, regexp.Compile,
+// regexp.MustCompile and the MatchString and Match methods of a *regexp.Regexp.
+// A failed match comes with a note showing the pattern, the input and the longest
+// prefix of the pattern that still matches. ok is false for any other call.
+func evaluateRegexpCall(fun *ast.SelectorExpr, baseTree *EvaluationTree, args []*EvaluationTree) (interface{}, string, bool) {
+	for _, arg := range args {
+		if !HasKnownResult(arg) {
+			return nil, "", false
+		}
+	}
+
+	var re *regexp.Regexp
+	var input interface{}
+	ident, isIdent := fun.X.(*ast.Ident)
+	switch {
+	case isIdent && ident.Name == "regexp" && (baseTree.Value == nil || isPlaceholder(baseTree.Value)):
+		if len(args) == 0 {
+			return nil, "", false
+		}
+		pattern, ok := args[0].Value.(string)
+		if !ok {
+			return nil, "", false
+		}
+		compiled, err := regexp.Compile(pattern)
+
+		switch fun.Sel.Name {
+		case "Compile", "MustCompile":
+			if err != nil {
+				return nil, fmt.Sprintf("invalid pattern %s: %v", quotePattern(pattern), err), true
+			}
+			return compiled, "", true
+		case "MatchString", "Match":
+			if len(args) != 2 {
+				return nil, "", false
+			}
+			if err != nil {
+				return nil, fmt.Sprintf("invalid pattern %s: %v", quotePattern(pattern), err), true
+			}
+			re, input = compiled, args[1].Value
+		default:
+			return nil, "", false
+		}
+	default:
+		compiled, ok := baseTree.Value.(*regexp.Regexp)
+		if !ok || compiled == nil || len(args) != 1 || (fun.Sel.Name != "MatchString" && fun.Sel.Name != "Match") {
+			return nil, "", false
+		}
+		re, input = compiled, args[0].Value
+	}
+
+	var text string
+	switch v := input.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return nil, "", false
+	}
+
+	if re.MatchString(text) {
+		return true, "", true
+	}
+	return false, describeRegexpMismatch(re.String(), text), true
+}
+
+// describeRegexpMismatch explains a failed match, e.g.
+// "pattern `[a-z]+\d{3}` does not match "abc12"; longest matching prefix `[a-z]+\d` matched "abc1"".
+func describeRegexpMismatch(pattern, input string) string {
+	note := fmt.Sprintf("pattern %s does not match %s", quotePattern(pattern), trimInput(input))
+
+	// Shorten the pattern until it matches something: that is how far the input conforms
+	for end := len(pattern) - 1; end > 0; end-- {
+		if !utf8.RuneStart(pattern[end]) {
+			continue
+		}
+		prefix, err := regexp.Compile(pattern[:end])
+		if err != nil {
+			continue
+		}
+		if loc := prefix.FindStringIndex(input); loc != nil && loc[1] > loc[0] {
+			return fmt.Sprintf("%s; longest matching prefix %s matched %s at index %d",
+				note, quotePattern(pattern[:end]), trimInput(input[loc[0]:loc[1]]), loc[0])
+		}
+	}
+	return note + "; no prefix of the pattern matches"
+}
+
+// quotePattern quotes a pattern with backquotes when possible so backslashes stay readable.
+func quotePattern(pattern string) string {
+	if strconv.CanBackquote(pattern) {
+		return "`" + pattern + "`"
+	}
+	return strconv.Quote(pattern)
+}
+
+// trimInput quotes a regexp input, eliding the tail of long inputs.
+func trimInput(input string) string {
+	if utf8.RuneCountInString(input) <= maxRegexpInputRunes {
+		return strconv.Quote(input)
+	}
+	runes := []rune(input)
+	return strconv.Quote(string(runes[:maxRegexpInputRunes])) + "..."
+}
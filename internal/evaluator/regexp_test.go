@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBuildEvaluationTree_Regexp(t *testing.T) {
+	tests := []struct {
+		name         string
+		expr         string
+		variables    map[string]interface{}
+		expectResult bool
+		expectNote   string
+	}{
+		{
+			name:         "package MatchString",
+			expr:         "regexp.MatchString(`^\\d+$`, id)",
+			variables:    map[string]interface{}{"id": "12a"},
+			expectResult: false,
+			expectNote:   "pattern `^\\d+$` does not match \"12a\"; longest matching prefix `^\\d+` matched \"12\" at index 0",
+		},
+		{
+			name:         "MustCompile chain",
+			expr:         "regexp.MustCompile(`[a-z]+\\d{3}`).MatchString(id)",
+			variables:    map[string]interface{}{"id": "abc12"},
+			expectResult: false,
+			expectNote:   "pattern `[a-z]+\\d{3}` does not match \"abc12\"; longest matching prefix `[a-z]+\\d` matched \"abc1\" at index 0",
+		},
+		{
+			name:         "compiled variable",
+			expr:         "re.MatchString(s)",
+			variables:    map[string]interface{}{"re": regexp.MustCompile("x+y"), "s": "abc"},
+			expectResult: false,
+			expectNote:   "pattern `x+y` does not match \"abc\"; no prefix of the pattern matches",
+		},
+		{
+			name:         "Match on bytes",
+			expr:         "re.Match(b)",
+			variables:    map[string]interface{}{"re": regexp.MustCompile("b+"), "b": []byte("abba")},
+			expectResult: true,
+		},
+		{
+			name:         "invalid pattern",
+			expr:         `regexp.MatchString("(", s)`,
+			variables:    map[string]interface{}{"s": "x"},
+			expectResult: false,
+			expectNote:   "invalid pattern `(`: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:         "unknown input",
+			expr:         "regexp.MatchString(`a`, s)",
+			variables:    map[string]interface{}{"s": "<s>"},
+			expectResult: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildEvaluationTree(tt.expr, tt.variables)
+			if tree.Result != tt.expectResult {
+				t.Errorf("Result = %v, expected %v", tree.Result, tt.expectResult)
+			}
+			if tree.Note != tt.expectNote {
+				t.Errorf("Note = %q, expected %q", tree.Note, tt.expectNote)
+			}
+		})
+	}
+}
+
+func TestDescribeRegexpMismatch_TrimsInput(t *testing.T) {
+	note := describeRegexpMismatch("^z", strings.Repeat("a", 100))
+
+	expected := "pattern `^z` does not match \"" + strings.Repeat("a", maxRegexpInputRunes) + "\"...; no prefix of the pattern matches"
+	if note != expected {
+		t.Errorf("describeRegexpMismatch() = %q, expected %q", note, expected)
+	}
+}
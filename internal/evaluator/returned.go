@@ -0,0 +1,32 @@
+package evaluator
+
+// EvaluateError builds the result of a value and a non-nil error returned together. When
+// callText, the source text of the call that returned them, is known, as for
+// Must(os.Open(path)), the expression is the call and its node holds both results and the
+// error. Otherwise, as for Check2(t, f, err), the expression compares errText, the error's
+// source text, to nil.
+func EvaluateError(callText, errText string, value interface{}, err error) *ExpressionResult {
+	if callText == "" {
+		expr := errText + " == nil"
+		variables := map[string]interface{}{errText: err}
+		return &ExpressionResult{
+			Expression: expr,
+			Result:     false,
+			Variables:  variables,
+			Tree:       buildEvaluationTree(expr, variables),
+		}
+	}
+
+	tree := buildEvaluationTree(callText, nil)
+	results := Results{value, err}
+	setLeafValue(tree, results)
+	tree.Result = false
+	tree.Err = err.Error()
+
+	return &ExpressionResult{
+		Expression: callText,
+		Result:     false,
+		Variables:  map[string]interface{}{callText: results},
+		Tree:       tree,
+	}
+}
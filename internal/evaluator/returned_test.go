@@ -0,0 +1,39 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEvaluateError(t *testing.T) {
+	err := errors.New("invalid syntax")
+
+	t.Run("call", func(t *testing.T) {
+		result := EvaluateError(`strconv.Atoi("x")`, "", 0, err)
+
+		if result.Expression != `strconv.Atoi("x")` || result.Result {
+			t.Fatalf("Expression = %q, Result = %v", result.Expression, result.Result)
+		}
+		tree := result.Tree
+		if results, ok := tree.Value.(Results); !ok || len(results) != 2 || results[1] != err {
+			t.Errorf("The call should hold both results, got %#v", tree.Value)
+		}
+		if tree.Err != "invalid syntax" || tree.Children != nil {
+			t.Errorf("The call should be a leaf carrying the error, got %+v", tree)
+		}
+		if FindFailingNode(tree) != tree {
+			t.Error("The call should be the failing node")
+		}
+	})
+
+	t.Run("error alone", func(t *testing.T) {
+		result := EvaluateError("", "loadErr", "value", err)
+
+		if result.Expression != "loadErr == nil" || result.Result {
+			t.Fatalf("Expression = %q, Result = %v", result.Expression, result.Result)
+		}
+		if tree := result.Tree; tree.Type != "comparison" || tree.Left.Value != err {
+			t.Errorf("The error should be compared to nil, got %+v", tree)
+		}
+	})
+}
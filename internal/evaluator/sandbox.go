@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// defaultCallTimeout bounds each method call made again to show its result.
+const defaultCallTimeout = time.Second
+
+// callTimeout returns how long a method called again may run: DIAGASSERT_CALL_TIMEOUT, such as
+// "500ms", or defaultCallTimeout. "0" lets calls run as long as they take.
+func callTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(config.Getenv("DIAGASSERT_CALL_TIMEOUT")); err == nil && timeout >= 0 {
+		return timeout
+	}
+	return defaultCallTimeout
+}
+
+// sandboxed makes call on a goroutine of its own, so that a call that panics, exits its
+// goroutine or hangs, as one reaching the network may, cannot take down or stall the test
+// reporting a failure. failure describes what went wrong, in the form shown in place of the
+// call's value, or is "" when the call returned. A call that times out is left running.
+func sandboxed(call func() []reflect.Value) (out []reflect.Value, failure string) {
+	type outcome struct {
+		out     []reflect.Value
+		failure string
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		o := outcome{failure: "(evaluation exited its goroutine)"}
+		defer func() { done <- o }()
+		defer func() {
+			if r := recover(); r != nil {
+				o.failure = fmt.Sprintf("(evaluation panicked: %v)", r)
+			}
+		}()
+		o.out = call()
+		o.failure = ""
+	}()
+
+	timeout := callTimeout()
+	if timeout == 0 {
+		o := <-done
+		return o.out, o.failure
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.out, o.failure
+	case <-timer.C:
+		return nil, fmt.Sprintf("(evaluation timed out after %v)", timeout)
+	}
+}
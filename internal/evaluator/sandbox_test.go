@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSandboxed(t *testing.T) {
+	t.Setenv("DIAGASSERT_CALL_TIMEOUT", "20ms")
+	tests := []struct {
+		name    string
+		call    func() []reflect.Value
+		failure string
+	}{
+		{
+			name: "returns",
+			call: func() []reflect.Value { return []reflect.Value{reflect.ValueOf(1)} },
+		},
+		{
+			name:    "panics",
+			call:    func() []reflect.Value { panic("boom") },
+			failure: "(evaluation panicked: boom)",
+		},
+		{
+			name:    "exits its goroutine",
+			call:    func() []reflect.Value { runtime.Goexit(); return nil },
+			failure: "(evaluation exited its goroutine)",
+		},
+		{
+			name:    "hangs",
+			call:    func() []reflect.Value { select {} },
+			failure: "(evaluation timed out after 20ms)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, failure := sandboxed(tt.call)
+			if failure != tt.failure {
+				t.Errorf("sandboxed() failure = %q, want %q", failure, tt.failure)
+			}
+			if tt.failure == "" && len(out) != 1 {
+				t.Errorf("sandboxed() = %v, want the call's results", out)
+			}
+		})
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	tests := []struct {
+		setting string
+		want    time.Duration
+	}{
+		{"", defaultCallTimeout},
+		{"250ms", 250 * time.Millisecond},
+		{"0", 0},
+		{"-1s", defaultCallTimeout},
+		{"soon", defaultCallTimeout},
+	}
+	for _, tt := range tests {
+		t.Setenv("DIAGASSERT_CALL_TIMEOUT", tt.setting)
+		if got := callTimeout(); got != tt.want {
+			t.Errorf("callTimeout() with %q = %v, want %v", tt.setting, got, tt.want)
+		}
+	}
+}
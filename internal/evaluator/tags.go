@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// shortLength is how many runes of a string a field tagged `diag:"short"` shows.
+const shortLength = 8
+
+// FieldTag holds the options of a struct field's `diag` tag, such as `diag:"hex"` or
+// `diag:"short,redact"`, with which type owners choose how fields are shown.
+type FieldTag struct {
+	Omit   bool // "-": left out of compact values and differences
+	Hex    bool // "hex": integers as 0x1f, and strings and byte slices as their bytes in hex
+	Short  bool // "short": strings cut to their first runes, collections shown by their length
+	Redact bool // "redact": masked; see RedactNames
+	Name   bool // "name": names the subtest of a table case; see diagassert.Table
+}
+
+// ParseFieldTag reads the `diag` tag of a struct field.
+func ParseFieldTag(field reflect.StructField) FieldTag {
+	var tag FieldTag
+	for _, option := range strings.Split(field.Tag.Get("diag"), ",") {
+		switch strings.TrimSpace(option) {
+		case "-":
+			tag.Omit = true
+		case "hex":
+			tag.Hex = true
+		case "short":
+			tag.Short = true
+		case "redact":
+			tag.Redact = true
+		case "name":
+			tag.Name = true
+		}
+	}
+	return tag
+}
+
+// FormatTagged renders the value of a field as its hex and short options ask. It reports
+// false when neither applies to the value, which is then shown as usual.
+func FormatTagged(tag FieldTag, v reflect.Value) (string, bool) {
+	if !tag.Hex && !tag.Short || !v.IsValid() {
+		return "", false
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if tag.Hex {
+		text, ok := formatHex(v)
+		if ok && tag.Short && len(text) > shortLength+2 {
+			text = text[:shortLength+2] + "..."
+		}
+		return text, ok
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		runes := []rune(v.String())
+		if len(runes) <= shortLength {
+			return strconv.Quote(v.String()), true
+		}
+		return strconv.Quote(string(runes[:shortLength])) + "...", true
+	case reflect.Slice, reflect.Array:
+		return fmt.Sprintf("[len %d]", v.Len()), true
+	case reflect.Map:
+		return fmt.Sprintf("map[len %d]", v.Len()), true
+	}
+	return "", false
+}
+
+// formatHex renders integers as 0x1f and strings, byte slices and byte arrays as their
+// bytes, 0x68690a.
+func formatHex(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%#x", v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fmt.Sprintf("%#x", v.Uint()), true
+	case reflect.String:
+		return "0x" + hex.EncodeToString([]byte(v.String())), true
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return "", false
+		}
+		b := make([]byte, v.Len())
+		for i := range b {
+			b[i] = byte(v.Index(i).Uint())
+		}
+		return "0x" + hex.EncodeToString(b), true
+	}
+	return "", false
+}
@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type taggedFrame struct {
+	ID      uint32            `diag:"hex"`
+	Body    []byte            `diag:"hex,short"`
+	Label   string            `diag:"short"`
+	Items   []int             `diag:"short"`
+	Offsets []int             `diag:"hex"`
+	Cache   map[string]string `diag:"-"`
+	Seq     int
+}
+
+func TestParseFieldTag(t *testing.T) {
+	typ := reflect.TypeOf(struct {
+		A int `diag:"-"`
+		B int `diag:"hex, short"`
+		C int `diag:"redact"`
+		D int `json:"d"`
+		E int `diag:"name"`
+	}{})
+
+	want := []FieldTag{{Omit: true}, {Hex: true, Short: true}, {Redact: true}, {}, {Name: true}}
+	for i, w := range want {
+		if got := ParseFieldTag(typ.Field(i)); got != w {
+			t.Errorf("ParseFieldTag(%s) = %+v, want %+v", typ.Field(i).Name, got, w)
+		}
+	}
+}
+
+func TestFormatTagged(t *testing.T) {
+	frame := taggedFrame{ID: 255, Body: []byte("hello world"), Label: "ünïcode-label", Items: []int{1, 2, 3}, Offsets: []int{16}}
+	v := reflect.ValueOf(frame)
+	typ := v.Type()
+
+	want := map[string]string{
+		"ID":    "0xff",
+		"Body":  "0x68656c6c...",
+		"Label": `"ünïcode-"...`,
+		"Items": "[len 3]",
+	}
+	for name, w := range want {
+		field, _ := typ.FieldByName(name)
+		got, ok := FormatTagged(ParseFieldTag(field), v.FieldByName(name))
+		if !ok || got != w {
+			t.Errorf("FormatTagged(%s) = %q, %v; want %q", name, got, ok, w)
+		}
+	}
+
+	// Options that do not apply leave the value to the usual formatting
+	field, _ := typ.FieldByName("Offsets")
+	if got, ok := FormatTagged(ParseFieldTag(field), v.FieldByName("Offsets")); ok {
+		t.Errorf("hex should not apply to []int, got %q", got)
+	}
+	field, _ = typ.FieldByName("Seq")
+	if _, ok := FormatTagged(ParseFieldTag(field), v.FieldByName("Seq")); ok {
+		t.Error("An untagged field should be formatted as usual")
+	}
+}
+
+func TestDeepDiffTags(t *testing.T) {
+	left := taggedFrame{ID: 0x10, Label: "request-0001", Items: []int{1}, Offsets: []int{1}, Cache: map[string]string{"a": "1"}, Seq: 1}
+	right := taggedFrame{ID: 0x20, Label: "request-0002", Items: []int{1}, Offsets: []int{2}, Cache: map[string]string{"a": "2"}, Seq: 2}
+
+	got := deepDiff(left, right, 10)
+	want := []string{
+		".ID: 0x10 != 0x20",
+		`.Label: "request-"... != "request-"...`,
+		".Offsets[0]: 0x1 != 0x2", // The elements of a tagged slice follow its tag
+		".Seq: 1 != 2",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("deepDiff() = %q, want %q", got, want)
+	}
+}
+
+func TestEqualDifferencesHiddenFields(t *testing.T) {
+	type account struct {
+		Name    string
+		Balance int `diag:"-"`
+	}
+
+	got := EqualDifferences(account{"a", 1}, account{"a", 1000}, EqualRules{})
+	if len(got) != 1 || got[0] != hiddenDifference {
+		t.Errorf("Hidden fields should count without being shown, got %q", got)
+	}
+	if got := EqualDifferences(account{"a", 1}, account{"a", 1}, EqualRules{}); len(got) != 0 {
+		t.Errorf("Equal values should have no differences, got %q", got)
+	}
+	if got := EqualDifferences(account{"a", 1}, account{"a", 1000}, EqualRules{Ignore: []string{"Balance"}}); len(got) != 0 {
+		t.Errorf("Ignored hidden fields should not count, got %q", got)
+	}
+}
@@ -0,0 +1,157 @@
+package evaluator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	"github.com/paveg/diagassert/internal/astutil"
+)
+
+// DescribeTautology explains why an asserted expression can never be false, such as
+// "x == x compares x with itself", or returns "" when nothing suggests it. The check is
+// syntactic: it finds assertions that cannot fail whatever the code under test does. The
+// package of the assertion at callerFrame is type checked to leave out comparisons of
+// floats with themselves, as f == f is, which are false for NaN; without it, or with a
+// callerFrame of 0, every operand is taken to be no float.
+func DescribeTautology(expr string, callerFrame uintptr) string {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return ""
+	}
+
+	var (
+		static *staticInfo
+		loaded bool
+	)
+	typeOf := func(node ast.Expr) types.Type {
+		if !loaded && callerFrame != 0 {
+			static = analyzeCaller(expr, callerFrame)
+		}
+		loaded = true
+		if static == nil {
+			return nil
+		}
+		return static.types[types.ExprString(node)]
+	}
+	return tautology(astutil.Unparen(node), typeOf)
+}
+
+// tautology explains why node can never be false, typeOf giving the static types of its
+// operands, or nil where they are unknown.
+func tautology(node ast.Expr, typeOf func(ast.Expr) types.Type) string {
+	text := types.ExprString(node)
+
+	if ident, ok := node.(*ast.Ident); ok && ident.Name == "true" {
+		return "asserts the literal true"
+	}
+	if onlyLiterals(node) {
+		return fmt.Sprintf("%s only involves literals, so its result never changes", text)
+	}
+
+	expr, ok := node.(*ast.BinaryExpr)
+	if !ok {
+		return ""
+	}
+	x, y := astutil.Unparen(expr.X), astutil.Unparen(expr.Y)
+
+	switch expr.Op {
+	case token.LOR:
+		if reason := tautology(x, typeOf); reason != "" {
+			return reason
+		}
+		if reason := tautology(y, typeOf); reason != "" {
+			return reason
+		}
+		if isNegationOf(x, y) || isNegationOf(y, x) {
+			return fmt.Sprintf("%s is true whatever %s is", text, types.ExprString(x))
+		}
+	case token.LAND:
+		if tautology(x, typeOf) != "" && tautology(y, typeOf) != "" {
+			return fmt.Sprintf("%s joins two assertions that cannot fail", text)
+		}
+	case token.EQL, token.LEQ, token.GEQ:
+		if types.ExprString(x) == types.ExprString(y) && !hasCall(x) && !mayHoldNaN(typeOf(x)) {
+			return fmt.Sprintf("%s compares %s with itself", text, types.ExprString(x))
+		}
+		if (expr.Op == token.GEQ && isLength(x) && isZero(y)) || (expr.Op == token.LEQ && isZero(x) && isLength(y)) {
+			return fmt.Sprintf("%s always holds: lengths are never negative", text)
+		}
+	}
+	return ""
+}
+
+// mayHoldNaN reports whether values of t can be NaN, or hold one, so that comparing them
+// with themselves can be false: floats, complex numbers, and structs, arrays and
+// interfaces that may contain them.
+func mayHoldNaN(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return u.Info()&(types.IsFloat|types.IsComplex) != 0
+	case *types.Array:
+		return mayHoldNaN(u.Elem())
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if mayHoldNaN(u.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Interface, *types.TypeParam:
+		return true
+	}
+	return false
+}
+
+// onlyLiterals reports whether an expression is built from literals alone, like 1 == 1.
+func onlyLiterals(node ast.Expr) bool {
+	literal := true
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if n.Name != "true" && n.Name != "false" && n.Name != "nil" {
+				literal = false
+			}
+		case *ast.CallExpr, *ast.CompositeLit, *ast.FuncLit, *ast.SelectorExpr, *ast.IndexExpr:
+			literal = false
+		}
+		return literal
+	})
+	return literal
+}
+
+// isNegationOf reports whether neg is !x.
+func isNegationOf(neg, x ast.Expr) bool {
+	unary, ok := neg.(*ast.UnaryExpr)
+	return ok && unary.Op == token.NOT && types.ExprString(astutil.Unparen(unary.X)) == types.ExprString(x) && !hasCall(x)
+}
+
+func hasCall(node ast.Expr) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// isLength reports whether node is a call of len or cap.
+func isLength(node ast.Expr) bool {
+	call, ok := node.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*ast.Ident)
+	return ok && (ident.Name == "len" || ident.Name == "cap")
+}
+
+func isZero(node ast.Expr) bool {
+	lit, ok := node.(*ast.BasicLit)
+	return ok && lit.Kind == token.INT && lit.Value == "0"
+}
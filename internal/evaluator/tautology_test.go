@@ -0,0 +1,57 @@
+package evaluator
+
+import "testing"
+
+func TestDescribeTautology(t *testing.T) {
+	tests := map[string]string{
+		"true":                   "asserts the literal true",
+		"(true)":                 "asserts the literal true",
+		"1 == 1":                 "1 == 1 only involves literals, so its result never changes",
+		`"a" != "b"`:             `"a" != "b" only involves literals, so its result never changes`,
+		"x == x":                 "x == x compares x with itself",
+		"u.Age >= u.Age":         "u.Age >= u.Age compares u.Age with itself",
+		"len(items) >= 0":        "len(items) >= 0 always holds: lengths are never negative",
+		"0 <= cap(buf)":          "0 <= cap(buf) always holds: lengths are never negative",
+		"ok || !ok":              "ok || !ok is true whatever ok is",
+		"x > 1 || y == y":        "y == y compares y with itself",
+		"x == x && len(s) >= 0":  "x == x && len(s) >= 0 joins two assertions that cannot fail",
+		"x > 10":                 "",
+		"x == y":                 "",
+		"next() == next()":       "",
+		"x == x && y > 1":        "",
+		"len(items) > 0":         "",
+		"strings.Contains(s, s)": "",
+		"not valid (":            "",
+	}
+	for expr, want := range tests {
+		if got := DescribeTautology(expr, 0); got != want {
+			t.Errorf("DescribeTautology(%q) = %q, want %q", expr, got, want)
+		}
+	}
+}
+
+type reading struct {
+	Value float64
+}
+
+func TestDescribeTautology_Floats(t *testing.T) {
+	f, n, r := 0.5, 1, reading{}
+	var v interface{} = f
+
+	tests := []struct {
+		expr string
+		pc   uintptr
+		want string
+	}{
+		{"f == f", callerPC(f == f), ""},
+		{"r == r", callerPC(r == r), ""},
+		{"v == v", callerPC(v == v), ""},
+		{"n == n", callerPC(n == n), "n == n compares n with itself"},
+		{"f == f", 0, "f == f compares f with itself"},
+	}
+	for _, tt := range tests {
+		if got := DescribeTautology(tt.expr, tt.pc); got != tt.want {
+			t.Errorf("DescribeTautology(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package evaluator
+
+import (
+	"fmt"
+	"time"
+)
+
+// EvaluateWait builds the result of a helper that gave up waiting on a value, such as
+// Receives on a channel, whose call on the value and the timeout is the expression:
+// "Receives(jobs, time.Second)". note says what it waited for in vain.
+func EvaluateWait(helper, text, timeoutText string, value interface{}, timeout time.Duration, note string) *ExpressionResult {
+	expr := fmt.Sprintf("%s(%s, %s)", helper, text, timeoutText)
+	tree := buildEvaluationTree(expr, nil)
+
+	if tree.Type == "call" && len(tree.Children) == 2 {
+		setLeafValue(tree.Children[0], value)
+		setLeafValue(tree.Children[1], timeout)
+		tree.Value, tree.Result = false, false
+		tree.Note = note
+	}
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     false,
+		Variables:  map[string]interface{}{text: value, timeoutText: timeout},
+		Tree:       tree,
+	}
+}
@@ -0,0 +1,22 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateWait(t *testing.T) {
+	jobs := make(chan int)
+	result := EvaluateWait("Receives", "jobs", "time.Second", jobs, time.Second, "no value was received from jobs within 1s")
+
+	if result.Expression != "Receives(jobs, time.Second)" || result.Result {
+		t.Fatalf("Expression = %q, Result = %v", result.Expression, result.Result)
+	}
+	call := result.Tree
+	if call.Value != false || call.Note != "no value was received from jobs within 1s" {
+		t.Errorf("The call should fail with the note, got %+v", call)
+	}
+	if ch, timeout := call.Children[0], call.Children[1]; ch.Value != jobs || timeout.Value != time.Second || timeout.Left != nil {
+		t.Errorf("The arguments should be leaves holding jobs and 1s, got %+v and %+v", ch, timeout)
+	}
+}
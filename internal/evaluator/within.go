@@ -0,0 +1,29 @@
+package evaluator
+
+import (
+	"fmt"
+	"time"
+)
+
+// EvaluateWithinDuration builds the result of checking that two times are at most delta
+// apart, whose call on the times and delta is the expression:
+// "WithinDuration(job.StartedAt, time.Now(), time.Second)". note says how far apart they are.
+func EvaluateWithinDuration(aText, bText, deltaText string, a, b time.Time, delta time.Duration, note string) *ExpressionResult {
+	expr := fmt.Sprintf("WithinDuration(%s, %s, %s)", aText, bText, deltaText)
+	tree := buildEvaluationTree(expr, nil)
+
+	if tree.Type == "call" && len(tree.Children) == 3 {
+		setLeafValue(tree.Children[0], a)
+		setLeafValue(tree.Children[1], b)
+		setLeafValue(tree.Children[2], delta)
+		tree.Value, tree.Result = false, false
+		tree.Note = note
+	}
+
+	return &ExpressionResult{
+		Expression: expr,
+		Result:     false,
+		Variables:  map[string]interface{}{aText: a, bText: b, deltaText: delta},
+		Tree:       tree,
+	}
+}
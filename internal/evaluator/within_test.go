@@ -0,0 +1,28 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateWithinDuration(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	end := start.Add(2500 * time.Millisecond)
+	result := EvaluateWithinDuration("start", "end", "time.Second", start, end, time.Second,
+		"end is 2.5s after start, 1.5s more than the 1s allowed")
+
+	if result.Expression != "WithinDuration(start, end, time.Second)" || result.Result != false {
+		t.Fatalf("Expression = %q, Result = %v", result.Expression, result.Result)
+	}
+	if len(result.Tree.Children) != 3 {
+		t.Fatalf("The call should have three arguments, got %+v", result.Tree)
+	}
+	for i, want := range []interface{}{start, end, time.Second} {
+		if got := result.Tree.Children[i].Value; got != want {
+			t.Errorf("Argument %d = %v, want %v", i, got, want)
+		}
+	}
+	if result.Tree.Note != "end is 2.5s after start, 1.5s more than the 1s allowed" {
+		t.Errorf("Note = %q", result.Tree.Note)
+	}
+}
@@ -0,0 +1,32 @@
+package formatter
+
+import "sync"
+
+// canvases pools the canvases diagram lines are laid out on, which every failure uses a few
+// of per layer.
+var canvases = sync.Pool{New: func() interface{} { return new(canvas) }}
+
+// newCanvas returns a blank canvas width columns wide, from the pool. render gives it back.
+func newCanvas(width int) *canvas {
+	c := canvases.Get().(*canvas)
+	if cap(c.runes) < width {
+		c.runes = make([]rune, width)
+		c.taken = make([]bool, width)
+		c.owners = make([]int, width)
+	}
+	c.runes, c.taken, c.owners, c.paints = c.runes[:width], c.taken[:width], c.owners[:width], c.paints[:0]
+	for i := range c.runes {
+		c.runes[i] = ' '
+		c.taken[i] = false
+		c.owners[i] = -1
+	}
+	return c
+}
+
+// release gives c back to the pool, dropping its paints.
+func (c *canvas) release() {
+	for i := range c.paints {
+		c.paints[i] = nil
+	}
+	canvases.Put(c)
+}
@@ -0,0 +1,132 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagram lines are drawn in two phases. They are laid out on a canvas of plain runes, one
+// column each, so that alignment is simple arithmetic whether colors are on or not; only then
+// are they colored, a span of columns at a time. Escape sequences go around whole spans, so
+// they can neither shift a column nor be cut by the layout.
+
+// paint colors the text of a span, as colorizeValue and colorizePipe do.
+type paint func(string) string
+
+// canvas is a diagram line being laid out.
+type canvas struct {
+	runes  []rune
+	taken  []bool  // Columns something was put in, spaces included
+	owners []int   // Index into paints of the paint of each column, or -1 for none
+	paints []paint // Paints of the columns; the columns of one paint form a span
+}
+
+// addPaint registers p for the columns put with the returned index. A nil p leaves them plain.
+func (c *canvas) addPaint(p paint) int {
+	if p == nil {
+		return -1
+	}
+	c.paints = append(c.paints, p)
+	return len(c.paints) - 1
+}
+
+// put draws r at column col with the paint of index owner, unless the column lies beyond the
+// canvas or is taken: the first rune put in a column keeps it, even a space. Which columns
+// are taken does not depend on paints, so the layout is the same with colors off.
+func (c *canvas) put(col int, r rune, owner int) {
+	if col < 0 || col >= len(c.runes) || c.taken[col] {
+		return
+	}
+	c.runes[col] = r
+	c.taken[col] = true
+	c.owners[col] = owner
+}
+
+// write draws text from column col on, rune by rune, with the paint of index owner.
+func (c *canvas) write(col int, text string, owner int) {
+	for _, r := range text {
+		c.put(col, r, owner)
+		col++
+	}
+}
+
+// width returns the number of columns of the line without trailing spaces.
+func (c *canvas) width() int {
+	width := len(c.runes)
+	for width > 0 && c.runes[width-1] == ' ' {
+		width--
+	}
+	return width
+}
+
+// plain returns the line without colors and without trailing spaces.
+func (c *canvas) plain() string {
+	return string(c.runes[:c.width()])
+}
+
+// render returns the line with each span colored by its paint, without trailing spaces. The
+// canvas goes back to the pool and must not be used after.
+func (c *canvas) render() string {
+	var b strings.Builder
+	c.renderTo(&b)
+	return b.String()
+}
+
+// renderTo writes the line as render returns it to b, so that a diagram is written without
+// a string for each of its lines. The canvas goes back to the pool and must not be used after.
+func (c *canvas) renderTo(b *strings.Builder) {
+	defer c.release()
+	width := c.width()
+	from := b.Len()
+	for start := 0; start < width; {
+		end := start + 1
+		for end < width && c.owners[end] == c.owners[start] {
+			end++
+		}
+		if owner := c.owners[start]; owner >= 0 {
+			b.WriteString(c.paints[owner](string(c.runes[start:end])))
+		} else {
+			for _, r := range c.runes[start:end] {
+				b.WriteRune(r)
+			}
+		}
+		start = end
+	}
+
+	if StrictANSI && len(c.paints) > 0 {
+		if err := checkANSI(b.String()[from:], c.plain()); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// StrictANSI makes rendering check every line it colors, and every failure the visual
+// formatter writes, with checkANSI and panic on the first that fails. The tests of this
+// package and of diagassert turn it on.
+var StrictANSI = false
+
+// checkANSI reports whether text, rendered from plain, holds only complete SGR sequences and
+// reads as plain once they are removed.
+func checkANSI(text, plain string) error {
+	var stripped strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\033' {
+			stripped.WriteByte(text[i])
+			continue
+		}
+		j := i + 1
+		if j >= len(text) || text[j] != '[' {
+			return fmt.Errorf("escape sequence at byte %d of %q is cut short", i, text)
+		}
+		for j++; j < len(text) && (text[j] >= '0' && text[j] <= '9' || text[j] == ';'); j++ {
+		}
+		if j >= len(text) || text[j] != 'm' {
+			return fmt.Errorf("escape sequence at byte %d of %q is cut short", i, text)
+		}
+		i = j
+	}
+	if stripped.String() != plain {
+		return fmt.Errorf("colors moved the text of %q: it reads %q without them", plain, stripped.String())
+	}
+	return nil
+}
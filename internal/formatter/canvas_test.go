@@ -0,0 +1,99 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/ansi"
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+func TestCanvas(t *testing.T) {
+	defer func(noColor bool) { ansi.NoColor = noColor }(ansi.NoColor)
+	ansi.NoColor = false
+	red := func(text string) string { return ansi.New(ansi.FgRed).Sprint(text) }
+
+	line := newCanvas(12)
+	line.put(0, '|', line.addPaint(red))
+	line.write(2, "16", line.addPaint(red))
+	line.write(3, "false", line.addPaint(nil)) // Overlaps "16", which keeps its columns
+	line.write(10, "xyz", -1)                  // Runs past the canvas and is cut
+
+	want := "\x1b[31m|\x1b[0m \x1b[31m16\x1b[0malse  xy"
+	if got := line.render(); got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+
+	// Without paints a space still takes its column
+	spaced := newCanvas(8)
+	spaced.write(0, "a b", -1)
+	spaced.write(1, "xyz", -1)
+	if got, want := spaced.render(), "a bz"; got != want {
+		t.Errorf("render() without paints = %q, want %q", got, want)
+	}
+
+	blank := newCanvas(4)
+	if got := blank.render(); got != "" {
+		t.Errorf("render() of a blank canvas = %q, want empty", got)
+	}
+}
+
+func TestCheckANSI(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		plain string
+		want  string
+	}{
+		{"plain", "age >= 18", "age >= 18", ""},
+		{"colored", "\x1b[31;1mfalse\x1b[0;22m", "false", ""},
+		{"cut short", "\x1b[3", "", "cut short"},
+		{"missing bracket", "\x1b31mfalse", "31mfalse", "cut short"},
+		{"moved text", "\x1b[31m16\x1b[0m", " 16", "colors moved the text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkANSI(tt.text, tt.plain)
+			if tt.want == "" && err != nil {
+				t.Errorf("checkANSI() = %v, want nil", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("checkANSI() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisualFormatter_ColorsKeepAlignment(t *testing.T) {
+	t.Setenv("DIAGASSERT_COLOR", "always")
+	result := &evaluator.ExpressionResult{
+		Expression: `name == "日本語" && count > 100000`,
+		Result:     false,
+		Tree: &evaluator.EvaluationTree{
+			Type: "logical", Operator: "&&", Text: `name == "日本語" && count > 100000`, Result: false,
+			Left: &evaluator.EvaluationTree{
+				Type: "comparison", Operator: "==", Text: `name == "日本語"`, Result: false,
+				Left:  &evaluator.EvaluationTree{Type: "identifier", Text: "name", Value: "ひらがなカタカナ"},
+				Right: &evaluator.EvaluationTree{Type: "literal", Text: `"日本語"`, Value: "日本語"},
+			},
+			Right: &evaluator.EvaluationTree{Type: "comparison", Operator: ">", Text: "count > 100000", NotEvaluated: true},
+		},
+	}
+
+	for _, style := range []string{styleLayered, styleClassic} {
+		t.Run(style, func(t *testing.T) {
+			t.Setenv("DIAGASSERT_STYLE", style)
+			colored := NewVisualFormatter().formatPowerAssertStyle(result)
+
+			t.Setenv("DIAGASSERT_COLOR", "never")
+			plain := NewVisualFormatter().formatPowerAssertStyle(result)
+
+			if colored == plain {
+				t.Fatalf("Diagram should be colored:\n%s", colored)
+			}
+			if StripColors(colored) != plain {
+				t.Errorf("Colors should not change the layout.\nColored:\n%s\nPlain:\n%s", StripColors(colored), plain)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package formatter
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// Diagram styles selected with DIAGASSERT_STYLE.
+const (
+	styleLayered  = "layered"
+	styleClassic  = "classic"
+	styleCompact  = "compact"
+	styleMarkdown = "markdown"
+)
+
+// getStyle reads DIAGASSERT_STYLE, defaulting to the layered style.
+func getStyle() string {
+	switch style := config.Getenv("DIAGASSERT_STYLE"); style {
+	case styleClassic, styleCompact, styleMarkdown:
+		return style
+	}
+	return styleLayered
+}
+
+// buildClassicLines lays values out as power-assert-js does: a line of pipes under every
+// value's column, then each value on a line of its own, the rightmost first so that the
+// pipes of those still to come run down on its left. Values sharing a column are shown
+// deepest first.
+//
+//	assert(age >= 18)
+//	       |   |  |
+//	       |   |  18
+//	       |   false
+//	       16
+func (f *VisualFormatter) buildClassicLines(positions []ValuePosition) []string {
+	rows := make([]VisualNode, len(positions))
+	for i, pos := range positions {
+		rows[i] = VisualNode{
+			Position:        pos,
+			EvaluationDepth: pos.Depth,
+			PipePosition:    pos.VisualPos,
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].PipePosition != rows[j].PipePosition {
+			return rows[i].PipePosition > rows[j].PipePosition
+		}
+		return rows[i].EvaluationDepth > rows[j].EvaluationDepth
+	})
+
+	// Every value is a layer of its own, so pipes are colored as in the layered style
+	assignment := LayerAssignment{
+		MaxLayer: len(rows) - 1,
+		Layers:   make([][]VisualNode, 0, len(rows)),
+	}
+	for i := range rows {
+		rows[i].VisualLayer = i
+		assignment.Layers = append(assignment.Layers, []VisualNode{rows[i]})
+	}
+
+	lines := []string{f.classicLine(assignment, 0, rows[0].PipePosition+1, nil).render()}
+	for i := range rows {
+		lines = append(lines, f.classicLine(assignment, i+1, rows[i].PipePosition, &rows[i]).render())
+	}
+	return lines
+}
+
+// classicLine lays out the pipes of the values from the layer first on, in the columns
+// before width, followed by the value of row when there is one.
+func (f *VisualFormatter) classicLine(assignment LayerAssignment, first, width int, row *VisualNode) *canvas {
+	if row != nil {
+		width += utf8.RuneCountInString(row.Position.Value)
+	}
+	line := newCanvas(width)
+	for _, layer := range assignment.Layers[first:] {
+		for _, node := range layer {
+			if row == nil || node.PipePosition < row.PipePosition {
+				line.put(node.PipePosition, '|', line.addPaint(f.pipePaint(node.Position)))
+			}
+		}
+	}
+	if row != nil {
+		line.write(row.PipePosition, row.Position.Value, line.addPaint(f.valuePaint(row.Position)))
+	}
+	return line
+}
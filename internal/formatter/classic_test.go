@@ -0,0 +1,71 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+func TestClassicStyle(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MAX_WIDTH", "")
+	t.Setenv("COLUMNS", "")
+	t.Setenv("DIAGASSERT_STYLE", "classic")
+
+	formatter := NewVisualFormatter()
+	result := evaluator.EvaluateWithValues("a + b == c", false, 0, map[string]interface{}{"a": 1, "b": 2, "c": 4})
+	got := formatter.formatPowerAssertStyle(result)
+
+	want := strings.Join([]string{
+		"  assert(a + b == c)",
+		"         | | | |  |",
+		"         | | | |  4",
+		"         | | | false",
+		"         | | 2",
+		"         | 3",
+		"         1",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("classic diagram:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestClassicStyle_SharedColumn(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	formatter := NewVisualFormatter()
+
+	// p and x start at the same column, and x is deeper
+	lines := formatter.buildClassicLines([]ValuePosition{
+		{Expression: "*p", Value: "1", VisualPos: 0, Depth: 1},
+		{Expression: "p", Value: "0xc0", VisualPos: 1, Depth: 2},
+		{Expression: "x", Value: "2", VisualPos: 1, Depth: 3},
+	})
+
+	want := []string{
+		"||",
+		"|2",
+		"|0xc0",
+		"1",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestGetStyle(t *testing.T) {
+	for setting, want := range map[string]string{
+		"":         styleLayered,
+		"layered":  styleLayered,
+		"classic":  styleClassic,
+		"compact":  styleCompact,
+		"markdown": styleMarkdown,
+		"unknown":  styleLayered,
+	} {
+		t.Setenv("DIAGASSERT_STYLE", setting)
+		if got := getStyle(); got != want {
+			t.Errorf("getStyle() with %q = %q, want %q", setting, got, want)
+		}
+	}
+}
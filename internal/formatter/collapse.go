@@ -0,0 +1,117 @@
+package formatter
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/astutil"
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/textwidth"
+)
+
+// Expansion modes selected with DIAGASSERT_EXPAND.
+const (
+	expandAuto      = "auto"
+	expandFull      = "full"
+	expandCollapsed = "collapsed"
+)
+
+// collapseThreshold is the expression width above which the auto mode collapses
+// passing sub-expressions.
+const collapseThreshold = 120
+
+// collapsedMarker replaces a passing sub-expression in the collapsed view.
+const collapsedMarker = "✓"
+
+// collapsedIdent stands in for collapsedMarker while the collapsed expression is parsed.
+// Both are one column wide, so every position stays put, and "_" can never be an operand.
+const collapsedIdent = "_"
+
+// getExpandMode reads DIAGASSERT_EXPAND, defaulting to auto.
+func getExpandMode() string {
+	switch mode := config.Getenv("DIAGASSERT_EXPAND"); mode {
+	case expandFull, expandCollapsed:
+		return mode
+	}
+	return expandAuto
+}
+
+// shouldCollapse reports whether passing sub-expressions of expr should be collapsed.
+func (f *VisualFormatter) shouldCollapse(expr string) bool {
+	switch f.expandMode {
+	case expandFull:
+		return false
+	case expandCollapsed:
+		return true
+	}
+	return textwidth.String(expr) > collapseThreshold
+}
+
+// collapsePassing prunes the operands of && and || that evaluated to true, since they
+// cannot be why the assertion failed, leaving only the failing branches expanded.
+// It returns the rewritten expression with collapsedIdent in place of each pruned
+// operand, the byte offsets of those placeholders and the matching pruned tree.
+func collapsePassing(expr string, root ast.Expr, fset *token.FileSet, tree *evaluator.EvaluationTree) (string, []int, *evaluator.EvaluationTree) {
+	type span struct{ start, end int }
+	var spans []span
+
+	var prune func(node *evaluator.EvaluationTree, astNode ast.Expr) *evaluator.EvaluationTree
+	prune = func(node *evaluator.EvaluationTree, astNode ast.Expr) *evaluator.EvaluationTree {
+		bin, ok := astutil.Unparen(astNode).(*ast.BinaryExpr)
+		if node == nil || node.NotEvaluated || node.Type != "logical" || !ok {
+			return node
+		}
+
+		pruned := *node
+		operands := []**evaluator.EvaluationTree{&pruned.Left, &pruned.Right}
+		for i, operandAST := range []ast.Expr{bin.X, bin.Y} {
+			operand := *operands[i]
+			if operand != nil && !operand.NotEvaluated && operand.Result && evaluator.HasKnownResult(operand) {
+				spans = append(spans, span{fset.Position(operandAST.Pos()).Offset, fset.Position(operandAST.End()).Offset})
+				*operands[i] = &evaluator.EvaluationTree{Type: "identifier", Text: collapsedIdent, Result: true}
+				continue
+			}
+			*operands[i] = prune(operand, operandAST)
+		}
+		return &pruned
+	}
+	prunedTree := prune(tree, root)
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	var offsets []int
+	last := 0
+	for _, s := range spans {
+		b.WriteString(expr[last:s.start])
+		offsets = append(offsets, b.Len())
+		b.WriteString(collapsedIdent)
+		last = s.end
+	}
+	b.WriteString(expr[last:])
+
+	return b.String(), offsets, prunedTree
+}
+
+// display returns expr[start:end] as it is shown to the user, with collapsed operands
+// rendered as collapsedMarker.
+func (m *PositionMapper) display(start, end int) string {
+	if len(m.collapsed) == 0 {
+		return m.expr[start:end]
+	}
+	var b strings.Builder
+	last := start
+	for _, offset := range m.collapsed {
+		if offset < start || offset >= end {
+			continue
+		}
+		b.WriteString(m.expr[last:offset])
+		b.WriteString(collapsedMarker)
+		last = offset + len(collapsedIdent)
+	}
+	b.WriteString(m.expr[last:end])
+	return b.String()
+}
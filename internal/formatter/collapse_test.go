@@ -0,0 +1,126 @@
+package formatter
+
+import (
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// collapseTestTree is the tree of "a == 1 && (b == 2 || c == 9) && c == 4" with c = 3.
+func collapseTestTree() *evaluator.EvaluationTree {
+	comparison := func(name string, value, literal int, result bool) *evaluator.EvaluationTree {
+		return &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: "==",
+			Text:     name + " == " + strconv.Itoa(literal),
+			Left:     &evaluator.EvaluationTree{Type: "identifier", Text: name, Value: value},
+			Right:    &evaluator.EvaluationTree{Type: "literal", Text: strconv.Itoa(literal), Value: literal},
+			Result:   result,
+		}
+	}
+
+	return &evaluator.EvaluationTree{
+		Type:     "logical",
+		Operator: "&&",
+		Text:     "a == 1 && b == 2 || c == 9 && c == 4",
+		Left: &evaluator.EvaluationTree{
+			Type:     "logical",
+			Operator: "&&",
+			Text:     "a == 1 && b == 2 || c == 9",
+			Left:     comparison("a", 1, 1, true),
+			Right: &evaluator.EvaluationTree{
+				Type:     "logical",
+				Operator: "||",
+				Text:     "b == 2 || c == 9",
+				Left:     comparison("b", 2, 2, true),
+				Right:    comparison("c", 3, 9, false),
+				Result:   true,
+			},
+			Result: true,
+		},
+		Right: comparison("c", 3, 4, false),
+	}
+}
+
+func TestCollapsePassing(t *testing.T) {
+	expr := "a == 1 && (b == 2 || c == 9) && c == 4"
+	fset := token.NewFileSet()
+	root, err := parser.ParseExprFrom(fset, "", expr, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collapsed, offsets, tree := collapsePassing(expr, root, fset, collapseTestTree())
+
+	if collapsed != "_ && c == 4" {
+		t.Errorf("collapsePassing() expression = %q, want %q", collapsed, "_ && c == 4")
+	}
+	if len(offsets) != 1 || offsets[0] != 0 {
+		t.Errorf("collapsePassing() offsets = %v, want [0]", offsets)
+	}
+	if tree.Left.Text != collapsedIdent || tree.Right.Text != "c == 4" {
+		t.Errorf("collapsePassing() tree operands = %q, %q", tree.Left.Text, tree.Right.Text)
+	}
+}
+
+func TestVisualFormatter_ExpandModes(t *testing.T) {
+	tests := []struct {
+		mode           string
+		expectAssert   string
+		expectCollapse bool
+	}{
+		{
+			mode:           "collapsed",
+			expectAssert:   "assert(✓ && c == 4)",
+			expectCollapse: true,
+		},
+		{
+			mode:         "full",
+			expectAssert: "assert(a == 1 && (b == 2 || c == 9) && c == 4)",
+		},
+		{
+			// Short expressions are left alone in auto mode
+			mode:         "auto",
+			expectAssert: "assert(a == 1 && (b == 2 || c == 9) && c == 4)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv("NO_COLOR", "1")
+			t.Setenv("DIAGASSERT_MACHINE_READABLE", "false")
+			t.Setenv("DIAGASSERT_EXPAND", tt.mode)
+			formatter := NewVisualFormatter()
+
+			result := &evaluator.ExpressionResult{
+				Expression: "a == 1 && (b == 2 || c == 9) && c == 4",
+				Tree:       collapseTestTree(),
+			}
+			output := formatter.FormatVisual(result, "test.go", 1, "")
+
+			if !strings.Contains(output, tt.expectAssert) {
+				t.Errorf("Expected output to contain %q.\nOutput:\n%s", tt.expectAssert, output)
+			}
+			footer := "(1 passing sub-expression shown as ✓; set DIAGASSERT_EXPAND=full to expand)"
+			if strings.Contains(output, footer) != tt.expectCollapse {
+				t.Errorf("Expected footer present = %v.\nOutput:\n%s", tt.expectCollapse, output)
+			}
+		})
+	}
+}
+
+func TestShouldCollapse_AutoThreshold(t *testing.T) {
+	t.Setenv("DIAGASSERT_EXPAND", "")
+	formatter := NewVisualFormatter()
+
+	if formatter.shouldCollapse(strings.Repeat("x", collapseThreshold)) {
+		t.Error("Expected expressions up to the threshold to stay expanded")
+	}
+	if !formatter.shouldCollapse(strings.Repeat("x", collapseThreshold+1)) {
+		t.Error("Expected expressions over the threshold to collapse")
+	}
+}
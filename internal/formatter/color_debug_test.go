@@ -4,7 +4,7 @@ import (
 	"os"
 	"testing"
 
-	"github.com/fatih/color"
+	"github.com/paveg/diagassert/internal/ansi"
 )
 
 func TestColorDebug(t *testing.T) {
@@ -12,10 +12,10 @@ func TestColorDebug(t *testing.T) {
 	t.Run("default", func(t *testing.T) {
 		os.Unsetenv("NO_COLOR")
 		os.Unsetenv("FORCE_COLOR")
-		color.NoColor = false
+		ansi.NoColor = false
 
-		enabled := shouldEnableColors()
-		t.Logf("Default: shouldEnableColors() = %v, color.NoColor = %v", enabled, color.NoColor)
+		enabled := shouldEnableColors(loadConfig())
+		t.Logf("Default: shouldEnableColors(loadConfig()) = %v, ansi.NoColor = %v", enabled, ansi.NoColor)
 
 		formatter := NewVisualFormatter()
 		t.Logf("Formatter ColorsEnabled = %v", formatter.colorConfig.ColorsEnabled)
@@ -25,10 +25,10 @@ func TestColorDebug(t *testing.T) {
 	t.Run("NO_COLOR only", func(t *testing.T) {
 		os.Setenv("NO_COLOR", "1")
 		os.Unsetenv("FORCE_COLOR")
-		color.NoColor = false
+		ansi.NoColor = false
 
-		enabled := shouldEnableColors()
-		t.Logf("NO_COLOR only: shouldEnableColors() = %v, color.NoColor = %v", enabled, color.NoColor)
+		enabled := shouldEnableColors(loadConfig())
+		t.Logf("NO_COLOR only: shouldEnableColors(loadConfig()) = %v, ansi.NoColor = %v", enabled, ansi.NoColor)
 
 		formatter := NewVisualFormatter()
 		t.Logf("Formatter ColorsEnabled = %v", formatter.colorConfig.ColorsEnabled)
@@ -38,10 +38,10 @@ func TestColorDebug(t *testing.T) {
 	t.Run("FORCE_COLOR only", func(t *testing.T) {
 		os.Unsetenv("NO_COLOR")
 		os.Setenv("FORCE_COLOR", "1")
-		color.NoColor = false
+		ansi.NoColor = false
 
-		enabled := shouldEnableColors()
-		t.Logf("FORCE_COLOR only: shouldEnableColors() = %v, color.NoColor = %v", enabled, color.NoColor)
+		enabled := shouldEnableColors(loadConfig())
+		t.Logf("FORCE_COLOR only: shouldEnableColors(loadConfig()) = %v, ansi.NoColor = %v", enabled, ansi.NoColor)
 
 		formatter := NewVisualFormatter()
 		t.Logf("Formatter ColorsEnabled = %v", formatter.colorConfig.ColorsEnabled)
@@ -51,16 +51,16 @@ func TestColorDebug(t *testing.T) {
 	t.Run("both NO_COLOR and FORCE_COLOR", func(t *testing.T) {
 		os.Setenv("NO_COLOR", "1")
 		os.Setenv("FORCE_COLOR", "1")
-		color.NoColor = false
+		ansi.NoColor = false
 
-		enabled := shouldEnableColors()
-		t.Logf("Both: shouldEnableColors() = %v, color.NoColor = %v", enabled, color.NoColor)
+		enabled := shouldEnableColors(loadConfig())
+		t.Logf("Both: shouldEnableColors(loadConfig()) = %v, ansi.NoColor = %v", enabled, ansi.NoColor)
 
 		formatter := NewVisualFormatter()
 		t.Logf("Formatter ColorsEnabled = %v", formatter.colorConfig.ColorsEnabled)
 
 		// Test if colors actually work
-		red := color.New(color.FgRed)
+		red := ansi.New(ansi.FgRed)
 		output := red.Sprint("test")
 		t.Logf("Red color output: %q", output)
 	})
@@ -5,10 +5,26 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/fatih/color"
+	"github.com/paveg/diagassert/internal/ansi"
 	"github.com/paveg/diagassert/internal/evaluator"
 )
 
+// TestMain runs the tests as in a terminal outside CI, where colors are on by default, of no
+// width that wraps diagrams, and checks that no escape sequence written is ever cut or moves
+// the text.
+func TestMain(m *testing.M) {
+	stdoutIsColorTerminal = func() bool { return true }
+	stdoutWidth = func() int { return 0 }
+	StrictANSI = true
+	for _, key := range []string{"DIAGASSERT_COLOR", "CI", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE"} {
+		os.Unsetenv(key)
+	}
+	if os.Getenv("TERM") == "dumb" {
+		os.Unsetenv("TERM")
+	}
+	os.Exit(m.Run())
+}
+
 func TestColorConfiguration(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -16,7 +32,7 @@ func TestColorConfiguration(t *testing.T) {
 		expectColor bool
 	}{
 		{
-			name:        "colors enabled by default",
+			name:        "colors enabled by default in a terminal",
 			envVars:     map[string]string{},
 			expectColor: true,
 		},
@@ -51,7 +67,7 @@ func TestColorConfiguration(t *testing.T) {
 			}
 
 			// Reset color package state
-			color.NoColor = false
+			ansi.NoColor = false
 
 			// Create formatter and test
 			formatter := NewVisualFormatter()
@@ -72,6 +88,44 @@ func TestColorConfiguration(t *testing.T) {
 	}
 }
 
+func TestShouldEnableColors(t *testing.T) {
+	tests := []struct {
+		name     string
+		envVars  map[string]string
+		terminal bool
+		want     bool
+	}{
+		{name: "terminal", terminal: true, want: true},
+		{name: "redirected output", terminal: false, want: false},
+		{name: "dumb terminal", envVars: map[string]string{"TERM": "dumb"}, terminal: true, want: false},
+		{name: "GitHub Actions", envVars: map[string]string{"CI": "true", "GITHUB_ACTIONS": "true"}, want: true},
+		{name: "GitLab CI", envVars: map[string]string{"CI": "true", "GITLAB_CI": "true"}, want: true},
+		{name: "other CI", envVars: map[string]string{"CI": "true"}, terminal: true, want: false},
+		{name: "NO_COLOR on GitHub Actions", envVars: map[string]string{"GITHUB_ACTIONS": "true", "NO_COLOR": "1"}, want: false},
+		{name: "FORCE_COLOR in CI", envVars: map[string]string{"CI": "true", "FORCE_COLOR": "1"}, want: true},
+		{name: "always", envVars: map[string]string{"DIAGASSERT_COLOR": "always", "NO_COLOR": "1"}, want: true},
+		{name: "never", envVars: map[string]string{"DIAGASSERT_COLOR": "never", "FORCE_COLOR": "1"}, terminal: true, want: false},
+		{name: "auto", envVars: map[string]string{"DIAGASSERT_COLOR": "auto"}, terminal: true, want: true},
+	}
+
+	original := stdoutIsColorTerminal
+	defer func() { stdoutIsColorTerminal = original }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envVars {
+				t.Setenv(key, value)
+			}
+			terminal := tt.terminal
+			stdoutIsColorTerminal = func() bool { return terminal }
+
+			if got := shouldEnableColors(loadConfig()); got != tt.want {
+				t.Errorf("shouldEnableColors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestColorOutput(t *testing.T) {
 	// Save original NO_COLOR state
 	originalNoColor := os.Getenv("NO_COLOR")
@@ -119,7 +173,7 @@ func TestColorOutput(t *testing.T) {
 			}
 
 			// Reset color package state
-			color.NoColor = false
+			ansi.NoColor = false
 
 			// Create formatter
 			formatter := NewVisualFormatter()
@@ -193,7 +247,7 @@ func TestColorHelperFunctions(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Set color configuration
 			formatter.colorConfig.ColorsEnabled = !tt.noColor
-			color.NoColor = tt.noColor
+			ansi.NoColor = tt.noColor
 
 			result := tt.function(tt.input)
 
@@ -248,7 +302,7 @@ func TestColorizeValue(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			formatter.colorConfig.ColorsEnabled = true
-			color.NoColor = false
+			ansi.NoColor = false
 
 			result := formatter.colorizeValue(tt.value, tt.isOperator)
 
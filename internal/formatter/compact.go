@@ -0,0 +1,64 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// formatCompactLine formats a failure on one line of logfmt-style pairs for the compact
+// style, as in
+//
+//	calc_test.go:12 "x > 20"=false x=10 cause="x > 20"
+//
+// the expression first, then the values of its variables by name, the operand that made
+// it fail and the custom message. Keys and values holding spaces, quotes or = are quoted.
+func formatCompactLine(result *evaluator.ExpressionResult, file string, line int, failingNode *evaluator.EvaluationTree, customMessage string) string {
+	variables := make(map[string]string, len(result.Variables))
+	for name, value := range result.Variables {
+		variables[name] = valueText(value)
+	}
+	cause := ""
+	if failingNode != nil {
+		cause = failingNode.Text
+	}
+	return CompactLine(file, line, result.Expression, result.Result, variables, cause, customMessage)
+}
+
+// CompactLine formats a failure on one line as the compact style does, from the text of
+// its variables, so that failures read back from saved logs are shown the same way. The
+// cause and message are left out when empty.
+func CompactLine(file string, line int, expr string, result bool, variables map[string]string, cause, message string) string {
+	parts := []string{
+		fmt.Sprintf("%s:%d", file, line),
+		logfmtText(expr) + "=" + strconv.FormatBool(result),
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, logfmtText(name)+"="+logfmtText(variables[name]))
+	}
+
+	if cause != "" {
+		parts = append(parts, "cause="+logfmtText(cause))
+	}
+	if message != "" {
+		parts = append(parts, "msg="+logfmtText(message))
+	}
+	return strings.Join(parts, " ")
+}
+
+// logfmtText quotes s when it would not read back as a single key or value.
+func logfmtText(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
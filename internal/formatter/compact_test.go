@@ -0,0 +1,64 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+func TestCompactStyle(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_STYLE", "compact")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+
+	formatter := NewVisualFormatter()
+	result := evaluator.EvaluateWithValues("x > 20", false, 0, map[string]interface{}{"x": 10})
+	output := formatter.FormatVisual(result, "calc_test.go", 12, "too small")
+
+	first, rest, _ := strings.Cut(output, "\n")
+	want := `calc_test.go:12 "x > 20"=false x=10 cause="x > 20" msg="too small"`
+	if first != want {
+		t.Errorf("first line = %q, want %q", first, want)
+	}
+
+	// The diagram is left out, the machine-readable section is kept in full
+	if strings.Contains(output, "assert(") {
+		t.Errorf("compact output should have no diagram:\n%s", output)
+	}
+	for _, line := range []string{"[MACHINE_READABLE_START]", "EXPR: x > 20", "LOCATION: calc_test.go:12", "FAILING_NODE: x > 20", "CUSTOM_MESSAGE: too small"} {
+		if !strings.Contains(rest, line+"\n") {
+			t.Errorf("machine-readable section misses %q:\n%s", line, output)
+		}
+	}
+}
+
+func TestCompactStyle_WithoutMachineReadable(t *testing.T) {
+	t.Setenv("DIAGASSERT_STYLE", "compact")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "false")
+
+	formatter := NewVisualFormatter()
+	result := evaluator.EvaluateWithValues("n == 3", false, 0, map[string]interface{}{"n": 2})
+	output := formatter.FormatVisual(result, "a_test.go", 3, "")
+
+	if want := "a_test.go:3 \"n == 3\"=false n=2 cause=\"n == 3\"\n"; output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+}
+
+func TestLogfmtText(t *testing.T) {
+	tests := map[string]string{
+		"x":         "x",
+		"user.Age":  "user.Age",
+		"":          `""`,
+		"a b":       `"a b"`,
+		"a=b":       `"a=b"`,
+		`say "hi"`:  `"say \"hi\""`,
+		"two\nline": `"two\nline"`,
+	}
+	for in, want := range tests {
+		if got := logfmtText(in); got != want {
+			t.Errorf("logfmtText(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
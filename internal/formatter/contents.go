@@ -0,0 +1,136 @@
+package formatter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// previewElements reads DIAGASSERT_PREVIEW_ELEMENTS, the number of elements of a container
+// shown in the CONTENTS section: 10 by default.
+func previewElements() int {
+	n, ok := config.Int("DIAGASSERT_PREVIEW_ELEMENTS")
+	if !ok || n < 0 {
+		return 10
+	}
+	return n
+}
+
+// collectContents previews the containers whose length the failing node depends on, one
+// line each, e.g. `items (12 elements): ["a" "b" "c" … +9 more]`. A length alone rarely
+// tells why it is wrong; the elements usually do.
+func collectContents(failing *evaluator.EvaluationTree) []string {
+	var contents []string
+	seen := make(map[string]bool)
+
+	var walk func(node *evaluator.EvaluationTree)
+	walk = func(node *evaluator.EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+
+		if node.Type != "call" || len(node.Children) != 1 || !strings.HasPrefix(node.Text, "len(") {
+			return
+		}
+		container := node.Children[0]
+		if seen[container.Text] || !evaluator.HasKnownResult(container) {
+			return
+		}
+		if preview, ok := containerPreview(container.Value, previewElements()); ok {
+			seen[container.Text] = true
+			contents = append(contents, container.Text+" "+preview)
+		}
+	}
+
+	walk(failing)
+	return contents
+}
+
+// containerPreview shows the size of a slice, array, map or string followed by its first n
+// elements, entries or runes. ok is false for other values, whose contents cannot be listed.
+func containerPreview(v interface{}, n int) (string, bool) {
+	if _, redacted := v.(evaluator.Redacted); redacted || v == nil {
+		return "", false
+	}
+
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		elems := make([]string, 0, n)
+		for i := 0; i < val.Len() && i < n; i++ {
+			elems = append(elems, elementText(val.Index(i)))
+		}
+		return fmt.Sprintf("(%s): [%s]", countText(val.Len(), "element"),
+			withRemainder(elems, val.Len())), true
+	case reflect.Map:
+		entries := make([]string, 0, val.Len())
+		iter := val.MapRange()
+		for iter.Next() {
+			entries = append(entries, elementText(iter.Key())+":"+elementText(iter.Value()))
+		}
+		sort.Strings(entries)
+		if len(entries) > n {
+			entries = entries[:n]
+		}
+		return fmt.Sprintf("(%s): map[%s]", countText(val.Len(), "entry"),
+			withRemainder(entries, val.Len())), true
+	case reflect.String:
+		runes := []rune(val.String())
+		text := strconv.Quote(string(runes))
+		if len(runes) > n*8 {
+			// Strings are cut at a line's worth of runes rather than n of them
+			text = strconv.Quote(string(runes[:n*8])) + " …"
+		}
+		return fmt.Sprintf("(%s): %s", countText(val.Len(), "byte"), text), true
+	}
+	return "", false
+}
+
+// elementText formats an element of a container: strings are quoted, so that empty and
+// blank ones can be seen, and other values are shown as in full, see valueText.
+func elementText(v reflect.Value) string {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	if !v.CanInterface() {
+		return fieldsText(v, pointerDepth(), 1, true)
+	}
+	return valueText(v.Interface())
+}
+
+// withRemainder joins the shown elements of a container of total elements and says how
+// many more there are.
+func withRemainder(shown []string, total int) string {
+	text := strings.Join(shown, " ")
+	if rest := total - len(shown); rest > 0 {
+		if text != "" {
+			text += " "
+		}
+		text += fmt.Sprintf("… +%d more", rest)
+	}
+	return text
+}
+
+// countText formats a count of things, such as "1 element" or "12 entries".
+func countText(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if strings.HasSuffix(noun, "y") {
+		return fmt.Sprintf("%d %sies", n, strings.TrimSuffix(noun, "y"))
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
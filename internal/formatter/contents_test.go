@@ -0,0 +1,54 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+func TestContainerPreview(t *testing.T) {
+	type point struct{ X, Y int }
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+		ok    bool
+	}{
+		{"short slice", []string{"a", ""}, `(2 elements): ["a" ""]`, true},
+		{"long slice", []int{1, 2, 3, 4, 5}, "(5 elements): [1 2 3 … +2 more]", true},
+		{"one element", [1]point{{1, 2}}, "(1 element): [{1 2}]", true},
+		{"interfaces", []interface{}{"a", 1}, `(2 elements): ["a" 1]`, true},
+		{"map", map[string]int{"b": 2, "a": 1, "c": 3, "d": 4}, `(4 entries): map["a":1 "b":2 "c":3 … +1 more]`, true},
+		{"string", "hello", `(5 bytes): "hello"`, true},
+		{"long string", "abcdefghijklmnopqrstuvwxyz", `(26 bytes): "abcdefghijklmnopqrstuvwx" …`, true},
+		{"number", 42, "", false},
+		{"nil", nil, "", false},
+		{"redacted", evaluator.Redacted("secret"), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := containerPreview(tt.value, 3)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("containerPreview(%v) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCollectContents(t *testing.T) {
+	items := &evaluator.EvaluationTree{Type: "selector", Text: "o.Items", Value: []int{1, 2}}
+	tree := &evaluator.EvaluationTree{
+		Type:     "comparison",
+		Operator: "==",
+		Text:     "len(o.Items) == len(o.Items)",
+		Left:     &evaluator.EvaluationTree{Type: "call", Text: "len(o.Items)", Value: 2, Children: []*evaluator.EvaluationTree{items}},
+		Right:    &evaluator.EvaluationTree{Type: "call", Text: "len(o.Items)", Value: 2, Children: []*evaluator.EvaluationTree{items}},
+	}
+
+	want := []string{"o.Items (2 elements): [1 2]"}
+	if got := collectContents(tree); !reflect.DeepEqual(got, want) {
+		t.Errorf("collectContents = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,241 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/textwidth"
+)
+
+// Diff styles selected with DIAGASSERT_DIFF_STYLE.
+const (
+	diffStyleUnified = "unified"
+	diffStyleSplit   = "split"
+)
+
+// maxDiffCells bounds the size of the line-diff table; larger inputs are shown
+// as a full replacement instead.
+const maxDiffCells = 1 << 20
+
+// diffOp is the kind of a line in a line-based diff.
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffLine is one line of a line-based diff.
+type diffLine struct {
+	Op   diffOp
+	Text string
+}
+
+// getDiffStyle reads DIAGASSERT_DIFF_STYLE, defaulting to unified.
+func getDiffStyle() string {
+	if config.Getenv("DIAGASSERT_DIFF_STYLE") == diffStyleSplit {
+		return diffStyleSplit
+	}
+	return diffStyleUnified
+}
+
+// prettyText returns the multi-line rendering of a string or []byte value: valid JSON
+// objects and arrays are indented, other text is returned as is. The boolean is false
+// when the value is not text or fits on one line.
+func prettyText(v interface{}) (string, bool) {
+	var raw []byte
+	switch t := v.(type) {
+	case string:
+		raw = []byte(t)
+	case []byte:
+		raw = t
+	default:
+		return "", false
+	}
+
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		var out bytes.Buffer
+		if err := json.Indent(&out, trimmed, "", "  "); err == nil {
+			return out.String(), true
+		}
+	}
+
+	text := string(raw)
+	return text, strings.Contains(text, "\n")
+}
+
+// textOperands returns the pretty-printed operands of a failed == between two text values
+// when at least one of them spans several lines or is JSON.
+func textOperands(node *evaluator.EvaluationTree) (string, string, bool) {
+	if node.Type != "comparison" || node.Operator != "==" || node.Left == nil || node.Right == nil {
+		return "", "", false
+	}
+
+	left, leftPretty := prettyText(node.Left.Value)
+	right, rightPretty := prettyText(node.Right.Value)
+	if !leftPretty && !rightPretty {
+		return "", "", false
+	}
+	if _, ok := textValue(node.Left.Value); !ok {
+		return "", "", false
+	}
+	if _, ok := textValue(node.Right.Value); !ok {
+		return "", "", false
+	}
+	return left, right, true
+}
+
+func textValue(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case []byte:
+		return string(t), true
+	}
+	return "", false
+}
+
+// diffLines computes a line-based diff of a and b from their longest common subsequence.
+func diffLines(a, b []string) []diffLine {
+	if len(a)*len(b) > maxDiffCells {
+		var lines []diffLine
+		for _, text := range a {
+			lines = append(lines, diffLine{diffDelete, text})
+		}
+		for _, text := range b {
+			lines = append(lines, diffLine{diffInsert, text})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{diffEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{diffDelete, a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{diffInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{diffDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{diffInsert, b[j]})
+	}
+	return lines
+}
+
+// formatUnifiedDiff renders a diff with "-" and "+" markers, one line per entry.
+func formatUnifiedDiff(leftName, rightName string, lines []diffLine) []string {
+	out := []string{"--- " + leftName, "+++ " + rightName}
+	for _, line := range lines {
+		switch line.Op {
+		case diffEqual:
+			out = append(out, "  "+line.Text)
+		case diffDelete:
+			out = append(out, "- "+line.Text)
+		case diffInsert:
+			out = append(out, "+ "+line.Text)
+		}
+	}
+	return out
+}
+
+// formatSplitDiff renders a diff side by side. As in sdiff, "|" marks a changed line,
+// "<" a line only on the left and ">" a line only on the right.
+func formatSplitDiff(leftName, rightName string, lines []diffLine) []string {
+	type row struct {
+		left, right string
+		marker      string
+	}
+
+	var rows []row
+	for i := 0; i < len(lines); {
+		if lines[i].Op == diffEqual {
+			rows = append(rows, row{lines[i].Text, lines[i].Text, " "})
+			i++
+			continue
+		}
+
+		// Pair a run of deletions with the insertions that follow it
+		var deleted, inserted []string
+		for ; i < len(lines) && lines[i].Op == diffDelete; i++ {
+			deleted = append(deleted, lines[i].Text)
+		}
+		for ; i < len(lines) && lines[i].Op == diffInsert; i++ {
+			inserted = append(inserted, lines[i].Text)
+		}
+		for k := 0; k < len(deleted) || k < len(inserted); k++ {
+			switch {
+			case k < len(deleted) && k < len(inserted):
+				rows = append(rows, row{deleted[k], inserted[k], "|"})
+			case k < len(deleted):
+				rows = append(rows, row{deleted[k], "", "<"})
+			default:
+				rows = append(rows, row{"", inserted[k], ">"})
+			}
+		}
+	}
+
+	width := textwidth.String(leftName)
+	for _, r := range rows {
+		if w := textwidth.String(r.left); w > width {
+			width = w
+		}
+	}
+
+	pad := func(s string) string {
+		return s + strings.Repeat(" ", width-textwidth.String(s))
+	}
+
+	out := []string{pad(leftName) + "   " + rightName}
+	for _, r := range rows {
+		out = append(out, strings.TrimRight(fmt.Sprintf("%s %s %s", pad(r.left), r.marker, r.right), " "))
+	}
+	return out
+}
+
+// formatTextDiff renders the line diff of a failed text comparison in the configured style.
+func (f *VisualFormatter) formatTextDiff(node *evaluator.EvaluationTree, style string) []string {
+	left, right, ok := textOperands(node)
+	if !ok {
+		return nil
+	}
+
+	lines := diffLines(strings.Split(left, "\n"), strings.Split(right, "\n"))
+	if style == diffStyleSplit {
+		return formatSplitDiff(node.Left.Text, node.Right.Text, lines)
+	}
+	return formatUnifiedDiff(node.Left.Text, node.Right.Text, lines)
+}
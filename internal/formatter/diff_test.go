@@ -0,0 +1,158 @@
+package formatter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+func TestPrettyText(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      interface{}
+		expected   string
+		expectedOK bool
+	}{
+		{
+			name:       "json object",
+			value:      `{"a":1,"b":[true]}`,
+			expected:   "{\n  \"a\": 1,\n  \"b\": [\n    true\n  ]\n}",
+			expectedOK: true,
+		},
+		{
+			name:       "json bytes",
+			value:      []byte(`[1,2]`),
+			expected:   "[\n  1,\n  2\n]",
+			expectedOK: true,
+		},
+		{
+			name:       "multi-line string",
+			value:      "a\nb",
+			expected:   "a\nb",
+			expectedOK: true,
+		},
+		{
+			name:       "single-line string",
+			value:      "hello",
+			expected:   "hello",
+			expectedOK: false,
+		},
+		{
+			name:       "json scalar is plain text",
+			value:      "42",
+			expected:   "42",
+			expectedOK: false,
+		},
+		{
+			name:       "not text",
+			value:      42,
+			expectedOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := prettyText(tt.value)
+			if ok != tt.expectedOK || got != tt.expected {
+				t.Errorf("prettyText() = %q, %v, want %q, %v", got, ok, tt.expected, tt.expectedOK)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	expected := []diffLine{
+		{diffEqual, "a"},
+		{diffDelete, "b"},
+		{diffInsert, "x"},
+		{diffEqual, "c"},
+		{diffInsert, "d"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("diffLines() = %v, want %v", got, expected)
+	}
+}
+
+func TestFormatTextDiff(t *testing.T) {
+	node := &evaluator.EvaluationTree{
+		Type:     "comparison",
+		Operator: "==",
+		Text:     "got == want",
+		Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "got", Value: "one\ntwo\nthree"},
+		Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "want", Value: "one\n2\nthree\nfour"},
+	}
+	formatter := NewVisualFormatter()
+
+	tests := []struct {
+		style    string
+		expected []string
+	}{
+		{
+			style: diffStyleUnified,
+			expected: []string{
+				"--- got",
+				"+++ want",
+				"  one",
+				"- two",
+				"+ 2",
+				"  three",
+				"+ four",
+			},
+		},
+		{
+			style: diffStyleSplit,
+			expected: []string{
+				"got     want",
+				"one     one",
+				"two   | 2",
+				"three   three",
+				"      > four",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			got := formatter.formatTextDiff(node, tt.style)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("formatTextDiff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestVisualFormatter_LineDiff(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	t.Setenv("DIAGASSERT_DIFF_STYLE", "split")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "got == want",
+		Tree: &evaluator.EvaluationTree{
+			Type:        "comparison",
+			Operator:    "==",
+			Text:        "got == want",
+			Left:        &evaluator.EvaluationTree{Type: "identifier", Text: "got", Value: `{"age":30}`},
+			Right:       &evaluator.EvaluationTree{Type: "identifier", Text: "want", Value: `{"age":31}`},
+			Differences: []string{"len 10 != 10"},
+		},
+	}
+	ctx := &AssertionContext{Values: []Value{{Name: "got", Value: `{"age":30}`}}}
+
+	output := formatter.FormatVisualWithContext(result, "test.go", 1, "", ctx)
+
+	expected := []string{
+		"LINE DIFF:\n  got           want\n  {             {\n    \"age\": 30 |   \"age\": 31\n  }             }\n",
+		"LINE_DIFF_START: got == want\n--- got\n+++ want\n  {\n-   \"age\": 30\n+   \"age\": 31\n  }\nLINE_DIFF_END\n",
+		"CAPTURED VALUES:\n  got = (string)\n    {\n      \"age\": 30\n    }\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+}
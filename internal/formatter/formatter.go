@@ -3,13 +3,24 @@ package formatter
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/paveg/diagassert/internal/evaluator"
 )
 
+// SchemaVersion is the version of the machine-readable section's format, written as its
+// SCHEMA_VERSION line. It is incremented whenever a line is removed or changes meaning;
+// new lines may be added without a change, so parsers should ignore keys they do not know.
+//
+// Version 1 added SCHEMA_VERSION, EXPR_ID, FAILING_NODE_ID and the "[node <id>]" suffix of
+// evaluation steps. Node IDs are hashes of the expression and the node's position, so they
+// are the same in every run.
+//
+// Version 2 added LOCATION, so that parsers can find the file and line without reading the
+// header, which DIAGASSERT_LANG translates.
+const SchemaVersion = 2
+
 // Options contains configuration options for formatting output.
 type Options struct {
 	IncludeMachineReadable bool
@@ -21,15 +32,18 @@ func BuildDiagnosticOutput(file string, line int, expr string, opts Options) str
 	var b strings.Builder
 
 	// Build the basic failure message
-	b.WriteString(fmt.Sprintf("ASSERTION FAILED at %s:%d\n", filepath.Base(file), line))
+	b.WriteString(Message(MsgAssertionFailed, filepath.Base(file), line) + "\n")
 	b.WriteString(fmt.Sprintf("Expression: %s\n", expr))
 	b.WriteString("Result: false\n")
 
 	// Machine-readable section (controlled by environment variable)
 	if opts.IncludeMachineReadable {
 		b.WriteString("\n[MACHINE_READABLE_START]\n")
-		b.WriteString(fmt.Sprintf("EXPR: %s\n", expr))
+		b.WriteString(fmt.Sprintf("SCHEMA_VERSION: %d\n", SchemaVersion))
+		b.WriteString(fmt.Sprintf("EXPR: %s\n", evaluator.IdentityExpression(expr)))
+		b.WriteString(fmt.Sprintf("EXPR_ID: %s\n", evaluator.ExprID(evaluator.IdentityExpression(expr))))
 		b.WriteString("RESULT: false\n")
+		b.WriteString(fmt.Sprintf("LOCATION: %s:%d\n", filepath.Base(file), line))
 		b.WriteString("[MACHINE_READABLE_END]\n")
 	}
 
@@ -39,8 +53,19 @@ func BuildDiagnosticOutput(file string, line int, expr string, opts Options) str
 // AssertionContext represents the context information for assertions (imported from main package).
 // This is defined here to avoid circular imports while allowing the formatter to handle context.
 type AssertionContext struct {
-	Values   []Value  // Captured values using V() or Values{}
-	Messages []string // Custom messages
+	Values      []Value      // Captured values using V() or Values{}
+	Messages    []string     // Custom messages
+	Attachments []Attachment // Artifacts passed with Attach()
+	Sections    []Section    // Extra diagnostics contributed by helper packages
+	Owners      []string     // Owners of the failing file, from DIAGASSERT_CODEOWNERS
+}
+
+// Section is a titled block of diagnostic lines, such as the HTTP exchange behind a failed
+// status check. It is rendered after the captured values and mirrored in the machine-readable
+// section between <TITLE>_START and <TITLE>_END markers.
+type Section struct {
+	Title string   `json:"title"` // Heading in upper case, e.g. "HTTP RESPONSE"
+	Lines []string `json:"lines"`
 }
 
 // Value represents a named value for diagnostic output.
@@ -49,6 +74,14 @@ type Value struct {
 	Value interface{}
 }
 
+// Attachment describes an artifact carried by a failure.
+type Attachment struct {
+	Name string
+	MIME string
+	Size int    // Size of the content in bytes
+	Path string // Where the content was written; empty if writing failed
+}
+
 // BuildDiagnosticOutputWithEvaluator constructs enhanced diagnostic output using evaluator results.
 func BuildDiagnosticOutputWithEvaluator(file string, line int, result *evaluator.ExpressionResult, opts Options) string {
 	return BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result, nil, opts)
@@ -106,9 +139,7 @@ func getCombinedMessage(ctx *AssertionContext) string {
 
 // ShouldIncludeMachineReadable determines whether to include machine-readable sections.
 func ShouldIncludeMachineReadable() bool {
-	// Controlled by environment variable (default is true)
-	env := os.Getenv("DIAGASSERT_MACHINE_READABLE")
-	return env != "false"
+	return loadConfig().MachineReadable
 }
 
 // GetDefaultOptions returns the default formatting options.
@@ -48,7 +48,9 @@ func TestBuildDiagnosticOutput(t *testing.T) {
 				"Expression: x > 20",
 				"Result: false",
 				"[MACHINE_READABLE_START]",
+				"SCHEMA_VERSION: 2",
 				"EXPR: x > 20",
+				"EXPR_ID: ",
 				"RESULT: false",
 				"[MACHINE_READABLE_END]",
 			},
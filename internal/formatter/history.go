@@ -0,0 +1,56 @@
+package formatter
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+var (
+	historyMu sync.RWMutex
+	history   func(fingerprint string) (failed, runs int)
+)
+
+// SetHistory installs the function that returns in how many of the last recorded runs the
+// failure with a fingerprint failed, counting the current run as failed. Recording failures
+// is left to the caller, which knows whether the test reports them. Package diagassert
+// installs it; without it failures have no history.
+func SetHistory(fn func(fingerprint string) (failed, runs int)) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history = fn
+}
+
+// Fingerprint identifies the failure of result at file and line across runs by the
+// expression, its failing operand and the location, as FINGERPRINT does in the
+// machine-readable section.
+func Fingerprint(result *evaluator.ExpressionResult, file string, line int) string {
+	return fingerprintOf(result, evaluator.FindFailingNode(result.Tree), file, line)
+}
+
+// fingerprintOf is Fingerprint for a result whose failing node is already known.
+func fingerprintOf(result *evaluator.ExpressionResult, failingNode *evaluator.EvaluationTree, file string, line int) string {
+	failingText := ""
+	if failingNode != nil {
+		failingText = failingNode.Text
+	}
+	return evaluator.Fingerprint(evaluator.IdentityExpression(result.Expression), evaluator.IdentityExpression(failingText), filepath.Base(file), line)
+}
+
+// failureHistory returns the recent history of a failure, 0 runs when none is kept.
+func failureHistory(fingerprint string) (failed, runs int) {
+	historyMu.RLock()
+	fn := history
+	historyMu.RUnlock()
+	if fn == nil {
+		return 0, 0
+	}
+	return fn(fingerprint)
+}
+
+// possiblyFlaky reports whether a failure seen before also passed in recent runs, and so
+// may fail intermittently.
+func possiblyFlaky(failed, runs int) bool {
+	return failed >= 2 && failed < runs
+}
@@ -0,0 +1,76 @@
+package formatter
+
+import "github.com/paveg/diagassert/internal/config"
+
+// Layouts selected with DIAGASSERT_LAYOUT.
+const (
+	layoutPriority = "priority"
+	layoutStable   = "stable"
+)
+
+// getLayout reads DIAGASSERT_LAYOUT, defaulting to the priority layout.
+func getLayout() string {
+	if config.Getenv("DIAGASSERT_LAYOUT") == layoutStable {
+		return layoutStable
+	}
+	return layoutPriority
+}
+
+// orderForLayers sorts nodes into the order they are given layers in, each taking the
+// lowest layer it fits in.
+//
+// The priority layout places identifiers first, then field values, literals, call
+// results and operator results, each group left to right.
+//
+// The stable layout follows the shape of the expression instead, so that a value keeps
+// its layer when other parts of a similar expression change:
+//
+//  1. operands before operators, every value and call result before any operator result,
+//     which start on the layer below the last operand;
+//  2. bottom-up, the deepest sub-expressions before those enclosing them;
+//  3. left to right;
+//  4. by text and value, for nodes starting at the same column.
+func (f *VisualFormatter) orderForLayers(nodes []VisualNode) {
+	if f.layout != layoutStable {
+		sortStable(nodes, priorityLess)
+		return
+	}
+	sortStable(nodes, stableLess)
+}
+
+// sortStable sorts s by less, keeping the order of equal elements. A diagram holds a few
+// dozen values at most, so an insertion sort does, without the allocation sort.Stable makes
+// to box s for every failure.
+func sortStable[T any](s []T, less func(a, b *T) bool) {
+	for i := 1; i < len(s); i++ {
+		for j := i; j > 0 && less(&s[j], &s[j-1]); j-- {
+			s[j], s[j-1] = s[j-1], s[j]
+		}
+	}
+}
+
+// priorityLess orders nodes for the priority layout.
+func priorityLess(a, b *VisualNode) bool {
+	if a.Position.Priority != b.Position.Priority {
+		return a.Position.Priority > b.Position.Priority
+	}
+	return a.PipePosition < b.PipePosition
+}
+
+// stableLess orders nodes for the stable layout.
+func stableLess(x, y *VisualNode) bool {
+	a, b := &x.Position, &y.Position
+	if a.Operator != b.Operator {
+		return !a.Operator
+	}
+	if a.Depth != b.Depth {
+		return a.Depth > b.Depth
+	}
+	if x.PipePosition != y.PipePosition {
+		return x.PipePosition < y.PipePosition
+	}
+	if a.Expression != b.Expression {
+		return a.Expression < b.Expression
+	}
+	return a.Value < b.Value
+}
@@ -0,0 +1,99 @@
+package formatter
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/layout")
+
+// TestStableLayout_Golden locks the diagrams of the stable layout. Run with -update to
+// rewrite the files after an intended change, and review the diff.
+func TestStableLayout_Golden(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_PIPE_COLORS", "false")
+	t.Setenv("DIAGASSERT_MAX_WIDTH", "")
+	t.Setenv("COLUMNS", "")
+	t.Setenv("DIAGASSERT_LAYOUT", "stable")
+
+	tests := []struct {
+		name   string
+		expr   string
+		values map[string]interface{}
+	}{
+		{"comparison", "x > 20", map[string]interface{}{"x": 15}},
+		{"arithmetic", "a + b == c", map[string]interface{}{"a": 1, "b": 2, "c": 4}},
+		{"arithmetic_longer_operand", "alpha + b == c", map[string]interface{}{"alpha": 1, "b": 2, "c": 4}},
+		{"logical", "age >= 18 && name == \"admin\"", map[string]interface{}{"age": 16, "name": "guest"}},
+		{"nested", "(a + b) * c > limit", map[string]interface{}{"a": 100, "b": 200, "c": 3, "limit": 1000}},
+		{"same_column", "len(items) == n", map[string]interface{}{"items": []int{1, 2}, "n": 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := NewVisualFormatter()
+			result := evaluator.EvaluateWithValues(tt.expr, false, 0, tt.values)
+			got := formatter.formatPowerAssertStyle(result)
+
+			path := filepath.Join("testdata", "layout", tt.name+".golden")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading golden file: %v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("diagram differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+			}
+		})
+	}
+}
+
+func TestStableLayout_Order(t *testing.T) {
+	formatter := &VisualFormatter{layout: layoutStable}
+
+	// Given in the priority layout's order: operators after everything else
+	nodes := []VisualNode{
+		{Position: ValuePosition{Expression: "b", Depth: 2, Priority: 20}, PipePosition: 4},
+		{Position: ValuePosition{Expression: "c", Depth: 1, Priority: 20}, PipePosition: 9},
+		{Position: ValuePosition{Expression: "a", Depth: 2, Priority: 20}, PipePosition: 0},
+		{Position: ValuePosition{Expression: "==", Depth: 1, Priority: 5, Operator: true}, PipePosition: 6},
+		{Position: ValuePosition{Expression: "+", Depth: 2, Priority: 5, Operator: true}, PipePosition: 2},
+		{Position: ValuePosition{Expression: "len(x)", Depth: 2, Priority: 10}, PipePosition: 12},
+	}
+	formatter.orderForLayers(nodes)
+
+	var got []string
+	for _, node := range nodes {
+		got = append(got, node.Position.Expression)
+	}
+	want := "a b len(x) c + =="
+	if strings.Join(got, " ") != want {
+		t.Errorf("order = %v, want %s", got, want)
+	}
+}
+
+func TestGetLayout(t *testing.T) {
+	for setting, want := range map[string]string{
+		"":         layoutPriority,
+		"priority": layoutPriority,
+		"stable":   layoutStable,
+		"unknown":  layoutPriority,
+	} {
+		t.Setenv("DIAGASSERT_LAYOUT", setting)
+		if got := getLayout(); got != want {
+			t.Errorf("getLayout() with %q = %q, want %q", setting, got, want)
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// MachineRecord is the content of the machine-readable section of a failure, which an
+// Encoder writes between [MACHINE_READABLE_START] and [MACHINE_READABLE_END].
+type MachineRecord struct {
+	SchemaVersion int               `json:"schema_version"`
+	Expr          string            `json:"expr"`
+	ExprID        string            `json:"expr_id"`
+	Result        bool              `json:"result"`
+	Mismatch      []string          `json:"mismatch,omitempty"` // Possible causes of a diagram giving another result
+	Variables     map[string]string `json:"variables,omitempty"`
+	StaticTypes   map[string]string `json:"static_types,omitempty"`
+	Sources       map[string]string `json:"sources,omitempty"` // Provenance of operand values, as "user"
+	Steps         []MachineStep     `json:"steps,omitempty"`   // Nil without an evaluation tree
+	ShortCircuits []string          `json:"short_circuits,omitempty"`
+	File          string            `json:"file"`
+	Line          int               `json:"line"`
+	Fingerprint   string            `json:"fingerprint"`
+	History       *MachineHistory   `json:"history,omitempty"`
+	Owners        []string          `json:"owners,omitempty"`
+	Failing       *MachineFailing   `json:"failing,omitempty"`
+	Contents      []string          `json:"contents,omitempty"`
+	Notes         []string          `json:"notes,omitempty"`
+	Errors        []string          `json:"errors,omitempty"`
+	Match         *MachineMatch     `json:"match,omitempty"`
+	Diffs         []MachineDiff     `json:"diffs,omitempty"`
+	Message       string            `json:"message,omitempty"`
+	Values        []MachineValue    `json:"values,omitempty"`
+	Attachments   []MachineFile     `json:"attachments,omitempty"`
+	Sections      []Section         `json:"sections,omitempty"`
+	Hints         []string          `json:"hints,omitempty"`
+}
+
+// MachineStep is one step of the evaluation, such as "`x > 10` with 5 > 10 => false".
+type MachineStep struct {
+	Text   string `json:"text"`
+	NodeID string `json:"node_id"`
+}
+
+// MachineHistory is how often a failure failed in the recent runs recorded with
+// DIAGASSERT_HISTORY.
+type MachineHistory struct {
+	Failed int `json:"failed"`
+	Runs   int `json:"runs"`
+}
+
+// MachineFailing is the operand that made the assertion fail.
+type MachineFailing struct {
+	Reason string `json:"reason"`
+	Node   string `json:"node"`
+	NodeID string `json:"node_id"`
+}
+
+// MachineMatch is a matcher's account of why it rejected a value.
+type MachineMatch struct {
+	Matcher  string   `json:"matcher"`
+	Expected string   `json:"expected,omitempty"`
+	Found    string   `json:"found,omitempty"`
+	Details  []string `json:"details,omitempty"`
+}
+
+// MachineDiff lists where the operands of a failed comparison diverge.
+type MachineDiff struct {
+	Expr        string   `json:"expr"`
+	Differences []string `json:"differences,omitempty"`
+	LineDiff    []string `json:"line_diff,omitempty"` // Unified diff of multi-line and JSON operands
+}
+
+// MachineValue is a value captured with V() or Values{}.
+type MachineValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// MachineFile is an attachment and the file it was written to.
+type MachineFile struct {
+	Name string `json:"name"`
+	MIME string `json:"mime"`
+	Size int    `json:"size"`
+	Path string `json:"path"`
+}
+
+// Encoder writes machine-readable records in a format of its own. Output of a registered
+// encoder that is not valid UTF-8, as binary formats such as protobuf or CBOR produce, is
+// written as base64.
+type Encoder interface {
+	Encode(record *MachineRecord) ([]byte, error)
+}
+
+// EncoderFunc adapts a function to an Encoder.
+type EncoderFunc func(record *MachineRecord) ([]byte, error)
+
+// Encode calls fn(record).
+func (fn EncoderFunc) Encode(record *MachineRecord) ([]byte, error) {
+	return fn(record)
+}
+
+// Machine-readable formats selected with DIAGASSERT_MACHINE_FORMAT.
+const (
+	machineFormatText = "text"
+	machineFormatJSON = "json"
+	machineFormatYAML = "yaml"
+)
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		machineFormatText: textEncoder{},
+		machineFormatJSON: EncoderFunc(encodeJSON),
+		machineFormatYAML: EncoderFunc(encodeYAML),
+	}
+	registered = map[string]bool{} // Names of the encoders registered with RegisterEncoder
+)
+
+// RegisterEncoder makes an encoder available to DIAGASSERT_MACHINE_FORMAT under name,
+// replacing the one registered under it before, built-in ones included.
+func RegisterEncoder(name string, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[name] = encoder
+	registered[name] = true
+}
+
+// machineEncoder returns the encoder DIAGASSERT_MACHINE_FORMAT selects, the text one
+// when it names none, and whether it was registered rather than built in.
+func machineEncoder() (Encoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	name := config.Getenv("DIAGASSERT_MACHINE_FORMAT")
+	if encoder, ok := encoders[name]; ok {
+		return encoder, registered[name]
+	}
+	return encoders[machineFormatText], registered[machineFormatText]
+}
+
+// encodeMachineSection encodes a record with the selected encoder between the markers of
+// the machine-readable section. Should the encoder fail, the text format is used.
+func encodeMachineSection(record *MachineRecord) string {
+	var b strings.Builder
+	writeMachineSection(&b, record)
+	return b.String()
+}
+
+// writeMachineSection writes the section encodeMachineSection returns to b.
+func writeMachineSection(b *strings.Builder, record *MachineRecord) {
+	encoder, custom := machineEncoder()
+	if _, ok := encoder.(textEncoder); ok {
+		b.WriteString("[MACHINE_READABLE_START]\n")
+		writeText(b, record)
+		b.WriteString("[MACHINE_READABLE_END]\n")
+		return
+	}
+	data, err := encoder.Encode(record)
+	if err != nil {
+		data, _ = encodeText(record)
+		custom = false
+	}
+
+	b.WriteString("[MACHINE_READABLE_START]\n")
+	if !custom || utf8.Valid(data) {
+		b.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			b.WriteString("\n")
+		}
+	} else {
+		b.WriteString("BASE64: " + base64.StdEncoding.EncodeToString(data) + "\n")
+	}
+	b.WriteString("[MACHINE_READABLE_END]\n")
+}
+
+// machineRecord collects the machine-readable content of a failure, whose fingerprint and
+// history the caller has looked up.
+func (f *VisualFormatter) machineRecord(result *evaluator.ExpressionResult, file string, line int, customMessage string, ctx *AssertionContext, facts *failureFacts) *MachineRecord {
+	expr := evaluator.IdentityExpression(result.Expression)
+	record := &MachineRecord{
+		SchemaVersion: SchemaVersion,
+		Expr:          expr,
+		ExprID:        evaluator.ExprID(expr),
+		Result:        result.Result,
+		File:          file,
+		Line:          line,
+		Fingerprint:   facts.fingerprint,
+		Message:       customMessage,
+	}
+
+	if len(result.Variables) > 0 {
+		record.Variables = make(map[string]string, len(result.Variables))
+		for name, value := range result.Variables {
+			record.Variables[name] = machineValueText(value)
+		}
+	}
+	record.StaticTypes = collectStaticTypes(result.Tree)
+	record.Sources = collectSources(result.Tree)
+	// Like every field of the record, the causes are in English whatever the language
+	record.Mismatch = mismatchCauses(facts.mismatch, "en")
+	if result.Tree != nil {
+		record.Steps = evaluationSteps(result.Tree)
+		record.ShortCircuits = extractShortCircuits(result.Tree)
+	}
+
+	if facts.runs > 0 {
+		record.History = &MachineHistory{Failed: facts.failed, Runs: facts.runs}
+	}
+	if failingNode := facts.failingNode; failingNode != nil {
+		record.Failing = &MachineFailing{
+			Reason: describeFailure(failingNode),
+			Node:   failingNode.Text,
+			NodeID: failingNode.ID,
+		}
+		record.Contents = facts.contents
+	}
+	record.Notes = facts.notes
+	record.Errors = facts.errors
+
+	if result.Tree != nil && result.Tree.Explanation != nil {
+		explanation := result.Tree.Explanation
+		record.Match = &MachineMatch{
+			Matcher:  explanation.Matcher,
+			Expected: explanation.Expected,
+			Found:    explanation.Found,
+			Details:  explanation.Details,
+		}
+	}
+	for _, node := range facts.differences {
+		record.Diffs = append(record.Diffs, MachineDiff{
+			Expr:        node.Text,
+			Differences: node.Differences,
+			LineDiff:    f.formatTextDiff(node, diffStyleUnified),
+		})
+	}
+
+	if ctx != nil {
+		record.Owners = ctx.Owners
+		for _, value := range ctx.Values {
+			text := machineValueText(value.Value)
+			if _, compact, ok := renderValue(value.Value); ok {
+				text = compact
+			}
+			record.Values = append(record.Values, MachineValue{Name: value.Name, Value: text, Type: fmt.Sprintf("%T", value.Value)})
+		}
+		for _, a := range ctx.Attachments {
+			record.Attachments = append(record.Attachments, MachineFile{Name: a.Name, MIME: a.MIME, Size: a.Size, Path: a.Path})
+		}
+		record.Sections = ctx.Sections
+	}
+
+	record.Hints = facts.hints
+	return record
+}
+
+// textEncoder is the text format, which writeMachineSection writes straight into the
+// section instead of encoding first.
+type textEncoder struct{}
+
+// Encode encodes record as encodeText does.
+func (textEncoder) Encode(record *MachineRecord) ([]byte, error) {
+	return encodeText(record)
+}
+
+// encodeText writes a record as lines of "KEY: value", the default format read by
+// logparse. Bytes of values that are not valid UTF-8 are escaped as \xff.
+func encodeText(r *MachineRecord) ([]byte, error) {
+	var b strings.Builder
+	b.Grow(1024)
+	writeText(&b, r)
+	return []byte(b.String()), nil
+}
+
+// writeText writes r to b as encodeText encodes it.
+func writeText(b *strings.Builder, r *MachineRecord) {
+	// Fields are written without fmt, which would box every value: the section is written
+	// for every failure
+
+	writeField(b, "SCHEMA_VERSION", strconv.Itoa(r.SchemaVersion))
+	writeField(b, "EXPR", r.Expr)
+	writeField(b, "EXPR_ID", r.ExprID)
+	writeField(b, "RESULT", strconv.FormatBool(r.Result))
+	for _, cause := range r.Mismatch {
+		writeField(b, "MISMATCH", cause)
+	}
+	if len(r.Variables) > 0 {
+		writePairs(b, "VARIABLES", r.Variables)
+	}
+	if len(r.StaticTypes) > 0 {
+		writePairs(b, "STATIC_TYPES", r.StaticTypes)
+	}
+	if len(r.Sources) > 0 {
+		writePairs(b, "SOURCES", r.Sources)
+	}
+	if r.Steps != nil {
+		b.WriteString("EVALUATION_STEPS:\n")
+		for i, step := range r.Steps {
+			b.WriteString("  Step ")
+			b.WriteString(strconv.Itoa(i + 1))
+			b.WriteString(": ")
+			b.WriteString(quoteText(step.Text))
+			b.WriteString(" [node ")
+			b.WriteString(step.NodeID)
+			b.WriteString("]\n")
+		}
+	}
+	for _, sc := range r.ShortCircuits {
+		writeField(b, "SHORT_CIRCUIT", sc)
+	}
+
+	writeField(b, "LOCATION", r.File+":"+strconv.Itoa(r.Line))
+	writeField(b, "FINGERPRINT", r.Fingerprint)
+	if r.History != nil {
+		b.WriteString("HISTORY: ")
+		b.WriteString(strconv.Itoa(r.History.Failed))
+		b.WriteByte('/')
+		b.WriteString(strconv.Itoa(r.History.Runs))
+		b.WriteByte('\n')
+	}
+	if len(r.Owners) > 0 {
+		writeField(b, "OWNERS", strings.Join(r.Owners, " "))
+	}
+
+	if r.Failing != nil {
+		writeField(b, "FAILURE_REASON", r.Failing.Reason)
+		writeField(b, "FAILING_NODE", r.Failing.Node)
+		writeField(b, "FAILING_NODE_ID", r.Failing.NodeID)
+	}
+	for _, content := range r.Contents {
+		writeField(b, "CONTENTS", content)
+	}
+	for _, note := range r.Notes {
+		writeField(b, "NOTE", note)
+	}
+	for _, returned := range r.Errors {
+		writeField(b, "ERROR", returned)
+	}
+
+	if r.Match != nil {
+		writeField(b, "MATCHER", r.Match.Matcher)
+		if r.Match.Expected != "" {
+			writeField(b, "EXPECTED", r.Match.Expected)
+		}
+		if r.Match.Found != "" {
+			writeField(b, "FOUND", r.Match.Found)
+		}
+		for _, detail := range r.Match.Details {
+			writeField(b, "DETAIL", detail)
+		}
+	}
+
+	for _, diff := range r.Diffs {
+		for _, difference := range diff.Differences {
+			writeField(b, "DIFF", diff.Expr+": "+difference)
+		}
+		if len(diff.LineDiff) > 0 {
+			writeField(b, "LINE_DIFF_START", diff.Expr)
+			for _, line := range diff.LineDiff {
+				b.WriteString(quoteText(line) + "\n")
+			}
+			b.WriteString("LINE_DIFF_END\n")
+		}
+	}
+
+	if r.Message != "" {
+		writeField(b, "CUSTOM_MESSAGE", r.Message)
+	}
+
+	if len(r.Values) > 0 {
+		b.WriteString("CAPTURED_VALUES_START\n")
+		for _, value := range r.Values {
+			b.WriteString("VALUE: ")
+			b.WriteString(value.Name)
+			b.WriteString(" = ")
+			b.WriteString(quoteText(value.Value))
+			b.WriteString(" (")
+			b.WriteString(value.Type)
+			b.WriteString(")\n")
+		}
+		b.WriteString("CAPTURED_VALUES_END\n")
+	}
+
+	if len(r.Attachments) > 0 {
+		b.WriteString("ATTACHMENTS_START\n")
+		for _, a := range r.Attachments {
+			writeField(b, "ATTACHMENT", fmt.Sprintf("%s (%s, %d bytes) => %s", a.Name, a.MIME, a.Size, a.Path))
+		}
+		b.WriteString("ATTACHMENTS_END\n")
+	}
+
+	for _, section := range r.Sections {
+		marker := strings.ReplaceAll(section.Title, " ", "_")
+		b.WriteString(marker + "_START\n")
+		for _, line := range section.Lines {
+			b.WriteString(quoteText(line) + "\n")
+		}
+		b.WriteString(marker + "_END\n")
+	}
+
+	for _, hint := range r.Hints {
+		writeField(b, "HINT", "add "+hint)
+	}
+
+}
+
+// writeField writes a line of the text format giving a field its value.
+func writeField(b *strings.Builder, name, value string) {
+	b.WriteString(name)
+	b.WriteString(": ")
+	b.WriteString(quoteText(value))
+	b.WriteByte('\n')
+}
+
+// quoteText returns a value as the text format writes it: as it is, or quoted as Go quotes
+// strings when it holds control characters such as newlines, which would end its line, or
+// invalid UTF-8, or when it would otherwise read back as a quoted string. Parsers unquote
+// the values that start with a double quote and unquote without error.
+func quoteText(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c == 0x7f {
+			return strconv.Quote(s)
+		}
+	}
+	if !utf8.ValidString(s) {
+		return strconv.Quote(s)
+	}
+	if strings.HasPrefix(s, `"`) {
+		if _, err := strconv.Unquote(s); err == nil {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// writePairs writes a field of name=value pairs, joined with commas in sorted order.
+func writePairs(b *strings.Builder, field string, pairs map[string]string) {
+	names := make([]string, 0, len(pairs))
+	for name := range pairs {
+		names = append(names, name)
+	}
+	// Sorted as the pairs are, "acct.Balance=" before "acct="; an insertion sort keeps the
+	// few names of a failure off the heap
+	for i := 1; i < len(names); i++ {
+		for j := i; j > 0 && names[j]+"=" < names[j-1]+"="; j-- {
+			names[j], names[j-1] = names[j-1], names[j]
+		}
+	}
+
+	b.WriteString(field)
+	b.WriteString(": ")
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(quoteText(pairs[name]))
+	}
+	b.WriteByte('\n')
+}
+
+// encodeJSON writes a record as an indented JSON object, leaving operators such as && and
+// > unescaped.
+func encodeJSON(r *MachineRecord) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// encodeYAML writes a record as a YAML mapping, its fields in the order of the JSON
+// encoding. Strings are double-quoted, which YAML reads the way JSON does.
+func encodeYAML(r *MachineRecord) ([]byte, error) {
+	var b bytes.Buffer
+	writeYAMLFields(&b, reflect.ValueOf(r).Elem(), "")
+	return b.Bytes(), nil
+}
+
+// writeYAMLFields writes the fields of a struct that JSON would write, at the indentation.
+func writeYAMLFields(b *bytes.Buffer, v reflect.Value, indent string) {
+	for i := 0; i < v.NumField(); i++ {
+		name, omitEmpty := jsonField(v.Type().Field(i))
+		field := v.Field(i)
+		if name == "" || (omitEmpty && isEmptyValue(field)) {
+			continue
+		}
+		b.WriteString(indent + name + ":")
+		writeYAMLValue(b, field, indent)
+	}
+}
+
+// isEmptyValue reports whether the JSON encoding omits v from a field tagged omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// writeYAMLValue writes a value after its key, on the same line if it is a scalar.
+func writeYAMLValue(b *bytes.Buffer, v reflect.Value, indent string) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		writeYAMLValue(b, v.Elem(), indent)
+	case reflect.Struct:
+		b.WriteString("\n")
+		writeYAMLFields(b, v, indent+"  ")
+	case reflect.Map:
+		if v.Len() == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString(indent + "  " + strconv.Quote(key) + ":")
+			writeYAMLValue(b, v.MapIndex(reflect.ValueOf(key)), indent+"  ")
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i)
+			if item.Kind() == reflect.Struct {
+				// The first field goes on the dash's line, the others line up with it
+				var fields bytes.Buffer
+				writeYAMLFields(&fields, item, indent+"    ")
+				b.WriteString(indent + "  - " + strings.TrimPrefix(fields.String(), indent+"    "))
+				continue
+			}
+			b.WriteString(indent + "  -")
+			writeYAMLValue(b, item, indent+"  ")
+		}
+	case reflect.String:
+		b.WriteString(" " + strconv.Quote(v.String()) + "\n")
+	default:
+		fmt.Fprintf(b, " %v\n", v.Interface())
+	}
+}
+
+// jsonField returns the name the JSON encoding gives a struct field, "" for fields it
+// leaves out, and whether empty values are omitted.
+func jsonField(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" || field.PkgPath != "" {
+		return "", false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, options == "omitempty"
+}
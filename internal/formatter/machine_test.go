@@ -0,0 +1,116 @@
+package formatter
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// machineOutput formats a failure of x > 20 and returns its machine-readable section.
+func machineOutput(t *testing.T, format string) string {
+	t.Helper()
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	t.Setenv("DIAGASSERT_MACHINE_FORMAT", format)
+
+	formatter := NewVisualFormatter()
+	result := evaluator.EvaluateWithValues("x > 20", false, 0, map[string]interface{}{"x": 10})
+	ctx := &AssertionContext{Values: []Value{{Name: "x", Value: 10}}}
+	output := formatter.FormatVisualWithContext(result, "calc_test.go", 12, "", ctx)
+
+	start := strings.Index(output, "[MACHINE_READABLE_START]\n")
+	end := strings.Index(output, "[MACHINE_READABLE_END]\n")
+	if start < 0 || end < start {
+		t.Fatalf("no machine-readable section in:\n%s", output)
+	}
+	return output[start+len("[MACHINE_READABLE_START]\n") : end]
+}
+
+func TestMachineFormat_JSON(t *testing.T) {
+	var record MachineRecord
+	if err := json.Unmarshal([]byte(machineOutput(t, "json")), &record); err != nil {
+		t.Fatalf("JSON section does not parse: %v", err)
+	}
+
+	if record.Expr != "x > 20" || record.File != "calc_test.go" || record.Line != 12 || record.Result {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	if record.Failing == nil || record.Failing.Node != "x > 20" {
+		t.Errorf("failing operand = %+v, want x > 20", record.Failing)
+	}
+	if want := []MachineValue{{Name: "x", Value: "10", Type: "int"}}; !reflect.DeepEqual(record.Values, want) {
+		t.Errorf("values = %+v, want %+v", record.Values, want)
+	}
+	if len(record.Steps) != 3 || record.Steps[2].Text != "`x > 20` with 10 > 20 => false" {
+		t.Errorf("steps = %+v", record.Steps)
+	}
+}
+
+func TestMachineFormat_YAML(t *testing.T) {
+	section := machineOutput(t, "yaml")
+
+	for _, want := range []string{
+		"schema_version: 2\nexpr: \"x > 20\"\n",
+		"variables:\n  \"x\": \"10\"\n",
+		"steps:\n  - text: \"`x` => 10\"\n    node_id: ",
+		"file: \"calc_test.go\"\nline: 12\n",
+		"failing:\n  reason: \"x > 20 is false because x = 10\"\n  node: \"x > 20\"\n",
+		"values:\n  - name: \"x\"\n    value: \"10\"\n    type: \"int\"\n",
+	} {
+		if !strings.Contains(section, want) {
+			t.Errorf("YAML section misses %q:\n%s", want, section)
+		}
+	}
+}
+
+func TestMachineFormat_UnknownIsText(t *testing.T) {
+	if section := machineOutput(t, "unknown"); !strings.HasPrefix(section, "SCHEMA_VERSION: 2\nEXPR: x > 20\n") {
+		t.Errorf("unknown formats should fall back to text:\n%s", section)
+	}
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	RegisterEncoder("test-binary", EncoderFunc(func(record *MachineRecord) ([]byte, error) {
+		return []byte{0xff, 0x00, byte(record.Line)}, nil
+	}))
+	RegisterEncoder("test-failing", EncoderFunc(func(*MachineRecord) ([]byte, error) {
+		return nil, errors.New("unavailable")
+	}))
+	RegisterEncoder("test-plain", EncoderFunc(func(record *MachineRecord) ([]byte, error) {
+		return []byte("failure " + record.Fingerprint), nil
+	}))
+
+	// Binary output is kept on one line of base64
+	if section := machineOutput(t, "test-binary"); section != "BASE64: /wAM\n" {
+		t.Errorf("binary section = %q, want base64 of its bytes", section)
+	}
+
+	// Failing encoders leave the failure with the text format
+	if section := machineOutput(t, "test-failing"); !strings.HasPrefix(section, "SCHEMA_VERSION: 2\n") {
+		t.Errorf("a failing encoder should fall back to text:\n%s", section)
+	}
+
+	// Text gets a final newline so the end marker keeps a line of its own
+	if section := machineOutput(t, "test-plain"); !strings.HasPrefix(section, "failure ") || strings.Count(section, "\n") != 1 {
+		t.Errorf("plain section = %q", section)
+	}
+}
+
+func TestMachineFormat_InvalidUTF8(t *testing.T) {
+	record := &MachineRecord{SchemaVersion: SchemaVersion, Expr: "s == t", Values: []MachineValue{{Name: "s", Value: "abc\xff", Type: "string"}}}
+
+	// Built-in formats stay readable line by line, whatever bytes the values hold
+	t.Setenv("DIAGASSERT_MACHINE_FORMAT", "text")
+	if section := encodeMachineSection(record); strings.Contains(section, "BASE64") || !strings.Contains(section, "VALUE: s = \"abc\\xff\" (string)\n") {
+		t.Errorf("text section should escape the invalid byte:\n%s", section)
+	}
+	for _, format := range []string{"json", "yaml"} {
+		t.Setenv("DIAGASSERT_MACHINE_FORMAT", format)
+		if section := encodeMachineSection(record); strings.Contains(section, "BASE64") {
+			t.Errorf("%s section should not fall back to base64:\n%s", format, section)
+		}
+	}
+}
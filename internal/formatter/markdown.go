@@ -0,0 +1,168 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// markdownSpecial are the characters escaped in free text, so that expressions such as
+// a * b or <-ch do not turn into emphasis or HTML.
+var markdownSpecial = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+// formatMarkdown formats a failure as Markdown to paste into issues, pull request comments
+// or chat: the diagram in a fenced code block, captured values and attachments as tables,
+// and the machine-readable section in a code block of its own.
+func (f *VisualFormatter) formatMarkdown(result *evaluator.ExpressionResult, file string, line int, customMessage string, ctx *AssertionContext, facts *failureFacts) string {
+	var b strings.Builder
+
+	b.WriteString("**" + markdownText(Message(MsgAssertionFailed, file, line)) + "**\n")
+	if possiblyFlaky(facts.failed, facts.runs) {
+		b.WriteString("\n*" + markdownText(Message(MsgFlaky, facts.failed, facts.runs)) + "*\n")
+	}
+	if causes := facts.mismatch; len(causes) > 0 {
+		b.WriteString("\n**" + markdownText(Message(MsgMismatch, result.Tree.Result, result.Result)) + "**\n\n")
+		for _, cause := range mismatchCauses(causes, config.Getenv("DIAGASSERT_LANG")) {
+			b.WriteString("- " + markdownText(cause) + "\n")
+		}
+	}
+
+	b.WriteString("\n")
+	writeFence(&b, "text", textLines(f.formatPowerAssertStyle(result)))
+
+	if facts.failingNode != nil {
+		b.WriteString("\n" + markdownText(Message(MsgLikelyCause, describeFailure(facts.failingNode))) + "\n")
+	}
+
+	writeList := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		b.WriteString("\n#### " + markdownText(title) + "\n\n")
+		for _, item := range items {
+			b.WriteString("- " + markdownText(item) + "\n")
+		}
+	}
+	writeList(Message(MsgContents), facts.contents)
+	writeList(Message(MsgNotes), facts.notes)
+	writeList(Message(MsgReturnedErrors), facts.errors)
+
+	if result.Tree != nil && result.Tree.Explanation != nil {
+		explanation := result.Tree.Explanation
+		var items []string
+		if explanation.Expected != "" {
+			items = append(items, Message(MsgExpected, explanation.Expected))
+		}
+		if explanation.Found != "" {
+			items = append(items, Message(MsgFound, explanation.Found))
+		}
+		writeList(Message(MsgExplanation, explanation.Matcher), append(items, explanation.Details...))
+	}
+
+	for _, node := range facts.differences {
+		b.WriteString("\n#### " + markdownText(Message(MsgDifferences, node.Text)) + "\n\n")
+		writeFence(&b, "text", node.Differences)
+		if lines := f.formatTextDiff(node, f.diffStyle); len(lines) > 0 {
+			lang := "diff"
+			if f.diffStyle == diffStyleSplit {
+				lang = "text"
+			}
+			b.WriteString("\n#### " + markdownText(Message(MsgLineDiff)) + "\n\n")
+			writeFence(&b, lang, lines)
+		}
+	}
+
+	// Custom messages are the test author's own text, which may well be Markdown already
+	if customMessage != "" {
+		b.WriteString("\n#### " + markdownText(Message(MsgCustomMessage)) + "\n\n" + customMessage + "\n")
+	}
+
+	if ctx != nil && len(ctx.Owners) > 0 {
+		b.WriteString("\n" + markdownText(Message(MsgOwners, strings.Join(ctx.Owners, " "))) + "\n")
+	}
+
+	if ctx != nil && len(ctx.Values) > 0 {
+		b.WriteString("\n#### " + markdownText(Message(MsgCapturedValues)) + "\n\n")
+		b.WriteString("| Name | Value | Type |\n| --- | --- | --- |\n")
+		for _, value := range ctx.Values {
+			text := valueText(value.Value)
+			if _, compact, ok := renderValue(value.Value); ok {
+				text = compact
+			}
+			b.WriteString(fmt.Sprintf("| %s | %s | %s |\n",
+				markdownCode(value.Name), markdownCode(text), markdownCode(fmt.Sprintf("%T", value.Value))))
+		}
+	}
+
+	if ctx != nil && len(ctx.Attachments) > 0 {
+		b.WriteString("\n#### " + markdownText(Message(MsgAttachments)) + "\n\n")
+		b.WriteString("| Name | Content | Path |\n| --- | --- | --- |\n")
+		for _, a := range ctx.Attachments {
+			b.WriteString(fmt.Sprintf("| %s | %s | %s |\n",
+				markdownText(a.Name), markdownText(describeAttachment(a)), markdownCode(a.Path)))
+		}
+	}
+
+	if ctx != nil {
+		for _, section := range ctx.Sections {
+			b.WriteString("\n#### " + markdownText(section.Title) + "\n\n")
+			writeFence(&b, "text", section.Lines)
+		}
+	}
+
+	if hints := facts.hints; len(hints) > 0 {
+		b.WriteString("\n#### " + markdownText(Message(MsgHint)) + "\n\n")
+		writeFence(&b, "go", hints)
+	}
+
+	if f.includeMachineReadable {
+		b.WriteString("\n")
+		writeFence(&b, "text", textLines(f.formatMachineReadable(result, file, line, customMessage, ctx, facts)))
+	}
+
+	return b.String()
+}
+
+// writeFence writes lines as a fenced code block, its fence longer than any run of
+// backticks in them.
+func writeFence(b *strings.Builder, lang string, lines []string) {
+	fence := "```"
+	for _, line := range lines {
+		for strings.Contains(line, fence) {
+			fence += "`"
+		}
+	}
+	b.WriteString(fence + lang + "\n")
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(fence + "\n")
+}
+
+// textLines splits text ending in a newline into its lines.
+func textLines(text string) []string {
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// markdownText escapes free text for Markdown, keeping it on one line.
+func markdownText(s string) string {
+	return strings.ReplaceAll(markdownSpecial.Replace(s), "\n", "<br>")
+}
+
+// markdownCode formats s as inline code for a table cell, where pipes still need escaping
+// and line breaks become <br>. Text holding backticks is escaped as free text instead.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	if strings.Contains(s, "`") {
+		return markdownText(s)
+	}
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return "`" + strings.ReplaceAll(s, "\n", "`<br>`") + "`"
+}
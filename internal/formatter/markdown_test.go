@@ -0,0 +1,65 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+func TestMarkdownStyle(t *testing.T) {
+	t.Setenv("FORCE_COLOR", "1")
+	t.Setenv("DIAGASSERT_STYLE", "markdown")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+
+	formatter := NewVisualFormatter()
+	result := evaluator.EvaluateWithValues("x > 20", false, 0, map[string]interface{}{"x": 10})
+	ctx := &AssertionContext{
+		Values: []Value{{Name: "x", Value: 10}, {Name: "sep", Value: "a|b"}},
+		Owners: []string{"@team/core"},
+	}
+	output := formatter.FormatVisualWithContext(result, "calc_test.go", 12, "see *issue*", ctx)
+
+	for _, want := range []string{
+		"**ASSERTION FAILED at calc\\_test.go:12**\n",
+		"```text\n  assert(x > 20)\n",
+		"LIKELY CAUSE: x \\> 20 is false because x = 10\n",
+		"#### CUSTOM MESSAGE\n\nsee *issue*\n",
+		"OWNERS: @team/core\n",
+		"| Name | Value | Type |\n| --- | --- | --- |\n| `x` | `10` | `int` |\n| `sep` | `a\\|b` | `string` |\n",
+		"```text\n[MACHINE_READABLE_START]\n",
+		"[MACHINE_READABLE_END]\n```\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("markdown output misses %q:\n%s", want, output)
+		}
+	}
+
+	// Colors would show up as escape sequences where the Markdown is pasted
+	if strings.Contains(output, "\033[") {
+		t.Errorf("markdown output should have no escape sequences:\n%q", output)
+	}
+}
+
+func TestWriteFence(t *testing.T) {
+	var b strings.Builder
+	writeFence(&b, "text", []string{"a ``` b", "c"})
+	if want := "````text\na ``` b\nc\n````\n"; b.String() != want {
+		t.Errorf("fence = %q, want %q", b.String(), want)
+	}
+}
+
+func TestMarkdownCode(t *testing.T) {
+	tests := map[string]string{
+		"":        "",
+		"x":       "`x`",
+		"a|b":     "`a\\|b`",
+		"a\nb":    "`a`<br>`b`",
+		"say `x`": "say \\`x\\`",
+	}
+	for in, want := range tests {
+		if got := markdownCode(in); got != want {
+			t.Errorf("markdownCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// Catalog maps message keys to the text a language shows for them. Texts are format strings
+// taking the same arguments as the English ones.
+type Catalog map[string]string
+
+// Message keys of the human-readable output. The machine-readable section never changes
+// with the language.
+const (
+	MsgAssertionFailed = "assertion_failed" // Header, with the file and line
+	MsgFlaky           = "flaky"            // Under the header, with the failed and recorded runs
+	MsgMismatch        = "mismatch"         // Under the header, with the diagram's result and the assertion's
+	MsgMismatchUser    = "mismatch_user"    // Under the mismatch, with the operands passed with V or Values
+	MsgMismatchCalls   = "mismatch_calls"   // Under the mismatch, with the methods called again
+	MsgMismatchChanged = "mismatch_changed" // Under the mismatch
+	MsgLikelyCause     = "likely_cause"     // With the description of the failing operand
+	MsgContents        = "contents"
+	MsgNotes           = "notes"
+	MsgReturnedErrors  = "returned_errors"
+	MsgExplanation     = "explanation" // With the matcher's name
+	MsgExpected        = "expected"    // With what the matcher expected
+	MsgFound           = "found"       // With what the matcher found
+	MsgDifferences     = "differences" // With the compared expression
+	MsgLineDiff        = "line_diff"
+	MsgCustomMessage   = "custom_message"
+	MsgOwners          = "owners"      // With the owners of the failing file
+	MsgUserValues      = "user_values" // Under a diagram marking values passed with V
+	MsgCapturedValues  = "captured_values"
+	MsgAttachments     = "attachments"
+	MsgWrittenTo       = "written_to" // With the path an attachment was written to
+	MsgHint            = "hint"
+	MsgRepeated        = "repeated"         // With the file, line and number of failures not shown
+	MsgExpectedFailure = "expected_failure" // Above a failure ExpectFail expects, with the issue
+	MsgUnexpectedPass  = "unexpected_pass"  // With the issue of a test ExpectFail marked that did not fail
+	MsgSoftAssertions  = "soft_assertions"  // Above the failures SoftT collected, with their number
+)
+
+var (
+	catalogsMu sync.RWMutex
+	catalogs   = map[string]Catalog{
+		"en": {
+			MsgAssertionFailed: "ASSERTION FAILED at %s:%d",
+			MsgFlaky:           "failed in %d of the last %d runs, possibly flaky",
+			MsgMismatch:        "DIAGNOSTIC MISMATCH: the diagram gives %v, but the assertion was given %v; some values shown are not the ones the expression read",
+			MsgMismatchUser:    "the values passed with V or Values for %s may be stale, or not the ones the expression read",
+			MsgMismatchCalls:   "%s, called again after the assertion, may have side effects or depend on state that changed; DIAGASSERT_REEVALUATE=pure calls only methods free of them again",
+			MsgMismatchChanged: "the values may have changed between the assertion and this report, as when another goroutine writes them",
+			MsgLikelyCause:     "LIKELY CAUSE: %s",
+			MsgContents:        "CONTENTS",
+			MsgNotes:           "NOTES",
+			MsgReturnedErrors:  "RETURNED ERRORS",
+			MsgExplanation:     "EXPLANATION from %s",
+			MsgExpected:        "expected: %s",
+			MsgFound:           "found:    %s",
+			MsgDifferences:     "DIFFERENCES in %s",
+			MsgLineDiff:        "LINE DIFF",
+			MsgCustomMessage:   "CUSTOM MESSAGE",
+			MsgOwners:          "OWNERS: %s",
+			MsgUserValues:      "ᵛ passed with V or Values, not worked out from the expression",
+			MsgCapturedValues:  "CAPTURED VALUES",
+			MsgAttachments:     "ATTACHMENTS",
+			MsgWrittenTo:       "written to %s",
+			MsgHint:            "HINT: pass the values that could not be read to show them in the diagram",
+			MsgRepeated:        "ASSERTION FAILED at %s:%d repeated %d more times",
+			MsgExpectedFailure: "EXPECTED FAILURE (%s): a known issue, so the test does not fail",
+			MsgUnexpectedPass:  "UNEXPECTED PASS: no assertion failed, although the test was expected to fail because of %s; remove ExpectFail if the issue is fixed",
+			MsgSoftAssertions:  "SOFT ASSERTIONS: %d failed",
+		},
+		"ja": {
+			MsgAssertionFailed: "アサーション失敗: %s:%d",
+			MsgFlaky:           "直近 %[2]d 回の実行のうち %[1]d 回失敗しています (不安定な可能性があります)",
+			MsgMismatch:        "DIAGNOSTIC MISMATCH: 図の結果は %v ですが、アサーションには %v が渡されました。表示された値の一部は式が読んだ値ではありません",
+			MsgMismatchUser:    "V または Values で %s に渡された値が古いか、式が読んだ値ではない可能性があります",
+			MsgMismatchCalls:   "アサーションの後に再度呼び出された %s に副作用があるか、変化した状態に依存している可能性があります。DIAGASSERT_REEVALUATE=pure にすると副作用のないメソッドだけを再度呼び出します",
+			MsgMismatchChanged: "別のゴルーチンが書き込んだ場合のように、アサーションからこの報告までの間に値が変わった可能性があります",
+			MsgLikelyCause:     "考えられる原因: %s",
+			MsgContents:        "内容",
+			MsgNotes:           "注記",
+			MsgReturnedErrors:  "返されたエラー",
+			MsgExplanation:     "%s による説明",
+			MsgExpected:        "期待値: %s",
+			MsgFound:           "実際値: %s",
+			MsgDifferences:     "%s の差分",
+			MsgLineDiff:        "行ごとの差分",
+			MsgCustomMessage:   "メッセージ",
+			MsgOwners:          "担当: %s",
+			MsgUserValues:      "ᵛ は V または Values で渡された値 (式からは求めていません)",
+			MsgCapturedValues:  "キャプチャした値",
+			MsgAttachments:     "添付ファイル",
+			MsgWrittenTo:       "保存先: %s",
+			MsgHint:            "ヒント: 読み取れなかった値を渡すと図に表示されます",
+			MsgRepeated:        "%s:%d のアサーション失敗がさらに %d 回繰り返されました",
+			MsgExpectedFailure: "想定された失敗 (%s): 既知の問題のため、テストは失敗になりません",
+			MsgUnexpectedPass:  "想定外の成功: %s のため失敗するはずのテストで、失敗したアサーションがありません。問題が修正されたなら ExpectFail を削除してください",
+			MsgSoftAssertions:  "ソフトアサーション: %d 件失敗",
+		},
+		"ko": {
+			MsgAssertionFailed: "단언 실패: %s:%d",
+			MsgFlaky:           "최근 %[2]d번의 실행 중 %[1]d번 실패했습니다 (불안정할 수 있습니다)",
+			MsgMismatch:        "DIAGNOSTIC MISMATCH: 다이어그램의 결과는 %v 이지만 어설션에는 %v 가 전달되었습니다. 표시된 값 중 일부는 식이 읽은 값이 아닙니다",
+			MsgMismatchUser:    "V 또는 Values 로 %s에 전달된 값이 오래되었거나 식이 읽은 값이 아닐 수 있습니다",
+			MsgMismatchCalls:   "어설션 후 다시 호출된 %s에 부작용이 있거나 변경된 상태에 의존할 수 있습니다. DIAGASSERT_REEVALUATE=pure 는 부작용이 없는 메서드만 다시 호출합니다",
+			MsgMismatchChanged: "다른 고루틴이 값을 쓰는 경우처럼 어설션과 이 보고 사이에 값이 바뀌었을 수 있습니다",
+			MsgLikelyCause:     "가능한 원인: %s",
+			MsgContents:        "내용",
+			MsgNotes:           "참고",
+			MsgReturnedErrors:  "반환된 오류",
+			MsgExplanation:     "%s의 설명",
+			MsgExpected:        "예상: %s",
+			MsgFound:           "실제: %s",
+			MsgDifferences:     "%s의 차이",
+			MsgLineDiff:        "줄 단위 차이",
+			MsgCustomMessage:   "메시지",
+			MsgOwners:          "담당: %s",
+			MsgUserValues:      "ᵛ 는 V 또는 Values 로 전달된 값 (식에서 구하지 않음)",
+			MsgCapturedValues:  "캡처한 값",
+			MsgAttachments:     "첨부 파일",
+			MsgWrittenTo:       "저장 위치: %s",
+			MsgHint:            "힌트: 읽을 수 없었던 값을 전달하면 다이어그램에 표시됩니다",
+			MsgRepeated:        "%s:%d 어설션 실패가 %d번 더 반복되었습니다",
+			MsgExpectedFailure: "예상된 실패 (%s): 알려진 문제이므로 테스트는 실패하지 않습니다",
+			MsgUnexpectedPass:  "예상치 못한 성공: %s 때문에 실패할 것으로 예상된 테스트에서 실패한 어설션이 없습니다. 문제가 수정되었다면 ExpectFail 을 제거하세요",
+			MsgSoftAssertions:  "소프트 어설션: %d개 실패",
+		},
+		"zh": {
+			MsgAssertionFailed: "断言失败: %s:%d",
+			MsgFlaky:           "最近 %[2]d 次运行中失败了 %[1]d 次, 可能不稳定",
+			MsgMismatch:        "DIAGNOSTIC MISMATCH: 图示的结果为 %v, 但断言收到的是 %v; 所示的部分值并非表达式读取的值",
+			MsgMismatchUser:    "通过 V 或 Values 为 %s 传入的值可能已过时, 或并非表达式读取的值",
+			MsgMismatchCalls:   "断言之后再次调用的 %s 可能有副作用, 或依赖已改变的状态; DIAGASSERT_REEVALUATE=pure 只会再次调用没有副作用的方法",
+			MsgMismatchChanged: "在断言与本报告之间值可能已改变, 例如被其他 goroutine 写入",
+			MsgLikelyCause:     "可能原因: %s",
+			MsgContents:        "内容",
+			MsgNotes:           "备注",
+			MsgReturnedErrors:  "返回的错误",
+			MsgExplanation:     "%s 的说明",
+			MsgExpected:        "期望: %s",
+			MsgFound:           "实际: %s",
+			MsgDifferences:     "%s 的差异",
+			MsgLineDiff:        "逐行差异",
+			MsgCustomMessage:   "消息",
+			MsgOwners:          "负责人: %s",
+			MsgUserValues:      "ᵛ 为通过 V 或 Values 传入的值 (并非由表达式求得)",
+			MsgCapturedValues:  "捕获的值",
+			MsgAttachments:     "附件",
+			MsgWrittenTo:       "已写入 %s",
+			MsgHint:            "提示: 传入无法读取的值即可在图中显示",
+			MsgRepeated:        "%s:%d 的断言失败又重复了 %d 次",
+			MsgExpectedFailure: "预期的失败 (%s): 这是已知问题, 测试不会因此失败",
+			MsgUnexpectedPass:  "意外通过: 该测试因 %s 预期会失败, 但没有断言失败; 如果问题已修复, 请移除 ExpectFail",
+			MsgSoftAssertions:  "软断言: %d 个失败",
+		},
+	}
+)
+
+// RegisterCatalog adds a language, or replaces the texts of one. Keys missing from the
+// catalog keep the texts of the base language, e.g. "pt" for "pt_BR", or English.
+func RegisterCatalog(lang string, catalog Catalog) {
+	lang = normalizeLang(lang)
+	copied := make(Catalog, len(catalog))
+	for key, text := range catalog {
+		copied[key] = text
+	}
+
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	if existing, ok := catalogs[lang]; ok {
+		for key, text := range existing {
+			if _, replaced := copied[key]; !replaced {
+				copied[key] = text
+			}
+		}
+	}
+	catalogs[lang] = copied
+}
+
+// Message returns the text for key in the language chosen by DIAGASSERT_LANG, formatted with
+// args. Languages are matched as in "ja", "ja-JP" or "ja_JP.UTF-8", falling back to the base
+// language and then to English.
+func Message(key string, args ...interface{}) string {
+	return messageIn(config.Getenv("DIAGASSERT_LANG"), key, args...)
+}
+
+// messageIn is Message in lang rather than the language DIAGASSERT_LANG chooses.
+func messageIn(lang, key string, args ...interface{}) string {
+	lang = normalizeLang(lang)
+	base, _, _ := strings.Cut(lang, "_")
+
+	catalogsMu.RLock()
+	text, ok := catalogs[lang][key]
+	if !ok {
+		text, ok = catalogs[base][key]
+	}
+	if !ok {
+		text = catalogs["en"][key]
+	}
+	catalogsMu.RUnlock()
+
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// normalizeLang turns a language tag or locale, such as "zh-CN" or "zh_CN.UTF-8", into the
+// form catalogs are registered under, "zh_cn".
+func normalizeLang(lang string) string {
+	if i := strings.IndexAny(lang, ".@"); i >= 0 {
+		lang = lang[:i]
+	}
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "-", "_"))
+}
+
+// mismatchCauses words the possible causes of a diagram disagreeing with the assertion in
+// lang.
+func mismatchCauses(causes []evaluator.MismatchCause, lang string) []string {
+	texts := make([]string, len(causes))
+	for i, cause := range causes {
+		operands := strings.Join(cause.Operands, ", ")
+		switch cause.Kind {
+		case evaluator.MismatchUserValues:
+			texts[i] = messageIn(lang, MsgMismatchUser, operands)
+		case evaluator.MismatchReevaluated:
+			texts[i] = messageIn(lang, MsgMismatchCalls, operands)
+		default:
+			texts[i] = messageIn(lang, MsgMismatchChanged)
+		}
+	}
+	return texts
+}
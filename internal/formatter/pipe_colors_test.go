@@ -5,7 +5,8 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/fatih/color"
+	"github.com/paveg/diagassert/internal/ansi"
+	"github.com/paveg/diagassert/internal/config"
 	"github.com/paveg/diagassert/internal/evaluator"
 )
 
@@ -44,22 +45,13 @@ func TestPipeColorsConfiguration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Save original env vars
-			originalEnvVars := make(map[string]string)
-			for key := range tt.envVars {
-				originalEnvVars[key] = os.Getenv(key)
-			}
-
-			// Set test env vars
-			for key, value := range tt.envVars {
-				os.Setenv(key, value)
-			}
+			cfg, _ := config.New(tt.envVars)
 
 			// Reset color package state
-			color.NoColor = false
+			ansi.NoColor = false
 
 			// Create formatter and test
-			formatter := NewVisualFormatter()
+			formatter := NewVisualFormatterWithConfig(cfg)
 
 			if formatter.colorConfig.PipeColorsEnabled != tt.expectPipeColorsEnabled {
 				t.Errorf("expected pipe colors enabled: %v, got: %v", tt.expectPipeColorsEnabled, formatter.colorConfig.PipeColorsEnabled)
@@ -68,15 +60,6 @@ func TestPipeColorsConfiguration(t *testing.T) {
 			if tt.expectPipeColorPalette && len(formatter.colorConfig.PipeColorPalette) == 0 {
 				t.Error("expected pipe color palette to be created")
 			}
-
-			// Restore original env vars
-			for key, value := range originalEnvVars {
-				if value == "" {
-					os.Unsetenv(key)
-				} else {
-					os.Setenv(key, value)
-				}
-			}
 		})
 	}
 }
@@ -301,7 +284,7 @@ func TestPerValuePipeColorIntegration(t *testing.T) {
 	os.Unsetenv("DIAGASSERT_PIPE_COLORS")
 
 	// Reset color package state
-	color.NoColor = false
+	ansi.NoColor = false
 
 	formatter := NewVisualFormatter()
 
@@ -311,19 +294,19 @@ func TestPerValuePipeColorIntegration(t *testing.T) {
 		Result:     false,
 		Variables:  map[string]interface{}{"x": 5},
 		Tree: &evaluator.EvaluationTree{
-			ID:       1,
+			ID:       "1",
 			Type:     "comparison",
 			Operator: ">",
 			Text:     "x > 10",
 			Result:   false,
 			Left: &evaluator.EvaluationTree{
-				ID:    2,
+				ID:    "2",
 				Type:  "identifier",
 				Text:  "x",
 				Value: 5,
 			},
 			Right: &evaluator.EvaluationTree{
-				ID:    3,
+				ID:    "3",
 				Type:  "literal",
 				Text:  "10",
 				Value: 10,
@@ -0,0 +1,37 @@
+package formatter
+
+import "sync"
+
+// ValueRenderer renders values that the default formatting shows poorly, such as protobuf
+// messages whose structs carry internal state. full is the complete, possibly multi-line,
+// rendering and compact a single line; ok is false for values the renderer does not handle.
+type ValueRenderer func(v interface{}) (full string, compact string, ok bool)
+
+var (
+	valueRenderersMu sync.RWMutex
+	valueRenderers   []ValueRenderer
+)
+
+// RegisterValueRenderer adds a renderer that is consulted, in registration order, before
+// the default formatting of captured and evaluated values.
+func RegisterValueRenderer(r ValueRenderer) {
+	valueRenderersMu.Lock()
+	defer valueRenderersMu.Unlock()
+	valueRenderers = append(valueRenderers, r)
+}
+
+// renderValue returns the rendering of the first registered renderer that handles v.
+func renderValue(v interface{}) (string, string, bool) {
+	if v == nil {
+		return "", "", false
+	}
+
+	valueRenderersMu.RLock()
+	defer valueRenderersMu.RUnlock()
+	for _, render := range valueRenderers {
+		if full, compact, ok := render(v); ok {
+			return full, compact, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,32 @@
+package formatter
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+var colorSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// StripColors removes the ANSI color sequences from rendered output.
+func StripColors(output string) string {
+	return colorSequence.ReplaceAllString(output, "")
+}
+
+// Route writes a rendered diagnostic to each writer for reading outside the test log:
+// without colors, and followed by a blank line that separates it from the next one. Every
+// writer is tried; the first error is returned.
+func Route(output string, writers ...io.Writer) error {
+	if len(writers) == 0 {
+		return nil
+	}
+	text := strings.TrimRight(StripColors(output), "\n") + "\n\n"
+
+	var first error
+	for _, w := range writers {
+		if _, err := io.WriteString(w, text); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,29 @@
+package formatter
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestRoute(t *testing.T) {
+	var first, second bytes.Buffer
+	output := "\x1b[31;1mASSERTION FAILED\x1b[0m at test.go:1\n\n  assert(\x1b[34mx\x1b[0m > 1)\n"
+
+	err := Route(output, &first, failingWriter{}, &second)
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("Route() error = %v, want the failing writer's error", err)
+	}
+	want := "ASSERTION FAILED at test.go:1\n\n  assert(x > 1)\n\n"
+	if first.String() != want || second.String() != want {
+		t.Errorf("Route() wrote %q and %q, want %q to both", first.String(), second.String(), want)
+	}
+
+	if err := Route(output); err != nil {
+		t.Errorf("Route() without writers = %v, want nil", err)
+	}
+}
@@ -0,0 +1,42 @@
+package formatter
+
+import (
+	"os"
+
+	"github.com/mattn/go-isatty"
+)
+
+// stdoutIsColorTerminal reports whether standard output, where go test prints failures, is a
+// terminal that shows ANSI colors. It is a variable so tests can pretend either way.
+var stdoutIsColorTerminal = func() bool {
+	return isColorTerminal(os.Stdout)
+}
+
+// stdoutWidth returns the number of columns of the terminal standard output is, or 0 when it
+// is not one. It is a variable so tests can pretend either way.
+var stdoutWidth = func() int {
+	return terminalWidth(os.Stdout)
+}
+
+// isColorTerminal reports whether f is a terminal that shows ANSI colors. Windows consoles
+// are switched to processing escape sequences first, and are not when that fails, as on
+// consoles older than Windows 10.
+func isColorTerminal(f *os.File) bool {
+	fd := f.Fd()
+	if isatty.IsCygwinTerminal(fd) {
+		// Cygwin and MSYS2 terminals such as mintty are pipes that interpret colors themselves
+		return true
+	}
+	return isatty.IsTerminal(fd) && enableVirtualTerminal(f)
+}
+
+// ciRendersColors reports whether the tests run on a CI service whose logs show ANSI colors,
+// although its output is not a terminal.
+func ciRendersColors() bool {
+	for _, key := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE"} {
+		if os.Getenv(key) == "true" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package formatter
+
+import "os"
+
+// enableVirtualTerminal reports whether the terminal f writes to processes ANSI escape
+// sequences, which terminals outside Windows always do.
+func enableVirtualTerminal(f *os.File) bool {
+	return true
+}
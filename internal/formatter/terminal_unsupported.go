@@ -0,0 +1,10 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !zos && !windows
+
+package formatter
+
+import "os"
+
+// terminalWidth returns 0, as the size of a terminal cannot be queried on this system.
+func terminalWidth(f *os.File) int {
+	return 0
+}
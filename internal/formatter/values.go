@@ -0,0 +1,215 @@
+package formatter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// diagStringer is implemented by values that describe themselves for diagnostics, in
+// preference to their Error or String methods.
+type diagStringer interface {
+	DiagString() string
+}
+
+// maxRawDepth bounds the rendering by fields of nested values, which may be cyclic.
+const maxRawDepth = 8
+
+// rawValues reports whether DIAGASSERT_RAW_VALUES asks for values to be shown by their
+// fields, ignoring the methods they describe themselves with.
+func rawValues() bool {
+	return config.Getenv("DIAGASSERT_RAW_VALUES") == "true"
+}
+
+// preferredText returns the text a value describes itself with: its DiagString, Error or
+// String method, in that order. ok is false for values without one, for methods that panic,
+// as they may for nil receivers, and for every value in raw mode, except redacted ones.
+func preferredText(v interface{}) (text string, ok bool) {
+	if v == nil {
+		return "", false
+	}
+	if redacted, isRedacted := v.(evaluator.Redacted); isRedacted {
+		return redacted.String(), true
+	}
+	if rawValues() {
+		return "", false
+	}
+
+	defer func() {
+		if recover() != nil {
+			text, ok = "", false
+		}
+	}()
+	switch val := v.(type) {
+	case diagStringer:
+		return val.DiagString(), true
+	case error:
+		return val.Error(), true
+	case fmt.Stringer:
+		return val.String(), true
+	}
+	return "", false
+}
+
+// pointerDepth reads DIAGASSERT_POINTER_DEPTH, the number of pointers followed when a value
+// is shown: 1 by default, which shows a pointer to a struct by its fields and the pointers
+// among them by their addresses, and 0 for addresses only. A chain of pointers to pointers,
+// such as a **T, is followed as one.
+func pointerDepth() int {
+	depth, ok := config.Int("DIAGASSERT_POINTER_DEPTH")
+	if !ok || depth < 0 {
+		return 1
+	}
+	return depth
+}
+
+// ValueText formats a value shown in full, as the CAPTURED VALUES section does, for helpers
+// that list values in sections of their own.
+func ValueText(v interface{}) string {
+	return valueText(v)
+}
+
+// valueText formats a value shown in full: by the text it describes itself with, see
+// preferredText, or else by its fields. Pointers are followed as far as pointerDepth allows
+// and shown together with their address, as in &{Name:Alice Age:16} @0xc000012345.
+func valueText(v interface{}) string {
+	if text, ok := preferredText(v); ok {
+		return text
+	}
+	if v == nil {
+		return fmt.Sprintf("%v", v)
+	}
+	if val := reflect.ValueOf(v); val.Kind() == reflect.Ptr && !val.IsNil() {
+		if pointerDepth() == 0 {
+			return fmt.Sprintf("%#x", val.Pointer())
+		}
+		return fmt.Sprintf("%s @%#x", fieldsText(val, pointerDepth(), 0, true), val.Pointer())
+	}
+	if rawValues() {
+		return fieldsText(reflect.ValueOf(v), pointerDepth(), 0, true)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// machineValueText formats a value for the machine-readable section as valueText does, but
+// without addresses, which differ from run to run and would make records of the same
+// failure differ: pointers are followed at least once, and those left are shown by type.
+func machineValueText(v interface{}) string {
+	if _, ok := preferredText(v); ok || v == nil {
+		return valueText(v)
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr && !val.IsNil() {
+		depth := pointerDepth()
+		if depth == 0 {
+			depth = 1
+		}
+		return fieldsText(val, depth, 0, false)
+	}
+	if rawValues() {
+		return fieldsText(val, pointerDepth(), 0, false)
+	}
+	// The most common values are written as %v would, without its buffer
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// fieldsText formats v like %+v does, following up to pointers pointers, and shows the
+// values inside it by the text they describe themselves with, see preferredText. Pointers
+// it does not follow, channels and functions are shown by address, or by type without
+// addresses, as &T and chan T.
+func fieldsText(v reflect.Value, pointers, depth int, addresses bool) string {
+	if !v.IsValid() {
+		return "<nil>"
+	}
+	if depth > maxRawDepth {
+		return "..."
+	}
+	if depth > 0 && v.CanInterface() {
+		if text, ok := preferredText(v.Interface()); ok {
+			return text
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "<nil>"
+		}
+		if pointers == 0 {
+			if !addresses {
+				return "&" + v.Type().Elem().String()
+			}
+			return fmt.Sprintf("%#x", v.Pointer())
+		}
+		if v.Elem().Kind() == reflect.Ptr {
+			// Pointers to pointers count once, at the pointer they lead to
+			return "&" + fieldsText(v.Elem(), pointers, depth+1, addresses)
+		}
+		return "&" + fieldsText(v.Elem(), pointers-1, depth+1, addresses)
+	case reflect.Interface:
+		if v.IsNil() {
+			return "<nil>"
+		}
+		return fieldsText(v.Elem(), pointers, depth, addresses)
+	case reflect.Struct:
+		fields := make([]string, v.NumField())
+		for i := range fields {
+			fields[i] = v.Type().Field(i).Name + ":" + fieldsText(v.Field(i), pointers, depth+1, addresses)
+		}
+		return "{" + strings.Join(fields, " ") + "}"
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return "[]"
+		}
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = fieldsText(v.Index(i), pointers, depth+1, addresses)
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case reflect.Map:
+		if v.IsNil() {
+			return "map[]"
+		}
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entries = append(entries, fieldsText(iter.Key(), pointers, depth+1, addresses)+":"+fieldsText(iter.Value(), pointers, depth+1, addresses))
+		}
+		sort.Strings(entries)
+		return "map[" + strings.Join(entries, " ") + "]"
+	case reflect.Bool:
+		return fmt.Sprint(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprint(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fmt.Sprint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprint(v.Complex())
+	case reflect.String:
+		return v.String()
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if v.IsNil() {
+			return "<nil>"
+		}
+		if !addresses {
+			return v.Type().String()
+		}
+		return fmt.Sprintf("%#x", v.Pointer())
+	}
+	return "?"
+}
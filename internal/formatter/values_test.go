@@ -0,0 +1,119 @@
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+type point struct{ X, Y int }
+
+func (p point) String() string { return "point" }
+
+type status struct{ Code int }
+
+func (s status) Error() string      { return "status error" }
+func (s status) String() string     { return "status" }
+func (s status) DiagString() string { return "status 500" }
+
+type codeError struct{ Code int }
+
+func (e codeError) Error() string { return "code error" }
+
+type failing struct{ Code int }
+
+func (f *failing) Error() string { return "failing " + string(rune('0'+f.Code)) }
+
+func TestValueText(t *testing.T) {
+	var nilFailing *failing
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+		raw   string
+	}{
+		{"DiagString first", status{Code: 500}, "status 500", "{Code:500}"},
+		{"error before fields", codeError{404}, "code error", "{Code:404}"},
+		{"Stringer before fields", point{1, 2}, "point", "{X:1 Y:2}"},
+		{"fields without methods", struct{ Name string }{"Alice"}, "{Alice}", "{Name:Alice}"},
+		{"panicking method", nilFailing, "<nil>", "<nil>"},
+		{"redacted", evaluator.Redacted("***"), "***", "***"},
+		{"nested", []point{{1, 2}}, "[point]", "[{X:1 Y:2}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := valueText(tt.value); got != tt.want {
+				t.Errorf("valueText() = %q, want %q", got, tt.want)
+			}
+			t.Setenv("DIAGASSERT_RAW_VALUES", "true")
+			if got := valueText(tt.value); got != tt.raw {
+				t.Errorf("valueText() in raw mode = %q, want %q", got, tt.raw)
+			}
+		})
+	}
+}
+
+type node struct {
+	Name string
+	Next *node
+}
+
+func TestValueText_Pointers(t *testing.T) {
+	n := &node{Name: "a", Next: &node{Name: "b"}}
+	address := fmt.Sprintf("%#x", reflect.ValueOf(n).Pointer())
+	next := fmt.Sprintf("%#x", reflect.ValueOf(n.Next).Pointer())
+
+	if got, want := valueText(n), "&{Name:a Next:"+next+"} @"+address; got != want {
+		t.Errorf("valueText() = %q, want %q", got, want)
+	}
+	if got, want := valueText(&n), fmt.Sprintf("&&{Name:a Next:%s} @%#x", next, reflect.ValueOf(&n).Pointer()); got != want {
+		t.Errorf("valueText() of a pointer to a pointer = %q, want %q", got, want)
+	}
+	if got, want := valueText([]*node{n}), fmt.Sprintf("%v", []*node{n}); got != want {
+		t.Errorf("values other than pointers should be shown as before, got %q, want %q", got, want)
+	}
+
+	t.Setenv("DIAGASSERT_POINTER_DEPTH", "2")
+	if got, want := valueText(n), "&{Name:a Next:&{Name:b Next:<nil>}} @"+address; got != want {
+		t.Errorf("valueText() with depth 2 = %q, want %q", got, want)
+	}
+
+	t.Setenv("DIAGASSERT_POINTER_DEPTH", "0")
+	if got := valueText(n); got != address {
+		t.Errorf("valueText() with depth 0 = %q, want %q", got, address)
+	}
+}
+
+func TestMachineValueText_NoAddresses(t *testing.T) {
+	n := &node{Name: "a", Next: &node{Name: "b"}}
+
+	if got, want := machineValueText(n), "&{Name:a Next:&formatter.node}"; got != want {
+		t.Errorf("machineValueText() = %q, want %q", got, want)
+	}
+	t.Setenv("DIAGASSERT_POINTER_DEPTH", "2")
+	if got, want := machineValueText(n), "&{Name:a Next:&{Name:b Next:<nil>}}"; got != want {
+		t.Errorf("machineValueText() with depth 2 = %q, want %q", got, want)
+	}
+	t.Setenv("DIAGASSERT_POINTER_DEPTH", "0")
+	if got := machineValueText(n); strings.Contains(got, "0x") {
+		t.Errorf("machineValueText() with depth 0 should not show an address, got %q", got)
+	}
+}
+
+func TestFormatValueCompact_PreferredText(t *testing.T) {
+	if got := formatValueCompact(errors.New("not found")); got != "not found" {
+		t.Errorf("errors should be shown by their message, got %q", got)
+	}
+	if got := formatValueCompact(struct{ P point }{point{1, 2}}); got != "{P:point}" {
+		t.Errorf("fields should be shown by their String method, got %q", got)
+	}
+
+	t.Setenv("DIAGASSERT_RAW_VALUES", "true")
+	if got := formatValueCompact(point{1, 2}); got != "{X:1,Y:2}" {
+		t.Errorf("raw values should be shown by their fields, got %q", got)
+	}
+}
@@ -1,13 +1,19 @@
 // Package formatter provides visual formatting for power-assert style output.
 //
 // Color Support:
-// The visual formatter now includes comprehensive color support using github.com/fatih/color.
+// The visual formatter writes colors with the internal ansi package.
 // Colors are automatically detected based on terminal capabilities and environment variables:
 //
 // Environment Variables:
 //   - NO_COLOR: Set to any value to disable colors (respects https://no-color.org/)
 //   - FORCE_COLOR: Set to any value to force enable colors
+//   - DIAGASSERT_COLOR: "auto" (default) detects terminals and CI services, "always" or
+//     "never" override every other setting
 //   - DIAGASSERT_PIPE_COLORS: Set to "false" to disable per-value pipe colors (default: enabled)
+//   - DIAGASSERT_DIFF_STYLE: "unified" (default) or "split" layout for multi-line and JSON diffs
+//   - DIAGASSERT_MAX_WIDTH: Wrap long expressions at operators to fit this width, or not at "0" (default: COLUMNS, if set, otherwise the terminal width)
+//   - DIAGASSERT_EXPAND: "auto" (default) collapses passing sub-expressions of very long expressions
+//     to "✓", "collapsed" always does, "full" never does
 //
 // Color Scheme:
 //   - Header ("ASSERTION FAILED"): Bold Red
@@ -16,6 +22,8 @@
 //   - Boolean true: Green
 //   - Boolean false: Red
 //   - Operators (>, ==, &&, etc.): Yellow
+//   - Short-circuited branches ("(not evaluated)"): Dim
+//   - Likely cause line: Bold Magenta
 //
 // Per-Value Pipe Colors:
 // When DIAGASSERT_PIPE_COLORS is enabled (default), each value in deep expression hierarchies
@@ -26,132 +34,182 @@ package formatter
 import (
 	"fmt"
 	"go/ast"
-	"go/parser"
 	"go/token"
 	"os"
 	"reflect"
-	"sort"
+	"strconv"
 	"strings"
-	"unicode"
 	"unicode/utf8"
 
-	"github.com/fatih/color"
+	"github.com/paveg/diagassert/internal/ansi"
+	"github.com/paveg/diagassert/internal/astutil"
+	"github.com/paveg/diagassert/internal/config"
 	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/textwidth"
 )
 
 // ColorConfig holds color configuration for different output elements
 type ColorConfig struct {
 	// Element colors
-	HeaderColor   *color.Color // "ASSERTION FAILED" header
-	PipeColor     *color.Color // Visual pipes (|) - default color
-	VariableColor *color.Color // Variable values (blue)
-	TrueColor     *color.Color // Boolean true values (green)
-	FalseColor    *color.Color // Boolean false values (red)
-	OperatorColor *color.Color // Operators like >, <, == (yellow)
+	HeaderColor   *ansi.Color // "ASSERTION FAILED" header
+	PipeColor     *ansi.Color // Visual pipes (|) - default color
+	VariableColor *ansi.Color // Variable values (blue)
+	TrueColor     *ansi.Color // Boolean true values (green)
+	FalseColor    *ansi.Color // Boolean false values (red)
+	OperatorColor *ansi.Color // Operators like >, <, == (yellow)
+	SkippedColor  *ansi.Color // Branches skipped by short-circuit evaluation (dim)
+	CauseColor    *ansi.Color // "LIKELY CAUSE" line (bold magenta)
 
 	// Per-value pipe colors
-	PipeColorPalette  []*color.Color // Color palette for per-value pipes
-	PipeColorsEnabled bool           // Enable per-value pipe colors
+	PipeColorPalette  []*ansi.Color // Color palette for per-value pipes
+	PipeColorsEnabled bool          // Enable per-value pipe colors
 
 	// Color detection
 	ColorsEnabled bool
+	Forced        bool // FORCE_COLOR overrides NO_COLOR, so escape sequences are written directly
 }
 
 // VisualFormatter formats evaluation results in power-assert style.
 type VisualFormatter struct {
 	includeMachineReadable bool
-	colorConfig            *ColorConfig
+	colorConfig            ColorConfig
+	diffStyle              string
+	maxWidth               int
+	expandMode             string
+	includeHints           bool
+	layout                 string
+	style                  string
 }
 
-// NewVisualFormatter creates a new visual formatter.
+// NewVisualFormatter creates a new visual formatter with the Config of the environment and
+// settings file.
 func NewVisualFormatter() *VisualFormatter {
-	// Respect environment variable for machine-readable output
-	includeMachine := os.Getenv("DIAGASSERT_MACHINE_READABLE") != "false"
+	return NewVisualFormatterWithConfig(loadConfig())
+}
+
+// NewVisualFormatterWithConfig creates a new visual formatter with the settings of cfg.
+func NewVisualFormatterWithConfig(cfg config.Config) *VisualFormatter {
+	// Markdown is pasted elsewhere, where escape sequences would only get in the way
+	colorConfig := setupColorConfig(cfg)
+	style := getStyle()
+	if style == styleMarkdown {
+		colorConfig.ColorsEnabled = false
+		colorConfig.PipeColorsEnabled = false
+	}
 
 	return &VisualFormatter{
-		includeMachineReadable: includeMachine,
-		colorConfig:            setupColorConfig(),
+		includeMachineReadable: cfg.MachineReadable,
+		colorConfig:            colorConfig,
+		diffStyle:              getDiffStyle(),
+		maxWidth:               getMaxWidth(),
+		expandMode:             getExpandMode(),
+		includeHints:           config.Getenv("DIAGASSERT_HINTS") != "false",
+		layout:                 getLayout(),
+		style:                  style,
 	}
 }
 
+// loadConfig returns the Config of the environment and settings file. Invalid settings keep
+// their defaults; the failures of the root package report them.
+func loadConfig() config.Config {
+	cfg, _ := config.Load()
+	return cfg
+}
+
 // setupColorConfig creates and configures the color system
-func setupColorConfig() *ColorConfig {
+func setupColorConfig(cfg config.Config) ColorConfig {
 	// Detect if colors should be enabled
-	colorsEnabled := shouldEnableColors()
-
-	// Handle FORCE_COLOR override by temporarily clearing NO_COLOR
-	var originalNoColor string
-	var hadNoColor bool
-	if colorsEnabled && os.Getenv("FORCE_COLOR") != "" && os.Getenv("NO_COLOR") != "" {
-		originalNoColor = os.Getenv("NO_COLOR")
-		hadNoColor = true
-		os.Unsetenv("NO_COLOR")
-	}
-
-	// Set the global color.NoColor flag based on our detection
-	color.NoColor = !colorsEnabled
+	colorsEnabled := shouldEnableColors(cfg)
 
-	// Check if per-value pipe colors should be enabled
-	pipeColorsEnabled := os.Getenv("DIAGASSERT_PIPE_COLORS") != "false"
+	// Set the global ansi.NoColor flag based on our detection
+	ansi.NoColor = !colorsEnabled
 
-	config := &ColorConfig{
+	colorConfig := ColorConfig{
 		ColorsEnabled: colorsEnabled,
-		HeaderColor:   color.New(color.FgRed, color.Bold), // Bold red for "ASSERTION FAILED"
-		PipeColor:     color.New(color.FgHiBlack),         // Gray/dim for pipes
-		VariableColor: color.New(color.FgBlue),            // Blue for variables
-		TrueColor:     color.New(color.FgGreen),           // Green for true
-		FalseColor:    color.New(color.FgRed),             // Red for false
-		OperatorColor: color.New(color.FgYellow),          // Yellow for operators
+		Forced:        cfg.ColorsForced(),
+		HeaderColor:   colors.header,
+		PipeColor:     colors.pipe,
+		VariableColor: colors.variable,
+		TrueColor:     colors.trueValue,
+		FalseColor:    colors.falseValue,
+		OperatorColor: colors.operator,
+		SkippedColor:  colors.skipped,
+		CauseColor:    colors.cause,
 
 		// Per-value pipe colors
-		PipeColorPalette:  createPipeColorPalette(),
-		PipeColorsEnabled: pipeColorsEnabled,
+		PipeColorPalette:  pipeColorPalette,
+		PipeColorsEnabled: cfg.PipeColors,
 	}
 
-	// Restore NO_COLOR if it was set
-	if hadNoColor {
-		os.Setenv("NO_COLOR", originalNoColor)
-	}
+	return colorConfig
+}
 
-	return config
+// colors are the colors of the parts of the diagram. A Color holds no state, so every
+// formatter shares them.
+var colors = struct {
+	header, pipe, variable, trueValue, falseValue, operator, skipped, cause *ansi.Color
+}{
+	header:     ansi.New(ansi.FgRed, ansi.Bold),     // Bold red for "ASSERTION FAILED"
+	pipe:       ansi.New(ansi.FgHiBlack),            // Gray/dim for pipes
+	variable:   ansi.New(ansi.FgBlue),               // Blue for variables
+	trueValue:  ansi.New(ansi.FgGreen),              // Green for true
+	falseValue: ansi.New(ansi.FgRed),                // Red for false
+	operator:   ansi.New(ansi.FgYellow),             // Yellow for operators
+	skipped:    ansi.New(ansi.Faint),                // Dim for short-circuited branches
+	cause:      ansi.New(ansi.FgMagenta, ansi.Bold), // Bold magenta for the likely cause
 }
 
-// createPipeColorPalette creates a palette of colors for per-value pipes
+// pipeColorPalette is the palette of colors for per-value pipes
 // Colors are chosen to be distinguishable, accessible, and different from existing colors
-func createPipeColorPalette() []*color.Color {
-	return []*color.Color{
-		color.New(color.FgCyan),      // Cyan - distinguishable from blue
-		color.New(color.FgMagenta),   // Magenta - distinct color
-		color.New(color.FgHiGreen),   // Bright green - different from regular green
-		color.New(color.FgHiYellow),  // Bright yellow - different from regular yellow
-		color.New(color.FgHiBlue),    // Bright blue - different from regular blue
-		color.New(color.FgHiMagenta), // Bright magenta - vibrant
-		color.New(color.FgHiCyan),    // Bright cyan - vivid
-		color.New(color.FgWhite),     // White - good contrast
-	}
+var pipeColorPalette = []*ansi.Color{
+	ansi.New(ansi.FgCyan),      // Cyan - distinguishable from blue
+	ansi.New(ansi.FgMagenta),   // Magenta - distinct color
+	ansi.New(ansi.FgHiGreen),   // Bright green - different from regular green
+	ansi.New(ansi.FgHiYellow),  // Bright yellow - different from regular yellow
+	ansi.New(ansi.FgHiBlue),    // Bright blue - different from regular blue
+	ansi.New(ansi.FgHiMagenta), // Bright magenta - vibrant
+	ansi.New(ansi.FgHiCyan),    // Bright cyan - vivid
+	ansi.New(ansi.FgWhite),     // White - good contrast
 }
 
-// shouldEnableColors detects if colors should be enabled based on environment and terminal capabilities
-func shouldEnableColors() bool {
+// shouldEnableColors detects if colors should be enabled based on environment and terminal capabilities.
+// DIAGASSERT_COLOR chooses explicitly. Otherwise FORCE_COLOR and NO_COLOR are respected, then
+// CI services: those whose logs show colors get them, and other CI=true services do not, since
+// their logs would keep the raw escape sequences. Everywhere else colors follow whether
+// standard output is a terminal.
+func shouldEnableColors(cfg config.Config) bool {
+	switch cfg.Color {
+	case config.ColorAlways:
+		return true
+	case config.ColorNever:
+		return false
+	}
+
 	// Check FORCE_COLOR environment variable first (it should override NO_COLOR)
-	if os.Getenv("FORCE_COLOR") != "" {
+	if cfg.ForceColor {
 		return true
 	}
 
 	// Respect NO_COLOR environment variable (https://no-color.org/)
-	if os.Getenv("NO_COLOR") != "" {
+	if cfg.NoColor {
 		return false
 	}
 
-	// Default to enabling colors in most cases
-	// The fatih/color package will handle terminal detection automatically
-	// We enable colors by default and let the color package decide whether to apply them
-	return true
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if ciRendersColors() {
+		return true
+	}
+	if os.Getenv("CI") == "true" {
+		return false
+	}
+	return stdoutIsColorTerminal()
 }
 
 // GetColorConfig returns the current color configuration (for testing purposes)
 func (f *VisualFormatter) GetColorConfig() *ColorConfig {
-	return f.colorConfig
+	return &f.colorConfig
 }
 
 // FormatVisual formats the evaluation result in power-assert style.
@@ -159,54 +217,233 @@ func (f *VisualFormatter) FormatVisual(result *evaluator.ExpressionResult, file
 	return f.FormatVisualWithContext(result, file, line, customMessage, nil)
 }
 
+// failureFacts are what the sections of a failure are drawn from, found once for the
+// diagram and the machine-readable section alike.
+type failureFacts struct {
+	failingNode  *evaluator.EvaluationTree
+	fingerprint  string
+	failed, runs int // Failures of the fingerprint among the recent runs DIAGASSERT_HISTORY keeps
+	mismatch     []evaluator.MismatchCause
+	contents     []string
+	notes        []string
+	errors       []string
+	differences  []*evaluator.EvaluationTree
+	hints        []string // Empty unless hints are shown
+}
+
+// findFacts finds the facts of the failure of result at file and line.
+func (f *VisualFormatter) findFacts(result *evaluator.ExpressionResult, file string, line int) failureFacts {
+	facts := failureFacts{
+		failingNode: evaluator.FindFailingNode(result.Tree),
+		mismatch:    evaluator.Mismatch(result),
+		notes:       collectNotes(result.Tree),
+		errors:      collectReturnedErrors(result.Tree),
+		differences: collectDifferences(result.Tree),
+	}
+	facts.fingerprint = fingerprintOf(result, facts.failingNode, file, line)
+	facts.failed, facts.runs = failureHistory(facts.fingerprint)
+	facts.contents = collectContents(facts.failingNode)
+	if f.includeHints {
+		facts.hints = evaluator.Hints(result)
+	}
+	return facts
+}
+
 // FormatVisualWithContext formats the evaluation result with context values.
 func (f *VisualFormatter) FormatVisualWithContext(result *evaluator.ExpressionResult, file string, line int, customMessage string, ctx *AssertionContext) string {
 	var b strings.Builder
+	b.Grow(2048)
+
+	facts := f.findFacts(result, file, line)
+
+	// The compact style keeps the whole failure on one line, for logs of many failures
+	if f.style == styleCompact {
+		b.WriteString(formatCompactLine(result, file, line, facts.failingNode, customMessage) + "\n")
+		if f.includeMachineReadable {
+			f.writeMachineReadable(&b, result, file, line, customMessage, ctx, &facts)
+		}
+		return b.String()
+	}
+
+	if f.style == styleMarkdown {
+		return f.formatMarkdown(result, file, line, customMessage, ctx, &facts)
+	}
 
-	// Header with color
-	header := fmt.Sprintf("ASSERTION FAILED at %s:%d", file, line)
-	b.WriteString(f.colorizeHeader(header) + "\n\n")
+	// Header with color, marked when the same failure came and went in recent runs
+	header := Message(MsgAssertionFailed, file, line)
+	b.WriteString(f.colorizeHeader(header))
+	b.WriteByte('\n')
+	if possiblyFlaky(facts.failed, facts.runs) {
+		b.WriteString(f.colorizeHeader(Message(MsgFlaky, facts.failed, facts.runs)) + "\n")
+	}
+	// A diagram contradicting the assertion would mislead, so say so before showing it
+	if causes := facts.mismatch; len(causes) > 0 {
+		b.WriteString("\n" + f.colorizeError(Message(MsgMismatch, result.Tree.Result, result.Result)) + "\n")
+		for _, cause := range mismatchCauses(causes, config.Getenv("DIAGASSERT_LANG")) {
+			b.WriteString("  - " + cause + "\n")
+		}
+	}
+	b.WriteString("\n")
 
 	// Power-assert style visual representation
-	b.WriteString(f.formatPowerAssertStyle(result))
+	from := b.Len()
+	f.writePowerAssertStyle(&b, result)
+	if strings.Contains(b.String()[from:], userValueMarker) {
+		b.WriteString(Message(MsgUserValues) + "\n")
+	}
+
+	// Point at the exact operand that made the assertion fail
+	if facts.failingNode != nil {
+		b.WriteString("\n" + f.colorizeCause(Message(MsgLikelyCause, describeFailure(facts.failingNode))) + "\n")
+	}
+
+	// The elements of the containers whose length made it fail
+	contents := facts.contents
+	if len(contents) > 0 {
+		b.WriteString("\n" + Message(MsgContents) + ":\n")
+		for _, content := range contents {
+			b.WriteString("  " + content + "\n")
+		}
+	}
+
+	// Notes found while evaluating, such as nil pointers in selector chains
+	notes := facts.notes
+	if len(notes) > 0 {
+		b.WriteString("\n" + Message(MsgNotes) + ":\n")
+		for _, note := range notes {
+			b.WriteString(fmt.Sprintf("  - %s\n", note))
+		}
+	}
+
+	// Errors returned by method calls next to their other results, which the diagram shows
+	// as a tuple and so easily hides
+	returnedErrors := facts.errors
+	if len(returnedErrors) > 0 {
+		b.WriteString("\n" + Message(MsgReturnedErrors) + ":\n")
+		for _, returned := range returnedErrors {
+			b.WriteString("  - " + f.colorizeError(returned) + "\n")
+		}
+	}
+
+	// A matcher's own account of why it rejected the value
+	var explanation *evaluator.MatchExplanation
+	if result.Tree != nil {
+		explanation = result.Tree.Explanation
+	}
+	if explanation != nil {
+		b.WriteString("\n" + Message(MsgExplanation, explanation.Matcher) + ":\n")
+		if explanation.Expected != "" {
+			b.WriteString("  " + Message(MsgExpected, explanation.Expected) + "\n")
+		}
+		if explanation.Found != "" {
+			b.WriteString("  " + Message(MsgFound, explanation.Found) + "\n")
+		}
+		for _, detail := range explanation.Details {
+			b.WriteString(fmt.Sprintf("  - %s\n", detail))
+		}
+	}
+
+	// Paths where composite operands of a failed == diverge
+	for _, node := range facts.differences {
+		b.WriteString("\n" + Message(MsgDifferences, node.Text) + ":\n")
+		for _, diff := range node.Differences {
+			b.WriteString(fmt.Sprintf("  %s\n", diff))
+		}
+
+		// Multi-line and JSON operands get a line-based diff instead of a truncated value
+		if lines := f.formatTextDiff(node, f.diffStyle); len(lines) > 0 {
+			b.WriteString("\n" + Message(MsgLineDiff) + ":\n")
+			for _, line := range lines {
+				b.WriteString(strings.TrimRight("  "+line, " ") + "\n")
+			}
+		}
+	}
 
 	// Custom message section
 	if customMessage != "" {
-		b.WriteString("\nCUSTOM MESSAGE:\n")
+		b.WriteString("\n" + Message(MsgCustomMessage) + ":\n")
 		b.WriteString(customMessage + "\n")
 	}
 
+	// Owners of the failing file, for routing the failure
+	if ctx != nil && len(ctx.Owners) > 0 {
+		b.WriteString("\n" + Message(MsgOwners, strings.Join(ctx.Owners, " ")) + "\n")
+	}
+
 	// Captured values section
 	if ctx != nil && len(ctx.Values) > 0 {
-		b.WriteString("\nCAPTURED VALUES:\n")
+		b.WriteString("\n" + Message(MsgCapturedValues) + ":\n")
 		for _, value := range ctx.Values {
-			b.WriteString(fmt.Sprintf("  %s = %v (%T)\n", value.Name, value.Value, value.Value))
+			text, ok := prettyText(value.Value)
+			if full, _, rendered := renderValue(value.Value); rendered {
+				text, ok = full, true
+			}
+			if ok {
+				b.WriteString(fmt.Sprintf("  %s = (%T)\n", value.Name, value.Value))
+				for _, line := range strings.Split(text, "\n") {
+					b.WriteString(strings.TrimRight("    "+line, " ") + "\n")
+				}
+				continue
+			}
+			b.WriteString(fmt.Sprintf("  %s = %s (%T)\n", value.Name, valueText(value.Value), value.Value))
 		}
 	}
 
-	// Machine readable section
-	if f.includeMachineReadable {
-		b.WriteString("\n[MACHINE_READABLE_START]\n")
-		b.WriteString(formatMachineSection(result))
-
-		// Add custom message in machine-readable format
-		if customMessage != "" {
-			b.WriteString(fmt.Sprintf("CUSTOM_MESSAGE: %s\n", customMessage))
+	// Attachments section
+	if ctx != nil && len(ctx.Attachments) > 0 {
+		b.WriteString("\n" + Message(MsgAttachments) + ":\n")
+		for _, a := range ctx.Attachments {
+			b.WriteString(fmt.Sprintf("  %s (%s)\n", a.Name, describeAttachment(a)))
+			if a.Path != "" {
+				b.WriteString("    " + Message(MsgWrittenTo, a.Path) + "\n")
+			}
 		}
+	}
 
-		// Add captured values in machine-readable format
-		if ctx != nil && len(ctx.Values) > 0 {
-			b.WriteString("CAPTURED_VALUES_START\n")
-			for _, value := range ctx.Values {
-				b.WriteString(fmt.Sprintf("VALUE: %s = %v (%T)\n", value.Name, value.Value, value.Value))
+	// Sections contributed by helper packages
+	if ctx != nil {
+		for _, section := range ctx.Sections {
+			b.WriteString(fmt.Sprintf("\n%s:\n", section.Title))
+			for _, line := range section.Lines {
+				b.WriteString(strings.TrimRight("  "+line, " ") + "\n")
 			}
-			b.WriteString("CAPTURED_VALUES_END\n")
 		}
+	}
+
+	// V calls that would fill in the values the diagram could not show
+	if hints := facts.hints; len(hints) > 0 {
+		b.WriteString("\n")
+		b.WriteString(Message(MsgHint))
+		b.WriteString(":\n")
+		for _, hint := range hints {
+			b.WriteString("  " + hint + "\n")
+		}
+	}
 
-		b.WriteString("[MACHINE_READABLE_END]\n")
+	// Machine readable section
+	if f.includeMachineReadable {
+		b.WriteByte('\n')
+		f.writeMachineReadable(&b, result, file, line, customMessage, ctx, &facts)
 	}
 
-	return b.String()
+	output := b.String()
+	if StrictANSI {
+		if err := checkANSI(output, StripColors(output)); err != nil {
+			panic(err)
+		}
+	}
+	return output
+}
+
+// formatMachineReadable formats the machine-readable section of a failure, whose facts
+// the caller has found, with the encoder DIAGASSERT_MACHINE_FORMAT selects.
+func (f *VisualFormatter) formatMachineReadable(result *evaluator.ExpressionResult, file string, line int, customMessage string, ctx *AssertionContext, facts *failureFacts) string {
+	return encodeMachineSection(f.machineRecord(result, file, line, customMessage, ctx, facts))
+}
+
+// writeMachineReadable writes the section formatMachineReadable returns to b.
+func (f *VisualFormatter) writeMachineReadable(b *strings.Builder, result *evaluator.ExpressionResult, file string, line int, customMessage string, ctx *AssertionContext, facts *failureFacts) {
+	writeMachineSection(b, f.machineRecord(result, file, line, customMessage, ctx, facts))
 }
 
 // Color helper functions
@@ -217,19 +454,43 @@ func (f *VisualFormatter) colorizeHeader(text string) string {
 		return text
 	}
 	// If FORCE_COLOR is set, manually apply colors even if NO_COLOR is set
-	if os.Getenv("FORCE_COLOR") != "" && os.Getenv("NO_COLOR") != "" {
+	if f.colorConfig.Forced {
 		return "\033[31;1m" + text + "\033[0;22m"
 	}
 	return f.colorConfig.HeaderColor.Sprint(text)
 }
 
+// colorizeCause applies color to the likely cause line
+func (f *VisualFormatter) colorizeCause(text string) string {
+	if !f.colorConfig.ColorsEnabled {
+		return text
+	}
+	// If FORCE_COLOR is set, manually apply colors even if NO_COLOR is set
+	if f.colorConfig.Forced {
+		return "\033[35;1m" + text + "\033[0;22m"
+	}
+	return f.colorConfig.CauseColor.Sprint(text)
+}
+
+// colorizeError applies color to an error returned by a call
+func (f *VisualFormatter) colorizeError(text string) string {
+	if !f.colorConfig.ColorsEnabled {
+		return text
+	}
+	// If FORCE_COLOR is set, manually apply colors even if NO_COLOR is set
+	if f.colorConfig.Forced {
+		return "\033[31m" + text + "\033[0m"
+	}
+	return f.colorConfig.FalseColor.Sprint(text)
+}
+
 // colorizePipe applies color to pipe characters
 func (f *VisualFormatter) colorizePipe(text string) string {
 	if !f.colorConfig.ColorsEnabled {
 		return text
 	}
 	// If FORCE_COLOR is set, manually apply colors even if NO_COLOR is set
-	if os.Getenv("FORCE_COLOR") != "" && os.Getenv("NO_COLOR") != "" {
+	if f.colorConfig.Forced {
 		return "\033[90m" + text + "\033[0m"
 	}
 	return f.colorConfig.PipeColor.Sprint(text)
@@ -242,7 +503,10 @@ func (f *VisualFormatter) colorizeValue(value string, isOperator bool) string {
 	}
 
 	// If FORCE_COLOR is set, manually apply colors even if NO_COLOR is set
-	if os.Getenv("FORCE_COLOR") != "" && os.Getenv("NO_COLOR") != "" {
+	if f.colorConfig.Forced {
+		if value == notEvaluatedMarker {
+			return "\033[2m" + value + "\033[22m" // Dim for short-circuited branches
+		}
 		if isOperator {
 			return "\033[33m" + value + "\033[0m" // Yellow for operators
 		}
@@ -256,6 +520,11 @@ func (f *VisualFormatter) colorizeValue(value string, isOperator bool) string {
 		}
 	}
 
+	// Short-circuited branches are dimmed
+	if value == notEvaluatedMarker {
+		return f.colorConfig.SkippedColor.Sprint(value)
+	}
+
 	// Special handling for operators
 	if isOperator {
 		return f.colorConfig.OperatorColor.Sprint(value)
@@ -272,140 +541,45 @@ func (f *VisualFormatter) colorizeValue(value string, isOperator bool) string {
 	}
 }
 
-// colorizePipeLine applies color to pipe characters in a line
-func (f *VisualFormatter) colorizePipeLine(line string) string {
+// valuePaint returns the paint of a value shown for position, or nil without colors.
+func (f *VisualFormatter) valuePaint(position ValuePosition) paint {
 	if !f.colorConfig.ColorsEnabled {
-		return line
+		return nil
 	}
-
-	// If FORCE_COLOR is set, manually apply colors even if NO_COLOR is set
-	if os.Getenv("FORCE_COLOR") != "" && os.Getenv("NO_COLOR") != "" {
-		return strings.ReplaceAll(line, "|", "\033[90m|\033[0m")
-	}
-
-	// Replace pipe characters with colored ones
-	return strings.ReplaceAll(line, "|", f.colorConfig.PipeColor.Sprint("|"))
+	isOperator := f.isOperatorValue(position.Expression, position.Value)
+	return func(text string) string { return f.colorizeValue(text, isOperator) }
 }
 
-// colorizePerValuePipeLine applies per-value colors to pipe characters in a line
-// layerAssignment contains the layer information needed to determine which values correspond to which pipes
-// layerIdx is the current layer being processed
-func (f *VisualFormatter) colorizePerValuePipeLine(line string, layerAssignment LayerAssignment, layerIdx int) string {
-	if !f.colorConfig.ColorsEnabled || !f.colorConfig.PipeColorsEnabled {
-		return f.colorizePipeLine(line) // Fall back to standard pipe coloring
-	}
-
-	// Build a map of pipe positions to their corresponding ValuePosition
-	pipeToValue := make(map[int]ValuePosition)
-
-	// Collect all value positions that have pipes in this layer or deeper layers
-	for currentLayerIdx := layerIdx; currentLayerIdx < len(layerAssignment.Layers); currentLayerIdx++ {
-		for _, node := range layerAssignment.Layers[currentLayerIdx] {
-			pipeToValue[node.PipePosition] = node.Position
-		}
-	}
-
-	// If no per-value mapping is available, use default coloring
-	if len(pipeToValue) == 0 {
-		return f.colorizePipeLine(line)
+// pipePaint returns the paint of the pipe running down to the value shown for position, in
+// the value's own color when per-value pipe colors are on, or nil without colors.
+func (f *VisualFormatter) pipePaint(position ValuePosition) paint {
+	if !f.colorConfig.ColorsEnabled {
+		return nil
 	}
-
-	// Build the result string with per-value pipe colors
-	var result strings.Builder
-	lineRunes := []rune(line)
-
-	for pos, char := range lineRunes {
-		if char == '|' {
-			if valuePos, exists := pipeToValue[pos]; exists {
-				// Get the color for this specific value
-				pipeColor := f.getPipeColorForValue(valuePos)
-
-				// Handle FORCE_COLOR case
-				if os.Getenv("FORCE_COLOR") != "" && os.Getenv("NO_COLOR") != "" {
-					result.WriteString(f.forceColorPipe("|", pipeColor))
-				} else {
-					result.WriteString(pipeColor.Sprint("|"))
-				}
-			} else {
-				// Use default pipe color for pipes without specific value mapping
-				result.WriteString(f.colorConfig.PipeColor.Sprint("|"))
-			}
-		} else {
-			result.WriteRune(char)
-		}
+	if !f.colorConfig.PipeColorsEnabled {
+		return f.colorizePipe
 	}
-
-	return result.String()
+	return func(text string) string { return f.colorizePerValuePipe(text, position) }
 }
 
-// buildColoredValueLine builds a value line with appropriate colors for each value
-func (f *VisualFormatter) buildColoredValueLine(layer []VisualNode, maxWidth int) string {
-	if !f.colorConfig.ColorsEnabled {
-		// If colors are disabled, use the simple approach
-		line := strings.Repeat(" ", maxWidth)
-		lineRunes := []rune(line)
-
-		// Track which positions have been colored to avoid overlaps
-		coloredPositions := make(map[int]bool)
-
-		// Place each value
-		for _, node := range layer {
-			value := node.Position.Value
-			startPos := node.PipePosition
-
-			// Place the raw value in the line
-			if startPos < len(lineRunes) {
-				valueRunes := []rune(value)
-				for i, r := range valueRunes {
-					if startPos+i < len(lineRunes) && !coloredPositions[startPos+i] {
-						lineRunes[startPos+i] = r
-						coloredPositions[startPos+i] = true
-					}
-				}
-			}
+// valueLine lays out the values of a layer at their pipes in a line at most maxWidth columns
+// wide. Where values overlap, the first one placed keeps the columns.
+func (f *VisualFormatter) valueLine(layer []VisualNode, maxWidth int) *canvas {
+	width := 0
+	for _, node := range layer {
+		if end := node.PipePosition + utf8.RuneCountInString(node.Position.Value); end > width {
+			width = end
 		}
-
-		return string(lineRunes)
 	}
-
-	// When colors are enabled, build the line more carefully
-	// to avoid ANSI escape sequence corruption
-	var result strings.Builder
-	result.Grow(maxWidth * 2) // Pre-allocate space for colors
-
-	// Sort nodes by position to place them left-to-right
-	sortedNodes := make([]VisualNode, len(layer))
-	copy(sortedNodes, layer)
-	sort.Slice(sortedNodes, func(i, j int) bool {
-		return sortedNodes[i].PipePosition < sortedNodes[j].PipePosition
-	})
-
-	currentPos := 0
-	for _, node := range sortedNodes {
-		value := node.Position.Value
-		startPos := node.PipePosition
-
-		// Add spaces before this value
-		if startPos > currentPos {
-			spacesNeeded := startPos - currentPos
-			result.WriteString(strings.Repeat(" ", spacesNeeded))
-		}
-
-		// Add the colored value
-		isOperator := f.isOperatorValue(node.Position.Expression, value)
-		coloredValue := f.colorizeValue(value, isOperator)
-		result.WriteString(coloredValue)
-
-		// Update current position (visual position, not including ANSI sequences)
-		currentPos = startPos + len([]rune(value))
+	if width > maxWidth {
+		width = maxWidth
 	}
 
-	// Add trailing spaces if needed
-	if currentPos < maxWidth {
-		result.WriteString(strings.Repeat(" ", maxWidth-currentPos))
+	line := newCanvas(width)
+	for _, node := range layer {
+		line.write(node.PipePosition, node.Position.Value, line.addPaint(f.valuePaint(node.Position)))
 	}
-
-	return result.String()
+	return line
 }
 
 // isOperatorValue determines if a value represents an operator result
@@ -426,7 +600,7 @@ func (f *VisualFormatter) isOperatorValue(expression, value string) bool {
 
 // assignPipeColor assigns a color to a pipe based on the expression text
 // Uses deterministic hashing to ensure consistent color assignment
-func (f *VisualFormatter) assignPipeColor(expression string) *color.Color {
+func (f *VisualFormatter) assignPipeColor(expression string) *ansi.Color {
 	if !f.colorConfig.ColorsEnabled || !f.colorConfig.PipeColorsEnabled {
 		return f.colorConfig.PipeColor // Fall back to default pipe color
 	}
@@ -442,7 +616,7 @@ func (f *VisualFormatter) assignPipeColor(expression string) *color.Color {
 }
 
 // getPipeColorForValue gets the appropriate pipe color for a specific value position
-func (f *VisualFormatter) getPipeColorForValue(position ValuePosition) *color.Color {
+func (f *VisualFormatter) getPipeColorForValue(position ValuePosition) *ansi.Color {
 	return f.assignPipeColor(position.Expression)
 }
 
@@ -467,8 +641,8 @@ func (f *VisualFormatter) colorizePerValuePipe(text string, position ValuePositi
 	pipeColor := f.getPipeColorForValue(position)
 
 	// If FORCE_COLOR is set, manually apply colors even if NO_COLOR is set
-	if os.Getenv("FORCE_COLOR") != "" && os.Getenv("NO_COLOR") != "" {
-		// Map color.Color to ANSI codes for force color mode
+	if f.colorConfig.Forced {
+		// Map ansi.Color to ANSI codes for force color mode
 		return f.forceColorPipe(text, pipeColor)
 	}
 
@@ -476,8 +650,8 @@ func (f *VisualFormatter) colorizePerValuePipe(text string, position ValuePositi
 }
 
 // forceColorPipe applies pipe colors manually when FORCE_COLOR is set
-func (f *VisualFormatter) forceColorPipe(text string, pipeColor *color.Color) string {
-	// Map the fatih/color.Color to ANSI codes for force color mode
+func (f *VisualFormatter) forceColorPipe(text string, pipeColor *ansi.Color) string {
+	// Map the ansi.Color to ANSI codes for force color mode
 	// This is a simple mapping for the colors we use in our palette
 
 	// Get the color by comparing with known colors from our palette
@@ -509,6 +683,17 @@ func (f *VisualFormatter) forceColorPipe(text string, pipeColor *color.Color) st
 	return "\033[36m" + text + "\033[0m"
 }
 
+// notEvaluatedMarker is shown in place of values for branches skipped by short-circuit evaluation.
+const notEvaluatedMarker = "(not evaluated)"
+
+// userValueMarker follows the values of fields, calls and indexes passed with V or Values, which
+// may disagree with what the expression would give; see valueLabel.
+const userValueMarker = "ᵛ"
+
+// reevaluatedMarker follows the results of methods called again after the assertion to show
+// them, which a method with side effects may not have returned to the test.
+const reevaluatedMarker = "(re-evaluated)"
+
 // CharPosition represents position information for a character in the expression.
 type CharPosition struct {
 	BytePos   int // バイト位置
@@ -520,13 +705,14 @@ type CharPosition struct {
 type ValuePosition struct {
 	Expression  string
 	Value       string
-	StartPos    int // Byte position in expression
-	EndPos      int // End byte position
-	VisualPos   int // Visual position considering wide characters
-	VisualEnd   int // Visual end position
-	Depth       int // Depth in the AST tree for proper layering
-	Priority    int // Priority for positioning (higher = more important)
-	VisualLayer int // Visual layer assigned for rendering (separate from semantic depth)
+	StartPos    int  // Byte position in expression
+	EndPos      int  // End byte position
+	VisualPos   int  // Visual position considering wide characters
+	VisualEnd   int  // Visual end position
+	Depth       int  // Depth in the AST tree for proper layering
+	Priority    int  // Priority for positioning (higher = more important)
+	Operator    bool // Result of a binary operator, shown under the operator
+	VisualLayer int  // Visual layer assigned for rendering (separate from semantic depth)
 }
 
 // VisualNode separates evaluation depth from visual layers for rendering
@@ -540,9 +726,8 @@ type VisualNode struct {
 
 // LayerAssignment manages the assignment of values to visual layers
 type LayerAssignment struct {
-	MaxLayer      int            // Maximum layer number assigned
-	Layers        [][]VisualNode // Nodes grouped by visual layer
-	PipePositions map[int]bool   // Track fixed pipe positions
+	MaxLayer int            // Maximum layer number assigned
+	Layers   [][]VisualNode // Nodes grouped by visual layer
 }
 
 // Range represents an occupied interval in a visual layer
@@ -559,35 +744,70 @@ type ConflictMap struct {
 type PositionMapper struct {
 	fset          *token.FileSet
 	expr          string
+	root          ast.Expr // Parsed expression; nil if the expression does not parse
 	charPositions []CharPosition
+	collapsed     []int // Byte offsets of collapsed passing operands, in order
 }
 
 // formatPowerAssertStyle generates power-assert style visual output.
 func (f *VisualFormatter) formatPowerAssertStyle(result *evaluator.ExpressionResult) string {
-	expr := result.Expression
+	var b strings.Builder
+	b.Grow(512)
+	f.writePowerAssertStyle(&b, result)
+	return b.String()
+}
+
+// writePowerAssertStyle writes the power-assert style visual output to b.
+func (f *VisualFormatter) writePowerAssertStyle(b *strings.Builder, result *evaluator.ExpressionResult) {
+	expr := normalizeExpression(result.Expression)
 
 	// If no tree, show the expression with proper pipe alignment
 	if result.Tree == nil {
-		return f.formatSimpleAssertStyle(expr)
+		b.WriteString(f.formatSimpleAssertStyle(expr))
+		return
 	}
 
 	// Create position mapper for precise positioning
 	mapper := f.createPositionMapper(expr)
+	tree := result.Tree
+
+	// Huge expressions only expand the branches that failed
+	var footer string
+	if mapper.root != nil && f.shouldCollapse(expr) {
+		collapsedExpr, offsets, prunedTree := collapsePassing(expr, mapper.root, mapper.fset, tree)
+		if len(offsets) > 0 {
+			expr, tree = collapsedExpr, prunedTree
+			mapper = f.createPositionMapper(expr)
+			mapper.collapsed = offsets
+			noun := "sub-expression"
+			if len(offsets) > 1 {
+				noun += "s"
+			}
+			footer = fmt.Sprintf("\n  (%d passing %s shown as %s; set DIAGASSERT_EXPAND=full to expand)\n",
+				len(offsets), noun, collapsedMarker)
+		}
+	}
 
 	// Extract positions using AST-based mapping
-	positions := f.extractAllPositionsWithAST(result.Tree, expr, mapper)
+	positions := f.extractAllPositionsWithAST(tree, expr, mapper)
+
+	// Long expressions are wrapped at operators so the diagram still lines up in narrow terminals
+	if width := f.maxWidth - assertIndent - 1; f.maxWidth > 0 && textwidth.String(expr) > width && len(positions) > 0 {
+		if segments := f.wrapExpression(expr, mapper, width); len(segments) > 1 {
+			b.WriteString(f.formatWrappedAssertStyle(expr, segments, positions, mapper))
+			b.WriteString(footer)
+			return
+		}
+	}
 
 	// Build visual output
-	var b strings.Builder
-	b.WriteString(fmt.Sprintf("  assert(%s)\n", expr))
+	b.WriteString("  assert(")
+	b.WriteString(mapper.display(0, len(expr)))
+	b.WriteString(")\n")
 
 	// Build visual lines with Unicode-aware positioning
-	lines := f.buildUnicodeAwareLines(expr, positions, mapper)
-	for _, line := range lines {
-		b.WriteString("         " + line + "\n")
-	}
-
-	return b.String()
+	f.writePowerAssertTree(b, "         ", expr, positions)
+	b.WriteString(footer)
 }
 
 // formatSimpleAssertStyle formats basic assert style when no tree is available.
@@ -596,7 +816,7 @@ func (f *VisualFormatter) formatSimpleAssertStyle(expr string) string {
 	b.WriteString(fmt.Sprintf("  assert(%s)\n", expr))
 
 	// Add a simple pipe under the end of the expression to show false
-	exprVisualWidth := visualWidth(expr)
+	exprVisualWidth := textwidth.String(expr)
 	padding := strings.Repeat(" ", exprVisualWidth)
 	pipe := f.colorizePipe("|")
 	falseValue := f.colorizeValue("false", false)
@@ -606,41 +826,33 @@ func (f *VisualFormatter) formatSimpleAssertStyle(expr string) string {
 	return b.String()
 }
 
-// isWideRune determines if a rune is a wide character (占2文字分).
-func isWideRune(r rune) bool {
-	return unicode.In(r,
-		unicode.Hiragana,
-		unicode.Katakana,
-		unicode.Han,
-		unicode.Hangul,
-	) || (r >= 0xFF00 && r <= 0xFFEF)
-}
-
-// visualWidth calculates the visual width of a string considering wide characters.
-func visualWidth(s string) int {
-	width := 0
-	for _, r := range s {
-		if isWideRune(r) {
-			width += 2 // 全角は2文字分
-		} else {
-			width++ // 半角は1文字分
-		}
-	}
-	return width
-}
-
 // createPositionMapper creates a position mapper for the expression.
+// The expression is parsed by evaluator.ParseExpr, which keeps it, so that every
+// AST node can be mapped to exact byte offsets within the expression.
 func (f *VisualFormatter) createPositionMapper(expr string) *PositionMapper {
-	fset := token.NewFileSet()
+	expr = normalizeExpression(expr)
 	charPositions := f.calculateCharPositions(expr)
 
+	fset, root, err := evaluator.ParseExpr(evaluator.ParseableExpr(expr))
+	if err != nil {
+		root = nil
+	}
+
 	return &PositionMapper{
 		fset:          fset,
 		expr:          expr,
+		root:          root,
 		charPositions: charPositions,
 	}
 }
 
+// normalizeExpression strips a leading byte order mark and CRLF line endings,
+// which would otherwise shift every position after them.
+func normalizeExpression(expr string) string {
+	expr = strings.TrimPrefix(expr, "\uFEFF")
+	return strings.ReplaceAll(expr, "\r\n", "\n")
+}
+
 // calculateCharPositions calculates position information for each character.
 func (f *VisualFormatter) calculateCharPositions(s string) []CharPosition {
 	positions := make([]CharPosition, 0, len(s))
@@ -661,7 +873,7 @@ func (f *VisualFormatter) calculateCharPositions(s string) []CharPosition {
 		bytePos += runeLen
 		runePos++
 
-		if isWideRune(r) {
+		if textwidth.Wide(r) {
 			visualPos += 2
 		} else {
 			visualPos++
@@ -673,79 +885,88 @@ func (f *VisualFormatter) calculateCharPositions(s string) []CharPosition {
 
 // extractAllPositionsWithAST extracts positions using AST-based mapping.
 func (f *VisualFormatter) extractAllPositionsWithAST(tree *evaluator.EvaluationTree, expr string, mapper *PositionMapper) []ValuePosition {
-	var positions []ValuePosition
+	// Most nodes place one value
+	positions := make([]ValuePosition, 0, countNodes(tree))
 
-	// Parse expression to get AST
-	node, err := parser.ParseExpr(expr)
-	if err != nil {
-		// Fallback to simple position extraction
-		return f.extractAllPositions(tree, expr)
+	// Without a parsed expression there are no reliable offsets to place values at
+	if mapper.root == nil {
+		return positions
 	}
 
-	// Use AST to find precise positions
-	f.collectPositionsWithAST(tree, node, expr, mapper, &positions, make(map[string]bool))
-
-	// If AST-based approach didn't find positions, fallback to simple method
-	if len(positions) == 0 {
-		return f.extractAllPositions(tree, expr)
-	}
+	// Walk the evaluation tree and the AST in parallel to find precise positions
+	f.collectPositionsWithAST(tree, mapper.root, expr, mapper, &positions, make(map[positionKey]bool))
 
 	// Sort by visual position for consistent output
-	sort.Slice(positions, func(i, j int) bool {
-		if positions[i].VisualPos != positions[j].VisualPos {
-			return positions[i].VisualPos < positions[j].VisualPos
-		}
-		return positions[i].Priority > positions[j].Priority
-	})
+	sortStable(positions, columnLess)
 
 	return positions
 }
 
-// extractAllPositions extracts all value positions from the tree (fallback method).
-func (f *VisualFormatter) extractAllPositions(tree *evaluator.EvaluationTree, expr string) []ValuePosition {
-	var positions []ValuePosition
-	mapper := f.createPositionMapper(expr)
-	f.collectPositions(tree, expr, mapper, &positions, make(map[string]bool))
+// columnLess orders positions left to right, the higher priority first at the same column.
+func columnLess(a, b *ValuePosition) bool {
+	if a.VisualPos != b.VisualPos {
+		return a.VisualPos < b.VisualPos
+	}
+	return a.Priority > b.Priority
+}
 
-	// Sort by visual position for consistent output
-	sort.Slice(positions, func(i, j int) bool {
-		if positions[i].VisualPos != positions[j].VisualPos {
-			return positions[i].VisualPos < positions[j].VisualPos
-		}
-		return positions[i].Priority > positions[j].Priority
-	})
+// countNodes returns the number of nodes in tree.
+func countNodes(tree *evaluator.EvaluationTree) int {
+	if tree == nil {
+		return 0
+	}
+	n := 1 + countNodes(tree.Left) + countNodes(tree.Right)
+	for _, child := range tree.Children {
+		n += countNodes(child)
+	}
+	return n
+}
 
-	return positions
+// positionKey identifies a value placed in the diagram: the column it hangs from, the kind
+// of node and its text, so a node reached twice is placed once.
+type positionKey struct {
+	column int
+	kind   string
+	text   string
 }
 
 // collectPositionsWithAST collects positions using AST node mapping.
-func (f *VisualFormatter) collectPositionsWithAST(tree *evaluator.EvaluationTree, astNode ast.Expr, expr string, mapper *PositionMapper, positions *[]ValuePosition, seen map[string]bool) {
+func (f *VisualFormatter) collectPositionsWithAST(tree *evaluator.EvaluationTree, astNode ast.Expr, expr string, mapper *PositionMapper, positions *[]ValuePosition, seen map[positionKey]bool) {
 	f.collectPositionsWithASTDepth(tree, astNode, expr, mapper, positions, seen, 0)
 }
 
 // collectPositionsWithASTDepth collects positions using AST node mapping with depth tracking.
-func (f *VisualFormatter) collectPositionsWithASTDepth(tree *evaluator.EvaluationTree, astNode ast.Expr, expr string, mapper *PositionMapper, positions *[]ValuePosition, seen map[string]bool, depth int) {
-	if tree == nil {
+// astNode is the AST node the evaluation tree node was built from.
+func (f *VisualFormatter) collectPositionsWithASTDepth(tree *evaluator.EvaluationTree, astNode ast.Expr, expr string, mapper *PositionMapper, positions *[]ValuePosition, seen map[positionKey]bool, depth int) {
+	if tree == nil || astNode == nil {
 		return
 	}
 
-	// Map tree text to AST nodes for precise positioning
-	var targetNode ast.Node
-	ast.Inspect(astNode, func(n ast.Node) bool {
-		if n == nil {
-			return false
-		}
+	// The evaluator looks through parentheses, so do the same here
+	targetNode := astutil.Unparen(astNode)
 
-		// Check if this AST node corresponds to our tree node
-		if f.nodeMatches(n, tree, expr) {
-			targetNode = n
-			return false
+	// A short-circuited branch was never evaluated: mark it once and show nothing beneath it
+	if tree.NotEvaluated {
+		startPos, endPos := f.getASTNodePosition(targetNode, mapper)
+		startVisual := f.byteToVisualPos(startPos, mapper.charPositions)
+		key := positionKey{startVisual, "skip", ""}
+		if !seen[key] {
+			seen[key] = true
+			*positions = append(*positions, ValuePosition{
+				Expression: tree.Text,
+				Value:      notEvaluatedMarker,
+				StartPos:   startPos,
+				EndPos:     endPos,
+				VisualPos:  startVisual,
+				VisualEnd:  f.byteToVisualPos(endPos, mapper.charPositions),
+				Depth:      depth,
+				Priority:   12,
+			})
 		}
+		return
+	}
 
-		return true
-	})
-
-	if targetNode != nil {
+	if f.nodeMatches(targetNode, tree, expr) {
 		// Get accurate positions using AST node positions
 		startPos, endPos := f.getASTNodePosition(targetNode, mapper)
 		startVisual := f.byteToVisualPos(startPos, mapper.charPositions)
@@ -753,13 +974,14 @@ func (f *VisualFormatter) collectPositionsWithASTDepth(tree *evaluator.Evaluatio
 
 		switch tree.Type {
 		case "identifier":
-			if tree.Value != nil && tree.Text != "" {
-				key := fmt.Sprintf("%d-%s", startVisual, tree.Text)
+			// The document behind a JSON expression is too large to show under "$"
+			if tree.Value != nil && tree.Text != "" && tree.Text != evaluator.JSONRoot {
+				key := positionKey{startVisual, "", tree.Text}
 				if !seen[key] {
 					seen[key] = true
 					*positions = append(*positions, ValuePosition{
 						Expression: tree.Text,
-						Value:      formatValueCompact(tree.Value),
+						Value:      valueLabel(tree),
 						StartPos:   startPos,
 						EndPos:     endPos,
 						VisualPos:  startVisual,
@@ -772,12 +994,12 @@ func (f *VisualFormatter) collectPositionsWithASTDepth(tree *evaluator.Evaluatio
 
 		case "literal":
 			if tree.Value != nil && tree.Text != "" {
-				key := fmt.Sprintf("%d-lit-%s", startVisual, tree.Text)
+				key := positionKey{startVisual, "lit", tree.Text}
 				if !seen[key] {
 					seen[key] = true
 					*positions = append(*positions, ValuePosition{
 						Expression: tree.Text,
-						Value:      formatValueCompact(tree.Value),
+						Value:      formatNodeCompact(tree),
 						StartPos:   startPos,
 						EndPos:     endPos,
 						VisualPos:  startVisual,
@@ -788,161 +1010,127 @@ func (f *VisualFormatter) collectPositionsWithASTDepth(tree *evaluator.Evaluatio
 				}
 			}
 
-		case "comparison", "logical":
-			if tree.Operator != "" {
-				// Find operator position within the node
-				opPos := f.findOperatorInNode(targetNode, tree.Operator, mapper)
-				opVisual := f.byteToVisualPos(opPos, mapper.charPositions)
-
-				key := fmt.Sprintf("%d-op-%s", opVisual, tree.Operator)
+		case "index":
+			if tree.Value != nil && tree.Text != "" {
+				// Index results are shown under the opening bracket
+				lbrack := mapper.fset.Position(targetNode.(*ast.IndexExpr).Lbrack).Offset
+				lbrackVisual := f.byteToVisualPos(lbrack, mapper.charPositions)
+				key := positionKey{lbrackVisual, "index", tree.Text}
 				if !seen[key] {
 					seen[key] = true
 					*positions = append(*positions, ValuePosition{
-						Expression: tree.Operator,
-						Value:      fmt.Sprintf("%v", tree.Result),
-						StartPos:   opPos,
-						EndPos:     opPos + len(tree.Operator),
-						VisualPos:  opVisual,
-						VisualEnd:  opVisual + visualWidth(tree.Operator),
-						Depth:      depth + 1, // Operator result at deeper level than operands
-						Priority:   5,
+						Expression: tree.Text,
+						Value:      valueLabel(tree),
+						StartPos:   lbrack,
+						EndPos:     endPos,
+						VisualPos:  lbrackVisual,
+						VisualEnd:  endVisual,
+						Depth:      depth + 1, // Index result at deeper level than its operands
+						Priority:   10,
 					})
 				}
 			}
-		}
-	}
-
-	// Process children with proper depth hierarchy for power-assert style display
-	// For binary expressions like "x > 20", we want values (15, 20) at one level,
-	// then operation result (false) at the next level
-	var nextDepth int
-	if tree.Type == "comparison" || tree.Type == "logical" {
-		// For operators, their operands should be at a deeper level to show values first,
-		// then the operation result at the current level
-		nextDepth = depth + 1
-	} else {
-		// For other types, increase depth for children
-		nextDepth = depth + 1
-	}
-
-	f.processChildrenWithASTDepth(tree, astNode, expr, mapper, positions, seen, nextDepth)
-}
-
-// collectPositions recursively collects positions from the tree (fallback method).
-func (f *VisualFormatter) collectPositions(tree *evaluator.EvaluationTree, expr string, mapper *PositionMapper, positions *[]ValuePosition, seen map[string]bool) {
-	f.collectPositionsDepth(tree, expr, mapper, positions, seen, 0)
-}
 
-// collectPositionsDepth recursively collects positions from the tree with depth tracking (fallback method).
-func (f *VisualFormatter) collectPositionsDepth(tree *evaluator.EvaluationTree, expr string, mapper *PositionMapper, positions *[]ValuePosition, seen map[string]bool, depth int) {
-	if tree == nil {
-		return
-	}
-
-	// For simple comparisons like "x > 20", we want to show:
-	// - The value of x under "x"
-	// - The result of the comparison under ">"
-
-	switch tree.Type {
-	case "identifier":
-		if tree.Value != nil && tree.Text != "" {
-			// Find where this identifier appears in the expression
-			if pos := strings.Index(expr, tree.Text); pos != -1 {
-				visualPos := f.byteToVisualPos(pos, mapper.charPositions)
-				// Fallback to byte position if visual position calculation fails
-				if visualPos == 0 && pos != 0 {
-					visualPos = pos
-				}
-				key := fmt.Sprintf("%d-%s", visualPos, tree.Text)
+		case "selector":
+			if tree.Value != nil && tree.Text != "" {
+				// Field values are shown under the field name
+				sel := targetNode.(*ast.SelectorExpr).Sel
+				selStart, selEnd := f.getASTNodePosition(sel, mapper)
+				selVisual := f.byteToVisualPos(selStart, mapper.charPositions)
+				key := positionKey{selVisual, "sel", tree.Text}
 				if !seen[key] {
 					seen[key] = true
 					*positions = append(*positions, ValuePosition{
 						Expression: tree.Text,
-						Value:      formatValueCompact(tree.Value),
-						StartPos:   pos,
-						EndPos:     pos + len(tree.Text),
-						VisualPos:  visualPos,
-						VisualEnd:  visualPos + visualWidth(tree.Text),
+						Value:      valueLabel(tree),
+						StartPos:   selStart,
+						EndPos:     selEnd,
+						VisualPos:  selVisual,
+						VisualEnd:  f.byteToVisualPos(selEnd, mapper.charPositions),
 						Depth:      depth,
-						Priority:   20, // Higher priority for identifier values
+						Priority:   18,
 					})
 				}
 			}
-		}
 
-	case "literal":
-		if tree.Value != nil && tree.Text != "" {
-			// Find where this literal appears in the expression
-			if pos := strings.Index(expr, tree.Text); pos != -1 {
-				visualPos := f.byteToVisualPos(pos, mapper.charPositions)
-				// Fallback to byte position if visual position calculation fails
-				if visualPos == 0 && pos != 0 {
-					visualPos = pos
-				}
-				key := fmt.Sprintf("%d-lit-%s", visualPos, tree.Text)
+		case "method_call":
+			if (tree.Value != nil || tree.Failure != "") && tree.Text != "" {
+				// Results are shown under the method name, below the receiver
+				sel := targetNode.(*ast.CallExpr).Fun.(*ast.SelectorExpr).Sel
+				selStart, selEnd := f.getASTNodePosition(sel, mapper)
+				selVisual := f.byteToVisualPos(selStart, mapper.charPositions)
+				key := positionKey{selVisual, "method", tree.Text}
 				if !seen[key] {
 					seen[key] = true
 					*positions = append(*positions, ValuePosition{
 						Expression: tree.Text,
-						Value:      formatValueCompact(tree.Value),
-						StartPos:   pos,
-						EndPos:     pos + len(tree.Text),
-						VisualPos:  visualPos,
-						VisualEnd:  visualPos + visualWidth(tree.Text),
-						Depth:      depth,
-						Priority:   15, // Show literal values too
+						Value:      valueLabel(tree),
+						StartPos:   selStart,
+						EndPos:     selEnd,
+						VisualPos:  selVisual,
+						VisualEnd:  f.byteToVisualPos(selEnd, mapper.charPositions),
+						Depth:      depth + 1,
+						Priority:   10,
 					})
 				}
 			}
-		}
 
-	case "comparison":
-		// For comparisons, show the result aligned with the operator
-		if tree.Operator != "" {
-			if pos := strings.Index(expr, tree.Operator); pos != -1 {
-				visualPos := f.byteToVisualPos(pos, mapper.charPositions)
-				// Fallback to byte position if visual position calculation fails
-				if visualPos == 0 && pos != 0 {
-					visualPos = pos
-				}
-				key := fmt.Sprintf("%d-op-%s", visualPos, tree.Operator)
+		case "dereference":
+			if tree.Value != nil && tree.Text != "" {
+				// The pointee is shown under the star, below the pointer
+				key := positionKey{startVisual, "deref", tree.Text}
 				if !seen[key] {
 					seen[key] = true
 					*positions = append(*positions, ValuePosition{
-						Expression: tree.Operator,
-						Value:      fmt.Sprintf("%v", tree.Result),
-						StartPos:   pos,
-						EndPos:     pos + len(tree.Operator),
-						VisualPos:  visualPos,
-						VisualEnd:  visualPos + visualWidth(tree.Operator),
-						Depth:      depth + 1, // Operator result at deeper level than operands
-						Priority:   5,
+						Expression: tree.Text,
+						Value:      valueLabel(tree),
+						StartPos:   startPos,
+						EndPos:     startPos + 1,
+						VisualPos:  startVisual,
+						VisualEnd:  startVisual + 1,
+						Depth:      depth + 1,
+						Priority:   10,
 					})
 				}
 			}
-		}
 
-	case "logical":
-		// For logical operators, show the result aligned with the operator
-		if tree.Operator != "" {
-			if pos := strings.Index(expr, tree.Operator); pos != -1 {
-				visualPos := f.byteToVisualPos(pos, mapper.charPositions)
-				// Fallback to byte position if visual position calculation fails
-				if visualPos == 0 && pos != 0 {
-					visualPos = pos
+		case "call":
+			if tree.Value != nil && tree.Text != "" {
+				key := positionKey{startVisual, "call", tree.Text}
+				if !seen[key] {
+					seen[key] = true
+					*positions = append(*positions, ValuePosition{
+						Expression: tree.Text,
+						Value:      valueLabel(tree),
+						StartPos:   startPos,
+						EndPos:     endPos,
+						VisualPos:  startVisual,
+						VisualEnd:  endVisual,
+						Depth:      depth + 1, // Call result at deeper level than its arguments
+						Priority:   10,
+					})
 				}
-				key := fmt.Sprintf("%d-log-%s", visualPos, tree.Operator)
+			}
+
+		case "comparison", "logical", "binary":
+			if tree.Operator != "" && (tree.Type != "binary" || tree.Value != nil) {
+				// The operator position comes straight from the AST
+				opPos := f.findOperatorInNode(targetNode, mapper)
+				opVisual := f.byteToVisualPos(opPos, mapper.charPositions)
+
+				key := positionKey{opVisual, "op", tree.Operator}
 				if !seen[key] {
 					seen[key] = true
 					*positions = append(*positions, ValuePosition{
 						Expression: tree.Operator,
-						Value:      fmt.Sprintf("%v", tree.Result),
-						StartPos:   pos,
-						EndPos:     pos + len(tree.Operator),
-						VisualPos:  visualPos,
-						VisualEnd:  visualPos + visualWidth(tree.Operator),
+						Value:      formatOperatorValue(tree),
+						StartPos:   opPos,
+						EndPos:     opPos + len(tree.Operator),
+						VisualPos:  opVisual,
+						VisualEnd:  opVisual + textwidth.String(tree.Operator),
 						Depth:      depth + 1, // Operator result at deeper level than operands
-						Priority:   3,
+						Priority:   5,
+						Operator:   true,
 					})
 				}
 			}
@@ -952,21 +1140,7 @@ func (f *VisualFormatter) collectPositionsDepth(tree *evaluator.EvaluationTree,
 	// Process children with proper depth hierarchy for power-assert style display
 	// For binary expressions like "x > 20", we want values (15, 20) at one level,
 	// then operation result (false) at the next level
-	var nextDepth int
-	if tree.Type == "comparison" || tree.Type == "logical" {
-		// For operators, their operands should be at a deeper level to show values first,
-		// then the operation result at the current level
-		nextDepth = depth + 1
-	} else {
-		// For other types, increase depth for children
-		nextDepth = depth + 1
-	}
-
-	f.collectPositionsDepth(tree.Left, expr, mapper, positions, seen, nextDepth)
-	f.collectPositionsDepth(tree.Right, expr, mapper, positions, seen, nextDepth)
-	for _, child := range tree.Children {
-		f.collectPositionsDepth(child, expr, mapper, positions, seen, nextDepth)
-	}
+	f.processChildrenWithASTDepth(tree, targetNode, expr, mapper, positions, seen, depth+1)
 }
 
 // Helper functions for AST-based positioning
@@ -975,48 +1149,98 @@ func (f *VisualFormatter) collectPositionsDepth(tree *evaluator.EvaluationTree,
 func (f *VisualFormatter) nodeMatches(astNode ast.Node, tree *evaluator.EvaluationTree, expr string) bool {
 	switch n := astNode.(type) {
 	case *ast.Ident:
-		return tree.Type == "identifier" && n.Name == tree.Text
+		return tree.Type == "identifier" && (n.Name == tree.Text || tree.Text == evaluator.JSONRoot)
 	case *ast.BasicLit:
 		return tree.Type == "literal" && n.Value == tree.Text
 	case *ast.BinaryExpr:
-		return (tree.Type == "comparison" || tree.Type == "logical") && n.Op.String() == tree.Operator
+		return (tree.Type == "comparison" || tree.Type == "logical" || tree.Type == "binary") && n.Op.String() == tree.Operator
+	case *ast.IndexExpr:
+		return tree.Type == "index"
+	case *ast.CallExpr:
+		if _, isMethod := n.Fun.(*ast.SelectorExpr); isMethod {
+			return tree.Type == "method_call"
+		}
+		return tree.Type == "call"
 	case *ast.SelectorExpr:
 		return tree.Type == "selector" && strings.Contains(tree.Text, ".")
+	case *ast.StarExpr:
+		return tree.Type == "dereference"
 	}
 	return false
 }
 
-// findOperatorInNode finds the operator position within an AST node.
-func (f *VisualFormatter) findOperatorInNode(astNode ast.Node, operator string, mapper *PositionMapper) int {
+// findOperatorInNode returns the byte offset of a binary expression's operator.
+func (f *VisualFormatter) findOperatorInNode(astNode ast.Node, mapper *PositionMapper) int {
 	if binExpr, ok := astNode.(*ast.BinaryExpr); ok {
-		leftEnd := mapper.fset.Position(binExpr.X.End()).Offset
-		rightStart := mapper.fset.Position(binExpr.Y.Pos()).Offset
-
-		// Search for operator between left and right operands
-		if leftEnd >= 0 && rightStart <= len(mapper.expr) {
-			betweenStr := mapper.expr[leftEnd:rightStart]
-			opIndex := strings.Index(betweenStr, operator)
-			if opIndex >= 0 {
-				return leftEnd + opIndex
-			}
-		}
-		return leftEnd
+		return mapper.fset.Position(binExpr.OpPos).Offset
 	}
 	return 0
 }
 
 // processChildrenWithASTDepth processes child nodes recursively with depth tracking.
-func (f *VisualFormatter) processChildrenWithASTDepth(tree *evaluator.EvaluationTree, astNode ast.Expr, expr string, mapper *PositionMapper, positions *[]ValuePosition, seen map[string]bool, depth int) {
-	// Process evaluation tree children
+// Each evaluation tree child is paired with the AST sub-expression it was built from.
+func (f *VisualFormatter) processChildrenWithASTDepth(tree *evaluator.EvaluationTree, astNode ast.Expr, expr string, mapper *PositionMapper, positions *[]ValuePosition, seen map[positionKey]bool, depth int) {
+	// Most nodes have few operands, which fit the buffers without allocating
+	var treeBuf [4]*evaluator.EvaluationTree
+	treeChildren := treeBuf[:0]
 	if tree.Left != nil {
-		f.collectPositionsWithASTDepth(tree.Left, astNode, expr, mapper, positions, seen, depth)
+		treeChildren = append(treeChildren, tree.Left)
 	}
 	if tree.Right != nil {
-		f.collectPositionsWithASTDepth(tree.Right, astNode, expr, mapper, positions, seen, depth)
+		treeChildren = append(treeChildren, tree.Right)
 	}
-	for _, child := range tree.Children {
-		f.collectPositionsWithASTDepth(child, astNode, expr, mapper, positions, seen, depth)
+	treeChildren = append(treeChildren, tree.Children...)
+
+	var astBuf [4]ast.Expr
+	astChildren := appendOperands(astBuf[:0], astNode)
+	for i, child := range treeChildren {
+		if i >= len(astChildren) {
+			break
+		}
+		f.collectPositionsWithASTDepth(child, astChildren[i], expr, mapper, positions, seen, depth)
+	}
+}
+
+// appendOperands appends the sub-expressions of an AST node to dst in the order the
+// evaluator stores them as Left, Right and Children of the evaluation tree.
+func appendOperands(dst []ast.Expr, node ast.Expr) []ast.Expr {
+	switch n := node.(type) {
+	case *ast.BinaryExpr:
+		return append(dst, n.X, n.Y)
+	case *ast.UnaryExpr:
+		return append(dst, n.X)
+	case *ast.SelectorExpr:
+		return append(dst, n.X)
+	case *ast.CallExpr:
+		if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+			dst = append(dst, sel.X)
+		}
+		return append(dst, n.Args...)
+	case *ast.IndexExpr:
+		return append(dst, n.X, n.Index)
+	case *ast.SliceExpr:
+		dst = append(dst, n.X)
+		for _, bound := range [...]ast.Expr{n.Low, n.High, n.Max} {
+			if bound != nil {
+				dst = append(dst, bound)
+			}
+		}
+		return dst
+	case *ast.StarExpr:
+		return append(dst, n.X)
+	case *ast.TypeAssertExpr:
+		return append(dst, n.X)
+	case *ast.CompositeLit:
+		if n.Type != nil {
+			dst = append(dst, n.Type)
+		}
+		return append(dst, n.Elts...)
+	case *ast.IndexListExpr:
+		return append(append(dst, n.X), n.Indices...)
+	case *ast.KeyValueExpr:
+		return append(dst, n.Key, n.Value)
 	}
+	return dst
 }
 
 // getASTNodePosition gets the byte position range of an AST node.
@@ -1050,62 +1274,11 @@ func (f *VisualFormatter) byteToVisualPos(bytePos int, charPositions []CharPosit
 	return 0
 }
 
-// buildUnicodeAwareLines builds visual lines with Unicode support and the new layer architecture.
-func (f *VisualFormatter) buildUnicodeAwareLines(expr string, positions []ValuePosition, mapper *PositionMapper) []string {
-	if len(positions) == 0 {
-		return []string{"false"}
-	}
-
-	// Fix visual positions by calculating them correctly based on expression content
-	correctedPositions := f.correctVisualPositions(positions, expr)
-
-	// Use the new layer-based architecture instead of depth-based grouping
-	return f.buildPowerAssertTreeWithLayers(expr, correctedPositions)
-}
-
-// correctVisualPositions fixes visual positions by finding actual positions in the expression
-func (f *VisualFormatter) correctVisualPositions(positions []ValuePosition, expr string) []ValuePosition {
-	corrected := make([]ValuePosition, len(positions))
-	copy(corrected, positions)
-
-	for i := range corrected {
-		pos := &corrected[i]
-
-		// Find the actual position of this expression element in the text
-		actualPos := f.findActualPosition(pos.Expression, expr)
-		if actualPos >= 0 {
-			pos.VisualPos = actualPos
-			pos.VisualEnd = actualPos + visualWidth(pos.Expression)
-		}
-	}
-
-	return corrected
-}
-
-// findActualPosition finds the position of an expression element in the source text
-func (f *VisualFormatter) findActualPosition(element string, expr string) int {
-	// For simple identifiers and operators, use string search
-	if pos := strings.Index(expr, element); pos != -1 {
-		return pos
-	}
-
-	// For operators that might have whitespace variations, try with spaces
-	if len(element) == 1 || (len(element) == 2 && strings.Contains(element, "=")) {
-		spaced := " " + element + " "
-		if pos := strings.Index(expr, spaced); pos != -1 {
-			return pos + 1 // Return position of the operator, not the leading space
-		}
-	}
-
-	return -1
-}
-
 // assignVisualLayers assigns values to visual layers using greedy algorithm to minimize layers
 func (f *VisualFormatter) assignVisualLayers(positions []ValuePosition) LayerAssignment {
 	assignment := LayerAssignment{
-		MaxLayer:      0,
-		Layers:        make([][]VisualNode, 0),
-		PipePositions: make(map[int]bool),
+		MaxLayer: 0,
+		Layers:   make([][]VisualNode, 0, len(positions)),
 	}
 
 	// Convert positions to visual nodes
@@ -1117,23 +1290,23 @@ func (f *VisualFormatter) assignVisualLayers(positions []ValuePosition) LayerAss
 			VisualLayer:     -1, // Unassigned
 			PipePosition:    pos.VisualPos,
 		}
-		assignment.PipePositions[pos.VisualPos] = true
 	}
 
-	// Sort nodes by priority (higher priority gets better layer assignment)
-	sort.Slice(nodes, func(i, j int) bool {
-		if nodes[i].Position.Priority != nodes[j].Position.Priority {
-			return nodes[i].Position.Priority > nodes[j].Position.Priority
-		}
-		return nodes[i].PipePosition < nodes[j].PipePosition
-	})
+	// Earlier nodes get lower layers
+	f.orderForLayers(nodes)
 
 	// Assign each node to the lowest available layer
+	firstLayer, operandsPlaced := 0, false
 	for i := range nodes {
 		layerAssigned := false
 
+		// The stable layout keeps operator results below every operand, however short they are
+		if f.layout == layoutStable && nodes[i].Position.Operator && !operandsPlaced {
+			firstLayer, operandsPlaced = len(assignment.Layers), true
+		}
+
 		// Try to place in existing layers
-		for layerIdx := 0; layerIdx < len(assignment.Layers); layerIdx++ {
+		for layerIdx := firstLayer; layerIdx < len(assignment.Layers); layerIdx++ {
 			if f.canPlaceInLayer(nodes[i], assignment.Layers[layerIdx]) {
 				assignment.Layers[layerIdx] = append(assignment.Layers[layerIdx], nodes[i])
 				nodes[i].VisualLayer = layerIdx
@@ -1143,10 +1316,10 @@ func (f *VisualFormatter) assignVisualLayers(positions []ValuePosition) LayerAss
 		}
 
 		// Create new layer if needed
+		// Create new layer if needed; it starts as a view of the node, which appending copies
 		if !layerAssigned {
-			newLayer := []VisualNode{nodes[i]}
-			assignment.Layers = append(assignment.Layers, newLayer)
-			nodes[i].VisualLayer = len(assignment.Layers) - 1
+			nodes[i].VisualLayer = len(assignment.Layers)
+			assignment.Layers = append(assignment.Layers, nodes[i:i+1:i+1])
 			assignment.MaxLayer = nodes[i].VisualLayer
 		}
 	}
@@ -1160,7 +1333,8 @@ func (f *VisualFormatter) canPlaceInLayer(node VisualNode, layer []VisualNode) b
 
 	for _, existing := range layer {
 		existingRange := f.getValueRange(existing)
-		if f.rangesOverlap(nodeRange.Start, nodeRange.End, existingRange.Start, existingRange.End) {
+		// Keep at least one column between values so adjacent ones (a+b) don't run together
+		if f.rangesOverlap(nodeRange.Start, nodeRange.End+1, existingRange.Start, existingRange.End+1) {
 			return false
 		}
 	}
@@ -1169,23 +1343,48 @@ func (f *VisualFormatter) canPlaceInLayer(node VisualNode, layer []VisualNode) b
 
 // getValueRange calculates the display range for a value
 func (f *VisualFormatter) getValueRange(node VisualNode) Range {
-	valueWidth := visualWidth(node.Position.Value)
+	valueWidth := textwidth.String(node.Position.Value)
 	startPos := node.PipePosition
 	endPos := startPos + valueWidth
 	return Range{Start: startPos, End: endPos}
 }
 
-// buildPowerAssertTreeWithLayers builds power-assert tree using visual layers
-func (f *VisualFormatter) buildPowerAssertTreeWithLayers(expr string, positions []ValuePosition) []string {
+// writePowerAssertTree writes the lines of the power-assert tree of expr to b, each after
+// indent, using visual layers.
+func (f *VisualFormatter) writePowerAssertTree(b *strings.Builder, indent, expr string, positions []ValuePosition) {
 	if len(positions) == 0 {
-		return []string{"false"}
+		b.WriteString(indent + "false\n")
+		return
+	}
+
+	if f.style == styleClassic {
+		for _, line := range f.buildClassicLines(positions) {
+			b.WriteString(indent + line + "\n")
+		}
+		return
 	}
 
 	// Assign values to visual layers
 	layerAssignment := f.assignVisualLayers(positions)
 
-	var result []string
-	exprWidth := visualWidth(expr)
+	exprWidth := textwidth.String(expr)
+
+	// Each pipe runs down to the deepest layer holding a value at its position, and lines
+	// are only as wide as the rightmost pipe; values may run on up to 100 columns further
+	type pipeEnd struct {
+		layer    int
+		position ValuePosition
+	}
+	deepest := make(map[int]pipeEnd, len(positions))
+	pipeWidth := 0
+	for layerIdx, layer := range layerAssignment.Layers {
+		for _, node := range layer {
+			deepest[node.PipePosition] = pipeEnd{layerIdx, node.Position}
+			if node.PipePosition+1 > pipeWidth {
+				pipeWidth = node.PipePosition + 1
+			}
+		}
+	}
 
 	// Build each visual layer
 	for layerIdx := 0; layerIdx <= layerAssignment.MaxLayer; layerIdx++ {
@@ -1198,63 +1397,32 @@ func (f *VisualFormatter) buildPowerAssertTreeWithLayers(expr string, positions
 			continue
 		}
 
-		// Build pipe line with all relevant pipe positions
-		pipeLine := strings.Repeat(" ", exprWidth+100)
-		pipeRunes := []rune(pipeLine)
-
 		// Place pipes for values in this layer AND pipes that continue from deeper layers
-		for pipePos := range layerAssignment.PipePositions {
-			showPipe := false
-
-			// Show pipe if there's a value at this layer
-			for _, node := range layer {
-				if node.PipePosition == pipePos {
-					showPipe = true
-					break
-				}
+		pipes := newCanvas(pipeWidth)
+		for pipePos, end := range deepest {
+			if end.layer >= layerIdx {
+				pipes.put(pipePos, '|', pipes.addPaint(f.pipePaint(end.position)))
 			}
-
-			// Show pipe if there are values at deeper layers
-			if !showPipe && layerIdx < layerAssignment.MaxLayer {
-				for futureLayerIdx := layerIdx + 1; futureLayerIdx < len(layerAssignment.Layers); futureLayerIdx++ {
-					for _, futureNode := range layerAssignment.Layers[futureLayerIdx] {
-						if futureNode.PipePosition == pipePos {
-							showPipe = true
-							break
-						}
-					}
-					if showPipe {
-						break
-					}
-				}
-			}
-
-			if showPipe && pipePos < len(pipeRunes) {
-				pipeRunes[pipePos] = '|'
-			}
-		}
-
-		// Add pipe line with color (use per-value coloring if enabled)
-		pipeStr := f.colorizePerValuePipeLine(string(pipeRunes), layerAssignment, layerIdx)
-		pipeStr = strings.TrimRight(pipeStr, " ")
-		if pipeStr != "" {
-			result = append(result, pipeStr)
-		}
-
-		// Build value line with colors
-		valueStr := f.buildColoredValueLine(layer, exprWidth+100)
-		valueStr = strings.TrimRight(valueStr, " ")
-		if valueStr != "" {
-			result = append(result, valueStr)
 		}
+		f.writeLine(b, indent, pipes)
+		f.writeLine(b, indent, f.valueLine(layer, exprWidth+100))
 
 		// Add spacing between layers (except for the last layer)
 		if layerIdx < layerAssignment.MaxLayer {
-			result = append(result, "")
+			b.WriteString(indent + "\n")
 		}
 	}
+}
 
-	return result
+// writeLine writes the line of c after indent to b, unless c is blank, and releases c.
+func (f *VisualFormatter) writeLine(b *strings.Builder, indent string, c *canvas) {
+	if c.width() == 0 {
+		c.release()
+		return
+	}
+	b.WriteString(indent)
+	c.renderTo(b)
+	b.WriteByte('\n')
 }
 
 // rangesOverlap checks if two ranges overlap
@@ -1272,9 +1440,9 @@ func formatValueCompact(v interface{}) string {
 	case string:
 		// Improve string truncation with better length limits
 		if len(val) > 10 {
-			return fmt.Sprintf("%q...", val[:10])
+			return strconv.Quote(truncateAt(val, 10)) + "..."
 		}
-		return fmt.Sprintf("%q", val)
+		return strconv.Quote(val)
 	case []int:
 		return formatSliceCompact(val)
 	case []string:
@@ -1282,23 +1450,124 @@ func formatValueCompact(v interface{}) string {
 	case []interface{}:
 		return formatInterfaceSliceCompact(val)
 	case bool:
-		return fmt.Sprintf("%v", val)
-	case int, int8, int16, int32, int64:
+		return strconv.FormatBool(val)
+	case int:
+		return strconv.Itoa(val)
+	case int8, int16, int32, int64:
 		return fmt.Sprintf("%v", val)
 	case uint, uint8, uint16, uint32, uint64:
 		return fmt.Sprintf("%v", val)
 	case float32, float64:
 		return fmt.Sprintf("%v", val)
 	default:
+		// Registered renderers know better than reflection, e.g. for protobuf messages
+		if _, compact, ok := renderValue(val); ok {
+			if len(compact) > 15 {
+				return truncateAt(compact, 15) + "..."
+			}
+			return compact
+		}
+
+		// Then the text the value describes itself with, as errors and time.Time do
+		if text, ok := preferredText(val); ok {
+			if len(text) > 15 {
+				return truncateAt(text, 15) + "..."
+			}
+			return text
+		}
+
 		// For structs and other complex types, try to format them nicely
 		s := formatStructCompact(val)
 		if len(s) > 15 {
-			return s[:15] + "..."
+			return truncateAt(s, 15) + "..."
 		}
 		return s
 	}
 }
 
+// truncateAt cuts s, which is longer than n bytes, to at most n bytes without splitting
+// a multibyte character such as those of 名前.
+func truncateAt(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// formatOperatorValue formats the value shown under an operator:
+// the computed value for arithmetic operators and the boolean result otherwise.
+func formatOperatorValue(node *evaluator.EvaluationTree) string {
+	if node.Type == "binary" {
+		return formatValueCompact(node.Value)
+	}
+	return fmt.Sprintf("%v", node.Result)
+}
+
+// formatNodeCompact formats a node's value in a compact way.
+// Numeric literals keep the notation they were written in (0x1F, 0b1010, 0o755, 1_000)
+// so that bit flags and permissions read the same as in the source, and bytes or runes
+// are shown together with the character they represent.
+func formatNodeCompact(node *evaluator.EvaluationTree) string {
+	if isCharNode(node) {
+		return formatCharValue(node.Value)
+	}
+	if isNumericLiteral(node) {
+		return node.Text
+	}
+	return formatValueCompact(node.Value)
+}
+
+// isCharNode reports whether a node holds a character: a rune literal like 'a'
+// or a byte obtained by indexing a string.
+func isCharNode(node *evaluator.EvaluationTree) bool {
+	switch node.Value.(type) {
+	case byte, rune:
+	default:
+		return false
+	}
+
+	switch node.Type {
+	case "literal":
+		return strings.HasPrefix(node.Text, "'")
+	case "index":
+		if node.Left == nil {
+			return false
+		}
+		_, isString := node.Left.Value.(string)
+		return isString
+	}
+	return false
+}
+
+// formatCharValue formats a byte or rune as its numeric value followed by the quoted character.
+func formatCharValue(v interface{}) string {
+	switch c := v.(type) {
+	case byte:
+		if c >= utf8.RuneSelf {
+			// A lone byte above ASCII is not a character on its own
+			return fmt.Sprintf("%d ('\\x%02x')", c, c)
+		}
+		return fmt.Sprintf("%d (%q)", c, rune(c))
+	case rune:
+		return fmt.Sprintf("%d (%q)", c, c)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// isNumericLiteral reports whether a node is a literal with a numeric value.
+func isNumericLiteral(node *evaluator.EvaluationTree) bool {
+	if node.Type != "literal" || node.Value == nil || node.Text == "" {
+		return false
+	}
+	switch reflect.ValueOf(node.Value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
 // formatSliceCompact formats an int slice in a compact way.
 func formatSliceCompact(slice []int) string {
 	if len(slice) == 0 {
@@ -1321,7 +1590,7 @@ func formatStringSliceCompact(slice []string) string {
 	}
 	first := slice[0]
 	if len(first) > 5 {
-		first = first[:5] + "..."
+		first = truncateAt(first, 5) + "..."
 	}
 	return fmt.Sprintf("[%q,...]", first)
 }
@@ -1341,8 +1610,8 @@ func formatInterfaceSliceCompact(slice []interface{}) string {
 func formatStructCompact(v interface{}) string {
 	val := reflect.ValueOf(v)
 
-	// Handle pointers
-	if val.Kind() == reflect.Ptr {
+	// Handle pointers, following pointers to pointers
+	for val.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			return "nil"
 		}
@@ -1354,64 +1623,80 @@ func formatStructCompact(v interface{}) string {
 		typ := val.Type()
 		var fields []string
 
-		// Show first 2 fields
-		for i := 0; i < val.NumField() && i < 2; i++ {
+		// Show the first 2 fields, leaving out those tagged `diag:"-"`
+		shown := 0
+		for i := 0; i < val.NumField(); i++ {
+			tag := evaluator.ParseFieldTag(typ.Field(i))
+			if tag.Omit {
+				continue
+			}
+			if shown == 2 {
+				fields = append(fields, "...")
+				break
+			}
+			shown++
+
 			field := val.Field(i)
 			if field.CanInterface() {
 				fieldName := typ.Field(i).Name
-				fieldValue := formatValueCompact(field.Interface())
+				fieldValue, ok := evaluator.FormatTagged(tag, field)
+				if !ok {
+					fieldValue = formatValueCompact(field.Interface())
+				}
 				fields = append(fields, fmt.Sprintf("%s:%s", fieldName, fieldValue))
 			}
 		}
 
-		if val.NumField() > 2 {
-			fields = append(fields, "...")
-		}
-
 		return fmt.Sprintf("{%s}", strings.Join(fields, ","))
 	}
 
 	// Fallback to regular formatting
-	s := fmt.Sprintf("%v", v)
+	s := valueText(v)
 	if len(s) > 10 {
-		return s[:10] + "..."
+		return truncateAt(s, 10) + "..."
 	}
 	return s
 }
 
-// formatMachineSection formats the machine-readable section.
-func formatMachineSection(result *evaluator.ExpressionResult) string {
-	var parts []string
-
-	parts = append(parts, fmt.Sprintf("EXPR: %s", result.Expression))
-	parts = append(parts, fmt.Sprintf("RESULT: %v", result.Result))
-
-	// Add variables
-	if len(result.Variables) > 0 {
-		var vars []string
-		for name, value := range result.Variables {
-			vars = append(vars, fmt.Sprintf("%s=%v", name, value))
-		}
-		sort.Strings(vars)
-		parts = append(parts, fmt.Sprintf("VARIABLES: %s", strings.Join(vars, ",")))
+// describeAttachment summarizes an attachment's media type and size, e.g. "application/json, 1.5 KiB".
+func describeAttachment(a Attachment) string {
+	size := formatByteSize(a.Size)
+	if a.MIME == "" {
+		return size
 	}
+	return a.MIME + ", " + size
+}
 
-	// Add step-by-step evaluation if tree is available
-	if result.Tree != nil {
-		parts = append(parts, "EVALUATION_STEPS:")
-		steps := extractEvaluationSteps(result.Tree)
-		for i, step := range steps {
-			parts = append(parts, fmt.Sprintf("  Step %d: %s", i+1, step))
+// formatByteSize renders a byte count with a binary unit, e.g. "512 B" or "1.5 KiB".
+func formatByteSize(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n) / unit
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		if size < unit || suffix == "GiB" {
+			return fmt.Sprintf("%.1f %s", size, suffix)
 		}
+		size /= unit
 	}
-
-	return strings.Join(parts, "\n") + "\n"
+	return fmt.Sprintf("%d B", n)
 }
 
-// extractEvaluationSteps traverses the evaluation tree and returns step-by-step evaluation
-func extractEvaluationSteps(tree *evaluator.EvaluationTree) []string {
+// EvaluationSteps traverses the evaluation tree and returns step-by-step evaluation,
+// as listed under EVALUATION_STEPS in the machine-readable section
+func EvaluationSteps(tree *evaluator.EvaluationTree) []string {
 	var steps []string
-	var nodeCounter int
+	for _, step := range evaluationSteps(tree) {
+		steps = append(steps, step.Text+" [node "+step.NodeID+"]")
+	}
+	return steps
+}
+
+// evaluationSteps returns the steps of the evaluation in the order they were taken.
+func evaluationSteps(tree *evaluator.EvaluationTree) []MachineStep {
+	// Never nil, even for no steps; room for those of a typical assertion
+	steps := make([]MachineStep, 0, 8)
 
 	// Helper function to traverse the tree in evaluation order
 	var traverse func(node *evaluator.EvaluationTree)
@@ -1420,6 +1705,12 @@ func extractEvaluationSteps(tree *evaluator.EvaluationTree) []string {
 			return
 		}
 
+		// Skipped branches are reported once, without their operands
+		if node.NotEvaluated {
+			steps = append(steps, MachineStep{Text: stepText(node.Text, notEvaluatedMarker), NodeID: node.ID})
+			return
+		}
+
 		// First traverse children (post-order traversal for evaluation order)
 		if node.Left != nil {
 			traverse(node.Left)
@@ -1429,10 +1720,9 @@ func extractEvaluationSteps(tree *evaluator.EvaluationTree) []string {
 		}
 
 		// Then process this node
-		nodeCounter++
 		step := formatEvaluationStep(node)
 		if step != "" {
-			steps = append(steps, step)
+			steps = append(steps, MachineStep{Text: step, NodeID: node.ID})
 		}
 	}
 
@@ -1440,73 +1730,386 @@ func extractEvaluationSteps(tree *evaluator.EvaluationTree) []string {
 	return steps
 }
 
+// isPredeclaredConstant reports whether a node is one of the identifiers nil, true or false.
+func isPredeclaredConstant(node *evaluator.EvaluationTree) bool {
+	if node.Type != "identifier" {
+		return false
+	}
+	switch node.Text {
+	case "nil", "true", "false":
+		return true
+	}
+	return false
+}
+
+// collectStaticTypes maps every operand with a static type to the type. Comparisons and
+// logical operators are always bool and left out.
+func collectStaticTypes(tree *evaluator.EvaluationTree) map[string]string {
+	var staticTypes map[string]string
+	var walk func(node *evaluator.EvaluationTree)
+	walk = func(node *evaluator.EvaluationTree) {
+		if node == nil {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+		if node.StaticType != "" && node.Type != "comparison" && node.Type != "logical" && node.Type != "literal" {
+			if staticTypes == nil {
+				staticTypes = map[string]string{}
+			}
+			staticTypes[node.Text] = node.StaticType
+		}
+	}
+	walk(tree)
+	return staticTypes
+}
+
+// collectSources maps the operands with values to where the values came from, such as "user"
+// for those passed with V. Literals, comparisons and logical operators are left out.
+func collectSources(tree *evaluator.EvaluationTree) map[string]string {
+	var sources map[string]string
+	var walk func(node *evaluator.EvaluationTree)
+	walk = func(node *evaluator.EvaluationTree) {
+		if node == nil {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+		switch {
+		case node.Provenance == evaluator.ProvenanceUnknown, node.Provenance == evaluator.ProvenanceLiteral,
+			node.Type == "comparison", node.Type == "logical":
+			return
+		}
+		if sources == nil {
+			sources = map[string]string{}
+		}
+		sources[node.Text] = node.Provenance.String()
+	}
+	walk(tree)
+	return sources
+}
+
+// staticTypeSuffix shows an operand's static type when its value does not reveal it: an
+// interface holding a number, or a typed constant such as time.Second shown as an integer.
+func staticTypeSuffix(node *evaluator.EvaluationTree) string {
+	if node.StaticType == "" || strings.HasPrefix(node.StaticType, "untyped ") || !evaluator.HasKnownResult(node) {
+		return ""
+	}
+	if node.Value != nil && fmt.Sprintf("%T", node.Value) == node.StaticType {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", node.StaticType)
+}
+
+// collectNotes gathers the notes attached to evaluated nodes in evaluation order.
+func collectNotes(tree *evaluator.EvaluationTree) []string {
+	var notes []string
+
+	var walk func(node *evaluator.EvaluationTree)
+	walk = func(node *evaluator.EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+		if node.Note != "" {
+			notes = append(notes, node.Note)
+		}
+	}
+
+	walk(tree)
+	return notes
+}
+
+// collectReturnedErrors gathers the errors evaluated calls returned after their other
+// results, as "p.Parse(s): invalid syntax", in evaluation order.
+func collectReturnedErrors(tree *evaluator.EvaluationTree) []string {
+	var errs []string
+
+	var walk func(node *evaluator.EvaluationTree)
+	walk = func(node *evaluator.EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+		if node.Err != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", node.Text, node.Err))
+		}
+	}
+
+	walk(tree)
+	return errs
+}
+
+// collectDifferences returns the evaluated comparison nodes that recorded deep-equality differences.
+func collectDifferences(tree *evaluator.EvaluationTree) []*evaluator.EvaluationTree {
+	var nodes []*evaluator.EvaluationTree
+
+	var walk func(node *evaluator.EvaluationTree)
+	walk = func(node *evaluator.EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+		walk(node.Left)
+		walk(node.Right)
+		if len(node.Differences) > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+
+	walk(tree)
+	return nodes
+}
+
+// describeFailure explains in one sentence why a failing node is false,
+// e.g. "user.Age >= 18 is false because user.Age = 16".
+func describeFailure(node *evaluator.EvaluationTree) string {
+	if e := node.Explanation; e != nil {
+		if e.Found != "" {
+			return fmt.Sprintf("%s does not match %s: %s", e.Actual, e.Matcher, e.Found)
+		}
+		return fmt.Sprintf("%s does not match %s", e.Actual, e.Matcher)
+	}
+	if node.Err != "" {
+		return fmt.Sprintf("%s returned an error: %s", node.Text, node.Err)
+	}
+
+	// Composite values that differ are told apart by where, rather than in full
+	if paths := differencePaths(node.Differences); node.Type == "comparison" && len(paths) > 0 {
+		return fmt.Sprintf("%s is false because they differ at %s", node.Text, strings.Join(paths, ", "))
+	}
+
+	var operands []*evaluator.EvaluationTree
+	switch node.Type {
+	case "comparison":
+		operands = []*evaluator.EvaluationTree{node.Left, node.Right}
+	case "unary":
+		operands = []*evaluator.EvaluationTree{node.Left}
+	case "logical":
+		return fmt.Sprintf("%s is false because every operand is false", node.Text)
+	}
+
+	// Literals and predeclared constants speak for themselves; only report the values that came from the test
+	var reasons []string
+	for _, operand := range operands {
+		if operand == nil || operand.Type == "literal" || isPredeclaredConstant(operand) {
+			continue
+		}
+		value := formatNodeValue(operand)
+		if operand.Value == nil && (operand.Type == "comparison" || operand.Type == "logical") {
+			// Conditions are known by their result
+			value = formatNodeResult(operand)
+		}
+		reasons = append(reasons, fmt.Sprintf("%s = %s%s", operand.Text, value, staticTypeSuffix(operand)))
+	}
+
+	if len(reasons) == 0 {
+		return fmt.Sprintf("%s is false", node.Text)
+	}
+	return fmt.Sprintf("%s is false because %s", node.Text, strings.Join(reasons, ", "))
+}
+
+// differencePaths returns the paths of deep differences such as ".Items[3].Price: 10 != 12",
+// or nil if any difference is of another kind, as those of strings or registered differs.
+func differencePaths(differences []string) []string {
+	var paths []string
+	for _, difference := range differences {
+		if difference == "..." {
+			paths = append(paths, difference)
+			continue
+		}
+		path := differencePath(difference)
+		if !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "[") && path != "(root)" {
+			return nil
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// differencePath returns the text of difference before the first ": " outside the quoted
+// map keys of its path, or "" if there is none.
+func differencePath(difference string) string {
+	quoted := false
+	for i := 0; i+1 < len(difference); i++ {
+		switch c := difference[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ':' && difference[i+1] == ' ':
+			return difference[:i]
+		}
+	}
+	return ""
+}
+
+// extractShortCircuits describes every short-circuited && or || in the tree.
+// Chains such as "a && b && c" are reported once, attributed to the operand that decided them:
+// "`a` => false (not evaluated: `b`, `c`)".
+func extractShortCircuits(tree *evaluator.EvaluationTree) []string {
+	var results []string
+
+	var walk func(node *evaluator.EvaluationTree)
+	walk = func(node *evaluator.EvaluationTree) {
+		if node == nil || node.NotEvaluated {
+			return
+		}
+
+		if isShortCircuited(node) {
+			decider := node.Left
+			skipped := []string{fmt.Sprintf("`%s`", node.Right.Text)}
+			for isShortCircuited(decider) && decider.Operator == node.Operator {
+				skipped = append([]string{fmt.Sprintf("`%s`", decider.Right.Text)}, skipped...)
+				decider = decider.Left
+			}
+
+			results = append(results, fmt.Sprintf("`%s` => %v (not evaluated: %s)",
+				decider.Text, decider.Result, strings.Join(skipped, ", ")))
+			walk(decider)
+			return
+		}
+
+		walk(node.Left)
+		walk(node.Right)
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+
+	walk(tree)
+	return results
+}
+
+// isShortCircuited reports whether a logical node skipped its right operand.
+func isShortCircuited(node *evaluator.EvaluationTree) bool {
+	return node != nil && node.Type == "logical" && node.Right != nil && node.Right.NotEvaluated
+}
+
 // formatEvaluationStep formats a single evaluation step
 func formatEvaluationStep(node *evaluator.EvaluationTree) string {
+	// Steps are joined with + rather than fmt: every failure lists them twice, in
+	// EVALUATION_STEPS and for the failure hooks
+	if node.Failure != "" {
+		return stepText(node.Text, node.Failure)
+	}
+	result := strconv.FormatBool(node.Result)
 	switch node.Type {
 	case "identifier":
 		if node.Value != nil {
-			return fmt.Sprintf("`%s` => %v", node.Text, node.Value)
+			return stepText(node.Text, machineValueText(node.Value))
 		}
-		return fmt.Sprintf("`%s` => <%s>", node.Text, node.Text)
+		return stepText(node.Text, "<"+node.Text+">")
 
 	case "literal":
-		return fmt.Sprintf("`%s` => %v", node.Text, node.Value)
+		return stepText(node.Text, formatNodeValue(node))
 
 	case "comparison":
 		if node.Left != nil && node.Right != nil {
 			leftVal := formatNodeValue(node.Left)
 			rightVal := formatNodeValue(node.Right)
-			return fmt.Sprintf("`%s` with %s %s %s => %v",
-				node.Text, leftVal, node.Operator, rightVal, node.Result)
+			return "`" + node.Text + "` with " + leftVal + " " + node.Operator + " " + rightVal + " => " + result
 		}
-		return fmt.Sprintf("`%s` => %v", node.Text, node.Result)
+		return stepText(node.Text, result)
 
 	case "logical":
 		if node.Left != nil && node.Right != nil {
 			leftVal := formatNodeResult(node.Left)
 			rightVal := formatNodeResult(node.Right)
-			return fmt.Sprintf("`%s` with %s %s %s => %v",
-				node.Text, leftVal, node.Operator, rightVal, node.Result)
+			return "`" + node.Text + "` with " + leftVal + " " + node.Operator + " " + rightVal + " => " + result
+		}
+		return stepText(node.Text, result)
+
+	case "binary":
+		if node.Left != nil && node.Right != nil && node.Value != nil {
+			leftVal := formatNodeValue(node.Left)
+			rightVal := formatNodeValue(node.Right)
+			return "`" + node.Text + "` with " + leftVal + " " + node.Operator + " " + rightVal + " => " + machineValueText(node.Value)
 		}
-		return fmt.Sprintf("`%s` => %v", node.Text, node.Result)
+		return stepText(node.Text, formatNodeValue(node))
 
 	case "unary":
 		if node.Right != nil {
 			rightVal := formatNodeValue(node.Right)
-			return fmt.Sprintf("`%s` with %s%s => %v",
-				node.Text, node.Operator, rightVal, node.Result)
+			return "`" + node.Text + "` with " + node.Operator + rightVal + " => " + result
 		}
-		return fmt.Sprintf("`%s` => %v", node.Text, node.Result)
+		return stepText(node.Text, result)
 
 	case "call":
-		return fmt.Sprintf("`%s` => %v", node.Text, node.Value)
+		return stepText(node.Text, machineValueText(node.Value))
 
 	case "index":
-		return fmt.Sprintf("`%s` => %v", node.Text, node.Value)
+		return stepText(node.Text, formatNodeValue(node))
 
 	case "selector":
-		return fmt.Sprintf("`%s` => %v", node.Text, node.Value)
+		return stepText(node.Text, machineValueText(node.Value))
 
 	default:
 		// For any other types, show the expression and its result if available
 		if node.Value != nil {
-			return fmt.Sprintf("`%s` => %v", node.Text, node.Value)
+			return stepText(node.Text, machineValueText(node.Value))
 		}
 		// Result is always available (bool type)
-		return fmt.Sprintf("`%s` => %v", node.Text, node.Result)
+		return stepText(node.Text, result)
 	}
 }
 
-// formatNodeValue returns a string representation of a node's value
+// stepText is the text of an evaluation step giving the value of an expression.
+func stepText(expr, value string) string {
+	return "`" + expr + "` => " + value
+}
+
+// valueLabel is the text shown under a node in the diagram: its value, marked with where it
+// came from when that was not the expression itself, or what went wrong evaluating it.
+func valueLabel(node *evaluator.EvaluationTree) string {
+	switch {
+	case node.Failure != "":
+		return node.Failure
+	case node.Reevaluated:
+		return formatNodeCompact(node) + " " + reevaluatedMarker
+	case node.Provenance == evaluator.ProvenanceUser && node.Type != "identifier":
+		// Variables can only be passed; fields and calls could also have been worked out
+		return formatNodeCompact(node) + userValueMarker
+	}
+	return formatNodeCompact(node)
+}
+
+// formatNodeValue returns a string representation of a node's value, without addresses as
+// the machine-readable section shows it
 func formatNodeValue(node *evaluator.EvaluationTree) string {
+	if node.Failure != "" {
+		return node.Failure
+	}
+	if isCharNode(node) {
+		return formatCharValue(node.Value)
+	}
+	if isNumericLiteral(node) {
+		return node.Text
+	}
 	if node.Value != nil {
-		return fmt.Sprintf("%v", node.Value)
+		return machineValueText(node.Value)
 	}
-	return fmt.Sprintf("<%s>", node.Text)
+	return "<" + node.Text + ">"
 }
 
 // formatNodeResult returns a string representation of a node's result
 func formatNodeResult(node *evaluator.EvaluationTree) string {
+	if node.NotEvaluated {
+		return notEvaluatedMarker
+	}
 	// Result is always available (bool type)
-	return fmt.Sprintf("%v", node.Result)
+	return strconv.FormatBool(node.Result)
 }
@@ -1,9 +1,11 @@
 package formatter
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
+	"github.com/paveg/diagassert/internal/ansi"
 	"github.com/paveg/diagassert/internal/evaluator"
 )
 
@@ -25,19 +27,19 @@ func TestVisualFormatter_FormatVisual(t *testing.T) {
 					"x": 10,
 				},
 				Tree: &evaluator.EvaluationTree{
-					ID:       1,
+					ID:       "1",
 					Type:     "comparison",
 					Operator: ">",
 					Text:     "x > 20",
 					Result:   false,
 					Left: &evaluator.EvaluationTree{
-						ID:    2,
+						ID:    "2",
 						Type:  "identifier",
 						Text:  "x",
 						Value: 10,
 					},
 					Right: &evaluator.EvaluationTree{
-						ID:    3,
+						ID:    "3",
 						Type:  "literal",
 						Text:  "20",
 						Value: 20,
@@ -64,26 +66,26 @@ func TestVisualFormatter_FormatVisual(t *testing.T) {
 					"hasLicense": false,
 				},
 				Tree: &evaluator.EvaluationTree{
-					ID:       1,
+					ID:       "1",
 					Type:     "logical",
 					Operator: "&&",
 					Text:     "age >= 18 && hasLicense",
 					Result:   false,
 					Left: &evaluator.EvaluationTree{
-						ID:       2,
+						ID:       "2",
 						Type:     "comparison",
 						Operator: ">=",
 						Text:     "age >= 18",
 						Result:   false,
 						Left: &evaluator.EvaluationTree{
-							ID:    3,
+							ID:    "3",
 							Type:  "identifier",
 							Text:  "age",
 							Value: 16,
 						},
 					},
 					Right: &evaluator.EvaluationTree{
-						ID:     4,
+						ID:     "4",
 						Type:   "identifier",
 						Text:   "hasLicense",
 						Value:  false,
@@ -112,19 +114,19 @@ func TestVisualFormatter_FormatVisual(t *testing.T) {
 					"y": 10,
 				},
 				Tree: &evaluator.EvaluationTree{
-					ID:       1,
+					ID:       "1",
 					Type:     "comparison",
 					Operator: "==",
 					Text:     "x == y",
 					Result:   false,
 					Left: &evaluator.EvaluationTree{
-						ID:    2,
+						ID:    "2",
 						Type:  "identifier",
 						Text:  "x",
 						Value: 5,
 					},
 					Right: &evaluator.EvaluationTree{
-						ID:    3,
+						ID:    "3",
 						Type:  "identifier",
 						Text:  "y",
 						Value: 10,
@@ -223,6 +225,7 @@ func TestVisualFormatter_FormatValueCompact(t *testing.T) {
 		{"int value", 42, "42"},
 		{"bool value", true, "true"},
 		{"long formatted value", "verylongvaluethatexceedsfifteencharacters", `"verylongva"...`},
+		{"long multibyte string", "user名前と名前", `"user名前"...`},
 	}
 
 	for _, tt := range tests {
@@ -235,6 +238,21 @@ func TestVisualFormatter_FormatValueCompact(t *testing.T) {
 	}
 }
 
+func TestFormatStructCompact_Tags(t *testing.T) {
+	type packet struct {
+		Cache    map[string]int `diag:"-"`
+		Flags    uint8          `diag:"hex"`
+		Payload  string         `diag:"short"`
+		Checksum int
+	}
+
+	got := formatStructCompact(packet{Cache: map[string]int{"a": 1}, Flags: 0x1f, Payload: "abcdefghijkl", Checksum: 7})
+	want := `{Flags:0x1f,Payload:"abcdefgh"...,...}`
+	if got != want {
+		t.Errorf("formatStructCompact() = %q, want %q", got, want)
+	}
+}
+
 func TestVisualFormatter_MachineReadableSection(t *testing.T) {
 	// Test with machine-readable enabled
 	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
@@ -290,19 +308,19 @@ func TestVisualFormatter_Issue10_Alignment(t *testing.T) {
 			"expected": 0,
 		},
 		Tree: &evaluator.EvaluationTree{
-			ID:       1,
+			ID:       "1",
 			Type:     "comparison",
 			Operator: "==",
 			Text:     "result == expected",
 			Result:   false,
 			Left: &evaluator.EvaluationTree{
-				ID:    2,
+				ID:    "2",
 				Type:  "identifier",
 				Text:  "result",
 				Value: 3,
 			},
 			Right: &evaluator.EvaluationTree{
-				ID:    3,
+				ID:    "3",
 				Type:  "identifier",
 				Text:  "expected",
 				Value: 0,
@@ -347,3 +365,748 @@ func TestVisualFormatter_Issue10_Alignment(t *testing.T) {
 	t.Logf("Value line: %q", valueLine)
 	t.Logf("Full output:\n%s", output)
 }
+
+func TestVisualFormatter_DuplicateIdentifierPositions(t *testing.T) {
+	formatter := NewVisualFormatter()
+
+	// "b" and "a" both appear twice; each occurrence must get its own pipe
+	expr := "a < b && b < a"
+	tree := &evaluator.EvaluationTree{
+		Type:     "logical",
+		Operator: "&&",
+		Text:     expr,
+		Left: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: "<",
+			Text:     "a < b",
+			Result:   true,
+			Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "a", Value: 1},
+			Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "b", Value: 2},
+		},
+		Right: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: "<",
+			Text:     "b < a",
+			Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "b", Value: 2},
+			Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "a", Value: 1},
+		},
+	}
+
+	mapper := formatter.createPositionMapper(expr)
+	positions := formatter.extractAllPositionsWithAST(tree, expr, mapper)
+
+	got := make(map[string][]int)
+	for _, pos := range positions {
+		got[pos.Expression] = append(got[pos.Expression], pos.VisualPos)
+	}
+
+	expected := map[string][]int{
+		"a":  {0, 13},
+		"b":  {4, 9},
+		"<":  {2, 11},
+		"&&": {6},
+	}
+	for name, want := range expected {
+		if len(got[name]) != len(want) {
+			t.Errorf("positions for %q = %v, want %v", name, got[name], want)
+			continue
+		}
+		for i := range want {
+			if got[name][i] != want[i] {
+				t.Errorf("positions for %q = %v, want %v", name, got[name], want)
+				break
+			}
+		}
+	}
+}
+
+func TestVisualFormatter_MultibyteIdentifierPositions(t *testing.T) {
+	formatter := NewVisualFormatter()
+
+	// 名前 is a substring of user名前, and wide characters take two columns each
+	tests := []struct {
+		expr     string
+		left     string
+		right    string
+		expected map[string]int
+	}{
+		{"user名前 == 名前", "user名前", "名前", map[string]int{"user名前": 0, "==": 9, "名前": 12}},
+		{"名前 == user名前", "名前", "user名前", map[string]int{"名前": 0, "==": 5, "user名前": 8}},
+		{"名前名前 != 名前", "名前名前", "名前", map[string]int{"名前名前": 0, "!=": 9, "名前": 12}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			tree := &evaluator.EvaluationTree{
+				Type:     "comparison",
+				Operator: tt.expr[len(tt.left)+1 : len(tt.left)+3],
+				Text:     tt.expr,
+				Left:     &evaluator.EvaluationTree{Type: "identifier", Text: tt.left, Value: 1},
+				Right:    &evaluator.EvaluationTree{Type: "identifier", Text: tt.right, Value: 2},
+			}
+
+			mapper := formatter.createPositionMapper(tt.expr)
+			positions := formatter.extractAllPositionsWithAST(tree, tt.expr, mapper)
+
+			got := make(map[string]int)
+			for _, pos := range positions {
+				got[pos.Expression] = pos.VisualPos
+			}
+			for name, want := range tt.expected {
+				if pos, ok := got[name]; !ok || pos != want {
+					t.Errorf("position of %q = %d (found %v), want %d", name, pos, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVisualFormatter_SameIdentifierOnBothSides(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "x > (x)",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: ">",
+			Text:     "x > x",
+			Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "x", Value: 7},
+			Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "x", Value: 7},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	// Both occurrences of x are rendered, the second one inside the parentheses
+	if !strings.Contains(output, "         7    7") {
+		t.Errorf("Expected both x values to be rendered under each occurrence.\nOutput:\n%s", output)
+	}
+}
+
+func TestVisualFormatter_LiteralNotationPreserved(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "mode == 0o755",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: "==",
+			Text:     "mode == 0o755",
+			Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "mode", Value: 420},
+			Right:    &evaluator.EvaluationTree{Type: "literal", Text: "0o755", Value: 493},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := []string{
+		"420     0o755",
+		"Step 2: `0o755` => 0o755",
+		"with 420 == 0o755 => false",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "493") {
+		t.Errorf("Literal should be shown in its original notation, not as decimal.\nOutput:\n%s", output)
+	}
+}
+
+func TestVisualFormatter_ArithmeticValues(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "a + b == 31",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: "==",
+			Text:     "a + b == 31",
+			Left: &evaluator.EvaluationTree{
+				Type:     "binary",
+				Operator: "+",
+				Text:     "a + b",
+				Value:    30,
+				Result:   true,
+				Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "a", Value: 10},
+				Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "b", Value: 20},
+			},
+			Right: &evaluator.EvaluationTree{Type: "literal", Text: "31", Value: 31},
+		},
+	}
+
+	mapper := formatter.createPositionMapper(result.Expression)
+	positions := formatter.extractAllPositionsWithAST(result.Tree, result.Expression, mapper)
+
+	found := false
+	for _, pos := range positions {
+		if pos.Expression == "+" {
+			found = true
+			if pos.Value != "30" || pos.VisualPos != 2 {
+				t.Errorf("Expected 30 under '+' at column 2, got %q at %d", pos.Value, pos.VisualPos)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected a value position for the '+' operator")
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+	if !strings.Contains(output, "`a + b` with 10 + 20 => 30") {
+		t.Errorf("Expected arithmetic evaluation step.\nOutput:\n%s", output)
+	}
+}
+
+func TestVisualFormatter_AdjacentValuesDoNotMerge(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	formatter := NewVisualFormatter()
+
+	positions := []ValuePosition{
+		{Expression: "a", Value: "10", VisualPos: 0, Priority: 20},
+		{Expression: "b", Value: "20", VisualPos: 2, Priority: 20},
+	}
+
+	assignment := formatter.assignVisualLayers(positions)
+	if len(assignment.Layers) != 2 {
+		t.Errorf("Expected adjacent values to be split across 2 layers, got %d", len(assignment.Layers))
+	}
+}
+
+func TestVisualFormatter_CharValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *evaluator.EvaluationTree
+		expected string
+	}{
+		{
+			name:     "rune literal",
+			node:     &evaluator.EvaluationTree{Type: "literal", Text: "'a'", Value: 'a'},
+			expected: "97 ('a')",
+		},
+		{
+			name:     "escaped rune literal",
+			node:     &evaluator.EvaluationTree{Type: "literal", Text: `'\n'`, Value: '\n'},
+			expected: `10 ('\n')`,
+		},
+		{
+			name:     "multibyte rune literal",
+			node:     &evaluator.EvaluationTree{Type: "literal", Text: "'é'", Value: 'é'},
+			expected: "233 ('é')",
+		},
+		{
+			name: "string index",
+			node: &evaluator.EvaluationTree{
+				Type:  "index",
+				Text:  "s[0]",
+				Value: byte('h'),
+				Left:  &evaluator.EvaluationTree{Type: "identifier", Text: "s", Value: "hello"},
+			},
+			expected: "104 ('h')",
+		},
+		{
+			name: "non-ASCII byte",
+			node: &evaluator.EvaluationTree{
+				Type:  "index",
+				Text:  "s[0]",
+				Value: byte(0xc3),
+				Left:  &evaluator.EvaluationTree{Type: "identifier", Text: "s", Value: "é"},
+			},
+			expected: `195 ('\xc3')`,
+		},
+		{
+			name: "byte slice index stays numeric",
+			node: &evaluator.EvaluationTree{
+				Type:  "index",
+				Text:  "b[0]",
+				Value: byte(7),
+				Left:  &evaluator.EvaluationTree{Type: "identifier", Text: "b", Value: []byte{7}},
+			},
+			expected: "7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatNodeCompact(tt.node); got != tt.expected {
+				t.Errorf("formatNodeCompact() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVisualFormatter_CRLFAndBOMExpression(t *testing.T) {
+	formatter := NewVisualFormatter()
+
+	tree := &evaluator.EvaluationTree{
+		Type:     "logical",
+		Operator: "&&",
+		Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "a", Value: true},
+		Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "b", Value: false},
+	}
+
+	for _, expr := range []string{"a &&\r\nb", "\uFEFFa &&\nb"} {
+		mapper := formatter.createPositionMapper(expr)
+		if mapper.expr != "a &&\nb" {
+			t.Errorf("createPositionMapper(%q).expr = %q, expected normalized expression", expr, mapper.expr)
+		}
+
+		positions := formatter.extractAllPositionsWithAST(tree, expr, mapper)
+		for _, pos := range positions {
+			if pos.Expression == "b" && pos.StartPos != 5 {
+				t.Errorf("Expected b at byte offset 5 in %q, got %d", expr, pos.StartPos)
+			}
+			if pos.Expression == "a" && pos.StartPos != 0 {
+				t.Errorf("Expected a at byte offset 0 in %q, got %d", expr, pos.StartPos)
+			}
+		}
+	}
+}
+
+func TestVisualFormatter_ShortCircuit(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	// a && b && c with a == false: b and c are never evaluated
+	result := &evaluator.ExpressionResult{
+		Expression: "a && b && c",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "logical",
+			Operator: "&&",
+			Text:     "a && b && c",
+			Left: &evaluator.EvaluationTree{
+				Type:     "logical",
+				Operator: "&&",
+				Text:     "a && b",
+				Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "a", Value: false},
+				Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "b", Value: true, Result: true, NotEvaluated: true},
+			},
+			Right: &evaluator.EvaluationTree{Type: "identifier", Text: "c", Value: true, Result: true, NotEvaluated: true},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := []string{
+		"(not evaluated)",
+		"`b` => (not evaluated)",
+		"`a && b` with false && (not evaluated) => false",
+		"SHORT_CIRCUIT: `a` => false (not evaluated: `b`, `c`)",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+
+	// Values of skipped operands must not be shown
+	if strings.Contains(output, "true") {
+		t.Errorf("Skipped operands should not show their values.\nOutput:\n%s", output)
+	}
+}
+
+func TestVisualFormatter_NoShortCircuitSection(t *testing.T) {
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "a && b",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "logical",
+			Operator: "&&",
+			Text:     "a && b",
+			Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "a", Value: true, Result: true},
+			Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "b", Value: false},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+	if strings.Contains(output, "SHORT_CIRCUIT") || strings.Contains(output, notEvaluatedMarker) {
+		t.Errorf("Did not expect short-circuit diagnostics.\nOutput:\n%s", output)
+	}
+}
+
+func TestColorizeValue_NotEvaluatedIsDimmed(t *testing.T) {
+	formatter := NewVisualFormatter()
+	formatter.colorConfig.ColorsEnabled = true
+	originalNoColor := ansi.NoColor
+	ansi.NoColor = false
+	defer func() { ansi.NoColor = originalNoColor }()
+
+	result := formatter.colorizeValue(notEvaluatedMarker, false)
+	if !strings.Contains(result, "\x1b[2m") {
+		t.Errorf("Expected dim escape sequence for skipped branch, got %q", result)
+	}
+}
+
+func TestVisualFormatter_LikelyCause(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	user := struct{ Age int }{Age: 16}
+	result := &evaluator.ExpressionResult{
+		Expression: "user.Age >= 18",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: ">=",
+			Text:     "user.Age >= 18",
+			Left: &evaluator.EvaluationTree{
+				Type:  "selector",
+				Text:  "user.Age",
+				Value: 16,
+				Left:  &evaluator.EvaluationTree{Type: "identifier", Text: "user", Value: user},
+			},
+			Right: &evaluator.EvaluationTree{Type: "literal", Text: "18", Value: 18},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := []string{
+		"LIKELY CAUSE: user.Age >= 18 is false because user.Age = 16",
+		"FAILURE_REASON: user.Age >= 18 is false because user.Age = 16",
+		"FAILING_NODE: user.Age >= 18",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+}
+
+func TestVisualFormatter_NoLikelyCauseForUnknownValues(t *testing.T) {
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "x > 20",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: ">",
+			Text:     "x > 20",
+			Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "x", Value: "<x>"},
+			Right:    &evaluator.EvaluationTree{Type: "literal", Text: "20", Value: 20},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+	if strings.Contains(output, "LIKELY CAUSE") || strings.Contains(output, "FAILURE_REASON") {
+		t.Errorf("Did not expect a likely cause for placeholder values.\nOutput:\n%s", output)
+	}
+}
+
+func TestDescribeFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *evaluator.EvaluationTree
+		expected string
+	}{
+		{
+			name: "both operands from the test",
+			node: &evaluator.EvaluationTree{
+				Type:     "comparison",
+				Operator: "==",
+				Text:     "x == y",
+				Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "x", Value: 5},
+				Right:    &evaluator.EvaluationTree{Type: "identifier", Text: "y", Value: 10},
+			},
+			expected: "x == y is false because x = 5, y = 10",
+		},
+		{
+			name: "negation",
+			node: &evaluator.EvaluationTree{
+				Type:     "unary",
+				Operator: "!",
+				Text:     "!done",
+				Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "done", Value: true, Result: true},
+			},
+			expected: "!done is false because done = true",
+		},
+		{
+			name:     "boolean leaf",
+			node:     &evaluator.EvaluationTree{Type: "identifier", Text: "hasLicense", Value: false},
+			expected: "hasLicense is false",
+		},
+		{
+			name: "static types the values do not reveal",
+			node: &evaluator.EvaluationTree{
+				Type:     "comparison",
+				Operator: "<",
+				Text:     "n < timeout",
+				Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "n", Value: 3, StaticType: "int"},
+				Right:    &evaluator.EvaluationTree{Type: "selector", Text: "time.Second", Value: int64(1000000000), StaticType: "time.Duration"},
+			},
+			expected: "n < timeout is false because n = 3, time.Second = 1000000000 (time.Duration)",
+		},
+		{
+			name: "composite values told apart by their differences",
+			node: &evaluator.EvaluationTree{
+				Type:        "comparison",
+				Operator:    "==",
+				Text:        "got == want",
+				Left:        &evaluator.EvaluationTree{Type: "identifier", Text: "got", Value: struct{ A, B int }{1, 2}},
+				Right:       &evaluator.EvaluationTree{Type: "identifier", Text: "want", Value: struct{ A, B int }{3, 4}},
+				Differences: []string{".A: 1 != 3", `.Labels["a: b"]: "x" != "y"`, "..."},
+			},
+			expected: `got == want is false because they differ at .A, .Labels["a: b"], ...`,
+		},
+		{
+			name: "differences of strings",
+			node: &evaluator.EvaluationTree{
+				Type:        "comparison",
+				Operator:    "==",
+				Text:        "s == t",
+				Left:        &evaluator.EvaluationTree{Type: "identifier", Text: "s", Value: "ab"},
+				Right:       &evaluator.EvaluationTree{Type: "identifier", Text: "t", Value: "ac"},
+				Differences: []string{"first difference at rune 1 (common prefix 1, common suffix 0)"},
+			},
+			expected: "s == t is false because s = ab, t = ac",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeFailure(tt.node); got != tt.expected {
+				t.Errorf("describeFailure() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVisualFormatter_Notes(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "cfg.DB.Host != \"\"",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: "!=",
+			Text:     "cfg.DB.Host != \"\"",
+			Left: &evaluator.EvaluationTree{
+				Type: "selector",
+				Text: "cfg.DB.Host",
+				Note: "cfg.DB is nil — cannot access Host",
+				Left: &evaluator.EvaluationTree{Type: "selector", Text: "cfg.DB"},
+			},
+			Right: &evaluator.EvaluationTree{Type: "literal", Text: "\"\"", Value: ""},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := []string{
+		"NOTES:\n  - cfg.DB is nil — cannot access Host",
+		"NOTE: cfg.DB is nil — cannot access Host",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+}
+
+func TestVisualFormatter_ReturnedErrors(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "p.Parse(s) == nil",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "comparison",
+			Operator: "==",
+			Text:     "p.Parse(s) == nil",
+			Left: &evaluator.EvaluationTree{
+				Type:  "method_call",
+				Text:  "p.Parse(s)",
+				Value: evaluator.Results{0, errors.New("invalid syntax")},
+				Err:   "invalid syntax",
+			},
+			Right: &evaluator.EvaluationTree{Type: "identifier", Text: "nil"},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := []string{
+		"RETURNED ERRORS:\n  - p.Parse(s): invalid syntax",
+		"ERROR: p.Parse(s): invalid syntax",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+}
+
+func TestVisualFormatter_Owners(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	result := evaluator.EvaluateWithValues("x > 1", false, 0, map[string]interface{}{"x": 0})
+	ctx := &AssertionContext{Owners: []string{"@acme/payments", "ops@example.com"}}
+
+	output := NewVisualFormatter().FormatVisualWithContext(result, "pay_test.go", 3, "", ctx)
+
+	expected := []string{
+		"\nOWNERS: @acme/payments ops@example.com\n",
+		"\nOWNERS: @acme/payments ops@example.com\nFAILURE_REASON",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+}
+
+func TestVisualFormatter_History(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	result := evaluator.EvaluateWithValues("x > 1", false, 0, map[string]interface{}{"x": 0})
+	fingerprint := evaluator.Fingerprint("x > 1", "x > 1", "flaky_test.go", 7)
+
+	var seen string
+	SetHistory(func(fp string) (int, int) {
+		seen = fp
+		return 3, 10
+	})
+	defer SetHistory(nil)
+
+	output := NewVisualFormatter().FormatVisual(result, "flaky_test.go", 7, "")
+
+	if seen != fingerprint {
+		t.Errorf("history looked up %q, want %q", seen, fingerprint)
+	}
+	expected := []string{
+		"ASSERTION FAILED at flaky_test.go:7\nfailed in 3 of the last 10 runs, possibly flaky\n",
+		"FINGERPRINT: " + fingerprint + "\n",
+		"HISTORY: 3/10\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+
+	// A failure in every recent run is not flaky, and a first one has nothing to compare with
+	for _, history := range [][2]int{{10, 10}, {1, 10}, {0, 0}} {
+		history := history
+		SetHistory(func(string) (int, int) { return history[0], history[1] })
+		if output := NewVisualFormatter().FormatVisual(result, "flaky_test.go", 7, ""); strings.Contains(output, "possibly flaky") {
+			t.Errorf("%d of %d runs should not be marked flaky, got:\n%s", history[0], history[1], output)
+		}
+	}
+}
+
+func TestVisualFormatter_Hints(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	result := evaluator.EvaluateWithValues("user.Age >= limit", false, 0,
+		map[string]interface{}{"user": "<user>", "limit": 18})
+
+	output := NewVisualFormatter().FormatVisual(result, "test.go", 1, "")
+	for _, want := range []string{
+		"HINT: pass the values that could not be read to show them in the diagram:\n  diagassert.V(\"user.Age\", user.Age)\n",
+		"HINT: add diagassert.V(\"user.Age\", user.Age)\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+
+	t.Setenv("DIAGASSERT_HINTS", "false")
+	if output := NewVisualFormatter().FormatVisual(result, "test.go", 1, ""); strings.Contains(output, "HINT") {
+		t.Errorf("DIAGASSERT_HINTS=false should suppress hints.\nOutput:\n%s", output)
+	}
+}
+
+func TestVisualFormatter_StaticTypes(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "got == want",
+		Tree: &evaluator.EvaluationTree{
+			Type:       "comparison",
+			Operator:   "==",
+			Text:       "got == want",
+			StaticType: "bool",
+			Left:       &evaluator.EvaluationTree{Type: "identifier", Text: "got", Value: 3, StaticType: "any"},
+			Right:      &evaluator.EvaluationTree{Type: "identifier", Text: "want", Value: 3.0, StaticType: "any"},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := []string{
+		"STATIC_TYPES: got=any,want=any\n",
+		"because got = 3 (any), want = 3 (any)",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+}
+
+func TestVisualFormatter_Differences(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "got == want",
+		Tree: &evaluator.EvaluationTree{
+			Type:        "comparison",
+			Operator:    "==",
+			Text:        "got == want",
+			Left:        &evaluator.EvaluationTree{Type: "identifier", Text: "got"},
+			Right:       &evaluator.EvaluationTree{Type: "identifier", Text: "want"},
+			Differences: []string{".Items[3].Price: 10 != 12"},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := []string{
+		"DIFFERENCES in got == want:\n  .Items[3].Price: 10 != 12",
+		"DIFF: got == want: .Items[3].Price: 10 != 12",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+}
+
+func TestVisualFormatter_JSONExpression(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "false")
+	formatter := NewVisualFormatter()
+
+	doc := map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 41.0}}}
+	result := evaluator.EvaluateJSON("$.items[0].id == 42", doc)
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := []string{
+		"  assert($.items[0].id == 42)\n",
+		"   0  41 false\n",
+		"LIKELY CAUSE: $.items[0].id == 42 is false because $.items[0].id = 41",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q.\nOutput:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "map[items") {
+		t.Errorf("The whole document should not be shown under $.\nOutput:\n%s", output)
+	}
+}
@@ -0,0 +1,136 @@
+package formatter
+
+import (
+	"go/ast"
+	"sort"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/textwidth"
+)
+
+// assertIndent is the width of the "  assert(" prefix that the diagram is aligned to.
+const assertIndent = len("  assert(")
+
+// getMaxWidth returns the width output should fit in: DIAGASSERT_MAX_WIDTH if set, where 0
+// turns wrapping off, otherwise COLUMNS, otherwise the width of the terminal standard output
+// is. Zero disables wrapping, as when output is redirected.
+func getMaxWidth() int {
+	if width, ok := config.Int("DIAGASSERT_MAX_WIDTH"); ok && width >= 0 {
+		return width
+	}
+	if width, ok := config.Int("COLUMNS"); ok && width > 0 {
+		return width
+	}
+	return stdoutWidth()
+}
+
+// exprSegment is one line of a wrapped expression, as byte offsets into the expression.
+type exprSegment struct {
+	start, end int
+}
+
+// wrapExpression splits an expression into segments no wider than width, breaking
+// before spaced binary operators, loosest first. A segment is only allowed to exceed width when it has
+// no operator to break at.
+func (f *VisualFormatter) wrapExpression(expr string, mapper *PositionMapper, width int) []exprSegment {
+	whole := []exprSegment{{0, len(expr)}}
+	if mapper.root == nil || width <= 0 || textwidth.String(expr) <= width {
+		return whole
+	}
+
+	// gofmt only spaces out the loosest-binding operators, so "score+age" is never split
+	type breakPoint struct {
+		offset     int
+		precedence int
+	}
+	var breaks []breakPoint
+	ast.Inspect(mapper.root, func(n ast.Node) bool {
+		if bin, ok := n.(*ast.BinaryExpr); ok {
+			if offset := mapper.fset.Position(bin.OpPos).Offset; offset > 0 && expr[offset-1] == ' ' {
+				breaks = append(breaks, breakPoint{offset, bin.Op.Precedence()})
+			}
+		}
+		return true
+	})
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].offset < breaks[j].offset })
+
+	var segments []exprSegment
+	start := 0
+	for start < len(expr) {
+		startVisual := f.byteToVisualPos(start, mapper.charPositions)
+
+		// Among the breaks that fit, prefer the loosest operator (|| before &&, && before ==)
+		// and then the farthest one; if none fits, take the nearest
+		end := len(expr)
+		if textwidth.String(expr[start:]) > width {
+			end = -1
+			bestPrecedence := 0
+			for _, b := range breaks {
+				if b.offset <= start {
+					continue
+				}
+				if f.byteToVisualPos(b.offset, mapper.charPositions)-startVisual > width {
+					if end == -1 {
+						end = b.offset
+					}
+					break
+				}
+				if end == -1 || b.precedence <= bestPrecedence {
+					end, bestPrecedence = b.offset, b.precedence
+				}
+			}
+			if end == -1 {
+				end = len(expr)
+			}
+		}
+
+		segments = append(segments, exprSegment{start, end})
+		start = end
+	}
+	return segments
+}
+
+// formatWrappedAssertStyle renders each segment of a wrapped expression followed by the
+// part of the diagram that belongs to it. Pipes keep their columns relative to the
+// segment, so alignment survives the wrap.
+func (f *VisualFormatter) formatWrappedAssertStyle(expr string, segments []exprSegment, positions []ValuePosition, mapper *PositionMapper) string {
+	var b strings.Builder
+	indent := strings.Repeat(" ", assertIndent)
+
+	for i, segment := range segments {
+		text := strings.TrimRight(expr[segment.start:segment.end], " ")
+		shown := strings.TrimRight(mapper.display(segment.start, segment.end), " ")
+		if i == 0 {
+			b.WriteString("  assert(" + shown)
+		} else {
+			b.WriteString(indent + shown)
+		}
+		if i == len(segments)-1 {
+			b.WriteString(")")
+		}
+		b.WriteString("\n")
+
+		startVisual := f.byteToVisualPos(segment.start, mapper.charPositions)
+		endVisual := f.byteToVisualPos(segment.end, mapper.charPositions)
+		if segment.end == len(expr) {
+			endVisual = textwidth.String(expr) + 1
+		}
+
+		var local []ValuePosition
+		for _, pos := range positions {
+			if pos.VisualPos >= startVisual && pos.VisualPos < endVisual {
+				pos.VisualPos -= startVisual
+				pos.VisualEnd -= startVisual
+				local = append(local, pos)
+			}
+		}
+		if len(local) == 0 {
+			continue
+		}
+
+		f.writePowerAssertTree(&b, indent, text, local)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,134 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+func TestGetMaxWidth(t *testing.T) {
+	original := stdoutWidth
+	defer func() { stdoutWidth = original }()
+
+	tests := []struct {
+		name     string
+		maxWidth string
+		columns  string
+		terminal int
+		expected int
+	}{
+		{name: "unset", expected: 0},
+		{name: "columns", columns: "80", expected: 80},
+		{name: "explicit width wins", maxWidth: "60", columns: "80", expected: 60},
+		{name: "invalid width falls back", maxWidth: "wide", columns: "80", expected: 80},
+		{name: "terminal", terminal: 120, expected: 120},
+		{name: "columns wins over the terminal", columns: "80", terminal: 120, expected: 80},
+		{name: "explicit zero turns wrapping off", maxWidth: "0", columns: "80", terminal: 120, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DIAGASSERT_MAX_WIDTH", tt.maxWidth)
+			t.Setenv("COLUMNS", tt.columns)
+			stdoutWidth = func() int { return tt.terminal }
+			if got := getMaxWidth(); got != tt.expected {
+				t.Errorf("getMaxWidth() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWrapExpression(t *testing.T) {
+	formatter := NewVisualFormatter()
+
+	tests := []struct {
+		name     string
+		expr     string
+		width    int
+		expected []string
+	}{
+		{
+			name:     "fits",
+			expr:     "x > 1 && y > 2",
+			width:    40,
+			expected: []string{"x > 1 && y > 2"},
+		},
+		{
+			name:     "breaks before operators",
+			expr:     "age >= 18 && score > 50 && name == \"alice\"",
+			width:    25,
+			expected: []string{"age >= 18 && score > 50 ", "&& name == \"alice\""},
+		},
+		{
+			name:     "tight operators stay together",
+			expr:     "a+b+c+d+e+f+g+h+i+j > limit",
+			width:    10,
+			expected: []string{"a+b+c+d+e+f+g+h+i+j ", "> limit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := formatter.createPositionMapper(tt.expr)
+			var got []string
+			for _, segment := range formatter.wrapExpression(tt.expr, mapper, tt.width) {
+				got = append(got, tt.expr[segment.start:segment.end])
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("wrapExpression() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVisualFormatter_WrappedExpression(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "false")
+	t.Setenv("DIAGASSERT_MAX_WIDTH", "30")
+	formatter := NewVisualFormatter()
+
+	result := &evaluator.ExpressionResult{
+		Expression: "count > 10 && total == 200",
+		Tree: &evaluator.EvaluationTree{
+			Type:     "logical",
+			Operator: "&&",
+			Text:     "count > 10 && total == 200",
+			Left: &evaluator.EvaluationTree{
+				Type:     "comparison",
+				Operator: ">",
+				Text:     "count > 10",
+				Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "count", Value: 5},
+				Right:    &evaluator.EvaluationTree{Type: "literal", Text: "10", Value: 10},
+			},
+			Right: &evaluator.EvaluationTree{
+				Type:     "comparison",
+				Operator: "==",
+				Text:     "total == 200",
+				Left:     &evaluator.EvaluationTree{Type: "identifier", Text: "total", Value: 200},
+				Right:    &evaluator.EvaluationTree{Type: "literal", Text: "200", Value: 200},
+				Result:   true,
+			},
+		},
+	}
+
+	output := formatter.FormatVisual(result, "test.go", 1, "")
+
+	expected := strings.Join([]string{
+		"  assert(count > 10",
+		"         |     | |",
+		"         5       10",
+		"         ",
+		"               |",
+		"               false",
+		"         && total == 200)",
+		"         |  |     |  |",
+		"            200      200",
+		"         ",
+		"         |        |",
+		"         false    true",
+	}, "\n")
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected wrapped diagram:\n%s\nOutput:\n%s", expected, output)
+	}
+}
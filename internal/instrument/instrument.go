@@ -0,0 +1,336 @@
+// Package instrument rewrites diagassert.Assert and Require calls so that the test records
+// the value of every sub-expression as it evaluates it. It is shared by diagassert-gen, which
+// writes instrumented copies of test files, and the toolexec mode of the diagassert command,
+// which instruments them while they are compiled.
+package instrument
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImportPath is the import path of the package whose calls are instrumented.
+const ImportPath = "github.com/paveg/diagassert"
+
+// captureParam names the Capture inside the generated closures. It is unlikely to shadow
+// anything the asserted expression refers to.
+const captureParam = "diagassertCapture"
+
+// TypeCheck type-checks files, grouped by package clause so that an external test package
+// is checked separately from the package under test. Type errors are tolerated: an
+// expression without type information is left as written rather than instrumented.
+func TypeCheck(fset *token.FileSet, files []*ast.File, importer types.Importer) *types.Info {
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+
+	groups := make(map[string][]*ast.File)
+	var order []string
+	for _, file := range files {
+		if groups[file.Name.Name] == nil {
+			order = append(order, file.Name.Name)
+		}
+		groups[file.Name.Name] = append(groups[file.Name.Name], file)
+	}
+
+	conf := types.Config{
+		// The calls are recognized by name, so diagassert itself need not be checked
+		Importer: stubImporter{importer, types.NewPackage(ImportPath, "diagassert")},
+		Error:    func(error) {},
+	}
+	for _, name := range order {
+		conf.Check(name, fset, groups[name], info)
+	}
+	return info
+}
+
+type stubImporter struct {
+	types.Importer
+	stub *types.Package
+}
+
+func (s stubImporter) Import(path string) (*types.Package, error) {
+	if path == ImportPath {
+		return s.stub, nil
+	}
+	return s.Importer.Import(path)
+}
+
+// Rewrite returns src with every assertion in file instrumented, and whether there was any.
+// Every line keeps its number. file must have been parsed from src with fset, and info is
+// the result of TypeCheck.
+func Rewrite(fset *token.FileSet, file *ast.File, info *types.Info, src []byte) ([]byte, bool) {
+	r := &rewriter{fset: fset, info: info, src: src, pkg: importName(file)}
+	if r.pkg == "" {
+		return src, false
+	}
+	ast.Inspect(file, r.visitCall)
+	if len(r.edits) == 0 {
+		return src, false
+	}
+	return r.apply(), true
+}
+
+// importName returns the name under which a file imports diagassert, or "" if it does not.
+// Dot and blank imports are not instrumented.
+func importName(file *ast.File) string {
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err != nil || path != ImportPath {
+			continue
+		}
+		if spec.Name == nil {
+			return "diagassert"
+		}
+		if spec.Name.Name != "." && spec.Name.Name != "_" {
+			return spec.Name.Name
+		}
+	}
+	return ""
+}
+
+// edit replaces src[start:end] with text. Edits at the same offset are applied in
+// ascending order of rank, which keeps the wrappers nested: every closing comes before any
+// opening, inner closings come first and outer openings come first.
+type edit struct {
+	start, end int
+	rank       int
+	text       string
+}
+
+// closeRank is the rank of the outermost closing; deeper closings rank below it.
+const closeRank = -1 << 10
+
+// rewriter collects the edits that instrument the assertions of one file.
+type rewriter struct {
+	fset  *token.FileSet
+	info  *types.Info
+	src   []byte
+	pkg   string
+	edits []edit
+
+	constants []string // Named constants of the assertion being rewritten
+}
+
+func (r *rewriter) offset(pos token.Pos) int {
+	return r.fset.Position(pos).Offset
+}
+
+// visitCall rewrites
+//
+//	diagassert.Assert(t, x > limit(), "msg")
+//
+// to
+//
+//	diagassert.AssertCaptured(t, "x > limit()", func(c *diagassert.Capture) bool { return diagassert.Cap(c, "x > limit()", ...) }, "msg")
+//
+// keeping the call on the lines it occupied, so that line numbers stay valid.
+func (r *rewriter) visitCall(node ast.Node) bool {
+	call, ok := node.(*ast.CallExpr)
+	if !ok || len(call.Args) < 2 {
+		return true
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || (sel.Sel.Name != "Assert" && sel.Sel.Name != "Require") {
+		return true
+	}
+	if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != r.pkg {
+		return true
+	}
+
+	expr := call.Args[1]
+	start, end := r.offset(expr.Pos()), r.offset(expr.End())
+	text := string(r.src[start:end])
+
+	r.constants = nil
+	r.visit(expr, 0, true)
+
+	// Named constants cannot be wrapped without fixing their type, so they are recorded
+	// before the expression is evaluated
+	var prelude strings.Builder
+	for _, name := range r.constants {
+		fmt.Fprintf(&prelude, "%s.Cap(%s, %s, %s); ", r.pkg, captureParam, strconv.Quote(name), name)
+	}
+
+	r.edits = append(r.edits,
+		edit{start: r.offset(sel.Sel.End()), end: r.offset(sel.Sel.End()), text: "Captured"},
+		edit{start: start, end: start, rank: -1, text: fmt.Sprintf("%s, func(%s *%s.Capture) bool { %sreturn ",
+			strconv.Quote(text), captureParam, r.pkg, prelude.String())},
+		edit{start: end, end: end, rank: closeRank + 1, text: " }"})
+
+	// Assertions nested in the expression, e.g. in a function literal, are left alone
+	return false
+}
+
+// visit wraps the sub-expressions of an asserted expression in Cap calls. A node is wrapped
+// only when capturable is set and wrapping cannot change what the expression means: the
+// operand of &, the receiver of a pointer method and the operand of a slice of an array
+// must stay addressable, and constants keep their untyped flexibility.
+func (r *rewriter) visit(expr ast.Expr, depth int, capturable bool) {
+	switch n := expr.(type) {
+	case *ast.ParenExpr:
+		r.visit(n.X, depth, capturable)
+		return
+	case *ast.BinaryExpr:
+		r.visit(n.X, depth+1, true)
+		r.visit(n.Y, depth+1, true)
+		if (n.Op == token.SHL || n.Op == token.SHR) && r.isConstant(n.X) {
+			// The type of 1 << n comes from its context, which a Cap call would hide
+			return
+		}
+	case *ast.UnaryExpr:
+		r.visit(n.X, depth+1, n.Op != token.AND)
+	case *ast.StarExpr:
+		r.visit(n.X, depth+1, true)
+	case *ast.SelectorExpr:
+		if selection := r.info.Selections[n]; selection != nil {
+			r.visit(n.X, depth+1, !needsAddressable(selection))
+		}
+	case *ast.CallExpr:
+		switch fun := n.Fun.(type) {
+		case *ast.SelectorExpr:
+			if selection := r.info.Selections[fun]; selection != nil {
+				r.visit(fun.X, depth+1, !needsAddressable(selection))
+			}
+		case *ast.ParenExpr, *ast.CallExpr, *ast.IndexExpr:
+			r.visit(fun, depth+1, false)
+		}
+		for _, arg := range n.Args {
+			r.visit(arg, depth+1, true)
+		}
+	case *ast.IndexExpr:
+		if _, generic := r.typeOf(n.X).(*types.Signature); generic {
+			return
+		}
+		r.visit(n.X, depth+1, true)
+		r.visit(n.Index, depth+1, true)
+	case *ast.SliceExpr:
+		_, array := underlying(r.typeOf(n.X)).(*types.Array)
+		r.visit(n.X, depth+1, !array)
+		for _, index := range []ast.Expr{n.Low, n.High, n.Max} {
+			if index != nil {
+				r.visit(index, depth+1, true)
+			}
+		}
+	case *ast.TypeAssertExpr:
+		r.visit(n.X, depth+1, true)
+	case *ast.CompositeLit, *ast.FuncLit, *ast.KeyValueExpr, *ast.IndexListExpr:
+		// Literals are shown as written, and their keys and bodies are not expressions to capture
+		return
+	}
+
+	if r.isNamedConstant(expr) {
+		r.constants = append(r.constants, types.ExprString(expr))
+		return
+	}
+	if capturable && r.capturable(expr) {
+		start, end := r.offset(expr.Pos()), r.offset(expr.End())
+		r.edits = append(r.edits,
+			edit{start: start, end: start, rank: depth, text: fmt.Sprintf("%s.Cap(%s, %s, ",
+				r.pkg, captureParam, strconv.Quote(types.ExprString(expr)))},
+			edit{start: end, end: end, rank: closeRank - depth, text: ")"})
+	}
+}
+
+// capturable reports whether the type checker found expr to be a single, non-constant value.
+func (r *rewriter) capturable(expr ast.Expr) bool {
+	tv, ok := r.info.Types[expr]
+	if !ok || !tv.IsValue() || tv.Value != nil || tv.IsNil() || tv.Type == types.Typ[types.Invalid] {
+		return false
+	}
+	if _, tuple := tv.Type.(*types.Tuple); tuple {
+		return false
+	}
+	if basic, ok := tv.Type.(*types.Basic); ok && basic.Info()&types.IsUntyped != 0 && basic.Kind() != types.UntypedBool {
+		return false
+	}
+	return true
+}
+
+// isNamedConstant reports whether expr names a declared constant, such as limit or
+// http.StatusOK, whose value can be recorded with its default type.
+func (r *rewriter) isNamedConstant(expr ast.Expr) bool {
+	var ident *ast.Ident
+	switch n := expr.(type) {
+	case *ast.Ident:
+		ident = n
+	case *ast.SelectorExpr:
+		if r.info.Selections[n] == nil {
+			ident = n.Sel
+		}
+	}
+	if ident == nil {
+		return false
+	}
+	if c, ok := r.info.Uses[ident].(*types.Const); !ok || c.Pkg() == nil {
+		return false
+	}
+
+	// An untyped constant that overflows int64 has no default type to record it in
+	tv := r.info.Types[expr]
+	if basic, ok := tv.Type.(*types.Basic); ok && basic.Info()&types.IsInteger != 0 {
+		if basic.Info()&types.IsUntyped != 0 || basic.Kind() == types.UntypedRune {
+			_, exact := constant.Int64Val(tv.Value)
+			return exact
+		}
+	}
+	return true
+}
+
+func (r *rewriter) isConstant(expr ast.Expr) bool {
+	tv, ok := r.info.Types[expr]
+	return ok && tv.Value != nil
+}
+
+func (r *rewriter) typeOf(expr ast.Expr) types.Type {
+	return r.info.Types[expr].Type
+}
+
+func underlying(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
+
+// needsAddressable reports whether a selection calls a pointer method on a value, which Go
+// only allows when the value is addressable.
+func needsAddressable(selection *types.Selection) bool {
+	if selection.Kind() == types.FieldVal {
+		return false
+	}
+	sig, ok := selection.Obj().Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return false
+	}
+	_, pointerRecv := sig.Recv().Type().(*types.Pointer)
+	_, pointerValue := underlying(selection.Recv()).(*types.Pointer)
+	return pointerRecv && !pointerValue
+}
+
+// apply returns the source with every edit made.
+func (r *rewriter) apply() []byte {
+	edits := append([]edit(nil), r.edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].rank < edits[j].rank
+	})
+
+	var out []byte
+	last := 0
+	for _, e := range edits {
+		out = append(out, r.src[last:e.start]...)
+		out = append(out, e.text...)
+		last = e.end
+	}
+	return append(out, r.src[last:]...)
+}
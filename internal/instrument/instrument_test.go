@@ -0,0 +1,116 @@
+package instrument
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// rewrite instruments a test file whose package declares the given source.
+func rewrite(t *testing.T, decls, body string) string {
+	t.Helper()
+	src := "package sample\n\nimport (\n\t\"strings\"\n\t\"testing\"\n\n\tda \"github.com/paveg/diagassert\"\n)\n\n" +
+		decls + "\n\nfunc TestSample(t *testing.T) {\n" + body + "\n}\n\nvar _ = strings.Contains\n"
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "sample_test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := TypeCheck(fset, []*ast.File{file}, importer.Default())
+
+	out, ok := Rewrite(fset, file, info, []byte(src))
+	if !ok {
+		t.Fatalf("Nothing was instrumented in:\n%s", src)
+	}
+	if strings.Count(string(out), "\n") != strings.Count(src, "\n") {
+		t.Errorf("Rewriting should keep every line, got:\n%s", out)
+	}
+	return string(out)
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		decls      string
+		body       string
+		contains   []string
+		uncaptured []string
+	}{
+		{
+			name:  "operands and results",
+			decls: "func limit() int { return 3 }",
+			body:  "\tx := 2\n\tda.Assert(t, x > limit(), \"msg\")",
+			contains: []string{
+				`da.AssertCaptured(t, "x > limit()", func(diagassertCapture *da.Capture) bool { return `,
+				`da.Cap(diagassertCapture, "x > limit()", da.Cap(diagassertCapture, "x", x) > da.Cap(diagassertCapture, "limit()", limit()))`,
+				` }, "msg")`,
+			},
+		},
+		{
+			name:     "package functions are called as written",
+			body:     "\ts := \"go\"\n\tda.Require(t, strings.Contains(s, \"x\"))",
+			contains: []string{`da.RequireCaptured(t, `, `da.Cap(diagassertCapture, "strings.Contains(s, \"x\")", strings.Contains(da.Cap(diagassertCapture, "s", s), "x"))`},
+		},
+		{
+			name:       "pointer methods keep an addressable receiver",
+			decls:      "type counter struct{ n int }\n\nfunc (c *counter) Next() int { c.n++; return c.n }",
+			body:       "\tvar c counter\n\tda.Assert(t, c.Next() == 2)",
+			contains:   []string{`da.Cap(diagassertCapture, "c.Next()", c.Next())`},
+			uncaptured: []string{`"c", c)`},
+		},
+		{
+			name:       "operands of & and slices of arrays stay addressable",
+			body:       "\tarr := [3]int{1, 2, 3}\n\tda.Assert(t, &arr != nil && len(arr[1:]) == 3)",
+			contains:   []string{`&arr != nil`, `len(arr[1:])`},
+			uncaptured: []string{`"arr", arr)`},
+		},
+		{
+			name:     "named constants are recorded before the expression",
+			decls:    "const limit = 3",
+			body:     "\tvar x int64 = 2\n\tda.Assert(t, x > limit)",
+			contains: []string{`bool { da.Cap(diagassertCapture, "limit", limit); return `, `da.Cap(diagassertCapture, "x", x) > limit)`},
+		},
+		{
+			name:       "untyped shifts keep the type of their context",
+			body:       "\tvar x int64 = 2\n\tvar n uint = 1\n\tda.Assert(t, x == 1<<n)",
+			contains:   []string{`== 1<<da.Cap(diagassertCapture, "n", n))`},
+			uncaptured: []string{`"1 << n"`},
+		},
+		{
+			name:     "function literals are left alone",
+			body:     "\tda.Assert(t, func() bool { return true }())",
+			contains: []string{`, func() bool { return true }()) })`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := rewrite(t, tt.decls, tt.body)
+			for _, part := range tt.contains {
+				if !strings.Contains(out, part) {
+					t.Errorf("Output should contain %q, got:\n%s", part, out)
+				}
+			}
+			for _, part := range tt.uncaptured {
+				if strings.Contains(out, part) {
+					t.Errorf("Output should not contain %q, got:\n%s", part, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteWithoutAssertions(t *testing.T) {
+	src := "package sample\n\nimport \"github.com/paveg/diagassert\"\n\nvar _ = diagassert.V\n"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "sample_test.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, ok := Rewrite(fset, file, TypeCheck(fset, []*ast.File{file}, importer.Default()), []byte(src)); ok || string(out) != src {
+		t.Errorf("Files without assertions should be left alone, got:\n%s", out)
+	}
+}
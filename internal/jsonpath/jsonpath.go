@@ -0,0 +1,312 @@
+// Package jsonpath selects values from decoded JSON documents with a small JSONPath subset
+// and reports the paths where two documents differ.
+//
+// Supported syntax: an optional leading "$", ".key" or "key" for object members,
+// ["key"] or ['key'] for members with special characters, brackets and dots included, and
+// [n] for array elements:
+//
+//	$.items[0].id
+//	items[0]["display name"]
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxDifferences caps how many differing paths Diff records.
+const maxDifferences = 5
+
+// Segment is one step of a path: an object member or an array index.
+type Segment struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// String renders the segment as it appears in a canonical path.
+func (s Segment) String() string {
+	if s.IsIndex {
+		return fmt.Sprintf("[%d]", s.Index)
+	}
+	if isPlainKey(s.Key) {
+		return "." + s.Key
+	}
+	return "[" + strconv.Quote(s.Key) + "]"
+}
+
+// Parse splits a path into segments.
+func Parse(path string) ([]Segment, error) {
+	rest := strings.TrimSpace(path)
+	rest = strings.TrimPrefix(rest, "$")
+
+	var segments []Segment
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			key, remaining := splitKey(rest[1:])
+			if key == "" {
+				return nil, fmt.Errorf("jsonpath: empty member name in %q", path)
+			}
+			segments = append(segments, Segment{Key: key})
+			rest = remaining
+
+		case rest[0] == '[':
+			inner := strings.TrimLeft(rest[1:], " ")
+			if strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`) {
+				// The name is scanned up to its closing quote, as it may hold ] itself
+				key, n, err := quotedKey(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath: invalid member name %s in %q", inner, path)
+				}
+				remaining := strings.TrimLeft(inner[n:], " ")
+				if !strings.HasPrefix(remaining, "]") {
+					return nil, fmt.Errorf("jsonpath: unterminated [ in %q", path)
+				}
+				segments = append(segments, Segment{Key: key})
+				rest = remaining[1:]
+				continue
+			}
+
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath: unterminated [ in %q", path)
+			}
+			inner = strings.TrimSpace(rest[1:end])
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("jsonpath: invalid index [%s] in %q", inner, path)
+			}
+			segments = append(segments, Segment{Index: index, IsIndex: true})
+			rest = rest[end+1:]
+
+		case len(segments) == 0:
+			// A leading member may omit the dot: "items[0]"
+			key, remaining := splitKey(rest)
+			segments = append(segments, Segment{Key: key})
+			rest = remaining
+
+		default:
+			return nil, fmt.Errorf("jsonpath: unexpected %q in %q", rest[:1], path)
+		}
+	}
+
+	return segments, nil
+}
+
+// Format renders segments as a canonical path starting with "$".
+func Format(segments []Segment) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, s := range segments {
+		b.WriteString(s.String())
+	}
+	return b.String()
+}
+
+// Select returns the value at path within doc, a document decoded by encoding/json.
+// The error names the first segment that could not be followed.
+func Select(doc interface{}, path string) (interface{}, error) {
+	segments, err := Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := doc
+	for i, s := range segments {
+		at := Format(segments[:i])
+		if s.IsIndex {
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is %s, not an array", at, Kind(current))
+			}
+			if s.Index >= len(arr) {
+				return nil, fmt.Errorf("%s has length %d, no index %d", at, len(arr), s.Index)
+			}
+			current = arr[s.Index]
+			continue
+		}
+
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is %s, not an object", at, Kind(current))
+		}
+		value, exists := obj[s.Key]
+		if !exists {
+			return nil, fmt.Errorf("%s has no member %q", at, s.Key)
+		}
+		current = value
+	}
+
+	return current, nil
+}
+
+// Normalize converts a Go value to the representation encoding/json decodes it to,
+// so that 42 compares equal to the float64 42 found in a document.
+func Normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// Kind names the JSON type of a decoded value.
+func Kind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64, json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// Diff returns up to five differences between two decoded documents, each prefixed with
+// the path below root where it occurs, e.g. "$.items[0].id: 41 != 42". A trailing "..."
+// entry means more differences were found than recorded.
+func Diff(root string, got, want interface{}) []string {
+	segments, err := Parse(root)
+	if err != nil {
+		segments = nil
+	}
+
+	d := &differ{}
+	d.diff(segments, got, want)
+	if d.truncated {
+		d.diffs = append(d.diffs, "...")
+	}
+	return d.diffs
+}
+
+type differ struct {
+	diffs     []string
+	truncated bool
+}
+
+func (d *differ) add(segments []Segment, format string, args ...interface{}) {
+	if len(d.diffs) >= maxDifferences {
+		d.truncated = true
+		return
+	}
+	d.diffs = append(d.diffs, Format(segments)+": "+fmt.Sprintf(format, args...))
+}
+
+func (d *differ) diff(segments []Segment, got, want interface{}) {
+	switch g := got.(type) {
+	case map[string]interface{}:
+		w, ok := want.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(g)+len(w))
+		for k := range g {
+			keys = append(keys, k)
+		}
+		for k := range w {
+			if _, ok := g[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			path := append(segments[:len(segments):len(segments)], Segment{Key: k})
+			gv, inGot := g[k]
+			wv, inWant := w[k]
+			switch {
+			case !inGot:
+				d.add(path, "missing, want %s", FormatValue(wv))
+			case !inWant:
+				d.add(path, "unexpected %s", FormatValue(gv))
+			default:
+				d.diff(path, gv, wv)
+			}
+		}
+		return
+
+	case []interface{}:
+		w, ok := want.([]interface{})
+		if !ok {
+			break
+		}
+		if len(g) != len(w) {
+			d.add(segments, "length %d != %d", len(g), len(w))
+		}
+		for i := 0; i < len(g) && i < len(w); i++ {
+			d.diff(append(segments[:len(segments):len(segments)], Segment{Index: i, IsIndex: true}), g[i], w[i])
+		}
+		return
+	}
+
+	if FormatValue(got) != FormatValue(want) {
+		d.add(segments, "%s != %s", FormatValue(got), FormatValue(want))
+	}
+}
+
+// FormatValue renders a decoded value as compact JSON.
+func FormatValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// splitKey reads a member name up to the next "." or "[".
+func splitKey(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// quotedKey reads the quoted member name s starts with, up to its closing quote, and
+// returns it unquoted with the length of its source. Names in double quotes are Go string
+// literals; in single quotes, \' and \\ stand for a quote and a backslash.
+func quotedKey(s string) (key string, n int, err error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			if quote == '"' {
+				key, err = strconv.Unquote(s[:i+1])
+				return key, i + 1, err
+			}
+			key = strings.NewReplacer(`\'`, "'", `\\`, `\`).Replace(s[1:i])
+			return key, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quote")
+}
+
+// isPlainKey reports whether a member name can be written in dot notation.
+func isPlainKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r == '_' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, doc string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$", "$"},
+		{"$.items[0].id", "$.items[0].id"},
+		{"items[0].id", "$.items[0].id"},
+		{`$["display name"][1]`, `$["display name"][1]`},
+		{"$['a.b'].c", `$["a.b"].c`},
+		{`$["a]b"]`, `$["a]b"]`},
+		{`$[ 'x[0]' ].y`, `$["x[0]"].y`},
+		{`$['it\'s']`, `$["it's"]`},
+		{`$["say \"hi\""]`, `$["say \"hi\""]`},
+	}
+
+	for _, tt := range tests {
+		segments, err := Parse(tt.path)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.path, err)
+			continue
+		}
+		if got := Format(segments); got != tt.want {
+			t.Errorf("Format(Parse(%q)) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	for _, path := range []string{"$.", "$[x]", "$[-1]", "$[0", "$['a]", `$["a]b"`, `$["a"x]`} {
+		if _, err := Parse(path); err == nil {
+			t.Errorf("Parse(%q) should fail", path)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	doc := decode(t, `{"items":[{"id":1,"tags":["a","b"]}],"count":1}`)
+
+	got, err := Select(doc, "$.items[0].tags[1]")
+	if err != nil || got != "b" {
+		t.Errorf("Select = %v, %v; want b", got, err)
+	}
+
+	errors := map[string]string{
+		"$.missing":        `$ has no member "missing"`,
+		"$.items[3]":       "$.items has length 1, no index 3",
+		"$.count.value":    "$.count is a number, not an object",
+		"$.items.id":       "$.items is an array, not an object",
+		"$.items[0].id[0]": "$.items[0].id is a number, not an array",
+	}
+	for path, want := range errors {
+		if _, err := Select(doc, path); err == nil || err.Error() != want {
+			t.Errorf("Select(%q) error = %v, want %q", path, err, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := decode(t, `{"id":1,"tags":["a","b"],"extra":true}`)
+	want, err := Normalize(map[string]interface{}{"id": 2, "tags": []string{"a", "c", "d"}, "name": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := Diff("$.items[0]", got, want)
+	expected := []string{
+		"$.items[0].extra: unexpected true",
+		"$.items[0].id: 1 != 2",
+		`$.items[0].name: missing, want "x"`,
+		"$.items[0].tags: length 2 != 3",
+		`$.items[0].tags[1]: "b" != "c"`,
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("Diff = %q, want %q", diffs, expected)
+	}
+
+	if diffs := Diff("$", decode(t, `[1,2]`), decode(t, `[1,2]`)); len(diffs) != 0 {
+		t.Errorf("Equal documents should have no differences, got %q", diffs)
+	}
+}
@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// diagassertPath is the import path of package diagassert.
+const diagassertPath = "github.com/paveg/diagassert"
+
+// qualifiedOnly are the assertions whose names are too common to recognize alone, such as
+// Not: calls count only when made on package diagassert.
+var qualifiedOnly = map[string]bool{"Not": true}
+
+// fileCalls names the functions the calls of a file make.
+type fileCalls struct {
+	aliases map[string][]*alias // Bindings of each name to a function, as da to diagassert.Assert
+	pkgs    map[string]bool     // Names the file refers to package diagassert by
+	local   bool                // Unqualified calls may be diagassert's: the file is in it or dot-imports it
+}
+
+// alias is a name bound to a function, as da in da := diagassert.Assert or var check = da,
+// within the body of the function it is bound in, or the whole file for package variables.
+// A parameter shadows names of enclosing scopes and holds no function: its value is nil.
+type alias struct {
+	value      ast.Expr
+	typ        ast.Expr  // The type of a parameter, nil for other bindings
+	pos        token.Pos // Where the binding is seen from: the end of its statement
+	start, end token.Pos // The function body it is bound in, both NoPos at package level
+}
+
+// visible reports whether the binding is seen at pos: in the function body it is bound in
+// and after it, or anywhere for a package variable.
+func (a *alias) visible(pos token.Pos) bool {
+	return a.start == token.NoPos || a.start <= pos && pos < a.end && a.pos <= pos
+}
+
+// newFileCalls collects how the file refers to package diagassert and the functions it
+// binds to names.
+func newFileCalls(file *ast.File) *fileCalls {
+	c := &fileCalls{aliases: funcAliases(file), pkgs: map[string]bool{}, local: file.Name.Name == "diagassert"}
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err != nil || path != diagassertPath {
+			continue
+		}
+		switch {
+		case spec.Name == nil:
+			c.pkgs["diagassert"] = true
+		case spec.Name.Name == ".":
+			c.local = true
+		default:
+			c.pkgs[spec.Name.Name] = true
+		}
+	}
+	return c
+}
+
+// funcAliases returns the bindings of names a file makes, by name, each with the scope of
+// the function it is made in, and the parameters of its functions, which shadow them.
+func funcAliases(file *ast.File) map[string][]*alias {
+	aliases := map[string][]*alias{}
+	var collect func(root ast.Node, start, end token.Pos)
+	bind := func(names []ast.Expr, values []ast.Expr, typ ast.Expr, pos, start, end token.Pos) {
+		for i, name := range names {
+			ident, ok := name.(*ast.Ident)
+			if !ok || ident.Name == "_" {
+				continue
+			}
+			var value ast.Expr
+			if len(names) == len(values) {
+				value = values[i]
+			}
+			aliases[ident.Name] = append(aliases[ident.Name], &alias{value: value, typ: typ, pos: pos, start: start, end: end})
+		}
+	}
+	enter := func(recv *ast.FieldList, typ *ast.FuncType, body *ast.BlockStmt) {
+		if body == nil {
+			return
+		}
+		for _, fields := range []*ast.FieldList{recv, typ.Params, typ.Results} {
+			if fields == nil {
+				continue
+			}
+			for _, field := range fields.List {
+				for _, name := range field.Names {
+					bind([]ast.Expr{name}, nil, field.Type, body.Pos(), body.Pos(), body.End())
+				}
+			}
+		}
+		collect(body, body.Pos(), body.End())
+	}
+	collect = func(root ast.Node, start, end token.Pos) {
+		ast.Inspect(root, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				enter(n.Recv, n.Type, n.Body)
+				return false
+			case *ast.FuncLit:
+				enter(nil, n.Type, n.Body)
+				return false
+			case *ast.AssignStmt:
+				bind(n.Lhs, n.Rhs, nil, n.End(), start, end)
+			case *ast.ValueSpec:
+				names := make([]ast.Expr, len(n.Names))
+				for i, name := range n.Names {
+					names[i] = name
+				}
+				bind(names, n.Values, nil, n.End(), start, end)
+			}
+			return true
+		})
+	}
+	collect(file, token.NoPos, token.NoPos)
+	return aliases
+}
+
+// binding returns the binding of name seen at pos: the last one made before it in the
+// innermost function, or else the package variable.
+func (c *fileCalls) binding(name string, pos token.Pos) *alias {
+	var found *alias
+	for _, a := range c.aliases[name] {
+		if !a.visible(pos) {
+			continue
+		}
+		if found == nil || a.start > found.start || a.start == found.start && a.pos > found.pos {
+			found = a
+		}
+	}
+	return found
+}
+
+// resolve returns the function a name called at pos holds, following the bindings it was
+// made from, as check in var check = da after da := diagassert.Assert, or nil for names
+// bound to no function.
+func (c *fileCalls) resolve(ident *ast.Ident) ast.Expr {
+	var value ast.Expr
+	// Stop at cycles such as f = g; g = f
+	seen := map[*alias]bool{}
+	for {
+		a := c.binding(ident.Name, ident.Pos())
+		if a == nil || seen[a] {
+			break
+		}
+		seen[a] = true
+		value = a.value
+		next, ok := value.(*ast.Ident)
+		if !ok {
+			break
+		}
+		ident = next
+	}
+	if value == nil || calledName(&ast.CallExpr{Fun: value}) == "" {
+		return nil
+	}
+	return value
+}
+
+// name returns the name of the function a call makes, through the aliases of the file, or
+// "" for a function named as one of qualifiedOnly that is not diagassert's, and for a Then
+// made on anything but a diagassert scenario.
+func (c *fileCalls) name(call *ast.CallExpr) string {
+	fun := call.Fun
+	if ident, ok := fun.(*ast.Ident); ok {
+		if value := c.resolve(ident); value != nil {
+			fun = value
+		}
+	}
+	name := calledName(&ast.CallExpr{Fun: fun})
+	if qualifiedOnly[name] && !c.isDiagassert(fun) {
+		return ""
+	}
+	if name == "Then" {
+		if sel, ok := fun.(*ast.SelectorExpr); !ok || !c.isScenario(sel.X) {
+			return ""
+		}
+	}
+	return name
+}
+
+// isScenario reports whether x, the receiver of a step, is a scenario of package diagassert:
+// a chain of steps from diagassert.Scenario, a name bound to one, or a parameter of type
+// *diagassert.Steps.
+func (c *fileCalls) isScenario(x ast.Expr) bool {
+	seen := map[*alias]bool{}
+	for {
+		switch e := x.(type) {
+		case *ast.ParenExpr:
+			x = e.X
+		case *ast.CallExpr:
+			fun := e.Fun
+			if ident, ok := fun.(*ast.Ident); ok {
+				if value := c.resolve(ident); value != nil {
+					fun = value
+				}
+			}
+			if calledName(&ast.CallExpr{Fun: fun}) == "Scenario" && c.isDiagassert(fun) {
+				return true
+			}
+			// A step made before, as Given in Scenario(t).Given(...).Then(...)
+			sel, ok := fun.(*ast.SelectorExpr)
+			if !ok {
+				return false
+			}
+			x = sel.X
+		case *ast.Ident:
+			a := c.binding(e.Name, e.Pos())
+			if a == nil || seen[a] {
+				return false
+			}
+			seen[a] = true
+			if a.typ != nil {
+				star, ok := a.typ.(*ast.StarExpr)
+				return ok && calledName(&ast.CallExpr{Fun: star.X}) == "Steps" && c.isDiagassert(star.X)
+			}
+			if a.value == nil {
+				return false
+			}
+			x = a.value
+		default:
+			return false
+		}
+	}
+}
+
+// isDiagassert reports whether fun, a function as called, is one of package diagassert's,
+// as diagassert.Not, or may be one, as an unqualified Not in the package itself.
+func (c *fileCalls) isDiagassert(fun ast.Expr) bool {
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		return c.local
+	case *ast.SelectorExpr:
+		pkg, ok := fun.X.(*ast.Ident)
+		return ok && c.pkgs[pkg.Name]
+	case *ast.IndexExpr:
+		return c.isDiagassert(fun.X)
+	case *ast.IndexListExpr:
+		return c.isDiagassert(fun.X)
+	}
+	return false
+}
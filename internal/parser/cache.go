@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sync"
+	"time"
+)
+
+// parsedFile is a source file parsed with its comments, kept while the file is unchanged: a
+// test failing repeatedly, or failing in a loop, reads and parses its file once.
+type parsedFile struct {
+	modTime time.Time
+	size    int64
+	src     []byte // Normalized, as NormalizeSource returns it
+	fset    *token.FileSet
+	file    *ast.File
+
+	callsOnce sync.Once
+	calls     *fileCalls
+
+	mu      sync.Mutex
+	located map[int]located // LocateExpression's results, by line
+}
+
+// located is a result of LocateExpression.
+type located struct {
+	extraction Extraction
+	err        error
+}
+
+// fileCalls returns how the file names the functions it calls, collected the first time.
+func (p *parsedFile) fileCalls() *fileCalls {
+	p.callsOnce.Do(func() { p.calls = newFileCalls(p.file) })
+	return p.calls
+}
+
+var (
+	parsedMu    sync.Mutex
+	parsedFiles = map[string]*parsedFile{} // By path
+)
+
+// parseFile returns the parsed file at path, reading and parsing it again only when its
+// modification time or size changed. The file and its positions must not be modified.
+func parseFile(path string) (*parsedFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedMu.Lock()
+	cached, ok := parsedFiles[path]
+	parsedMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached, nil
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	src = NormalizeSource(src)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &parsedFile{modTime: info.ModTime(), size: info.Size(), src: src, fset: fset, file: file}
+	parsedMu.Lock()
+	parsedFiles[path] = parsed
+	parsedMu.Unlock()
+	return parsed, nil
+}
+
+// ParseCached returns the file at path parsed with its comments, as parseFile keeps it. The
+// file must not be modified.
+func ParseCached(path string) (*ast.File, error) {
+	parsed, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.file, nil
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// directiveFiles returns the Go files that may hold code //line directives attribute to
+// filename, such as a parser generated from a grammar: the files with line directives in
+// the directory of filename and in the working directory, the package directory under
+// go test.
+func directiveFiles(filename string) []string {
+	dirs := []string{filepath.Dir(ResolveSource(filename))}
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+
+	var files []string
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || seen[path] {
+				continue
+			}
+			seen[path] = true
+			if src, err := os.ReadFile(path); err == nil && hasLineDirective(src) {
+				files = append(files, path)
+			}
+		}
+	}
+	return files
+}
+
+// hasLineDirective reports whether src has a //line or /*line directive.
+func hasLineDirective(src []byte) bool {
+	return bytes.Contains(src, []byte("//line ")) || bytes.Contains(src, []byte("/*line "))
+}
+
+// sameSource reports whether a file named by a line directive is the file named by a
+// runtime frame. Either may be relative, so they match when one ends with the other.
+func sameSource(directive, frame string) bool {
+	directive, frame = filepath.ToSlash(filepath.Clean(directive)), filepath.ToSlash(filepath.Clean(frame))
+	return directive == frame || strings.HasSuffix(directive, "/"+frame) || strings.HasSuffix(frame, "/"+directive)
+}
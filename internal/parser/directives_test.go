@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocateExpression_LineDirectives(t *testing.T) {
+	dir := t.TempDir()
+	generated := `package main
+
+func TestGenerated(t *testing.T) {
+//line rules.tmpl:40
+	diagassert.Assert(t, total > 0)
+//line rules.tmpl:52
+	diagassert.Match(t, code, Like("E*"))
+	/*line other.tmpl:40:1*/ diagassert.Assert(t, other > 0)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "rules_gen.go"), []byte(generated), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		line     int
+		expected string
+	}{
+		// The template the directives name does not exist
+		{"logical location", filepath.Join(dir, "rules.tmpl"), 40, "total > 0"},
+		{"inline directive", filepath.Join(dir, "other.tmpl"), 40, "other > 0"},
+		// Locations of the generated file itself still work
+		{"physical location", filepath.Join(dir, "rules_gen.go"), 5, "total > 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractExpression(tt.filename, tt.line)
+			if err != nil || got != tt.expected {
+				t.Errorf("ExtractExpression(%s:%d) = %q, %v, want %q", filepath.Base(tt.filename), tt.line, got, err, tt.expected)
+			}
+		})
+	}
+
+	// The template exists, but the call is only in the generated code
+	if err := os.WriteFile(filepath.Join(dir, "rules.tmpl"), []byte("{{ range .Rules }}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	if got, err := ExtractExpression(filepath.Join(dir, "rules.tmpl"), 40); err != nil || got != "total > 0" {
+		t.Errorf("ExtractExpression(rules.tmpl:40) = %q, %v, want the generated call", got, err)
+	}
+	if args, err := ExtractCallArguments(filepath.Join(dir, "rules.tmpl"), 52, "Match"); err != nil || len(args) != 3 || args[2] != `Like("E*")` {
+		t.Errorf("ExtractCallArguments(rules.tmpl:52) = %q, %v", args, err)
+	}
+	if _, err := ExtractExpression(filepath.Join(dir, "rules.tmpl"), 41); err == nil {
+		t.Error("Expected an error for a line no directive maps code to")
+	}
+}
+
+func TestSameSource(t *testing.T) {
+	tests := []struct {
+		directive, frame string
+		expected         bool
+	}{
+		{"/src/app/rules.tmpl", "/src/app/rules.tmpl", true},
+		{"/src/app/rules.tmpl", "app/rules.tmpl", true},
+		{"rules.tmpl", "/src/app/rules.tmpl", true},
+		{"/src/app/rules.tmpl", "/src/app/myrules.tmpl", false},
+		{"/src/app/rules.tmpl", "/src/lib/rules.tmpl", false},
+	}
+	for _, tt := range tests {
+		if got := sameSource(tt.directive, tt.frame); got != tt.expected {
+			t.Errorf("sameSource(%q, %q) = %v, want %v", tt.directive, tt.frame, got, tt.expected)
+		}
+	}
+}
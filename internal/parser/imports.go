@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"path"
+	"strconv"
+)
+
+// FileImports returns the package name of a source file and its imports, as import path by
+// the name the file refers to the package with. Blank and dot imports are left out.
+func FileImports(filename string) (string, map[string]string, error) {
+	parsed, err := parseFile(ResolveSource(filename))
+	if err != nil {
+		return "", nil, err
+	}
+	file := parsed.file
+
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		imports[name] = importPath
+	}
+	return file.Name.Name, imports, nil
+}
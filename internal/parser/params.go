@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// Param is a parameter of a function: its name and the source text of its type.
+type Param struct {
+	Name string
+	Type string
+}
+
+// EnclosingParams returns the parameters of the functions, declared or literal, whose
+// bodies hold the line, innermost first. Unnamed parameters have an empty name.
+func EnclosingParams(filename string, line int) ([][]Param, error) {
+	parsed, err := parseFile(ResolveSource(filename))
+	if err != nil {
+		return nil, err
+	}
+	fset, file := parsed.fset, parsed.file
+
+	var funcs [][]Param
+	ast.Inspect(file, func(n ast.Node) bool {
+		var fields *ast.FieldList
+		var body *ast.BlockStmt
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			fields, body = fn.Type.Params, fn.Body
+		case *ast.FuncLit:
+			fields, body = fn.Type.Params, fn.Body
+		default:
+			return true
+		}
+		if body == nil || fset.Position(body.Pos()).Line > line || fset.Position(body.End()).Line < line {
+			return false
+		}
+
+		var params []Param
+		for _, field := range fields.List {
+			typ := types.ExprString(field.Type)
+			if len(field.Names) == 0 {
+				params = append(params, Param{Type: typ})
+			}
+			for _, name := range field.Names {
+				params = append(params, Param{Name: name.Name, Type: typ})
+			}
+		}
+		// Inspect visits the outer functions first
+		funcs = append([][]Param{params}, funcs...)
+		return true
+	})
+	return funcs, nil
+}
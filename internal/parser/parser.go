@@ -2,76 +2,383 @@
 package parser
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"go/ast"
-	"go/parser"
 	"go/token"
-	"os"
+	"go/types"
+	"strings"
 )
 
+// Confidence is how surely an extracted expression is the one asserted.
+type Confidence int
+
+const (
+	// Certain expressions are arguments of a call to Assert, Require or another assertion,
+	// made directly, through an import alias or through a variable the file binds it to.
+	Certain Confidence = iota
+	// Guessed expressions are the second argument of a call at the line passing a test
+	// first, such as check(t, x > 1), taken when no assertion call is found there.
+	Guessed
+)
+
+// Extraction is an expression extracted from a line, and how it was found.
+type Extraction struct {
+	Expr       string
+	Confidence Confidence
+	Call       string // Source text of the function called with the expression, e.g. "check"
+	Deferred   bool   // The assertion was deferred, and runs as its function returns at the line
+}
+
 // ExtractExpression extracts the expression from source code at the specified line.
-// It looks for Assert or Require function calls and returns the expression argument.
+// It looks for Assert or Require function calls, or a scenario's Then, and returns the
+// expression argument. See LocateExpression.
 func ExtractExpression(filename string, line int) (string, error) {
-	// Read the source file
-	src, err := os.ReadFile(filename)
+	extraction, err := LocateExpression(filename, line)
+	return extraction.Expr, err
+}
+
+// LocateExpression extracts the expression from source code at the specified line and says
+// how surely it is the one asserted. Assertions are recognized by name, also when called
+// through a variable, as da in da := diagassert.Assert. Without one at the line, the
+// expression is guessed from a call passing a test, such as a *testing.T parameter of the
+// enclosing function, as its first argument. Deferred assertions are reported at the line
+// their function returns at, and found there too. Results are kept with the parsed file, so
+// an assertion failing again is located once while its file is unchanged.
+func LocateExpression(filename string, line int) (Extraction, error) {
+	parsed, err := parseFile(filename)
 	if err != nil {
-		return "", err
+		// Frames may name files that are elsewhere where the test runs
+		parsed, err = parseFile(ResolveSource(filename))
 	}
+	if err != nil {
+		return locateExpression(filename, line)
+	}
+	parsed.mu.Lock()
+	result, ok := parsed.located[line]
+	parsed.mu.Unlock()
+	if !ok {
+		result.extraction, result.err = locateExpression(filename, line)
+		parsed.mu.Lock()
+		if parsed.located == nil {
+			parsed.located = map[int]located{}
+		}
+		parsed.located[line] = result
+		parsed.mu.Unlock()
+	}
+	return result.extraction, result.err
+}
 
-	// Parse the AST
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+// locateExpression is LocateExpression without the cache.
+func locateExpression(filename string, line int) (Extraction, error) {
+	index := 1
+	var callee string
+	args, err := extractCallArgs(filename, line, 1, func(call *ast.CallExpr, name string) bool {
+		i, ok := exprIndex(name)
+		if ok {
+			index = i
+		}
+		return ok && len(call.Args) > i
+	})
+	if err == nil {
+		return Extraction{Expr: args[index], Confidence: Certain}, nil
+	}
+	if !errors.Is(err, errNotFound) {
+		return Extraction{}, err
+	}
+
+	// Any call with a test first will do, as long as it has an argument after the test
+	tests, err := testingParams(filename, line)
 	if err != nil {
-		return "", err
+		return Extraction{}, err
+	}
+	args, err = extractCallArgs(filename, line, 2, func(call *ast.CallExpr, _ string) bool {
+		first, ok := call.Args[0].(*ast.Ident)
+		if ok && tests[first.Name] {
+			callee = types.ExprString(call.Fun)
+			return true
+		}
+		return false
+	})
+	if err == nil {
+		return Extraction{Expr: args[1], Confidence: Guessed, Call: callee}, nil
+	}
+	if !errors.Is(err, errNotFound) {
+		return Extraction{}, err
 	}
 
-	// Find the expression at the specified line
-	var targetExpr string
+	if extraction, ok := deferredAssertion(filename, line); ok {
+		return extraction, nil
+	}
+	return Extraction{}, err
+}
+
+// exprIndex returns the position of the expression among the arguments of the named
+// assertion: 1 after the test, 2 after AssertSkip's skip or AssertC's test, or 0 for Then.
+func exprIndex(name string) (int, bool) {
+	switch {
+	case isSkipCall(name), isContextCall(name):
+		return 2, true
+	case name == "Then":
+		return 0, true
+	case isAssertCall(name):
+		return 1, true
+	}
+	return 0, false
+}
+
+// deferredAssertion finds the assertion deferred in the innermost function enclosing the
+// line. A deferred call runs as its function returns, so it is reported at the return
+// statement or closing brace instead of where it was deferred; other lines have none. Of
+// several deferred before the line, the last one runs first and is taken, but only as a guess.
+func deferredAssertion(filename string, line int) (Extraction, bool) {
+	parsed, err := parseFile(ResolveSource(filename))
+	if err != nil {
+		return Extraction{}, false
+	}
+	fset, calls := parsed.fset, parsed.fileCalls()
+
+	var body *ast.BlockStmt
+	ast.Inspect(parsed.file, func(n ast.Node) bool {
+		if n == nil || fset.Position(n.Pos()).Line > line || fset.Position(n.End()).Line < line {
+			return n == nil
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			body = n.Body
+		case *ast.FuncLit:
+			body = n.Body
+		}
+		return true
+	})
+	if body == nil {
+		return Extraction{}, false
+	}
+
+	var deferred []ast.Expr
+	returns := fset.Position(body.Rbrace).Line == line
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Its deferred calls run when it returns
+			return false
+		case *ast.ReturnStmt:
+			returns = returns || fset.Position(n.Pos()).Line == line
+		case *ast.DeferStmt:
+			i, ok := exprIndex(calls.name(n.Call))
+			if ok && len(n.Call.Args) > i && fset.Position(n.Pos()).Line <= line {
+				deferred = append(deferred, n.Call.Args[i])
+			}
+		}
+		return true
+	})
+	if !returns || len(deferred) == 0 {
+		return Extraction{}, false
+	}
+
+	expr := deferred[len(deferred)-1]
+	start, end := fset.Position(expr.Pos()).Offset, fset.Position(expr.End()).Offset
+	if start < 0 || end > len(parsed.src) || start >= end {
+		return Extraction{}, false
+	}
+	extraction := Extraction{Expr: string(parsed.src[start:end]), Confidence: Certain, Deferred: true}
+	if len(deferred) > 1 {
+		extraction.Confidence = Guessed
+	}
+	return extraction, true
+}
+
+// testingParams returns the names of the parameters of testing types, such as t *testing.T
+// or tb testing.TB, of the functions enclosing the line.
+func testingParams(filename string, line int) (map[string]bool, error) {
+	funcs, err := EnclosingParams(filename, line)
+	if err != nil {
+		return nil, err
+	}
+	tests := map[string]bool{}
+	for _, params := range funcs {
+		for _, param := range params {
+			if param.Name != "" && (strings.Contains(param.Type, "testing.") || strings.HasSuffix(param.Type, "TestingT")) {
+				tests[param.Name] = true
+			}
+		}
+	}
+	return tests, nil
+}
+
+// ExtractCallArguments returns the source text of every argument of the first call to the
+// named function at the specified line, e.g. ["t", "user.Email", "IsEmail()"] for
+// diagassert.Match(t, user.Email, IsEmail()). Calls through variables bound to the function
+// count too.
+func ExtractCallArguments(filename string, line int, name string) ([]string, error) {
+	return extractCallArgs(filename, line, 0, func(_ *ast.CallExpr, called string) bool {
+		return called == name
+	})
+}
+
+// errNotFound is returned when no call at the line is accepted.
+var errNotFound = errors.New("expression not found")
+
+// extractCallArgs finds the first call at the line accepted by match that has at least
+// minArgs arguments, and returns the source text of its arguments. match is given the name
+// of the function called, through the aliases the file binds functions to. Lines of code
+// that //line directives attribute to the file, as in generated code, are looked up in the
+// files holding the directives when the file does not have the call.
+func extractCallArgs(filename string, line, minArgs int, match func(call *ast.CallExpr, name string) bool) ([]string, error) {
+	args, err := callArgsIn(ResolveSource(filename), func(physical, logical token.Position) bool {
+		return physical.Line == line || logical.Line == line && sameSource(logical.Filename, filename)
+	}, minArgs, match)
+	if err == nil {
+		return args, nil
+	}
+
+	// The file may not exist, not be Go, as a template, or not have the call
+
+	for _, path := range directiveFiles(filename) {
+		found, ferr := callArgsIn(path, func(_, logical token.Position) bool {
+			return logical.Line == line && sameSource(logical.Filename, filename)
+		}, minArgs, match)
+		if ferr == nil {
+			return found, nil
+		}
+	}
+	return nil, err
+}
+
+// callArgsIn returns the arguments of the first call in the file at path that is at, by
+// its physical position or the one line directives give it, a line accepted by at.
+func callArgsIn(path string, at func(physical, logical token.Position) bool, minArgs int, match func(call *ast.CallExpr, name string) bool) ([]string, error) {
+	parsed, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	src, fset, file := parsed.src, parsed.fset, parsed.file
+	calls := parsed.fileCalls()
+	atLine := func(pos token.Pos) bool {
+		return at(fset.PositionFor(pos, false), fset.PositionFor(pos, true))
+	}
+
+	// Find the call at the specified line
+	var args []string
 	ast.Inspect(file, func(n ast.Node) bool {
-		if n == nil {
+		if n == nil || args != nil {
 			return false
 		}
 
-		pos := fset.Position(n.Pos())
-		if pos.Line != line {
+		// A call is at the line of its parenthesis too, where a chained call such as
+		// Scenario(t).\n\tThen(x) is reported
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !atLine(call.Pos()) && !atLine(call.Lparen) {
 			return true
 		}
-
-		// Look for Assert/Require function calls
-		if call, ok := n.(*ast.CallExpr); ok {
-			if isAssertCall(call) && len(call.Args) >= 2 {
-				// Extract the second argument (expression) as string (0=t, 1=expr)
-				exprArg := call.Args[1]
-				start := fset.Position(exprArg.Pos()).Offset
-				end := fset.Position(exprArg.End()).Offset
-				if start >= 0 && end <= len(src) && start < end {
-					targetExpr = string(src[start:end])
-					return false
-				}
+		if len(call.Args) < minArgs || !match(call, calls.name(call)) {
+			return true
+		}
+		texts := make([]string, len(call.Args))
+		for i, arg := range call.Args {
+			start := fset.Position(arg.Pos()).Offset
+			end := fset.Position(arg.End()).Offset
+			if start < 0 || end > len(src) || start >= end {
+				return true
 			}
+			texts[i] = string(src[start:end])
 		}
+		args = texts
+		return false
+	})
+
+	if args == nil {
+		return nil, errNotFound
+	}
+
+	return args, nil
+}
+
+// NormalizeSource strips a leading UTF-8 byte order mark and converts CRLF line
+// endings to LF, so that offsets and extracted expressions are identical on every platform.
+// Line numbers are unaffected: only the '\r' preceding each '\n' is removed.
+func NormalizeSource(src []byte) []byte {
+	src = bytes.TrimPrefix(src, utf8BOM)
+	return bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+}
+
+// utf8BOM is the UTF-8 encoding of the byte order mark.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ParameterIndex returns the position of the parameter named name in the signature of the
+// innermost function, declared or literal, enclosing the specified line, or -1 if it has
+// none by that name. The receiver is not counted.
+func ParameterIndex(filename string, line int, name string) (int, error) {
+	parsed, err := parseFile(ResolveSource(filename))
+	if err != nil {
+		return -1, err
+	}
+	fset, file := parsed.fset, parsed.file
 
+	var fn *ast.FuncType
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n == nil || fset.Position(n.Pos()).Line > line || fset.Position(n.End()).Line < line {
+			return n == nil
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			fn = n.Type
+		case *ast.FuncLit:
+			fn = n.Type
+		}
 		return true
 	})
+	if fn == nil {
+		return -1, fmt.Errorf("no function encloses line %d", line)
+	}
 
-	if targetExpr == "" {
-		return "", fmt.Errorf("expression not found")
+	index := 0
+	for _, field := range fn.Params.List {
+		if len(field.Names) == 0 {
+			index++
+			continue
+		}
+		for _, ident := range field.Names {
+			if ident.Name == name {
+				return index, nil
+			}
+			index++
+		}
 	}
+	return -1, nil
+}
 
-	return targetExpr, nil
+// isSkipCall determines if the named function is AssertSkip or RequireSkip, whose
+// expression follows the number of frames to skip.
+func isSkipCall(name string) bool {
+	return name == "AssertSkip" || name == "RequireSkip"
 }
 
-// isAssertCall determines if a function call is an Assert or Require call.
-func isAssertCall(call *ast.CallExpr) bool {
-	// Package selector: diagassert.Assert
-	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
-		name := sel.Sel.Name
-		return name == "Assert" || name == "Require"
-	}
-	// Direct function call: Assert (within same package)
-	if ident, ok := call.Fun.(*ast.Ident); ok {
-		name := ident.Name
-		return name == "Assert" || name == "Require"
+// isContextCall determines if the named function is AssertC or RequireC, whose arguments
+// start with a context before the test.
+func isContextCall(name string) bool {
+	return name == "AssertC" || name == "RequireC"
+}
+
+// isAssertCall determines if the named function is Assert, Require or Not.
+func isAssertCall(name string) bool {
+	return name == "Assert" || name == "Require" || name == "Not"
+}
+
+// calledName returns the name of the called function, both for package selectors such as
+// diagassert.Assert and for direct calls within the same package.
+func calledName(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	case *ast.Ident:
+		return fun.Name
+	case *ast.IndexExpr:
+		// An instantiated generic function, such as Must[*os.File]
+		return calledName(&ast.CallExpr{Fun: fun.X})
+	case *ast.IndexListExpr:
+		return calledName(&ast.CallExpr{Fun: fun.X})
 	}
-	return false
+	return ""
 }
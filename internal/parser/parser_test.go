@@ -3,6 +3,8 @@ package parser
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -89,3 +91,505 @@ func TestIsAssertCall(t *testing.T) {
 	// Additional unit tests could be added here if needed
 	t.Skip("isAssertCall is tested indirectly through ExtractExpression")
 }
+
+func TestExtractExpression_CRLFAndBOM(t *testing.T) {
+	lines := []string{
+		"package main",
+		"",
+		"func TestExample(t *testing.T) {",
+		"\tx := 10",
+		"\tdiagassert.Assert(t, x > 20) // This is line 5",
+		"\tdiagassert.Assert(t, x > 1 &&",
+		"\t\tx < 5) // Expression spans lines 6-7",
+		"}",
+		"",
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"CRLF line endings", strings.Join(lines, "\r\n")},
+		{"UTF-8 BOM", "\uFEFF" + strings.Join(lines, "\n")},
+		{"CRLF with BOM", "\uFEFF" + strings.Join(lines, "\r\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "test.go")
+			if err := os.WriteFile(testFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			result, err := ExtractExpression(testFile, 5)
+			if err != nil {
+				t.Fatalf("ExtractExpression() unexpected error: %v", err)
+			}
+			if result != "x > 20" {
+				t.Errorf("ExtractExpression() = %q, expected %q", result, "x > 20")
+			}
+
+			result, err = ExtractExpression(testFile, 6)
+			if err != nil {
+				t.Fatalf("ExtractExpression() unexpected error: %v", err)
+			}
+			if expected := "x > 1 &&\n\t\tx < 5"; result != expected {
+				t.Errorf("ExtractExpression() = %q, expected %q", result, expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain", "a\nb\n", "a\nb\n"},
+		{"CRLF", "a\r\nb\r\n", "a\nb\n"},
+		{"BOM", "\uFEFFa\n", "a\n"},
+		{"lone CR is kept", "a\rb\n", "a\rb\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(NormalizeSource([]byte(tt.input))); got != tt.expected {
+				t.Errorf("NormalizeSource(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractExpression_ModernSyntax(t *testing.T) {
+	testContent := `package main
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+func Map[T, U any](xs []T, f func(T) U) []U { return nil }
+
+func TestExample(t *testing.T) {
+	diagassert.Assert(t, Map[int, string](xs, strconv.Itoa)[0] == "1") // line 11
+	diagassert.Assert(t, Pair[string, int]{Key: "a", Val: 1} == p)     // line 12
+	diagassert.Assert(t, min(a, b) > max(c, d))                       // line 13
+	for v := range seq {
+		diagassert.Assert(t, v < limit) // line 15
+	}
+}
+`
+
+	testFile := filepath.Join(t.TempDir(), "generics_test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		line     int
+		expected string
+	}{
+		{11, `Map[int, string](xs, strconv.Itoa)[0] == "1"`},
+		{12, `Pair[string, int]{Key: "a", Val: 1} == p`},
+		{13, `min(a, b) > max(c, d)`},
+		{15, `v < limit`},
+	}
+
+	for _, tt := range tests {
+		result, err := ExtractExpression(testFile, tt.line)
+		if err != nil {
+			t.Errorf("ExtractExpression(line %d) unexpected error: %v", tt.line, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("ExtractExpression(line %d) = %q, expected %q", tt.line, result, tt.expected)
+		}
+	}
+}
+
+func TestExtractCallArguments(t *testing.T) {
+	testContent := `package main
+
+func TestExample(t *testing.T) {
+	diagassert.Match(t, user.Email, IsEmail(), "msg")
+	Match(t, f(a, b), Between(1, 5))
+}
+`
+	testFile := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	args, err := ExtractCallArguments(testFile, 4, "Match")
+	if err != nil || strings.Join(args, "|") != `t|user.Email|IsEmail()|"msg"` {
+		t.Errorf("ExtractCallArguments = %q, %v", args, err)
+	}
+
+	args, err = ExtractCallArguments(testFile, 5, "Match")
+	if err != nil || strings.Join(args, "|") != "t|f(a, b)|Between(1, 5)" {
+		t.Errorf("ExtractCallArguments = %q, %v", args, err)
+	}
+
+	if _, err := ExtractCallArguments(testFile, 4, "Assert"); err == nil {
+		t.Error("Expected an error when the line has no such call")
+	}
+}
+
+func TestExtractExpression_Not(t *testing.T) {
+	testContent := `package main
+
+import (
+	"github.com/paveg/diagassert"
+	"example.com/filters"
+)
+
+func TestExample(t *testing.T) {
+	diagassert.Not(t, strings.Contains(log, "panic"))
+	filters.Not(t, deleted)
+	refute := diagassert.Not
+	refute(t, failed)
+}
+`
+	testFile := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	for line, want := range map[int]string{9: `strings.Contains(log, "panic")`, 12: "failed"} {
+		if got, err := LocateExpression(testFile, line); err != nil || got.Expr != want || got.Confidence != Certain {
+			t.Errorf("LocateExpression(line %d) = %+v, %v, want %q", line, got, err, want)
+		}
+	}
+	// Other packages' Not is only guessed at, like any call passing the test
+	if got, err := LocateExpression(testFile, 10); err != nil || got.Confidence != Guessed {
+		t.Errorf("LocateExpression(line 10) = %+v, %v, want a guess", got, err)
+	}
+}
+
+func TestExtractExpression_Then(t *testing.T) {
+	testContent := `package main
+
+import "github.com/paveg/diagassert"
+
+func TestExample(t *testing.T) {
+	diagassert.Scenario(t).
+		Given("a cart", diagassert.V("cart", cart)).
+		Then(cart.Total() == 3).
+		Then(len(cart.Items) > 0, "msg")
+	s := diagassert.Scenario(t).When("paying")
+	s.Then(paid)
+	promise.Then(done)
+}
+
+func checkout(s *diagassert.Steps) {
+	s.Then(ordered)
+}
+`
+	testFile := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	for line, want := range map[int]string{8: "cart.Total() == 3", 9: "len(cart.Items) > 0", 11: "paid", 16: "ordered"} {
+		if got, err := ExtractExpression(testFile, line); err != nil || got != want {
+			t.Errorf("ExtractExpression(line %d) = %q, %v, want %q", line, got, err, want)
+		}
+	}
+	// Then of anything but a scenario is not an assertion
+	if got, err := ExtractExpression(testFile, 12); err == nil {
+		t.Errorf("ExtractExpression(line 12) = %q, want an error", got)
+	}
+}
+
+func TestLocateExpression(t *testing.T) {
+	testContent := `package main
+
+import (
+	da "github.com/paveg/diagassert"
+	. "github.com/paveg/diagassert"
+)
+
+var check = da.Require
+
+func TestExample(t *testing.T) {
+	must := da.Require
+	da.Assert(t, a > 1)
+	Assert(t, b > 1)
+	must(t, c > 1)
+	check(t, d > 1)
+	h.ok(t, e > 1)
+	fmt.Println(t, f)
+	h.ok(other, g > 1)
+	da.Match(t, user.Email, IsEmail())
+}
+
+func TestShadowed(t *testing.T, check func(...interface{})) {
+	must(t, x > 1)
+	must := fmt.Println
+	must(t, y)
+	check(t, z)
+}
+`
+	testFile := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		line int
+		want Extraction
+	}{
+		{12, Extraction{Expr: "a > 1", Confidence: Certain}},
+		{13, Extraction{Expr: "b > 1", Confidence: Certain}},
+		{14, Extraction{Expr: "c > 1", Confidence: Certain}},
+		{15, Extraction{Expr: "d > 1", Confidence: Certain}},
+		{16, Extraction{Expr: "e > 1", Confidence: Guessed, Call: "h.ok"}},
+		{17, Extraction{Expr: "f", Confidence: Guessed, Call: "fmt.Println"}},
+		// Bindings are seen in their own function only, after them, and not through parameters
+		{23, Extraction{Expr: "x > 1", Confidence: Guessed, Call: "must"}},
+		{25, Extraction{Expr: "y", Confidence: Guessed, Call: "must"}},
+		{26, Extraction{Expr: "z", Confidence: Guessed, Call: "check"}},
+	}
+	for _, tt := range tests {
+		if got, err := LocateExpression(testFile, tt.line); err != nil || got != tt.want {
+			t.Errorf("LocateExpression(line %d) = %+v, %v, want %+v", tt.line, got, err, tt.want)
+		}
+	}
+
+	// Calls that do not pass the test first are not guessed at
+	if got, err := LocateExpression(testFile, 18); err == nil {
+		t.Errorf("LocateExpression(line 18) = %+v, want an error", got)
+	}
+	if args, err := ExtractCallArguments(testFile, 19, "Match"); err != nil || len(args) != 3 {
+		t.Errorf("ExtractCallArguments through an import alias = %q, %v", args, err)
+	}
+}
+
+func TestFileImports(t *testing.T) {
+	testContent := `package shop_test
+
+import (
+	"strings"
+	_ "embed"
+	yaml "gopkg.in/yaml.v3"
+	"github.com/paveg/diagassert"
+)
+`
+	testFile := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	pkg, imports, err := FileImports(testFile)
+	want := map[string]string{"strings": "strings", "yaml": "gopkg.in/yaml.v3", "diagassert": "github.com/paveg/diagassert"}
+	if err != nil || pkg != "shop_test" || !reflect.DeepEqual(imports, want) {
+		t.Errorf("FileImports = %q, %v, %v", pkg, imports, err)
+	}
+}
+
+func TestEnclosingParams(t *testing.T) {
+	testContent := `package main
+
+func TestExample(t *testing.T) {
+	diagassert.Table(t, cases, func(t *testing.T, tc parseCase) {
+		diagassert.Assert(t, parse(tc.in) == tc.want)
+	})
+}
+`
+	testFile := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	funcs, err := EnclosingParams(testFile, 5)
+	if err != nil {
+		t.Fatalf("EnclosingParams: %v", err)
+	}
+	want := [][]Param{
+		{{Name: "t", Type: "*testing.T"}, {Name: "tc", Type: "parseCase"}},
+		{{Name: "t", Type: "*testing.T"}},
+	}
+	if !reflect.DeepEqual(funcs, want) {
+		t.Errorf("EnclosingParams = %+v, want %+v", funcs, want)
+	}
+
+	if funcs, _ := EnclosingParams(testFile, 1); len(funcs) != 0 {
+		t.Errorf("EnclosingParams outside functions = %+v, want none", funcs)
+	}
+}
+
+func TestExtractExpression_SkipVariants(t *testing.T) {
+	testContent := `package main
+
+func check(t *testing.T, label string, ok, strict bool, args ...interface{}) {
+	diagassert.AssertSkip(t, 1, ok)
+	diagassert.RequireSkip(t, 2, ok && strict, args...)
+	run := func(inner bool) {
+		diagassert.AssertSkip(t, 1, inner)
+	}
+	run(ok)
+}
+`
+	testFile := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	for line, want := range map[int]string{4: "ok", 5: "ok && strict", 7: "inner"} {
+		if got, err := ExtractExpression(testFile, line); err != nil || got != want {
+			t.Errorf("ExtractExpression(line %d) = %q, %v; want %q", line, got, err, want)
+		}
+	}
+
+	tests := []struct {
+		line  int
+		name  string
+		index int
+	}{
+		{4, "ok", 2},
+		{4, "strict", 3},
+		{4, "args", 4},
+		{4, "missing", -1},
+		{7, "inner", 0},
+		{7, "ok", -1},
+	}
+	for _, tt := range tests {
+		if got, err := ParameterIndex(testFile, tt.line, tt.name); err != nil || got != tt.index {
+			t.Errorf("ParameterIndex(line %d, %q) = %d, %v; want %d", tt.line, tt.name, got, err, tt.index)
+		}
+	}
+}
+
+func TestExtractExpression_ContextVariants(t *testing.T) {
+	testContent := `package main
+
+func check(ctx context.Context, t *testing.T, order Order) {
+	diagassert.AssertC(ctx, t, order.Total > 0)
+	diagassert.RequireC(ctx, t, order.Paid, "unpaid")
+}
+`
+	testFile := filepath.Join(t.TempDir(), "test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	for line, want := range map[int]string{4: "order.Total > 0", 5: "order.Paid"} {
+		if got, err := ExtractExpression(testFile, line); err != nil || got != want {
+			t.Errorf("ExtractExpression(line %d) = %q, %v; want %q", line, got, err, want)
+		}
+	}
+}
+
+func TestLocateExpression_ClosuresAndDefers(t *testing.T) {
+	testContent := `package main
+
+func FuzzParse(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) { diagassert.Assert(t, len(s) < 10) })
+	f.Fuzz(func(t *testing.T, n int) {
+		diagassert.Require(t,
+			n >= 0)
+	})
+}
+
+func ExampleAssert() {
+	t := &exampleT{}
+	diagassert.Assert(t, 1+1 == 3)
+}
+
+func TestDeferred(t *testing.T) {
+	defer diagassert.Assert(t, closed)
+	must(t, total > 0)
+	count++
+	func() {
+		defer diagassert.Assert(t, inner > 1)
+	}()
+	if early {
+		return
+	}
+}
+
+func TestDeferredTwice(t *testing.T) {
+	defer diagassert.Assert(t, first)
+	defer diagassert.Assert(t, second)
+	go func() {
+		defer diagassert.Assert(t, other)
+	}()
+}
+`
+	testFile := filepath.Join(t.TempDir(), "fuzz_test.go")
+	if err := os.WriteFile(testFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		line       int
+		expr       string
+		confidence Confidence
+		deferred   bool
+	}{
+		{4, "len(s) < 10", Certain, false},
+		{6, "n >= 0", Certain, false},
+		{13, "1+1 == 3", Certain, false},
+		{18, "total > 0", Guessed, false}, // A call at the line comes before deferred ones
+		{22, "inner > 1", Certain, true},  // Closing brace of the literal
+		{24, "closed", Certain, true},     // Return statement
+		{26, "closed", Certain, true},     // Closing brace of the function
+		{34, "second", Guessed, true},
+	}
+	for _, tt := range tests {
+		got, err := LocateExpression(testFile, tt.line)
+		if err != nil || got.Expr != tt.expr || got.Confidence != tt.confidence || got.Deferred != tt.deferred {
+			t.Errorf("LocateExpression(line %d) = %+v, %v; want %q (confidence %d, deferred %v)",
+				tt.line, got, err, tt.expr, tt.confidence, tt.deferred)
+		}
+	}
+
+	// Deferred assertions run as the function returns, not at any line after the defer
+	if got, err := LocateExpression(testFile, 19); err == nil {
+		t.Errorf("LocateExpression(line 19) = %+v, want an error", got)
+	}
+}
+
+func TestResolveSource(t *testing.T) {
+	root := t.TempDir()
+	moved := filepath.Join(root, "app", "user_test.go")
+	if err := os.MkdirAll(filepath.Dir(moved), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "package app\n\nfunc TestUser(t *testing.T) {\n\tdiagassert.Assert(t, age >= 18)\n}\n"
+	if err := os.WriteFile(moved, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DIAGASSERT_SRC_ROOT", root)
+
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{"existing file", moved, moved},
+		{"built on another machine", "/home/ci/work/app/user_test.go", moved},
+		{"trimpath import path", "github.com/paveg/diagassert/internal/parser/parser.go", filepath.Join(wd, "parser.go")},
+		{"working directory", "/build/src/internal/parser/source.go", filepath.Join(wd, "source.go")},
+		{"not found", "/home/ci/work/app/missing_test.go", "/home/ci/work/app/missing_test.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveSource(tt.filename); got != tt.want {
+				t.Errorf("ResolveSource(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+
+	if got, err := ExtractExpression("/home/ci/work/app/user_test.go", 4); err != nil || got != "age >= 18" {
+		t.Errorf("ExtractExpression() of a relocated file = %q, %v; want %q", got, err, "age >= 18")
+	}
+	if _, err := ExtractExpression("/home/ci/work/app/missing_test.go", 4); !os.IsNotExist(err) {
+		t.Errorf("ExtractExpression() of a missing file should fail with a not-exist error, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"bufio"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+)
+
+// ResolveSource returns the path the source file named by a runtime frame can be read from.
+// Frames name files as they were when the test binary was built, which may not exist where
+// it runs: -trimpath builds name them by import path, such as example.com/app/user_test.go,
+// and sandboxed or relocated builds by paths of another machine. Missing files are looked up
+//
+//   - under each directory listed in DIAGASSERT_SRC_ROOT,
+//   - in the module enclosing the working directory, by import path,
+//   - in the module cache, for paths such as example.com/lib@v1.2.0/check.go,
+//   - in the working directory, the package directory under go test,
+//
+// each time trying the longest trailing part of the path first. The name is returned
+// unchanged when nothing is found.
+func ResolveSource(filename string) string {
+	if exists(filename) {
+		return filename
+	}
+	parts := strings.Split(strings.TrimPrefix(filepath.ToSlash(filename), "/"), "/")
+
+	for _, root := range filepath.SplitList(config.Getenv("DIAGASSERT_SRC_ROOT")) {
+		if path, ok := underRoot(root, parts); ok {
+			return path
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return filename
+	}
+	if dir, module := enclosingModule(wd); module != "" {
+		slashed := filepath.ToSlash(filename)
+		if rest := strings.TrimPrefix(slashed, module+"/"); rest != slashed {
+			if path := filepath.Join(dir, filepath.FromSlash(rest)); exists(path) {
+				return path
+			}
+		}
+	}
+
+	if strings.Contains(filename, "@") {
+		cache := os.Getenv("GOMODCACHE")
+		if cache == "" {
+			cache = filepath.Join(build.Default.GOPATH, "pkg", "mod")
+		}
+		if path := filepath.Join(cache, filepath.FromSlash(filepath.ToSlash(filename))); exists(path) {
+			return path
+		}
+	}
+
+	if path, ok := underRoot(wd, parts); ok {
+		return path
+	}
+	return filename
+}
+
+// underRoot finds the longest trailing part of a path that exists under root.
+func underRoot(root string, parts []string) (string, bool) {
+	if root == "" {
+		return "", false
+	}
+	for i := range parts {
+		if path := filepath.Join(root, filepath.Join(parts[i:]...)); exists(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// enclosingModule returns the directory and path of the module whose go.mod is in dir or
+// one of its parents.
+func enclosingModule(dir string) (string, string) {
+	for {
+		if f, err := os.Open(filepath.Join(dir, "go.mod")); err == nil {
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if strings.HasPrefix(line, "module ") {
+					return dir, strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`)
+				}
+			}
+			return dir, ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+func exists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
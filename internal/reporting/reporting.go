@@ -0,0 +1,39 @@
+// Package reporting lets helper packages such as httpassert and dbassert report failures
+// through package diagassert, so that failure hooks and Retry see them like any failed Assert.
+package reporting
+
+// TestingT mirrors diagassert.TestingT, which this package cannot import.
+type TestingT interface {
+	Error(args ...interface{})
+	Fatal(args ...interface{})
+	Helper()
+}
+
+// Failure is a failed helper assertion with its rendered diagnostic output.
+type Failure struct {
+	File        string
+	Line        int
+	Expression  string
+	Variables   map[string]interface{}
+	Steps       []string
+	Output      string
+	Fingerprint string // formatter.Fingerprint of the failure, for DIAGASSERT_HISTORY
+}
+
+// reporter is replaced by package diagassert when it is initialized. Every helper package
+// imports diagassert, so the default is only used by this package's own callers.
+var reporter = func(t TestingT, failure Failure) {
+	t.Helper()
+	t.Error(failure.Output)
+}
+
+// SetReporter installs the function that reports helper failures.
+func SetReporter(fn func(t TestingT, failure Failure)) {
+	reporter = fn
+}
+
+// Report reports a failure to t.
+func Report(t TestingT, failure Failure) {
+	t.Helper()
+	reporter(t, failure)
+}
@@ -0,0 +1,419 @@
+// Package repro writes standalone tests that reproduce a failed assertion from the values
+// it captured, declared as Go literals.
+package repro
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/types"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+// diagassertPath is the import path of the package the reproduced test asserts with.
+const diagassertPath = "github.com/paveg/diagassert"
+
+// Failure is a failed assertion to reproduce.
+type Failure struct {
+	Package   string                 // Package clause of the test file, e.g. "order_test"
+	PkgPath   string                 // Import path of the test's package; its types are not qualified
+	File      string                 // Base name of the test file
+	Line      int                    // Line of the assertion
+	Expr      string                 // Asserted expression
+	Variables map[string]interface{} // Values captured by name path, such as x or tc.in
+	Imports   map[string]string      // Import paths of the test file, by package name
+}
+
+// TestName is the name of the test reproducing a failure at file:line, e.g.
+// TestRepro_order_test_42.
+func TestName(file string, line int) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSuffix(file, ".go"))
+	return fmt.Sprintf("TestRepro_%s_%d", name, line)
+}
+
+// Source returns a test file asserting the expression again, with every name path whose
+// value was captured replaced by a variable declared as a literal of that value. Names
+// that were not captured, or whose values have no literal, are listed in a comment to be
+// declared by hand.
+func Source(f Failure) ([]byte, error) {
+	expr, err := parser.ParseExpr(f.Expr)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &literalWriter{pkgPath: f.PkgPath, imports: map[string]string{}}
+	if f.PkgPath != diagassertPath {
+		w.imports[diagassertPath] = "diagassert"
+	}
+
+	type replacement struct {
+		start, end int
+		name       string
+	}
+	var (
+		replacements []replacement
+		decls        []string
+		missing      []string
+		declared     = map[string]string{}
+	)
+	note := func(name string) {
+		for _, m := range missing {
+			if m == name {
+				return
+			}
+		}
+		missing = append(missing, name)
+	}
+
+	// visit replaces the captured name paths under node, leaving called functions alone:
+	// they are declared in the package or imported
+	var visit func(node ast.Node, called bool)
+	visit = func(node ast.Node, called bool) {
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch e := n.(type) {
+			case *ast.CallExpr:
+				visit(e.Fun, true)
+				for _, arg := range e.Args {
+					visit(arg, false)
+				}
+				return false
+			case *ast.CompositeLit:
+				// The literal's type is declared in the package or imported
+				for _, elt := range e.Elts {
+					visit(elt, false)
+				}
+				return false
+			case *ast.KeyValueExpr:
+				visit(e.Value, false)
+				return false
+			case *ast.SelectorExpr, *ast.Ident:
+				text := f.Expr[e.Pos()-1 : e.End()-1]
+				if name, ok := declared[text]; ok {
+					replacements = append(replacements, replacement{int(e.Pos()) - 1, int(e.End()) - 1, name})
+					return false
+				}
+				if value, ok := f.Variables[text]; ok && isNamePath(e.(ast.Expr)) {
+					// A value without a literal must not leave the imports of its types behind
+					imports := make(map[string]string, len(w.imports))
+					for path, name := range w.imports {
+						imports[path] = name
+					}
+					if lit, ok := w.literal(reflect.ValueOf(value)); ok {
+						name := variableName(text)
+						declared[text] = name
+						decls = append(decls, fmt.Sprintf("%s := %s", name, lit))
+						replacements = append(replacements, replacement{int(e.Pos()) - 1, int(e.End()) - 1, name})
+						return false
+					}
+					w.imports = imports
+				}
+				if sel, ok := e.(*ast.SelectorExpr); ok {
+					visit(sel.X, false)
+					return false
+				}
+				ident := e.(*ast.Ident)
+				if path, ok := f.Imports[ident.Name]; ok {
+					w.imports[path] = ident.Name
+				} else if !called && types.Universe.Lookup(ident.Name) == nil {
+					note(ident.Name)
+				}
+				return false
+			}
+			return true
+		})
+	}
+	visit(expr, false)
+
+	// Replace from the end so earlier offsets stay valid
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].start > replacements[j].start })
+	asserted := f.Expr
+	for _, r := range replacements {
+		asserted = asserted[:r.start] + r.name + asserted[r.end:]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s reproduces the failure at %s:%d from the values it captured.\n", TestName(f.File, f.Line), f.File, f.Line)
+	fmt.Fprintf(&b, "// Copy this file into the directory of %s and run it with\n", f.File)
+	fmt.Fprintf(&b, "//\n//\tgo test -run %s\n//\n", TestName(f.File, f.Line))
+	fmt.Fprintf(&b, "// The assertion was:\n//\n//\t%s\n\n", f.Expr)
+	fmt.Fprintf(&b, "package %s\n\n", f.Package)
+
+	paths := []string{"testing"}
+	for path := range w.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	b.WriteString("import (\n")
+	for _, path := range paths {
+		if name, ok := w.imports[path]; ok && name != lastElement(path) {
+			fmt.Fprintf(&b, "\t%s %q\n", name, path)
+			continue
+		}
+		fmt.Fprintf(&b, "\t%q\n", path)
+	}
+	b.WriteString(")\n\n")
+
+	fmt.Fprintf(&b, "func %s(t *testing.T) {\n", TestName(f.File, f.Line))
+	for _, name := range missing {
+		fmt.Fprintf(&b, "\t// TODO: %s was not captured; declare it here\n", name)
+	}
+	for _, decl := range decls {
+		b.WriteString("\t" + decl + "\n")
+	}
+	assert := "diagassert.Assert"
+	if f.PkgPath == diagassertPath {
+		assert = "Assert"
+	}
+	fmt.Fprintf(&b, "\t%s(t, %s)\n}\n", assert, asserted)
+
+	return format.Source([]byte(b.String()))
+}
+
+// isNamePath reports whether expr is an identifier or a chain of field selections on one,
+// such as tc.in.
+func isNamePath(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return isNamePath(e.X)
+	}
+	return false
+}
+
+// variableName names the variable standing in for a name path: tc.in becomes tc_in.
+func variableName(path string) string {
+	return strings.ReplaceAll(path, ".", "_")
+}
+
+// lastElement returns the last element of an import path, the usual package name.
+func lastElement(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// maxLiteralDepth bounds how deeply values are nested in the literals written for them.
+const maxLiteralDepth = 32
+
+// literalWriter writes values as Go literals for a file in the package pkgPath, collecting
+// the imports the types they name need, as import path to package name. visiting holds the
+// pointers and maps being written, which have no literal when they contain themselves.
+type literalWriter struct {
+	pkgPath  string
+	imports  map[string]string
+	visiting map[uintptr]bool
+	depth    int
+}
+
+// redactedType is the type of masked values, which cannot be reproduced.
+var redactedType = reflect.TypeOf(evaluator.Redacted(""))
+
+// literal returns the Go expression for v, or false for values that have none, such as
+// functions, channels, masked values, fields unexported by another package and values that
+// contain themselves or are nested more than maxLiteralDepth deep.
+func (w *literalWriter) literal(v reflect.Value) (string, bool) {
+	if !v.IsValid() || v.Type() == redactedType || w.depth >= maxLiteralDepth {
+		return "", false
+	}
+	w.depth++
+	defer func() { w.depth-- }()
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Map) && !v.IsNil() {
+		if w.visiting[v.Pointer()] {
+			return "", false
+		}
+		if w.visiting == nil {
+			w.visiting = map[uintptr]bool{}
+		}
+		w.visiting[v.Pointer()] = true
+		defer delete(w.visiting, v.Pointer())
+	}
+	typ, ok := w.typeText(v.Type())
+	if !ok {
+		return "", false
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return w.basic(v.Type(), strconv.FormatBool(v.Bool()), "bool"), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return w.basic(v.Type(), strconv.FormatInt(v.Int(), 10), "int"), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return w.basic(v.Type(), strconv.FormatUint(v.Uint(), 10), ""), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", false
+		}
+		text := strconv.FormatFloat(f, 'g', -1, v.Type().Bits())
+		if !strings.ContainsAny(text, ".e") {
+			text += ".0"
+		}
+		return w.basic(v.Type(), text, "float64"), true
+	case reflect.String:
+		return w.basic(v.Type(), strconv.Quote(v.String()), "string"), true
+	case reflect.Interface:
+		if v.IsNil() {
+			return "nil", true
+		}
+		return w.literal(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "(" + typ + ")(nil)", true
+		}
+		switch v.Elem().Kind() {
+		case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+			elem, ok := w.literal(v.Elem())
+			return "&" + elem, ok
+		}
+		return "", false
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return typ + "(nil)", true
+		}
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elem, ok := w.literal(v.Index(i))
+			if !ok {
+				return "", false
+			}
+			elems[i] = w.elide(elem, v.Type().Elem())
+		}
+		return typ + "{" + strings.Join(elems, ", ") + "}", true
+	case reflect.Map:
+		if v.IsNil() {
+			return typ + "(nil)", true
+		}
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, ok := w.literal(iter.Key())
+			if !ok {
+				return "", false
+			}
+			value, ok := w.literal(iter.Value())
+			if !ok {
+				return "", false
+			}
+			entries = append(entries, w.elide(key, v.Type().Key())+": "+w.elide(value, v.Type().Elem()))
+		}
+		sort.Strings(entries)
+		return typ + "{" + strings.Join(entries, ", ") + "}", true
+	case reflect.Struct:
+		v = evaluator.Addressable(v)
+		var fields []string
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			value := v.Field(i)
+			if value.IsZero() {
+				continue
+			}
+			if field.PkgPath != "" && field.PkgPath != w.pkgPath {
+				return "", false
+			}
+			lit, ok := w.literal(evaluator.Settable(value))
+			if !ok {
+				return "", false
+			}
+			fields = append(fields, field.Name+": "+lit)
+		}
+		return typ + "{" + strings.Join(fields, ", ") + "}", true
+	}
+	return "", false
+}
+
+// elide drops the type of a composite literal nested in one of a collection of that type,
+// as gofmt -s does: []Item{{Name: "a"}} rather than []Item{Item{Name: "a"}}.
+func (w *literalWriter) elide(lit string, elem reflect.Type) string {
+	typ, ok := w.typeText(elem)
+	if ok && strings.HasPrefix(lit, typ+"{") {
+		return lit[len(typ):]
+	}
+	return lit
+}
+
+// basic writes a constant of a basic kind, converted unless its type is the default one
+// for the constant, e.g. 5 for an int but uint8(5) or Celsius(5).
+func (w *literalWriter) basic(t reflect.Type, text, defaultType string) string {
+	if t.Name() == defaultType && t.PkgPath() == "" {
+		return text
+	}
+	typ, _ := w.typeText(t)
+	return typ + "(" + text + ")"
+}
+
+// typeText returns the Go source of a type, qualifying the types of other packages.
+func (w *literalWriter) typeText(t reflect.Type) (string, bool) {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name(), true
+		}
+		// Instantiated generic types have no source form reflect could give
+		if strings.Contains(t.Name(), "[") {
+			return "", false
+		}
+		if t.PkgPath() == w.pkgPath {
+			return t.Name(), true
+		}
+		if !ast.IsExported(t.Name()) {
+			return "", false
+		}
+		pkg := strings.SplitN(t.String(), ".", 2)[0]
+		w.imports[t.PkgPath()] = pkg
+		return pkg + "." + t.Name(), true
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		elem, ok := w.typeText(t.Elem())
+		return "[]" + elem, ok
+	case reflect.Array:
+		elem, ok := w.typeText(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), ok
+	case reflect.Ptr:
+		elem, ok := w.typeText(t.Elem())
+		return "*" + elem, ok
+	case reflect.Map:
+		key, ok := w.typeText(t.Key())
+		if !ok {
+			return "", false
+		}
+		elem, ok := w.typeText(t.Elem())
+		return "map[" + key + "]" + elem, ok
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", true
+		}
+	case reflect.Struct:
+		fields := make([]string, t.NumField())
+		for i := range fields {
+			field := t.Field(i)
+			if field.PkgPath != "" && field.PkgPath != w.pkgPath {
+				return "", false
+			}
+			typ, ok := w.typeText(field.Type)
+			if !ok {
+				return "", false
+			}
+			fields[i] = typ
+			if !field.Anonymous {
+				fields[i] = field.Name + " " + typ
+			}
+			if field.Tag != "" {
+				fields[i] += " " + strconv.Quote(string(field.Tag))
+			}
+		}
+		return "struct{ " + strings.Join(fields, "; ") + " }", true
+	}
+	return "", false
+}
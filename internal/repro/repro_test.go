@@ -0,0 +1,132 @@
+package repro
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+)
+
+type item struct {
+	Name  string
+	price int
+}
+
+type celsius float64
+
+func TestSource(t *testing.T) {
+	src, err := Source(Failure{
+		Package: "shop_test",
+		PkgPath: "github.com/paveg/diagassert/internal/repro",
+		File:    "shop_test.go",
+		Line:    42,
+		Expr:    `total(items) == want && strings.HasPrefix(tc.name, "a") && cart.Open()`,
+		Variables: map[string]interface{}{
+			"items":   []item{{Name: "pen", price: 3}},
+			"want":    4,
+			"tc.name": "bob",
+		},
+		Imports: map[string]string{"strings": "strings"},
+	})
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "repro_test.go", src, 0); err != nil {
+		t.Fatalf("Source should be valid Go: %v\n%s", err, src)
+	}
+
+	expected := []string{
+		"package shop_test",
+		"//\tgo test -run TestRepro_shop_test_42",
+		"\t\"github.com/paveg/diagassert\"\n",
+		"\t\"strings\"\n",
+		"// TODO: cart was not captured; declare it here",
+		`items := []item{{Name: "pen", price: 3}}`,
+		"want := 4",
+		`tc_name := "bob"`,
+		`diagassert.Assert(t, total(items) == want && strings.HasPrefix(tc_name, "a") && cart.Open())`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Source should contain %q, got:\n%s", want, src)
+		}
+	}
+	if strings.Contains(string(src), "TODO: total") || strings.Contains(string(src), "TODO: strings") {
+		t.Errorf("called functions and imports are not missing, got:\n%s", src)
+	}
+}
+
+func TestSource_UnreproducibleValues(t *testing.T) {
+	src, err := Source(Failure{
+		Package:   "shop",
+		PkgPath:   "example.com/shop",
+		File:      "shop_test.go",
+		Line:      7,
+		Expr:      "token == secret && done != nil",
+		Variables: map[string]interface{}{"token": evaluator.Redacted("***"), "secret": "s", "done": make(chan int)},
+	})
+	if err != nil {
+		t.Fatalf("Source: %v", err)
+	}
+	for _, want := range []string{"TODO: token was not captured", "TODO: done was not captured", `secret := "s"`} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("Source should contain %q, got:\n%s", want, src)
+		}
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	w := &literalWriter{pkgPath: "github.com/paveg/diagassert/internal/repro", imports: map[string]string{}}
+	var nilItems []item
+
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{5, "5"},
+		{uint8(7), "uint8(7)"},
+		{2.0, "2.0"},
+		{float32(1.5), "float32(1.5)"},
+		{celsius(21), "celsius(21.0)"},
+		{"a\"b", `"a\"b"`},
+		{true, "true"},
+		{[]int{1, 2}, "[]int{1, 2}"},
+		{[2]string{"a", "b"}, `[2]string{"a", "b"}`},
+		{nilItems, "[]item(nil)"},
+		{map[string]int{"b": 2, "a": 1}, `map[string]int{"a": 1, "b": 2}`},
+		{&item{Name: "pen"}, `&item{Name: "pen"}`},
+		{(*item)(nil), "(*item)(nil)"},
+		{[]interface{}{1, "a", nil}, `[]interface{}{1, "a", nil}`},
+		{map[item]bool{{Name: "x"}: true}, `map[item]bool{{Name: "x"}: true}`},
+		{struct{ N int }{3}, "struct{ N int }{N: 3}"},
+		{3 * time.Second, "time.Duration(3000000000)"},
+	}
+
+	for _, tt := range tests {
+		got, ok := w.literal(reflect.ValueOf(tt.value))
+		if !ok || got != tt.want {
+			t.Errorf("literal(%#v) = %q, %v, want %q", tt.value, got, ok, tt.want)
+		}
+	}
+	if w.imports["time"] != "time" {
+		t.Errorf("time.Duration should import time, got %v", w.imports)
+	}
+
+	type link struct{ Next *link }
+	cycle := &link{}
+	cycle.Next = cycle
+	deep := &link{}
+	for i := 0; i < maxLiteralDepth; i++ {
+		deep = &link{Next: deep}
+	}
+
+	for _, value := range []interface{}{func() {}, make(chan int), time.Now(), evaluator.Redacted("***"), cycle, deep} {
+		if got, ok := w.literal(reflect.ValueOf(value)); ok {
+			t.Errorf("literal(%T) = %q, want none", value, got)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Package textwidth measures text in terminal columns, which the formatter lines diagrams up
+// in and the evaluator aligns its string differences by.
+package textwidth
+
+import "unicode"
+
+// Wide reports whether r takes two columns: kana, Han, Hangul and the fullwidth forms.
+func Wide(r rune) bool {
+	return unicode.In(r,
+		unicode.Hiragana,
+		unicode.Katakana,
+		unicode.Han,
+		unicode.Hangul,
+	) || (r >= 0xFF00 && r <= 0xFFEF)
+}
+
+// String returns the number of columns s takes: two for each wide rune, one for the others.
+func String(s string) int {
+	width := 0
+	for _, r := range s {
+		if Wide(r) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
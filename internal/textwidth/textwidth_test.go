@@ -0,0 +1,23 @@
+package textwidth
+
+import "testing"
+
+func TestString(t *testing.T) {
+	tests := map[string]int{
+		"":             0,
+		"x > 20":       6,
+		"café":         4,
+		"名前":           4,
+		"名前:":          5,
+		"こんにちは":        10,
+		"ｈｅｌｌｏ":        10,
+		"한국어":          6,
+		`"a\tc"`:       6,
+		"user.名前 == x": 14,
+	}
+	for s, want := range tests {
+		if got := String(s); got != want {
+			t.Errorf("String(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
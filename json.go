@@ -0,0 +1,88 @@
+package diagassert
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/jsonpath"
+)
+
+// AssertJSON evaluates expr against a JSON document and outputs detailed diagnostic
+// information if it is false. Inside expr, "$" is the document and members are selected
+// with Go syntax, so the power-assert diagram shows the value under each path segment:
+//
+//	AssertJSON(t, body, `$.items[0].id == 42`)
+//	AssertJSON(t, body, `len($.items) > 0 && $.meta["next"] == nil`)
+//
+// doc may be raw JSON ([]byte, string or json.RawMessage) or any value that encodes to
+// JSON. Numbers are decoded as float64 and compare with integer literals by value.
+// Trailing args are handled as in Assert.
+func AssertJSON(t TestingT, doc interface{}, expr string, args ...interface{}) {
+	t.Helper()
+
+	decoded, err := decodeJSONDocument(doc)
+	if err != nil {
+		failure := buildJSONFailureInfo(expr, nil, newContext(t, args))
+		failure.Output = fmt.Sprintf("%s\n(unable to decode JSON document: %v)",
+			formatter.Message(formatter.MsgAssertionFailed, filepath.Base(failure.File), failure.Line), err)
+		reportFailure(t, failure, false)
+		return
+	}
+
+	result := evaluator.EvaluateJSON(expr, decoded)
+	if result.Result {
+		return
+	}
+
+	failure := buildJSONFailureInfo(expr, result, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// decodeJSONDocument decodes raw JSON, or round-trips any other value through encoding/json
+// so that structs and maps are seen exactly as their JSON encoding.
+func decodeJSONDocument(doc interface{}) (interface{}, error) {
+	var raw []byte
+	switch d := doc.(type) {
+	case []byte:
+		raw = d
+	case json.RawMessage:
+		raw = d
+	case string:
+		raw = []byte(d)
+	default:
+		return jsonpath.Normalize(doc)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// buildJSONFailureInfo builds diagnostic information for a failed AssertJSON.
+// result is nil when the document could not be decoded.
+func buildJSONFailureInfo(expr string, result *evaluator.ExpressionResult, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+	failure := FailureInfo{File: file, Line: line, Expression: expr, Messages: ctx.Messages, Stack: site.stack, writers: ctx.Writers, maxRepeats: ctx.MaxRepeats}
+	if result == nil {
+		return failure
+	}
+
+	evaluator.Redact(result)
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	failure.Attachments = ctx.Attachments
+	failure.Variables = result.Variables
+	failure.tree = result.Tree
+	failure.fingerprint = formatter.Fingerprint(result, file, line)
+	failure.Output = formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+		toFormatterContext(ctx), formatter.GetDefaultOptions())
+
+	return failure
+}
@@ -0,0 +1,58 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestAssertJSON(t *testing.T) {
+	const body = `{"items":[{"id":41,"name":"apple"}],"total":1}`
+
+	t.Run("passing expressions", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertJSON(mock, body, `$.items[0].id == 41 && $.items[0].name == "apple"`)
+		AssertJSON(mock, []byte(body), "len($.items) == $.total")
+		AssertJSON(mock, map[string]interface{}{"ok": true}, "$.ok")
+
+		if mock.Failed() {
+			t.Errorf("AssertJSON should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure shows selected values", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		AssertJSON(mock, body, "$.items[0].id == 42", "wrong item")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at json_test.go:",
+			"assert($.items[0].id == 42)",
+			"LIKELY CAUSE: $.items[0].id == 42 is false because $.items[0].id = 41",
+			"CUSTOM MESSAGE:\nwrong item",
+			"EXPR: $.items[0].id == 42",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("invalid document", func(t *testing.T) {
+		var got FailureInfo
+		remove := OnFailure(func(f FailureInfo) { got = f })
+		defer remove()
+
+		mock := testutil.NewMockT()
+		AssertJSON(mock, `{"items":`, "$.total == 1")
+
+		if !strings.Contains(mock.GetOutput(), "unable to decode JSON document") {
+			t.Errorf("Should report the decoding error, got: %s", mock.GetOutput())
+		}
+		if got.Expression != "$.total == 1" || !strings.HasSuffix(got.File, "json_test.go") {
+			t.Errorf("Hook should receive the failure, got %+v", got)
+		}
+	})
+}
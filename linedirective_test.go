@@ -0,0 +1,30 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestAssert_LineDirective(t *testing.T) {
+	mock := testutil.NewMockT()
+	assertGenerated(mock, 0)
+
+	output := mock.GetOutput()
+	expected := []string{
+		"ASSERTION FAILED at generated_rules.tmpl:40",
+		"assert(total > 0)",
+	}
+	for _, part := range expected {
+		if !strings.Contains(output, part) {
+			t.Errorf("Output should contain %q, got: %s", part, output)
+		}
+	}
+}
+
+// assertGenerated asserts as code generated from a template does, at the location its line
+// directive gives. It is last in the file, as the directive applies to the lines after it.
+func assertGenerated(t TestingT, total int) {
+	/*line generated_rules.tmpl:40:1*/ Assert(t, total > 0, V("total", total))
+}
@@ -0,0 +1,498 @@
+// Package logparse extracts failed assertions from saved `go test` output by reading the
+// machine-readable blocks diagassert writes between [MACHINE_READABLE_START] and
+// [MACHINE_READABLE_END].
+//
+// It accepts plain `go test` and `go test -v` output, `go test -json` events, CI logs that
+// prefix every line with timestamps or colors, and diagnostics reported as single lines with
+// DIAGASSERT_OUTPUT_ENCODING. Blocks are read in the text and JSON formats of
+// DIAGASSERT_MACHINE_FORMAT.
+//
+// Usage:
+//
+//	failures, err := logparse.Parse(file)
+//	for _, f := range failures {
+//		fmt.Printf("%s %s:%d: %s\n", f.Test, f.File, f.Line, f.Expr)
+//	}
+package logparse
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+const (
+	blockStart = "[MACHINE_READABLE_START]"
+	blockEnd   = "[MACHINE_READABLE_END]"
+)
+
+// Failure is one failed assertion read from a machine-readable block.
+type Failure struct {
+	Test          string              `json:"test,omitempty"` // Test that reported the failure, when the log names it
+	File          string              `json:"file,omitempty"` // From LOCATION, or the "ASSERTION FAILED at" header before older blocks
+	Line          int                 `json:"line,omitempty"`
+	Fingerprint   string              `json:"fingerprint,omitempty"` // Identifies the failure across runs: expression, failing operand and location
+	History       string              `json:"history,omitempty"`     // From HISTORY, as "3/10": runs it failed in of the last ones recorded
+	Owners        []string            `json:"owners,omitempty"`      // From OWNERS, as listed in CODEOWNERS
+	SchemaVersion int                 `json:"schema_version"`        // 0 for blocks written before versioning
+	Expr          string              `json:"expr"`
+	ExprID        string              `json:"expr_id,omitempty"`
+	Result        string              `json:"result,omitempty"`
+	Variables     map[string]string   `json:"variables,omitempty"`
+	Steps         []Step              `json:"steps,omitempty"`
+	ShortCircuits []string            `json:"short_circuits,omitempty"`
+	Reason        string              `json:"reason,omitempty"`
+	FailingNode   string              `json:"failing_node,omitempty"`
+	FailingNodeID string              `json:"failing_node_id,omitempty"`
+	Contents      []string            `json:"contents,omitempty"` // Previews of containers, as "items (2 elements): [1 2]"
+	Notes         []string            `json:"notes,omitempty"`
+	Errors        []string            `json:"errors,omitempty"` // Errors returned by calls, as "p.Parse(s): invalid syntax"
+	Diffs         []string            `json:"diffs,omitempty"`
+	Message       string              `json:"message,omitempty"`
+	Values        []Value             `json:"values,omitempty"`
+	Attachments   []string            `json:"attachments,omitempty"`
+	Sections      map[string][]string `json:"sections,omitempty"` // Sections such as HTTP_RESPONSE, SEED or ALLOCS, by marker
+	Lines         []string            `json:"-"`                  // Lines of the block without its markers
+}
+
+// Step is one entry of EVALUATION_STEPS.
+type Step struct {
+	Text   string `json:"text"`              // e.g. "`x > 10` with 5 > 10 => false"
+	NodeID string `json:"node_id,omitempty"` // Stable node ID, from schema version 1
+}
+
+// Value is a value captured with V() or Values{}.
+type Value struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+var (
+	headerLine = regexp.MustCompile(`ASSERTION FAILED at (\S+):(\d+)`)
+	testLine   = regexp.MustCompile(`^\s*(?:=== RUN|=== CONT|--- FAIL:)\s+(\S+)`)
+	stepLine   = regexp.MustCompile(`^Step \d+: (.*?)(?: \[node ([0-9a-f]+)\])?$`)
+	valueLine  = regexp.MustCompile(`^(.*?) = (.*) \(([^()]*)\)$`)
+)
+
+// Parse reads a log and returns its failures in the order they were reported.
+func Parse(r io.Reader) ([]Failure, error) {
+	p := &logParser{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		p.feed(scanner.Text(), "")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p.failures, nil
+}
+
+// ParseString is Parse for a log held in memory.
+func ParseString(log string) []Failure {
+	failures, _ := Parse(strings.NewReader(log))
+	return failures
+}
+
+// logParser is a line-by-line state machine. Lines outside a block only update the test
+// name and the location of the latest header; lines inside a block are collected until
+// its end marker.
+type logParser struct {
+	failures []Failure
+
+	test   string
+	file   string
+	line   int
+	indent string // Prefix go test added before the start marker, removed from block lines
+	block  []string
+	inside bool
+}
+
+func (p *logParser) feed(raw, test string) {
+	text := formatter.StripColors(strings.TrimRight(raw, "\r\n"))
+
+	// go test -json wraps every output line in an event
+	if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "{") {
+		var event struct {
+			Test   string
+			Output string
+		}
+		if json.Unmarshal([]byte(trimmed), &event) == nil && event.Output != "" {
+			for _, line := range strings.Split(strings.TrimRight(event.Output, "\n"), "\n") {
+				p.feed(line, event.Test)
+			}
+			return
+		}
+	}
+
+	// Diagnostics reported with DIAGASSERT_OUTPUT_ENCODING carry the whole output on one line
+	if decoded, ok := diagassert.DecodeOutput(text); ok {
+		for _, line := range strings.Split(decoded, "\n") {
+			p.feed(line, test)
+		}
+		return
+	}
+
+	if p.inside {
+		p.blockLine(text)
+		return
+	}
+
+	if test != "" {
+		p.test = test
+	} else if m := testLine.FindStringSubmatch(text); m != nil {
+		p.test = m[1]
+	}
+
+	if m := headerLine.FindStringSubmatch(text); m != nil {
+		p.file = m[1]
+		p.line, _ = strconv.Atoi(m[2])
+	}
+
+	if i := strings.Index(text, blockStart); i >= 0 {
+		p.inside = true
+		p.indent = text[:i]
+		p.block = nil
+	}
+}
+
+func (p *logParser) blockLine(text string) {
+	if strings.Contains(text, blockEnd) {
+		failure := parseBlock(p.block)
+		failure.Test = p.test
+		if failure.File == "" {
+			failure.File, failure.Line = p.file, p.line
+		}
+		p.failures = append(p.failures, failure)
+
+		p.inside = false
+		p.file, p.line = "", 0
+		return
+	}
+
+	p.block = append(p.block, strings.TrimPrefix(text, p.indent))
+}
+
+// parseBlock reads the keys of one machine-readable block. Unknown keys are ignored so that
+// newer schema versions can still be read.
+func parseBlock(lines []string) Failure {
+	if len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[0]), "{") {
+		if f, ok := parseJSONBlock(lines); ok {
+			return f
+		}
+	}
+
+	f := Failure{Lines: lines}
+
+	var section string // Marker of the open <TITLE>_START ... <TITLE>_END section
+	inSteps := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if section != "" {
+			if trimmed == section+"_END" {
+				section = ""
+				continue
+			}
+			switch section {
+			case "CAPTURED_VALUES":
+				if v, ok := strings.CutPrefix(trimmed, "VALUE: "); ok {
+					f.Values = append(f.Values, parseValue(v))
+				}
+			case "ATTACHMENTS":
+				if a, ok := strings.CutPrefix(trimmed, "ATTACHMENT: "); ok {
+					f.Attachments = append(f.Attachments, unquoteText(a))
+				}
+			case "LINE_DIFF":
+				// Line diffs repeat the DIFF entries in another layout
+			default:
+				f.Sections[section] = append(f.Sections[section], unquoteText(line))
+			}
+			continue
+		}
+
+		if inSteps && strings.HasPrefix(trimmed, "Step ") {
+			if m := stepLine.FindStringSubmatch(trimmed); m != nil {
+				f.Steps = append(f.Steps, Step{Text: unquoteText(m[1]), NodeID: m[2]})
+			}
+			continue
+		}
+		inSteps = false
+
+		key, value, found := strings.Cut(trimmed, ": ")
+		if !found {
+			key = strings.TrimSuffix(trimmed, ":")
+		}
+		value = unquoteText(value)
+
+		switch key {
+		case "SCHEMA_VERSION":
+			f.SchemaVersion, _ = strconv.Atoi(value)
+		case "EXPR":
+			f.Expr = value
+		case "EXPR_ID":
+			f.ExprID = value
+		case "RESULT":
+			f.Result = value
+		case "LOCATION":
+			if i := strings.LastIndex(value, ":"); i >= 0 {
+				f.File = value[:i]
+				f.Line, _ = strconv.Atoi(value[i+1:])
+			}
+		case "FINGERPRINT":
+			f.Fingerprint = value
+		case "HISTORY":
+			f.History = value
+		case "OWNERS":
+			f.Owners = strings.Fields(value)
+		case "VARIABLES":
+			f.Variables = parseVariables(value)
+		case "EVALUATION_STEPS":
+			inSteps = true
+		case "SHORT_CIRCUIT":
+			f.ShortCircuits = append(f.ShortCircuits, value)
+		case "FAILURE_REASON":
+			f.Reason = value
+		case "FAILING_NODE":
+			f.FailingNode = value
+		case "FAILING_NODE_ID":
+			f.FailingNodeID = value
+		case "CONTENTS":
+			f.Contents = append(f.Contents, value)
+		case "NOTE":
+			f.Notes = append(f.Notes, value)
+		case "ERROR":
+			f.Errors = append(f.Errors, value)
+		case "DIFF":
+			f.Diffs = append(f.Diffs, value)
+		case "CUSTOM_MESSAGE":
+			f.Message = value
+		case "LINE_DIFF_START":
+			section = "LINE_DIFF"
+		default:
+			if marker, ok := strings.CutSuffix(trimmed, "_START"); ok && !found {
+				section = marker
+				if section != "CAPTURED_VALUES" && section != "ATTACHMENTS" {
+					if f.Sections == nil {
+						f.Sections = map[string][]string{}
+					}
+					f.Sections[section] = []string{}
+				}
+			}
+		}
+	}
+
+	return f
+}
+
+// parseJSONBlock reads a block written with DIAGASSERT_MACHINE_FORMAT=json.
+func parseJSONBlock(lines []string) (Failure, bool) {
+	var r formatter.MachineRecord
+	if json.Unmarshal([]byte(strings.Join(lines, "\n")), &r) != nil {
+		return Failure{}, false
+	}
+
+	f := Failure{
+		Lines:         lines,
+		File:          r.File,
+		Line:          r.Line,
+		Fingerprint:   r.Fingerprint,
+		Owners:        r.Owners,
+		SchemaVersion: r.SchemaVersion,
+		Expr:          r.Expr,
+		ExprID:        r.ExprID,
+		Result:        strconv.FormatBool(r.Result),
+		Variables:     r.Variables,
+		ShortCircuits: r.ShortCircuits,
+		Contents:      r.Contents,
+		Notes:         r.Notes,
+		Errors:        r.Errors,
+		Message:       r.Message,
+	}
+	if r.History != nil {
+		f.History = fmt.Sprintf("%d/%d", r.History.Failed, r.History.Runs)
+	}
+	for _, step := range r.Steps {
+		f.Steps = append(f.Steps, Step{Text: step.Text, NodeID: step.NodeID})
+	}
+	if r.Failing != nil {
+		f.Reason, f.FailingNode, f.FailingNodeID = r.Failing.Reason, r.Failing.Node, r.Failing.NodeID
+	}
+	for _, diff := range r.Diffs {
+		for _, difference := range diff.Differences {
+			f.Diffs = append(f.Diffs, diff.Expr+": "+difference)
+		}
+	}
+	for _, v := range r.Values {
+		f.Values = append(f.Values, Value{Name: v.Name, Value: v.Value, Type: v.Type})
+	}
+	for _, a := range r.Attachments {
+		f.Attachments = append(f.Attachments, fmt.Sprintf("%s (%s, %d bytes) => %s", a.Name, a.MIME, a.Size, a.Path))
+	}
+	for _, section := range r.Sections {
+		if f.Sections == nil {
+			f.Sections = map[string][]string{}
+		}
+		f.Sections[strings.ReplaceAll(section.Title, " ", "_")] = section.Lines
+	}
+	return f, true
+}
+
+// parseVariables splits "a=1,b=<b>". Values containing commas cannot be told apart from
+// separators, so a part without "=" is joined to the previous value.
+func parseVariables(s string) map[string]string {
+	vars := map[string]string{}
+	last := ""
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || last != "" && !isName(name) {
+			if last != "" {
+				vars[last] += "," + part
+			}
+			continue
+		}
+		vars[name] = value
+		last = name
+	}
+	// Quoted values may hold commas, so they are unquoted once joined again
+	for name, value := range vars {
+		vars[name] = unquoteText(value)
+	}
+	return vars
+}
+
+// unquoteText reads a value of the text format, which quotes values holding newlines and
+// other control characters or invalid UTF-8 as Go does.
+func unquoteText(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			return unquoted
+		}
+	}
+	return s
+}
+
+// isName reports whether s looks like a variable or selector such as "user.Age".
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 127) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseValue reads "name = value (type)".
+func parseValue(s string) Value {
+	if m := valueLine.FindStringSubmatch(s); m != nil {
+		return Value{Name: m[1], Value: unquoteText(m[2]), Type: m[3]}
+	}
+	name, value, _ := strings.Cut(s, " = ")
+	return Value{Name: name, Value: value}
+}
+
+// Summary aggregates failures by test, by file and by expression.
+type Summary struct {
+	Failures    int          `json:"failures"`
+	Tests       []Count      `json:"tests"`
+	Files       []Count      `json:"files"`
+	Expressions []Expression `json:"expressions"`
+}
+
+// Count is the number of failures attributed to a test or a file.
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Expression is an assertion that failed one or more times, identified by its EXPR_ID
+// (or by its text for blocks without one).
+type Expression struct {
+	ExprID string   `json:"expr_id,omitempty"`
+	Expr   string   `json:"expr"`
+	Count  int      `json:"count"`
+	Tests  []string `json:"tests,omitempty"`  // Tests it failed in, in order of first failure
+	Reason string   `json:"reason,omitempty"` // Reason of the first failure
+}
+
+// Summarize counts failures. Tests and files are sorted by descending count, then by name;
+// expressions by descending count, then in the order they first failed in.
+func Summarize(failures []Failure) Summary {
+	s := Summary{Failures: len(failures)}
+
+	tests := map[string]int{}
+	files := map[string]int{}
+	exprs := map[string]*Expression{}
+	var order []string
+	for _, f := range failures {
+		if f.Test != "" {
+			tests[f.Test]++
+		}
+		if f.File != "" {
+			files[f.File]++
+		}
+
+		key := f.ExprID
+		if key == "" {
+			key = f.Expr
+		}
+		e, ok := exprs[key]
+		if !ok {
+			e = &Expression{ExprID: f.ExprID, Expr: f.Expr, Reason: f.Reason}
+			exprs[key] = e
+			order = append(order, key)
+		}
+		e.Count++
+		if f.Test != "" && !contains(e.Tests, f.Test) {
+			e.Tests = append(e.Tests, f.Test)
+		}
+	}
+
+	s.Tests = sortedCounts(tests)
+	s.Files = sortedCounts(files)
+	for _, key := range order {
+		s.Expressions = append(s.Expressions, *exprs[key])
+	}
+	sort.SliceStable(s.Expressions, func(i, j int) bool {
+		return s.Expressions[i].Count > s.Expressions[j].Count
+	})
+
+	return s
+}
+
+func sortedCounts(counts map[string]int) []Count {
+	list := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		list = append(list, Count{Name: name, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
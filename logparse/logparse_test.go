@@ -0,0 +1,327 @@
+package logparse
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert"
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// plainLog is `go test` output with one failure in a test and one in a subtest.
+const plainLog = `--- FAIL: TestUser (0.00s)
+    user_test.go:7: ASSERTION FAILED at user_test.go:7
+
+          assert(u.Age >= 18 && x > 1)
+
+        [MACHINE_READABLE_START]
+        SCHEMA_VERSION: 1
+        EXPR: u.Age >= 18 && x > 1
+        EXPR_ID: 40abc208
+        RESULT: false
+        VARIABLES: u.Age=16,x=5
+        EVALUATION_STEPS:
+          Step 1: ` + "`u.Age`" + ` => 16 [node 6ac272d0]
+          Step 2: ` + "`18`" + ` => 18 [node 68c26faa]
+          Step 3: ` + "`u.Age >= 18`" + ` with 16 >= 18 => false [node 50fac3bc]
+          Step 4: ` + "`x > 1`" + ` => <not evaluated> [node 46fab3fe]
+        SHORT_CIRCUIT: x > 1 not evaluated
+        FAILURE_REASON: u.Age >= 18 is false
+        FAILING_NODE: u.Age >= 18
+        FAILING_NODE_ID: 50fac3bc
+        CUSTOM_MESSAGE: adult
+        CAPTURED_VALUES_START
+        VALUE: x = 5 (int)
+        CAPTURED_VALUES_END
+        [MACHINE_READABLE_END]
+
+    --- FAIL: TestUser/name (0.00s)
+        user_test.go:8: ASSERTION FAILED at user_test.go:8
+
+            [MACHINE_READABLE_START]
+            EXPR: name == "b"
+            RESULT: false
+            DIFF: name == "b": len 1 != 1
+            HTTP_RESPONSE_START
+            Status: 200 OK
+              Body (2 bytes):
+            HTTP_RESPONSE_END
+            [MACHINE_READABLE_END]
+FAIL
+`
+
+func TestParse(t *testing.T) {
+	failures := ParseString(plainLog)
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %d", len(failures))
+	}
+
+	first := failures[0]
+	if first.Test != "TestUser" || first.File != "user_test.go" || first.Line != 7 {
+		t.Errorf("Unexpected location %s %s:%d", first.Test, first.File, first.Line)
+	}
+	if first.SchemaVersion != 1 || first.Expr != "u.Age >= 18 && x > 1" || first.ExprID != "40abc208" || first.Result != "false" {
+		t.Errorf("Unexpected header fields: %+v", first)
+	}
+	if !reflect.DeepEqual(first.Variables, map[string]string{"u.Age": "16", "x": "5"}) {
+		t.Errorf("Unexpected variables: %v", first.Variables)
+	}
+	if len(first.Steps) != 4 || first.Steps[2] != (Step{Text: "`u.Age >= 18` with 16 >= 18 => false", NodeID: "50fac3bc"}) {
+		t.Errorf("Unexpected steps: %+v", first.Steps)
+	}
+	if first.Reason != "u.Age >= 18 is false" || first.FailingNodeID != "50fac3bc" || first.Message != "adult" {
+		t.Errorf("Unexpected failure details: %+v", first)
+	}
+	if !reflect.DeepEqual(first.Values, []Value{{Name: "x", Value: "5", Type: "int"}}) {
+		t.Errorf("Unexpected values: %+v", first.Values)
+	}
+	if len(first.ShortCircuits) != 1 {
+		t.Errorf("Unexpected short circuits: %v", first.ShortCircuits)
+	}
+
+	second := failures[1]
+	if second.Test != "TestUser/name" || second.Line != 8 || second.SchemaVersion != 0 {
+		t.Errorf("Unexpected second failure: %+v", second)
+	}
+	if !reflect.DeepEqual(second.Diffs, []string{`name == "b": len 1 != 1`}) {
+		t.Errorf("Unexpected diffs: %v", second.Diffs)
+	}
+	if !reflect.DeepEqual(second.Sections["HTTP_RESPONSE"], []string{"Status: 200 OK", "  Body (2 bytes):"}) {
+		t.Errorf("Section lines should keep their indentation, got %q", second.Sections["HTTP_RESPONSE"])
+	}
+}
+
+func TestParseTest2JSON(t *testing.T) {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(plainLog, "\n") {
+		event, _ := json.Marshal(map[string]string{"Action": "output", "Test": "TestFromEvent", "Output": line})
+		b.Write(event)
+		b.WriteString("\n")
+	}
+
+	failures := ParseString(b.String())
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %d", len(failures))
+	}
+	if failures[0].Test != "TestFromEvent" || failures[0].Expr != "u.Age >= 18 && x > 1" || len(failures[0].Steps) != 4 {
+		t.Errorf("Unexpected failure from events: %+v", failures[0])
+	}
+}
+
+func TestParseEncodedOutput(t *testing.T) {
+	diagnostic := "ASSERTION FAILED at a_test.go:3\n\n[MACHINE_READABLE_START]\nEXPR: a > b\nRESULT: false\n[MACHINE_READABLE_END]\n"
+	log := "=== RUN   TestEncoded\n" +
+		"    a_test.go:3: ASSERTION FAILED at a_test.go:3 | DIAGASSERT_BASE64 " + base64.StdEncoding.EncodeToString([]byte(diagnostic)) + "\n" +
+		"\x1b[31m--- FAIL: TestEncoded (0.00s)\x1b[0m\n"
+
+	failures := ParseString(log)
+	if len(failures) != 1 {
+		t.Fatalf("Expected 1 failure, got %d", len(failures))
+	}
+	if f := failures[0]; f.Test != "TestEncoded" || f.File != "a_test.go" || f.Line != 3 || f.Expr != "a > b" {
+		t.Errorf("Unexpected failure: %+v", f)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	log := `--- FAIL: TestUser (0.00s)
+    user_test.go:9: アサーション失敗: user_test.go:9
+
+        [MACHINE_READABLE_START]
+        SCHEMA_VERSION: 2
+        EXPR: ok
+        RESULT: false
+        LOCATION: user_test.go:9
+        [MACHINE_READABLE_END]
+`
+	failures := ParseString(log)
+	if len(failures) != 1 || failures[0].File != "user_test.go" || failures[0].Line != 9 {
+		t.Errorf("The location should be read from LOCATION whatever the header's language, got %+v", failures)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	log := `--- FAIL: TestParse (0.00s)
+    parse_test.go:9: ASSERTION FAILED at parse_test.go:9
+
+        [MACHINE_READABLE_START]
+        SCHEMA_VERSION: 1
+        EXPR: p.Parse(s) == nil
+        RESULT: false
+        ERROR: p.Parse(s): invalid syntax
+        [MACHINE_READABLE_END]
+`
+	failures := ParseString(log)
+	if len(failures) != 1 || !reflect.DeepEqual(failures[0].Errors, []string{"p.Parse(s): invalid syntax"}) {
+		t.Errorf("Returned errors should be read from ERROR lines, got %+v", failures)
+	}
+}
+
+func TestParseRouting(t *testing.T) {
+	log := `--- FAIL: TestPay (0.00s)
+    pay_test.go:3: ASSERTION FAILED at pay_test.go:3
+
+        [MACHINE_READABLE_START]
+        SCHEMA_VERSION: 2
+        EXPR: x > 1
+        LOCATION: pay_test.go:3
+        FINGERPRINT: e54beb79
+        HISTORY: 3/10
+        OWNERS: @acme/payments ops@example.com
+        [MACHINE_READABLE_END]
+`
+	failures := ParseString(log)
+	if len(failures) != 1 || !reflect.DeepEqual(failures[0].Owners, []string{"@acme/payments", "ops@example.com"}) {
+		t.Errorf("Owners should be read from the OWNERS line, got %+v", failures)
+	}
+	if len(failures) == 1 && (failures[0].Fingerprint != "e54beb79" || failures[0].History != "3/10") {
+		t.Errorf("FINGERPRINT and HISTORY should be read, got %+v", failures[0])
+	}
+}
+
+func TestParsePerformance(t *testing.T) {
+	log := `    pool_test.go:9: ASSERTION FAILED at pool_test.go:9
+
+        [MACHINE_READABLE_START]
+        SCHEMA_VERSION: 2
+        EXPR: testing.AllocsPerRun(100, get) <= 0
+        LOCATION: pool_test.go:9
+        ALLOCS_START
+        2 allocations per call, averaged over 100 calls
+        2 over the limit of 0
+        ALLOCS_END
+        [MACHINE_READABLE_END]
+    pool_test.go:14: ASSERTION FAILED at pool_test.go:14
+
+        [MACHINE_READABLE_START]
+        SCHEMA_VERSION: 2
+        EXPR: elapsed <= budget
+        LOCATION: pool_test.go:14
+        DURATION_START
+        took 63ms, 13ms (26.0%) over the budget of 50ms
+        DURATION_END
+        [MACHINE_READABLE_END]
+`
+	failures := ParseString(log)
+	if len(failures) != 2 {
+		t.Fatalf("expected two failures, got %+v", failures)
+	}
+	if lines := failures[0].Sections["ALLOCS"]; len(lines) != 2 || lines[0] != "2 allocations per call, averaged over 100 calls" {
+		t.Errorf("ALLOCS should be read, got %+v", failures[0])
+	}
+	if lines := failures[1].Sections["DURATION"]; len(lines) != 1 || lines[0] != "took 63ms, 13ms (26.0%) over the budget of 50ms" {
+		t.Errorf("DURATION should be read, got %+v", failures[1])
+	}
+}
+
+func TestParseJSONBlock(t *testing.T) {
+	t.Setenv("DIAGASSERT_HISTORY", "")
+	t.Setenv("DIAGASSERT_CODEOWNERS", "")
+
+	// The same failure in both formats
+	assertion := func() Failure {
+		mock := testutil.NewMockT()
+		age, name := 16, "bob"
+		diagassert.Assert(mock, age >= 18 && name != "", diagassert.V("age", age), diagassert.V("name", name), "adults only")
+		failures := ParseString(mock.GetOutput())
+		if len(failures) != 1 {
+			t.Fatalf("expected one failure, got %d in:\n%s", len(failures), mock.GetOutput())
+		}
+		failures[0].Lines = nil
+		return failures[0]
+	}
+	t.Setenv("DIAGASSERT_MACHINE_FORMAT", "text")
+	text := assertion()
+	t.Setenv("DIAGASSERT_MACHINE_FORMAT", "json")
+	fromJSON := assertion()
+
+	if fromJSON.Expr == "" || fromJSON.Reason == "" || len(fromJSON.Steps) == 0 || len(fromJSON.Values) != 2 {
+		t.Fatalf("JSON block was not read: %+v", fromJSON)
+	}
+	// Both failures come from the same line, so nothing may differ
+	if !reflect.DeepEqual(text, fromJSON) {
+		t.Errorf("JSON block read differently from the text one:\ntext: %+v\njson: %+v", text, fromJSON)
+	}
+}
+
+func TestParseMultiLineValue(t *testing.T) {
+	t.Setenv("DIAGASSERT_HISTORY", "")
+	t.Setenv("DIAGASSERT_CODEOWNERS", "")
+	t.Setenv("DIAGASSERT_MACHINE_FORMAT", "text")
+
+	mock := testutil.NewMockT()
+	line := "x\ny"
+	diagassert.Assert(mock, line == "x,y", diagassert.V("line", line))
+
+	failures := ParseString(mock.GetOutput())
+	if len(failures) != 1 {
+		t.Fatalf("expected one failure, got %d in:\n%s", len(failures), mock.GetOutput())
+	}
+	f := failures[0]
+	if f.Variables["line"] != "x\ny" {
+		t.Errorf("VARIABLES should read back the newline, got %q", f.Variables["line"])
+	}
+	if len(f.Values) != 1 || f.Values[0].Value != "x\ny" {
+		t.Errorf("VALUE should read back the newline, got %+v", f.Values)
+	}
+	if len(f.Steps) != 3 {
+		t.Errorf("expected 3 steps, got %+v", f.Steps)
+	}
+	for _, step := range f.Steps {
+		if step.NodeID == "" {
+			t.Errorf("step %q lost its node id", step.Text)
+		}
+	}
+}
+
+func TestParseVariables(t *testing.T) {
+	got := parseVariables("items=[1,2,3],name=a=b,x=<x>")
+	want := map[string]string{"items": "[1,2,3]", "name": "a=b", "x": "<x>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseVariables = %v, want %v", got, want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	failures := []Failure{
+		{Test: "TestA", File: "a_test.go", Expr: "x > 1", ExprID: "01", Reason: "x > 1 is false"},
+		{Test: "TestB", File: "a_test.go", Expr: "ok"},
+		{Test: "TestA", File: "b_test.go", Expr: "x > 1", ExprID: "01"},
+		{Test: "TestC", File: "a_test.go", Expr: "x > 1", ExprID: "01"},
+	}
+
+	s := Summarize(failures)
+	if s.Failures != 4 {
+		t.Errorf("Failures = %d, want 4", s.Failures)
+	}
+	if !reflect.DeepEqual(s.Tests, []Count{{"TestA", 2}, {"TestB", 1}, {"TestC", 1}}) {
+		t.Errorf("Unexpected tests: %v", s.Tests)
+	}
+	if !reflect.DeepEqual(s.Files, []Count{{"a_test.go", 3}, {"b_test.go", 1}}) {
+		t.Errorf("Unexpected files: %v", s.Files)
+	}
+	want := []Expression{
+		{ExprID: "01", Expr: "x > 1", Count: 3, Tests: []string{"TestA", "TestC"}, Reason: "x > 1 is false"},
+		{Expr: "ok", Count: 1, Tests: []string{"TestB"}},
+	}
+	if !reflect.DeepEqual(s.Expressions, want) {
+		t.Errorf("Unexpected expressions: %+v", s.Expressions)
+	}
+}
+
+func TestParseSeed(t *testing.T) {
+	t.Setenv("DIAGASSERT_SEED", "")
+	for _, format := range []string{"text", "json"} {
+		t.Setenv("DIAGASSERT_MACHINE_FORMAT", format)
+		mock := testutil.NewMockT()
+		diagassert.Seed(mock, 1712345678)
+		diagassert.Assert(mock, 1 > 2)
+		failures := ParseString(mock.GetOutput())
+		if len(failures) != 1 || len(failures[0].Sections["SEED"]) == 0 || failures[0].Sections["SEED"][0] != "1712345678" {
+			t.Errorf("SEED should be read from the %s block, got %+v", format, failures)
+		}
+	}
+}
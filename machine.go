@@ -0,0 +1,29 @@
+package diagassert
+
+import "github.com/paveg/diagassert/internal/formatter"
+
+// MachineRecord is the content of the machine-readable section of a failure: the
+// expression, its values and evaluation steps, the location and fingerprint, and the
+// captured values, attachments and sections. See RegisterMachineEncoder.
+type MachineRecord = formatter.MachineRecord
+
+// MachineEncoder writes machine-readable records in a format of its own.
+type MachineEncoder = formatter.Encoder
+
+// MachineEncoderFunc adapts a function to a MachineEncoder.
+type MachineEncoderFunc = formatter.EncoderFunc
+
+// RegisterMachineEncoder makes an encoder available to DIAGASSERT_MACHINE_FORMAT under
+// name, next to the built-in "text", "json" and "yaml", so that failures can be written in
+// the format a failure-ingestion pipeline already reads. Output that is not valid UTF-8,
+// such as protobuf or CBOR, is written as a BASE64 line between the markers.
+//
+// Usage:
+//
+//	diagassert.RegisterMachineEncoder("cbor", diagassert.MachineEncoderFunc(
+//		func(record *diagassert.MachineRecord) ([]byte, error) {
+//			return cbor.Marshal(record)
+//		}))
+func RegisterMachineEncoder(name string, encoder MachineEncoder) {
+	formatter.RegisterEncoder(name, encoder)
+}
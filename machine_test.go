@@ -0,0 +1,65 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestRegisterMachineEncoder(t *testing.T) {
+	RegisterMachineEncoder("test-summary", MachineEncoderFunc(func(record *MachineRecord) ([]byte, error) {
+		return []byte("summary: " + record.Expr + " at " + record.File), nil
+	}))
+	t.Setenv("DIAGASSERT_MACHINE_FORMAT", "test-summary")
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+
+	mock := testutil.NewMockT()
+	x := 10
+	Assert(mock, x > 20)
+
+	want := "[MACHINE_READABLE_START]\nsummary: x > 20 at machine_test.go\n[MACHINE_READABLE_END]\n"
+	if !strings.Contains(mock.GetOutput(), want) {
+		t.Errorf("expected the registered encoder's section, got:\n%s", mock.GetOutput())
+	}
+}
+
+func TestMachineFormat_Normalize(t *testing.T) {
+	t.Setenv("DIAGASSERT_MACHINE_READABLE", "true")
+	t.Setenv("NO_COLOR", "1")
+	x, ready := 10, false
+
+	fields := func(output string) map[string]string {
+		found := map[string]string{}
+		for _, line := range strings.Split(output, "\n") {
+			for _, key := range []string{"EXPR: ", "EXPR_ID: ", "FAILING_NODE_ID: "} {
+				if strings.HasPrefix(line, key) {
+					found[key] = strings.TrimPrefix(line, key)
+				}
+			}
+		}
+		return found
+	}
+
+	t.Setenv("DIAGASSERT_NORMALIZE", "true")
+	mock := testutil.NewMockT()
+	Assert(mock, (x > 20) && (ready))
+	normalized := mock.GetOutput()
+	if !strings.Contains(normalized, "assert((x > 20) && (ready))") {
+		t.Errorf("Diagram should show the source, got:\n%s", normalized)
+	}
+	mock = testutil.NewMockT()
+	Assert(mock, x > 20 &&
+		ready)
+	if got, want := fields(mock.GetOutput()), fields(normalized); got["EXPR: "] != "x > 20 && ready" || got["EXPR_ID: "] != want["EXPR_ID: "] ||
+		got["FAILING_NODE_ID: "] != want["FAILING_NODE_ID: "] {
+		t.Errorf("Reformatted assertions should be reported alike, got %v and %v", got, want)
+	}
+
+	t.Setenv("DIAGASSERT_NORMALIZE", "false")
+	mock = testutil.NewMockT()
+	Assert(mock, (x > 20) && (ready))
+	if got := fields(mock.GetOutput())["EXPR: "]; got != "(x > 20) && (ready)" {
+		t.Errorf("EXPR should be the source without DIAGASSERT_NORMALIZE, got %q", got)
+	}
+}
@@ -0,0 +1,79 @@
+package diagassert
+
+import (
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// DiagMatcher is implemented by matchers that judge a value and explain their verdict in
+// the vocabulary of their domain. Use them with Match. An explanation of an accepted value
+// is optional; Negate shows it when the value should have been rejected.
+type DiagMatcher interface {
+	DiagMatch(actual interface{}) (bool, Explanation)
+}
+
+// Explanation describes why a DiagMatcher rejected, or accepted, a value. Every field is
+// optional.
+type Explanation struct {
+	Expected string   // What the matcher accepts, e.g. "an ISO 4217 currency code"
+	Found    string   // What it found instead, e.g. `"EURO" has 4 letters`
+	Details  []string // Further findings, shown one per line
+}
+
+// Match checks actual with matcher and outputs detailed diagnostic information, including
+// the matcher's Explanation, if it does not match:
+//
+//	Match(t, user.Email, IsEmail())
+//
+// The diagram shows the call that was made, IsEmail().DiagMatch(user.Email), with the
+// value under its source text. Trailing args are handled as in Assert.
+func Match(t TestingT, actual interface{}, matcher DiagMatcher, args ...interface{}) {
+	t.Helper()
+
+	ok, explanation := matcher.DiagMatch(actual)
+	if ok {
+		return
+	}
+
+	failure := buildMatchFailureInfo(actual, matcher, explanation, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// buildMatchFailureInfo builds diagnostic information for a failed Match.
+func buildMatchFailureInfo(actual interface{}, matcher DiagMatcher, explanation Explanation, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source the value and the matcher are named generically
+	actualText, matcherText := "actual", "matcher"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, "Match"); err == nil && len(args) >= 3 {
+		actualText, matcherText = args[1], args[2]
+	}
+
+	result := evaluator.EvaluateMatch(actualText, matcherText, actual, matcher, evaluator.MatchExplanation{
+		Expected: explanation.Expected,
+		Found:    explanation.Found,
+		Details:  explanation.Details,
+	})
+	evaluator.Redact(result)
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
@@ -0,0 +1,80 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// emailMatcher accepts strings with a local part and a domain around "@".
+type emailMatcher struct{}
+
+func (emailMatcher) DiagMatch(actual interface{}) (bool, Explanation) {
+	s, ok := actual.(string)
+	if !ok {
+		return false, Explanation{Expected: "an email address", Found: "not a string"}
+	}
+	at := strings.Index(s, "@")
+	if at > 0 && at < len(s)-1 {
+		return true, Explanation{}
+	}
+	return false, Explanation{
+		Expected: "an email address",
+		Found:    "no domain after @",
+		Details:  []string{"local part: " + strings.TrimSuffix(s, "@")},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	type user struct{ Email string }
+
+	t.Run("passing matcher", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		Match(mock, "bob@example.com", emailMatcher{})
+
+		if mock.Failed() {
+			t.Errorf("Match should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure shows the explanation", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		u := user{Email: "bob@"}
+		Match(mock, u.Email, emailMatcher{}, "signup form")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at match_test.go:",
+			"assert(emailMatcher{}.DiagMatch(u.Email))",
+			`"bob@"`,
+			"LIKELY CAUSE: u.Email does not match emailMatcher{}: no domain after @",
+			"EXPLANATION from emailMatcher{}:\n  expected: an email address\n  found:    no domain after @\n  - local part: bob",
+			"CUSTOM MESSAGE:\nsignup form",
+			"MATCHER: emailMatcher{}",
+			"EXPECTED: an email address",
+			"FOUND: no domain after @",
+			"DETAIL: local part: bob",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("failure hooks see the matcher call", func(t *testing.T) {
+		var got FailureInfo
+		remove := OnFailure(func(f FailureInfo) { got = f })
+		defer remove()
+
+		Match(testutil.NewMockT(), 42, emailMatcher{})
+
+		if got.Expression != "emailMatcher{}.DiagMatch(42)" {
+			t.Errorf("Expression = %q, want the matcher call", got.Expression)
+		}
+		if !strings.HasSuffix(got.File, "match_test.go") || got.Line == 0 {
+			t.Errorf("Location = %s:%d, want the Match call", got.File, got.Line)
+		}
+	})
+}
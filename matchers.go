@@ -0,0 +1,282 @@
+package diagassert
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Approx matches numbers within tolerance of x, either way:
+//
+//	Match(t, ratio, Approx(0.75, 0.01))
+//
+// A failure says how far the number is from x and how much of that is beyond tolerance.
+func Approx(x, tolerance float64) DiagMatcher {
+	return approxMatcher{x: x, tolerance: tolerance}
+}
+
+// Like matches strings against pattern, where * stands for any run of characters and ? for
+// any one character:
+//
+//	Match(t, greeting, Like("hello, *!"))
+//
+// A failure gives the edit distance to the pattern: the fewest characters to insert, delete
+// or replace for the string to match.
+func Like(pattern string) DiagMatcher {
+	return likeMatcher{pattern: pattern}
+}
+
+// OneOf matches values equal to one of candidates, as reflect.DeepEqual decides, or for
+// numbers of any type, by value:
+//
+//	Match(t, order.Status, OneOf("paid", "shipped", "delivered"))
+//
+// A failure lists the candidates with how close the value is to each, by edit distance for
+// strings and by difference for numbers, and names the closest.
+func OneOf(candidates ...interface{}) DiagMatcher {
+	return oneOfMatcher{candidates: candidates}
+}
+
+// Negate matches the values matcher rejects:
+//
+//	Match(t, logLine, Negate(Like("*password=*")))
+//
+// A failure shows what matcher found in the value: the built-in matchers explain the values
+// they accept too, such as what each wildcard of Like matched.
+func Negate(matcher DiagMatcher) DiagMatcher {
+	return negatedMatcher{matcher: matcher}
+}
+
+type approxMatcher struct {
+	x, tolerance float64
+}
+
+func (m approxMatcher) DiagMatch(actual interface{}) (bool, Explanation) {
+	expected := fmt.Sprintf("a number within %s of %s", formatNumber(m.tolerance), formatNumber(m.x))
+	f, ok := toFloat(actual)
+	if !ok {
+		return false, Explanation{Expected: expected, Found: fmt.Sprintf("%T, not a number", actual)}
+	}
+
+	delta := math.Abs(f - m.x)
+	if delta <= m.tolerance {
+		return true, Explanation{Expected: expected, Found: fmt.Sprintf("%s, %s away", formatNumber(f), formatNumber(delta))}
+	}
+	explanation := Explanation{
+		Expected: expected,
+		Found:    fmt.Sprintf("%s, %s away", formatNumber(f), formatNumber(delta)),
+		Details:  []string{fmt.Sprintf("delta: %s, %s beyond the tolerance", formatNumber(delta), formatNumber(delta-m.tolerance))},
+	}
+	if m.x != 0 && !math.IsInf(delta, 0) {
+		explanation.Details = append(explanation.Details, fmt.Sprintf("relative delta: %.2f%%", delta/math.Abs(m.x)*100))
+	}
+	return false, explanation
+}
+
+type likeMatcher struct {
+	pattern string
+}
+
+func (m likeMatcher) DiagMatch(actual interface{}) (bool, Explanation) {
+	expected := fmt.Sprintf("a string like %q", m.pattern)
+	var s string
+	switch v := actual.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return false, Explanation{Expected: expected, Found: fmt.Sprintf("%T, not a string", actual)}
+	}
+
+	if match := globPattern(m.pattern).FindStringSubmatch(s); match != nil {
+		explanation := Explanation{Expected: expected, Found: fmt.Sprintf("%q", s)}
+		wildcards := strings.Map(func(r rune) rune {
+			if r == '*' || r == '?' {
+				return r
+			}
+			return -1
+		}, m.pattern)
+		for i, wildcard := range wildcards {
+			explanation.Details = append(explanation.Details, fmt.Sprintf("%c matched %q", wildcard, match[i+1]))
+		}
+		return true, explanation
+	}
+	distance := editDistance([]rune(m.pattern), []rune(s), true)
+	return false, Explanation{
+		Expected: expected,
+		Found:    fmt.Sprintf("%q, %s away", s, edits(distance)),
+		Details:  []string{fmt.Sprintf("edit distance: %d", distance)},
+	}
+}
+
+type oneOfMatcher struct {
+	candidates []interface{}
+}
+
+func (m oneOfMatcher) DiagMatch(actual interface{}) (bool, Explanation) {
+	expected := fmt.Sprintf("one of %d candidates", len(m.candidates))
+	for i, candidate := range m.candidates {
+		if equalCandidate(actual, candidate) {
+			return true, Explanation{Expected: expected, Found: fmt.Sprintf("%#v, candidate %d", actual, i+1)}
+		}
+	}
+
+	explanation := Explanation{Expected: expected}
+	closest, best := -1, math.Inf(1)
+	for i, candidate := range m.candidates {
+		line := fmt.Sprintf("%#v", candidate)
+		if closeness, text, ok := closenessTo(actual, candidate); ok {
+			line += ": " + text
+			if closeness < best {
+				closest, best = i, closeness
+			}
+		}
+		explanation.Details = append(explanation.Details, line)
+	}
+	explanation.Found = fmt.Sprintf("%#v, none of them", actual)
+	if closest >= 0 {
+		_, text, _ := closenessTo(actual, m.candidates[closest])
+		explanation.Found = fmt.Sprintf("%#v, closest to %#v, %s", actual, m.candidates[closest], text)
+	}
+	return false, explanation
+}
+
+type negatedMatcher struct {
+	matcher DiagMatcher
+}
+
+func (m negatedMatcher) DiagMatch(actual interface{}) (bool, Explanation) {
+	matched, inner := m.matcher.DiagMatch(actual)
+	if !matched {
+		return true, Explanation{}
+	}
+
+	explanation := Explanation{Expected: "a value the matcher rejects", Found: "it matches", Details: inner.Details}
+	if inner.Expected != "" {
+		explanation.Expected = "not " + inner.Expected
+	}
+	if inner.Found != "" {
+		explanation.Found = "it matches: " + inner.Found
+	}
+	return false, explanation
+}
+
+// equalCandidate reports whether actual equals candidate, comparing numbers by value.
+func equalCandidate(actual, candidate interface{}) bool {
+	if a, ok := toFloat(actual); ok {
+		c, ok := toFloat(candidate)
+		return ok && a == c
+	}
+	return reflect.DeepEqual(actual, candidate)
+}
+
+// closenessTo measures how close actual is to candidate: the edit distance between strings
+// or the difference between numbers, with its description. ok is false for other values.
+func closenessTo(actual, candidate interface{}) (closeness float64, text string, ok bool) {
+	if a, isString := actual.(string); isString {
+		if c, isString := candidate.(string); isString {
+			distance := editDistance([]rune(c), []rune(a), false)
+			return float64(distance), edits(distance), true
+		}
+		return 0, "", false
+	}
+	a, aok := toFloat(actual)
+	c, cok := toFloat(candidate)
+	if !aok || !cok {
+		return 0, "", false
+	}
+	delta := math.Abs(a - c)
+	return delta, formatNumber(delta) + " away", true
+}
+
+// globPattern compiles a Like pattern to an anchored regular expression with a group for
+// each wildcard.
+func globPattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?s)\A`)
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString("(.*?)")
+		case '?':
+			b.WriteString("(.)")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(`\z`)
+	return regexp.MustCompile(b.String())
+}
+
+// editDistance returns the fewest characters to insert, delete or replace in s for it to
+// match pattern. With wildcards, * in pattern matches any run of characters and ? any one
+// for free; without, it is the Levenshtein distance.
+func editDistance(pattern, s []rune, wildcards bool) int {
+	prev := make([]int, len(s)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for _, p := range pattern {
+		cur := make([]int, len(s)+1)
+		cur[0] = prev[0] + 1
+		star := wildcards && p == '*'
+		if star {
+			cur[0] = prev[0]
+		}
+		for j, r := range s {
+			if star {
+				cur[j+1] = minInt(prev[j+1], cur[j])
+				continue
+			}
+			replace := prev[j]
+			if p != r && !(wildcards && p == '?') {
+				replace++
+			}
+			cur[j+1] = minInt(replace, minInt(prev[j+1], cur[j])+1)
+		}
+		prev = cur
+	}
+	return prev[len(s)]
+}
+
+// edits describes an edit distance: "1 edit", "3 edits".
+func edits(n int) string {
+	if n == 1 {
+		return "1 edit"
+	}
+	return fmt.Sprintf("%d edits", n)
+}
+
+// formatNumber renders f in its shortest form, keeping whole numbers whole and six
+// significant digits of computed differences such as 0.06000000000000005.
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+// toFloat returns the value of any integer or floating-point number.
+func toFloat(v interface{}) (float64, bool) {
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(val.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	}
+	return 0, false
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,99 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestMatchers(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   interface{}
+		matcher  DiagMatcher
+		passes   bool
+		expected []string
+	}{
+		{"approx within tolerance", 0.755, Approx(0.75, 0.01), true, nil},
+		{"approx of an integer", 3, Approx(3, 0), true, nil},
+		{"approx beyond tolerance", 3.2, Approx(3.14, 0.01), false, []string{
+			"expected: a number within 0.01 of 3.14",
+			"found:    3.2, 0.06 away",
+			"- delta: 0.06, 0.05 beyond the tolerance",
+			"- relative delta: 1.91%",
+		}},
+		{"approx of a string", "3.14", Approx(3.14, 0.01), false, []string{"found:    string, not a number"}},
+		{"like with wildcards", "hello, world!", Like("hello, *!"), true, nil},
+		{"like with a single wildcard", "v1", Like("v?"), true, nil},
+		{"like without wildcards in the text", "a.b", Like("a?b"), true, nil},
+		{"unlike", "hallo, world", Like("hello, *!"), false, []string{
+			`expected: a string like "hello, *!"`,
+			`found:    "hallo, world", 2 edits away`,
+			"- edit distance: 2",
+		}},
+		{"one of", "shipped", OneOf("paid", "shipped"), true, nil},
+		{"one of numbers by value", int64(2), OneOf(1, 2), true, nil},
+		{"none of the strings", "shiped", OneOf("paid", "shipped", "delivered"), false, []string{
+			"expected: one of 3 candidates",
+			`found:    "shiped", closest to "shipped", 1 edit`,
+			`- "paid": 4 edits`,
+			`- "shipped": 1 edit`,
+			`- "delivered": 6 edits`,
+		}},
+		{"none of the numbers", 7, OneOf(1, 5, 10), false, []string{
+			"found:    7, closest to 5, 2 away",
+			"- 10: 3 away",
+		}},
+		{"negated mismatch", "user=bob", Negate(Like("*password=*")), true, nil},
+		{"negated like", "user=bob password=hunter2", Negate(Like("*password=*")), false, []string{
+			`expected: not a string like "*password=*"`,
+			`found:    it matches: "user=bob password=hunter2"`,
+			`- * matched "user=bob "`,
+			`- * matched "hunter2"`,
+		}},
+		{"negated one of", "paid", Negate(OneOf("refunded", "paid")), false, []string{
+			`found:    it matches: "paid", candidate 2`,
+		}},
+		{"negated approx", 0.1, Negate(Approx(0, 0.5)), false, []string{
+			"found:    it matches: 0.1, 0.1 away",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := testutil.NewMockT()
+			Match(mock, tt.actual, tt.matcher)
+
+			output := mock.GetOutput()
+			if mock.Failed() == tt.passes {
+				t.Fatalf("Match failed = %v, want %v, output: %s", mock.Failed(), !tt.passes, output)
+			}
+			for _, part := range tt.expected {
+				if !strings.Contains(output, part) {
+					t.Errorf("Output should contain %q, got: %s", part, output)
+				}
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		wildcards  bool
+		expected   int
+	}{
+		{"kitten", "sitting", false, 3},
+		{"", "abc", false, 3},
+		{"a*", "abc", false, 2},
+		{"a*", "abc", true, 0},
+		{"*.go", "main.g", true, 1},
+		{"a?c", "ac", true, 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.pattern), []rune(tt.s), tt.wildcards); got != tt.expected {
+			t.Errorf("editDistance(%q, %q, %v) = %d, want %d", tt.pattern, tt.s, tt.wildcards, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package diagassert
+
+import "github.com/paveg/diagassert/internal/formatter"
+
+// Catalog maps the message keys of the human-readable output to the texts of one language.
+// See RegisterCatalog.
+type Catalog map[string]string
+
+// RegisterCatalog adds a language for DIAGASSERT_LANG to select, or replaces texts of a
+// built-in one (en, ja, ko, zh). Texts are format strings taking the arguments of the
+// English ones; keys left out fall back to the base language, e.g. "pt" for "pt_BR", and
+// then to English. The machine-readable section is the same in every language.
+//
+//	assertion_failed  "ASSERTION FAILED at %s:%d"  (file, line)
+//	flaky             "failed in %d of the last %d runs, possibly flaky"  (failed runs, runs)
+//	likely_cause      "LIKELY CAUSE: %s"           (failing operand)
+//	contents          "CONTENTS"
+//	notes             "NOTES"
+//	returned_errors   "RETURNED ERRORS"
+//	explanation       "EXPLANATION from %s"        (matcher)
+//	expected          "expected: %s"
+//	found             "found:    %s"
+//	differences       "DIFFERENCES in %s"          (compared expression)
+//	line_diff         "LINE DIFF"
+//	custom_message    "CUSTOM MESSAGE"
+//	owners            "OWNERS: %s"                 (owners of the failing file)
+//	captured_values   "CAPTURED VALUES"
+//	attachments       "ATTACHMENTS"
+//	written_to        "written to %s"              (attachment path)
+//	hint              "HINT: pass the values that could not be read to show them in the diagram"
+//	repeated          "ASSERTION FAILED at %s:%d repeated %d more times"  (file, line, count)
+//	expected_failure  "EXPECTED FAILURE (%s): a known issue, so the test does not fail"  (issue)
+//	unexpected_pass   "UNEXPECTED PASS: no assertion failed, although ..."  (issue)
+//
+// Usage:
+//
+//	diagassert.RegisterCatalog("de", diagassert.Catalog{
+//		"assertion_failed": "ASSERTION FEHLGESCHLAGEN bei %s:%d",
+//	})
+func RegisterCatalog(lang string, catalog Catalog) {
+	formatter.RegisterCatalog(lang, formatter.Catalog(catalog))
+}
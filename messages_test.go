@@ -0,0 +1,64 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestLanguages(t *testing.T) {
+	tests := []struct {
+		lang   string
+		header string
+		values string
+	}{
+		{"", "ASSERTION FAILED at messages_test.go:", "CAPTURED VALUES:"},
+		{"ja", "アサーション失敗: messages_test.go:", "キャプチャした値:"},
+		{"ja_JP.UTF-8", "アサーション失敗: messages_test.go:", "キャプチャした値:"},
+		{"ko-KR", "단언 실패: messages_test.go:", "캡처한 값:"},
+		{"zh", "断言失败: messages_test.go:", "捕获的值:"},
+		{"fr", "ASSERTION FAILED at messages_test.go:", "CAPTURED VALUES:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			t.Setenv("DIAGASSERT_LANG", tt.lang)
+
+			mock := testutil.NewMockT()
+			count := 2
+			Assert(mock, count == 3, V("count", count), "counting")
+			output := mock.GetOutput()
+
+			if !strings.Contains(output, tt.header) || !strings.Contains(output, tt.values) {
+				t.Errorf("Expected %q and %q, got:\n%s", tt.header, tt.values, output)
+			}
+			for _, key := range []string{"EXPR: count == 3", "CUSTOM_MESSAGE: counting", "CAPTURED_VALUES_START", "LOCATION: messages_test.go:"} {
+				if !strings.Contains(output, key) {
+					t.Errorf("The machine-readable section should not change with the language, missing %q in:\n%s", key, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterCatalog(t *testing.T) {
+	RegisterCatalog("de", Catalog{"assertion_failed": "ASSERTION FEHLGESCHLAGEN bei %s:%d"})
+	RegisterCatalog("ja_JP", Catalog{"custom_message": "メモ"})
+
+	t.Setenv("DIAGASSERT_LANG", "de-AT")
+	mock := testutil.NewMockT()
+	Assert(mock, 1 > 2, "note")
+	output := mock.GetOutput()
+	if !strings.Contains(output, "ASSERTION FEHLGESCHLAGEN bei messages_test.go:") || !strings.Contains(output, "CUSTOM MESSAGE:") {
+		t.Errorf("A registered language should fall back to English for missing keys, got:\n%s", output)
+	}
+
+	t.Setenv("DIAGASSERT_LANG", "ja_JP")
+	mock = testutil.NewMockT()
+	Assert(mock, 1 > 2, "note")
+	output = mock.GetOutput()
+	if !strings.Contains(output, "メモ:") || !strings.Contains(output, "アサーション失敗: messages_test.go:") {
+		t.Errorf("A regional catalog should fall back to its base language, got:\n%s", output)
+	}
+}
@@ -0,0 +1,66 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestAssert_DiagnosticMismatch(t *testing.T) {
+	x := 10
+	mock := testutil.NewMockT()
+	Assert(mock, x > 20, V("x", 30))
+
+	output := mock.GetOutput()
+	expected := []string{
+		"DIAGNOSTIC MISMATCH: the diagram gives true, but the assertion was given false",
+		"the values passed with V or Values for x may be stale",
+		"MISMATCH: the values passed with V or Values for x may be stale",
+	}
+	for _, part := range expected {
+		if !strings.Contains(output, part) {
+			t.Errorf("Output should contain %q, got: %s", part, output)
+		}
+	}
+
+	// The causes follow the language; the machine-readable section stays in English
+	t.Run("translated", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_LANG", "ja")
+		mock := testutil.NewMockT()
+		Assert(mock, x > 20, V("x", 30))
+		output := mock.GetOutput()
+		for _, part := range []string{"  - V または Values で x に渡された値が古いか", "MISMATCH: the values passed with V or Values for x may be stale"} {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	mock = testutil.NewMockT()
+	Assert(mock, x > 20, V("x", x))
+	if output := mock.GetOutput(); strings.Contains(output, "MISMATCH") {
+		t.Errorf("A consistent diagram should not warn, got: %s", output)
+	}
+}
+
+func TestAssert_EscapedLiteralsAgree(t *testing.T) {
+	line := "x\ny"
+	tab := "a\tc"
+	path := `C:\dir`
+
+	for name, assert := range map[string]func(TestingT){
+		"newline":    func(mock TestingT) { Assert(mock, line != "x\ny", V("line", line)) },
+		"tab":        func(mock TestingT) { Assert(mock, tab != "a\tc", V("tab", tab)) },
+		"raw string": func(mock TestingT) { Assert(mock, path != `C:\dir`, V("path", path)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			mock := testutil.NewMockT()
+			assert(mock)
+			output := mock.GetOutput()
+			if !mock.Failed() || strings.Contains(output, "MISMATCH") {
+				t.Errorf("A literal with escapes should read as the value it denotes, got: %s", output)
+			}
+		})
+	}
+}
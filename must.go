@@ -0,0 +1,127 @@
+package diagassert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// Must takes the results of a call returning a value and an error, and returns a function
+// that checks the error and returns the value. If the error is not nil, it outputs
+// detailed diagnostic information and terminates the test:
+//
+//	f := Must(os.Open(path))(t)
+//	cfg := Must(config.Load(f))(t, "the fixture should load")
+//
+// Go only passes several results to a function on their own, hence the second call for t.
+// The failure shows the call with both results, the error and the chain of errors it
+// wraps. Trailing args are handled as in Assert.
+func Must[T any](value T, err error) func(t TestingT, args ...interface{}) T {
+	return func(t TestingT, args ...interface{}) T {
+		t.Helper()
+		recordAssertion(err == nil, "")
+
+		if err != nil {
+			ctx := newContext(t, args)
+			addGoroutines(ctx)
+			failure := buildErrorFailureInfo("Must", value, err, ctx)
+			reportFailure(t, failure, true)
+		}
+		return value
+	}
+}
+
+// Check2 checks the error returned together with value and returns value. If the error is
+// not nil, it outputs detailed diagnostic information, including the chain of errors it
+// wraps, and fails the test without terminating it:
+//
+//	f, err := os.Open(path)
+//	f = Check2(t, f, err, "the fixture should open")
+//
+// Trailing args are handled as in Assert.
+func Check2[T any](t TestingT, value T, err error, args ...interface{}) T {
+	t.Helper()
+	recordAssertion(err == nil, "")
+
+	if err != nil {
+		failure := buildErrorFailureInfo("Check2", value, err, newContext(t, args))
+		reportFailure(t, failure, false)
+	}
+	return value
+}
+
+// buildErrorFailureInfo builds diagnostic information for an error checked by Must or
+// Check2, named by helper. Must shows the call that returned the error, Check2 compares the
+// error to nil.
+func buildErrorFailureInfo(helper string, value interface{}, err error, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source the error is named generically
+	callText, errText := "", "err"
+	if args, parseErr := parser.ExtractCallArguments(site.file, site.line, helper); parseErr == nil {
+		switch {
+		case helper == "Must" && len(args) == 1:
+			callText = args[0]
+		case helper == "Check2" && len(args) >= 3:
+			errText = args[2]
+		}
+	}
+
+	result := evaluator.EvaluateError(callText, errText, value, err)
+	evaluator.Redact(result)
+	ctx.sections = append(ctx.sections, formatter.Section{Title: "ERROR CHAIN", Lines: errorChain(err)})
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
+
+// errorChain describes err and the errors it wraps, one per line with its type, outermost
+// first. The errors joined by errors.Join or wrapped by several %w verbs are indented below
+// the error that holds them, which is shown by its type and their number. Messages spanning
+// several lines, as joined errors' do, are kept to one with semicolons.
+func errorChain(err error) []string {
+	var lines []string
+	var walk func(err error, indent string)
+	walk = func(err error, indent string) {
+		for err != nil {
+			switch wrapped := err.(type) {
+			case interface{ Unwrap() error }:
+				lines = append(lines, fmt.Sprintf("%s%T: %s", indent, err, strings.ReplaceAll(err.Error(), "\n", "; ")))
+				err = wrapped.Unwrap()
+			case interface{ Unwrap() []error }:
+				// Their messages are those of the errors listed below it
+				inner := wrapped.Unwrap()
+				lines = append(lines, fmt.Sprintf("%s%T (%d errors)", indent, err, len(inner)))
+				for _, e := range inner {
+					walk(e, indent+"  ")
+				}
+				return
+			default:
+				lines = append(lines, fmt.Sprintf("%s%T: %s", indent, err, strings.ReplaceAll(err.Error(), "\n", "; ")))
+				return
+			}
+		}
+	}
+	walk(err, "")
+	return lines
+}
@@ -0,0 +1,95 @@
+package diagassert
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestMust(t *testing.T) {
+	t.Run("nil error returns the value", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		n := Must(strconv.Atoi("42"))(mock)
+
+		if mock.Failed() || n != 42 {
+			t.Errorf("Must should pass and return 42, got %d: %s", n, mock.GetOutput())
+		}
+	})
+
+	t.Run("error terminates with the call and its chain", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		func() {
+			defer func() { recover() }() // MockT.Fatal panics
+			Must(strconv.Atoi("4x2"))(mock, "port setting")
+		}()
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at must_test.go:",
+			`assert(strconv.Atoi("4x2"))`,
+			`LIKELY CAUSE: strconv.Atoi("4x2") returned an error: strconv.Atoi: parsing "4x2": invalid syntax`,
+			"RETURNED ERRORS:\n  - " + `strconv.Atoi("4x2"): strconv.Atoi: parsing "4x2": invalid syntax`,
+			"CUSTOM MESSAGE:\nport setting",
+			"ERROR CHAIN:\n  *strconv.NumError: strconv.Atoi: parsing \"4x2\": invalid syntax\n  *errors.errorString: invalid syntax",
+			"ERROR_CHAIN_START",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+}
+
+func TestCheck2(t *testing.T) {
+	t.Run("nil error returns the value", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		n, err := strconv.Atoi("7")
+		n = Check2(mock, n, err)
+
+		if mock.Failed() || n != 7 {
+			t.Errorf("Check2 should pass and return 7, got %d: %s", n, mock.GetOutput())
+		}
+	})
+
+	t.Run("error fails without terminating and returns the value", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		loadErr := fmt.Errorf("load config: %w", errors.New("missing key"))
+		name := Check2(mock, "default", loadErr, "config")
+
+		output := mock.GetOutput()
+		if !mock.Failed() || name != "default" {
+			t.Fatalf("Check2 should fail and return the value, got %q", name)
+		}
+		expected := []string{
+			"assert(loadErr == nil)",
+			"LIKELY CAUSE: loadErr == nil is false because loadErr = load config: missing key",
+			"ERROR CHAIN:\n  *fmt.wrapError: load config: missing key\n  *errors.errorString: missing key",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+}
+
+func TestErrorChain(t *testing.T) {
+	err := fmt.Errorf("save: %w", errors.Join(errors.New("disk full"), fmt.Errorf("retry: %w", errors.New("timeout"))))
+
+	got := strings.Join(errorChain(err), "\n")
+	want := strings.Join([]string{
+		"*fmt.wrapError: save: disk full; retry: timeout",
+		"*errors.joinError (2 errors)",
+		"  *errors.errorString: disk full",
+		"  *fmt.wrapError: retry: timeout",
+		"  *errors.errorString: timeout",
+	}, "\n")
+	if got != want {
+		t.Errorf("errorChain =\n%s\nwant\n%s", got, want)
+	}
+}
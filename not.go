@@ -0,0 +1,26 @@
+package diagassert
+
+import "github.com/paveg/diagassert/internal/config"
+
+// Not checks that expr is false, and outputs detailed diagnostic information if it is not:
+//
+//	Not(t, strings.Contains(log, "panic"))
+//
+// The failure shows the negated expression, !strings.Contains(log, "panic"), with what made
+// expr true: searches with the strings, bytes and slices packages say what they found and
+// where, such as `"panic" found in log at index 42: "…goroutine panic: nil map…"`.
+// Trailing args are handled as in Assert.
+func Not(t TestingT, expr bool, args ...interface{}) {
+	t.Helper()
+	recordAssertion(!expr, "")
+
+	if !expr {
+		return
+	}
+
+	defer config.Hold()()
+	ctx := newContext(t, args)
+	ctx.negated = true
+	failure := buildFailureInfo(false, ctx)
+	reportFailure(t, failure, false)
+}
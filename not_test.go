@@ -0,0 +1,52 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestNot(t *testing.T) {
+	t.Run("passes when false", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		Not(mock, strings.Contains("ok", "panic"))
+		if mock.Failed() {
+			t.Errorf("Not should pass for a false expression, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("shows what was found", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		log := "worker 3 started\npanic: assignment to entry in nil map"
+		Not(mock, strings.Contains(log, "panic"), V("log", log))
+
+		output := mock.GetOutput()
+		expected := []string{
+			`assert(!strings.Contains(log, "panic"))`,
+			`LIKELY CAUSE: !strings.Contains(log, "panic") is false because strings.Contains(log, "panic") = true`,
+			`"panic" found in log at index 17: …"orker 3 started\npanic: assignment to "…`,
+			`EXPR: !strings.Contains(log, "panic")`,
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+		if strings.Contains(output, `V("strings"`) {
+			t.Errorf("The strings package should not be hinted at, got: %s", output)
+		}
+	})
+
+	t.Run("parenthesizes operators", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		got, want := 1, 1
+		Not(mock, got == want, V("got", got), V("want", want))
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "assert(!(got == want))") ||
+			!strings.Contains(output, "!(got == want) is false because got == want = true") {
+			t.Errorf("Output should show the negated comparison, got: %s", output)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package diagassert
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// OutputWriter carries a writer a failure is also written to. See OutputTo.
+type OutputWriter struct {
+	w io.Writer
+}
+
+// OutputTo writes the assertion's diagnostic to w as well as to the test log, without
+// colors, so that large failures can be read on their own. Nothing is written when the
+// assertion passes. To keep a file per test instead, set DIAGASSERT_OUTPUT_DIR.
+//
+// Usage: diagassert.Assert(t, got == want, diagassert.OutputTo(&buf))
+func OutputTo(w io.Writer) OutputWriter {
+	return OutputWriter{w: w}
+}
+
+// addWriter appends w unless writers holds it already, as when several assertions of a
+// retried attempt write to the same buffer.
+func addWriter(writers []io.Writer, w io.Writer) []io.Writer {
+	if reflect.TypeOf(w).Comparable() {
+		for _, existing := range writers {
+			if existing == w {
+				return writers
+			}
+		}
+	}
+	return append(writers, w)
+}
+
+var (
+	outputFilesMu sync.Mutex
+	outputFiles   = map[string]bool{} // Files this process has written, appended to from then on
+)
+
+// routeOutput writes a reported failure to the writers passed with OutputTo and, when
+// DIAGASSERT_OUTPUT_DIR is set, to the file of its test. Write errors are ignored: the
+// failure still reaches the test log.
+func routeOutput(test, output string, writers []io.Writer) {
+	formatter.Route(output, writers...)
+	if dir := config.Getenv("DIAGASSERT_OUTPUT_DIR"); dir != "" {
+		writeOutputFile(dir, test, output)
+	}
+}
+
+// writeOutputFile appends a failure to <dir>/<test>.log, named after t.Name() with the
+// slashes of subtests replaced. A file left over from an earlier run is truncated the first
+// time this process writes to it.
+func writeOutputFile(dir, test, output string) {
+	if test == "" {
+		test = "diagassert"
+	}
+	path := filepath.Join(dir, safeFileName(test)+".log")
+
+	outputFilesMu.Lock()
+	defer outputFilesMu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !outputFiles[path] {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	outputFiles[path] = true
+	formatter.Route(output, f)
+}
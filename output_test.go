@@ -0,0 +1,90 @@
+package diagassert
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestOutputTo(t *testing.T) {
+	t.Setenv("FORCE_COLOR", "1")
+
+	var buf bytes.Buffer
+	mock := testutil.NewMockT()
+	x := 5
+	Assert(mock, x > 10, V("x", x), OutputTo(&buf))
+
+	if !mock.Failed() {
+		t.Fatal("The assertion should still fail the test")
+	}
+	written := buf.String()
+	if !strings.Contains(written, "ASSERTION FAILED") || !strings.Contains(written, "VARIABLES: x=5") {
+		t.Errorf("The writer should receive the diagnostic, got: %s", written)
+	}
+	if strings.Contains(written, "\x1b[") {
+		t.Errorf("The writer should receive the diagnostic without colors, got: %q", written)
+	}
+	if !strings.HasSuffix(written, "\n\n") {
+		t.Errorf("Diagnostics should be separated by a blank line, got: %q", written)
+	}
+
+	buf.Reset()
+	Assert(testutil.NewMockT(), x < 10, OutputTo(&buf))
+	if buf.Len() != 0 {
+		t.Errorf("A passing assertion should write nothing, got: %s", buf.String())
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "failures")
+	t.Setenv("DIAGASSERT_OUTPUT_DIR", dir)
+
+	stale := filepath.Join(dir, "TestLogin_admin.log")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("from an earlier run\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mock := namedMockT{MockT: testutil.NewMockT(), name: "TestLogin/admin"}
+	role, attempts := "guest", 4
+	Assert(mock, role == "admin")
+	Assert(mock, attempts < 3)
+
+	data, err := os.ReadFile(stale)
+	if err != nil {
+		t.Fatalf("The test's failures should be written to %s: %v", stale, err)
+	}
+	written := string(data)
+	if strings.Contains(written, "earlier run") {
+		t.Errorf("A file from an earlier run should be replaced, got: %s", written)
+	}
+	if strings.Count(written, "ASSERTION FAILED") != 2 || !strings.Contains(written, `role == "admin"`) || !strings.Contains(written, "attempts < 3") {
+		t.Errorf("Both failures should be written to the test's file, got: %s", written)
+	}
+
+	Retry(mock, 2, 0, func(a *Attempt) {
+		Assert(a, role == "admin")
+	})
+	data, _ = os.ReadFile(stale)
+	if !strings.Contains(string(data), "RETRY HISTORY (2 attempts") {
+		t.Errorf("Retried failures should be written with their history, got: %s", data)
+	}
+}
+
+func TestOutputToRetry(t *testing.T) {
+	var buf bytes.Buffer
+	Retry(testutil.NewMockT(), 2, 0, func(a *Attempt) {
+		Assert(a, a.Number > 2, OutputTo(&buf))
+		Assert(a, a.Number > 3, OutputTo(&buf))
+	})
+
+	if got := strings.Count(buf.String(), "RETRY HISTORY"); got != 1 {
+		t.Errorf("The retried failures should be written once, got %d times:\n%s", got, buf.String())
+	}
+}
@@ -0,0 +1,17 @@
+// Package protodiag teaches diagassert to render protobuf messages with prototext and to
+// report failed == comparisons between messages as field paths, instead of formatting the
+// generated structs with their internal state, sizeCache and unknownFields fields.
+//
+// The package is compiled only with the diagassert_protobuf build tag, so modules that do
+// not use protobuf never build against it. Import it for its side effects:
+//
+//	import _ "github.com/paveg/diagassert/protodiag"
+//
+//	go test -tags diagassert_protobuf ./...
+//
+// A failed comparison of two messages then reports, for example:
+//
+//	DIFFERENCES in got == want:
+//	  user.address.city: "Berlin" != "Paris"
+//	  user.roles: length 1 != 2
+package protodiag
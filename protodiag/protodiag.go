@@ -0,0 +1,166 @@
+//go:build diagassert_protobuf
+
+package protodiag
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+func init() {
+	formatter.RegisterValueRenderer(render)
+	evaluator.RegisterDiffer(diff)
+}
+
+// render formats a message as prototext: indented for captured values, on one line elsewhere.
+func render(v interface{}) (string, string, bool) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return "", "", false
+	}
+	if !msg.ProtoReflect().IsValid() {
+		return "<nil>", "<nil>", true
+	}
+
+	full := prototext.MarshalOptions{Multiline: true, Indent: "  "}.Format(msg)
+	compact := strings.Join(strings.Fields(prototext.Format(msg)), " ")
+	return strings.TrimRight(full, "\n"), "{" + compact + "}", true
+}
+
+// diff compares two messages of the same type field by field.
+func diff(left, right interface{}) ([]string, bool) {
+	l, lok := left.(proto.Message)
+	r, rok := right.(proto.Message)
+	if !lok || !rok {
+		return nil, false
+	}
+
+	lm, rm := l.ProtoReflect(), r.ProtoReflect()
+	if lm.Descriptor().FullName() != rm.Descriptor().FullName() {
+		return []string{fmt.Sprintf("(root): %s != %s", lm.Descriptor().FullName(), rm.Descriptor().FullName())}, true
+	}
+
+	var diffs []string
+	diffMessage("", lm, rm, &diffs)
+	return diffs, true
+}
+
+// diffMessage appends one entry per differing field, named by its field mask path.
+func diffMessage(path string, l, r protoreflect.Message, diffs *[]string) {
+	fields := l.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		fieldPath := joinPath(path, string(fd.Name()))
+
+		hasL, hasR := l.Has(fd), r.Has(fd)
+		if !hasL && !hasR {
+			continue
+		}
+
+		switch {
+		case fd.IsList():
+			diffList(fieldPath, fd, l.Get(fd).List(), r.Get(fd).List(), diffs)
+		case fd.IsMap():
+			diffMap(fieldPath, fd, l.Get(fd).Map(), r.Get(fd).Map(), diffs)
+		case fd.Message() != nil && hasL && hasR:
+			diffMessage(fieldPath, l.Get(fd).Message(), r.Get(fd).Message(), diffs)
+		case hasL != hasR:
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s",
+				fieldPath, formatField(fd, l.Get(fd), hasL), formatField(fd, r.Get(fd), hasR)))
+		case !l.Get(fd).Equal(r.Get(fd)):
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s",
+				fieldPath, formatValue(fd, l.Get(fd)), formatValue(fd, r.Get(fd))))
+		}
+	}
+}
+
+func diffList(path string, fd protoreflect.FieldDescriptor, l, r protoreflect.List, diffs *[]string) {
+	if l.Len() != r.Len() {
+		*diffs = append(*diffs, fmt.Sprintf("%s: length %d != %d", path, l.Len(), r.Len()))
+	}
+	for i := 0; i < l.Len() && i < r.Len(); i++ {
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+		if fd.Message() != nil {
+			diffMessage(elemPath, l.Get(i).Message(), r.Get(i).Message(), diffs)
+		} else if !l.Get(i).Equal(r.Get(i)) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s", elemPath, formatValue(fd, l.Get(i)), formatValue(fd, r.Get(i))))
+		}
+	}
+}
+
+func diffMap(path string, fd protoreflect.FieldDescriptor, l, r protoreflect.Map, diffs *[]string) {
+	keys := map[string]protoreflect.MapKey{}
+	collect := func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys[k.String()] = k
+		return true
+	}
+	l.Range(collect)
+	r.Range(collect)
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	valueField := fd.MapValue()
+	for _, name := range names {
+		key := keys[name]
+		entryPath := fmt.Sprintf("%s[%s]", path, strconv.Quote(name))
+		hasL, hasR := l.Has(key), r.Has(key)
+		switch {
+		case hasL != hasR:
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s",
+				entryPath, formatField(valueField, l.Get(key), hasL), formatField(valueField, r.Get(key), hasR)))
+		case valueField.Message() != nil:
+			diffMessage(entryPath, l.Get(key).Message(), r.Get(key).Message(), diffs)
+		case !l.Get(key).Equal(r.Get(key)):
+			*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s",
+				entryPath, formatValue(valueField, l.Get(key)), formatValue(valueField, r.Get(key))))
+		}
+	}
+}
+
+// formatField renders a field value, or "unset" when it is not populated.
+func formatField(fd protoreflect.FieldDescriptor, v protoreflect.Value, has bool) string {
+	if !has {
+		return "unset"
+	}
+	return formatValue(fd, v)
+}
+
+// formatValue renders a singular value the way prototext would.
+func formatValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return strconv.Quote(v.String())
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("%q", v.Bytes())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		_, compact, _ := render(v.Message().Interface())
+		return compact
+	}
+	return v.String()
+}
+
+// joinPath appends a field name to a field mask path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
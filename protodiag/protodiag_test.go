@@ -0,0 +1,94 @@
+//go:build diagassert_protobuf
+
+package protodiag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/paveg/diagassert"
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func newFile(name string, messages ...string) *descriptorpb.FileDescriptorProto {
+	file := &descriptorpb.FileDescriptorProto{Name: proto.String(name), Syntax: proto.String("proto3")}
+	for _, m := range messages {
+		file.MessageType = append(file.MessageType, &descriptorpb.DescriptorProto{Name: proto.String(m)})
+	}
+	return file
+}
+
+func TestDiff(t *testing.T) {
+	got := newFile("user.proto", "User", "Address")
+	got.Options = &descriptorpb.FileOptions{GoPackage: proto.String("example.com/user")}
+	want := newFile("user.proto", "User", "Account", "Role")
+	want.Options = &descriptorpb.FileOptions{GoPackage: proto.String("example.com/users"), JavaPackage: proto.String("com.example")}
+
+	diffs, ok := diff(got, want)
+	if !ok {
+		t.Fatal("diff should handle proto messages")
+	}
+
+	expected := []string{
+		"message_type: length 2 != 3",
+		`message_type[1].name: "Address" != "Account"`,
+		`options.java_package: unset != "com.example"`,
+		`options.go_package: "example.com/user" != "example.com/users"`,
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("diff = %q, want %q", diffs, expected)
+	}
+
+	if _, ok := diff(got, "not a message"); ok {
+		t.Error("diff should not handle non-message values")
+	}
+}
+
+func TestRender(t *testing.T) {
+	full, compact, ok := render(newFile("a.proto", "A"))
+	if !ok {
+		t.Fatal("render should handle proto messages")
+	}
+	if !strings.Contains(full, "message_type") || !strings.Contains(full, "\n") {
+		t.Errorf("full rendering should be multi-line prototext, got %q", full)
+	}
+	if strings.Contains(compact, "\n") || !strings.HasPrefix(compact, "{name:") {
+		t.Errorf("compact rendering should be one line, got %q", compact)
+	}
+	if _, _, ok := render(42); ok {
+		t.Error("render should not handle non-message values")
+	}
+}
+
+func TestAssertWithMessages(t *testing.T) {
+	mock := testutil.NewMockT()
+	got := newFile("a.proto", "A")
+	want := newFile("a.proto", "B")
+
+	diagassert.Assert(mock, proto.Equal(got, want), diagassert.V("got", got), diagassert.V("want", want))
+
+	output := mock.GetOutput()
+	if strings.Contains(output, "sizeCache") || strings.Contains(output, "unknownFields") {
+		t.Errorf("Output should not show internal message state, got: %s", output)
+	}
+	if !strings.Contains(output, `name: "a.proto"`) {
+		t.Errorf("Captured values should be rendered as prototext, got: %s", output)
+	}
+}
+
+func TestAssertProtoEqual(t *testing.T) {
+	mock := testutil.NewMockT()
+	got := newFile("a.proto", "A")
+	want := newFile("a.proto", "B")
+
+	diagassert.Assert(mock, proto.Equal(got, want), diagassert.V("got", got), diagassert.V("want", want))
+
+	output := mock.GetOutput()
+	if !strings.Contains(output, "DIFFERENCES in proto.Equal(got, want):\n  message_type[0].name: \"A\" != \"B\"") {
+		t.Errorf("proto.Equal failures should list differing fields, got: %s", output)
+	}
+}
@@ -0,0 +1,41 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+type provenanceAccount struct{ Balance int }
+
+func TestAssert_ValueSources(t *testing.T) {
+	account := provenanceAccount{Balance: 40}
+	mock := testutil.NewMockT()
+	Assert(mock, account.Balance >= 100, V("account", account), V("account.Balance", 50))
+
+	output := mock.GetOutput()
+	expected := []string{
+		"50ᵛ",
+		"ᵛ passed with V or Values, not worked out from the expression",
+		"account.Balance was passed as 50, but the account passed holds 40",
+		"SOURCES: account.Balance=user\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(output, part) {
+			t.Errorf("Output should contain %q, got: %s", part, output)
+		}
+	}
+
+	// Variables can only be passed, so their values are not marked
+	mock = testutil.NewMockT()
+	balance := 40
+	Assert(mock, balance >= 100, V("balance", balance))
+	output = mock.GetOutput()
+	if strings.Contains(output, "ᵛ") {
+		t.Errorf("Variables should not be marked, got: %s", output)
+	}
+	if !strings.Contains(output, "SOURCES: balance=user\n") {
+		t.Errorf("Output should list the source of balance, got: %s", output)
+	}
+}
@@ -0,0 +1,20 @@
+package diagassert
+
+import "github.com/paveg/diagassert/internal/evaluator"
+
+// PureMethods marks methods as free of side effects. Assert receives the result of its
+// expression, so to show the results of the method calls in it, a failure calls them again,
+// marking their values "(re-evaluated)". A method that changes something, such as one taking
+// the next value of a counter or channel, then runs twice. With DIAGASSERT_REEVALUATE set to
+// "pure", only the methods listed here or in the comma-separated DIAGASSERT_PURE_METHODS are
+// called again, along with the methods of time's values and String and Error; the values of
+// others are left out, and can be passed with V.
+//
+// Each name is a method, as "IsAdult"; a method of a type, as "User.IsAdult"; or every
+// method of a type, as "User.*". Types may be qualified by the last element of their package
+// path, as "models.User.*".
+//
+// Usage: diagassert.PureMethods("User.IsAdult", "Order.*")
+func PureMethods(names ...string) {
+	evaluator.AddPureMethods(names...)
+}
@@ -0,0 +1,63 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+type pureCounter struct{ n int }
+
+func (c *pureCounter) Next() int { c.n++; return c.n }
+
+func (c *pureCounter) Peek() int { return c.n }
+
+func TestPureMethods(t *testing.T) {
+	t.Run("all", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_REEVALUATE", "")
+		c := &pureCounter{}
+		mock := testutil.NewMockT()
+		Assert(mock, c.Next() > 5, V("c", c))
+
+		if c.n != 2 {
+			t.Errorf("Next should be called again to show its result, called %d times", c.n)
+		}
+		if output := mock.GetOutput(); !strings.Contains(output, "2 (re-evaluated)") {
+			t.Errorf("Output should mark the result as re-evaluated, got: %s", output)
+		}
+	})
+
+	t.Run("pure", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_REEVALUATE", "pure")
+		c := &pureCounter{}
+		mock := testutil.NewMockT()
+		Assert(mock, c.Next() > 5, V("c", c))
+
+		if c.n != 1 {
+			t.Errorf("Next should not be called again, called %d times", c.n)
+		}
+		output := mock.GetOutput()
+		expected := []string{
+			"c.Next() was not called again, as it is not listed as free of side effects",
+			`diagassert.V("c.Next()", c.Next())`,
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("listed", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_REEVALUATE", "pure")
+		PureMethods("pureCounter.Peek")
+		c := &pureCounter{n: 3}
+		mock := testutil.NewMockT()
+		Assert(mock, c.Peek() > 5, V("c", c))
+
+		if output := mock.GetOutput(); !strings.Contains(output, "3 (re-evaluated)") {
+			t.Errorf("Listed methods should be called again, got: %s", output)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package diagassert
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Recorder is a TestingT for the goroutines a test starts. Assertions made on it from any
+// goroutine are recorded instead of reaching the test, whose methods must not be called once
+// it may have finished and whose output would interleave, and are reported by Flush in the
+// order they were made:
+//
+//	rec := diagassert.NewRecorder(t)
+//	for _, job := range jobs {
+//		wg.Add(1)
+//		go func(job Job) {
+//			defer wg.Done()
+//			diagassert.Assert(rec, job.Run() == nil, diagassert.V("job", job.ID))
+//		}(job)
+//	}
+//	wg.Wait()
+//	rec.Flush()
+//
+// Require on a Recorder ends the calling goroutine, as runtime.Goexit does, and makes Flush
+// terminate the test once everything recorded is reported.
+type Recorder struct {
+	t TestingT
+
+	mu       sync.Mutex
+	recorded []recording
+	reported int // Recordings already reported by Flush
+	fatal    bool
+}
+
+// recording is a failure recorded by a Recorder: the structured failure of an assertion,
+// or, when failure is nil, the arguments of a failure reported directly on it, e.g. by a
+// helper package.
+type recording struct {
+	failure *FailureInfo
+	args    []interface{}
+}
+
+// NewRecorder returns a Recorder reporting to t. When t has a Cleanup method, as
+// *testing.T does, Flush is also registered with it, so failures recorded by goroutines the
+// test does not wait for are reported when it ends.
+func NewRecorder(t TestingT) *Recorder {
+	r := &Recorder{t: t}
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(r.Flush)
+	}
+	return r
+}
+
+// Error records a failure reported directly on the recorder.
+func (r *Recorder) Error(args ...interface{}) {
+	r.record(recording{args: args}, false)
+}
+
+// Fatal records a failure and ends the calling goroutine.
+func (r *Recorder) Fatal(args ...interface{}) {
+	r.record(recording{args: args}, true)
+}
+
+// Helper does nothing: the recorder's failures are reported to the test later, from the
+// goroutine calling Flush, each with the location its assertion found for itself.
+func (r *Recorder) Helper() {}
+
+// Failed reports whether any assertion failed on the recorder, reported or not.
+func (r *Recorder) Failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.recorded) > 0
+}
+
+// recordFailure keeps the structured failure of an Assert or Require made on the recorder.
+func (r *Recorder) recordFailure(failure FailureInfo, fatal bool) {
+	r.record(recording{failure: &failure}, fatal)
+}
+
+// record keeps a failure and, for fatal ones, ends the calling goroutine.
+func (r *Recorder) record(rec recording, fatal bool) {
+	r.mu.Lock()
+	r.recorded = append(r.recorded, rec)
+	r.fatal = r.fatal || fatal
+	r.mu.Unlock()
+
+	if fatal {
+		runtime.Goexit()
+	}
+}
+
+// Flush reports the failures recorded since the last Flush to the test, in the order they
+// were recorded, running the failure hooks for each. It must be called from the test's
+// goroutine, after the goroutines asserting on the recorder are done. If a Require failed,
+// the last failure terminates the test.
+func (r *Recorder) Flush() {
+	r.t.Helper()
+
+	r.mu.Lock()
+	pending := r.recorded[r.reported:]
+	r.reported = len(r.recorded)
+	fatal := r.fatal && len(pending) > 0
+	if fatal {
+		r.fatal = false
+	}
+	r.mu.Unlock()
+
+	for i, rec := range pending {
+		last := fatal && i == len(pending)-1
+		if rec.failure == nil {
+			if last {
+				r.t.Fatal(rec.args...)
+				return
+			}
+			r.t.Error(rec.args...)
+			continue
+		}
+		reportFailure(r.t, *rec.failure, last)
+	}
+}
@@ -0,0 +1,130 @@
+package diagassert
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// cleanupT is a MockT that runs its cleanups when told to, as a test does when it ends.
+type cleanupT struct {
+	*testutil.MockT
+	cleanups []func()
+}
+
+func (c *cleanupT) Cleanup(fn func()) { c.cleanups = append(c.cleanups, fn) }
+
+func (c *cleanupT) end() {
+	for i := len(c.cleanups) - 1; i >= 0; i-- {
+		c.cleanups[i]()
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	t.Run("failures are reported in the order they were made", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		rec := NewRecorder(mock)
+
+		// Each worker waits for the previous one, so the order is known
+		var wg sync.WaitGroup
+		turn := make([]chan struct{}, 4)
+		for i := range turn {
+			turn[i] = make(chan struct{})
+		}
+		for worker := 1; worker <= 3; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				<-turn[worker-1]
+				Assert(rec, worker == 0, V("worker", worker))
+				close(turn[worker])
+			}(worker)
+		}
+		close(turn[0])
+		wg.Wait()
+
+		if mock.Failed() {
+			t.Fatal("Nothing should reach the test before Flush")
+		}
+		if !rec.Failed() {
+			t.Fatal("The recorder should have failed")
+		}
+		rec.Flush()
+
+		output := mock.GetOutput()
+		first := strings.Index(output, "worker == 0 is false because worker = 1")
+		second := strings.Index(output, "worker == 0 is false because worker = 2")
+		third := strings.Index(output, "worker == 0 is false because worker = 3")
+		if first < 0 || !(first < second && second < third) {
+			t.Errorf("The failures should be reported in order, got: %s", output)
+		}
+		if strings.Count(output, "ASSERTION FAILED at recorder_test.go:") != 3 {
+			t.Errorf("Each failure should be reported on its own, got: %s", output)
+		}
+	})
+
+	t.Run("require ends the goroutine and the test", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		rec := NewRecorder(mock)
+		reached := false
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			Require(rec, reached)
+			reached = true
+		}()
+		<-done
+		rec.Error("reported directly")
+
+		if reached {
+			t.Error("Require should end the goroutine")
+		}
+		fatal := func() (fatal bool) {
+			defer func() { fatal = recover() != nil }() // MockT.Fatal panics
+			rec.Flush()
+			return false
+		}()
+		if !fatal || !strings.Contains(mock.GetOutput(), "reported directly") {
+			t.Errorf("Flush should report every failure and then terminate the test, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("cleanup flushes what is left", func(t *testing.T) {
+		ct := &cleanupT{MockT: testutil.NewMockT()}
+		rec := NewRecorder(ct)
+
+		Assert(rec, 1 > 2)
+		rec.Flush()
+		Assert(rec, 3 > 4)
+		ct.end()
+
+		output := ct.GetOutput()
+		if strings.Count(output, "EXPR: 1 > 2") != 1 || strings.Count(output, "EXPR: 3 > 4") != 1 {
+			t.Errorf("Each failure should be reported once, got: %s", output)
+		}
+	})
+
+	t.Run("concurrent assertions", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		rec := NewRecorder(mock)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				Assert(rec, i < 0, fmt.Sprintf("worker %d", i))
+			}(i)
+		}
+		wg.Wait()
+		rec.Flush()
+
+		if n := strings.Count(mock.GetOutput(), "CUSTOM MESSAGE:"); n != 20 {
+			t.Errorf("Every failure should be reported, got %d", n)
+		}
+	})
+}
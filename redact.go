@@ -0,0 +1,23 @@
+package diagassert
+
+import "github.com/paveg/diagassert/internal/evaluator"
+
+// Redact masks the values of the named variables, struct fields, method calls and map keys
+// as "***" in every failure, in the diagram, the captured values, the machine-readable
+// section and the values passed to failure hooks. Names are matched without regard to case,
+// so Redact("password") masks password, user.Password, user.Password() and
+// creds["password"]. Struct fields can also be marked with a tag:
+//
+//	type Credentials struct {
+//		User   string
+//		Secret string `diag:"redact"`
+//	}
+//
+// Masked strings and byte slices read "***"; fields of other types show their zero value.
+// The test's own values are never modified. DIAGASSERT_REDACT lists further names,
+// separated by commas.
+//
+// Usage: diagassert.Redact("password", "token")
+func Redact(names ...string) {
+	evaluator.RedactNames(names...)
+}
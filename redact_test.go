@@ -0,0 +1,85 @@
+package diagassert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+type credentials struct {
+	User  string
+	Token string `diag:"redact"`
+}
+
+func (c credentials) Password() string { return "s3cret-pw" }
+
+func TestRedact(t *testing.T) {
+	Redact("password")
+
+	var hooked FailureInfo
+	remove := OnFailure(func(f FailureInfo) { hooked = f })
+	defer remove()
+
+	mock := testutil.NewMockT()
+	creds := credentials{User: "alice", Token: "tok-abc123"}
+	want := credentials{User: "alice", Token: "tok-xyz789"}
+	password := "hunter2"
+	Assert(mock, creds == want && password == "letmein",
+		V("creds", creds), V("want", want), V("password", password), V("creds.Password()", creds.Password()))
+
+	output := mock.GetOutput()
+	leaked := fmt.Sprint(output, hooked.Variables, hooked.Steps)
+	for _, secret := range []string{"tok-abc123", "tok-xyz789", "hunter2", "s3cret-pw"} {
+		if strings.Contains(leaked, secret) {
+			t.Errorf("%q should be masked everywhere, got:\n%s", secret, leaked)
+		}
+	}
+
+	steps := output[strings.Index(output, "EVALUATION_STEPS:"):]
+	if !strings.Contains(steps, "`creds` => {alice ***}") {
+		t.Errorf("EVALUATION_STEPS should show the masked value, got:\n%s", steps)
+	}
+	for _, shown := range []string{"DIFF: creds == want: .Token: ***", "VALUE: password = *** (string)", "VALUE: creds = {alice ***}"} {
+		if !strings.Contains(output, shown) {
+			t.Errorf("Output should contain %q, got:\n%s", shown, output)
+		}
+	}
+
+	if creds.Token != "tok-abc123" {
+		t.Errorf("The test's own value should not change, got %q", creds.Token)
+	}
+}
+
+func TestRedact_StringDifferences(t *testing.T) {
+	Redact("password")
+
+	mock := testutil.NewMockT()
+	password := "hunter3-SECRETVALUX"
+	Assert(mock, password == "hunter3-SECRETVALUE", V("password", password))
+
+	// Where the strings first differ and their lengths would tell the secret but for a rune
+	output := mock.GetOutput()
+	differences := output[strings.Index(output, "DIFFERENCES"):strings.Index(output, "CAPTURED VALUES")]
+	for _, leak := range []string{"SECRETVALUX", "first difference", "common prefix", "len 19"} {
+		if strings.Contains(output, leak) {
+			t.Errorf("%q should not be shown, got:\n%s", leak, output)
+		}
+	}
+	if !strings.Contains(differences, "password: ***") || !strings.Contains(output, "DIFF: password == \"hunter3-SECRETVALUE\": password: ***") {
+		t.Errorf("DIFFERENCES and DIFF should mask the secret, got:\n%s", output)
+	}
+}
+
+func TestRedactEnvironment(t *testing.T) {
+	t.Setenv("DIAGASSERT_REDACT", "apiKey, sessionID")
+
+	mock := testutil.NewMockT()
+	sessionID := "sess-42"
+	Assert(mock, sessionID == "", V("sessionID", sessionID))
+
+	if output := mock.GetOutput(); strings.Contains(output, "sess-42") {
+		t.Errorf("Names listed in DIAGASSERT_REDACT should be masked, got:\n%s", output)
+	}
+}
@@ -0,0 +1,194 @@
+package diagassert
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// MaxRepeats limits the full reports of an assertion failing again and again. See WithMaxRepeats.
+type MaxRepeats int
+
+// WithMaxRepeats reports the first n failures of an assertion in a test in full and only
+// counts the next ones, which are summarized with their values when the test ends:
+//
+//	for _, tt := range cases {
+//		diagassert.Assert(t, parse(tt.in) == tt.want, diagassert.WithMaxRepeats(3))
+//	}
+//
+// A failing table-driven loop then reports
+//
+//	ASSERTION FAILED at parse_test.go:12 repeated 997 more times: parse(tt.in) == tt.want
+//	  tt.in = "b", tt.want = 2
+//	  ...
+//
+// n of 0 or less reports every failure in full. The default is DIAGASSERT_MAX_REPEATS, and
+// without it every failure is reported in full.
+// Failures are told apart by their test, location and expression, and only tests with a
+// Cleanup method, as *testing.T has, summarize them. Failure hooks still see every failure.
+func WithMaxRepeats(n int) MaxRepeats {
+	if n <= 0 {
+		return MaxRepeats(-1)
+	}
+	return MaxRepeats(n)
+}
+
+const (
+	// defaultMaxRepeats of 0 reports every failure in full: summarizing is opt-in
+	defaultMaxRepeats = 0
+	// maxRepeatValues bounds the values listed in a summary of repeated failures
+	maxRepeatValues = 10
+)
+
+// repeatLimit returns the number of failures reported in full for a limit taken from
+// WithMaxRepeats, 0 for none and -1 for no limit, falling back to DIAGASSERT_MAX_REPEATS.
+func repeatLimit(limit int) int {
+	if limit != 0 {
+		return limit
+	}
+	n, ok := config.Int("DIAGASSERT_MAX_REPEATS")
+	if !ok {
+		n = defaultMaxRepeats
+	}
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+// repeatKey identifies an assertion failing repeatedly within a test.
+type repeatKey struct {
+	t          TestingT
+	file       string
+	line       int
+	expression string
+}
+
+// repeats counts the failures of an assertion and keeps what its summary shows of the
+// ones not reported in full.
+type repeats struct {
+	count      int      // Failures so far
+	suppressed int      // Failures counted since the last summary
+	values     []string // Values of the first suppressed failures, one line each
+	writers    []io.Writer
+	timer      *time.Timer // Summarizes before the test's deadline
+}
+
+var (
+	repeatsMu sync.Mutex
+	repeated  = map[repeatKey]*repeats{}
+)
+
+// suppressRepeat counts a non-fatal failure and reports whether it is one too many to be
+// reported in full. The first suppressed failure of an assertion schedules its summary at
+// the end of the test and, for tests with a deadline, shortly before it, so that a test
+// timing out still shows what it hid.
+func suppressRepeat(t TestingT, failure FailureInfo) bool {
+	limit := repeatLimit(failure.maxRepeats)
+	cleaner, ok := t.(interface{ Cleanup(func()) })
+	if limit < 0 || failure.File == "" || !ok || !reflect.TypeOf(t).Comparable() {
+		return false
+	}
+	key := repeatKey{t: t, file: failure.File, line: failure.Line, expression: failure.Expression}
+
+	repeatsMu.Lock()
+	defer repeatsMu.Unlock()
+
+	r := repeated[key]
+	if r == nil {
+		r = &repeats{}
+		repeated[key] = r
+		cleaner.Cleanup(func() { endRepeats(key) })
+	}
+	r.count++
+	if r.count <= limit {
+		return false
+	}
+
+	r.suppressed++
+	if len(r.values) < maxRepeatValues {
+		r.values = append(r.values, repeatValues(failure))
+	}
+	for _, w := range failure.writers {
+		r.writers = addWriter(r.writers, w)
+	}
+	if r.timer == nil {
+		if deadline, ok := deadlineOf(t); ok {
+			// A tenth of the time left is kept for the summary to be written
+			r.timer = time.AfterFunc(time.Until(deadline)*9/10, func() { summarizeRepeats(key) })
+		}
+	}
+	return true
+}
+
+// deadlineOf returns the time a test times out at, for tests with a Deadline method such
+// as *testing.T.
+func deadlineOf(t TestingT) (time.Time, bool) {
+	if d, ok := t.(interface{ Deadline() (time.Time, bool) }); ok {
+		return d.Deadline()
+	}
+	return time.Time{}, false
+}
+
+// endRepeats summarizes what an assertion hid once its test ends, and forgets it.
+func endRepeats(key repeatKey) {
+	summarizeRepeats(key)
+
+	repeatsMu.Lock()
+	defer repeatsMu.Unlock()
+	if r := repeated[key]; r != nil && r.timer != nil {
+		r.timer.Stop()
+	}
+	delete(repeated, key)
+}
+
+// summarizeRepeats reports the failures of an assertion counted since its last summary,
+// if any. t.Error may be called from any goroutine, so summaries made by the deadline
+// timer are safe too.
+func summarizeRepeats(key repeatKey) {
+	repeatsMu.Lock()
+	r := repeated[key]
+	if r == nil || r.suppressed == 0 {
+		repeatsMu.Unlock()
+		return
+	}
+	suppressed, values, writers := r.suppressed, r.values, r.writers
+	r.suppressed, r.values = 0, nil
+	repeatsMu.Unlock()
+
+	var b strings.Builder
+	b.WriteString(formatter.Message(formatter.MsgRepeated, filepath.Base(key.file), key.line, suppressed))
+	if key.expression != "" {
+		b.WriteString(": " + key.expression)
+	}
+	for _, line := range values {
+		b.WriteString("\n  " + line)
+	}
+	if more := suppressed - len(values); more > 0 {
+		b.WriteString(fmt.Sprintf("\n  ... %d more", more))
+	}
+
+	output := b.String()
+	routeOutput(testName(key.t), output, writers)
+	key.t.Error(encodeOutput(output))
+}
+
+// repeatValues lists the known values of a failure on one line, e.g. `tt.in = "b", tt.want = 2`.
+func repeatValues(failure FailureInfo) string {
+	values := knownVariables(failure.Variables)
+	parts := make([]string, 0, len(values))
+	for _, name := range sortedNames(values) {
+		parts = append(parts, fmt.Sprintf("%s = %s", name, formatRetryValue(values[name])))
+	}
+	if len(parts) == 0 {
+		return "(no values)"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,114 @@
+package diagassert
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// deadlineT is a cleanupT whose test times out at deadline. Like *testing.T, and unlike
+// MockT, it may be reported to from other goroutines.
+type deadlineT struct {
+	*cleanupT
+	deadline time.Time
+	mu       sync.Mutex
+}
+
+func (d *deadlineT) Deadline() (time.Time, bool) { return d.deadline, true }
+
+func (d *deadlineT) Error(args ...interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cleanupT.Error(args...)
+}
+
+func (d *deadlineT) GetOutput() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cleanupT.GetOutput()
+}
+
+func TestWithMaxRepeats(t *testing.T) {
+	t.Run("repeats are summarized at the end of the test", func(t *testing.T) {
+		var hooked int
+		remove := OnFailure(func(FailureInfo) { hooked++ })
+		defer remove()
+
+		ct := &cleanupT{MockT: testutil.NewMockT()}
+		for i := 1; i <= 6; i++ {
+			Assert(ct, i < 0, V("i", i), WithMaxRepeats(2))
+		}
+
+		if n := strings.Count(ct.GetOutput(), "ASSERTION FAILED at repeats_test.go:"); n != 2 {
+			t.Errorf("Only 2 failures should be reported before the test ends, got %d", n)
+		}
+		ct.end()
+
+		output := ct.GetOutput()
+		summary := "repeated 4 more times: i < 0\n  i = 3\n  i = 4\n  i = 5\n  i = 6"
+		if !strings.Contains(output, summary) {
+			t.Errorf("Output should contain %q, got: %s", summary, output)
+		}
+		if hooked != 6 {
+			t.Errorf("Hooks should see every failure, saw %d", hooked)
+		}
+	})
+
+	t.Run("default and unlimited", func(t *testing.T) {
+		tests := []struct {
+			name string
+			env  string
+			args []interface{}
+			want int
+		}{
+			{"default", "", nil, 8},
+			{"environment", "3", nil, 3},
+			{"environment unlimited", "0", nil, 8},
+			{"option over environment", "3", []interface{}{WithMaxRepeats(0)}, 8},
+			{"option over default", "", []interface{}{WithMaxRepeats(2)}, 2},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				t.Setenv("DIAGASSERT_MAX_REPEATS", tt.env)
+				ct := &cleanupT{MockT: testutil.NewMockT()}
+				for i := 0; i < 8; i++ {
+					Assert(ct, i < 0, tt.args...)
+				}
+
+				if n := strings.Count(ct.GetOutput(), "ASSERTION FAILED at repeats_test.go:"); n != tt.want {
+					t.Errorf("%d failures should be reported in full, got %d", tt.want, n)
+				}
+			})
+		}
+	})
+
+	t.Run("tests without cleanup report everything", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		for i := 0; i < 3; i++ {
+			Assert(mock, i < 0, WithMaxRepeats(1))
+		}
+
+		if n := strings.Count(mock.GetOutput(), "ASSERTION FAILED at repeats_test.go:"); n != 3 {
+			t.Errorf("Every failure should be reported, got %d", n)
+		}
+	})
+
+	t.Run("summary before the deadline", func(t *testing.T) {
+		dt := &deadlineT{cleanupT: &cleanupT{MockT: testutil.NewMockT()}, deadline: time.Now().Add(50 * time.Millisecond)}
+		for i := 0; i < 3; i++ {
+			Assert(dt, i < 0, WithMaxRepeats(1))
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		if !strings.Contains(dt.GetOutput(), "repeated 2 more times") {
+			t.Errorf("The repeats should be summarized before the deadline, got: %s", dt.GetOutput())
+		}
+		dt.end()
+		if n := strings.Count(dt.GetOutput(), "more times"); n != 1 {
+			t.Errorf("The repeats should be summarized once, got %d summaries", n)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package diagassert
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+	"github.com/paveg/diagassert/internal/repro"
+)
+
+// reproSuffix ends the names of the files addRepro writes, which are test files.
+const reproSuffix = "_diagassert_repro_test.go"
+
+// addRepro writes a test reproducing a failed assertion when DIAGASSERT_REPRO is "true",
+// and adds its path to the output under REPRO. The test declares the captured values as
+// literals and asserts expr again, so a failure in CI can be replayed by copying the file
+// into the test's package. It is written to the artifacts directory, see
+// DIAGASSERT_ARTIFACTS_DIR, as <file>_<line>_<fingerprint>_diagassert_repro_test.go, the
+// fingerprint telling apart failures of different operands at one line.
+func addRepro(ctx *AssertionContext, site callSite, expr string, variables map[string]interface{}, fingerprint string) {
+	if config.Getenv("DIAGASSERT_REPRO") != "true" || expr == "" {
+		return
+	}
+
+	pkg, imports, err := parser.FileImports(site.file)
+	if err != nil {
+		return
+	}
+	var pkgPath string
+	if fn := runtime.FuncForPC(site.pc); fn != nil {
+		pkgPath = packagePath(fn.Name())
+	}
+
+	base := filepath.Base(site.file)
+	src, err := repro.Source(repro.Failure{
+		Package:   pkg,
+		PkgPath:   pkgPath,
+		File:      base,
+		Line:      site.line,
+		Expr:      expr,
+		Variables: knownVariables(variables),
+		Imports:   imports,
+	})
+	if err != nil {
+		return
+	}
+
+	dir := artifactsDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	path := filepath.Join(dir, strings.TrimSuffix(base, ".go")+"_"+strconv.Itoa(site.line)+"_"+fingerprint+reproSuffix)
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		return
+	}
+	ctx.sections = append(ctx.sections, formatter.Section{Title: "REPRO", Lines: []string{path}})
+}
+
+// packagePath returns the import path of the package declaring a function, e.g.
+// example.com/app/user for example.com/app/user.TestCheck.func1.
+func packagePath(function string) string {
+	slash := strings.LastIndex(function, "/") + 1
+	if dot := strings.Index(function[slash:], "."); dot >= 0 {
+		return function[:slash+dot]
+	}
+	return function
+}
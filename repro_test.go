@@ -0,0 +1,72 @@
+package diagassert
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestRepro(t *testing.T) {
+	t.Run("failures write a test declaring the captured values", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("DIAGASSERT_REPRO", "true")
+		t.Setenv("DIAGASSERT_ARTIFACTS_DIR", dir)
+
+		mock := testutil.NewMockT()
+		items, limit := []int{4, 5}, 3
+		Assert(mock, len(items) < limit-1, V("items", items), V("limit", limit))
+
+		files, _ := filepath.Glob(filepath.Join(dir, "*"+reproSuffix))
+		if len(files) != 1 || !strings.HasPrefix(filepath.Base(files[0]), "repro_test_") {
+			t.Fatalf("a repro file should be written, got %v", files)
+		}
+		match := regexp.MustCompile(`FINGERPRINT: (\w+)`).FindStringSubmatch(mock.GetOutput())
+		if match == nil || !strings.HasSuffix(files[0], "_"+match[1]+reproSuffix) {
+			t.Errorf("the repro file should be named after the fingerprint, got %s", files[0])
+		}
+		if output := mock.GetOutput(); !strings.Contains(output, "REPRO:\n  "+files[0]) {
+			t.Errorf("output should point at the repro file, got:\n%s", output)
+		}
+
+		src, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"package diagassert", "items := []int{4, 5}", "limit := 3", "Assert(t, len(items) < limit-1)"} {
+			if !strings.Contains(string(src), want) {
+				t.Errorf("repro should contain %q, got:\n%s", want, src)
+			}
+		}
+	})
+
+	t.Run("nothing is written by default", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("DIAGASSERT_ARTIFACTS_DIR", dir)
+
+		mock := testutil.NewMockT()
+		x := 1
+		Assert(mock, x == 2, V("x", x))
+
+		if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 || strings.Contains(mock.GetOutput(), "REPRO") {
+			t.Errorf("no repro should be written without DIAGASSERT_REPRO, got %v", files)
+		}
+	})
+}
+
+func TestPackagePath(t *testing.T) {
+	tests := map[string]string{
+		"example.com/app/user.TestCheck.func1":  "example.com/app/user",
+		"example.com/app/user_test.TestCheck":   "example.com/app/user_test",
+		"github.com/paveg/diagassert.TestRepro": "github.com/paveg/diagassert",
+		"main.TestX":                            "main",
+	}
+	for function, want := range tests {
+		if got := packagePath(function); got != want {
+			t.Errorf("packagePath(%q) = %q, want %q", function, got, want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package diagassert
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Attempt is the TestingT passed to the function run by Retry. Assertions made on it are
+// recorded instead of failing the test, so that eventually consistent conditions can be
+// polled without reporting the attempts that were merely too early.
+type Attempt struct {
+	Number int // 1-based number of this attempt
+
+	failures []FailureInfo
+	fatal    bool
+}
+
+// errAttemptStopped is panicked by Fatal to end an attempt early; Retry recovers it.
+type errAttemptStopped struct{}
+
+// Error records a failure reported directly on the attempt, e.g. by a helper package.
+func (a *Attempt) Error(args ...interface{}) {
+	a.failures = append(a.failures, FailureInfo{Output: fmt.Sprint(args...)})
+}
+
+// Fatal records a failure and ends the attempt.
+func (a *Attempt) Fatal(args ...interface{}) {
+	a.Error(args...)
+	a.fatal = true
+	panic(errAttemptStopped{})
+}
+
+// Helper does nothing: an attempt only keeps its failures for Retry, which reports the
+// last attempt's at the location of their assertions.
+func (a *Attempt) Helper() {}
+
+// Failed reports whether any assertion failed during this attempt.
+func (a *Attempt) Failed() bool {
+	return len(a.failures) > 0
+}
+
+// recordFailure keeps the structured failure of an Assert or Require made on the attempt.
+func (a *Attempt) recordFailure(failure FailureInfo, fatal bool) {
+	a.failures = append(a.failures, failure)
+	if fatal {
+		a.fatal = true
+		panic(errAttemptStopped{})
+	}
+}
+
+// run calls fn, treating a Require failure as the end of the attempt.
+func (a *Attempt) run(fn func(a *Attempt)) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(errAttemptStopped); !ok {
+				panic(r)
+			}
+		}
+	}()
+	fn(a)
+}
+
+// Retry runs fn up to attempts times, waiting delay between attempts, until an attempt
+// makes no failing assertion. Failures are only reported if every attempt fails: the last
+// attempt's full diagnostics are followed by a history of what each attempt saw.
+//
+// Usage:
+//
+//	diagassert.Retry(t, 10, 100*time.Millisecond, func(a *diagassert.Attempt) {
+//		status := job.Status()
+//		diagassert.Assert(a, status == "done", diagassert.V("status", status))
+//	})
+func Retry(t TestingT, attempts int, delay time.Duration, fn func(a *Attempt)) {
+	t.Helper()
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	history := make([]*Attempt, 0, attempts)
+	for i := 1; i <= attempts; i++ {
+		attempt := &Attempt{Number: i}
+		attempt.run(fn)
+		if !attempt.Failed() {
+			return
+		}
+
+		history = append(history, attempt)
+		if i < attempts {
+			time.Sleep(delay)
+		}
+	}
+
+	last := history[len(history)-1]
+	name := testName(t)
+	issue := expectedFailure(t)
+	outputs := make([]string, 0, len(last.failures))
+	var writers []io.Writer
+	for _, failure := range last.failures {
+		if issue == "" {
+			recordHistory(failure.fingerprint)
+		}
+		if failure.File != "" {
+			failure.Test = name
+			runFailureHooks(failure)
+		}
+		outputs = append(outputs, strings.TrimRight(failure.Output, "\n"))
+		for _, w := range failure.writers {
+			writers = addWriter(writers, w)
+		}
+	}
+
+	output := strings.Join(outputs, "\n\n") + "\n\n" + formatRetryHistory(history, delay)
+	reportCollected(t, name, issue, output, writers, last.fatal)
+}
+
+// formatRetryHistory summarizes every attempt on one line per failed assertion, showing
+// captured values and whether they changed since the previous attempt:
+//
+//	attempt 2: status == "done" (status = "running", unchanged)
+func formatRetryHistory(history []*Attempt, delay time.Duration) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("RETRY HISTORY (%d attempts, %v apart):\n", len(history), delay))
+
+	previous := map[string]map[string]interface{}{}
+	for _, attempt := range history {
+		current := map[string]map[string]interface{}{}
+		for _, failure := range attempt.failures {
+			summary := failureSummary(failure)
+			values := knownVariables(failure.Variables)
+			current[summary] = values
+
+			var details []string
+			before, seen := previous[summary]
+			for _, name := range sortedNames(values) {
+				detail := fmt.Sprintf("%s = %s", name, formatRetryValue(values[name]))
+				if seen {
+					if old, ok := before[name]; !ok || fmt.Sprint(old) != fmt.Sprint(values[name]) {
+						detail += fmt.Sprintf(", was %s", formatRetryValue(old))
+					} else {
+						detail += ", unchanged"
+					}
+				}
+				details = append(details, detail)
+			}
+
+			line := fmt.Sprintf("  attempt %d: %s", attempt.Number, summary)
+			if len(details) > 0 {
+				line += " (" + strings.Join(details, "; ") + ")"
+			}
+			b.WriteString(line + "\n")
+		}
+		previous = current
+	}
+
+	return b.String()
+}
+
+// failureSummary names a failure by its expression, or by the first line of its output
+// when it was reported without one.
+func failureSummary(failure FailureInfo) string {
+	if failure.Expression != "" {
+		return failure.Expression
+	}
+	summary, _, _ := strings.Cut(strings.TrimSpace(failure.Output), "\n")
+	return summary
+}
+
+// knownVariables drops the "<name>" placeholders of values that could not be extracted.
+func knownVariables(variables map[string]interface{}) map[string]interface{} {
+	known := make(map[string]interface{}, len(variables))
+	for name, value := range variables {
+		if s, ok := value.(string); ok && s == "<"+name+">" {
+			continue
+		}
+		known[name] = value
+	}
+	return known
+}
+
+func sortedNames(values map[string]interface{}) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatRetryValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,80 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestRetry(t *testing.T) {
+	t.Run("passing attempt reports nothing", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		calls := 0
+		Retry(mock, 5, 0, func(a *Attempt) {
+			calls++
+			Assert(a, calls == 3, V("calls", calls))
+		})
+
+		if mock.Failed() {
+			t.Errorf("Retry should pass once an attempt passes, got: %s", mock.GetOutput())
+		}
+		if calls != 3 {
+			t.Errorf("Expected 3 attempts, got %d", calls)
+		}
+	})
+
+	t.Run("all attempts failing reports last diagnostics and history", func(t *testing.T) {
+		var hooked []FailureInfo
+		remove := OnFailure(func(f FailureInfo) { hooked = append(hooked, f) })
+		defer remove()
+
+		mock := testutil.NewMockT()
+		statuses := []string{"queued", "running", "running"}
+		Retry(mock, 3, 0, func(a *Attempt) {
+			status := statuses[a.Number-1]
+			Assert(a, status == "done", V("status", status))
+		})
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at retry_test.go:",
+			`status == "done" is false because status = running`,
+			"RETRY HISTORY (3 attempts, 0s apart):",
+			`  attempt 1: status == "done" (status = "queued")`,
+			`  attempt 2: status == "done" (status = "running", was "queued")`,
+			`  attempt 3: status == "done" (status = "running", unchanged)`,
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+		if strings.Count(output, "ASSERTION FAILED") != 1 {
+			t.Errorf("Only the last attempt's diagnostics should be shown, got: %s", output)
+		}
+		if len(hooked) != 1 {
+			t.Errorf("Hooks should run once for the reported failure, ran %d times", len(hooked))
+		}
+	})
+
+	t.Run("require ends the attempt", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		reached := false
+
+		func() {
+			defer func() { recover() }() // MockT.Fatal panics
+			Retry(mock, 2, 0, func(a *Attempt) {
+				Require(a, a.Number > 5)
+				reached = true
+			})
+		}()
+
+		if reached {
+			t.Error("Require should end the attempt")
+		}
+		if !mock.Failed() || !strings.Contains(mock.GetOutput(), "attempt 2: a.Number > 5") {
+			t.Errorf("Retry should report the Require failure, got: %s", mock.GetOutput())
+		}
+	})
+}
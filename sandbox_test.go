@@ -0,0 +1,62 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// flakyService works when the test calls it, and fails when a failure calls it again.
+type flakyService struct{ calls int }
+
+func (s *flakyService) Status() int {
+	s.calls++
+	if s.calls > 1 {
+		panic("connection reset")
+	}
+	return 500
+}
+
+func (s *flakyService) Latency() int {
+	s.calls++
+	if s.calls > 1 {
+		time.Sleep(time.Hour)
+	}
+	return 900
+}
+
+func TestAssert_SandboxedCalls(t *testing.T) {
+	t.Run("panic", func(t *testing.T) {
+		s := &flakyService{}
+		mock := testutil.NewMockT()
+		Assert(mock, s.Status() == 200, V("s", s))
+
+		output := mock.GetOutput()
+		expected := []string{
+			"(evaluation panicked: connection reset)",
+			"Step 2: `s.Status()` => (evaluation panicked: connection reset)",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_CALL_TIMEOUT", "20ms")
+		s := &flakyService{}
+		mock := testutil.NewMockT()
+		start := time.Now()
+		Assert(mock, s.Latency() < 500, V("s", s))
+
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("The failure should not wait for the call, took %v", elapsed)
+		}
+		if output := mock.GetOutput(); !strings.Contains(output, "(evaluation timed out after 20ms)") {
+			t.Errorf("Output should say the call timed out, got: %s", output)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package diagassert
+
+import (
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// Steps is a scenario of a test, written as Given and When steps followed by the Then
+// assertions they lead to. See Scenario.
+type Steps struct {
+	t      TestingT
+	steps  []string // "Given ..." and "When ..." lines, in order
+	values []Value  // Values of the steps, the latest of each name only
+}
+
+// Scenario starts a scenario of steps on t. The values of every Given and When step are
+// carried into the failures of the Then assertions after it, which also list the steps:
+//
+//	diagassert.Scenario(t).
+//		Given("a cart with two items", diagassert.V("cart", cart)).
+//		When("the order is placed", diagassert.V("order", order)).
+//		Then(order.Total == cart.Total())
+//
+// A failing Then is reported like Assert, with the steps under SCENARIO and their values
+// among the captured values, so the failure of the last step shows how it was reached.
+func Scenario(t TestingT) *Steps {
+	return &Steps{t: t}
+}
+
+// Given adds a step setting up the scenario, described by desc, with the values it leads to.
+func (s *Steps) Given(desc string, values ...Value) *Steps {
+	return s.step("Given "+desc, values)
+}
+
+// When adds a step acting on the scenario, described by desc, with the values it leads to.
+func (s *Steps) When(desc string, values ...Value) *Steps {
+	return s.step("When "+desc, values)
+}
+
+// step records a step, its values replacing those of earlier steps with the same name.
+func (s *Steps) step(line string, values []Value) *Steps {
+	s.steps = append(s.steps, line)
+	for _, v := range values {
+		replaced := false
+		for i := range s.values {
+			if s.values[i].Name == v.Name {
+				s.values[i].Value = v.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			s.values = append(s.values, v)
+		}
+	}
+	return s
+}
+
+// Then checks expr as Assert does, adding the steps so far and their values to its failure.
+// Values in args take precedence over those of the steps. Thens can be chained, each
+// checked against every step before it.
+func (s *Steps) Then(expr bool, args ...interface{}) *Steps {
+	s.t.Helper()
+	recordAssertion(expr, "")
+
+	if expr {
+		return s
+	}
+
+	defer config.Hold()()
+	ctx := newContext(s.t, args)
+	provided := ctx.GetValuesMap()
+	for _, v := range s.values {
+		if _, ok := provided[v.Name]; !ok {
+			ctx.Values = append(ctx.Values, v)
+		}
+	}
+	if len(s.steps) > 0 {
+		ctx.sections = append(ctx.sections, formatter.Section{Title: "SCENARIO", Lines: s.steps})
+	}
+
+	failure := buildFailureInfo(expr, ctx)
+	reportFailure(s.t, failure, false)
+	return s
+}
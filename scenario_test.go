@@ -0,0 +1,62 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestScenario(t *testing.T) {
+	t.Run("passing steps report nothing", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		items := 2
+		Scenario(mock).
+			Given("a cart", V("items", items)).
+			Then(items == 2).
+			Then(items > 0)
+
+		if mock.Failed() {
+			t.Errorf("Scenario should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("a failing Then shows the steps and their values", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		cart, total := 3, 4
+		Scenario(mock).
+			Given("a cart with three items", V("cart", cart), V("total", 0)).
+			When("the order is placed", V("total", total)).
+			Then(cart == total, "totals should match")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"assert(cart == total)",
+			"LIKELY CAUSE: cart == total is false because cart = 3, total = 4",
+			"SCENARIO:\n  Given a cart with three items\n  When the order is placed\n",
+			"CUSTOM MESSAGE:\ntotals should match",
+			"SCENARIO_START\nGiven a cart with three items\nWhen the order is placed\nSCENARIO_END",
+		}
+		for _, want := range expected {
+			if !strings.Contains(output, want) {
+				t.Errorf("output should contain %q, got:\n%s", want, output)
+			}
+		}
+		if strings.Contains(output, "total = 0") {
+			t.Errorf("a later step's value should replace an earlier one, got:\n%s", output)
+		}
+	})
+
+	t.Run("values passed to Then take precedence", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		count := 1
+		Scenario(mock).
+			Given("a count", V("count", 5)).
+			Then(count == 2, V("count", count))
+
+		output := mock.GetOutput()
+		if !strings.Contains(output, "count = 1 (int)") || strings.Contains(output, "count = 5") {
+			t.Errorf("the value passed to Then should be shown, got:\n%s", output)
+		}
+	})
+}
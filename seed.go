@@ -0,0 +1,116 @@
+package diagassert
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+var (
+	seedsMu     sync.Mutex
+	seedsByName = map[string]int64{}   // Seeds of named tests, which their subtests share
+	seedsByT    = map[TestingT]int64{} // Seeds of tests without a name
+)
+
+// Seed returns the seed for the random values of a test and adds it to every failure in
+// the test and its subtests, with the command that re-runs the test with it:
+//
+//	rng := rand.New(rand.NewSource(diagassert.Seed(t)))
+//
+// The seed is DIAGASSERT_SEED when set, so that a failure is reproduced by
+//
+//	DIAGASSERT_SEED=1712345678 go test -run '^TestShuffle$'
+//
+// and otherwise the seed passed in or, without one, a new seed for every run. Tests with a
+// Logf method log it.
+func Seed(t TestingT, seed ...int64) int64 {
+	t.Helper()
+
+	var value int64
+	if env, err := strconv.ParseInt(os.Getenv("DIAGASSERT_SEED"), 10, 64); err == nil {
+		value = env
+	} else if len(seed) > 0 {
+		value = seed[0]
+	} else {
+		value = time.Now().UnixNano()
+	}
+
+	name := testName(t)
+	seedsMu.Lock()
+	switch {
+	case name != "":
+		seedsByName[name] = value
+	case reflect.TypeOf(t).Comparable():
+		seedsByT[t] = value
+	}
+	seedsMu.Unlock()
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(func() {
+			seedsMu.Lock()
+			defer seedsMu.Unlock()
+			if name != "" {
+				delete(seedsByName, name)
+			} else {
+				delete(seedsByT, t)
+			}
+		})
+	}
+
+	if l, ok := t.(interface {
+		Logf(format string, args ...interface{})
+	}); ok {
+		l.Logf("SEED: %d, re-run with %s", value, rerunCommand(name, value))
+	}
+	return value
+}
+
+// seedOf returns the seed Seed set for t or, for subtests, the closest test above it.
+func seedOf(t TestingT) (int64, bool) {
+	seedsMu.Lock()
+	defer seedsMu.Unlock()
+	for name := testName(t); name != ""; {
+		if seed, ok := seedsByName[name]; ok {
+			return seed, true
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	if reflect.TypeOf(t).Comparable() {
+		seed, ok := seedsByT[t]
+		return seed, ok
+	}
+	return 0, false
+}
+
+// seedSection lists a test's seed and the command re-running the test named name with it,
+// under SEED.
+func seedSection(name string, seed int64) formatter.Section {
+	return formatter.Section{Title: "SEED", Lines: []string{
+		strconv.FormatInt(seed, 10),
+		"re-run with " + rerunCommand(name, seed),
+	}}
+}
+
+// rerunCommand returns the command that runs the test named name, or every test without
+// a name, with seed.
+func rerunCommand(name string, seed int64) string {
+	command := fmt.Sprintf("DIAGASSERT_SEED=%d go test", seed)
+	if name == "" {
+		return command
+	}
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return command + " -run '" + strings.Join(parts, "/") + "'"
+}
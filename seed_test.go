@@ -0,0 +1,76 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestSeed(t *testing.T) {
+	t.Setenv("DIAGASSERT_SEED", "")
+
+	t.Run("explicit", func(t *testing.T) {
+		if seed := Seed(t, 42); seed != 42 {
+			t.Fatalf("Seed should return the seed passed in, got %d", seed)
+		}
+
+		// Failures in subtests report the seed of the test above them
+		mock := namedMockT{testutil.NewMockT(), t.Name() + "/case"}
+		Assert(mock, 1 > 2)
+		output := mock.GetOutput()
+		expected := []string{
+			"SEED:\n  42\n  re-run with DIAGASSERT_SEED=42 go test -run '^TestSeed$/^explicit$/^case$'\n",
+			"SEED_START\n42\n",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_SEED", "7")
+		if seed := Seed(t, 42); seed != 7 {
+			t.Errorf("DIAGASSERT_SEED should take precedence, got %d", seed)
+		}
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		if seed := Seed(t); seed == 0 {
+			t.Errorf("Seed should make a seed when none is given")
+		}
+	})
+
+	t.Run("unnamed", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		Seed(mock, 3)
+		Assert(mock, 1 > 2)
+		if output := mock.GetOutput(); !strings.Contains(output, "SEED:\n  3\n  re-run with DIAGASSERT_SEED=3 go test\n") {
+			t.Errorf("Output should contain the seed, got: %s", output)
+		}
+
+		other := testutil.NewMockT()
+		Assert(other, 1 > 2)
+		if output := other.GetOutput(); strings.Contains(output, "SEED") {
+			t.Errorf("Tests without a seed should not report one, got: %s", output)
+		}
+	})
+}
+
+func TestRerunCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"", "DIAGASSERT_SEED=5 go test"},
+		{"TestShuffle", "DIAGASSERT_SEED=5 go test -run '^TestShuffle$'"},
+		{"TestShuffle/size_(10)", `DIAGASSERT_SEED=5 go test -run '^TestShuffle$/^size_\(10\)$'`},
+	}
+	for _, tt := range tests {
+		if got := rerunCommand(tt.name, 5); got != tt.expected {
+			t.Errorf("rerunCommand(%q) = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+// Package slogdiag mirrors failed assertions to a *slog.Logger as structured records, so
+// that test logs shipped to an aggregator carry queryable assertion data alongside the
+// console output.
+//
+// The package requires Go 1.21 for log/slog; with older toolchains it is empty.
+//
+// Usage:
+//
+//	func TestMain(m *testing.M) {
+//		logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+//		remove := slogdiag.Mirror(logger)
+//		code := m.Run()
+//		remove()
+//		os.Exit(code)
+//	}
+//
+// Each failure is logged at error level with the message "assertion failed" and the
+// attributes expr, file, line, vars (a group holding one attribute per captured variable)
+// and, when present, messages and attachments:
+//
+//	{"level":"ERROR","msg":"assertion failed","expr":"x > 10","file":"/src/app/x_test.go","line":12,"vars":{"x":5}}
+package slogdiag
@@ -0,0 +1,69 @@
+//go:build go1.21
+
+package slogdiag
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/paveg/diagassert"
+)
+
+// Message is the message of every record logged by Mirror.
+const Message = "assertion failed"
+
+// Mirror logs every failed assertion to logger until the returned function is called.
+// Failures are still reported to the test as usual.
+func Mirror(logger *slog.Logger) (remove func()) {
+	return diagassert.OnFailure(func(f diagassert.FailureInfo) {
+		logger.LogAttrs(context.Background(), slog.LevelError, Message, Attrs(f)...)
+	})
+}
+
+// Attrs returns the attributes Mirror logs for a failure, for callers that log failures
+// from their own hooks.
+func Attrs(f diagassert.FailureInfo) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("expr", f.Expression),
+		slog.String("file", f.File),
+		slog.Int("line", f.Line),
+	}
+
+	if vars := variableAttrs(f.Variables); len(vars) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "vars", Value: slog.GroupValue(vars...)})
+	}
+	if len(f.Messages) > 0 {
+		attrs = append(attrs, slog.Any("messages", f.Messages))
+	}
+	if len(f.Attachments) > 0 {
+		paths := make([]string, 0, len(f.Attachments))
+		for _, a := range f.Attachments {
+			if a.Path != "" {
+				paths = append(paths, a.Path)
+			}
+		}
+		attrs = append(attrs, slog.Any("attachments", paths))
+	}
+
+	return attrs
+}
+
+// variableAttrs converts captured variables to attributes sorted by name, leaving out the
+// "<name>" placeholders of variables whose values could not be extracted.
+func variableAttrs(variables map[string]interface{}) []slog.Attr {
+	names := make([]string, 0, len(variables))
+	for name, value := range variables {
+		if s, ok := value.(string); ok && s == "<"+name+">" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		attrs = append(attrs, slog.Any(name, variables[name]))
+	}
+	return attrs
+}
@@ -0,0 +1,82 @@
+//go:build go1.21
+
+package slogdiag
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert"
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestMirror(t *testing.T) {
+	var buf bytes.Buffer
+	remove := Mirror(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	mock := testutil.NewMockT()
+	x := 5
+	diagassert.Assert(mock, x > 10, diagassert.V("x", x), "x must be large")
+	remove()
+	diagassert.Assert(mock, x > 20)
+
+	if !mock.Failed() {
+		t.Fatal("Assert should still fail the test")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected exactly one record before remove, got %d: %s", len(lines), buf.String())
+	}
+
+	var record struct {
+		Level    string
+		Msg      string
+		Expr     string
+		File     string
+		Line     int
+		Vars     map[string]interface{}
+		Messages []string
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Record is not JSON: %v\n%s", err, lines[0])
+	}
+
+	if record.Level != "ERROR" || record.Msg != Message {
+		t.Errorf("Unexpected level or message: %s", lines[0])
+	}
+	if record.Expr != "x > 10" {
+		t.Errorf("Expected expr %q, got %q", "x > 10", record.Expr)
+	}
+	if !strings.HasSuffix(record.File, "slogdiag_test.go") || record.Line == 0 {
+		t.Errorf("Expected the test's location, got %s:%d", record.File, record.Line)
+	}
+	if record.Vars["x"] != float64(5) {
+		t.Errorf("Expected vars.x = 5, got %v", record.Vars)
+	}
+	if len(record.Messages) != 1 || record.Messages[0] != "x must be large" {
+		t.Errorf("Expected the custom message, got %v", record.Messages)
+	}
+}
+
+func TestAttrsSkipsPlaceholders(t *testing.T) {
+	attrs := Attrs(diagassert.FailureInfo{
+		Expression: "a == b",
+		Variables:  map[string]interface{}{"a": "<a>", "b": 2},
+	})
+
+	for _, attr := range attrs {
+		if attr.Key != "vars" {
+			continue
+		}
+		group := attr.Value.Group()
+		if len(group) != 1 || group[0].Key != "b" {
+			t.Errorf("Expected only b among vars, got %v", group)
+		}
+		return
+	}
+	t.Error("Expected a vars group")
+}
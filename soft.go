@@ -0,0 +1,134 @@
+package diagassert
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/formatter"
+)
+
+// SoftT is the TestingT returned by Soft. Assertions made on it are collected instead of
+// failing the test at once, and reported together when the test ends, so that a test
+// checking many conditions shows every one that does not hold in a single run.
+type SoftT struct {
+	t TestingT
+
+	mu       sync.Mutex
+	failures []FailureInfo
+	fatal    bool
+}
+
+// Soft returns a TestingT collecting the failures of the assertions made on it, each with
+// its full diagnostics, and reporting them together in one failure of t when the test ends:
+//
+//	soft := diagassert.Soft(t)
+//	diagassert.Assert(soft, user.Name != "")
+//	diagassert.Assert(soft, user.Age >= 18, diagassert.V("age", user.Age))
+//	diagassert.Assert(soft, strings.Contains(user.Email, "@"))
+//
+// The failures are reported by a cleanup registered with t, or, when t has no Cleanup
+// method, by calling Report. Require on a SoftT ends the test at once, as t.FailNow does,
+// and the failures collected so far are reported with it.
+func Soft(t TestingT) *SoftT {
+	t.Helper()
+
+	s := &SoftT{t: t}
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		// A cleanup's failures are located at the call of Cleanup past the helpers. The
+		// closure, unlike the method value's wrapper, is a frame testing finds, and with
+		// Soft a helper the summary is located at the call of Soft.
+		c.Cleanup(func() {
+			t.Helper()
+			s.Report()
+		})
+	}
+	return s
+}
+
+// Error collects a failure reported directly on the soft asserter, e.g. by a helper package.
+func (s *SoftT) Error(args ...interface{}) {
+	s.collect(FailureInfo{Output: fmt.Sprint(args...)}, false)
+}
+
+// Fatal collects a failure and ends the test.
+func (s *SoftT) Fatal(args ...interface{}) {
+	s.collect(FailureInfo{Output: fmt.Sprint(args...)}, true)
+}
+
+// Helper does nothing: each failure the soft asserter collects is located at the call of
+// its own assertion, not at the test's helpers.
+func (s *SoftT) Helper() {}
+
+// Name returns the name of the test, so that failures collected carry its seed and fixtures.
+func (s *SoftT) Name() string {
+	return testName(s.t)
+}
+
+// Failed reports whether any assertion failed on the soft asserter, reported or not.
+func (s *SoftT) Failed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.failures) > 0
+}
+
+// recordFailure collects the structured failure of an Assert or Require made on the soft
+// asserter.
+func (s *SoftT) recordFailure(failure FailureInfo, fatal bool) {
+	s.collect(failure, fatal)
+}
+
+// collect keeps a failure and, for fatal ones, ends the calling goroutine.
+func (s *SoftT) collect(failure FailureInfo, fatal bool) {
+	s.mu.Lock()
+	s.failures = append(s.failures, failure)
+	s.fatal = s.fatal || fatal
+	s.mu.Unlock()
+
+	if fatal {
+		runtime.Goexit()
+	}
+}
+
+// Report reports the failures collected since the last Report to the test in one failure,
+// running the failure hooks for each. It is called when the test ends if t has a Cleanup
+// method; otherwise it must be called once the assertions are made, from the test's
+// goroutine. If a Require failed, the test is terminated.
+func (s *SoftT) Report() {
+	s.t.Helper()
+
+	s.mu.Lock()
+	failures := s.failures
+	fatal := s.fatal
+	s.failures, s.fatal = nil, false
+	s.mu.Unlock()
+	if len(failures) == 0 {
+		return
+	}
+
+	name := testName(s.t)
+	issue := expectedFailure(s.t)
+	outputs := make([]string, 0, len(failures))
+	var writers []io.Writer
+	for i, failure := range failures {
+		if issue == "" {
+			recordHistory(failure.fingerprint)
+		}
+		if failure.File != "" {
+			failure.Test = name
+			if failure.Owners == nil {
+				failure.Owners = ownersOf(failure.File)
+			}
+			runFailureHooks(failure)
+		}
+		outputs = append(outputs, fmt.Sprintf("[%d/%d] %s", i+1, len(failures), strings.TrimRight(failure.Output, "\n")))
+		for _, w := range failure.writers {
+			writers = addWriter(writers, w)
+		}
+	}
+
+	output := formatter.Message(formatter.MsgSoftAssertions, len(failures)) + "\n\n" + strings.Join(outputs, "\n\n")
+	reportCollected(s.t, name, issue, output, writers, fatal)
+}
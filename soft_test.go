@@ -0,0 +1,155 @@
+package diagassert
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestSoft(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	t.Run("failures are reported together when the test ends", func(t *testing.T) {
+		ct := &cleanupT{MockT: testutil.NewMockT()}
+		var hooked []string
+		remove := OnFailure(func(f FailureInfo) { hooked = append(hooked, f.Expression) })
+		defer remove()
+
+		soft := Soft(ct)
+		name, age := "", 16
+		Assert(soft, name != "")
+		Assert(soft, age >= 18)
+		Assert(soft, age > 0)
+
+		if ct.Failed() {
+			t.Fatal("Nothing should reach the test before it ends")
+		}
+		if !soft.Failed() {
+			t.Fatal("The soft asserter should have failed")
+		}
+		ct.end()
+
+		output := ct.GetOutput()
+		if strings.Count(output, "SOFT ASSERTIONS: 2 failed") != 1 {
+			t.Errorf("The failures should be reported in one failure, got: %s", output)
+		}
+		first := strings.Index(output, "[1/2] ASSERTION FAILED at soft_test.go:")
+		second := strings.Index(output, "[2/2] ASSERTION FAILED at soft_test.go:")
+		if first < 0 || second < first {
+			t.Errorf("Each failure should be reported in order, got: %s", output)
+		}
+		if !strings.Contains(output, `assert(name != "")`) || !strings.Contains(output, "assert(age >= 18)") {
+			t.Errorf("Each failure should keep its diagnostics, got: %s", output)
+		}
+		if strings.Join(hooked, ", ") != `name != "", age >= 18` {
+			t.Errorf("Hooks should run for each failure, got: %q", hooked)
+		}
+	})
+
+	t.Run("require ends the test with what was collected", func(t *testing.T) {
+		ct := &cleanupT{MockT: testutil.NewMockT()}
+		soft := Soft(ct)
+		reached := false
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			Assert(soft, reached)
+			Require(soft, reached)
+			reached = true
+		}()
+		<-done
+		fatal := false
+		func() {
+			defer func() { fatal = recover() != nil }() // MockT.Fatal panics
+			ct.end()
+		}()
+
+		if reached {
+			t.Error("Require should end the test")
+		}
+		if !fatal {
+			t.Error("The report should terminate the test")
+		}
+		if !ct.Failed() || !strings.Contains(ct.GetOutput(), "SOFT ASSERTIONS: 2 failed") {
+			t.Errorf("Both failures should be reported, got: %s", ct.GetOutput())
+		}
+	})
+
+	t.Run("report without cleanup", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		soft := Soft(mock)
+		soft.Report()
+		if mock.Failed() {
+			t.Error("Nothing should be reported when no assertion failed")
+		}
+
+		ok := false
+		Assert(soft, ok)
+		soft.Report()
+		soft.Report()
+		if strings.Count(mock.GetOutput(), "SOFT ASSERTIONS: 1 failed") != 1 {
+			t.Errorf("Each failure should be reported once, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("the summary is located at the call of Soft", func(t *testing.T) {
+		lt := &locatingT{MockT: testutil.NewMockT(), helpers: map[string]bool{}}
+		_, file, line, _ := runtime.Caller(0)
+		soft := Soft(lt)
+		Assert(soft, 1 > 2)
+		lt.end()
+
+		if want := filepath.Base(file) + ":" + strconv.Itoa(line+1); lt.at != want {
+			t.Errorf("The summary should be located at %s, got %q", want, lt.at)
+		}
+	})
+}
+
+// locatingT is a cleanupT locating its failures as testing does: at the first caller of
+// Error that is not a helper, going on from the call of Cleanup on reaching the cleanup.
+type locatingT struct {
+	*testutil.MockT
+	helpers map[string]bool
+	cleanup func()
+	name    string
+	calls   []uintptr
+	at      string
+}
+
+func (l *locatingT) Helper() {
+	pc, _, _, _ := runtime.Caller(1)
+	l.helpers[runtime.FuncForPC(pc).Name()] = true
+}
+
+func (l *locatingT) Cleanup(fn func()) {
+	l.cleanup = fn
+	l.name = runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	l.calls = make([]uintptr, 32)
+	l.calls = l.calls[:runtime.Callers(2, l.calls)]
+}
+
+func (l *locatingT) end() { l.cleanup() }
+
+func (l *locatingT) Error(args ...interface{}) {
+	l.MockT.Error(args...)
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == l.name:
+			frames = runtime.CallersFrames(l.calls)
+		case !l.helpers[frame.Function]:
+			l.at = filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+			return
+		case !more:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package diagassert
+
+import (
+	"fmt"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// orderContext is the number of elements listed on each side of the out-of-order pair.
+const orderContext = 2
+
+// Sorted checks that slice is sorted by less, as sort.SliceIsSorted does, and outputs
+// detailed diagnostic information if not:
+//
+//	Sorted(t, users, func(a, b User) bool { return a.Age < b.Age })
+//
+// The failure shows the first element that sorts before the one preceding it, that pair
+// in the diagram of the less call, and the elements around it under ORDER. Trailing args
+// are handled as in Assert.
+func Sorted[T any](t TestingT, slice []T, less func(a, b T) bool, args ...interface{}) {
+	t.Helper()
+
+	index := 0
+	for i := 1; i < len(slice); i++ {
+		if less(slice[i], slice[i-1]) {
+			index = i
+			break
+		}
+	}
+	recordAssertion(index == 0, "")
+	if index == 0 {
+		return
+	}
+
+	elements := make([]interface{}, len(slice))
+	for i, elem := range slice {
+		elements[i] = elem
+	}
+	failure := buildOrderFailureInfo(elements, index, newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// buildOrderFailureInfo builds diagnostic information for a failed Sorted, whose elements
+// are out of order at index.
+func buildOrderFailureInfo(elements []interface{}, index int, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source, or for literals, the slice and the ordering are named generically
+	sliceText, lessText := "slice", "less"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, "Sorted"); err == nil && len(args) >= 3 {
+		sliceText, lessText = evaluator.NameText(args[1], sliceText), evaluator.NameText(args[2], lessText)
+	}
+
+	result := evaluator.EvaluateOrder(sliceText, lessText, index, elements[index-1], elements[index])
+	evaluator.Redact(result)
+	ctx.sections = append(ctx.sections, formatter.Section{Title: "ORDER", Lines: orderLines(sliceText, elements, index)})
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
+
+// orderLines lists the elements around the pair out of order at index, marking the pair
+// with ">":
+//
+//	  users[2] = {Name:Bob Age:30}
+//	> users[3] = {Name:Eve Age:41}
+//	> users[4] = {Name:Ann Age:25}
+func orderLines(sliceText string, elements []interface{}, index int) []string {
+	first, last := index-1-orderContext, index+orderContext
+	if first < 0 {
+		first = 0
+	}
+	if last > len(elements)-1 {
+		last = len(elements) - 1
+	}
+
+	lines := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		marker := " "
+		if i == index-1 || i == index {
+			marker = ">"
+		}
+		value := evaluator.RedactValue(sliceText, elements[i])
+		lines = append(lines, fmt.Sprintf("%s %s[%d] = %s", marker, sliceText, i, formatter.ValueText(value)))
+	}
+	return lines
+}
@@ -0,0 +1,71 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+func TestSorted(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	byAge := func(a, b user) bool { return a.Age < b.Age }
+
+	t.Run("sorted slices pass", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		Sorted(mock, []user{{"ann", 20}, {"bob", 20}, {"eve", 31}}, byAge)
+		Sorted(mock, []user{}, byAge)
+
+		if mock.Failed() {
+			t.Errorf("Sorted should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("failure shows the first pair out of order", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		users := []user{{"a", 20}, {"b", 25}, {"c", 30}, {"d", 41}, {"e", 25}, {"f", 50}, {"g", 60}, {"h", 70}}
+		Sorted(mock, users, byAge, "by age")
+
+		output := mock.GetOutput()
+		expected := []string{
+			"ASSERTION FAILED at sorted_test.go:",
+			"assert(!byAge(users[4], users[3]))",
+			"LIKELY CAUSE: !byAge(users[4], users[3]) is false because byAge(users[4], users[3]) = true",
+			"users[4] sorts before users[3], breaking the order at index 4",
+			"ORDER:\n    users[1] = {b 25}\n    users[2] = {c 30}\n  > users[3] = {d 41}\n  > users[4] = {e 25}\n    users[5] = {f 50}\n    users[6] = {g 60}\n",
+			"CUSTOM MESSAGE:\nby age",
+			"ORDER_START",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("literals are named generically", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		Sorted(mock, []int{3, 1}, func(a, b int) bool { return a < b })
+
+		output := mock.GetOutput()
+		for _, part := range []string{"assert(!less(slice[1], slice[0]))", "> slice[0] = 3\n  > slice[1] = 1"} {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+
+	t.Run("redacted slices", func(t *testing.T) {
+		Redact("pins")
+		mock := testutil.NewMockT()
+		pins := []string{"1111", "0000"}
+		Sorted(mock, pins, func(a, b string) bool { return a < b })
+
+		if output := mock.GetOutput(); strings.Contains(output, "1111") || strings.Contains(output, "0000") {
+			t.Errorf("The elements should be masked, got: %s", output)
+		}
+	})
+}
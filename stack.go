@@ -0,0 +1,167 @@
+package diagassert
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// CallerSkip moves the location a failure is reported at up the call stack. See WithCallerSkip.
+type CallerSkip int
+
+// WithCallerSkip reports a failure at the caller n frames above the assertion instead of at
+// the assertion itself, so that helpers wrapping diagassert point at the test that called them:
+//
+//	func requireAdult(t *testing.T, u User) {
+//		t.Helper()
+//		diagassert.Assert(t, u.Age >= 18, diagassert.WithCallerSkip(1))
+//	}
+//
+// The assertion is listed in the STACK section with every frame up to the reported caller.
+// Its expression is read from the assertion, or from the callers when the helpers pass it
+// down as a parameter; see AssertSkip.
+//
+// Usage: diagassert.Assert(t, expr, diagassert.WithCallerSkip(1))
+func WithCallerSkip(n int) CallerSkip {
+	return CallerSkip(n)
+}
+
+// callSite is where a failed assertion was made and where it is reported.
+type callSite struct {
+	pc   uintptr // Program counter of the assertion, for evaluating its expression
+	file string  // Source file of the assertion, from which the expression is read
+	line int
+
+	reportFile string // The assertion, or the caller WithCallerSkip points at
+	reportLine int
+	stack      []string        // Frames shown in the STACK section, from the assertion up
+	frames     []runtime.Frame // Frames from the assertion up to the reported caller
+}
+
+// locateCall finds the assertion skip frames above locateCall's caller, as counted by
+// runtime.Caller, and the location its failure is reported at. The frames for the STACK
+// section, the owners of the reported file and the values of the Table case it is made in
+// are kept in ctx.
+func locateCall(skip int, ctx *AssertionContext) (callSite, bool) {
+	depth := stackDepth()
+	pcs := make([]uintptr, ctx.CallerSkip+depth+1)
+	n := runtime.Callers(skip+2, pcs)
+	if n == 0 {
+		return callSite{}, false
+	}
+	frames := runtime.CallersFrames(pcs[:n])
+
+	// Without a requested depth the stack only bridges the assertion and the reported caller
+	var site callSite
+	module, withStack := mainModule(), depth > 0 || ctx.CallerSkip > 0
+	for i := 0; ; i++ {
+		frame, more := frames.Next()
+		if i == 0 {
+			site.pc, site.file, site.line = frame.PC, frame.File, frame.Line
+		}
+		if i <= ctx.CallerSkip {
+			site.reportFile, site.reportLine = frame.File, frame.Line
+			site.frames = append(site.frames, frame)
+		}
+		if withStack && inModule(frame.Function, module) {
+			site.stack = append(site.stack, fmt.Sprintf("%s:%d %s", frame.File, frame.Line, shortFunction(frame.Function)))
+		}
+		if !more {
+			break
+		}
+	}
+
+	ctx.stack = site.stack
+	ctx.owners = ownersOf(site.reportFile)
+	ctx.environ = environmentLines(ctx.EnvironmentKeys, ctx.runtime)
+	if ctx.tableCase != nil {
+		ctx.Values = append(ctx.Values, ctx.tableCase.values(site.file, site.line, ctx.GetValuesMap())...)
+	}
+	return site, true
+}
+
+// follow traces an asserted expression that helpers pass down as a parameter, like ok in
+//
+//	func check(t *testing.T, ok bool) { diagassert.AssertSkip(t, 1, ok) }
+//
+// back to the text their callers wrote, check(t, x > 5), up to the reported caller. The
+// site is moved to the frame the returned expression was read from, where it is evaluated.
+func (site *callSite) follow(expr string) string {
+	for i := 1; i < len(site.frames); i++ {
+		callee, caller := site.frames[i-1], site.frames[i]
+		index, err := parser.ParameterIndex(callee.File, callee.Line, expr)
+		if err != nil || index < 0 {
+			break
+		}
+		args, err := parser.ExtractCallArguments(caller.File, caller.Line, functionName(callee.Function))
+		if err != nil || index >= len(args) {
+			break
+		}
+		expr = args[index]
+		site.pc, site.file, site.line = caller.PC, caller.File, caller.Line
+	}
+	return expr
+}
+
+// functionName returns the name a function is called by in the source: Check for
+// example.com/app.Check, example.com/app.(*Suite).Check and example.com/app.Check[...].
+func functionName(function string) string {
+	function = strings.TrimSuffix(function, "[...]")
+	if i := strings.LastIndex(function, "."); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
+
+// stackDepth reads DIAGASSERT_STACK_DEPTH, the number of frames above the assertion shown
+// in the STACK section. It defaults to 0, which shows no stack.
+func stackDepth() int {
+	depth, ok := config.Int("DIAGASSERT_STACK_DEPTH")
+	if !ok || depth < 0 {
+		return 0
+	}
+	return depth
+}
+
+var (
+	mainModuleOnce sync.Once
+	mainModulePath string
+)
+
+// mainModule returns the path of the module the test binary was built for, or "" if unknown.
+func mainModule() string {
+	mainModuleOnce.Do(func() {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			mainModulePath = info.Main.Path
+		}
+	})
+	return mainModulePath
+}
+
+// inModule reports whether a function belongs to a package of the module, including its
+// external test packages. Without a module, every function outside the runtime and the
+// testing package does.
+func inModule(function, module string) bool {
+	if module == "" {
+		return !strings.HasPrefix(function, "runtime.") && !strings.HasPrefix(function, "testing.")
+	}
+	if !strings.HasPrefix(function, module) {
+		return false
+	}
+	rest := function[len(module):]
+	return strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "_test.")
+}
+
+// shortFunction strips the directories from a function name: example.com/app/user.Check
+// becomes user.Check.
+func shortFunction(function string) string {
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
@@ -0,0 +1,197 @@
+package diagassert
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// lineHere returns the line it is called from.
+func lineHere() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+func requirePositive(t TestingT, n int, args ...interface{}) {
+	t.Helper()
+	Assert(t, n > 0, args...)
+}
+
+func checkOrder(t TestingT, total int) {
+	t.Helper()
+	requirePositive(t, total, WithCallerSkip(2))
+}
+
+func captureFailure(t *testing.T, assert func(mock *testutil.MockT)) FailureInfo {
+	t.Helper()
+	var got FailureInfo
+	remove := OnFailure(func(f FailureInfo) { got = f })
+	defer remove()
+
+	mock := testutil.NewMockT()
+	assert(mock)
+	if !mock.Failed() {
+		t.Fatal("Expected the assertion to fail")
+	}
+	if got.Output != mock.GetOutput() {
+		t.Errorf("Output should match what was reported to the test.\nHook: %s\nTest: %s", got.Output, mock.GetOutput())
+	}
+	return got
+}
+
+func TestWithCallerSkip(t *testing.T) {
+	t.Setenv("DIAGASSERT_STACK_DEPTH", "")
+	t.Setenv("NO_COLOR", "1")
+
+	var line int
+	failure := captureFailure(t, func(mock *testutil.MockT) {
+		line = lineHere() + 1
+		checkOrder(mock, 0)
+	})
+
+	if !strings.HasSuffix(failure.File, "stack_test.go") || failure.Line != line {
+		t.Errorf("Failure should be reported at the caller of the helpers, stack_test.go:%d, got %s:%d", line, failure.File, failure.Line)
+	}
+	if failure.Expression != "n > 0" {
+		t.Errorf("Expression should be read from the assertion, got %q", failure.Expression)
+	}
+	if !strings.Contains(failure.Output, "ASSERTION FAILED at stack_test.go:"+strconv.Itoa(line)) {
+		t.Errorf("Header should name the caller.\nOutput:\n%s", failure.Output)
+	}
+
+	if len(failure.Stack) != 3 {
+		t.Fatalf("Stack should bridge the assertion and the caller in 3 frames, got %q", failure.Stack)
+	}
+	for i, fn := range []string{"diagassert.requirePositive", "diagassert.checkOrder", "diagassert.TestWithCallerSkip.func1"} {
+		if !strings.HasSuffix(failure.Stack[i], " "+fn) {
+			t.Errorf("Stack[%d] = %q, want a frame of %s", i, failure.Stack[i], fn)
+		}
+	}
+	for _, want := range []string{"\nSTACK:\n  ", "STACK_START\n", "stack_test.go:" + strconv.Itoa(line) + " diagassert.TestWithCallerSkip.func1\nSTACK_END\n"} {
+		if !strings.Contains(failure.Output, want) {
+			t.Errorf("Output should contain %q.\nOutput:\n%s", want, failure.Output)
+		}
+	}
+}
+
+func checkTotal(t TestingT, ok bool) {
+	t.Helper()
+	AssertSkip(t, 1, ok)
+}
+
+func checkDeep(t TestingT, ok bool) {
+	t.Helper()
+	RequireSkip(t, 2, ok)
+}
+
+func verify(t TestingT, label string, cond bool) {
+	t.Helper()
+	checkDeep(t, cond)
+}
+
+func TestAssertSkip(t *testing.T) {
+	t.Setenv("DIAGASSERT_STACK_DEPTH", "")
+	t.Setenv("NO_COLOR", "1")
+
+	t.Run("expression passed to the helper", func(t *testing.T) {
+		var line int
+		failure := captureFailure(t, func(mock *testutil.MockT) {
+			total := 3
+			line = lineHere() + 1
+			checkTotal(mock, total > 10)
+		})
+		if failure.Line != line || failure.Expression != "total > 10" {
+			t.Errorf("Failure = line %d %q, want line %d %q", failure.Line, failure.Expression, line, "total > 10")
+		}
+		if !strings.Contains(failure.Output, "assert(total > 10)") {
+			t.Errorf("Diagram should show the caller's expression.\nOutput:\n%s", failure.Output)
+		}
+	})
+
+	t.Run("expression passed through several helpers", func(t *testing.T) {
+		var line int
+		mock := testutil.NewMockT()
+		var got FailureInfo
+		remove := OnFailure(func(f FailureInfo) { got = f })
+		defer remove()
+		func() {
+			defer func() { recover() }()
+			ready := false
+			line = lineHere() + 1
+			verify(mock, "ready", ready && mock != nil)
+		}()
+		if got.Line != line || got.Expression != "ready && mock != nil" {
+			t.Errorf("Failure = line %d %q, want line %d %q", got.Line, got.Expression, line, "ready && mock != nil")
+		}
+		if !mock.Failed() || len(got.Stack) != 3 {
+			t.Errorf("RequireSkip should fail the test with a stack of 3 frames, got %q", got.Stack)
+		}
+	})
+
+	t.Run("expression built by the helper", func(t *testing.T) {
+		failure := captureFailure(t, func(mock *testutil.MockT) { checkOrder(mock, 0) })
+		if failure.Expression != "n > 0" {
+			t.Errorf("Expression = %q, want the helper's own n > 0", failure.Expression)
+		}
+	})
+}
+
+func TestStackDepth(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	t.Run("no stack by default", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_STACK_DEPTH", "")
+		failure := captureFailure(t, func(mock *testutil.MockT) { requirePositive(mock, 0) })
+		if len(failure.Stack) != 0 || strings.Contains(failure.Output, "STACK") {
+			t.Errorf("Stack should be off by default, got %q.\nOutput:\n%s", failure.Stack, failure.Output)
+		}
+		if failure.Expression != "n > 0" || !strings.HasSuffix(failure.File, "stack_test.go") {
+			t.Errorf("Failure should be reported at the assertion, got %s:%d %q", failure.File, failure.Line, failure.Expression)
+		}
+	})
+
+	t.Run("frames above the assertion", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_STACK_DEPTH", "1")
+		failure := captureFailure(t, func(mock *testutil.MockT) { checkOrder(mock, 0) })
+		// The skip of 2 bridges three frames; the depth adds one more above the reported caller
+		if len(failure.Stack) != 4 || !strings.HasSuffix(failure.Stack[3], " diagassert.captureFailure") {
+			t.Errorf("Stack = %q, want 4 frames ending in captureFailure", failure.Stack)
+		}
+	})
+
+	t.Run("frames outside the module are left out", func(t *testing.T) {
+		t.Setenv("DIAGASSERT_STACK_DEPTH", "10")
+		failure := captureFailure(t, func(mock *testutil.MockT) { requirePositive(mock, 0) })
+		for _, frame := range failure.Stack {
+			if strings.Contains(frame, " testing.") || strings.Contains(frame, " runtime.") {
+				t.Errorf("Stack should only hold frames of the module, got %q", failure.Stack)
+			}
+		}
+		if len(failure.Stack) != 4 || !strings.HasSuffix(failure.Stack[3], " diagassert.TestStackDepth.func3") {
+			t.Errorf("Stack = %q, want the helper, the closure, captureFailure and the subtest", failure.Stack)
+		}
+	})
+}
+
+func TestInModule(t *testing.T) {
+	tests := []struct {
+		function, module string
+		want             bool
+	}{
+		{"example.com/app.TestUser", "example.com/app", true},
+		{"example.com/app/user.Check", "example.com/app", true},
+		{"example.com/app_test.TestUser", "example.com/app", true},
+		{"example.com/apple.Run", "example.com/app", false},
+		{"testing.tRunner", "example.com/app", false},
+		{"main.helper", "", true},
+		{"runtime.goexit", "", false},
+	}
+	for _, tt := range tests {
+		if got := inModule(tt.function, tt.module); got != tt.want {
+			t.Errorf("inModule(%q, %q) = %v, want %v", tt.function, tt.module, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package diagassert
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/paveg/diagassert/internal/config"
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/formatter"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// Workers labels the goroutines of a test, so that an assertion giving up on them lists
+// those still running under WORKERS. Its zero value is ready to use:
+//
+//	var workers diagassert.Workers
+//	workers.Go("indexer", index)
+//	g.Go(workers.Track("fetch users", fetchUsers))
+//	diagassert.Completes(t, g, time.Second, &workers)
+//
+// It waits for the goroutines it started or tracked as a sync.WaitGroup would, so it can be
+// passed to Drained itself. Passed to any assertion in its trailing args, its outstanding
+// workers are listed when the assertion fails.
+type Workers struct {
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running map[*worker]struct{}
+}
+
+// worker is one labeled goroutine of Workers.
+type worker struct {
+	label   string
+	started time.Time
+}
+
+// Go runs fn in a goroutine labeled label.
+func (w *Workers) Go(label string, fn func()) {
+	done := w.Start(label)
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// Track labels fn, to be run by another group such as an errgroup.Group, from the time it
+// is tracked until it returns.
+func (w *Workers) Track(label string, fn func() error) func() error {
+	done := w.Start(label)
+	return func() error {
+		defer done()
+		return fn()
+	}
+}
+
+// Start counts a worker labeled label as running until the returned function is called,
+// for goroutines started by hand:
+//
+//	done := workers.Start("listener")
+//	go func() { defer done(); serve() }()
+func (w *Workers) Start(label string) (done func()) {
+	wk := &worker{label: label, started: time.Now()}
+	w.wg.Add(1)
+	w.mu.Lock()
+	if w.running == nil {
+		w.running = make(map[*worker]struct{})
+	}
+	w.running[wk] = struct{}{}
+	w.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mu.Lock()
+			delete(w.running, wk)
+			w.mu.Unlock()
+			w.wg.Done()
+		})
+	}
+}
+
+// Wait blocks until every worker is done.
+func (w *Workers) Wait() {
+	w.wg.Wait()
+}
+
+// Outstanding returns the labels of the workers still running, the longest running first,
+// with how long they have been running: "indexer (running for 1.2s)".
+func (w *Workers) Outstanding() []string {
+	w.mu.Lock()
+	workers := make([]*worker, 0, len(w.running))
+	for wk := range w.running {
+		workers = append(workers, wk)
+	}
+	w.mu.Unlock()
+
+	sort.Slice(workers, func(i, j int) bool {
+		if !workers[i].started.Equal(workers[j].started) {
+			return workers[i].started.Before(workers[j].started)
+		}
+		return workers[i].label < workers[j].label
+	})
+	lines := make([]string, len(workers))
+	for i, wk := range workers {
+		lines[i] = fmt.Sprintf("%s (running for %s)", wk.label, time.Since(wk.started).Round(time.Millisecond))
+	}
+	return lines
+}
+
+// Drained waits up to timeout for wg, a *sync.WaitGroup or *Workers, to reach zero, and
+// outputs detailed diagnostic information if it does not:
+//
+//	Drained(t, &wg, time.Second, &workers)
+//
+// The failure shows how long it waited and, for Workers in wg or in the trailing args, the
+// workers still running. A goroutine is left waiting on wg once it times out. Trailing args
+// are handled as in Assert.
+func Drained(t TestingT, wg interface{ Wait() }, timeout time.Duration, args ...interface{}) {
+	t.Helper()
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	if waitFor(done, timeout) {
+		recordAssertion(true, "")
+		return
+	}
+	recordAssertion(false, "")
+
+	ctx := newContext(t, args)
+	if w, ok := wg.(*Workers); ok && !containsWorkers(ctx.workers, w) {
+		ctx.workers = append([]*Workers{w}, ctx.workers...)
+	}
+	failure := buildSyncFailureInfo("Drained", "%[1]s did not reach zero within %[2]s", wg, timeout, time.Since(start), ctx)
+	reportFailure(t, failure, false)
+}
+
+// Completes waits up to timeout for group's Wait, as that of an errgroup.Group, to return,
+// and returns its error. It outputs detailed diagnostic information if Wait does not return
+// in time:
+//
+//	err := Completes(t, g, time.Second, &workers)
+//
+// The failure shows how long it waited and the workers still running of the Workers in the
+// trailing args. The error is nil after a timeout, and a goroutine is left waiting on group.
+// Trailing args are handled as in Assert.
+func Completes(t TestingT, group interface{ Wait() error }, timeout time.Duration, args ...interface{}) error {
+	t.Helper()
+
+	start := time.Now()
+	done := make(chan struct{})
+	var err error
+	go func() {
+		err = group.Wait()
+		close(done)
+	}()
+	if waitFor(done, timeout) {
+		recordAssertion(true, "")
+		return err
+	}
+	recordAssertion(false, "")
+
+	failure := buildSyncFailureInfo("Completes", "%[1]s did not complete within %[2]s", group, timeout, time.Since(start), newContext(t, args))
+	reportFailure(t, failure, false)
+	return nil
+}
+
+// Unlocked checks that mu, such as a *sync.Mutex or *sync.RWMutex, can be locked within
+// timeout, and outputs detailed diagnostic information if it stays held, as it does after a
+// missing Unlock:
+//
+//	Unlocked(t, &cache.mu, 100*time.Millisecond, &workers)
+//
+// Unlocked takes the lock for a moment when it is free. Trailing args are handled as in
+// Assert.
+func Unlocked(t TestingT, mu interface {
+	TryLock() bool
+	Unlock()
+}, timeout time.Duration, args ...interface{}) {
+	t.Helper()
+
+	start := time.Now()
+	for {
+		if mu.TryLock() {
+			mu.Unlock()
+			recordAssertion(true, "")
+			return
+		}
+		if time.Since(start) >= timeout {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	recordAssertion(false, "")
+
+	failure := buildSyncFailureInfo("Unlocked", "%[1]s was still held after %[2]s", mu, timeout, time.Since(start), newContext(t, args))
+	reportFailure(t, failure, false)
+}
+
+// containsWorkers reports whether list holds w.
+func containsWorkers(list []*Workers, w *Workers) bool {
+	for _, l := range list {
+		if l == w {
+			return true
+		}
+	}
+	return false
+}
+
+// syncDescription stands in for a value a sync helper waited on, whose fields other
+// goroutines may still be changing, in the diagram.
+type syncDescription string
+
+func (d syncDescription) String() string { return string(d) }
+
+// describeSync describes value by its type and, for Workers, how many are running, without
+// reading its fields.
+func describeSync(value interface{}) syncDescription {
+	if w, ok := value.(*Workers); ok {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return syncDescription(fmt.Sprintf("%T (%d running)", value, len(w.running)))
+	}
+	return syncDescription(fmt.Sprintf("%T", value))
+}
+
+// waitFor reports whether done is closed within timeout.
+func waitFor(done <-chan struct{}, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// buildSyncFailureInfo builds diagnostic information for a failed Drained, Completes or
+// Unlocked, named by helper. problem is the note of the failure, with indexed verbs for the
+// value waited on and the timeout.
+func buildSyncFailureInfo(helper, problem string, value interface{}, timeout, waited time.Duration, ctx *AssertionContext) FailureInfo {
+	defer config.Hold()()
+	site, _ := locateCall(2, ctx)
+	file, line := site.reportFile, site.reportLine
+
+	// Without the source the value and the timeout are named generically
+	text, timeoutText := "value", "timeout"
+	if args, err := parser.ExtractCallArguments(site.file, site.line, helper); err == nil && len(args) >= 3 {
+		text, timeoutText = args[1], args[2]
+	}
+
+	result := evaluator.EvaluateWait(helper, text, timeoutText, describeSync(value), timeout, fmt.Sprintf(problem, text, timeout))
+	evaluator.Redact(result)
+	ctx.sections = append(ctx.sections, formatter.Section{Title: "WAIT", Lines: []string{
+		fmt.Sprintf("waited %s of %s", waited.Round(time.Microsecond), timeout),
+	}})
+
+	writeAttachments(ctx.Attachments, ctx.test, file, line)
+	return FailureInfo{
+		File:        file,
+		Line:        line,
+		Expression:  result.Expression,
+		Variables:   result.Variables,
+		tree:        result.Tree,
+		Messages:    ctx.Messages,
+		Attachments: ctx.Attachments,
+		Stack:       site.stack,
+		writers:     ctx.Writers,
+		maxRepeats:  ctx.MaxRepeats,
+		fingerprint: formatter.Fingerprint(result, file, line),
+		Output: formatter.BuildDiagnosticOutputWithEvaluatorAndContext(file, line, result,
+			toFormatterContext(ctx), formatter.GetDefaultOptions()),
+	}
+}
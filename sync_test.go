@@ -0,0 +1,145 @@
+package diagassert
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// group waits like an errgroup.Group.
+type group struct {
+	wg  sync.WaitGroup
+	err error
+}
+
+func (g *group) Go(fn func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := fn(); err != nil {
+			g.err = err
+		}
+	}()
+}
+
+func (g *group) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+func TestWorkers_Outstanding(t *testing.T) {
+	var workers Workers
+	release := make(chan struct{})
+	workers.Go("indexer", func() { <-release })
+	done := workers.Start("listener")
+	workers.Go("quick", func() {})
+
+	time.Sleep(10 * time.Millisecond)
+	outstanding := workers.Outstanding()
+	if len(outstanding) != 2 || !strings.HasPrefix(outstanding[0], "indexer (running for ") || !strings.HasPrefix(outstanding[1], "listener (running for ") {
+		t.Errorf("Outstanding = %q, want indexer and listener", outstanding)
+	}
+
+	close(release)
+	done()
+	done()
+	workers.Wait()
+
+	if outstanding := workers.Outstanding(); len(outstanding) != 0 {
+		t.Errorf("Outstanding = %q once every worker is done", outstanding)
+	}
+}
+
+func TestDrained(t *testing.T) {
+	t.Run("wait group reaching zero", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go wg.Done()
+		Drained(mock, &wg, time.Second)
+
+		if mock.Failed() {
+			t.Errorf("Drained should pass, got: %s", mock.GetOutput())
+		}
+	})
+
+	t.Run("outstanding workers", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		var workers Workers
+		release := make(chan struct{})
+		defer close(release)
+		workers.Go("indexer", func() { <-release })
+		workers.Go("quick", func() {})
+		time.Sleep(10 * time.Millisecond)
+		Drained(mock, &workers, 10*time.Millisecond, &workers)
+
+		output := mock.GetOutput()
+		expected := []string{
+			"assert(Drained(&workers, 10*time.Millisecond))",
+			"&workers did not reach zero within 10ms",
+			"WAIT:\n  waited 1",
+			"WORKERS:\n  indexer (running for ",
+			"WORKERS_START",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+		if strings.Contains(output, "quick") || strings.Count(output, "indexer (running") != 2 {
+			t.Errorf("Only the outstanding worker should be listed, once per section, got: %s", output)
+		}
+	})
+}
+
+func TestCompletes(t *testing.T) {
+	t.Run("returns the error of the group", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		var g group
+		g.Go(func() error { return errors.New("fetch failed") })
+
+		if err := Completes(mock, &g, time.Second); err == nil || err.Error() != "fetch failed" || mock.Failed() {
+			t.Errorf("Completes = %v, failed: %v, output: %s", err, mock.Failed(), mock.GetOutput())
+		}
+	})
+
+	t.Run("tracked workers of a hanging group", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		var g group
+		var workers Workers
+		release := make(chan struct{})
+		defer close(release)
+		g.Go(workers.Track("fetch users", func() error { <-release; return nil }))
+		g.Go(workers.Track("fetch orders", func() error { return nil }))
+
+		if err := Completes(mock, &g, 10*time.Millisecond, &workers); err != nil {
+			t.Errorf("Completes should return nil after a timeout, got %v", err)
+		}
+		output := mock.GetOutput()
+		for _, part := range []string{"&g did not complete within 10ms", "WORKERS:\n  fetch users (running for "} {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+	})
+}
+
+func TestUnlocked(t *testing.T) {
+	var mu sync.Mutex
+	mock := testutil.NewMockT()
+	Unlocked(mock, &mu, 10*time.Millisecond)
+	if mock.Failed() {
+		t.Fatalf("Unlocked should pass for a free mutex, got: %s", mock.GetOutput())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	Unlocked(mock, &mu, 10*time.Millisecond)
+	if output := mock.GetOutput(); !strings.Contains(output, "&mu was still held after 10ms") {
+		t.Errorf("Output should tell the mutex is held, got: %s", output)
+	}
+}
@@ -0,0 +1,214 @@
+package diagassert
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert/internal/evaluator"
+	"github.com/paveg/diagassert/internal/parser"
+)
+
+// Table runs fn over each case in a subtest of its own, as a table-driven test's loop over
+// t.Run does, and adds the fields of the case to the values of every failure in it:
+//
+//	type parseCase struct {
+//		name string
+//		in   string
+//		want int
+//	}
+//
+//	diagassert.Table(t, []parseCase{
+//		{name: "empty", in: "", want: 0},
+//		{name: "digits", in: "42", want: 42},
+//	}, func(t *testing.T, tc parseCase) {
+//		diagassert.Assert(t, parse(tc.in) == tc.want)
+//	})
+//
+// A failure then shows tc.name, tc.in and tc.want, named after fn's parameter, without V.
+// Subtests are named after the field tagged `diag:"name"`, or else the field called name or
+// Name, and are numbered case_0, case_1, ... when that is missing or empty. Fields tagged
+// `diag:"-"` are left out and fields tagged `diag:"redact"` are masked.
+func Table[C any, T interface {
+	TestingT
+	Run(name string, f func(T)) bool
+}](t T, cases []C, fn func(t T, tc C)) {
+	t.Helper()
+
+	for i, tc := range cases {
+		tc := tc
+		t.Run(caseName(tc, i), func(t T) {
+			t.Helper()
+			defer enterTableCase(t, tc)()
+			fn(t, tc)
+		})
+	}
+}
+
+// tableCase is the case a subtest started by Table runs.
+type tableCase struct {
+	value interface{}
+}
+
+var (
+	tableCasesMu sync.Mutex
+	tableCases   = map[TestingT]*tableCase{}
+)
+
+// enterTableCase makes tc the case of the subtest t until the returned function is called.
+func enterTableCase(t TestingT, tc interface{}) func() {
+	if !reflect.TypeOf(t).Comparable() {
+		return func() {}
+	}
+
+	tableCasesMu.Lock()
+	tableCases[t] = &tableCase{value: tc}
+	tableCasesMu.Unlock()
+
+	return func() {
+		tableCasesMu.Lock()
+		delete(tableCases, t)
+		tableCasesMu.Unlock()
+	}
+}
+
+// tableCaseOf returns the case the subtest t runs, or nil outside Table. Assertions made on
+// a SoftT or a Recorder belong to the case of the test it wraps.
+func tableCaseOf(t TestingT) *tableCase {
+	for {
+		if soft, ok := t.(*SoftT); ok {
+			t = soft.t
+		} else if recorder, ok := t.(*Recorder); ok {
+			t = recorder.t
+		} else {
+			break
+		}
+	}
+	if t == nil || !reflect.TypeOf(t).Comparable() {
+		return nil
+	}
+
+	tableCasesMu.Lock()
+	defer tableCasesMu.Unlock()
+	return tableCases[t]
+}
+
+// newContext creates the context of an assertion made on t from its trailing args,
+// including the case t runs when it is a subtest of Table and the fixtures registered for it.
+func newContext(t TestingT, args []interface{}) *AssertionContext {
+	ctx := NewAssertionContext(args...)
+	ctx.test = testName(t)
+	ctx.tableCase = tableCaseOf(t)
+	ctx.fixtures = fixturesOf(t)
+	if seed, ok := seedOf(t); ok {
+		ctx.sections = append(ctx.sections, seedSection(testName(t), seed))
+	}
+	return ctx
+}
+
+// caseName names the subtest of the case at index i.
+func caseName(tc interface{}, i int) string {
+	v := reflect.ValueOf(tc)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return fmt.Sprintf("case_%d", i)
+	}
+
+	field, ok := nameField(v.Type())
+	if !ok {
+		return fmt.Sprintf("case_%d", i)
+	}
+	names, values, _ := evaluator.StructFields(v.Interface())
+	for j, name := range names {
+		if name != field {
+			continue
+		}
+		if text := fmt.Sprint(values[j]); text != "" {
+			return text
+		}
+	}
+	return fmt.Sprintf("case_%d", i)
+}
+
+// nameField returns the field of a case type subtests are named after: the one tagged
+// `diag:"name"`, or else the one called name or Name.
+func nameField(typ reflect.Type) (string, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		if evaluator.ParseFieldTag(typ.Field(i)).Name {
+			return typ.Field(i).Name, true
+		}
+	}
+	for _, name := range []string{"name", "Name"} {
+		if _, ok := typ.FieldByName(name); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// values returns the fields of the case as values named after the parameter it is passed
+// to in the function holding the assertion at file:line, e.g. tc.in and tc.want, leaving
+// out the names in provided. A nil case is shown as a single nil value.
+func (c *tableCase) values(file string, line int, provided map[string]interface{}) []Value {
+	v := reflect.ValueOf(c.value)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		name := caseParam(file, line, reflect.TypeOf(c.value))
+		if _, ok := provided[name]; ok {
+			return nil
+		}
+		return []Value{{Name: name, Value: nil}}
+	}
+	names, fieldValues, ok := evaluator.StructFields(v.Interface())
+	if !ok {
+		return nil
+	}
+
+	param := caseParam(file, line, v.Type())
+	var values []Value
+	for i, name := range names {
+		name = param + "." + name
+		if _, ok := provided[name]; ok {
+			continue
+		}
+		values = append(values, Value{Name: name, Value: fieldValues[i]})
+	}
+	return values
+}
+
+// caseParam returns the name of the case parameter in the function holding file:line: the
+// last parameter of the innermost function whose last parameter has the case's type, or of
+// the innermost one with two parameters, as fn has. Without the source it is tc.
+func caseParam(file string, line int, typ reflect.Type) string {
+	funcs, err := parser.EnclosingParams(file, line)
+	if err != nil {
+		return "tc"
+	}
+
+	for _, params := range funcs {
+		if n := len(params); n >= 2 && params[n-1].Name != "" && isTypeText(params[n-1].Type, typ) {
+			return params[n-1].Name
+		}
+	}
+	for _, params := range funcs {
+		if len(params) == 2 && params[1].Name != "" && params[1].Name != "_" {
+			return params[1].Name
+		}
+	}
+	return "tc"
+}
+
+// isTypeText reports whether text, the source of a parameter's type, names typ, with or
+// without its package and as a pointer or not.
+func isTypeText(text string, typ reflect.Type) bool {
+	text = strings.TrimPrefix(text, "*")
+	if i := strings.LastIndex(text, "."); i >= 0 {
+		text = text[i+1:]
+	}
+	return typ != nil && typ.Name() != "" && text == typ.Name()
+}
@@ -0,0 +1,146 @@
+package diagassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert/internal/testutil"
+)
+
+// subT is a test with subtests, as *testing.T is, running them in turn.
+type subT struct {
+	*testutil.MockT
+	names []string
+	subs  []*subT
+}
+
+func (s *subT) Run(name string, f func(*subT)) bool {
+	sub := &subT{MockT: testutil.NewMockT()}
+	s.names = append(s.names, name)
+	s.subs = append(s.subs, sub)
+	f(sub)
+	return !sub.Failed()
+}
+
+type parseCase struct {
+	name   string
+	in     string
+	want   int
+	token  string `diag:"redact"`
+	parsed bool   `diag:"-"`
+}
+
+func TestTable(t *testing.T) {
+	t.Run("failures show the fields of the case", func(t *testing.T) {
+		parent := &subT{MockT: testutil.NewMockT()}
+		cases := []parseCase{
+			{name: "empty", in: "", want: 0, token: "s3cr3t"},
+			{name: "digits", in: "42", want: 41, token: "s3cr3t"},
+		}
+		Table(parent, cases, func(t *subT, c parseCase) {
+			Assert(t, len(c.in) == c.want)
+		})
+
+		if got := strings.Join(parent.names, ","); got != "empty,digits" {
+			t.Errorf("subtests = %q, want empty,digits", got)
+		}
+		if parent.subs[0].Failed() {
+			t.Errorf("the first case should pass, got: %s", parent.subs[0].GetOutput())
+		}
+
+		output := parent.subs[1].GetOutput()
+		for _, want := range []string{"c.name = digits (string)", "c.in = 42 (string)", "c.want = 41 (int)", "c.token = ***"} {
+			if !strings.Contains(output, want) {
+				t.Errorf("output should contain %q, got:\n%s", want, output)
+			}
+		}
+		for _, unwanted := range []string{"s3cr3t", "c.parsed"} {
+			if strings.Contains(output, unwanted) {
+				t.Errorf("output should not contain %q, got:\n%s", unwanted, output)
+			}
+		}
+	})
+
+	t.Run("provided values take precedence", func(t *testing.T) {
+		parent := &subT{MockT: testutil.NewMockT()}
+		Table(parent, []parseCase{{name: "one", in: "1", want: 2}}, func(t *subT, tc parseCase) {
+			Assert(t, len(tc.in) == tc.want, V("tc.want", "two"))
+		})
+
+		output := parent.subs[0].GetOutput()
+		if !strings.Contains(output, "tc.want = two (string)") || strings.Contains(output, "tc.want = 2 (int)") {
+			t.Errorf("the provided tc.want should be shown, got:\n%s", output)
+		}
+	})
+
+	t.Run("nil cases are shown as nil", func(t *testing.T) {
+		parent := &subT{MockT: testutil.NewMockT()}
+		Table(parent, []*parseCase{nil}, func(t *subT, tc *parseCase) {
+			Assert(t, tc != nil)
+		})
+
+		output := parent.subs[0].GetOutput()
+		if !strings.Contains(output, "tc = <nil>") {
+			t.Errorf("output should show the nil case, got:\n%s", output)
+		}
+	})
+
+	t.Run("soft and recorded assertions show the case", func(t *testing.T) {
+		parent := &subT{MockT: testutil.NewMockT()}
+		Table(parent, []parseCase{{name: "soft", in: "1", want: 2}, {name: "recorded", in: "12", want: 3}}, func(t *subT, tc parseCase) {
+			if tc.name == "soft" {
+				soft := Soft(t)
+				Assert(soft, len(tc.in) == tc.want)
+				soft.Report()
+				return
+			}
+			rec := NewRecorder(t)
+			Assert(rec, len(tc.in) == tc.want)
+			rec.Flush()
+		})
+
+		for i, want := range []string{"tc.name = soft (string)", "tc.name = recorded (string)"} {
+			if output := parent.subs[i].GetOutput(); !strings.Contains(output, want) {
+				t.Errorf("output should contain %q, got:\n%s", want, output)
+			}
+		}
+	})
+
+	t.Run("assertions outside Table show no case", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		tc := parseCase{in: "1"}
+		Assert(mock, len(tc.in) == 0)
+
+		if strings.Contains(mock.GetOutput(), "tc.name") {
+			t.Errorf("output should not contain case fields, got:\n%s", mock.GetOutput())
+		}
+	})
+}
+
+func TestCaseName(t *testing.T) {
+	type tagged struct {
+		Name  string
+		Title string `diag:"name"`
+	}
+	type unnamed struct{ In string }
+
+	tests := []struct {
+		tc   interface{}
+		want string
+	}{
+		{parseCase{name: "empty"}, "empty"},
+		{&parseCase{name: "pointer"}, "pointer"},
+		{parseCase{}, "case_3"},
+		{tagged{Name: "name", Title: "title"}, "title"},
+		{unnamed{In: "x"}, "case_3"},
+		{42, "case_3"},
+		{nil, "case_3"},
+		{(*parseCase)(nil), "case_3"},
+	}
+
+	for _, tt := range tests {
+		if got := caseName(tt.tc, 3); got != tt.want {
+			t.Errorf("caseName(%#v) = %q, want %q", tt.tc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package tapdiag reports tests in the Test Anything Protocol (TAP version 13), so that
+// suites using diagassert can feed TAP consumers. Each tracked test becomes a test point;
+// a failed test carries the structured data of its failed assertions in a YAML block:
+//
+//	TAP version 13
+//	not ok 1 - TestUser
+//	  ---
+//	  failures:
+//	    - expr: "user.Age >= 18"
+//	      file: "/src/app/user_test.go"
+//	      line: 12
+//	      variables:
+//	        "user.Age": 16
+//	      steps:
+//	        - "`user.Age` => 16 [node 1c9f0e3a]"
+//	  ...
+//	ok 2 - TestOrder
+//	1..2
+//
+// Usage:
+//
+//	var tap = tapdiag.New(os.Stdout)
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		tap.Close()
+//		os.Exit(code)
+//	}
+//
+//	func TestUser(t *testing.T) {
+//		tap.Track(t)
+//		diagassert.Assert(t, user.Age >= 18)
+//	}
+package tapdiag
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/paveg/diagassert"
+)
+
+// TB is the part of testing.TB the reporter needs.
+type TB interface {
+	Name() string
+	Failed() bool
+	Cleanup(func())
+}
+
+// Reporter writes a TAP stream with one test point per tracked test.
+type Reporter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	count    int
+	failures map[string][]diagassert.FailureInfo
+	remove   func()
+}
+
+// New writes the TAP version line to w and starts collecting failed assertions.
+func New(w io.Writer) *Reporter {
+	r := &Reporter{w: w, failures: map[string][]diagassert.FailureInfo{}}
+	fmt.Fprintln(w, "TAP version 13")
+	r.remove = diagassert.OnFailure(r.record)
+	return r
+}
+
+// Track emits a test point for t when it finishes. Subtests become test points of their
+// own only when they are tracked too.
+func (r *Reporter) Track(t TB) {
+	t.Cleanup(func() {
+		r.point(t.Name(), t.Failed())
+	})
+}
+
+// Close writes the plan line and stops collecting failures.
+func (r *Reporter) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove()
+	fmt.Fprintf(r.w, "1..%d\n", r.count)
+}
+
+func (r *Reporter) record(f diagassert.FailureInfo) {
+	if f.Test == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[f.Test] = append(r.failures[f.Test], f)
+}
+
+func (r *Reporter) point(name string, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.count++
+	failures := r.failures[name]
+	delete(r.failures, name)
+
+	if !failed {
+		fmt.Fprintf(r.w, "ok %d - %s\n", r.count, name)
+		return
+	}
+
+	fmt.Fprintf(r.w, "not ok %d - %s\n", r.count, name)
+	if len(failures) > 0 {
+		io.WriteString(r.w, diagnostics(failures))
+	}
+}
+
+// diagnostics renders failures as a YAML block indented under a test point.
+func diagnostics(failures []diagassert.FailureInfo) string {
+	var b strings.Builder
+	b.WriteString("  ---\n  failures:\n")
+	for _, f := range failures {
+		b.WriteString(fmt.Sprintf("    - expr: %s\n", strconv.Quote(f.Expression)))
+		b.WriteString(fmt.Sprintf("      file: %s\n", strconv.Quote(f.File)))
+		b.WriteString(fmt.Sprintf("      line: %d\n", f.Line))
+
+		if len(f.Messages) > 0 {
+			b.WriteString("      messages:\n")
+			for _, m := range f.Messages {
+				b.WriteString(fmt.Sprintf("        - %s\n", strconv.Quote(m)))
+			}
+		}
+
+		if len(f.Variables) > 0 {
+			names := make([]string, 0, len(f.Variables))
+			for name := range f.Variables {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			b.WriteString("      variables:\n")
+			for _, name := range names {
+				b.WriteString(fmt.Sprintf("        %s: %s\n", strconv.Quote(name), yamlValue(f.Variables[name])))
+			}
+		}
+
+		if len(f.Steps) > 0 {
+			b.WriteString("      steps:\n")
+			for _, step := range f.Steps {
+				b.WriteString(fmt.Sprintf("        - %s\n", strconv.Quote(step)))
+			}
+		}
+	}
+	b.WriteString("  ...\n")
+	return b.String()
+}
+
+// yamlValue writes numbers, booleans and nil as YAML scalars and quotes everything else.
+func yamlValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case string:
+		return strconv.Quote(v)
+	}
+	return strconv.Quote(fmt.Sprintf("%v", v))
+}
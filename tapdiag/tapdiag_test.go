@@ -0,0 +1,101 @@
+package tapdiag
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/paveg/diagassert"
+)
+
+// fakeT is a named TestingT whose cleanups run when finish is called.
+type fakeT struct {
+	name     string
+	failed   bool
+	cleanups []func()
+}
+
+func (f *fakeT) Error(args ...interface{}) { f.failed = true }
+func (f *fakeT) Fatal(args ...interface{}) { f.failed = true }
+func (f *fakeT) Helper()                   {}
+func (f *fakeT) Name() string              { return f.name }
+func (f *fakeT) Failed() bool              { return f.failed }
+func (f *fakeT) Cleanup(fn func())         { f.cleanups = append(f.cleanups, fn) }
+
+func (f *fakeT) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(&buf)
+
+	failing := &fakeT{name: "TestFailing"}
+	r.Track(failing)
+	age := 16
+	diagassert.Assert(failing, age >= 18, diagassert.V("age", age), "must be an adult")
+	failing.finish()
+
+	passing := &fakeT{name: "TestPassing"}
+	r.Track(passing)
+	diagassert.Assert(passing, age < 18)
+	passing.finish()
+
+	r.Close()
+
+	output := buf.String()
+	expected := []string{
+		"TAP version 13\n",
+		"not ok 1 - TestFailing\n  ---\n  failures:\n",
+		`    - expr: "age >= 18"`,
+		"      file: \"",
+		"tapdiag_test.go\"\n      line: ",
+		"      messages:\n        - \"must be an adult\"\n",
+		"      variables:\n        \"age\": 16\n",
+		"      steps:\n        - \"`age` => 16 [node ",
+		"  ...\nok 2 - TestPassing\n1..2\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(output, part) {
+			t.Errorf("Output should contain %q, got:\n%s", part, output)
+		}
+	}
+}
+
+func TestReporterWithoutDiagnostics(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(&buf)
+
+	failing := &fakeT{name: "TestPlainError"}
+	r.Track(failing)
+	failing.Error("boom")
+	failing.finish()
+	r.Close()
+
+	want := "TAP version 13\nnot ok 1 - TestPlainError\n1..1\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestYAMLValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{true, "true"},
+		{"a \"b\"", `"a \"b\""`},
+		{[]int{1, 2}, `"[1 2]"`},
+		{fmt.Errorf("x: y"), `"x: y"`},
+	}
+	for _, tt := range tests {
+		if got := yamlValue(tt.value); got != tt.want {
+			t.Errorf("yamlValue(%#v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -22,7 +22,13 @@
 //	diagassert.Assert(t, expr)
 package diagassert
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/paveg/diagassert/internal/formatter"
+)
 
 // Value represents a named value for diagnostic output
 type Value struct {
@@ -44,10 +50,51 @@ func V(name string, value interface{}) Value {
 // Usage: diagassert.Assert(t, expr, diagassert.Values{"x": x, "y": y})
 type Values map[string]interface{}
 
+// DiagStringer is implemented by values that describe themselves for diagnostics. Values are
+// shown by their DiagString method first, then by Error for errors and String for
+// fmt.Stringers, and otherwise by their fields. DIAGASSERT_RAW_VALUES=true shows every value
+// by its fields.
+type DiagStringer interface {
+	DiagString() string
+}
+
+// CmpOpts carries go-cmp options for an assertion. See CmpOptions.
+type CmpOpts []interface{}
+
+// CmpOptions passes go-cmp options, such as cmpopts.IgnoreFields, to the diff shown for a
+// failed == on composite values. The options only shape the diff: the expression still
+// decides whether the assertion fails. They are ignored unless the cmpdiag adapter is built
+// in with the diagassert_gocmp tag.
+//
+// Usage: diagassert.Assert(t, got == want, diagassert.CmpOptions(cmpopts.IgnoreFields(User{}, "UpdatedAt")))
+func CmpOptions(opts ...interface{}) CmpOpts {
+	return CmpOpts(opts)
+}
+
 // AssertionContext holds additional context for assertions
 type AssertionContext struct {
-	Values   []Value
-	Messages []string
+	Values          []Value
+	Messages        []string
+	Attachments     []Attachment
+	CmpOptions      []interface{} // Options for the go-cmp adapter, from CmpOptions
+	CallerSkip      int           // Frames above the assertion its failure is reported at, from WithCallerSkip
+	Writers         []io.Writer   // Writers the failure is also written to, from OutputTo
+	MaxRepeats      int           // Failures reported in full before repeats are summarized, from WithMaxRepeats; 0 for the default
+	EnvironmentKeys []string      // Environment variables for the RUNTIME section, from WithEnvironmentKeys
+
+	stack      []string            // Frames for the STACK section, set once the failure is located
+	sections   []formatter.Section // Sections added by the assertion, such as Must's ERROR CHAIN
+	tableCase  *tableCase          // Case of the Table subtest the assertion is made in
+	owners     []string            // Owners of the reported file, from DIAGASSERT_CODEOWNERS
+	workers    []*Workers          // Workers whose outstanding goroutines the failure lists
+	runtime    bool                // The RUNTIME section was asked for with WithEnvironmentKeys
+	environ    []string            // Lines of the RUNTIME section, set once the failure is located
+	now        *time.Time          // Time the assertion was made at, from Clock
+	times      []Value             // Times the assertion compared, listed under CLOCK
+	clockNotes []string            // Notes on how the times compared, listed under CLOCK
+	negated    bool                // The expression is asserted to be false, by Not
+	fixtures   []Value             // Fixtures registered for the test with Fixture, listed under FIXTURES
+	test       string              // Name of the test the assertion is made in, which names its attachments
 }
 
 // NewAssertionContext creates a new assertion context from variadic arguments
@@ -61,6 +108,32 @@ func NewAssertionContext(args ...interface{}) *AssertionContext {
 		switch v := arg.(type) {
 		case Value:
 			ctx.Values = append(ctx.Values, v)
+		case Attachment:
+			ctx.Attachments = append(ctx.Attachments, v)
+		case CmpOpts:
+			ctx.CmpOptions = append(ctx.CmpOptions, v...)
+		case Clock:
+			if v != nil {
+				now := v()
+				ctx.now = &now
+			}
+		case EqualRule:
+			// Read by Equal
+		case *Workers:
+			ctx.workers = append(ctx.workers, v)
+		case CallerSkip:
+			ctx.CallerSkip += int(v)
+		case MaxRepeats:
+			ctx.MaxRepeats = int(v)
+		case EnvironmentKeys:
+			ctx.EnvironmentKeys = append(ctx.EnvironmentKeys, v...)
+			ctx.runtime = true
+		case OutputWriter:
+			if v.w != nil {
+				ctx.Writers = append(ctx.Writers, v.w)
+			}
+		case FieldValues:
+			ctx.Values = append(ctx.Values, v...)
 		case Values:
 			// Convert Values map to individual Value structs
 			for name, value := range v {
@@ -89,6 +162,11 @@ func (ctx *AssertionContext) HasMessages() bool {
 	return len(ctx.Messages) > 0
 }
 
+// HasAttachments returns true if the context contains any attachments
+func (ctx *AssertionContext) HasAttachments() bool {
+	return len(ctx.Attachments) > 0
+}
+
 // GetValuesMap returns all values as a map for easy access
 func (ctx *AssertionContext) GetValuesMap() map[string]interface{} {
 	result := make(map[string]interface{})
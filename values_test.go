@@ -7,6 +7,16 @@ import (
 	"github.com/paveg/diagassert/internal/testutil"
 )
 
+type pathLimits struct{ Daily int }
+
+func (l pathLimits) Headroom() int { return l.Daily - 10 }
+
+type pathAccount struct {
+	owner  string
+	Limits pathLimits
+	Meta   interface{}
+}
+
 // TestAPI_ValueCapture tests the new API for value capture and custom messages
 func TestAPI_ValueCapture(t *testing.T) {
 	t.Run("single value capture with V()", func(t *testing.T) {
@@ -29,6 +39,30 @@ func TestAPI_ValueCapture(t *testing.T) {
 		}
 	})
 
+	t.Run("paths resolved from a root value", func(t *testing.T) {
+		mock := testutil.NewMockT()
+		account := &pathAccount{owner: "ann", Limits: pathLimits{Daily: 50}, Meta: pathLimits{Daily: 20}}
+
+		// Only the root is passed: fields, unexported ones included, type assertions and
+		// methods are read from it
+		Assert(mock, account.owner == "bob" || account.Meta.(pathLimits).Daily > account.Limits.Headroom(), V("account", account))
+
+		output := mock.GetOutput()
+		expected := []string{
+			`account.owner: "ann"`,
+			"`account.Meta.(pathLimits).Daily` => 20",
+			"`account.Limits.Headroom()` => 40",
+		}
+		for _, part := range expected {
+			if !strings.Contains(output, part) {
+				t.Errorf("Output should contain %q, got: %s", part, output)
+			}
+		}
+		if strings.Contains(output, "HINT") {
+			t.Errorf("Every path should resolve without hints, got: %s", output)
+		}
+	})
+
 	t.Run("multiple values with Values map", func(t *testing.T) {
 		mock := testutil.NewMockT()
 		x := 10
@@ -289,6 +323,64 @@ func TestAssertionContext(t *testing.T) {
 			t.Errorf("Should contain message2, got: %s", combined)
 		}
 	})
+
+	t.Run("NewAssertionContext with cmp options", func(t *testing.T) {
+		ctx := NewAssertionContext(CmpOptions("opt1", "opt2"), "message", CmpOptions("opt3"))
+
+		if len(ctx.CmpOptions) != 3 {
+			t.Errorf("Expected 3 cmp options, got %d", len(ctx.CmpOptions))
+		}
+		if ctx.HasValues() {
+			t.Error("Cmp options should not be captured as values")
+		}
+		if ctx.GetCombinedMessage() != "message" {
+			t.Errorf("Cmp options should not be part of the message, got: %s", ctx.GetCombinedMessage())
+		}
+	})
 }
 
 // Note: Using MockT and NewMockT from assert_test.go
+
+type order struct {
+	ID    int
+	Items []string
+}
+
+func (o order) DiagString() string { return "order 42" }
+
+func TestDiagStringer(t *testing.T) {
+	var _ DiagStringer = order{}
+	o := order{ID: 42, Items: []string{"book"}}
+
+	mock := testutil.NewMockT()
+	Assert(mock, o.ID == 7, V("o", o))
+	if output := mock.GetOutput(); !strings.Contains(output, "o = order 42") {
+		t.Errorf("Values should be shown by their DiagString method, got: %s", output)
+	}
+
+	t.Setenv("DIAGASSERT_RAW_VALUES", "true")
+	mock = testutil.NewMockT()
+	Assert(mock, o.ID == 7, V("o", o))
+	if output := mock.GetOutput(); !strings.Contains(output, "o = {ID:42 Items:[book]}") {
+		t.Errorf("Raw values should be shown by their fields, got: %s", output)
+	}
+}
+
+func TestPointerValues(t *testing.T) {
+	type account struct {
+		Name  string
+		Owner *struct{ ID int }
+	}
+	a := &account{Name: "Alice", Owner: &struct{ ID int }{1}}
+	pa := &a
+
+	mock := testutil.NewMockT()
+	Assert(mock, (*pa).Name == "Bob", V("pa", pa))
+	output := mock.GetOutput()
+	if !strings.Contains(output, "pa = &&{Name:Alice Owner:0x") || !strings.Contains(output, "} @0x") {
+		t.Errorf("Pointers should be shown by what they point to and their address, got: %s", output)
+	}
+	if !strings.Contains(output, "(*pa).Name") {
+		t.Errorf("Dereferences should keep their parentheses, got: %s", output)
+	}
+}